@@ -0,0 +1,127 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupprocessor // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor"
+
+import (
+	"context"
+	"sync"
+)
+
+// lookupBatch collects the records one rule enriches in a ConsumeX call.
+// Each distinct key is looked up once, the lookups run on up to
+// max_concurrency goroutines, and the results are then written back from the
+// calling goroutine, so pdata is never modified concurrently.
+type lookupBatch struct {
+	rule    *lookupRule
+	keys    []string
+	indexes map[string]int
+	pending []pendingRecord
+	// metadataValue is the lookup key of every record when the rule reads
+	// it from the client metadata.
+	metadataValue string
+	// cardinality limits the distinct values written, if set. Only batches
+	// of metrics set it.
+	cardinality *cardinalityLimiter
+}
+
+// pendingRecord is a record waiting for the lookup of keys[key].
+type pendingRecord struct {
+	key   int
+	write func(ctx context.Context, val any) error
+	// done, if set, releases the resources held for write.
+	done func()
+}
+
+// newBatch returns an empty batch for the records of the ConsumeX call with
+// context ctx.
+func (r *lookupRule) newBatch(ctx context.Context) *lookupBatch {
+	b := &lookupBatch{rule: r, indexes: map[string]int{}}
+	if r.metadataKey != "" {
+		b.metadataValue = metadataValue(ctx, r.metadataKey)
+	}
+	return b
+}
+
+// add queues write to be called with the value found for key.
+func (b *lookupBatch) add(key string, write func(ctx context.Context, val any) error, done func()) {
+	i, ok := b.indexes[key]
+	if !ok {
+		i = len(b.keys)
+		b.indexes[key] = i
+		b.keys = append(b.keys, key)
+	}
+	b.pending = append(b.pending, pendingRecord{key: i, write: write, done: done})
+}
+
+// run looks up the keys of the batch and writes the results. It returns the
+// error of the first key whose lookup failed with on_error propagate,
+// leaving every record unchanged, or else the first error writing a result,
+// leaving the remaining records unchanged.
+func (b *lookupBatch) run(ctx context.Context, maxConcurrency int) error {
+	defer b.close()
+
+	type result struct {
+		val any
+		ok  bool
+		err error
+	}
+	results := make([]result, len(b.keys))
+	lookup := func(i int) {
+		results[i].val, results[i].ok, results[i].err = b.rule.lookup(ctx, b.keys[i])
+	}
+
+	workers := min(maxConcurrency, len(b.keys))
+	if workers <= 1 {
+		for i := range b.keys {
+			lookup(i)
+		}
+	} else {
+		indexes := make(chan int)
+		var wg sync.WaitGroup
+		for range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range indexes {
+					lookup(i)
+				}
+			}()
+		}
+		for i := range b.keys {
+			indexes <- i
+		}
+		close(indexes)
+		wg.Wait()
+	}
+	for _, res := range results {
+		if res.err != nil {
+			return res.err
+		}
+	}
+
+	for _, record := range b.pending {
+		res := results[record.key]
+		if !res.ok {
+			continue
+		}
+		val := res.val
+		if b.cardinality != nil {
+			val = b.cardinality.limit(ctx, val)
+		}
+		if err := record.write(ctx, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// close releases the resources held by the pending records.
+func (b *lookupBatch) close() {
+	for _, record := range b.pending {
+		if record.done != nil {
+			record.done()
+		}
+	}
+	b.pending = nil
+}
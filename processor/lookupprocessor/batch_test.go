@@ -0,0 +1,118 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupprocessor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/confmap"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/processor/processortest"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/metadata"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+type slowSourceConfig struct{}
+
+func (*slowSourceConfig) Validate() error { return nil }
+
+// slowSource resolves every key to "host-<key>" after a delay, recording
+// how many lookups ran and how many ran at once.
+type slowSource struct {
+	delay    time.Duration
+	calls    atomic.Int64
+	mu       sync.Mutex
+	inFlight int
+	maxSeen  int
+}
+
+func (s *slowSource) factory() lookupsource.SourceFactory {
+	return lookupsource.NewSourceFactory(
+		"slow",
+		func() lookupsource.SourceConfig { return &slowSourceConfig{} },
+		func(context.Context, lookupsource.CreateSettings, lookupsource.SourceConfig) (lookupsource.Source, error) {
+			return lookupsource.NewSource(s.lookup, func() string { return "slow" }, nil, nil), nil
+		},
+	)
+}
+
+func (s *slowSource) lookup(_ context.Context, key string) (any, bool, error) {
+	s.calls.Add(1)
+	s.mu.Lock()
+	s.inFlight++
+	s.maxSeen = max(s.maxSeen, s.inFlight)
+	s.mu.Unlock()
+
+	time.Sleep(s.delay)
+
+	s.mu.Lock()
+	s.inFlight--
+	s.mu.Unlock()
+	return "host-" + key, true, nil
+}
+
+func TestMaxConcurrency(t *testing.T) {
+	const keys = 20
+
+	// Each key appears twice, and is looked up once.
+	newLogs := func() plog.Logs {
+		ld := plog.NewLogs()
+		lrs := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+		for range 2 {
+			for i := range keys {
+				lrs.AppendEmpty().Attributes().PutStr("client.ip", fmt.Sprintf("10.0.0.%d", i))
+			}
+		}
+		return ld
+	}
+
+	run := func(t *testing.T, maxConcurrency int) (time.Duration, *slowSource) {
+		source := &slowSource{delay: 20 * time.Millisecond}
+		factory := NewFactoryWithOptions(WithSources(source.factory()))
+		cfg := factory.CreateDefaultConfig().(*Config)
+		require.NoError(t, confmap.NewFromStringMap(map[string]any{
+			"sources":         map[string]any{"hosts": map[string]any{"type": "slow"}},
+			"lookups":         []any{map[string]any{"source": "hosts", "source_attribute": "client.ip", "target_attribute": "host.name"}},
+			"max_concurrency": maxConcurrency,
+		}).Unmarshal(cfg))
+		require.NoError(t, cfg.Validate())
+
+		sink := new(consumertest.LogsSink)
+		proc, err := factory.CreateLogs(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, sink)
+		require.NoError(t, err)
+
+		start := time.Now()
+		require.NoError(t, proc.ConsumeLogs(t.Context(), newLogs()))
+		elapsed := time.Since(start)
+
+		lrs := sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords()
+		require.Equal(t, 2*keys, lrs.Len())
+		for i := 0; i < lrs.Len(); i++ {
+			attrs := lrs.At(i).Attributes()
+			ip, _ := attrs.Get("client.ip")
+			host, ok := attrs.Get("host.name")
+			require.True(t, ok, ip.Str())
+			assert.Equal(t, "host-"+ip.Str(), host.Str())
+		}
+		assert.Equal(t, int64(keys), source.calls.Load())
+		return elapsed, source
+	}
+
+	serial, serialSource := run(t, 1)
+	assert.Equal(t, 1, serialSource.maxSeen)
+
+	concurrent, concurrentSource := run(t, 10)
+	assert.LessOrEqual(t, concurrentSource.maxSeen, 10)
+	assert.Greater(t, concurrentSource.maxSeen, 1)
+	assert.Less(t, concurrent, serial/2, "serial: %v, concurrent: %v", serial, concurrent)
+}
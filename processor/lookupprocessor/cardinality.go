@@ -0,0 +1,147 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupprocessor // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+// CardinalityLimit bounds the number of distinct lookup results written to
+// the data points of metrics, so that results unique to a record, such as
+// host names, don't create a time series each in the backend.
+type CardinalityLimit struct {
+	// MaxValues is the number of distinct values each target attribute may
+	// take within a window. Further values are replaced by OverflowValue.
+	// Zero disables the limit.
+	// Default: 0
+	MaxValues int `mapstructure:"max_values"`
+
+	// Window is how long the distinct values are remembered before the
+	// budget starts over. Zero remembers them for the lifetime of the
+	// processor.
+	// Default: 1h
+	Window time.Duration `mapstructure:"window"`
+
+	// OverflowValue is written instead of the values over the budget.
+	// Default: overflow
+	OverflowValue string `mapstructure:"overflow_value"`
+}
+
+func (c CardinalityLimit) validate() error {
+	if c.MaxValues < 0 {
+		return errors.New("metrics_cardinality_limit: max_values must not be negative")
+	}
+	if c.Window < 0 {
+		return errors.New("metrics_cardinality_limit: window must not be negative")
+	}
+	return nil
+}
+
+// cardinalityLimiter enforces a CardinalityLimit on one target attribute.
+// Rules writing the same target attribute share a limiter.
+type cardinalityLimiter struct {
+	cfg       CardinalityLimit
+	logger    *zap.Logger
+	overflows metric.Int64Counter
+	attrs     metric.MeasurementOption
+	now       func() time.Time
+
+	mu          sync.Mutex
+	windowStart time.Time
+	seen        map[string]struct{}
+	// warned is set once the overflow of the current window is logged.
+	warned bool
+}
+
+// newCardinalityLimiters returns a limiter for each target attribute of
+// rules of the processor with id, or nil if cfg disables the limit.
+func newCardinalityLimiters(cfg CardinalityLimit, rules []LookupRule, id component.ID, logger *zap.Logger, meter metric.Meter) map[string]*cardinalityLimiter {
+	if cfg.MaxValues == 0 {
+		return nil
+	}
+	overflows, err := meter.Int64Counter(
+		"otelcol_lookup_cardinality_overflows",
+		metric.WithDescription("Number of lookup results replaced by the overflow value of metrics_cardinality_limit."),
+		metric.WithUnit("{values}"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+	limiters := make(map[string]*cardinalityLimiter)
+	for _, rule := range rules {
+		target := rule.TargetAttribute
+		if _, ok := limiters[target]; ok {
+			continue
+		}
+		limiters[target] = &cardinalityLimiter{
+			cfg:       cfg,
+			logger:    logger.With(zap.String("target_attribute", target)),
+			overflows: overflows,
+			attrs:     metric.WithAttributeSet(attribute.NewSet(processorAttribute(id), attribute.String("target_attribute", target))),
+			now:       time.Now,
+			seen:      make(map[string]struct{}),
+		}
+	}
+	return limiters
+}
+
+// limit returns val if it is one of the distinct values allowed in the
+// current window, and the overflow value otherwise. Map results count as a
+// single value.
+func (l *cardinalityLimiter) limit(ctx context.Context, val any) any {
+	if failure, ok := val.(failedResult); ok {
+		if failure.hasValue {
+			failure.value = l.limit(ctx, failure.value)
+		}
+		return failure
+	}
+	timed, isTimed := val.(lookupsource.TimedResult)
+	if isTimed {
+		val = timed.Value
+	}
+	key := fmt.Sprint(val)
+
+	l.mu.Lock()
+	if now := l.now(); l.cfg.Window > 0 && now.Sub(l.windowStart) >= l.cfg.Window {
+		l.windowStart = now
+		clear(l.seen)
+		l.warned = false
+	}
+	_, allowed := l.seen[key]
+	if !allowed && len(l.seen) < l.cfg.MaxValues {
+		l.seen[key] = struct{}{}
+		allowed = true
+	}
+	warn := !allowed && !l.warned
+	if warn {
+		l.warned = true
+	}
+	l.mu.Unlock()
+
+	if !allowed {
+		l.overflows.Add(ctx, 1, l.attrs)
+		if warn {
+			l.logger.Warn("Distinct lookup results exceed metrics_cardinality_limit, writing the overflow value instead",
+				zap.Int("max_values", l.cfg.MaxValues), zap.String("overflow_value", l.cfg.OverflowValue))
+		}
+		val = l.cfg.OverflowValue
+	}
+	if isTimed {
+		timed.Value = val
+		return timed
+	}
+	return val
+}
@@ -0,0 +1,63 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupprocessor
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/otel/metric/noop"
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/metadata"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+func TestCardinalityLimiter(t *testing.T) {
+	cfg := CardinalityLimit{MaxValues: 3, Window: time.Minute, OverflowValue: "other"}
+	rules := []LookupRule{
+		{TargetAttribute: "host.name"},
+		{TargetAttribute: "host.name"},
+		{TargetAttribute: "geo.country"},
+	}
+	limiters := newCardinalityLimiters(cfg, rules, component.NewID(metadata.Type), zap.NewNop(), noop.Meter{})
+	require.Len(t, limiters, 2)
+
+	l := limiters["host.name"]
+	now := time.Unix(0, 0)
+	l.now = func() time.Time { return now }
+
+	limit := func(count int) []any {
+		var got []any
+		for i := range count {
+			got = append(got, l.limit(t.Context(), fmt.Sprintf("host-%d", i)))
+		}
+		return got
+	}
+	assert.Equal(t, []any{"host-0", "host-1", "host-2", "other", "other"}, limit(5))
+	assert.Equal(t, []any{"host-0", "host-1", "host-2", "other"}, limit(4))
+
+	// Maps count as one value, and the value of timed results is limited.
+	assert.Equal(t, "other", l.limit(t.Context(), map[string]any{"name": "host-0"}))
+	assert.Equal(t, lookupsource.TimedResult{Value: "other", Duration: time.Second},
+		l.limit(t.Context(), lookupsource.TimedResult{Value: "host-3", Duration: time.Second}))
+	assert.Equal(t, lookupsource.TimedResult{Value: "host-1", Duration: time.Second},
+		l.limit(t.Context(), lookupsource.TimedResult{Value: "host-1", Duration: time.Second}))
+
+	// The budget starts over with the next window.
+	now = now.Add(time.Minute)
+	assert.Equal(t, "host-9", l.limit(t.Context(), "host-9"))
+	assert.Equal(t, []any{"host-0", "host-1", "other"}, limit(3))
+
+	// Other target attributes have their own budget.
+	assert.Equal(t, "US", limiters["geo.country"].limit(t.Context(), "US"))
+}
+
+func TestNewCardinalityLimitersDisabled(t *testing.T) {
+	assert.Nil(t, newCardinalityLimiters(CardinalityLimit{}, []LookupRule{{TargetAttribute: "host.name"}}, component.NewID(metadata.Type), zap.NewNop(), noop.Meter{}))
+}
@@ -4,26 +4,543 @@
 package lookupprocessor // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor"
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
 	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/confmap"
 
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/sourceconfig"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
 )
 
+// AttributeContext selects which attributes are used as the lookup key and
+// enriched with the result.
+type AttributeContext string
+
+const (
+	// ContextRecord uses the attributes of each log record, span or metric
+	// data point.
+	ContextRecord AttributeContext = "record"
+	// ContextResource uses the resource attributes.
+	ContextResource AttributeContext = "resource"
+)
+
+// MissingAttributeAction selects what a source_key template does with a
+// reference to an attribute that doesn't exist.
+type MissingAttributeAction string
+
+const (
+	// MissingAttributeSkip leaves the record without a lookup.
+	MissingAttributeSkip MissingAttributeAction = "skip"
+	// MissingAttributeEmpty replaces the reference with an empty string.
+	MissingAttributeEmpty MissingAttributeAction = "empty"
+)
+
+// LookupErrorAction selects what happens to the records whose lookup fails.
+type LookupErrorAction string
+
+const (
+	// LookupErrorPropagate rejects the batch with the error, so that the
+	// pipeline drops or retries it.
+	LookupErrorPropagate LookupErrorAction = "propagate"
+	// LookupErrorSkip leaves the records unchanged.
+	LookupErrorSkip LookupErrorAction = "skip"
+	// LookupErrorDefault writes the default value of the lookup, as if the
+	// key wasn't found, and leaves the records unchanged if there is none.
+	LookupErrorDefault LookupErrorAction = "default"
+)
+
 type Config struct {
-	Source SourceConfig `mapstructure:"source"`
+	// Sources are the lookup sources, keyed by the name lookups refer to
+	// them by. Lookups referring to the same name share one source
+	// instance, and so its cache.
+	Sources map[string]SourceConfig `mapstructure:"sources"`
+
+	// Lookups are applied in order: a lookup sees the attributes written by
+	// the ones before it.
+	Lookups []LookupRule `mapstructure:"lookups"`
+
+	// MaxConcurrency is the maximum number of lookups run concurrently
+	// while processing a batch. Each distinct key is looked up once per
+	// lookup and batch.
+	// Default: 1
+	MaxConcurrency int `mapstructure:"max_concurrency"`
+
+	// ErrorMode determines how errors evaluating the OTTL conditions and
+	// expressions of lookups are handled. Records whose conditions or
+	// expressions fail to evaluate are not looked up unless the mode is
+	// propagate, in which case the batch is rejected.
+	// Default: ignore
+	ErrorMode ottl.ErrorMode `mapstructure:"error_mode"`
+
+	// OnError selects what happens to records whose lookup fails, such as
+	// during an outage of a source: propagate rejects the batch, skip
+	// leaves them unchanged and default writes the default value of the
+	// lookup, so that pipelines keep flowing with a placeholder.
+	// Default: skip
+	OnError LookupErrorAction `mapstructure:"on_error"`
+
+	// ExposeExpvar publishes the cache statistics of the sources on the
+	// expvar /debug/vars endpoint, for deployments without the collector's
+	// own telemetry. Sources owned by a lookup extension are not published.
+	// Default: false
+	ExposeExpvar bool `mapstructure:"expose_expvar"`
+
+	// CacheBypassMetadataKey is a client metadata key, such as a debug
+	// header, with which requests can bypass the caches of the sources: if
+	// its first value is true, every key of the batch is looked up again
+	// and the cached entries are refreshed. Empty disables bypassing.
+	CacheBypassMetadataKey string `mapstructure:"cache_bypass_metadata_key"`
+
+	// MultiValue selects how results holding several values, such as the
+	// host names of a reverse DNS lookup, are written: array writes an
+	// array attribute, join the values joined by MultiValueSeparator and
+	// first the first value only. It applies to every source.
+	// Default: array
+	MultiValue lookupsource.MultiValueMode `mapstructure:"multi_value"`
+
+	// MultiValueSeparator joins the values of a result with the join
+	// multi_value mode.
+	// Default: ,
+	MultiValueSeparator string `mapstructure:"multi_value_separator"`
+
+	// ReadyTimeout makes Start wait up to ReadyTimeout for the sources to be
+	// ready, such as a k8s source syncing its informer, so that the first
+	// records aren't looked up in sources still loading their data. Start
+	// fails if a source isn't ready in time. Zero doesn't wait.
+	// Default: 0
+	ReadyTimeout time.Duration `mapstructure:"ready_timeout"`
+
+	// MetricsCardinalityLimit bounds the number of distinct values each
+	// target attribute takes on metric data points. It doesn't apply to
+	// logs and traces.
+	MetricsCardinalityLimit CardinalityLimit `mapstructure:"metrics_cardinality_limit"`
+
+	// sources resolves SourceConfig.Type to a factory while unmarshaling. It
+	// is set by the processor factory so that custom sources added with
+	// WithSources can be configured.
+	sources *lookupsource.Registry
+}
+
+// LookupRule configures one lookup: where its key is read from, which source
+// resolves it and where the result is written.
+type LookupRule struct {
+	// Source is the name of the entry of Config.Sources to look keys up in.
+	Source string `mapstructure:"source"`
+
+	// RouteBy is the attribute of the context whose value selects the source
+	// of a record among Routes, such as cloud.provider. Records with another
+	// value, or without the attribute, are looked up in Source, or left
+	// untouched if Source is empty.
+	RouteBy string `mapstructure:"route_by"`
+
+	// Routes map values of RouteBy to the name of the entry of
+	// Config.Sources the keys of their records are looked up in.
+	Routes map[string]string `mapstructure:"routes"`
+
+	// SourceAttribute is the attribute holding the lookup key, or an OTTL
+	// value expression computing it, such as
+	// Concat([attributes["a"], attributes["b"]], ":"). With the "context."
+	// prefix, the key is instead read from the client metadata of the
+	// incoming request, and is the same for every record of a batch.
+	SourceAttribute string `mapstructure:"source_attribute"`
+
+	// SourceKey is a template building the lookup key from several
+	// attributes, used instead of SourceAttribute, such as
+	// "{resource.service.name}/{attributes.region}". {resource.<name>}
+	// references a resource attribute and {attributes.<name>} an attribute
+	// of the context: the record, or the resource with the resource
+	// context. "{{" and "}}" are literal braces.
+	SourceKey string `mapstructure:"source_key"`
+
+	// SourceAttributes name the attributes of the context making up a
+	// compound key, by key part, such as {ip: client.address, port:
+	// client.port}, used instead of SourceAttribute for sources looking up
+	// several keys at once. Records missing any of them are not looked up.
+	SourceAttributes map[string]string `mapstructure:"source_attributes"`
+
+	// SourceKeyMissing selects what happens when an attribute referenced by
+	// SourceKey doesn't exist: skip or empty.
+	// Default: skip
+	SourceKeyMissing MissingAttributeAction `mapstructure:"source_key_missing"`
+
+	// TargetAttribute is the attribute the lookup result is written to, or
+	// an OTTL path such as resource.attributes["host.name"].
+	TargetAttribute string `mapstructure:"target_attribute"`
+
+	// Context selects whether record or resource attributes are read and
+	// written.
+	// Default: record
+	Context AttributeContext `mapstructure:"context"`
+
+	// Overwrite replaces the target attribute if it already exists.
+	// Default: false
+	Overwrite bool `mapstructure:"overwrite"`
+
+	// DefaultValue is written to the target attribute when the key is not
+	// found. If unset, records with unknown keys are left untouched.
+	DefaultValue any `mapstructure:"default_value"`
+
+	// SkipOnNotFound leaves the target attribute untouched when the key is
+	// not found, even if DefaultValue is set.
+	// Default: false
+	SkipOnNotFound bool `mapstructure:"skip_on_not_found"`
+
+	// Flatten writes map results as one attribute per entry, named after
+	// TargetAttribute and the entry key joined by FlattenSeparator, instead
+	// of a single map-valued attribute. Other results are written to
+	// TargetAttribute as usual. With Overwrite false, each attribute that
+	// already exists is kept.
+	// Default: false
+	Flatten bool `mapstructure:"flatten"`
+
+	// FlattenSeparator joins TargetAttribute and the keys of flattened map
+	// entries.
+	// Default: .
+	FlattenSeparator string `mapstructure:"flatten_separator"`
+
+	// FlattenMaxDepth is the number of levels of nested maps flattened.
+	// Maps nested deeper are written as map-valued attributes. Zero means
+	// no limit.
+	// Default: 0
+	FlattenMaxDepth int `mapstructure:"flatten_max_depth"`
+
+	// TargetParts splits string results by SplitSeparator and writes each
+	// part to its own attribute, named after TargetAttribute and the part
+	// name joined by FlattenSeparator, such as [dc, rack, host] for a
+	// dc1.rack2.web-1 result. Parts with an empty name are discarded. Other
+	// results are written to TargetAttribute as usual. With Overwrite
+	// false, each attribute that already exists is kept.
+	TargetParts []string `mapstructure:"target_parts"`
+
+	// SplitSeparator splits string results into TargetParts.
+	// Default: .
+	SplitSeparator string `mapstructure:"split_separator"`
+
+	// OnTooFewParts selects what happens to results with fewer parts than
+	// TargetParts: skip or partial.
+	// Default: skip
+	OnTooFewParts PartCountAction `mapstructure:"on_too_few_parts"`
+
+	// OnTooManyParts selects what happens to results with more parts than
+	// TargetParts: skip, partial or join_last.
+	// Default: join_last
+	OnTooManyParts PartCountAction `mapstructure:"on_too_many_parts"`
+
+	// AppendToArray appends the result to the array held by the target
+	// attribute, creating it if it doesn't exist, instead of replacing the
+	// attribute, so that several lookups can accumulate their results in one
+	// list. The elements of list results are appended one by one. A target
+	// attribute that exists but isn't an array is kept, unless Overwrite is
+	// set, which replaces it with a new array.
+	// Default: false
+	AppendToArray bool `mapstructure:"append_to_array"`
+
+	// AppendDeduplicate skips appending values the array already holds.
+	// Default: false
+	AppendDeduplicate bool `mapstructure:"append_deduplicate"`
+
+	// Transform are steps applied in order to string results before they
+	// are written, such as to strip a domain suffix or uppercase a country
+	// code. They apply after multi_value, and not to DefaultValue.
+	Transform []TransformStep `mapstructure:"transform"`
+
+	// TransformNonString selects what happens to results that aren't
+	// strings when Transform is set: skip writes them untransformed, and
+	// error leaves the record unchanged.
+	// Default: skip
+	TransformNonString NonStringAction `mapstructure:"transform_non_string"`
+
+	// NormalizeKey canonicalizes the keys read from the record before they
+	// are looked up, and before they are matched against SkipKeys.
+	NormalizeKey KeyNormalization `mapstructure:"normalize_key"`
+
+	// SkipKeys are keys that are never looked up, matched exactly, by CIDR
+	// or by regular expression, such as private IP addresses or "-"
+	// placeholders. Records with a skipped key get SkipKeys.DefaultValue,
+	// if set, and are otherwise left untouched.
+	SkipKeys SkipKeys `mapstructure:"skip_keys"`
+
+	// Conditions are OTTL conditions a record must satisfy to be looked up.
+	// They are evaluated against the same context as the attributes: log
+	// records, spans or data points for the record context, resources for
+	// the resource context. A record is looked up if any condition matches;
+	// if empty, every record is looked up.
+	Conditions []string `mapstructure:"conditions"`
+
+	// SampleRate is the fraction of records looked up, from 0 to 1, to
+	// bound the cost of expensive sources. Records not sampled are left
+	// untouched. If unset, every record is looked up.
+	SampleRate *float64 `mapstructure:"sample_rate"`
+
+	// SampleBy selects how records are sampled with SampleRate: trace_id
+	// looks up all the spans and log records of a trace or none of them,
+	// and random samples each record independently.
+	// Default: trace_id
+	SampleBy SampleMode `mapstructure:"sample_by"`
 }
 
 type SourceConfig struct {
 	// Type is the source type identifier (e.g., "noop", "yaml", "dns").
 	Type string `mapstructure:"type"`
 
+	// Extension is the ID of a lookup extension owning the source. The
+	// source is then shared with the other processors using the extension,
+	// and is configured in the extension instead of here.
+	Extension *component.ID `mapstructure:"extension"`
+
+	// Name is the name of the source in Extension.
+	// Default: the name of this source
+	Name string `mapstructure:"name"`
+
 	// Config holds the source-specific configuration.
 	// This is populated during config unmarshaling based on the Type.
 	Config lookupsource.SourceConfig `mapstructure:"-"`
 }
 
-var _ component.Config = (*Config)(nil)
+var (
+	_ component.Config    = (*Config)(nil)
+	_ confmap.Unmarshaler = (*Config)(nil)
+)
 
-func (*Config) Validate() error {
+func (cfg *Config) Validate() error {
+	if len(cfg.Lookups) == 0 {
+		return errors.New("at least one lookup must be specified")
+	}
+	for _, name := range sortedNames(cfg.Sources) {
+		sourceCfg := cfg.Sources[name]
+		if sourceCfg.Extension == nil && sourceCfg.Name != "" {
+			return fmt.Errorf("source %q: name can only be used with extension", name)
+		}
+	}
+	if cfg.MaxConcurrency < 1 {
+		return errors.New("max_concurrency must be at least 1")
+	}
+	if cfg.ReadyTimeout < 0 {
+		return errors.New("ready_timeout must not be negative")
+	}
+	switch cfg.OnError {
+	case LookupErrorPropagate, LookupErrorSkip, LookupErrorDefault:
+	default:
+		return fmt.Errorf("invalid on_error %q, must be %q, %q or %q", cfg.OnError, LookupErrorPropagate, LookupErrorSkip, LookupErrorDefault)
+	}
+	if err := cfg.MultiValue.Validate(); err != nil {
+		return err
+	}
+	if err := cfg.MetricsCardinalityLimit.validate(); err != nil {
+		return err
+	}
+	var errs error
+	for i, rule := range cfg.Lookups {
+		if err := rule.validate(cfg.Sources); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("lookups[%d]: %w", i, err))
+		}
+	}
+	return errs
+}
+
+func (rule *LookupRule) validate(sources map[string]SourceConfig) error {
+	switch {
+	case rule.RouteBy == "" && len(rule.Routes) > 0:
+		return errors.New("routes requires route_by")
+	case rule.RouteBy != "" && len(rule.Routes) == 0:
+		return errors.New("route_by requires routes")
+	}
+	for _, value := range sortedNames(rule.Routes) {
+		if _, ok := sources[rule.Routes[value]]; !ok {
+			return fmt.Errorf("routes: %q: unknown source %q, available sources: %v", value, rule.Routes[value], sortedNames(sources))
+		}
+	}
+	// Routed rules may leave records with other values untouched.
+	if _, ok := sources[rule.Source]; !ok && (rule.RouteBy == "" || rule.Source != "") {
+		return fmt.Errorf("unknown source %q, available sources: %v", rule.Source, sortedNames(sources))
+	}
+	switch {
+	case rule.SourceAttribute == "" && rule.SourceKey == "" && len(rule.SourceAttributes) == 0:
+		return errors.New("source_attribute, source_attributes or source_key must be specified")
+	case rule.SourceAttribute != "" && rule.SourceKey != "":
+		return errors.New("source_attribute and source_key cannot be used together")
+	case len(rule.SourceAttributes) > 0 && (rule.SourceAttribute != "" || rule.SourceKey != ""):
+		return errors.New("source_attributes cannot be used with source_attribute or source_key")
+	case len(rule.SourceAttributes) > 0:
+		for _, part := range sortedNames(rule.SourceAttributes) {
+			if rule.SourceAttributes[part] == "" {
+				return fmt.Errorf("source_attributes: %q must name an attribute", part)
+			}
+		}
+		if len(rule.SkipKeys.Values) > 0 || len(rule.SkipKeys.CIDRs) > 0 || len(rule.SkipKeys.Patterns) > 0 {
+			return errors.New("skip_keys cannot be used with source_attributes")
+		}
+		if rule.NormalizeKey.compile() != nil {
+			return errors.New("normalize_key cannot be used with source_attributes")
+		}
+	case rule.SourceKey != "":
+		if _, err := parseKeyTemplate(rule.SourceKey); err != nil {
+			return fmt.Errorf("invalid source_key: %w", err)
+		}
+		switch rule.SourceKeyMissing {
+		case MissingAttributeSkip, MissingAttributeEmpty:
+		default:
+			return fmt.Errorf("invalid source_key_missing %q, must be %q or %q", rule.SourceKeyMissing, MissingAttributeSkip, MissingAttributeEmpty)
+		}
+	}
+	if rule.SourceAttribute == contextPrefix {
+		return fmt.Errorf("source_attribute must name a client metadata key after %q", contextPrefix)
+	}
+	if rule.TargetAttribute == "" {
+		return errors.New("target_attribute must be specified")
+	}
+	switch rule.Context {
+	case ContextRecord, ContextResource:
+	default:
+		return fmt.Errorf("invalid context %q, must be %q or %q", rule.Context, ContextRecord, ContextResource)
+	}
+	if rule.Flatten && isExpression(rule.TargetAttribute) {
+		return errors.New("flatten cannot be used with an OTTL target_attribute")
+	}
+	if rule.FlattenMaxDepth < 0 {
+		return errors.New("flatten_max_depth must not be negative")
+	}
+	if rule.AppendToArray && rule.Flatten {
+		return errors.New("append_to_array cannot be used with flatten")
+	}
+	if rule.AppendToArray && isExpression(rule.TargetAttribute) {
+		return errors.New("append_to_array cannot be used with an OTTL target_attribute")
+	}
+	if rule.AppendDeduplicate && !rule.AppendToArray {
+		return errors.New("append_deduplicate requires append_to_array")
+	}
+	if err := rule.validateSplit(); err != nil {
+		return err
+	}
+	if _, err := rule.SkipKeys.compile(); err != nil {
+		return err
+	}
+	if rule.SampleRate != nil && (*rule.SampleRate < 0 || *rule.SampleRate > 1) {
+		return fmt.Errorf("sample_rate must be between 0 and 1, got %v", *rule.SampleRate)
+	}
+	if _, err := newSampler(rule.SampleRate, rule.SampleBy); err != nil {
+		return err
+	}
+	if len(rule.Transform) > 0 {
+		if _, err := compileTransform(rule.Transform); err != nil {
+			return err
+		}
+		switch rule.TransformNonString {
+		case NonStringSkip, NonStringError:
+		default:
+			return fmt.Errorf("invalid transform_non_string %q, must be %q or %q", rule.TransformNonString, NonStringSkip, NonStringError)
+		}
+	}
 	return nil
 }
+
+// Unmarshal decodes the processor configuration. The Type of each source
+// picks the source factory whose default config receives the remaining
+// settings of that source. Unset lookup settings get their documented
+// defaults.
+func (cfg *Config) Unmarshal(componentParser *confmap.Conf) error {
+	if componentParser == nil {
+		return nil
+	}
+
+	// Sources are decoded below, into the config of their type, so that
+	// unknown settings are rejected everywhere else.
+	settings := componentParser.ToStringMap()
+	delete(settings, "sources")
+	if err := confmap.NewFromStringMap(settings).Unmarshal(cfg); err != nil {
+		return err
+	}
+
+	for i := range cfg.Lookups {
+		if cfg.Lookups[i].Context == "" {
+			cfg.Lookups[i].Context = ContextRecord
+		}
+		if cfg.Lookups[i].FlattenSeparator == "" {
+			cfg.Lookups[i].FlattenSeparator = "."
+		}
+		if cfg.Lookups[i].SplitSeparator == "" {
+			cfg.Lookups[i].SplitSeparator = "."
+		}
+		if cfg.Lookups[i].OnTooFewParts == "" {
+			cfg.Lookups[i].OnTooFewParts = PartCountSkip
+		}
+		if cfg.Lookups[i].OnTooManyParts == "" {
+			cfg.Lookups[i].OnTooManyParts = PartCountJoinLast
+		}
+		if cfg.Lookups[i].SourceKeyMissing == "" {
+			cfg.Lookups[i].SourceKeyMissing = MissingAttributeSkip
+		}
+		if cfg.Lookups[i].TransformNonString == "" {
+			cfg.Lookups[i].TransformNonString = NonStringSkip
+		}
+		if cfg.Lookups[i].SampleBy == "" {
+			cfg.Lookups[i].SampleBy = SampleByTraceID
+		}
+	}
+
+	registry := cfg.sources
+	if registry == nil {
+		registry = sourceconfig.DefaultRegistry()
+	}
+
+	sourcesSection, err := componentParser.Sub("sources")
+	if err != nil {
+		return err
+	}
+	if cfg.Sources == nil {
+		cfg.Sources = make(map[string]SourceConfig)
+	}
+	for name := range sourcesSection.ToStringMap() {
+		sourceSection, err := sourcesSection.Sub(name)
+		if err != nil {
+			return err
+		}
+		// The settings of the source type are decoded strictly by
+		// unmarshal.
+		var sourceCfg SourceConfig
+		if err := sourceSection.Unmarshal(&sourceCfg, confmap.WithIgnoreUnused()); err != nil {
+			return fmt.Errorf("source %q: %w", name, err)
+		}
+		if err := sourceCfg.unmarshal(registry, sourceSection); err != nil {
+			return fmt.Errorf("source %q: %w", name, err)
+		}
+		cfg.Sources[name] = sourceCfg
+	}
+
+	return nil
+}
+
+// unmarshal decodes the settings of conf into the default config of the
+// factory registered for the source type. Sources owned by an extension have
+// no other settings.
+func (sourceCfg *SourceConfig) unmarshal(registry *lookupsource.Registry, conf *confmap.Conf) error {
+	if sourceCfg.Extension != nil {
+		for key := range conf.ToStringMap() {
+			if key != "extension" && key != "name" {
+				return fmt.Errorf("%s cannot be used with extension, configure the source in the extension instead", key)
+			}
+		}
+		return nil
+	}
+	if sourceCfg.Type == "" {
+		sourceCfg.Type = sourceconfig.DefaultType
+	}
+	cfg, err := sourceconfig.Decode(registry, sourceCfg.Type, conf)
+	if err != nil {
+		return err
+	}
+	sourceCfg.Config = cfg
+	return nil
+}
+
+// typ returns the type of the source, or the default type if not set.
+func (sourceCfg SourceConfig) typ() string {
+	if sourceCfg.Type == "" {
+		return sourceconfig.DefaultType
+	}
+	return sourceCfg.Type
+}
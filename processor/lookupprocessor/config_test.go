@@ -0,0 +1,511 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupprocessor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/confmap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/noop"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/static"
+)
+
+func TestConfigUnmarshal(t *testing.T) {
+	tests := []struct {
+		name     string
+		conf     map[string]any
+		wantType string
+		wantErr  string
+	}{
+		{
+			name: "default source type",
+			conf: map[string]any{
+				"sources": map[string]any{"src": map[string]any{}},
+			},
+			wantType: "noop",
+		},
+		{
+			name: "static source",
+			conf: map[string]any{
+				"sources": map[string]any{
+					"src": map[string]any{
+						"type":    "static",
+						"entries": map[string]any{"10.0.0.1": "web-1"},
+					},
+				},
+			},
+			wantType: "static",
+		},
+		{
+			name: "unknown source type",
+			conf: map[string]any{
+				"sources": map[string]any{
+					"src": map[string]any{"type": "missing"},
+				},
+			},
+			wantErr: `source "src": unknown source type "missing"`,
+		},
+		{
+			name: "unknown source setting",
+			conf: map[string]any{
+				"sources": map[string]any{
+					"src": map[string]any{
+						"type":    "static",
+						"entryes": map[string]any{"10.0.0.1": "web-1"},
+					},
+				},
+			},
+			wantErr: `error reading settings for source type "static"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewFactory().CreateDefaultConfig().(*Config)
+			err := confmap.NewFromStringMap(tt.conf).Unmarshal(cfg)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			sourceCfg := cfg.Sources["src"].Config
+			require.NotNil(t, sourceCfg)
+			assert.NoError(t, sourceCfg.Validate())
+
+			switch tt.wantType {
+			case "noop":
+				assert.IsType(t, &noop.Config{}, sourceCfg)
+			case "static":
+				staticCfg, ok := sourceCfg.(*static.Config)
+				require.True(t, ok)
+				assert.Equal(t, map[string]any{"10.0.0.1": "web-1"}, staticCfg.Entries)
+			}
+		})
+	}
+}
+
+func TestConfigUnmarshalLookups(t *testing.T) {
+	cfg := NewFactory().CreateDefaultConfig().(*Config)
+	require.NoError(t, confmap.NewFromStringMap(map[string]any{
+		"sources": map[string]any{"hosts": map[string]any{"type": "noop"}},
+		"lookups": []any{
+			map[string]any{"source": "hosts", "source_attribute": "client.ip", "target_attribute": "client.host.name"},
+			map[string]any{"source": "hosts", "source_attribute": "server.ip", "target_attribute": "server.host.name", "context": "resource"},
+		},
+	}).Unmarshal(cfg))
+
+	require.Len(t, cfg.Lookups, 2)
+	assert.Equal(t, ContextRecord, cfg.Lookups[0].Context)
+	assert.Equal(t, ContextResource, cfg.Lookups[1].Context)
+	assert.Equal(t, ".", cfg.Lookups[0].FlattenSeparator)
+	assert.Equal(t, MissingAttributeSkip, cfg.Lookups[0].SourceKeyMissing)
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr string
+	}{
+		{
+			name: "valid",
+		},
+		{
+			name:    "no lookups",
+			modify:  func(cfg *Config) { cfg.Lookups = nil },
+			wantErr: "at least one lookup must be specified",
+		},
+		{
+			name:    "name without extension",
+			modify:  func(cfg *Config) { cfg.Sources["hosts"] = SourceConfig{Type: "noop", Name: "users"} },
+			wantErr: `source "hosts": name can only be used with extension`,
+		},
+		{
+			name:    "invalid on error",
+			modify:  func(cfg *Config) { cfg.OnError = "retry" },
+			wantErr: `invalid on_error "retry", must be "propagate", "skip" or "default"`,
+		},
+		{
+			name:    "invalid max concurrency",
+			modify:  func(cfg *Config) { cfg.MaxConcurrency = 0 },
+			wantErr: "max_concurrency must be at least 1",
+		},
+		{
+			name:    "invalid multi value",
+			modify:  func(cfg *Config) { cfg.MultiValue = "last" },
+			wantErr: `invalid multi_value "last"`,
+		},
+		{
+			name:    "negative ready timeout",
+			modify:  func(cfg *Config) { cfg.ReadyTimeout = -time.Second },
+			wantErr: "ready_timeout must not be negative",
+		},
+		{
+			name:    "negative cardinality limit",
+			modify:  func(cfg *Config) { cfg.MetricsCardinalityLimit.MaxValues = -1 },
+			wantErr: "metrics_cardinality_limit: max_values must not be negative",
+		},
+		{
+			name:    "negative cardinality window",
+			modify:  func(cfg *Config) { cfg.MetricsCardinalityLimit.Window = -time.Second },
+			wantErr: "metrics_cardinality_limit: window must not be negative",
+		},
+		{
+			name:    "unknown source",
+			modify:  func(cfg *Config) { cfg.Lookups[0].Source = "users" },
+			wantErr: `lookups[0]: unknown source "users", available sources: [hosts]`,
+		},
+		{
+			name: "routes",
+			modify: func(cfg *Config) {
+				cfg.Lookups[0].RouteBy = "cloud.provider"
+				cfg.Lookups[0].Routes = map[string]string{"aws": "hosts"}
+			},
+		},
+		{
+			name: "routes without default source",
+			modify: func(cfg *Config) {
+				cfg.Lookups[0].Source = ""
+				cfg.Lookups[0].RouteBy = "cloud.provider"
+				cfg.Lookups[0].Routes = map[string]string{"aws": "hosts"}
+			},
+		},
+		{
+			name:    "routes without route by",
+			modify:  func(cfg *Config) { cfg.Lookups[0].Routes = map[string]string{"aws": "hosts"} },
+			wantErr: "lookups[0]: routes requires route_by",
+		},
+		{
+			name:    "route by without routes",
+			modify:  func(cfg *Config) { cfg.Lookups[0].RouteBy = "cloud.provider" },
+			wantErr: "lookups[0]: route_by requires routes",
+		},
+		{
+			name: "route to unknown source",
+			modify: func(cfg *Config) {
+				cfg.Lookups[0].RouteBy = "cloud.provider"
+				cfg.Lookups[0].Routes = map[string]string{"aws": "hosts", "gcp": "gce"}
+			},
+			wantErr: `lookups[0]: routes: "gcp": unknown source "gce", available sources: [hosts]`,
+		},
+		{
+			name:    "missing source attribute",
+			modify:  func(cfg *Config) { cfg.Lookups[0].SourceAttribute = "" },
+			wantErr: "lookups[0]: source_attribute, source_attributes or source_key must be specified",
+		},
+		{
+			name: "source key",
+			modify: func(cfg *Config) {
+				cfg.Lookups[0].SourceAttribute = ""
+				cfg.Lookups[0].SourceKey = "{resource.service.name}/{attributes.region}"
+				cfg.Lookups[0].SourceKeyMissing = MissingAttributeEmpty
+			},
+		},
+		{
+			name:    "source attribute and source key",
+			modify:  func(cfg *Config) { cfg.Lookups[0].SourceKey = "{attributes.region}" },
+			wantErr: "lookups[0]: source_attribute and source_key cannot be used together",
+		},
+		{
+			name: "source attributes",
+			modify: func(cfg *Config) {
+				cfg.Lookups[0].SourceAttribute = ""
+				cfg.Lookups[0].SourceAttributes = map[string]string{"ip": "client.address", "port": "client.port"}
+			},
+		},
+		{
+			name: "source attributes and source attribute",
+			modify: func(cfg *Config) {
+				cfg.Lookups[0].SourceAttributes = map[string]string{"ip": "client.address"}
+			},
+			wantErr: "lookups[0]: source_attributes cannot be used with source_attribute or source_key",
+		},
+		{
+			name: "empty source attributes entry",
+			modify: func(cfg *Config) {
+				cfg.Lookups[0].SourceAttribute = ""
+				cfg.Lookups[0].SourceAttributes = map[string]string{"ip": "client.address", "port": ""}
+			},
+			wantErr: `lookups[0]: source_attributes: "port" must name an attribute`,
+		},
+		{
+			name: "source attributes with skip keys",
+			modify: func(cfg *Config) {
+				cfg.Lookups[0].SourceAttribute = ""
+				cfg.Lookups[0].SourceAttributes = map[string]string{"ip": "client.address"}
+				cfg.Lookups[0].SkipKeys.Values = []string{"-"}
+			},
+			wantErr: "lookups[0]: skip_keys cannot be used with source_attributes",
+		},
+		{
+			name: "source attributes with key normalization",
+			modify: func(cfg *Config) {
+				cfg.Lookups[0].SourceAttribute = ""
+				cfg.Lookups[0].SourceAttributes = map[string]string{"ip": "client.address"}
+				cfg.Lookups[0].NormalizeKey.Lowercase = true
+			},
+			wantErr: "lookups[0]: normalize_key cannot be used with source_attributes",
+		},
+		{
+			name: "invalid source key",
+			modify: func(cfg *Config) {
+				cfg.Lookups[0].SourceAttribute = ""
+				cfg.Lookups[0].SourceKey = "{region}"
+				cfg.Lookups[0].SourceKeyMissing = MissingAttributeSkip
+			},
+			wantErr: "lookups[0]: invalid source_key: invalid reference {region}",
+		},
+		{
+			name: "invalid source key missing",
+			modify: func(cfg *Config) {
+				cfg.Lookups[0].SourceAttribute = ""
+				cfg.Lookups[0].SourceKey = "{attributes.region}"
+				cfg.Lookups[0].SourceKeyMissing = "fail"
+			},
+			wantErr: `lookups[0]: invalid source_key_missing "fail"`,
+		},
+		{
+			name:    "missing metadata key",
+			modify:  func(cfg *Config) { cfg.Lookups[0].SourceAttribute = "context." },
+			wantErr: `lookups[0]: source_attribute must name a client metadata key after "context."`,
+		},
+		{
+			name:    "missing target attribute",
+			modify:  func(cfg *Config) { cfg.Lookups[0].TargetAttribute = "" },
+			wantErr: "lookups[0]: target_attribute must be specified",
+		},
+		{
+			name:    "invalid context",
+			modify:  func(cfg *Config) { cfg.Lookups[0].Context = "scope" },
+			wantErr: `lookups[0]: invalid context "scope"`,
+		},
+		{
+			name: "flatten with OTTL target",
+			modify: func(cfg *Config) {
+				cfg.Lookups[0].Flatten = true
+				cfg.Lookups[0].TargetAttribute = `resource.attributes["geo"]`
+			},
+			wantErr: "lookups[0]: flatten cannot be used with an OTTL target_attribute",
+		},
+		{
+			name:    "negative flatten depth",
+			modify:  func(cfg *Config) { cfg.Lookups[0].FlattenMaxDepth = -1 },
+			wantErr: "lookups[0]: flatten_max_depth must not be negative",
+		},
+		{
+			name: "append to array with flatten",
+			modify: func(cfg *Config) {
+				cfg.Lookups[0].AppendToArray = true
+				cfg.Lookups[0].Flatten = true
+			},
+			wantErr: "lookups[0]: append_to_array cannot be used with flatten",
+		},
+		{
+			name: "append to array with OTTL target",
+			modify: func(cfg *Config) {
+				cfg.Lookups[0].AppendToArray = true
+				cfg.Lookups[0].TargetAttribute = `resource.attributes["hosts"]`
+			},
+			wantErr: "lookups[0]: append_to_array cannot be used with an OTTL target_attribute",
+		},
+		{
+			name:    "append deduplicate without append",
+			modify:  func(cfg *Config) { cfg.Lookups[0].AppendDeduplicate = true },
+			wantErr: "lookups[0]: append_deduplicate requires append_to_array",
+		},
+		{
+			name: "target parts with flatten",
+			modify: func(cfg *Config) {
+				setTargetParts(&cfg.Lookups[0], "dc", "rack")
+				cfg.Lookups[0].Flatten = true
+			},
+			wantErr: "lookups[0]: target_parts cannot be used with flatten or append_to_array",
+		},
+		{
+			name: "duplicate target parts",
+			modify: func(cfg *Config) {
+				setTargetParts(&cfg.Lookups[0], "dc", "", "dc")
+			},
+			wantErr: `lookups[0]: target_parts: duplicate part "dc"`,
+		},
+		{
+			name: "unnamed target parts",
+			modify: func(cfg *Config) {
+				setTargetParts(&cfg.Lookups[0], "", "")
+			},
+			wantErr: "lookups[0]: target_parts must name at least one part",
+		},
+		{
+			name: "invalid on too few parts",
+			modify: func(cfg *Config) {
+				setTargetParts(&cfg.Lookups[0], "dc", "rack")
+				cfg.Lookups[0].OnTooFewParts = PartCountJoinLast
+			},
+			wantErr: `lookups[0]: invalid on_too_few_parts "join_last", must be "skip" or "partial"`,
+		},
+		{
+			name: "invalid transform",
+			modify: func(cfg *Config) {
+				cfg.Lookups[0].Transform = []TransformStep{{Type: TransformTrimSuffix}}
+				cfg.Lookups[0].TransformNonString = NonStringSkip
+			},
+			wantErr: "lookups[0]: transform[0]: suffix must be specified",
+		},
+		{
+			name: "invalid transform non string",
+			modify: func(cfg *Config) {
+				cfg.Lookups[0].Transform = []TransformStep{{Type: TransformLowercase}}
+				cfg.Lookups[0].TransformNonString = "drop"
+			},
+			wantErr: `lookups[0]: invalid transform_non_string "drop"`,
+		},
+		{
+			name:    "invalid skip keys cidr",
+			modify:  func(cfg *Config) { cfg.Lookups[0].SkipKeys.CIDRs = []string{"10.0.0.0/8", "192.168.0.0"} },
+			wantErr: `lookups[0]: skip_keys: cidrs[1]: invalid cidr "192.168.0.0"`,
+		},
+		{
+			name:    "invalid skip keys pattern",
+			modify:  func(cfg *Config) { cfg.Lookups[0].SkipKeys.Patterns = []string{"("} },
+			wantErr: `lookups[0]: skip_keys: patterns[0]: invalid pattern "("`,
+		},
+		{
+			name: "sample rate",
+			modify: func(cfg *Config) {
+				rate := 0.25
+				cfg.Lookups[0].SampleRate = &rate
+				cfg.Lookups[0].SampleBy = SampleRandom
+			},
+		},
+		{
+			name: "sample rate out of range",
+			modify: func(cfg *Config) {
+				rate := 1.5
+				cfg.Lookups[0].SampleRate = &rate
+				cfg.Lookups[0].SampleBy = SampleByTraceID
+			},
+			wantErr: "lookups[0]: sample_rate must be between 0 and 1, got 1.5",
+		},
+		{
+			name: "invalid sample by",
+			modify: func(cfg *Config) {
+				rate := 0.5
+				cfg.Lookups[0].SampleRate = &rate
+				cfg.Lookups[0].SampleBy = "span_id"
+			},
+			wantErr: `lookups[0]: invalid sample_by "span_id"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewFactory().CreateDefaultConfig().(*Config)
+			cfg.Sources = map[string]SourceConfig{"hosts": {Type: "noop"}}
+			cfg.Lookups = []LookupRule{{
+				Source:          "hosts",
+				SourceAttribute: "client.ip",
+				TargetAttribute: "host.name",
+				Context:         ContextRecord,
+			}}
+			if tt.modify != nil {
+				tt.modify(cfg)
+			}
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+// setTargetParts sets the target parts of rule with the split defaults.
+func setTargetParts(rule *LookupRule, parts ...string) {
+	rule.TargetParts = parts
+	rule.SplitSeparator = "."
+	rule.OnTooFewParts = PartCountSkip
+	rule.OnTooManyParts = PartCountJoinLast
+}
+
+func TestConfigUnmarshalUnknownSettings(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    map[string]any
+		wantErr string
+	}{
+		{
+			name:    "processor setting",
+			conf:    map[string]any{"on_eror": "propagate"},
+			wantErr: "on_eror",
+		},
+		{
+			name: "lookup setting",
+			conf: map[string]any{
+				"lookups": []any{map[string]any{"source": "hosts", "source_atribute": "client.ip", "target_attribute": "host.name"}},
+			},
+			wantErr: "source_atribute",
+		},
+		{
+			name:    "source setting",
+			conf:    map[string]any{"sources": map[string]any{"hosts": map[string]any{"type": "static", "entryes": map[string]any{"a": "b"}}}},
+			wantErr: `source "hosts": error reading settings for source type "static"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewFactory().CreateDefaultConfig().(*Config)
+			assert.ErrorContains(t, confmap.NewFromStringMap(tt.conf).Unmarshal(cfg), tt.wantErr)
+		})
+	}
+}
+
+func TestConfigUnmarshalExtension(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  map[string]any
+		wantErr string
+	}{
+		{
+			name:   "extension",
+			source: map[string]any{"extension": "lookup/shared", "name": "hosts"},
+		},
+		{
+			name:    "extension with type",
+			source:  map[string]any{"extension": "lookup/shared", "type": "static"},
+			wantErr: `source "src": type cannot be used with extension`,
+		},
+		{
+			name:    "extension with settings",
+			source:  map[string]any{"extension": "lookup/shared", "entries": map[string]any{"a": "b"}},
+			wantErr: `source "src": entries cannot be used with extension`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewFactory().CreateDefaultConfig().(*Config)
+			err := confmap.NewFromStringMap(map[string]any{
+				"sources": map[string]any{"src": tt.source},
+			}).Unmarshal(cfg)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			sourceCfg := cfg.Sources["src"]
+			assert.Equal(t, component.MustNewIDWithName("lookup", "shared"), *sourceCfg.Extension)
+			assert.Equal(t, "hosts", sourceCfg.Name)
+			assert.Empty(t, sourceCfg.Type)
+			assert.Nil(t, sourceCfg.Config)
+		})
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupprocessor
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/confmap"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/extension/extensiontest"
+	"go.opentelemetry.io/collector/processor/processortest"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/metadata"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupextension"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+type cachedSourceConfig struct {
+	Cache lookupsource.CacheConfig `mapstructure:"cache"`
+}
+
+func (*cachedSourceConfig) Validate() error { return nil }
+
+// cachedSource resolves every key to "host-<key>" through a cache, counting
+// the lookups that missed it.
+type cachedSource struct {
+	misses atomic.Int64
+}
+
+func (s *cachedSource) factory() lookupsource.SourceFactory {
+	return lookupsource.NewSourceFactory(
+		"cached",
+		func() lookupsource.SourceConfig { return &cachedSourceConfig{} },
+		func(_ context.Context, _ lookupsource.CreateSettings, cfg lookupsource.SourceConfig) (lookupsource.Source, error) {
+			lookup := func(_ context.Context, key string) (any, bool, error) {
+				s.misses.Add(1)
+				return "host-" + key, true, nil
+			}
+			cache := lookupsource.NewCache(cfg.(*cachedSourceConfig).Cache)
+			return lookupsource.NewSource(
+				lookupsource.WrapWithCache(cache, lookup),
+				func() string { return "cached" },
+				nil,
+				nil,
+				lookupsource.WithCache(cache),
+			), nil
+		},
+	)
+}
+
+// extensionHost is a host holding the given extensions.
+type extensionHost struct {
+	component.Host
+	extensions map[component.ID]component.Component
+}
+
+func (h extensionHost) GetExtensions() map[component.ID]component.Component {
+	return h.extensions
+}
+
+func TestExtensionSourceSharesCache(t *testing.T) {
+	source := &cachedSource{}
+	extID := component.MustNewID("lookup")
+
+	extFactory := lookupextension.NewFactoryWithOptions(lookupextension.WithSources(source.factory()))
+	extCfg := extFactory.CreateDefaultConfig()
+	require.NoError(t, confmap.NewFromStringMap(map[string]any{
+		"sources": map[string]any{
+			"hosts": map[string]any{"type": "cached", "cache": map[string]any{"enabled": true}},
+		},
+	}).Unmarshal(extCfg))
+	ext, err := extFactory.Create(t.Context(), extensiontest.NewNopSettings(extFactory.Type()), extCfg)
+	require.NoError(t, err)
+	require.NoError(t, ext.Start(t.Context(), componenttest.NewNopHost()))
+	defer func() { require.NoError(t, ext.Shutdown(context.Background())) }()
+
+	host := extensionHost{
+		Host:       componenttest.NewNopHost(),
+		extensions: map[component.ID]component.Component{extID: ext},
+	}
+
+	factory := NewFactoryWithOptions(WithSources(source.factory()))
+	cfg := factory.CreateDefaultConfig().(*Config)
+	require.NoError(t, confmap.NewFromStringMap(map[string]any{
+		"sources": map[string]any{"hosts": map[string]any{"extension": "lookup"}},
+		"lookups": []any{map[string]any{"source": "hosts", "source_attribute": "client.ip", "target_attribute": "host.name"}},
+	}).Unmarshal(cfg))
+	require.NoError(t, cfg.Validate())
+
+	logsSink := new(consumertest.LogsSink)
+	logsProc, err := factory.CreateLogs(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, logsSink)
+	require.NoError(t, err)
+	require.NoError(t, logsProc.Start(t.Context(), host))
+	defer func() { require.NoError(t, logsProc.Shutdown(context.Background())) }()
+
+	tracesSink := new(consumertest.TracesSink)
+	tracesProc, err := factory.CreateTraces(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, tracesSink)
+	require.NoError(t, err)
+	require.NoError(t, tracesProc.Start(t.Context(), host))
+	defer func() { require.NoError(t, tracesProc.Shutdown(context.Background())) }()
+
+	// The logs look up the three test IPs, filling the extension's cache.
+	require.NoError(t, logsProc.ConsumeLogs(t.Context(), generateTestLogs()))
+	assert.Equal(t, int64(3), source.misses.Load())
+
+	// The traces look up the same IPs, and find them in the cache.
+	require.NoError(t, tracesProc.ConsumeTraces(t.Context(), generateTestTraces()))
+	assert.Equal(t, int64(3), source.misses.Load())
+
+	lr := logsSink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0)
+	hostName, ok := lr.Attributes().Get("host.name")
+	require.True(t, ok)
+	assert.Equal(t, "host-10.0.0.1", hostName.Str())
+
+	span := tracesSink.AllTraces()[0].ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0)
+	hostName, ok = span.Attributes().Get("host.name")
+	require.True(t, ok)
+	assert.Equal(t, "host-10.0.0.1", hostName.Str())
+}
+
+func TestExtensionSourceErrors(t *testing.T) {
+	extID := component.MustNewID("lookup")
+	ext, err := lookupextension.NewFactory().Create(t.Context(), extensiontest.NewNopSettings(extID.Type()), &lookupextension.Config{})
+	require.NoError(t, err)
+	var other component.Component = struct {
+		component.StartFunc
+		component.ShutdownFunc
+	}{}
+
+	tests := []struct {
+		name       string
+		source     map[string]any
+		extensions map[component.ID]component.Component
+		wantErr    string
+	}{
+		{
+			name:    "missing extension",
+			source:  map[string]any{"extension": "lookup"},
+			wantErr: `failed to resolve source "hosts": extension "lookup" not found`,
+		},
+		{
+			name:       "not a lookup extension",
+			source:     map[string]any{"extension": "lookup"},
+			extensions: map[component.ID]component.Component{extID: other},
+			wantErr:    `extension "lookup" is not a lookup extension`,
+		},
+		{
+			name:       "unknown source",
+			source:     map[string]any{"extension": "lookup", "name": "users"},
+			extensions: map[component.ID]component.Component{extID: ext},
+			wantErr:    `extension "lookup" has no source "users"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewFactory().CreateDefaultConfig().(*Config)
+			require.NoError(t, confmap.NewFromStringMap(map[string]any{
+				"sources": map[string]any{"hosts": tt.source},
+				"lookups": []any{map[string]any{"source": "hosts", "source_attribute": "client.ip", "target_attribute": "host.name"}},
+			}).Unmarshal(cfg))
+			require.NoError(t, cfg.Validate())
+
+			proc, err := NewFactory().CreateLogs(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, consumertest.NewNop())
+			require.NoError(t, err)
+			host := extensionHost{Host: componenttest.NewNopHost(), extensions: tt.extensions}
+			assert.ErrorContains(t, proc.Start(t.Context(), host), tt.wantErr)
+			assert.NoError(t, proc.Shutdown(t.Context()))
+		})
+	}
+}
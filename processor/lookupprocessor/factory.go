@@ -5,15 +5,21 @@ package lookupprocessor // import "github.com/open-telemetry/opentelemetry-colle
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"reflect"
+	"time"
 
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pipeline"
 	"go.opentelemetry.io/collector/processor"
 	"go.opentelemetry.io/collector/processor/processorhelper"
+	"go.uber.org/zap"
 
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/metadata"
-	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/noop"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/sourceconfig"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
 )
 
@@ -27,6 +33,9 @@ type FactoryOption func(*lookupProcessorFactory)
 // This REPLACES the default sources on first call, then MERGES on subsequent calls.
 // (Same pattern as transform processor's WithXxxFunctions)
 //
+// Registering two factories with the same type is an error, reported when
+// the processor is created.
+//
 // Example:
 //
 //	lookupprocessor.NewFactoryWithOptions(
@@ -35,25 +44,36 @@ type FactoryOption func(*lookupProcessorFactory)
 func WithSources(factories ...lookupsource.SourceFactory) FactoryOption {
 	return func(f *lookupProcessorFactory) {
 		if !f.defaultSourcesOverridden {
-			f.sources = make(map[string]lookupsource.SourceFactory)
+			f.sources = lookupsource.NewRegistry()
 			f.defaultSourcesOverridden = true
 		}
 		for _, factory := range factories {
-			f.sources[factory.Type()] = factory
+			f.registrationErr = errors.Join(f.registrationErr, f.sources.Register(factory))
 		}
 	}
 }
 
+// WithReloadHandover makes the processors hand their started sources over
+// with handover, see [ReloadHandover].
+func WithReloadHandover(handover *ReloadHandover) FactoryOption {
+	return func(f *lookupProcessorFactory) {
+		f.handover = handover
+	}
+}
+
 type lookupProcessorFactory struct {
-	sources                  map[string]lookupsource.SourceFactory
+	sources                  *lookupsource.Registry
 	defaultSourcesOverridden bool
+	registrationErr          error
+	// handover is the handover of WithReloadHandover, if any.
+	handover *ReloadHandover
 }
 
-func defaultSources() map[string]lookupsource.SourceFactory {
-	return map[string]lookupsource.SourceFactory{
-		"noop": noop.NewFactory(),
-		// yaml and dns sources will be added in subsequent branches
-	}
+// processorKey identifies the processors whose sources are handed over
+// across configuration reloads.
+type processorKey struct {
+	id     component.ID
+	signal pipeline.Signal
 }
 
 func NewFactory() processor.Factory {
@@ -74,7 +94,7 @@ func NewFactory() processor.Factory {
 //	)
 func NewFactoryWithOptions(options ...FactoryOption) processor.Factory {
 	f := &lookupProcessorFactory{
-		sources: defaultSources(),
+		sources: sourceconfig.DefaultRegistry(),
 	}
 	for _, opt := range options {
 		opt(f)
@@ -84,14 +104,23 @@ func NewFactoryWithOptions(options ...FactoryOption) processor.Factory {
 		metadata.Type,
 		f.createDefaultConfig,
 		processor.WithLogs(f.createLogsProcessor, metadata.LogsStability),
+		processor.WithTraces(f.createTracesProcessor, metadata.TracesStability),
+		processor.WithMetrics(f.createMetricsProcessor, metadata.MetricsStability),
 	)
 }
 
-func (*lookupProcessorFactory) createDefaultConfig() component.Config {
+func (f *lookupProcessorFactory) createDefaultConfig() component.Config {
 	return &Config{
-		Source: SourceConfig{
-			Type: "noop",
+		MaxConcurrency:      1,
+		ErrorMode:           ottl.IgnoreError,
+		OnError:             LookupErrorSkip,
+		MultiValue:          lookupsource.MultiValueArray,
+		MultiValueSeparator: ",",
+		MetricsCardinalityLimit: CardinalityLimit{
+			Window:        time.Hour,
+			OverflowValue: "overflow",
 		},
+		sources: f.sources,
 	}
 }
 
@@ -101,15 +130,12 @@ func (f *lookupProcessorFactory) createLogsProcessor(
 	cfg component.Config,
 	next consumer.Logs,
 ) (processor.Logs, error) {
-	processorCfg := cfg.(*Config)
-
-	source, err := f.createSource(ctx, set, processorCfg)
+	key := processorKey{id: set.ID, signal: pipeline.SignalLogs}
+	proc, err := f.newProcessor(ctx, set, cfg, key, (*lookupProcessor).parseLogRules)
 	if err != nil {
 		return nil, err
 	}
 
-	proc := newLookupProcessor(source, set.Logger)
-
 	return processorhelper.NewLogs(
 		ctx,
 		set,
@@ -118,35 +144,192 @@ func (f *lookupProcessorFactory) createLogsProcessor(
 		proc.processLogs,
 		processorhelper.WithCapabilities(processorCapabilities),
 		processorhelper.WithStart(proc.Start),
-		processorhelper.WithShutdown(proc.Shutdown),
+		processorhelper.WithShutdown(f.shutdown(key, proc)),
 	)
 }
 
-func (f *lookupProcessorFactory) createSource(
+func (f *lookupProcessorFactory) createTracesProcessor(
+	ctx context.Context,
+	set processor.Settings,
+	cfg component.Config,
+	next consumer.Traces,
+) (processor.Traces, error) {
+	key := processorKey{id: set.ID, signal: pipeline.SignalTraces}
+	proc, err := f.newProcessor(ctx, set, cfg, key, (*lookupProcessor).parseSpanRules)
+	if err != nil {
+		return nil, err
+	}
+
+	return processorhelper.NewTraces(
+		ctx,
+		set,
+		cfg,
+		next,
+		proc.processTraces,
+		processorhelper.WithCapabilities(processorCapabilities),
+		processorhelper.WithStart(proc.Start),
+		processorhelper.WithShutdown(f.shutdown(key, proc)),
+	)
+}
+
+func (f *lookupProcessorFactory) createMetricsProcessor(
+	ctx context.Context,
+	set processor.Settings,
+	cfg component.Config,
+	next consumer.Metrics,
+) (processor.Metrics, error) {
+	key := processorKey{id: set.ID, signal: pipeline.SignalMetrics}
+	proc, err := f.newProcessor(ctx, set, cfg, key, (*lookupProcessor).parseDataPointRules)
+	if err != nil {
+		return nil, err
+	}
+
+	return processorhelper.NewMetrics(
+		ctx,
+		set,
+		cfg,
+		next,
+		proc.processMetrics,
+		processorhelper.WithCapabilities(processorCapabilities),
+		processorhelper.WithStart(proc.Start),
+		processorhelper.WithShutdown(f.shutdown(key, proc)),
+	)
+}
+
+// newProcessor creates the processor of key, whose rules parseRules parses
+// for its signal. If the handover retired sources for key, such as the ones
+// of the processor the collector shut down to reload its configuration, the
+// new processor
+// takes over the ones cfg still configures. Their new settings are applied
+// once the processor is built, so that a configuration that fails to build
+// leaves them as they were. If the processor fails to be created, they are
+// retired again for the next attempt.
+func (f *lookupProcessorFactory) newProcessor(
+	ctx context.Context,
+	set processor.Settings,
+	cfg component.Config,
+	key processorKey,
+	parseRules func(*lookupProcessor, component.TelemetrySettings) error,
+) (*lookupProcessor, error) {
+	if f.registrationErr != nil {
+		return nil, f.registrationErr
+	}
+
+	previous := f.handover.take(key)
+	proc, err := f.buildProcessor(ctx, set, cfg.(*Config), previous, parseRules)
+	if err != nil {
+		if previous != nil {
+			err = errors.Join(err, f.handover.retire(ctx, key, previous))
+		}
+		return nil, err
+	}
+	return proc, nil
+}
+
+// buildProcessor creates the processor for cfg, taking over the sources of
+// previous, if not nil, that cfg configures again with the same type. The
+// sources of previous it doesn't take over are shut down once it is built.
+func (f *lookupProcessorFactory) buildProcessor(
 	ctx context.Context,
 	set processor.Settings,
 	cfg *Config,
-) (lookupsource.Source, error) {
-	sourceType := cfg.Source.Type
-	if sourceType == "" {
-		sourceType = "noop"
+	previous *retiredSources,
+	parseRules func(*lookupProcessor, component.TelemetrySettings) error,
+) (*lookupProcessor, error) {
+	var adopted map[string]lookupsource.Source
+	if previous != nil {
+		adopted = previous.handOver(cfg)
+	}
+
+	sources := make(map[string]lookupsource.Source, len(cfg.Sources))
+	for name, sourceCfg := range cfg.Sources {
+		if sourceCfg.Extension != nil {
+			// Resolved from the extension when the processor starts.
+			continue
+		}
+		if source, ok := adopted[name]; ok {
+			sources[name] = source
+			continue
+		}
+		source, err := f.createSource(ctx, set, sourceCfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create source %q: %w", name, err)
+		}
+		sources[name] = source
+	}
+
+	proc, err := newLookupProcessor(set.ID, cfg, sources, set.Logger, set.MeterProvider.Meter(metadata.ScopeName))
+	if err != nil {
+		return nil, err
+	}
+	if err := parseRules(proc, set.TelemetrySettings); err != nil {
+		return nil, err
+	}
+	if previous == nil {
+		return proc, nil
 	}
 
-	factory, ok := f.sources[sourceType]
-	if !ok {
-		return nil, fmt.Errorf("unknown source type %q", sourceType)
+	for _, name := range sortedNames(adopted) {
+		sourceCfg := cfg.Sources[name]
+		if reflect.DeepEqual(sourceCfg.Config, previous.configs[name].Config) {
+			continue
+		}
+		err := lookupsource.Reconfigure(ctx, adopted[name], sourceCfg.Config)
+		if err == nil {
+			// Retired again with its new settings if a later source fails
+			// to be created.
+			previous.configs[name] = sourceCfg
+			set.Logger.Info("Source reconfigured in place", zap.String("source", name))
+			continue
+		}
+		set.Logger.Info("Source created again to apply its new settings", zap.String("source", name), zap.Error(err))
+		source, err := f.createSource(ctx, set, sourceCfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create source %q: %w", name, err)
+		}
+		if err := proc.replaceSource(name, source); err != nil {
+			return nil, err
+		}
+		delete(adopted, name)
 	}
 
-	// Get the source-specific config from the raw config
-	sourceCfg := cfg.Source.Config
-	if sourceCfg == nil {
-		sourceCfg = factory.CreateDefaultConfig()
+	// The adopted sources are started already, and keep their start order.
+	for _, name := range previous.started {
+		if _, ok := adopted[name]; ok {
+			proc.started = append(proc.started, name)
+		}
 	}
+	for i := len(previous.started) - 1; i >= 0; i-- {
+		name := previous.started[i]
+		if _, ok := adopted[name]; ok {
+			continue
+		}
+		if err := previous.sources[name].Shutdown(ctx); err != nil {
+			set.Logger.Warn("Failed to shut down source not taken over", zap.String("source", name), zap.Error(err))
+		}
+	}
+	return proc, nil
+}
 
-	createSettings := lookupsource.CreateSettings{
-		TelemetrySettings: set.TelemetrySettings,
-		BuildInfo:         set.BuildInfo,
+// shutdown returns the shutdown function of proc, the processor of key. With
+// a handover, the started sources of proc are retired for the processor of
+// key created next instead of being shut down.
+func (f *lookupProcessorFactory) shutdown(key processorKey, proc *lookupProcessor) component.ShutdownFunc {
+	return func(ctx context.Context) error {
+		var errs error
+		if f.handover != nil {
+			if r := proc.retire(); r != nil {
+				errs = f.handover.retire(ctx, key, r)
+			}
+		}
+		return errors.Join(errs, proc.Shutdown(ctx))
 	}
+}
 
-	return factory.CreateSource(ctx, createSettings, sourceCfg)
+func (f *lookupProcessorFactory) createSource(
+	ctx context.Context,
+	set processor.Settings,
+	cfg SourceConfig,
+) (lookupsource.Source, error) {
+	return sourceconfig.Create(ctx, f.sources, cfg.typ(), cfg.Config, set.TelemetrySettings, set.BuildInfo)
 }
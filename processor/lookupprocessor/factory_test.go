@@ -0,0 +1,215 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupprocessor
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/confmap"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/processor"
+	"go.opentelemetry.io/collector/processor/processortest"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/metadata"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/noop"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/static"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+func TestWithSources(t *testing.T) {
+	factory := NewFactoryWithOptions(WithSources(static.NewFactory()))
+
+	cfg := factory.CreateDefaultConfig().(*Config)
+	err := confmap.NewFromStringMap(map[string]any{
+		"sources": map[string]any{
+			"src": map[string]any{
+				"type":    "static",
+				"entries": map[string]any{"a": "b"},
+			},
+		},
+	}).Unmarshal(cfg)
+	require.NoError(t, err)
+
+	proc, err := factory.CreateLogs(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, consumertest.NewNop())
+	require.NoError(t, err)
+	assert.NotNil(t, proc)
+
+	// The defaults were replaced, so noop is no longer available.
+	cfg = factory.CreateDefaultConfig().(*Config)
+	cfg.Sources = map[string]SourceConfig{"src": {Type: "noop"}}
+	_, err = factory.CreateLogs(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, consumertest.NewNop())
+	assert.ErrorContains(t, err, `unknown source type "noop", available types: [static]`)
+}
+
+func TestWithSourcesDuplicateType(t *testing.T) {
+	factory := NewFactoryWithOptions(
+		WithSources(noop.NewFactory()),
+		WithSources(noop.NewFactory()),
+	)
+
+	cfg := factory.CreateDefaultConfig()
+	_, err := factory.CreateLogs(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, consumertest.NewNop())
+	assert.ErrorContains(t, err, `source type "noop" is already registered`)
+}
+
+type reloadSourceConfig struct {
+	Value string `mapstructure:"value"`
+	// InPlace makes the source apply new settings in place.
+	InPlace bool `mapstructure:"in_place"`
+}
+
+func (*reloadSourceConfig) Validate() error { return nil }
+
+// reloadSource counts the sources it creates, reconfigures and shuts down.
+// They find every key with their configured value.
+type reloadSource struct {
+	created      atomic.Int64
+	reconfigured atomic.Int64
+	shutdown     atomic.Int64
+}
+
+func (s *reloadSource) factory() lookupsource.SourceFactory {
+	return lookupsource.NewSourceFactory(
+		"reload",
+		func() lookupsource.SourceConfig { return &reloadSourceConfig{} },
+		func(_ context.Context, _ lookupsource.CreateSettings, cfg lookupsource.SourceConfig) (lookupsource.Source, error) {
+			s.created.Add(1)
+			var value atomic.Value
+			value.Store(cfg.(*reloadSourceConfig).Value)
+			return lookupsource.NewSource(
+				func(context.Context, string) (any, bool, error) { return value.Load(), true, nil },
+				func() string { return "reload" },
+				nil,
+				func(context.Context) error {
+					s.shutdown.Add(1)
+					return nil
+				},
+				lookupsource.WithReconfigure(func(_ context.Context, cfg lookupsource.SourceConfig) error {
+					sourceCfg := cfg.(*reloadSourceConfig)
+					if !sourceCfg.InPlace {
+						return lookupsource.ErrReconfigureUnsupported
+					}
+					s.reconfigured.Add(1)
+					value.Store(sourceCfg.Value)
+					return nil
+				}),
+			), nil
+		},
+	)
+}
+
+func TestReloadHandOver(t *testing.T) {
+	source := &reloadSource{}
+	handover := NewReloadHandover(time.Minute)
+	set := processortest.NewNopSettings(metadata.Type)
+	// newProcessor creates the processor with a new factory, as the
+	// collector does once it shut the processor of the previous
+	// configuration down.
+	newProcessor := func(handover *ReloadHandover, host, zone string, modify func(*Config)) (processor.Logs, error) {
+		factory := NewFactoryWithOptions(WithSources(source.factory()), WithReloadHandover(handover))
+		cfg := factory.CreateDefaultConfig().(*Config)
+		require.NoError(t, confmap.NewFromStringMap(map[string]any{
+			"sources": map[string]any{
+				"hosts": map[string]any{"type": "reload", "value": host, "in_place": true},
+				"zones": map[string]any{"type": "reload", "value": zone},
+			},
+			"lookups": []any{
+				map[string]any{"source": "hosts", "source_attribute": "client.ip", "target_attribute": "host.name"},
+				map[string]any{"source": "zones", "source_attribute": "client.ip", "target_attribute": "cloud.region"},
+			},
+		}).Unmarshal(cfg))
+		if modify != nil {
+			modify(cfg)
+		}
+		return factory.CreateLogs(t.Context(), set, cfg, consumertest.NewNop())
+	}
+	start := func(handover *ReloadHandover, host, zone string) processor.Logs {
+		proc, err := newProcessor(handover, host, zone, nil)
+		require.NoError(t, err)
+		require.NoError(t, proc.Start(t.Context(), componenttest.NewNopHost()))
+		return proc
+	}
+	consume := func(proc processor.Logs) map[string]any {
+		ld := plog.NewLogs()
+		ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().Attributes().PutStr("client.ip", "10.0.0.1")
+		require.NoError(t, proc.ConsumeLogs(t.Context(), ld))
+		return ld.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Attributes().AsRaw()
+	}
+
+	first := start(handover, "web-1", "eu-west-1")
+	attrs := consume(first)
+	assert.Equal(t, "web-1", attrs["host.name"])
+	assert.Equal(t, "eu-west-1", attrs["cloud.region"])
+	require.NoError(t, first.Shutdown(t.Context()))
+	// The sources keep running for the processor of the next configuration.
+	assert.Zero(t, source.shutdown.Load())
+
+	// A processor that fails to be created leaves the sources as they were,
+	// for the next attempt.
+	_, err := newProcessor(handover, "web-2", "us-east-1", func(cfg *Config) {
+		cfg.Lookups[0].SourceAttributes = map[string]string{"ip": "client.ip"}
+	})
+	require.ErrorContains(t, err, `source "hosts" of type "reload" doesn't support source_attributes`)
+	assert.Equal(t, int64(2), source.created.Load())
+	assert.Zero(t, source.reconfigured.Load())
+	assert.Zero(t, source.shutdown.Load())
+
+	// Another handover, such as the one of another collector, doesn't see
+	// the retired sources.
+	otherHandover := NewReloadHandover(time.Minute)
+	other := start(otherHandover, "web-1", "eu-west-1")
+	assert.Equal(t, int64(4), source.created.Load())
+	require.NoError(t, other.Shutdown(t.Context()))
+	require.NoError(t, otherHandover.Shutdown(t.Context()))
+	assert.Equal(t, int64(2), source.shutdown.Load())
+
+	// The processor of the next configuration takes hosts over, reconfigured
+	// in place, and creates zones again, which can't apply its new settings.
+	second := start(handover, "web-1-new", "us-east-1")
+	attrs = consume(second)
+	assert.Equal(t, "web-1-new", attrs["host.name"])
+	assert.Equal(t, "us-east-1", attrs["cloud.region"])
+	assert.Equal(t, int64(5), source.created.Load())
+	assert.Equal(t, int64(1), source.reconfigured.Load())
+	assert.Equal(t, int64(3), source.shutdown.Load())
+
+	// Once the collector stopped, shutting the handover down shuts down the
+	// sources no processor took over, and the ones of processors shut down
+	// later, before returning.
+	require.NoError(t, second.Shutdown(t.Context()))
+	assert.Equal(t, int64(3), source.shutdown.Load())
+	require.NoError(t, handover.Shutdown(t.Context()))
+	assert.Equal(t, int64(5), source.shutdown.Load())
+	third := start(handover, "web-1", "eu-west-1")
+	require.NoError(t, third.Shutdown(t.Context()))
+	assert.Equal(t, source.created.Load(), source.shutdown.Load())
+	assert.Empty(t, handover.retired)
+}
+
+func TestReloadHandOverGracePeriod(t *testing.T) {
+	source := &reloadSource{}
+	handover := NewReloadHandover(10 * time.Millisecond)
+	factory := NewFactoryWithOptions(WithSources(source.factory()), WithReloadHandover(handover))
+	cfg := factory.CreateDefaultConfig().(*Config)
+	require.NoError(t, confmap.NewFromStringMap(map[string]any{
+		"sources": map[string]any{"hosts": map[string]any{"type": "reload", "value": "web-1"}},
+		"lookups": []any{map[string]any{"source": "hosts", "source_attribute": "client.ip", "target_attribute": "host.name"}},
+	}).Unmarshal(cfg))
+	proc, err := factory.CreateLogs(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, consumertest.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, proc.Start(t.Context(), componenttest.NewNopHost()))
+	require.NoError(t, proc.Shutdown(t.Context()))
+
+	// Sources no processor takes over are shut down after the grace period.
+	assert.Eventually(t, func() bool { return source.shutdown.Load() == 1 }, time.Second, time.Millisecond)
+	require.NoError(t, handover.Shutdown(t.Context()))
+	assert.Equal(t, int64(1), source.shutdown.Load())
+}
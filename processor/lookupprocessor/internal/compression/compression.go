@@ -0,0 +1,96 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package compression decompresses the files sources load entries from, which
+// are usually shipped compressed when they are large.
+package compression // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/compression"
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codec is a compression format.
+type Codec string
+
+const (
+	// None is uncompressed content.
+	None Codec = ""
+	// Gzip is content compressed with gzip, in files ending in .gz.
+	Gzip Codec = "gzip"
+	// Zstd is content compressed with Zstandard, in files ending in .zst.
+	Zstd Codec = "zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// NewReader returns a reader streaming the decompressed content of r, the
+// content of the file or object called name. The codec is selected by the
+// extension of name, .gz or .zst, or else detected from the first bytes of
+// r, so that a compressed file without the extension is decompressed too.
+// Content that is neither is read as is. Closing the reader doesn't close r.
+func NewReader(r io.Reader, name string) (io.ReadCloser, error) {
+	codec := codecOf(name)
+	if codec == None {
+		buffered := bufio.NewReader(r)
+		codec = detect(buffered)
+		r = buffered
+	}
+
+	switch codec {
+	case Gzip:
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gzip header: %w", err)
+		}
+		return zr, nil
+	case Zstd:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd reader: %w", err)
+		}
+		return zr.IOReadCloser(), nil
+	default:
+		return io.NopCloser(r), nil
+	}
+}
+
+// codecOf returns the codec of the files with the extension of name.
+func codecOf(name string) Codec {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".gz", ".gzip":
+		return Gzip
+	case ".zst", ".zstd":
+		return Zstd
+	default:
+		return None
+	}
+}
+
+// detect returns the codec whose magic number r starts with, without
+// consuming it.
+func detect(r *bufio.Reader) Codec {
+	head, err := r.Peek(len(zstdMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return None
+	}
+	switch {
+	case bytes.HasPrefix(head, gzipMagic):
+		return Gzip
+	case bytes.HasPrefix(head, zstdMagic):
+		return Zstd
+	default:
+		return None
+	}
+}
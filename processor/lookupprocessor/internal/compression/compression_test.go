@@ -0,0 +1,85 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const content = "ip,host\n10.0.0.1,web-1\n"
+
+func gzipped(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write([]byte(s))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func zstded(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := zstd.NewWriter(&buf)
+	require.NoError(t, err)
+	_, err = w.Write([]byte(s))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestNewReader(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		data     []byte
+	}{
+		{name: "plain", fileName: "hosts.csv", data: []byte(content)},
+		{name: "gzip by extension", fileName: "hosts.csv.gz", data: gzipped(t, content)},
+		{name: "gzip by magic", fileName: "hosts.csv", data: gzipped(t, content)},
+		{name: "zstd by extension", fileName: "hosts.csv.ZST", data: zstded(t, content)},
+		{name: "zstd by magic", fileName: "hosts", data: zstded(t, content)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewReader(bytes.NewReader(tt.data), tt.fileName)
+			require.NoError(t, err)
+			defer r.Close()
+			got, err := io.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, content, string(got))
+		})
+	}
+}
+
+func TestNewReaderShort(t *testing.T) {
+	// Content shorter than the longest magic number is read as is.
+	for _, s := range []string{"", "a", "\x1f"} {
+		r, err := NewReader(bytes.NewReader([]byte(s)), "keys")
+		require.NoError(t, err)
+		got, err := io.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, s, string(got))
+	}
+}
+
+func TestNewReaderCorrupt(t *testing.T) {
+	_, err := NewReader(bytes.NewReader([]byte(content)), "hosts.csv.gz")
+	assert.ErrorContains(t, err, "failed to read gzip header")
+
+	data := gzipped(t, content)
+	r, err := NewReader(bytes.NewReader(data[:len(data)-4]), "hosts.csv.gz")
+	require.NoError(t, err)
+	_, err = io.ReadAll(r)
+	assert.Error(t, err)
+}
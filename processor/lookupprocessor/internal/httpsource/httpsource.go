@@ -0,0 +1,94 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package httpsource provides the parts shared by the lookup sources querying
+// an HTTP API: the client configured by confighttp, the result cache, and
+// the bounded decoding of responses.
+package httpsource // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/httpsource"
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/confighttp"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+// ErrNotStarted is returned by [Client.Do] before the source is started.
+var ErrNotStarted = errors.New("source not started")
+
+// Client is the HTTP client of a source. It is created when the source
+// starts, because authentication extensions are only available from the
+// host.
+type Client struct {
+	typ       string
+	cfg       *confighttp.ClientConfig
+	telemetry component.TelemetrySettings
+
+	// CheckRedirect, if set, is the redirect policy of the client, see
+	// [http.Client.CheckRedirect].
+	CheckRedirect func(req *http.Request, via []*http.Request) error
+
+	client *http.Client
+}
+
+// NewClient returns the client of a source of type typ, configured by cfg.
+func NewClient(typ string, cfg *confighttp.ClientConfig, telemetry component.TelemetrySettings) *Client {
+	return &Client{typ: typ, cfg: cfg, telemetry: telemetry}
+}
+
+// Do sends req.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	if c.client == nil {
+		return nil, fmt.Errorf("%s %w", c.typ, ErrNotStarted)
+	}
+	return c.client.Do(req)
+}
+
+func (c *Client) start(ctx context.Context, host component.Host) error {
+	client, err := c.cfg.ToClient(ctx, host.GetExtensions(), c.telemetry)
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP client: %w", err)
+	}
+	if c.CheckRedirect != nil {
+		client.CheckRedirect = c.CheckRedirect
+	}
+	c.client = client
+	return nil
+}
+
+func (c *Client) shutdown() {
+	if c.client != nil {
+		c.client.CloseIdleConnections()
+		c.client = nil
+	}
+}
+
+// NewSource returns a source of the type of client, whose lookups are cached
+// as configured by cacheCfg. Starting it creates client, and shutting it
+// down closes its idle connections.
+func NewSource(client *Client, cacheCfg lookupsource.CacheConfig, lookup lookupsource.LookupFunc) lookupsource.Source {
+	cache := lookupsource.NewCache(cacheCfg)
+	return lookupsource.NewSource(
+		lookupsource.WrapWithCache(cache, lookup),
+		func() string { return client.typ },
+		client.start,
+		func(ctx context.Context) error {
+			client.shutdown()
+			return cache.Shutdown(ctx)
+		},
+		lookupsource.WithCache(cache),
+	)
+}
+
+// NewDecoder returns a JSON decoder of the body of resp, reading at most
+// maxSize bytes.
+func NewDecoder(resp *http.Response, maxSize int64) *json.Decoder {
+	return json.NewDecoder(io.LimitReader(resp.Body, maxSize))
+}
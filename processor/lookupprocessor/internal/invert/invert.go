@@ -0,0 +1,73 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package invert inverts the entries sources load, so that they are looked
+// up by value and return their key, such as a dataset keyed by IP address
+// resolving host names to addresses.
+package invert // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/invert"
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// Collision selects the value of an inverted entry whose value is shared by
+// several keys.
+type Collision string
+
+const (
+	// First returns the first of the keys in sorted order.
+	First Collision = "first"
+	// List returns the list of the keys, sorted.
+	List Collision = "list"
+	// Error fails the inversion.
+	Error Collision = "error"
+)
+
+// Validate returns an error if c is not a known action. The empty action is
+// First.
+func (c Collision) Validate() error {
+	switch c {
+	case "", First, List, Error:
+		return nil
+	default:
+		return fmt.Errorf("unsupported invert_collision %q, must be %q, %q or %q", c, First, List, Error)
+	}
+}
+
+// Entries maps the values of entries, which must be strings, to their keys,
+// and returns the number of values shared by several keys. Keys are visited
+// in sorted order, so that the result doesn't depend on the order entries
+// were read in.
+func Entries(entries map[string]any, collision Collision) (map[string]any, int, error) {
+	keys := make(map[string][]string, len(entries))
+	for _, key := range slices.Sorted(maps.Keys(entries)) {
+		value, ok := entries[key].(string)
+		if !ok {
+			return nil, 0, fmt.Errorf("value of key %q is not a string", key)
+		}
+		keys[value] = append(keys[value], key)
+	}
+
+	inverted := make(map[string]any, len(keys))
+	collisions := 0
+	for value, valueKeys := range keys {
+		if len(valueKeys) > 1 {
+			collisions++
+		}
+		switch {
+		case len(valueKeys) > 1 && collision == Error:
+			return nil, 0, fmt.Errorf("value %q is shared by keys %v", value, valueKeys)
+		case collision == List:
+			list := make([]any, len(valueKeys))
+			for i, key := range valueKeys {
+				list[i] = key
+			}
+			inverted[value] = list
+		default:
+			inverted[value] = valueKeys[0]
+		}
+	}
+	return inverted, collisions, nil
+}
@@ -0,0 +1,99 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package jsonnumber decodes the numbers of the JSON documents sources load
+// entries from. encoding/json decodes them as float64 by default, which
+// can't hold integers above 2^53 exactly, so large identifiers such as
+// account IDs would be rounded and written in scientific notation.
+package jsonnumber // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/jsonnumber"
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Mode is how numbers are decoded.
+type Mode string
+
+const (
+	// Float64 decodes every number as float64, like encoding/json.
+	Float64 Mode = "float64"
+	// Int64 decodes integers that fit as int64, and other numbers as
+	// float64.
+	Int64 Mode = "int64"
+	// String decodes numbers as strings holding their exact text, such as
+	// integers too large for int64.
+	String Mode = "string"
+)
+
+// Validate returns an error if m is not a known mode. The empty mode is
+// Float64.
+func (m Mode) Validate() error {
+	switch m {
+	case "", Float64, Int64, String:
+		return nil
+	default:
+		return fmt.Errorf("invalid json_numbers %q, must be %q, %q or %q", m, Float64, Int64, String)
+	}
+}
+
+// Unmarshal is like json.Unmarshal into the map pointed to by v, decoding
+// numbers according to mode.
+func Unmarshal(data []byte, v *map[string]any, mode Mode) error {
+	dec := newDecoder(bytes.NewReader(data), mode)
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return errors.New("invalid data after top-level value")
+	}
+	Convert(*v, mode)
+	return nil
+}
+
+// Decode decodes the first JSON value of r into the map pointed to by v,
+// decoding numbers according to mode.
+func Decode(r io.Reader, v *map[string]any, mode Mode) error {
+	if err := newDecoder(r, mode).Decode(v); err != nil {
+		return err
+	}
+	Convert(*v, mode)
+	return nil
+}
+
+func newDecoder(r io.Reader, mode Mode) *json.Decoder {
+	dec := json.NewDecoder(r)
+	if mode == Int64 || mode == String {
+		dec.UseNumber()
+	}
+	return dec
+}
+
+// Convert replaces the json.Number values of val, decoded with UseNumber,
+// according to mode, in place for maps and slices, and returns the
+// converted value.
+func Convert(val any, mode Mode) any {
+	switch v := val.(type) {
+	case json.Number:
+		if mode == String {
+			return v.String()
+		}
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		for k, e := range v {
+			v[k] = Convert(e, mode)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = Convert(e, mode)
+		}
+	}
+	return val
+}
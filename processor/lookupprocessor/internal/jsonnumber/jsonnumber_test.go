@@ -0,0 +1,77 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package jsonnumber
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const document = `{"account_id": 9007199254740993, "ratio": 0.25, "huge": 123456789012345678901234567890, "nested": {"ids": [1, 9223372036854775807]}, "name": "web"}`
+
+func TestUnmarshal(t *testing.T) {
+	tests := []struct {
+		mode Mode
+		want map[string]any
+	}{
+		{
+			mode: Float64,
+			want: map[string]any{
+				"account_id": float64(9007199254740992),
+				"ratio":      0.25,
+				"huge":       1.2345678901234568e29,
+				"nested":     map[string]any{"ids": []any{float64(1), float64(9223372036854775807)}},
+				"name":       "web",
+			},
+		},
+		{
+			mode: Int64,
+			want: map[string]any{
+				"account_id": int64(9007199254740993),
+				"ratio":      0.25,
+				// Integers that don't fit in int64 are decoded as float64.
+				"huge":   1.2345678901234568e29,
+				"nested": map[string]any{"ids": []any{int64(1), int64(9223372036854775807)}},
+				"name":   "web",
+			},
+		},
+		{
+			mode: String,
+			want: map[string]any{
+				"account_id": "9007199254740993",
+				"ratio":      "0.25",
+				"huge":       "123456789012345678901234567890",
+				"nested":     map[string]any{"ids": []any{"1", "9223372036854775807"}},
+				"name":       "web",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			var got map[string]any
+			require.NoError(t, Unmarshal([]byte(document), &got, tt.mode))
+			assert.Equal(t, tt.want, got)
+
+			got = nil
+			require.NoError(t, Decode(strings.NewReader(document), &got, tt.mode))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestUnmarshalTrailingData(t *testing.T) {
+	var got map[string]any
+	assert.Error(t, Unmarshal([]byte(`{"a": 1} {"b": 2}`), &got, Int64))
+	assert.NoError(t, Unmarshal([]byte("{\"a\": 1}\n"), &got, Int64))
+}
+
+func TestModeValidate(t *testing.T) {
+	for _, mode := range []Mode{"", Float64, Int64, String} {
+		assert.NoError(t, mode.Validate(), mode)
+	}
+	assert.EqualError(t, Mode("number").Validate(), `invalid json_numbers "number", must be "float64", "int64" or "string"`)
+}
@@ -0,0 +1,233 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package keyed provides a variant of the cache and lookup contract of
+// lookupsource keyed by any comparable type, such as netip.Addr or
+// netip.Prefix, so that sources working on parsed keys don't format them
+// back into strings on every lookup. Sources keep the string API of
+// lookupsource towards the processor, whose keys are attribute values, and
+// adapt it with [StringLookup].
+package keyed // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/keyed"
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+// LookupFunc is a [lookupsource.LookupFunc] taking a key of type K.
+type LookupFunc[K comparable] func(ctx context.Context, key K) (any, bool, error)
+
+// StringLookup adapts fn to the string keys of lookupsource. Keys parse
+// rejects are not found, without calling fn.
+func StringLookup[K comparable](fn LookupFunc[K], parse func(string) (K, bool)) lookupsource.LookupFunc {
+	return func(ctx context.Context, key string) (any, bool, error) {
+		k, ok := parse(key)
+		if !ok {
+			return nil, false, nil
+		}
+		return fn(ctx, k)
+	}
+}
+
+// Cache is a size-bounded cache with optional TTL expiration, like
+// [lookupsource.Cache], keyed by K. It honors the Size, TTL, MinTTL, MaxTTL
+// and EvictInSetOrder settings of its [lookupsource.CacheConfig]; the others
+// apply to string keys or to the wrappers of lookupsource. It is safe for
+// concurrent use.
+type Cache[K comparable] struct {
+	config lookupsource.CacheConfig
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[K]entry
+	// order holds the keys from least to most recently used, or set with
+	// EvictInSetOrder.
+	order     list.List
+	hits      atomic.Int64
+	misses    atomic.Int64
+	evictions atomic.Int64
+	// closed is set by Shutdown.
+	closed bool
+}
+
+type entry struct {
+	value     any
+	expiresAt time.Time
+	// elem is the element of the key in Cache.order.
+	elem *list.Element
+}
+
+// defaultSize is the size of caches configured without one, like the one of
+// lookupsource.
+const defaultSize = 1000
+
+// NewCache returns a cache configured by cfg.
+func NewCache[K comparable](cfg lookupsource.CacheConfig) *Cache[K] {
+	if cfg.Size <= 0 {
+		cfg.Size = defaultSize
+	}
+	return &Cache[K]{
+		config:  cfg,
+		now:     time.Now,
+		entries: make(map[K]entry),
+	}
+}
+
+// Get returns the value of key, and whether it was found.
+func (c *Cache[K]) Get(key K) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, false
+	}
+	e, ok := c.entries[key]
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+	if !e.expiresAt.IsZero() && !c.now().Before(e.expiresAt) {
+		c.remove(key)
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.hits.Add(1)
+	if !c.config.EvictInSetOrder {
+		c.order.MoveToBack(e.elem)
+	}
+	return e.value, true
+}
+
+// Set stores value for key with the configured TTL, replacing any previous
+// value.
+func (c *Cache[K]) Set(key K, value any) {
+	c.SetWithTTL(key, value, c.config.TTL)
+}
+
+// SetWithTTL sets key to value like Set, with the time-to-live ttl instead
+// of the configured TTL. A ttl of zero or less means no expiration.
+func (c *Cache[K]) SetWithTTL(key K, value any, ttl time.Duration) {
+	ttl = c.clampTTL(ttl)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return
+	}
+	e := entry{value: value}
+	if ttl > 0 {
+		e.expiresAt = c.now().Add(ttl)
+	}
+	if old, ok := c.entries[key]; ok {
+		c.order.MoveToBack(old.elem)
+		e.elem = old.elem
+	} else {
+		if len(c.entries) >= c.config.Size {
+			c.remove(c.order.Front().Value.(K))
+			c.evictions.Add(1)
+		}
+		e.elem = c.order.PushBack(key)
+	}
+	c.entries[key] = e
+}
+
+// Delete removes the entry of key, if any.
+func (c *Cache[K]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; ok {
+		c.remove(key)
+	}
+}
+
+// Clear removes every entry.
+func (c *Cache[K]) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[K]entry)
+	c.order.Init()
+}
+
+// Len returns the number of entries, including expired ones not yet removed.
+func (c *Cache[K]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Stats returns the statistics of the cache.
+func (c *Cache[K]) Stats() lookupsource.CacheStats {
+	return lookupsource.CacheStats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+		Size:      c.Len(),
+	}
+}
+
+// Shutdown releases the entries of the cache. Afterwards, Get finds nothing
+// and Set does nothing.
+func (c *Cache[K]) Shutdown(_ context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	c.entries = make(map[K]entry)
+	c.order.Init()
+	return nil
+}
+
+// clampTTL applies MinTTL and MaxTTL to ttl, where zero or less means no
+// expiration.
+func (c *Cache[K]) clampTTL(ttl time.Duration) time.Duration {
+	if ttl > 0 && ttl < c.config.MinTTL {
+		ttl = c.config.MinTTL
+	}
+	if c.config.MaxTTL > 0 && (ttl <= 0 || ttl > c.config.MaxTTL) {
+		ttl = c.config.MaxTTL
+	}
+	return ttl
+}
+
+// remove deletes key from the cache. c.mu must be held.
+func (c *Cache[K]) remove(key K) {
+	c.order.Remove(c.entries[key].elem)
+	delete(c.entries, key)
+}
+
+// WrapWithCache wraps fn with caching of the keys it finds, like
+// [lookupsource.WrapWithCache]. A nil or disabled cache disables caching,
+// and lookups with a context returned by [lookupsource.WithCacheBypass]
+// replace the entry of their key.
+func WrapWithCache[K comparable](cache *Cache[K], fn LookupFunc[K]) LookupFunc[K] {
+	if cache == nil || !cache.config.Enabled {
+		return fn
+	}
+	return func(ctx context.Context, key K) (any, bool, error) {
+		bypass := lookupsource.IsCacheBypassed(ctx)
+		if !bypass {
+			if val, found := cache.Get(key); found {
+				return val, true, nil
+			}
+		}
+		val, found, err := fn(ctx, key)
+		if err != nil {
+			return nil, false, err
+		}
+		if found {
+			cache.Set(key, val)
+		} else if bypass {
+			// A refreshed key that no longer exists mustn't keep its entry.
+			cache.Delete(key)
+		}
+		return val, found, nil
+	}
+}
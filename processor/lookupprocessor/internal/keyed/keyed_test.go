@@ -0,0 +1,162 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package keyed
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+var (
+	addr1 = netip.MustParseAddr("10.0.0.1")
+	addr2 = netip.MustParseAddr("10.0.0.2")
+	addr3 = netip.MustParseAddr("2001:db8::1")
+)
+
+func TestCache(t *testing.T) {
+	cache := NewCache[netip.Addr](lookupsource.CacheConfig{Enabled: true, Size: 2})
+
+	_, found := cache.Get(addr1)
+	assert.False(t, found)
+
+	cache.Set(addr1, "web-1")
+	cache.Set(addr2, "web-2")
+	val, found := cache.Get(addr1)
+	assert.True(t, found)
+	assert.Equal(t, "web-1", val)
+
+	// addr1 was read last, so addr2 is evicted.
+	cache.Set(addr3, "web-3")
+	_, found = cache.Get(addr2)
+	assert.False(t, found)
+	assert.Equal(t, lookupsource.CacheStats{Hits: 1, Misses: 2, Evictions: 1, Size: 2}, cache.Stats())
+
+	cache.Delete(addr1)
+	assert.Equal(t, 1, cache.Len())
+	cache.Clear()
+	assert.Equal(t, 0, cache.Len())
+
+	require.NoError(t, cache.Shutdown(t.Context()))
+	cache.Set(addr1, "web-1")
+	_, found = cache.Get(addr1)
+	assert.False(t, found)
+}
+
+func TestCacheTTL(t *testing.T) {
+	cache := NewCache[netip.Prefix](lookupsource.CacheConfig{Enabled: true, TTL: time.Minute, MaxTTL: time.Hour})
+	now := time.Unix(0, 0)
+	cache.now = func() time.Time { return now }
+
+	prefix := netip.MustParsePrefix("10.0.0.0/24")
+	cache.Set(prefix, "not found")
+	cache.SetWithTTL(netip.MustParsePrefix("10.0.1.0/24"), "not found", 0)
+
+	now = now.Add(time.Minute)
+	_, found := cache.Get(prefix)
+	assert.False(t, found)
+
+	// MaxTTL expires entries set without a TTL.
+	_, found = cache.Get(netip.MustParsePrefix("10.0.1.0/24"))
+	assert.True(t, found)
+	now = now.Add(time.Hour)
+	_, found = cache.Get(netip.MustParsePrefix("10.0.1.0/24"))
+	assert.False(t, found)
+}
+
+func TestWrapWithCache(t *testing.T) {
+	var calls int
+	errUnavailable := errors.New("unavailable")
+	lookup := WrapWithCache(NewCache[netip.Addr](lookupsource.CacheConfig{Enabled: true}), func(_ context.Context, addr netip.Addr) (any, bool, error) {
+		calls++
+		switch addr {
+		case addr1:
+			return "web-1", true, nil
+		case addr2:
+			return nil, false, errUnavailable
+		default:
+			return nil, false, nil
+		}
+	})
+
+	for range 2 {
+		val, found, err := lookup(t.Context(), addr1)
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "web-1", val)
+	}
+	assert.Equal(t, 1, calls)
+
+	// Errors and misses are not cached.
+	for range 2 {
+		_, _, err := lookup(t.Context(), addr2)
+		require.ErrorIs(t, err, errUnavailable)
+		_, found, err := lookup(t.Context(), addr3)
+		require.NoError(t, err)
+		assert.False(t, found)
+	}
+	assert.Equal(t, 5, calls)
+
+	_, _, err := lookup(lookupsource.WithCacheBypass(t.Context()), addr1)
+	require.NoError(t, err)
+	assert.Equal(t, 6, calls)
+}
+
+func TestStringLookup(t *testing.T) {
+	parse := func(key string) (netip.Addr, bool) {
+		addr, err := netip.ParseAddr(key)
+		return addr, err == nil
+	}
+	lookup := StringLookup(func(_ context.Context, addr netip.Addr) (any, bool, error) {
+		return addr.Is4(), true, nil
+	}, parse)
+
+	val, found, err := lookup(t.Context(), "10.0.0.1")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, true, val)
+
+	_, found, err = lookup(t.Context(), "web-1")
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+// BenchmarkCacheGet compares the cache gets of a source holding parsed
+// addresses, such as the DNS source, in a cache keyed by their string form
+// and in one keyed by the addresses.
+func BenchmarkCacheGet(b *testing.B) {
+	addrs := make([]netip.Addr, 256)
+	for i := range addrs {
+		addrs[i] = netip.AddrFrom4([4]byte{10, 0, 0, byte(i)})
+	}
+	cfg := lookupsource.CacheConfig{Enabled: true, Size: len(addrs)}
+
+	b.Run("string keys", func(b *testing.B) {
+		cache := lookupsource.NewCache(cfg)
+		for _, addr := range addrs {
+			cache.Set(addr.String(), "host")
+		}
+		b.ReportAllocs()
+		for i := 0; b.Loop(); i++ {
+			_, _ = cache.Get(addrs[i%len(addrs)].String())
+		}
+	})
+	b.Run("addr keys", func(b *testing.B) {
+		cache := NewCache[netip.Addr](cfg)
+		for _, addr := range addrs {
+			cache.Set(addr, "host")
+		}
+		b.ReportAllocs()
+		for i := 0; b.Loop(); i++ {
+			_, _ = cache.Get(addrs[i%len(addrs)])
+		}
+	})
+}
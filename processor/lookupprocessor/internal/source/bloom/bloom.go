@@ -0,0 +1,262 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package bloom provides a lookup source answering whether keys belong to a
+// large list, such as a denylist of IP addresses, from a Bloom filter.
+//
+// The filter answers membership rather than values, using a fraction of the
+// memory of the list, at the cost of false positives: a key that isn't
+// listed may be reported as a member, at the configured rate. Listed keys are
+// always reported as members.
+package bloom // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/bloom"
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+const sourceType = "bloom"
+
+type Config struct {
+	// Path is the file listing the member keys, one per line. Empty lines
+	// and lines starting with # are ignored.
+	Path string `mapstructure:"path"`
+
+	// FalsePositiveRate is the fraction of the keys that aren't listed
+	// reported as members. Lower rates use more memory.
+	// Default: 0.001
+	FalsePositiveRate float64 `mapstructure:"false_positive_rate"`
+
+	// RefreshInterval is how often the modification time of the file is
+	// checked; the list is loaded again when it changes. Zero disables
+	// reloading.
+	// Default: 1m
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"`
+}
+
+func (c *Config) Validate() error {
+	if c.Path == "" {
+		return errors.New("path must be specified")
+	}
+	if c.FalsePositiveRate <= 0 || c.FalsePositiveRate >= 1 {
+		return fmt.Errorf("false_positive_rate must be between 0 and 1, got %v", c.FalsePositiveRate)
+	}
+	if c.RefreshInterval < 0 {
+		return errors.New("refresh_interval must not be negative")
+	}
+	return nil
+}
+
+func NewFactory() lookupsource.SourceFactory {
+	return lookupsource.NewSourceFactory(
+		sourceType,
+		createDefaultConfig,
+		createSource,
+	)
+}
+
+func createDefaultConfig() lookupsource.SourceConfig {
+	return &Config{
+		FalsePositiveRate: 0.001,
+		RefreshInterval:   time.Minute,
+	}
+}
+
+func createSource(
+	_ context.Context,
+	settings lookupsource.CreateSettings,
+	cfg lookupsource.SourceConfig,
+) (lookupsource.Source, error) {
+	return newSource(cfg.(*Config), settings.TelemetrySettings.Logger), nil
+}
+
+func newSource(cfg *Config, logger *zap.Logger) lookupsource.Source {
+	s := &bloomSource{cfg: cfg, logger: logger}
+
+	// Filter lookups are cheaper than cache lookups, so no cache is needed.
+	return lookupsource.NewSource(
+		s.lookup,
+		func() string { return sourceType },
+		s.start,
+		s.shutdown,
+		lookupsource.WithChanges(&s.changes),
+	)
+}
+
+type bloomSource struct {
+	cfg    *Config
+	logger *zap.Logger
+
+	filter atomic.Pointer[filter]
+	// changes reports the replacements of filter.
+	changes lookupsource.Changes
+	// modTime is the modification time of the file when it was last loaded.
+	// It is only used by start and the refresh goroutine.
+	modTime time.Time
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// start loads the list and starts watching the file. The collector fails to
+// start if the list can't be loaded, rather than treating every key as
+// unlisted.
+func (s *bloomSource) start(_ context.Context, _ component.Host) error {
+	if err := s.load(); err != nil {
+		return err
+	}
+	if s.cfg.RefreshInterval > 0 {
+		var ctx context.Context
+		ctx, s.cancel = context.WithCancel(context.Background())
+		s.wg.Add(1)
+		go s.refresh(ctx)
+	}
+	return nil
+}
+
+func (s *bloomSource) shutdown(_ context.Context) error {
+	if s.cancel == nil {
+		return nil
+	}
+	s.cancel()
+	s.wg.Wait()
+	s.cancel = nil
+	return nil
+}
+
+// refresh loads the list again whenever the file changes, until ctx is done.
+// A failed load keeps the previous filter.
+func (s *bloomSource) refresh(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.cfg.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(s.cfg.Path)
+			if err == nil && info.ModTime().Equal(s.modTime) {
+				continue
+			}
+			if err == nil {
+				err = s.load()
+			}
+			if err != nil {
+				s.logger.Warn("Failed to reload Bloom filter list, keeping the previous one",
+					zap.String("path", s.cfg.Path), zap.Error(err))
+			}
+		}
+	}
+}
+
+// load reads the list and replaces the filter with one sized for it.
+func (s *bloomSource) load() error {
+	f, err := os.Open(s.cfg.Path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", s.cfg.Path, err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", s.cfg.Path, err)
+	}
+
+	var keys []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key := strings.TrimSpace(scanner.Text())
+		if key == "" || strings.HasPrefix(key, "#") {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", s.cfg.Path, err)
+	}
+
+	filter := newFilter(len(keys), s.cfg.FalsePositiveRate)
+	for _, key := range keys {
+		filter.add(key)
+	}
+	s.filter.Store(filter)
+	s.modTime = info.ModTime()
+	s.changes.Notify()
+	return nil
+}
+
+// lookup returns true for members. Other keys are not found, so that the
+// default_value of a lookup can mark them.
+func (s *bloomSource) lookup(_ context.Context, key string) (any, bool, error) {
+	filter := s.filter.Load()
+	if filter == nil || !filter.contains(key) {
+		return nil, false, nil
+	}
+	return true, true, nil
+}
+
+// filter is a Bloom filter: a key is added by setting the bits at hashes
+// positions of bits, and may be a member if all of them are set.
+type filter struct {
+	bits   []uint64
+	size   uint64
+	hashes uint64
+}
+
+// newFilter returns a filter holding n keys with the false positive rate p,
+// with the optimal number of bits, -n*ln(p)/ln(2)^2, and of hashes.
+func newFilter(n int, p float64) *filter {
+	n = max(n, 1)
+	size := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	hashes := uint64(max(1, math.Round(float64(size)/float64(n)*math.Ln2)))
+	return &filter{
+		bits:   make([]uint64, (size+63)/64),
+		size:   size,
+		hashes: hashes,
+	}
+}
+
+func (f *filter) add(key string) {
+	h1, h2 := hashKey(key)
+	for i := range f.hashes {
+		pos := (h1 + i*h2) % f.size
+		f.bits[pos/64] |= 1 << (pos % 64)
+	}
+}
+
+func (f *filter) contains(key string) bool {
+	h1, h2 := hashKey(key)
+	for i := range f.hashes {
+		pos := (h1 + i*h2) % f.size
+		if f.bits[pos/64]&(1<<(pos%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// hashKey returns the two hashes combined into the positions of key, with
+// h2 odd so that the positions don't repeat early.
+func hashKey(key string) (h1, h2 uint64) {
+	a := fnv.New64a()
+	_, _ = a.Write([]byte(key))
+	b := fnv.New64()
+	_, _ = b.Write([]byte(key))
+	return a.Sum64(), b.Sum64() | 1
+}
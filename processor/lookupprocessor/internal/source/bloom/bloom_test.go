@@ -0,0 +1,176 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package bloom
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+// writeList writes keys to a list file in a temporary directory and returns
+// its path.
+func writeList(t *testing.T, path string, keys ...string) string {
+	t.Helper()
+	if path == "" {
+		path = filepath.Join(t.TempDir(), "denylist.txt")
+	}
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(keys, "\n")), 0o600))
+	return path
+}
+
+func newTestConfig(path string) *Config {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Path = path
+	return cfg
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr string
+	}{
+		{
+			name: "valid",
+		},
+		{
+			name:    "missing path",
+			modify:  func(c *Config) { c.Path = "" },
+			wantErr: "path must be specified",
+		},
+		{
+			name:    "zero false positive rate",
+			modify:  func(c *Config) { c.FalsePositiveRate = 0 },
+			wantErr: "false_positive_rate must be between 0 and 1",
+		},
+		{
+			name:    "false positive rate of one",
+			modify:  func(c *Config) { c.FalsePositiveRate = 1 },
+			wantErr: "false_positive_rate must be between 0 and 1",
+		},
+		{
+			name:    "negative refresh interval",
+			modify:  func(c *Config) { c.RefreshInterval = -1 },
+			wantErr: "refresh_interval must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig("denylist.txt")
+			if tt.modify != nil {
+				tt.modify(cfg)
+			}
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	path := writeList(t, "", "# threat list", "10.0.0.1", "", "  192.168.1.7  ", "2001:db8::1")
+	source, err := NewFactory().CreateSource(t.Context(), lookupsource.CreateSettings{}, newTestConfig(path))
+	require.NoError(t, err)
+	require.NoError(t, source.Start(t.Context(), componenttest.NewNopHost()))
+	defer func() { require.NoError(t, source.Shutdown(t.Context())) }()
+
+	for _, key := range []string{"10.0.0.1", "192.168.1.7", "2001:db8::1"} {
+		val, found, err := source.Lookup(t.Context(), key)
+		require.NoError(t, err)
+		assert.True(t, found, key)
+		assert.Equal(t, true, val)
+	}
+	_, found, err := source.Lookup(t.Context(), "10.0.0.2")
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestFalsePositiveRate(t *testing.T) {
+	const members = 100_000
+	rng := rand.New(rand.NewPCG(1, 2))
+	keys := make([]string, members)
+	listed := make(map[string]bool, members)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%d.%d.%d.%d", rng.IntN(256), rng.IntN(256), rng.IntN(256), rng.IntN(256))
+		listed[keys[i]] = true
+	}
+
+	for _, rate := range []float64{0.01, 0.001} {
+		t.Run(fmt.Sprint(rate), func(t *testing.T) {
+			f := newFilter(len(keys), rate)
+			for _, key := range keys {
+				f.add(key)
+			}
+			for _, key := range keys {
+				require.True(t, f.contains(key), key)
+			}
+
+			var falsePositives, checked int
+			for checked < members {
+				key := fmt.Sprintf("%d.%d.%d.%d", rng.IntN(256), rng.IntN(256), rng.IntN(256), rng.IntN(256))
+				if listed[key] {
+					continue
+				}
+				checked++
+				if f.contains(key) {
+					falsePositives++
+				}
+			}
+			// Allow for the variance of the measured rate.
+			assert.LessOrEqual(t, float64(falsePositives)/float64(checked), 1.5*rate)
+		})
+	}
+}
+
+func TestStartError(t *testing.T) {
+	cfg := newTestConfig(filepath.Join(t.TempDir(), "missing.txt"))
+	source := newSource(cfg, zap.NewNop())
+	assert.ErrorContains(t, source.Start(t.Context(), componenttest.NewNopHost()), "failed to open")
+}
+
+func TestReload(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	path := writeList(t, "", "10.0.0.1")
+	cfg := newTestConfig(path)
+	cfg.RefreshInterval = 10 * time.Millisecond
+	source := newSource(cfg, zap.New(core))
+	require.NoError(t, source.Start(t.Context(), componenttest.NewNopHost()))
+	defer func() { require.NoError(t, source.Shutdown(t.Context())) }()
+
+	found := func(key string) bool {
+		_, found, err := source.Lookup(t.Context(), key)
+		require.NoError(t, err)
+		return found
+	}
+	assert.True(t, found("10.0.0.1"))
+
+	// The new list replaces the previous one when the file changes.
+	writeList(t, path, "10.0.0.2")
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Hour)))
+	require.Eventually(t, func() bool { return found("10.0.0.2") }, 5*time.Second, 5*time.Millisecond)
+	assert.False(t, found("10.0.0.1"))
+
+	// Failed reloads keep the previous list.
+	require.NoError(t, os.Remove(path))
+	require.Eventually(t, func() bool { return logs.Len() > 0 }, 5*time.Second, 5*time.Millisecond)
+	assert.True(t, found("10.0.0.2"))
+	assert.Equal(t, "Failed to reload Bloom filter list, keeping the previous one", logs.All()[0].Message)
+}
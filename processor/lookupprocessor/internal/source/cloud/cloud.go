@@ -0,0 +1,300 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package cloud provides a lookup source resolving the private IP addresses
+// of cloud instances to their instance ID and tags.
+//
+// The instances are listed from the API of the cloud provider on start and
+// then on every refresh interval, and lookups are served from the index
+// built from the last successful listing.
+package cloud // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/cloud"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configopaque"
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+const sourceType = "cloud"
+
+// Provider is the cloud provider whose instances are listed.
+type Provider string
+
+// ProviderAWS lists the EC2 instances of a region.
+const ProviderAWS Provider = "aws"
+
+type Config struct {
+	// Provider is the cloud provider whose instances are listed. Only aws
+	// is supported.
+	// Default: aws
+	Provider Provider `mapstructure:"provider"`
+
+	// Region is the region whose instances are listed. If empty, the region
+	// is taken from the environment or shared configuration.
+	Region string `mapstructure:"region"`
+
+	// Endpoint is the URL of the EC2 API to use instead of the default
+	// endpoint of Region, such as a VPC endpoint.
+	Endpoint string `mapstructure:"endpoint"`
+
+	// Profile is the shared configuration profile the credentials are read
+	// from. If Profile and AccessKeyID are empty, credentials come from the
+	// default AWS credential chain.
+	Profile string `mapstructure:"profile"`
+
+	// AccessKeyID and SecretAccessKey are static credentials used instead
+	// of the credential chain.
+	AccessKeyID     string              `mapstructure:"access_key_id"`
+	SecretAccessKey configopaque.String `mapstructure:"secret_access_key"`
+
+	// Tags are the instance tags included in the results. If empty, all
+	// tags are included.
+	Tags []string `mapstructure:"tags"`
+
+	// RefreshInterval is how often the instances are listed again. Zero
+	// disables refreshing.
+	// Default: 5m
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"`
+}
+
+func (c *Config) Validate() error {
+	if c.Provider != ProviderAWS {
+		return fmt.Errorf("unsupported provider %q, must be %q", c.Provider, ProviderAWS)
+	}
+	if (c.AccessKeyID == "") != (c.SecretAccessKey == "") {
+		return errors.New("access_key_id and secret_access_key must be specified together")
+	}
+	if c.Profile != "" && c.AccessKeyID != "" {
+		return errors.New("profile cannot be used with access_key_id")
+	}
+	for _, tag := range c.Tags {
+		if tag == "" {
+			return errors.New("tags must not be empty")
+		}
+	}
+	if c.RefreshInterval < 0 {
+		return errors.New("refresh_interval must not be negative")
+	}
+	return nil
+}
+
+func NewFactory() lookupsource.SourceFactory {
+	return lookupsource.NewSourceFactory(
+		sourceType,
+		createDefaultConfig,
+		createSource,
+	)
+}
+
+func createDefaultConfig() lookupsource.SourceConfig {
+	return &Config{
+		Provider:        ProviderAWS,
+		RefreshInterval: 5 * time.Minute,
+	}
+}
+
+func createSource(
+	_ context.Context,
+	settings lookupsource.CreateSettings,
+	cfg lookupsource.SourceConfig,
+) (lookupsource.Source, error) {
+	return newSource(cfg.(*Config), settings.TelemetrySettings.Logger, newClient), nil
+}
+
+func newSource(cfg *Config, logger *zap.Logger, newClient func(context.Context, *Config) (client, error)) lookupsource.Source {
+	s := &cloudSource{cfg: cfg, logger: logger, newClient: newClient}
+
+	// Instances are served from memory, so no cache is needed.
+	return lookupsource.NewSource(
+		s.lookup,
+		func() string { return sourceType },
+		s.start,
+		s.shutdown,
+		lookupsource.WithChanges(&s.changes),
+	)
+}
+
+// client is the subset of *ec2.Client used by the source.
+type client interface {
+	DescribeInstances(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error)
+}
+
+func newClient(ctx context.Context, cfg *Config) (client, error) {
+	var opts []func(*config.LoadOptions) error
+	if cfg.Region != "" {
+		opts = append(opts, config.WithRegion(cfg.Region))
+	}
+	if cfg.Profile != "" {
+		opts = append(opts, config.WithSharedConfigProfile(cfg.Profile))
+	}
+	if cfg.AccessKeyID != "" {
+		opts = append(opts, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(cfg.AccessKeyID, string(cfg.SecretAccessKey), "")))
+	}
+	awsCfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return ec2.NewFromConfig(awsCfg, func(o *ec2.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+	}), nil
+}
+
+type cloudSource struct {
+	cfg       *Config
+	logger    *zap.Logger
+	newClient func(context.Context, *Config) (client, error)
+	client    client
+
+	mu        sync.RWMutex
+	instances map[string]any
+	// changes reports the replacements of instances.
+	changes lookupsource.Changes
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// start lists the instances and starts refreshing them. The collector fails
+// to start if the first listing fails, rather than running without any
+// instances.
+func (s *cloudSource) start(ctx context.Context, _ component.Host) error {
+	c, err := s.newClient(ctx, s.cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create EC2 client: %w", err)
+	}
+	s.client = c
+	if err := s.load(ctx); err != nil {
+		return err
+	}
+
+	if s.cfg.RefreshInterval > 0 {
+		var refreshCtx context.Context
+		refreshCtx, s.cancel = context.WithCancel(context.Background())
+		s.wg.Add(1)
+		go s.refresh(refreshCtx)
+	}
+	return nil
+}
+
+func (s *cloudSource) shutdown(_ context.Context) error {
+	if s.cancel == nil {
+		return nil
+	}
+	s.cancel()
+	s.wg.Wait()
+	s.cancel = nil
+	return nil
+}
+
+// refresh lists the instances every refresh interval until ctx is done. A
+// failed listing keeps the index of the last successful one, since instances
+// rarely change between two listings.
+func (s *cloudSource) refresh(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.cfg.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.load(ctx); err != nil && ctx.Err() == nil {
+				s.logger.Warn("Failed to refresh cloud instances, keeping the previous index",
+					zap.String("provider", string(s.cfg.Provider)), zap.Error(err))
+			}
+		}
+	}
+}
+
+// load lists the instances, following every page, and replaces the index
+// with the result if all pages are listed.
+func (s *cloudSource) load(ctx context.Context) error {
+	input := &ec2.DescribeInstancesInput{
+		// Terminated instances keep their tags for a while, but their
+		// addresses may already be reused.
+		Filters: []types.Filter{{
+			Name:   aws.String("instance-state-name"),
+			Values: []string{"pending", "running", "stopping", "stopped"},
+		}},
+	}
+	instances := map[string]any{}
+	for {
+		out, err := s.client.DescribeInstances(ctx, input)
+		if err != nil {
+			return fmt.Errorf("failed to describe EC2 instances: %w", err)
+		}
+		for _, reservation := range out.Reservations {
+			for _, instance := range reservation.Instances {
+				s.index(instances, instance)
+			}
+		}
+		if aws.ToString(out.NextToken) == "" {
+			break
+		}
+		input.NextToken = out.NextToken
+	}
+
+	s.mu.Lock()
+	s.instances = instances
+	s.mu.Unlock()
+	s.changes.Notify()
+	return nil
+}
+
+// index adds the private IP addresses of instance, including the secondary
+// addresses of all its network interfaces, to instances.
+func (s *cloudSource) index(instances map[string]any, instance types.Instance) {
+	tags := make(map[string]any, len(instance.Tags))
+	for _, tag := range instance.Tags {
+		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	if len(s.cfg.Tags) > 0 {
+		exposed := make(map[string]any, len(s.cfg.Tags))
+		for _, key := range s.cfg.Tags {
+			if value, ok := tags[key]; ok {
+				exposed[key] = value
+			}
+		}
+		tags = exposed
+	}
+	value := map[string]any{
+		"instance_id": aws.ToString(instance.InstanceId),
+		"tags":        tags,
+	}
+
+	if ip := aws.ToString(instance.PrivateIpAddress); ip != "" {
+		instances[ip] = value
+	}
+	for _, iface := range instance.NetworkInterfaces {
+		for _, addr := range iface.PrivateIpAddresses {
+			if ip := aws.ToString(addr.PrivateIpAddress); ip != "" {
+				instances[ip] = value
+			}
+		}
+	}
+}
+
+func (s *cloudSource) lookup(_ context.Context, key string) (any, bool, error) {
+	s.mu.RLock()
+	val, found := s.instances[key]
+	s.mu.RUnlock()
+	return val, found, nil
+}
@@ -0,0 +1,245 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package cloud
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/sourcetest"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+// stubClient serves pages of reservations, one per DescribeInstances call,
+// or fails with err if set. The pages can be changed while a source
+// refreshes them.
+type stubClient struct {
+	mu       sync.Mutex
+	pages    [][]types.Reservation
+	err      error
+	requests []ec2.DescribeInstancesInput
+}
+
+func (c *stubClient) DescribeInstances(_ context.Context, params *ec2.DescribeInstancesInput, _ ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests = append(c.requests, *params)
+	if c.err != nil {
+		return nil, c.err
+	}
+	page := 0
+	if params.NextToken != nil {
+		page, _ = strconv.Atoi(*params.NextToken)
+	}
+	out := &ec2.DescribeInstancesOutput{}
+	if page < len(c.pages) {
+		out.Reservations = c.pages[page]
+	}
+	if page+1 < len(c.pages) {
+		out.NextToken = aws.String(strconv.Itoa(page + 1))
+	}
+	return out, nil
+}
+
+func (c *stubClient) set(pages [][]types.Reservation, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pages = pages
+	c.err = err
+}
+
+func instance(id, ip string, tags ...string) types.Instance {
+	i := types.Instance{InstanceId: aws.String(id), PrivateIpAddress: aws.String(ip)}
+	for j := 0; j+1 < len(tags); j += 2 {
+		i.Tags = append(i.Tags, types.Tag{Key: aws.String(tags[j]), Value: aws.String(tags[j+1])})
+	}
+	return i
+}
+
+func testPages() [][]types.Reservation {
+	web := instance("i-0a1", "10.0.0.1", "Name", "web-1", "team", "storefront")
+	web.NetworkInterfaces = []types.InstanceNetworkInterface{{
+		PrivateIpAddresses: []types.InstancePrivateIpAddress{
+			{PrivateIpAddress: aws.String("10.0.0.1")},
+			{PrivateIpAddress: aws.String("10.0.1.1")},
+		},
+	}}
+	return [][]types.Reservation{
+		{{Instances: []types.Instance{web}}},
+		{{Instances: []types.Instance{
+			instance("i-0b2", "10.0.0.2", "Name", "db-1", "team", "checkout"),
+			instance("i-0c3", "10.0.0.3"),
+		}}},
+	}
+}
+
+func newTestSource(cfg *Config, logger *zap.Logger, c *stubClient) lookupsource.Source {
+	return newSource(cfg, logger, func(context.Context, *Config) (client, error) { return c, nil })
+}
+
+func TestConfigValidate(t *testing.T) {
+	sourcetest.RunValidateTests(t, func() *Config { return createDefaultConfig().(*Config) }, []sourcetest.ValidateTest[*Config]{
+		{
+			Name: "valid",
+		},
+		{
+			Name: "static credentials",
+			Modify: func(c *Config) {
+				c.AccessKeyID = "AKIDEXAMPLE"
+				c.SecretAccessKey = "secret"
+			},
+		},
+		{
+			Name:    "unsupported provider",
+			Modify:  func(c *Config) { c.Provider = "gcp" },
+			WantErr: `unsupported provider "gcp", must be "aws"`,
+		},
+		{
+			Name:    "access key without secret",
+			Modify:  func(c *Config) { c.AccessKeyID = "AKIDEXAMPLE" },
+			WantErr: "access_key_id and secret_access_key must be specified together",
+		},
+		{
+			Name: "profile with access key",
+			Modify: func(c *Config) {
+				c.Profile = "inventory"
+				c.AccessKeyID = "AKIDEXAMPLE"
+				c.SecretAccessKey = "secret"
+			},
+			WantErr: "profile cannot be used with access_key_id",
+		},
+		{
+			Name:    "empty tag",
+			Modify:  func(c *Config) { c.Tags = []string{"Name", ""} },
+			WantErr: "tags must not be empty",
+		},
+		{
+			Name:    "negative refresh interval",
+			Modify:  func(c *Config) { c.RefreshInterval = -1 },
+			WantErr: "refresh_interval must not be negative",
+		},
+	})
+}
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name   string
+		tags   []string
+		key    string
+		want   any
+		absent bool
+	}{
+		{
+			name: "primary address",
+			key:  "10.0.0.1",
+			want: map[string]any{
+				"instance_id": "i-0a1",
+				"tags":        map[string]any{"Name": "web-1", "team": "storefront"},
+			},
+		},
+		{
+			name: "secondary address",
+			key:  "10.0.1.1",
+			want: map[string]any{
+				"instance_id": "i-0a1",
+				"tags":        map[string]any{"Name": "web-1", "team": "storefront"},
+			},
+		},
+		{
+			name: "later page",
+			key:  "10.0.0.2",
+			want: map[string]any{
+				"instance_id": "i-0b2",
+				"tags":        map[string]any{"Name": "db-1", "team": "checkout"},
+			},
+		},
+		{
+			name: "untagged instance",
+			key:  "10.0.0.3",
+			want: map[string]any{"instance_id": "i-0c3", "tags": map[string]any{}},
+		},
+		{
+			name: "selected tags",
+			tags: []string{"team", "owner"},
+			key:  "10.0.0.2",
+			want: map[string]any{"instance_id": "i-0b2", "tags": map[string]any{"team": "checkout"}},
+		},
+		{
+			name:   "unknown address",
+			key:    "10.0.9.9",
+			absent: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := createDefaultConfig().(*Config)
+			cfg.Tags = tt.tags
+			cfg.RefreshInterval = 0
+			c := &stubClient{pages: testPages()}
+			source := sourcetest.Start(t, newTestSource(cfg, zap.NewNop(), c))
+
+			val, found, err := source.Lookup(t.Context(), tt.key)
+			require.NoError(t, err)
+			assert.Equal(t, !tt.absent, found)
+			assert.Equal(t, tt.want, val)
+
+			// Only live instances are listed, and every page is followed.
+			require.Len(t, c.requests, 2)
+			assert.Equal(t, "instance-state-name", aws.ToString(c.requests[0].Filters[0].Name))
+			assert.NotContains(t, c.requests[0].Filters[0].Values, "terminated")
+			assert.Equal(t, "1", aws.ToString(c.requests[1].NextToken))
+		})
+	}
+}
+
+func TestStartError(t *testing.T) {
+	c := &stubClient{err: errors.New("UnauthorizedOperation")}
+	source := newTestSource(createDefaultConfig().(*Config), zap.NewNop(), c)
+	assert.ErrorContains(t, source.Start(t.Context(), componenttest.NewNopHost()),
+		"failed to describe EC2 instances: UnauthorizedOperation")
+}
+
+func TestRefresh(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	cfg := createDefaultConfig().(*Config)
+	cfg.Tags = []string{"Name"}
+	cfg.RefreshInterval = 10 * time.Millisecond
+	c := &stubClient{pages: testPages()}
+	source := sourcetest.Start(t, newTestSource(cfg, zap.New(core), c))
+
+	name := func(key string) any {
+		val, found, err := source.Lookup(t.Context(), key)
+		require.NoError(t, err)
+		if !found {
+			return nil
+		}
+		return val.(map[string]any)["tags"].(map[string]any)["Name"]
+	}
+	assert.Equal(t, "web-1", name("10.0.0.1"))
+
+	// The new listing replaces the previous index as a whole.
+	c.set([][]types.Reservation{{{Instances: []types.Instance{instance("i-0d4", "10.0.0.4", "Name", "web-4")}}}}, nil)
+	require.Eventually(t, func() bool { return name("10.0.0.4") == "web-4" }, 5*time.Second, 5*time.Millisecond)
+	assert.Nil(t, name("10.0.0.1"))
+
+	// Failed refreshes keep serving the last good index.
+	c.set(nil, errors.New("RequestLimitExceeded"))
+	require.Eventually(t, func() bool { return logs.Len() > 0 }, 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, "web-4", name("10.0.0.4"))
+	assert.Equal(t, "Failed to refresh cloud instances, keeping the previous index", logs.All()[0].Message)
+}
@@ -0,0 +1,1177 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package dns provides a lookup source resolving keys with DNS queries.
+//
+// The record type selects the query: PTR resolves IP addresses to host
+// names, A and AAAA resolve host names to addresses and CNAME resolves host
+// names to their canonical name.
+package dns // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/dns"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/netip"
+	"reflect"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/confighttp"
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/keyed"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+const sourceType = "dns"
+
+// RecordType is the type of DNS record queried for a key.
+type RecordType string
+
+const (
+	// RecordTypePTR resolves an IP address to the first host name of its
+	// reverse DNS entry.
+	RecordTypePTR RecordType = "PTR"
+	// RecordTypeA resolves a host name to its IPv4 addresses, selected with
+	// AnswerSelection.
+	RecordTypeA RecordType = "A"
+	// RecordTypeAAAA resolves a host name to its IPv6 addresses, selected
+	// with AnswerSelection.
+	RecordTypeAAAA RecordType = "AAAA"
+	// RecordTypeCNAME resolves a host name to the canonical name at the end
+	// of its CNAME chain. Names without a CNAME record are not found.
+	RecordTypeCNAME RecordType = "CNAME"
+	// RecordTypeSRV resolves a service name, such as
+	// _http._tcp.example.com, to the host and port of its preferred target:
+	// the one with the lowest priority and, among those, the highest
+	// weight. The result is a map with the host and port entries.
+	RecordTypeSRV RecordType = "SRV"
+)
+
+// AnswerSelection selects the value returned when an A or AAAA lookup
+// answers with several addresses.
+type AnswerSelection string
+
+const (
+	// AnswerSelectionFirst returns the first address of the answer.
+	AnswerSelectionFirst AnswerSelection = "first"
+	// AnswerSelectionAll returns the addresses of the answer, in its order,
+	// joined by commas.
+	AnswerSelectionAll AnswerSelection = "all"
+	// AnswerSelectionRandom returns an address of the answer picked at
+	// random on every lookup, including cache hits.
+	AnswerSelectionRandom AnswerSelection = "random"
+	// AnswerSelectionSorted returns the addresses of the answer as a list
+	// sorted in address order, so that answers listing the same addresses
+	// in a different order have the same value.
+	AnswerSelectionSorted AnswerSelection = "sorted"
+)
+
+// TimeoutPolicy selects how the timeout of a lookup combines with the
+// deadline of the context of the caller.
+type TimeoutPolicy string
+
+const (
+	// TimeoutPolicyInherit bounds lookups by the timeout or the deadline of
+	// the caller, whichever comes first, and stops them when the caller is
+	// canceled.
+	TimeoutPolicyInherit TimeoutPolicy = "inherit"
+	// TimeoutPolicyDetached bounds lookups by the timeout only, ignoring the
+	// deadline and cancellation of the caller, so that a lookup whose caller
+	// gave up still completes and populates the cache.
+	TimeoutPolicyDetached TimeoutPolicy = "detached"
+)
+
+type Config struct {
+	// RecordType is the type of record queried: PTR, A, AAAA, CNAME or
+	// SRV.
+	// Default: PTR
+	RecordType RecordType `mapstructure:"record_type"`
+
+	// AnswerSelection selects the value of A and AAAA answers holding
+	// several addresses: first, all, random or sorted.
+	// Default: first
+	AnswerSelection AnswerSelection `mapstructure:"answer_selection"`
+
+	// Server is the address ("host:port") of the DNS server queried. If
+	// empty, the resolver of the system is used.
+	Server string `mapstructure:"server"`
+
+	// BindAddress is the local IP address queries are sent from, on hosts
+	// where interfaces see different DNS views. It must be an address of
+	// the host.
+	BindAddress string `mapstructure:"bind_address"`
+
+	// Interface is the name of the network interface queries are sent
+	// from, using its first address of the family of Server, or its first
+	// IPv4 address. It can't be used with BindAddress.
+	Interface string `mapstructure:"interface"`
+
+	// Timeout bounds the duration of a single lookup.
+	// Default: 5s
+	Timeout time.Duration `mapstructure:"timeout"`
+
+	// TimeoutPolicy selects whether lookups also end with the context of
+	// the caller (inherit) or only after Timeout (detached), such as for
+	// lookups refreshing the cache in the background.
+	// Default: inherit
+	TimeoutPolicy TimeoutPolicy `mapstructure:"timeout_policy"`
+
+	// CIDROverrides map IP addresses to a value without querying DNS. When
+	// several prefixes contain a key, the longest one wins.
+	CIDROverrides []CIDROverride `mapstructure:"cidr_overrides"`
+
+	// NegativeAggregates cache the PTR misses of the addresses in their
+	// ranges for a whole enclosing prefix, such as the /24 of an address in
+	// an unrouted range, so that scans of the range query DNS once per
+	// prefix rather than once per address. Addresses whose result is
+	// already cached keep it. Requires record_type PTR and the cache.
+	NegativeAggregates []NegativeAggregate `mapstructure:"negative_aggregates"`
+
+	// ForwardConfirm only returns the host name of a PTR lookup if it
+	// resolves back to the address looked up (forward-confirmed reverse
+	// DNS), since anyone controlling the reverse zone of an address can
+	// make it point to any name. The first name of the answer that is
+	// confirmed is returned. Requires record_type PTR.
+	ForwardConfirm bool `mapstructure:"forward_confirm"`
+
+	// UnverifiedValue is returned for addresses whose host names aren't
+	// confirmed, such as "<unverified>". If empty, they are not found.
+	UnverifiedValue string `mapstructure:"unverified_value"`
+
+	// TrimTrailingDot removes the trailing dot of the host names returned by
+	// PTR and CNAME lookups and of SRV targets. Disable it for systems
+	// expecting fully qualified names, such as "web-1.example.com.".
+	// Default: true
+	TrimTrailingDot bool `mapstructure:"trim_trailing_dot"`
+
+	// LowercaseResult lowercases the host names returned by PTR and CNAME
+	// lookups and SRV targets, whose case depends on how the zone was
+	// written, so that every resolver answers with the same value.
+	// Default: false
+	LowercaseResult bool `mapstructure:"lowercase_result"`
+
+	// EmitLatencyAttribute returns results with the duration of the lookup,
+	// which the processor writes to the <target>.lookup_ms attribute.
+	// Results answered by the cache or an override report the near-zero
+	// duration of reading them.
+	EmitLatencyAttribute bool `mapstructure:"emit_latency_attribute"`
+
+	// EmitErrorAttribute reports why lookups failed or didn't find their
+	// key, which the processor writes to the <target>.lookup_error
+	// attribute: NXDOMAIN, NODATA, invalid_key or unverified for misses,
+	// and timeout, SERVFAIL, unreachable, rejected or error for failures.
+	EmitErrorAttribute bool `mapstructure:"emit_error_attribute"`
+
+	// RefreshAhead keeps frequently read keys resolved in the background,
+	// so that they don't wait for a query when their entry expires.
+	RefreshAhead RefreshAheadConfig `mapstructure:"refresh_ahead"`
+
+	// DoH sends PTR queries, and those of forward confirmation, as
+	// DNS-over-HTTPS requests to DoH.Endpoint instead of to Server. The
+	// source then also looks keys up in batches, querying the keys missing
+	// from the cache concurrently over one connection.
+	DoH DoHConfig `mapstructure:"doh"`
+
+	Cache lookupsource.CacheConfig `mapstructure:"cache"`
+}
+
+// RefreshAheadConfig configures the refresh of frequently read cache entries
+// before they expire.
+type RefreshAheadConfig struct {
+	// Enabled refreshes the entries read more than MinAccesses times once
+	// a read finds them within Window of their expiry. Rarely read entries
+	// expire as usual. Requires the cache.
+	Enabled bool `mapstructure:"enabled"`
+
+	// MinAccesses is the number of cache hits since an entry was set, or
+	// last refreshed, above which it is refreshed.
+	// Default: 10
+	MinAccesses int64 `mapstructure:"min_accesses"`
+
+	// Window is how long before its expiry an entry is refreshed. It must be
+	// shorter than the cache TTL.
+	// Default: 30s
+	Window time.Duration `mapstructure:"window"`
+
+	// RefreshSchedulerConfig bounds the rate and concurrency of the
+	// refreshes, such as when many hot entries expire at about the same
+	// time.
+	lookupsource.RefreshSchedulerConfig `mapstructure:",squash"`
+}
+
+// CIDROverride is the value of the IP addresses in a prefix.
+type CIDROverride struct {
+	// CIDR is the prefix, such as "10.0.0.0/8" or "2001:db8::/32".
+	CIDR string `mapstructure:"cidr"`
+	// Value is returned for the addresses in the prefix.
+	Value string `mapstructure:"value"`
+}
+
+// NegativeAggregate is a range whose PTR misses are cached per prefix.
+type NegativeAggregate struct {
+	// CIDR is the range, such as "10.0.0.0/8", known to have few or no
+	// reverse entries.
+	CIDR string `mapstructure:"cidr"`
+	// PrefixLength is the length of the prefixes cached as not found, such
+	// as 24 for the /24 around each address missed. It must not be shorter
+	// than the range.
+	// Default: the length of CIDR, caching the whole range
+	PrefixLength int `mapstructure:"prefix_length"`
+}
+
+func (c *Config) Validate() error {
+	var errs lookupsource.FieldErrors
+	switch c.RecordType {
+	case RecordTypePTR, RecordTypeA, RecordTypeAAAA, RecordTypeCNAME, RecordTypeSRV:
+	default:
+		errs.Addf("record_type", "unsupported value %q, must be one of PTR, A, AAAA, CNAME or SRV", c.RecordType)
+	}
+	switch c.AnswerSelection {
+	case AnswerSelectionFirst:
+	case AnswerSelectionAll, AnswerSelectionRandom, AnswerSelectionSorted:
+		if c.RecordType != RecordTypeA && c.RecordType != RecordTypeAAAA {
+			errs.Addf("answer_selection", "%q is only supported with the A and AAAA record types, not %s", c.AnswerSelection, c.RecordType)
+		}
+	default:
+		errs.Addf("answer_selection", "unsupported value %q, must be one of first, all, random or sorted", c.AnswerSelection)
+	}
+	if c.Server != "" {
+		if _, _, err := net.SplitHostPort(c.Server); err != nil {
+			errs.Addf("server", "invalid address %q, must be host:port: %w", c.Server, err)
+		}
+	}
+	if c.BindAddress != "" {
+		if c.Interface != "" {
+			errs.Addf("bind_address", "cannot be used with interface, set only one of them")
+		}
+		if ip := net.ParseIP(c.BindAddress); ip == nil {
+			errs.Addf("bind_address", "%q is not an IP address", c.BindAddress)
+		} else if !isLocalIP(ip) {
+			errs.Addf("bind_address", "%q is not an address of this host", c.BindAddress)
+		}
+	}
+	if c.Interface != "" {
+		if _, err := net.InterfaceByName(c.Interface); err != nil {
+			errs.Addf("interface", "invalid value %q: %w", c.Interface, err)
+		}
+	}
+	if c.Timeout < 0 {
+		errs.Addf("timeout", "must not be negative, got %s", c.Timeout)
+	}
+	switch c.TimeoutPolicy {
+	case TimeoutPolicyInherit:
+	case TimeoutPolicyDetached:
+		if c.Timeout == 0 {
+			errs.Addf("timeout_policy", "detached requires a timeout")
+		}
+	default:
+		errs.Addf("timeout_policy", "unsupported value %q, must be inherit or detached", c.TimeoutPolicy)
+	}
+	for i, o := range c.CIDROverrides {
+		if _, err := netip.ParsePrefix(o.CIDR); err != nil {
+			errs.Addf(fmt.Sprintf("cidr_overrides[%d].cidr", i), "invalid prefix %q: %w", o.CIDR, err)
+		}
+		if o.Value == "" {
+			errs.Addf(fmt.Sprintf("cidr_overrides[%d].value", i), "must be specified")
+		}
+	}
+	if len(c.NegativeAggregates) > 0 {
+		if c.RecordType != RecordTypePTR {
+			errs.Addf("negative_aggregates", "is only supported with the PTR record type, not %s", c.RecordType)
+		}
+		if !c.Cache.Enabled {
+			errs.Addf("negative_aggregates", "requires the cache to be enabled")
+		}
+	}
+	for i, a := range c.NegativeAggregates {
+		prefix, err := netip.ParsePrefix(a.CIDR)
+		if err != nil {
+			errs.Addf(fmt.Sprintf("negative_aggregates[%d].cidr", i), "invalid prefix %q: %w", a.CIDR, err)
+			continue
+		}
+		if a.PrefixLength != 0 && (a.PrefixLength < prefix.Bits() || a.PrefixLength > prefix.Addr().BitLen()) {
+			errs.Addf(fmt.Sprintf("negative_aggregates[%d].prefix_length", i), "%d must be between %d and %d", a.PrefixLength, prefix.Bits(), prefix.Addr().BitLen())
+		}
+	}
+	if c.ForwardConfirm && c.RecordType != RecordTypePTR {
+		errs.Addf("forward_confirm", "is only supported with the PTR record type, not %s", c.RecordType)
+	}
+	if c.UnverifiedValue != "" && !c.ForwardConfirm {
+		errs.Addf("unverified_value", "requires forward_confirm")
+	}
+	if c.RefreshAhead.Enabled {
+		if !c.Cache.Enabled {
+			errs.Addf("refresh_ahead", "requires the cache to be enabled")
+		}
+		if c.RefreshAhead.MinAccesses < 0 {
+			errs.Addf("refresh_ahead.min_accesses", "must not be negative, got %d", c.RefreshAhead.MinAccesses)
+		}
+		if c.RefreshAhead.Window <= 0 {
+			errs.Addf("refresh_ahead.window", "must be positive, got %s", c.RefreshAhead.Window)
+		} else if c.Cache.TTL > 0 && c.RefreshAhead.Window >= c.Cache.TTL {
+			errs.Addf("refresh_ahead.window", "must be shorter than the cache ttl %s, got %s", c.Cache.TTL, c.RefreshAhead.Window)
+		}
+		errs.Add("refresh_ahead", c.RefreshAhead.RefreshSchedulerConfig.Validate())
+	}
+	c.DoH.validate(c, &errs)
+	return errs.Err()
+}
+
+func NewFactory() lookupsource.SourceFactory {
+	return lookupsource.NewSourceFactory(
+		sourceType,
+		createDefaultConfig,
+		createSource,
+	)
+}
+
+func createDefaultConfig() lookupsource.SourceConfig {
+	return &Config{
+		RecordType:      RecordTypePTR,
+		AnswerSelection: AnswerSelectionFirst,
+		Timeout:         5 * time.Second,
+		TimeoutPolicy:   TimeoutPolicyInherit,
+		TrimTrailingDot: true,
+		RefreshAhead: RefreshAheadConfig{
+			MinAccesses: 10,
+			Window:      30 * time.Second,
+		},
+		DoH: DoHConfig{
+			ClientConfig:         confighttp.NewDefaultClientConfig(),
+			MaxConcurrentQueries: 50,
+		},
+		Cache: lookupsource.CacheConfig{
+			Enabled: true,
+			Size:    10000,
+			TTL:     5 * time.Minute,
+		},
+	}
+}
+
+func createSource(
+	_ context.Context,
+	settings lookupsource.CreateSettings,
+	cfg lookupsource.SourceConfig,
+) (lookupsource.Source, error) {
+	dnsCfg := cfg.(*Config)
+	localIP, err := dnsCfg.localIP()
+	if err != nil {
+		return nil, err
+	}
+	logger := settings.LookupLogger()
+	fields := []zap.Field{zap.String("record_type", string(dnsCfg.RecordType))}
+	if localIP != nil {
+		fields = append(fields, zap.Stringer("local_address", localIP))
+	}
+	var r resolver
+	switch {
+	case dnsCfg.DoH.enabled():
+		logger.Debug("Using DNS-over-HTTPS", append(fields, zap.String("endpoint", dnsCfg.DoH.Endpoint))...)
+		r = &dohResolver{
+			endpoint: dnsCfg.DoH.Endpoint,
+			newClient: func(ctx context.Context, host component.Host) (*http.Client, error) {
+				return dnsCfg.DoH.ToClient(ctx, host.GetExtensions(), settings.TelemetrySettings)
+			},
+		}
+	case dnsCfg.Server == "":
+		logger.Debug("Using the system resolver", fields...)
+		r = newResolver(dnsCfg.Server, localIP)
+	default:
+		logger.Debug("Using the configured DNS server", append(fields, zap.String("server", dnsCfg.Server))...)
+		r = newResolver(dnsCfg.Server, localIP)
+	}
+	return newSource(dnsCfg, r, logger), nil
+}
+
+func newSource(cfg *Config, r resolver, logger *zap.Logger) lookupsource.Source {
+	s := &dnsSource{cfg: cfg, logger: logger}
+	s.setUpstream(cfg, r)
+
+	// Host names are case-insensitive, so differently-cased names share an
+	// entry. PTR keys are IP addresses, looked up and cached in their
+	// canonical form, so that the spellings of an address share one.
+	cacheCfg := cfg.Cache
+	var opts []lookupsource.SourceOption
+	var cacheOpts []lookupsource.CacheWrapOption
+	if cfg.RecordType == RecordTypePTR {
+		keyFunc := lookupsource.WithKeyFunc(canonicalAddr)
+		opts = append(opts, keyFunc)
+		cacheOpts = append(cacheOpts, keyFunc)
+	} else {
+		cacheCfg.CaseInsensitive = true
+	}
+	cache := lookupsource.NewCache(cacheCfg)
+	shutdown := cache.Shutdown
+
+	// Aggregated misses are checked below the cache, so that the addresses
+	// of a prefix cached as found keep their result. They are keyed by the
+	// prefix itself, which every lookup in the ranges computes, rather than
+	// its string form.
+	lookup := s.lookup
+	if aggregates := newNegativeAggregates(cfg.NegativeAggregates); len(aggregates) > 0 {
+		negatives := keyed.NewCache[netip.Prefix](lookupsource.CacheConfig{
+			Enabled:         true,
+			Size:            cacheCfg.Size,
+			TTL:             cacheCfg.TTL,
+			EvictInSetOrder: cacheCfg.EvictInSetOrder,
+		})
+		shutdown = func(ctx context.Context) error {
+			return errors.Join(cache.Shutdown(ctx), negatives.Shutdown(ctx))
+		}
+		uncached := lookup
+		lookup = func(ctx context.Context, key string) (any, bool, error) {
+			prefix, ok := aggregates.prefix(key)
+			if !ok {
+				return uncached(ctx, key)
+			}
+			if reason, negative := negatives.Get(prefix); negative && !lookupsource.IsCacheBypassed(ctx) {
+				s.current().logger.Debug("DNS lookup not found", zap.String("key", key), zap.String("reason", "prefix cached as not found"), zap.Stringer("prefix", prefix))
+				return reason, false, nil
+			}
+			val, found, err := uncached(ctx, key)
+			if err == nil && !found {
+				// The reason of the miss, if reported, is kept for the
+				// other addresses of the prefix.
+				negatives.Set(prefix, val)
+			}
+			return val, found, err
+		}
+	}
+	uncached := lookup
+	lookup = lookupsource.WrapWithCache(cache, lookup, cacheOpts...)
+
+	var start lookupsource.StartFunc
+	if doh, ok := r.(*dohResolver); ok {
+		start = doh.start
+		cacheShutdown := shutdown
+		shutdown = func(ctx context.Context) error {
+			return errors.Join(doh.shutdown(ctx), cacheShutdown(ctx))
+		}
+	}
+	if cfg.RefreshAhead.Enabled {
+		refresher := &refreshAhead{cfg: cfg.RefreshAhead, cache: cache, refresh: lookup}
+		if cfg.RecordType == RecordTypePTR {
+			refresher.key = canonicalAddr
+		} else {
+			refresher.key = strings.ToLower
+		}
+		start = chainStart(start, refresher.start)
+		cacheShutdown := shutdown
+		shutdown = func(ctx context.Context) error {
+			return errors.Join(refresher.shutdown(ctx), cacheShutdown(ctx))
+		}
+		lookup = refresher.wrap(lookup)
+	}
+
+	// The cache keeps every address, so that each lookup picks again.
+	if cfg.AnswerSelection == AnswerSelectionRandom {
+		cachedLookup := lookup
+		lookup = func(ctx context.Context, key string) (any, bool, error) {
+			val, found, err := cachedLookup(ctx, key)
+			if addrs, ok := val.([]string); ok && len(addrs) > 0 {
+				val = addrs[rand.IntN(len(addrs))]
+			}
+			return val, found, err
+		}
+	}
+
+	// Overrides are answered before the cache so that the addresses of large
+	// ranges don't take up its entries.
+	if overrides := newCIDROverrides(cfg.CIDROverrides); len(overrides) > 0 {
+		cachedLookup := lookup
+		lookup = func(ctx context.Context, key string) (any, bool, error) {
+			if value, ok := overrides.match(key); ok {
+				s.current().logger.Debug("DNS lookup overridden", zap.String("key", key), zap.String("value", value))
+				return value, true, nil
+			}
+			return cachedLookup(ctx, key)
+		}
+	}
+
+	if cfg.EmitLatencyAttribute {
+		lookup = lookupsource.WithTiming(lookup)
+	}
+
+	// Batches only query the keys missing from the cache, concurrently.
+	// Overridden keys are answered without being cached, as by lookup.
+	if cfg.DoH.enabled() {
+		batch := lookupsource.WrapBatchWithCache(cache, s.lookupBatch(uncached), cacheOpts...)
+		if overrides := newCIDROverrides(cfg.CIDROverrides); len(overrides) > 0 {
+			batch = overrides.wrapBatch(batch)
+		}
+		opts = append(opts, lookupsource.WithBatchLookup(batch))
+	}
+
+	return lookupsource.NewSource(
+		lookup,
+		func() string { return sourceType },
+		start,
+		shutdown,
+		append(opts, lookupsource.WithCache(cache), lookupsource.WithReconfigure(s.reconfigure))...,
+	)
+}
+
+// chainStart returns a start function calling first, if set, then second.
+func chainStart(first, second lookupsource.StartFunc) lookupsource.StartFunc {
+	if first == nil {
+		return second
+	}
+	return func(ctx context.Context, host component.Host) error {
+		if err := first(ctx, host); err != nil {
+			return err
+		}
+		return second(ctx, host)
+	}
+}
+
+// refreshAhead schedules the refresh of the cache entries of hot keys when
+// they are read close to their expiry. The entries track their accesses
+// since they were set, so a refreshed entry must be read often again to be
+// refreshed once more.
+type refreshAhead struct {
+	cfg   RefreshAheadConfig
+	cache *lookupsource.Cache
+	// refresh looks keys up through the cache, replacing their entry when
+	// called with a bypass context.
+	refresh lookupsource.LookupFunc
+	// key returns the key of the cache entry of a lookup key, so that the
+	// spellings of a key share its refresh.
+	key func(string) string
+
+	// scheduler is set between start and shutdown.
+	scheduler atomic.Pointer[lookupsource.RefreshScheduler]
+}
+
+func (r *refreshAhead) start(context.Context, component.Host) error {
+	r.scheduler.Store(lookupsource.NewRefreshScheduler(r.cfg.RefreshSchedulerConfig, r.refresh))
+	return nil
+}
+
+func (r *refreshAhead) shutdown(ctx context.Context) error {
+	if scheduler := r.scheduler.Swap(nil); scheduler != nil {
+		return scheduler.Shutdown(ctx)
+	}
+	return nil
+}
+
+// wrap returns fn, the lookup through the cache, scheduling the refresh of
+// the keys it finds whose entry is due.
+func (r *refreshAhead) wrap(fn lookupsource.LookupFunc) lookupsource.LookupFunc {
+	return func(ctx context.Context, key string) (any, bool, error) {
+		val, found, err := fn(ctx, key)
+		if !found {
+			return val, found, err
+		}
+		scheduler := r.scheduler.Load()
+		if scheduler == nil {
+			return val, found, err
+		}
+		cacheKey := r.key(key)
+		info, ok := r.cache.Entry(cacheKey)
+		if ok && info.Accesses > r.cfg.MinAccesses && !info.ExpiresAt.IsZero() && time.Until(info.ExpiresAt) < r.cfg.Window {
+			scheduler.Schedule(cacheKey)
+		}
+		return val, found, err
+	}
+}
+
+// canonicalAddr returns IP address keys in their canonical form, without a
+// zone and with IPv4-mapped IPv6 addresses as IPv4, and other keys
+// unchanged.
+func canonicalAddr(key string) string {
+	addr, err := netip.ParseAddr(key)
+	if err != nil {
+		return key
+	}
+	return addr.WithZone("").Unmap().String()
+}
+
+// isScoped reports whether addr is only meaningful on one link or interface,
+// such as fe80::1%eth0. The same scoped address may belong to a different
+// host on every link, so it has no PTR record to look up.
+func isScoped(addr netip.Addr) bool {
+	return addr.Is6() && (addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast())
+}
+
+// cidrOverride is a parsed CIDROverride.
+type cidrOverride struct {
+	prefix netip.Prefix
+	value  string
+}
+
+// cidrOverrides are sorted from the longest prefix to the shortest, so the
+// first match is the longest.
+type cidrOverrides []cidrOverride
+
+func newCIDROverrides(cfg []CIDROverride) cidrOverrides {
+	overrides := make(cidrOverrides, 0, len(cfg))
+	for _, o := range cfg {
+		// Validate rejected invalid prefixes.
+		prefix, _ := netip.ParsePrefix(o.CIDR)
+		overrides = append(overrides, cidrOverride{prefix: prefix.Masked(), value: o.Value})
+	}
+	slices.SortStableFunc(overrides, func(a, b cidrOverride) int {
+		return b.prefix.Bits() - a.prefix.Bits()
+	})
+	return overrides
+}
+
+// match returns the value of the longest prefix containing key, or false if
+// key isn't an IP address or no prefix contains it.
+func (o cidrOverrides) match(key string) (string, bool) {
+	addr, err := netip.ParseAddr(key)
+	if err != nil {
+		return "", false
+	}
+	addr = addr.Unmap()
+	for _, override := range o {
+		if override.prefix.Contains(addr) {
+			return override.value, true
+		}
+	}
+	return "", false
+}
+
+// wrapBatch returns a batch lookup function answering the keys in a range of
+// o with its value, and the others with fn.
+func (o cidrOverrides) wrapBatch(fn lookupsource.BatchLookupFunc) lookupsource.BatchLookupFunc {
+	return func(ctx context.Context, keys []string) ([]lookupsource.Result, error) {
+		results := make([]lookupsource.Result, len(keys))
+		var rest []string
+		var restIdx []int
+		for i, key := range keys {
+			if value, ok := o.match(key); ok {
+				results[i] = lookupsource.Result{Value: value, Found: true}
+				continue
+			}
+			rest = append(rest, key)
+			restIdx = append(restIdx, i)
+		}
+		if len(rest) == 0 {
+			return results, nil
+		}
+		restResults, err := fn(ctx, rest)
+		if err != nil {
+			return nil, err
+		}
+		for j, res := range restResults {
+			results[restIdx[j]] = res
+		}
+		return results, nil
+	}
+}
+
+// negativeAggregate is a parsed NegativeAggregate.
+type negativeAggregate struct {
+	prefix netip.Prefix
+	bits   int
+}
+
+// negativeAggregates are sorted from the longest range to the shortest, so
+// the first match is the most specific.
+type negativeAggregates []negativeAggregate
+
+func newNegativeAggregates(cfg []NegativeAggregate) negativeAggregates {
+	aggregates := make(negativeAggregates, 0, len(cfg))
+	for _, a := range cfg {
+		// Validate rejected invalid prefixes and lengths.
+		prefix, _ := netip.ParsePrefix(a.CIDR)
+		bits := a.PrefixLength
+		if bits == 0 {
+			bits = prefix.Bits()
+		}
+		aggregates = append(aggregates, negativeAggregate{prefix: prefix.Masked(), bits: bits})
+	}
+	slices.SortStableFunc(aggregates, func(a, b negativeAggregate) int {
+		return b.prefix.Bits() - a.prefix.Bits()
+	})
+	return aggregates
+}
+
+// prefix returns the prefix whose misses are cached together with the one
+// of key, or false if key isn't an IP address in one of the ranges.
+func (a negativeAggregates) prefix(key string) (netip.Prefix, bool) {
+	addr, err := netip.ParseAddr(key)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+	addr = addr.WithZone("").Unmap()
+	for _, aggregate := range a {
+		if aggregate.prefix.Contains(addr) {
+			// bits is valid for the family of the range containing addr.
+			prefix, _ := addr.Prefix(aggregate.bits)
+			return prefix, true
+		}
+	}
+	return netip.Prefix{}, false
+}
+
+// resolver is the subset of *net.Resolver used by the source.
+type resolver interface {
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+	LookupIP(ctx context.Context, network, host string) ([]net.IP, error)
+	LookupCNAME(ctx context.Context, host string) (string, error)
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// newResolver returns a resolver querying server, or the servers of the
+// system if empty, from localIP if set.
+func newResolver(server string, localIP net.IP) *net.Resolver {
+	if server == "" && localIP == nil {
+		return net.DefaultResolver
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			if server != "" {
+				address = server
+			}
+			dialer := &net.Dialer{LocalAddr: localAddr(network, localIP)}
+			return dialer.DialContext(ctx, network, address)
+		},
+	}
+}
+
+// localAddr returns the local address of a connection over network from ip,
+// or nil to let the system choose it.
+func localAddr(network string, ip net.IP) net.Addr {
+	if ip == nil {
+		return nil
+	}
+	if strings.HasPrefix(network, "tcp") {
+		return &net.TCPAddr{IP: ip}
+	}
+	return &net.UDPAddr{IP: ip}
+}
+
+// localIP returns the address queries are sent from, or nil if the system
+// chooses it.
+func (c *Config) localIP() (net.IP, error) {
+	if c.BindAddress != "" {
+		return net.ParseIP(c.BindAddress), nil
+	}
+	if c.Interface == "" {
+		return nil, nil
+	}
+	iface, err := net.InterfaceByName(c.Interface)
+	if err != nil {
+		return nil, fmt.Errorf("invalid interface %q: %w", c.Interface, err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the addresses of interface %q: %w", c.Interface, err)
+	}
+	wantIPv4 := true
+	if host, _, err := net.SplitHostPort(c.Server); err == nil {
+		if ip := net.ParseIP(host); ip != nil {
+			wantIPv4 = ip.To4() != nil
+		}
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && (ipNet.IP.To4() != nil) == wantIPv4 {
+			return ipNet.IP, nil
+		}
+	}
+	family := "IPv4"
+	if !wantIPv4 {
+		family = "IPv6"
+	}
+	return nil, fmt.Errorf("interface %q has no %s address", c.Interface, family)
+}
+
+// isLocalIP reports whether ip is an address of an interface of the host.
+func isLocalIP(ip net.IP) bool {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+type dnsSource struct {
+	cfg *Config
+	// upstream is where queries are sent, swapped by reconfigure.
+	upstream atomic.Pointer[upstream]
+	// logger is the logger of the source, without the fields of upstream.
+	logger *zap.Logger
+}
+
+// upstream is the resolver a dnsSource queries, and the settings of cfg it
+// was created from.
+type upstream struct {
+	cfg      *Config
+	resolver resolver
+	// logger writes debug entries for each lookup, with the record type and
+	// the server answering it.
+	logger *zap.Logger
+}
+
+// current returns the upstream queries are sent to.
+func (s *dnsSource) current() *upstream {
+	return s.upstream.Load()
+}
+
+// setUpstream sends the next queries to r, created from the server,
+// bind_address, interface and doh settings of cfg.
+func (s *dnsSource) setUpstream(cfg *Config, r resolver) {
+	server := cfg.Server
+	switch {
+	case cfg.DoH.enabled():
+		server = cfg.DoH.Endpoint
+	case server == "":
+		server = "system"
+	}
+	s.upstream.Store(&upstream{
+		cfg:      cfg,
+		resolver: r,
+		logger:   s.logger.With(zap.String("record_type", string(cfg.RecordType)), zap.String("server", server)),
+	})
+}
+
+// reconfigure applies the server, bind_address and interface settings of
+// sourceCfg by swapping the resolver, so that the cache stays warm: its
+// entries are answers for the same names, and expire as usual. Other
+// changes, including those of doh whose client is created on start, require
+// recreating the source.
+func (s *dnsSource) reconfigure(_ context.Context, sourceCfg lookupsource.SourceConfig) error {
+	cfg, ok := sourceCfg.(*Config)
+	if !ok {
+		return fmt.Errorf("unexpected config type %T", sourceCfg)
+	}
+	current := s.current()
+	if reflect.DeepEqual(cfg, current.cfg) {
+		return nil
+	}
+	resolverless := *cfg
+	resolverless.Server = s.cfg.Server
+	resolverless.BindAddress = s.cfg.BindAddress
+	resolverless.Interface = s.cfg.Interface
+	if s.cfg.DoH.enabled() || !reflect.DeepEqual(&resolverless, s.cfg) {
+		return fmt.Errorf("%w: only server, bind_address and interface can change", lookupsource.ErrReconfigureUnsupported)
+	}
+	localIP, err := cfg.localIP()
+	if err != nil {
+		return err
+	}
+	s.setUpstream(cfg, newResolver(cfg.Server, localIP))
+	s.current().logger.Info("DNS source reconfigured")
+	return nil
+}
+
+func (s *dnsSource) lookup(ctx context.Context, key string) (any, bool, error) {
+	if s.cfg.TimeoutPolicy == TimeoutPolicyDetached {
+		// The values of ctx, such as the cache bypass, still apply.
+		ctx = context.WithoutCancel(ctx)
+	}
+	if s.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.cfg.Timeout)
+		defer cancel()
+	}
+
+	var (
+		value    string
+		addrs    []netip.Addr
+		srv      *net.SRV
+		notFound string
+		err      error
+	)
+	switch s.cfg.RecordType {
+	case RecordTypeA:
+		addrs, err = s.lookupIP(ctx, "ip4", key)
+		notFound = "no address records"
+	case RecordTypeAAAA:
+		addrs, err = s.lookupIP(ctx, "ip6", key)
+		notFound = "no address records"
+	case RecordTypeCNAME:
+		value, err = s.lookupCNAME(ctx, key)
+		notFound = "name has no CNAME record"
+	case RecordTypeSRV:
+		srv, err = s.lookupSRV(ctx, key)
+		notFound = "no SRV records"
+	default:
+		value, notFound, err = s.lookupPTR(ctx, key)
+	}
+
+	if isNotFound(err) {
+		s.current().logger.Debug("DNS lookup not found", zap.String("key", key), zap.String("reason", "name does not exist"), zap.Error(err))
+		return s.notFoundReason(reasonNXDOMAIN), false, nil
+	}
+	if err != nil {
+		classified := classifyError(err)
+		if s.cfg.EmitErrorAttribute {
+			classified = lookupsource.WithFailureReason(classified, failureReason(err))
+		}
+		err = fmt.Errorf("dns %s lookup failed: %w", s.cfg.RecordType, classified)
+		s.current().logger.Debug("DNS lookup failed", zap.String("key", key), zap.Error(err))
+		return nil, false, err
+	}
+	if value == "" && len(addrs) == 0 && srv == nil {
+		s.current().logger.Debug("DNS lookup not found", zap.String("key", key), zap.String("reason", notFound))
+		return s.notFoundReason(missReason(notFound)), false, nil
+	}
+	var result any = value
+	switch {
+	case len(addrs) > 0:
+		result = selectAnswer(s.cfg.AnswerSelection, addrs)
+	case srv != nil:
+		result = map[string]any{
+			"host": s.formatName(srv.Target),
+			"port": int64(srv.Port),
+		}
+	}
+	s.current().logger.Debug("DNS lookup resolved", zap.String("key", key), zap.Any("value", result))
+	return result, true, nil
+}
+
+// lookupPTR returns the first host name of the address key, or "" and why
+// it has none. Only IP addresses with a global meaning have reverse entries,
+// so nothing else is queried.
+func (s *dnsSource) lookupPTR(ctx context.Context, key string) (string, string, error) {
+	addr, err := netip.ParseAddr(key)
+	if err != nil {
+		return "", notFoundNotIP, nil
+	}
+	if isScoped(addr) {
+		return "", notFoundScoped, nil
+	}
+	names, err := s.current().resolver.LookupAddr(ctx, canonicalAddr(key))
+	if err != nil || len(names) == 0 {
+		return "", "no PTR records", err
+	}
+	if s.cfg.ForwardConfirm {
+		return s.forwardConfirm(ctx, key, addr.WithZone("").Unmap(), names)
+	}
+	return s.formatName(names[0]), "", nil
+}
+
+// formatName returns the host name of an answer in the form configured with
+// TrimTrailingDot and LowercaseResult.
+func (s *dnsSource) formatName(name string) string {
+	if s.cfg.TrimTrailingDot {
+		name = strings.TrimSuffix(name, ".")
+	}
+	if s.cfg.LowercaseResult {
+		name = strings.ToLower(name)
+	}
+	return name
+}
+
+// forwardConfirm returns the first of the PTR names of addr whose addresses
+// include addr, or the unverified value or "" and why if none does. Names
+// that don't exist aren't confirmed; other failures fail the lookup, since
+// a later query may confirm the name.
+func (s *dnsSource) forwardConfirm(ctx context.Context, key string, addr netip.Addr, names []string) (string, string, error) {
+	network := "ip6"
+	if addr.Is4() {
+		network = "ip4"
+	}
+	for _, name := range names {
+		host := strings.TrimSuffix(name, ".")
+		addrs, err := s.lookupIP(ctx, network, host)
+		if isNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return "", "", fmt.Errorf("forward confirmation of %q: %w", host, err)
+		}
+		if slices.Contains(addrs, addr) {
+			return s.formatName(name), "", nil
+		}
+	}
+	s.current().logger.Debug("DNS PTR names not forward-confirmed", zap.String("key", key), zap.Strings("names", names))
+	if s.cfg.UnverifiedValue != "" {
+		return s.cfg.UnverifiedValue, "", nil
+	}
+	return "", notFoundUnconfirmed, nil
+}
+
+// lookupIP returns the addresses of key on network, in the order of the
+// answer.
+func (s *dnsSource) lookupIP(ctx context.Context, network, key string) ([]netip.Addr, error) {
+	ips, err := s.current().resolver.LookupIP(ctx, network, key)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]netip.Addr, 0, len(ips))
+	for _, ip := range ips {
+		if addr, ok := netip.AddrFromSlice(ip); ok {
+			addrs = append(addrs, addr.Unmap())
+		}
+	}
+	return addrs, nil
+}
+
+// selectAnswer returns the value of the addresses of an answer according to
+// selection. The random selection returns them sorted, for the lookup
+// wrapping the cache to pick from.
+func selectAnswer(selection AnswerSelection, addrs []netip.Addr) any {
+	switch selection {
+	case AnswerSelectionAll:
+		return strings.Join(addrStrings(addrs), ",")
+	case AnswerSelectionRandom, AnswerSelectionSorted:
+		sorted := slices.Clone(addrs)
+		slices.SortFunc(sorted, netip.Addr.Compare)
+		return addrStrings(sorted)
+	default:
+		return addrs[0].String()
+	}
+}
+
+func addrStrings(addrs []netip.Addr) []string {
+	strs := make([]string, len(addrs))
+	for i, addr := range addrs {
+		strs[i] = addr.String()
+	}
+	return strs
+}
+
+// lookupCNAME returns the canonical name of key, or "" if key has no CNAME
+// record. The resolver answers with key itself when it resolves directly to
+// addresses; writing the key back as its own canonical name would hide that
+// no alias exists, so this is reported as not found.
+func (s *dnsSource) lookupCNAME(ctx context.Context, key string) (string, error) {
+	cname, err := s.current().resolver.LookupCNAME(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	if strings.EqualFold(strings.TrimSuffix(cname, "."), strings.TrimSuffix(key, ".")) {
+		return "", nil
+	}
+	return s.formatName(cname), nil
+}
+
+// lookupSRV returns the preferred target of the service name key, or nil if
+// it has none. Go's resolver orders the answer by priority with a weighted
+// shuffle, so the target is picked here again, deterministically, so that
+// cached and fresh lookups agree. A single "." target means the service is
+// deliberately unavailable.
+func (s *dnsSource) lookupSRV(ctx context.Context, key string) (*net.SRV, error) {
+	_, records, err := s.current().resolver.LookupSRV(ctx, "", "", key)
+	if err != nil {
+		return nil, err
+	}
+	var best *net.SRV
+	for _, r := range records {
+		if best == nil || r.Priority < best.Priority || (r.Priority == best.Priority && r.Weight > best.Weight) {
+			best = r
+		}
+	}
+	if best == nil || best.Target == "." {
+		return nil, nil
+	}
+	return best, nil
+}
+
+// The descriptions of PTR misses that aren't empty answers, logged and
+// mapped to the reasons reported by EmitErrorAttribute.
+const (
+	notFoundNotIP       = "key is not an IP address"
+	notFoundScoped      = "scoped address"
+	notFoundUnconfirmed = "PTR names not forward-confirmed"
+)
+
+// The reasons reported by EmitErrorAttribute. Misses are named after the
+// DNS answer they come from, where there is one.
+const (
+	reasonNXDOMAIN    = "NXDOMAIN"
+	reasonNODATA      = "NODATA"
+	reasonInvalidKey  = "invalid_key"
+	reasonUnverified  = "unverified"
+	reasonTimeout     = "timeout"
+	reasonSERVFAIL    = "SERVFAIL"
+	reasonUnreachable = "unreachable"
+	reasonRejected    = "rejected"
+	reasonError       = "error"
+)
+
+// notFoundReason returns the value of a miss for reason: reason itself if
+// EmitErrorAttribute is set, and nil otherwise.
+func (s *dnsSource) notFoundReason(reason string) any {
+	if !s.cfg.EmitErrorAttribute {
+		return nil
+	}
+	return lookupsource.NotFoundReason(reason)
+}
+
+// missReason returns the reason of the miss described by notFound: keys
+// that aren't queried and names that aren't confirmed have their own, and
+// the other misses are answers without records.
+func missReason(notFound string) string {
+	switch notFound {
+	case notFoundNotIP, notFoundScoped:
+		return reasonInvalidKey
+	case notFoundUnconfirmed:
+		return reasonUnverified
+	default:
+		return reasonNODATA
+	}
+}
+
+// failureReason returns the reason of a failed lookup, following the
+// classification of classifyError: SERVFAIL answers are reported as
+// temporary by Go's resolver, and answers such as REFUSED as neither
+// temporary nor timeouts.
+func failureReason(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return reasonTimeout
+	}
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) {
+		return reasonError
+	}
+	var opErr *net.OpError
+	switch {
+	case dnsErr.IsTimeout:
+		return reasonTimeout
+	case dnsErr.IsTemporary:
+		return reasonSERVFAIL
+	case errors.As(err, &opErr):
+		return reasonUnreachable
+	default:
+		return reasonRejected
+	}
+}
+
+// isNotFound reports whether err is an answer that the name doesn't exist,
+// rather than a failure: the resolver doesn't say it may exist on a later
+// query.
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound && !dnsErr.IsTemporary
+}
+
+// classifyError marks resolver errors as transient or permanent. Timeouts and
+// temporary failures such as SERVFAIL answers may not happen on a later query,
+// while servers rejecting the query, such as with REFUSED, will do so again.
+// Failures to reach the server are left unclassified.
+func classifyError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return lookupsource.Transient(err)
+	}
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) {
+		return err
+	}
+	if dnsErr.IsTimeout || dnsErr.IsTemporary {
+		return lookupsource.Transient(err)
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return err
+	}
+	return lookupsource.Permanent(err)
+}
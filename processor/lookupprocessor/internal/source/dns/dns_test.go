@@ -0,0 +1,1514 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+// stubResolver answers from maps keyed by the queried name or address.
+// Unknown keys fail with a not-found DNS error, like NXDOMAIN.
+type stubResolver struct {
+	addrs  map[string][]string
+	ips    map[string][]net.IP
+	cnames map[string]string
+	srvs   map[string][]*net.SRV
+	err    error
+	delay  time.Duration
+	calls  int
+}
+
+func (r *stubResolver) notFound(name string) error {
+	return &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (r *stubResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	r.calls++
+	time.Sleep(r.delay)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	names, ok := r.addrs[addr]
+	if !ok {
+		return nil, r.notFound(addr)
+	}
+	return names, nil
+}
+
+func (r *stubResolver) LookupIP(_ context.Context, network, host string) ([]net.IP, error) {
+	r.calls++
+	if r.err != nil {
+		return nil, r.err
+	}
+	var ips []net.IP
+	for _, ip := range r.ips[host] {
+		if (network == "ip4") == (ip.To4() != nil) {
+			ips = append(ips, ip)
+		}
+	}
+	if len(ips) == 0 {
+		return nil, r.notFound(host)
+	}
+	return ips, nil
+}
+
+func (r *stubResolver) LookupCNAME(_ context.Context, host string) (string, error) {
+	r.calls++
+	if r.err != nil {
+		return "", r.err
+	}
+	cname, ok := r.cnames[host]
+	if !ok {
+		return "", r.notFound(host)
+	}
+	return cname, nil
+}
+
+func (r *stubResolver) LookupSRV(_ context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	r.calls++
+	if r.err != nil {
+		return "", nil, r.err
+	}
+	if service != "" || proto != "" {
+		return "", nil, fmt.Errorf("unexpected service %q and proto %q", service, proto)
+	}
+	srvs, ok := r.srvs[name]
+	if !ok {
+		return "", nil, r.notFound(name)
+	}
+	return name, srvs, nil
+}
+
+func newStubResolver() *stubResolver {
+	return &stubResolver{
+		addrs: map[string][]string{
+			"10.0.0.1": {"web-1.example.com.", "web.example.com."},
+			"10.0.0.2": {},
+		},
+		ips: map[string][]net.IP{
+			"web.example.com": {net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.3"), net.ParseIP("2001:db8::1")},
+			// Round-robin answers list the addresses in a different order.
+			"pool.example.com": {net.ParseIP("10.0.0.10"), net.ParseIP("10.0.0.2"), net.ParseIP("10.0.0.1")},
+		},
+		cnames: map[string]string{
+			// Resolvers follow the whole chain and return its last name.
+			"www.example.com":  "cdn.example.net.",
+			"www.example.com.": "cdn.example.net.",
+			// Names that resolve directly are their own canonical name.
+			"web.example.com": "web.example.com.",
+		},
+		srvs: map[string][]*net.SRV{
+			// The preferred target is neither first nor the heaviest
+			// overall.
+			"_http._tcp.example.com": {
+				{Target: "backup.example.com.", Port: 8080, Priority: 20, Weight: 100},
+				{Target: "web-1.example.com.", Port: 80, Priority: 10, Weight: 10},
+				{Target: "web-2.example.com.", Port: 8000, Priority: 10, Weight: 60},
+			},
+			"_ldap._tcp.example.com": {},
+			"_smtp._tcp.example.com": {{Target: ".", Priority: 0, Weight: 0}},
+		},
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr string
+	}{
+		{
+			name: "default",
+		},
+		{
+			name:   "cname with server",
+			modify: func(c *Config) { c.RecordType = RecordTypeCNAME; c.Server = "10.0.0.53:53" },
+		},
+		{
+			name:   "srv",
+			modify: func(c *Config) { c.RecordType = RecordTypeSRV },
+		},
+		{
+			name:    "answer selection with SRV",
+			modify:  func(c *Config) { c.RecordType = RecordTypeSRV; c.AnswerSelection = AnswerSelectionSorted },
+			wantErr: `answer_selection: "sorted" is only supported with the A and AAAA record types, not SRV`,
+		},
+		{
+			name:   "detached timeout",
+			modify: func(c *Config) { c.TimeoutPolicy = TimeoutPolicyDetached },
+		},
+		{
+			name:    "detached without timeout",
+			modify:  func(c *Config) { c.TimeoutPolicy = TimeoutPolicyDetached; c.Timeout = 0 },
+			wantErr: "timeout_policy: detached requires a timeout",
+		},
+		{
+			name:    "unsupported timeout policy",
+			modify:  func(c *Config) { c.TimeoutPolicy = "shortest" },
+			wantErr: `timeout_policy: unsupported value "shortest", must be inherit or detached`,
+		},
+		{
+			name:    "unsupported record type",
+			modify:  func(c *Config) { c.RecordType = "MX" },
+			wantErr: `record_type: unsupported value "MX", must be one of PTR, A, AAAA, CNAME or SRV`,
+		},
+		{
+			name:   "sorted answers",
+			modify: func(c *Config) { c.RecordType = RecordTypeA; c.AnswerSelection = AnswerSelectionSorted },
+		},
+		{
+			name:    "unsupported answer selection",
+			modify:  func(c *Config) { c.RecordType = RecordTypeA; c.AnswerSelection = "last" },
+			wantErr: `answer_selection: unsupported value "last", must be one of first, all, random or sorted`,
+		},
+		{
+			name:    "answer selection with PTR",
+			modify:  func(c *Config) { c.AnswerSelection = AnswerSelectionAll },
+			wantErr: `answer_selection: "all" is only supported with the A and AAAA record types, not PTR`,
+		},
+		{
+			name:    "server without port",
+			modify:  func(c *Config) { c.Server = "10.0.0.53" },
+			wantErr: `server: invalid address "10.0.0.53", must be host:port`,
+		},
+		{
+			name:   "loopback bind address",
+			modify: func(c *Config) { c.BindAddress = "127.0.0.1" },
+		},
+		{
+			name:    "invalid bind address",
+			modify:  func(c *Config) { c.BindAddress = "localhost" },
+			wantErr: `bind_address: "localhost" is not an IP address`,
+		},
+		{
+			name:    "non-local bind address",
+			modify:  func(c *Config) { c.BindAddress = "192.0.2.1" },
+			wantErr: `bind_address: "192.0.2.1" is not an address of this host`,
+		},
+		{
+			name:    "unknown interface",
+			modify:  func(c *Config) { c.Interface = "no-such-interface" },
+			wantErr: `interface: invalid value "no-such-interface"`,
+		},
+		{
+			name: "bind address and interface",
+			modify: func(c *Config) {
+				c.BindAddress = "127.0.0.1"
+				c.Interface = "lo"
+			},
+			wantErr: "bind_address: cannot be used with interface",
+		},
+		{
+			name:    "negative timeout",
+			modify:  func(c *Config) { c.Timeout = -1 },
+			wantErr: "timeout: must not be negative, got -1ns",
+		},
+		{
+			name: "cidr overrides",
+			modify: func(c *Config) {
+				c.CIDROverrides = []CIDROverride{{CIDR: "10.0.0.0/8", Value: "internal"}, {CIDR: "2001:db8::/32", Value: "doc"}}
+			},
+		},
+		{
+			name:    "invalid cidr",
+			modify:  func(c *Config) { c.CIDROverrides = []CIDROverride{{CIDR: "10.0.0.0", Value: "internal"}} },
+			wantErr: `cidr_overrides[0].cidr: invalid prefix "10.0.0.0"`,
+		},
+		{
+			name:    "cidr without value",
+			modify:  func(c *Config) { c.CIDROverrides = []CIDROverride{{CIDR: "10.0.0.0/8"}} },
+			wantErr: "cidr_overrides[0].value: must be specified",
+		},
+		{
+			name: "negative aggregates",
+			modify: func(c *Config) {
+				c.NegativeAggregates = []NegativeAggregate{{CIDR: "10.0.0.0/8", PrefixLength: 24}, {CIDR: "192.0.2.0/24"}}
+			},
+		},
+		{
+			name: "negative aggregates with A records",
+			modify: func(c *Config) {
+				c.RecordType = RecordTypeA
+				c.NegativeAggregates = []NegativeAggregate{{CIDR: "10.0.0.0/8"}}
+			},
+			wantErr: "negative_aggregates: is only supported with the PTR record type, not A",
+		},
+		{
+			name: "negative aggregates without cache",
+			modify: func(c *Config) {
+				c.Cache.Enabled = false
+				c.NegativeAggregates = []NegativeAggregate{{CIDR: "10.0.0.0/8"}}
+			},
+			wantErr: "negative_aggregates: requires the cache to be enabled",
+		},
+		{
+			name: "forward confirm",
+			modify: func(c *Config) {
+				c.ForwardConfirm = true
+				c.UnverifiedValue = "<unverified>"
+			},
+		},
+		{
+			name: "forward confirm with A records",
+			modify: func(c *Config) {
+				c.RecordType = RecordTypeA
+				c.ForwardConfirm = true
+			},
+			wantErr: "forward_confirm: is only supported with the PTR record type, not A",
+		},
+		{
+			name:    "unverified value without forward confirm",
+			modify:  func(c *Config) { c.UnverifiedValue = "<unverified>" },
+			wantErr: "unverified_value: requires forward_confirm",
+		},
+		{
+			name:    "invalid negative aggregate cidr",
+			modify:  func(c *Config) { c.NegativeAggregates = []NegativeAggregate{{CIDR: "10.0.0.0"}} },
+			wantErr: `negative_aggregates[0].cidr: invalid prefix "10.0.0.0"`,
+		},
+		{
+			name:    "negative aggregate prefix shorter than range",
+			modify:  func(c *Config) { c.NegativeAggregates = []NegativeAggregate{{CIDR: "10.0.0.0/8", PrefixLength: 4}} },
+			wantErr: "negative_aggregates[0].prefix_length: 4 must be between 8 and 32",
+		},
+		{
+			name: "negative aggregate prefix too long",
+			modify: func(c *Config) {
+				c.NegativeAggregates = []NegativeAggregate{{CIDR: "2001:db8::/32", PrefixLength: 129}}
+			},
+			wantErr: "negative_aggregates[0].prefix_length: 129 must be between 32 and 128",
+		},
+		{
+			name:   "refresh ahead",
+			modify: func(c *Config) { c.RefreshAhead.Enabled = true },
+		},
+		{
+			name: "refresh ahead without cache",
+			modify: func(c *Config) {
+				c.RefreshAhead.Enabled = true
+				c.Cache.Enabled = false
+			},
+			wantErr: "refresh_ahead: requires the cache to be enabled",
+		},
+		{
+			name: "refresh ahead window not shorter than ttl",
+			modify: func(c *Config) {
+				c.RefreshAhead.Enabled = true
+				c.RefreshAhead.Window = c.Cache.TTL
+			},
+			wantErr: "refresh_ahead.window: must be shorter than the cache ttl 5m0s, got 5m0s",
+		},
+		{
+			name: "refresh ahead negative min accesses",
+			modify: func(c *Config) {
+				c.RefreshAhead.Enabled = true
+				c.RefreshAhead.MinAccesses = -1
+			},
+			wantErr: "refresh_ahead.min_accesses: must not be negative, got -1",
+		},
+		{
+			name: "refresh ahead negative budget",
+			modify: func(c *Config) {
+				c.RefreshAhead.Enabled = true
+				c.RefreshAhead.Budget = -1
+			},
+			wantErr: "refresh_ahead: budget must not be negative",
+		},
+		{
+			name:   "doh",
+			modify: func(c *Config) { c.DoH.Endpoint = "https://dns.example.com/dns-query" },
+		},
+		{
+			name:    "doh over http",
+			modify:  func(c *Config) { c.DoH.Endpoint = "http://dns.example.com/dns-query" },
+			wantErr: `doh.endpoint: must be an https:// URL, got "http://dns.example.com/dns-query"`,
+		},
+		{
+			name: "doh with A records",
+			modify: func(c *Config) {
+				c.DoH.Endpoint = "https://dns.example.com/dns-query"
+				c.RecordType = RecordTypeA
+			},
+			wantErr: "doh: is only supported with the PTR record type, not A",
+		},
+		{
+			name: "doh with server",
+			modify: func(c *Config) {
+				c.DoH.Endpoint = "https://dns.example.com/dns-query"
+				c.Server = "10.0.0.53:53"
+			},
+			wantErr: "doh: cannot be used with server, bind_address or interface",
+		},
+		{
+			name: "doh without concurrency",
+			modify: func(c *Config) {
+				c.DoH.Endpoint = "https://dns.example.com/dns-query"
+				c.DoH.MaxConcurrentQueries = 0
+			},
+			wantErr: "doh.max_concurrent_queries: must be positive, got 0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := createDefaultConfig().(*Config)
+			if tt.modify != nil {
+				tt.modify(cfg)
+			}
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestConfigValidateReportsAllErrors(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.RecordType = "MX"
+	cfg.Timeout = -time.Second
+	cfg.CIDROverrides = []CIDROverride{{CIDR: "10.0.0.0/8"}, {CIDR: "10.1.0.0", Value: "lab"}}
+
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Equal(t, `record_type: unsupported value "MX", must be one of PTR, A, AAAA, CNAME or SRV
+timeout: must not be negative, got -1s
+cidr_overrides[0].value: must be specified
+cidr_overrides[1].cidr: invalid prefix "10.1.0.0": netip.ParsePrefix("10.1.0.0"): no '/'`, err.Error())
+
+	var fieldErr *lookupsource.FieldError
+	require.ErrorAs(t, err, &fieldErr)
+	assert.Equal(t, "record_type", fieldErr.Field)
+}
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		recordType RecordType
+		key        string
+		want       any
+		wantFound  bool
+	}{
+		{recordType: RecordTypePTR, key: "10.0.0.1", want: "web-1.example.com", wantFound: true},
+		{recordType: RecordTypePTR, key: "10.0.0.2"},
+		{recordType: RecordTypePTR, key: "10.0.0.9"},
+		{recordType: RecordTypePTR, key: "not-an-ip"},
+		{recordType: RecordTypeA, key: "web.example.com", want: "10.0.0.1", wantFound: true},
+		{recordType: RecordTypeA, key: "missing.example.com"},
+		{recordType: RecordTypeAAAA, key: "web.example.com", want: "2001:db8::1", wantFound: true},
+		{recordType: RecordTypeCNAME, key: "www.example.com", want: "cdn.example.net", wantFound: true},
+		{recordType: RecordTypeCNAME, key: "www.example.com.", want: "cdn.example.net", wantFound: true},
+		{recordType: RecordTypeCNAME, key: "web.example.com"},
+		{recordType: RecordTypeCNAME, key: "missing.example.com"},
+		{
+			recordType: RecordTypeSRV, key: "_http._tcp.example.com",
+			want: map[string]any{"host": "web-2.example.com", "port": int64(8000)}, wantFound: true,
+		},
+		{recordType: RecordTypeSRV, key: "_ldap._tcp.example.com"},
+		{recordType: RecordTypeSRV, key: "_smtp._tcp.example.com"},
+		{recordType: RecordTypeSRV, key: "_missing._tcp.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.recordType)+"/"+tt.key, func(t *testing.T) {
+			r := newStubResolver()
+			cfg := createDefaultConfig().(*Config)
+			cfg.RecordType = tt.recordType
+
+			val, found, err := newSource(cfg, r, zap.NewNop()).Lookup(t.Context(), tt.key)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantFound, found)
+			assert.Equal(t, tt.want, val)
+		})
+	}
+}
+
+func TestLookupNameFormat(t *testing.T) {
+	tests := []struct {
+		trimTrailingDot bool
+		lowercaseResult bool
+		want            string
+	}{
+		{trimTrailingDot: true, want: "Example.COM"},
+		{trimTrailingDot: true, lowercaseResult: true, want: "example.com"},
+		{want: "Example.COM."},
+		{lowercaseResult: true, want: "example.com."},
+	}
+
+	r := &stubResolver{
+		addrs:  map[string][]string{"10.0.0.1": {"Example.COM."}},
+		cnames: map[string]string{"www.example.com": "Example.COM."},
+		srvs:   map[string][]*net.SRV{"_http._tcp.example.com": {{Target: "Example.COM.", Port: 80}}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("trim=%t,lowercase=%t", tt.trimTrailingDot, tt.lowercaseResult), func(t *testing.T) {
+			lookup := func(recordType RecordType, key string) any {
+				cfg := createDefaultConfig().(*Config)
+				cfg.RecordType = recordType
+				cfg.TrimTrailingDot = tt.trimTrailingDot
+				cfg.LowercaseResult = tt.lowercaseResult
+				val, found, err := newSource(cfg, r, zap.NewNop()).Lookup(t.Context(), key)
+				require.NoError(t, err)
+				require.True(t, found)
+				return val
+			}
+
+			assert.Equal(t, tt.want, lookup(RecordTypePTR, "10.0.0.1"))
+			assert.Equal(t, tt.want, lookup(RecordTypeCNAME, "www.example.com"))
+			assert.Equal(t, map[string]any{"host": tt.want, "port": int64(80)}, lookup(RecordTypeSRV, "_http._tcp.example.com"))
+		})
+	}
+}
+
+func TestLookupAnswerSelection(t *testing.T) {
+	tests := []struct {
+		selection AnswerSelection
+		want      any
+	}{
+		{selection: AnswerSelectionFirst, want: "10.0.0.10"},
+		{selection: AnswerSelectionAll, want: "10.0.0.10,10.0.0.2,10.0.0.1"},
+		{selection: AnswerSelectionSorted, want: []string{"10.0.0.1", "10.0.0.2", "10.0.0.10"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.selection), func(t *testing.T) {
+			cfg := createDefaultConfig().(*Config)
+			cfg.RecordType = RecordTypeA
+			cfg.AnswerSelection = tt.selection
+
+			val, found, err := newSource(cfg, newStubResolver(), zap.NewNop()).Lookup(t.Context(), "pool.example.com")
+			require.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, tt.want, val)
+		})
+	}
+
+	t.Run("sorted is stable", func(t *testing.T) {
+		r := newStubResolver()
+		cfg := createDefaultConfig().(*Config)
+		cfg.RecordType = RecordTypeA
+		cfg.AnswerSelection = AnswerSelectionSorted
+		cfg.Cache.Enabled = false
+		source := newSource(cfg, r, zap.NewNop())
+
+		first, _, err := source.Lookup(t.Context(), "pool.example.com")
+		require.NoError(t, err)
+		slices.Reverse(r.ips["pool.example.com"])
+		second, _, err := source.Lookup(t.Context(), "pool.example.com")
+		require.NoError(t, err)
+		assert.Equal(t, first, second)
+	})
+
+	t.Run("random", func(t *testing.T) {
+		r := newStubResolver()
+		cfg := createDefaultConfig().(*Config)
+		cfg.RecordType = RecordTypeA
+		cfg.AnswerSelection = AnswerSelectionRandom
+		source := newSource(cfg, r, zap.NewNop())
+
+		// Every lookup picks again from the cached answer, so all of its
+		// addresses come up over enough lookups.
+		seen := map[any]bool{}
+		for range 200 {
+			val, found, err := source.Lookup(t.Context(), "pool.example.com")
+			require.NoError(t, err)
+			assert.True(t, found)
+			seen[val] = true
+		}
+		assert.Equal(t, map[any]bool{"10.0.0.1": true, "10.0.0.2": true, "10.0.0.10": true}, seen)
+		assert.Equal(t, 1, r.calls)
+	})
+}
+
+func TestLookupSRVPriority(t *testing.T) {
+	tests := []struct {
+		name    string
+		records []*net.SRV
+		want    map[string]any
+	}{
+		{
+			name: "lowest priority wins over weight",
+			records: []*net.SRV{
+				{Target: "heavy.example.com.", Port: 1, Priority: 5, Weight: 1000},
+				{Target: "light.example.com.", Port: 2, Priority: 1, Weight: 0},
+			},
+			want: map[string]any{"host": "light.example.com", "port": int64(2)},
+		},
+		{
+			name: "highest weight within priority",
+			records: []*net.SRV{
+				{Target: "a.example.com.", Port: 1, Priority: 1, Weight: 10},
+				{Target: "b.example.com.", Port: 2, Priority: 1, Weight: 30},
+				{Target: "c.example.com.", Port: 3, Priority: 1, Weight: 20},
+			},
+			want: map[string]any{"host": "b.example.com", "port": int64(2)},
+		},
+		{
+			name: "first of equal targets",
+			records: []*net.SRV{
+				{Target: "a.example.com.", Port: 1, Priority: 1, Weight: 10},
+				{Target: "b.example.com.", Port: 2, Priority: 1, Weight: 10},
+			},
+			want: map[string]any{"host": "a.example.com", "port": int64(1)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newStubResolver()
+			r.srvs["_svc._tcp.example.com"] = tt.records
+			cfg := createDefaultConfig().(*Config)
+			cfg.RecordType = RecordTypeSRV
+
+			val, found, err := newSource(cfg, r, zap.NewNop()).Lookup(t.Context(), "_svc._tcp.example.com")
+			require.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, tt.want, val)
+		})
+	}
+}
+
+func TestLookupNotAnIPSkipsQuery(t *testing.T) {
+	r := newStubResolver()
+	_, found, err := newSource(createDefaultConfig().(*Config), r, zap.NewNop()).Lookup(t.Context(), "web.example.com")
+	require.NoError(t, err)
+	assert.False(t, found)
+	assert.Zero(t, r.calls)
+}
+
+func TestLookupScopedAddressSkipsQuery(t *testing.T) {
+	r := newStubResolver()
+	core, logs := observer.New(zap.DebugLevel)
+	source := newSource(createDefaultConfig().(*Config), r, zap.New(core))
+
+	for _, key := range []string{"fe80::1%eth0", "fe80::1", "FE80::1%25en0", "ff02::1%eth0"} {
+		_, found, err := source.Lookup(t.Context(), key)
+		require.NoError(t, err)
+		assert.False(t, found, key)
+	}
+	assert.Zero(t, r.calls)
+	assert.Equal(t, 4, logs.FilterField(zap.String("reason", "scoped address")).Len())
+}
+
+func TestLookupPTRCanonicalKey(t *testing.T) {
+	r := newStubResolver()
+	r.addrs["2001:db8::1"] = []string{"web-6.example.com."}
+	source := newSource(createDefaultConfig().(*Config), r, zap.NewNop())
+
+	// Spellings of an address, with or without a zone, share one query and
+	// cache entry.
+	for _, key := range []string{"2001:db8::1%eth0", "2001:DB8:0:0::0001", "2001:db8::1"} {
+		val, found, err := source.Lookup(t.Context(), key)
+		require.NoError(t, err)
+		assert.True(t, found, key)
+		assert.Equal(t, "web-6.example.com", val, key)
+	}
+	assert.Equal(t, 1, r.calls)
+
+	// IPv4-mapped addresses are looked up as IPv4.
+	val, found, err := source.Lookup(t.Context(), "::ffff:10.0.0.1")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "web-1.example.com", val)
+	assert.Equal(t, "10.0.0.1", lookupsource.KeyFunc(source)("::ffff:10.0.0.1"))
+}
+
+func TestLookupError(t *testing.T) {
+	r := newStubResolver()
+	r.err = &net.DNSError{Err: "server misbehaving", Name: "www.example.com", IsTemporary: true}
+	cfg := createDefaultConfig().(*Config)
+	cfg.RecordType = RecordTypeCNAME
+
+	_, found, err := newSource(cfg, r, zap.NewNop()).Lookup(t.Context(), "www.example.com")
+	assert.False(t, found)
+	assert.ErrorContains(t, err, "dns CNAME lookup failed")
+	var dnsErr *net.DNSError
+	assert.True(t, errors.As(err, &dnsErr))
+}
+
+func TestLookupErrorClassification(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		wantNotFound  bool
+		wantTransient bool
+		wantPermanent bool
+	}{
+		{
+			name:         "NXDOMAIN",
+			err:          &net.DNSError{Err: "no such host", Name: "www.example.com", IsNotFound: true},
+			wantNotFound: true,
+		},
+		{
+			name:          "temporary NXDOMAIN",
+			err:           &net.DNSError{Err: "no such host", Name: "www.example.com", IsNotFound: true, IsTemporary: true},
+			wantTransient: true,
+		},
+		{
+			name:          "SERVFAIL",
+			err:           &net.DNSError{Err: "server misbehaving", Name: "www.example.com", IsTemporary: true},
+			wantTransient: true,
+		},
+		{
+			name:          "REFUSED",
+			err:           &net.DNSError{Err: "server misbehaving", Name: "www.example.com"},
+			wantPermanent: true,
+		},
+		{
+			name:          "timeout",
+			err:           &net.DNSError{Err: "i/o timeout", Name: "www.example.com", IsTimeout: true},
+			wantTransient: true,
+		},
+		{
+			name:          "deadline exceeded",
+			err:           context.DeadlineExceeded,
+			wantTransient: true,
+		},
+		{
+			name: "server unreachable",
+			err: &net.DNSError{
+				Err:       "dial udp 10.0.0.53:53: connect: connection refused",
+				Name:      "www.example.com",
+				UnwrapErr: &net.OpError{Op: "dial", Net: "udp", Err: errors.New("connection refused")},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newStubResolver()
+			r.err = tt.err
+			cfg := createDefaultConfig().(*Config)
+			cfg.RecordType = RecordTypeCNAME
+
+			_, found, err := newSource(cfg, r, zap.NewNop()).Lookup(t.Context(), "www.example.com")
+			assert.False(t, found)
+			if tt.wantNotFound {
+				assert.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.wantTransient, lookupsource.IsTransient(err))
+			assert.Equal(t, tt.wantPermanent, lookupsource.IsPermanent(err))
+		})
+	}
+}
+
+func TestLookupTimeoutPolicy(t *testing.T) {
+	canceled, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	t.Run("inherit", func(t *testing.T) {
+		r := newStubResolver()
+		source := newSource(createDefaultConfig().(*Config), r, zap.NewNop())
+
+		_, found, err := source.Lookup(canceled, "10.0.0.1")
+		require.ErrorIs(t, err, context.Canceled)
+		assert.False(t, found)
+
+		// A deadline shorter than the timeout wins.
+		ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+		defer cancel()
+		r.delay = 50 * time.Millisecond
+		_, _, err = source.Lookup(ctx, "10.0.0.1")
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("detached", func(t *testing.T) {
+		r := newStubResolver()
+		cfg := createDefaultConfig().(*Config)
+		cfg.TimeoutPolicy = TimeoutPolicyDetached
+		source := newSource(cfg, r, zap.NewNop())
+
+		val, found, err := source.Lookup(canceled, "10.0.0.1")
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "web-1.example.com", val)
+
+		// The result is cached for callers that didn't give up.
+		_, _, err = source.Lookup(t.Context(), "10.0.0.1")
+		require.NoError(t, err)
+		assert.Equal(t, 1, r.calls)
+
+		// The source timeout still bounds the lookup.
+		cfg.Timeout = 10 * time.Millisecond
+		r.delay = 50 * time.Millisecond
+		_, _, err = newSource(cfg, r, zap.NewNop()).Lookup(canceled, "10.0.0.1")
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.True(t, lookupsource.IsTransient(err))
+	})
+}
+
+func TestLookupCIDROverrides(t *testing.T) {
+	r := newStubResolver()
+	cfg := createDefaultConfig().(*Config)
+	cfg.CIDROverrides = []CIDROverride{
+		{CIDR: "10.0.0.0/8", Value: "internal"},
+		{CIDR: "10.1.2.0/24", Value: "build-farm"},
+		{CIDR: "10.1.0.0/16", Value: "datacenter"},
+		{CIDR: "2001:db8::/32", Value: "documentation"},
+	}
+	source := newSource(cfg, r, zap.NewNop())
+
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{key: "10.9.9.9", want: "internal"},
+		{key: "10.1.9.9", want: "datacenter"},
+		{key: "10.1.2.3", want: "build-farm"},
+		{key: "::ffff:10.1.2.3", want: "build-farm"},
+		{key: "2001:db8::1", want: "documentation"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			val, found, err := source.Lookup(t.Context(), tt.key)
+			require.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, tt.want, val)
+		})
+	}
+	assert.Zero(t, r.calls)
+
+	// Addresses outside the prefixes are resolved.
+	r.addrs["192.168.0.1"] = []string{"printer.example.com."}
+	val, found, err := source.Lookup(t.Context(), "192.168.0.1")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "printer.example.com", val)
+	assert.Equal(t, 1, r.calls)
+
+	stats, _ := source.(lookupsource.CacheStatsReporter).CacheStats()
+	assert.Equal(t, 1, stats.Size)
+}
+
+func TestLookupNegativeAggregates(t *testing.T) {
+	r := newStubResolver()
+	cfg := createDefaultConfig().(*Config)
+	cfg.NegativeAggregates = []NegativeAggregate{
+		{CIDR: "100.64.0.0/10", PrefixLength: 24},
+		{CIDR: "2001:db8::/32", PrefixLength: 64},
+	}
+	source := newSource(cfg, r, zap.NewNop())
+
+	lookup := func(ctx context.Context, key string) (any, bool) {
+		t.Helper()
+		val, found, err := source.Lookup(ctx, key)
+		require.NoError(t, err)
+		return val, found
+	}
+
+	// An address with a reverse entry, cached before its /24 misses.
+	r.addrs["100.64.1.7"] = []string{"gw.example.com."}
+	val, found := lookup(t.Context(), "100.64.1.7")
+	require.True(t, found)
+	assert.Equal(t, "gw.example.com", val)
+
+	// One miss caches the whole /24.
+	_, found = lookup(t.Context(), "100.64.1.1")
+	require.False(t, found)
+	assert.Equal(t, 2, r.calls)
+	for i := 2; i < 255; i++ {
+		if i != 7 {
+			_, found = lookup(t.Context(), fmt.Sprintf("100.64.1.%d", i))
+			assert.False(t, found)
+		}
+	}
+	_, found = lookup(t.Context(), "::ffff:100.64.1.200")
+	assert.False(t, found)
+	assert.Equal(t, 2, r.calls, "addresses of a prefix cached as not found were queried")
+
+	// The address found before keeps its result.
+	val, found = lookup(t.Context(), "100.64.1.7")
+	assert.True(t, found)
+	assert.Equal(t, "gw.example.com", val)
+
+	// Other /24s of the range, other ranges and other families are queried.
+	lookup(t.Context(), "100.64.2.1")
+	lookup(t.Context(), "100.64.2.2")
+	assert.Equal(t, 3, r.calls)
+	lookup(t.Context(), "192.0.2.1")
+	lookup(t.Context(), "192.0.2.2")
+	assert.Equal(t, 5, r.calls)
+	lookup(t.Context(), "2001:db8:0:1::1")
+	lookup(t.Context(), "2001:db8:0:1::2")
+	lookup(t.Context(), "2001:db8:0:2::1")
+	assert.Equal(t, 7, r.calls)
+
+	// Bypassing the cache queries again, and an address found then
+	// overrides its prefix.
+	r.addrs["100.64.1.9"] = []string{"new.example.com."}
+	val, found = lookup(lookupsource.WithCacheBypass(t.Context()), "100.64.1.9")
+	assert.True(t, found)
+	assert.Equal(t, "new.example.com", val)
+	val, found = lookup(t.Context(), "100.64.1.9")
+	assert.True(t, found)
+	assert.Equal(t, "new.example.com", val)
+	_, found = lookup(t.Context(), "100.64.1.10")
+	assert.False(t, found)
+	assert.Equal(t, 8, r.calls)
+
+	require.NoError(t, source.Shutdown(t.Context()))
+}
+
+func TestLookupForwardConfirm(t *testing.T) {
+	newResolver := func() *stubResolver {
+		r := newStubResolver()
+		r.addrs = map[string][]string{
+			// web-1 resolves back to its address.
+			"10.0.0.1": {"web-1.example.com."},
+			// The reverse zone of the address claims a name of someone
+			// else.
+			"10.0.0.66": {"bank.example.com."},
+			// Only the second name is confirmed.
+			"10.0.0.5": {"stale.example.com.", "db.example.com."},
+			// The name doesn't exist.
+			"10.0.0.7":    {"gone.example.com."},
+			"2001:db8::1": {"v6.example.com."},
+		}
+		r.ips = map[string][]net.IP{
+			"web-1.example.com": {net.ParseIP("10.0.0.9"), net.ParseIP("10.0.0.1")},
+			"bank.example.com":  {net.ParseIP("192.0.2.10")},
+			"stale.example.com": {net.ParseIP("10.0.0.50")},
+			"db.example.com":    {net.ParseIP("10.0.0.5")},
+			"v6.example.com":    {net.ParseIP("10.0.0.1"), net.ParseIP("2001:db8::1")},
+		}
+		return r
+	}
+
+	tests := []struct {
+		name       string
+		unverified string
+		key        string
+		want       any
+		wantFound  bool
+	}{
+		{name: "confirmed", key: "10.0.0.1", want: "web-1.example.com", wantFound: true},
+		{name: "mapped address confirmed", key: "::ffff:10.0.0.1", want: "web-1.example.com", wantFound: true},
+		{name: "second name confirmed", key: "10.0.0.5", want: "db.example.com", wantFound: true},
+		{name: "ipv6 confirmed", key: "2001:db8::1", want: "v6.example.com", wantFound: true},
+		{name: "not confirmed", key: "10.0.0.66"},
+		{name: "name does not exist", key: "10.0.0.7"},
+		{name: "not confirmed with marker", unverified: "<unverified>", key: "10.0.0.66", want: "<unverified>", wantFound: true},
+		{name: "no PTR record with marker", unverified: "<unverified>", key: "10.0.0.8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newResolver()
+			cfg := createDefaultConfig().(*Config)
+			cfg.ForwardConfirm = true
+			cfg.UnverifiedValue = tt.unverified
+			source := newSource(cfg, r, zap.NewNop())
+
+			for range 2 {
+				val, found, err := source.Lookup(t.Context(), tt.key)
+				require.NoError(t, err)
+				assert.Equal(t, tt.wantFound, found)
+				if tt.wantFound {
+					assert.Equal(t, tt.want, val)
+				}
+			}
+			if tt.wantFound {
+				// The second lookup is answered by the cache.
+				stats, _ := source.(lookupsource.CacheStatsReporter).CacheStats()
+				assert.Equal(t, int64(1), stats.Hits)
+			}
+		})
+	}
+
+	t.Run("trailing dot kept", func(t *testing.T) {
+		cfg := createDefaultConfig().(*Config)
+		cfg.ForwardConfirm = true
+		cfg.TrimTrailingDot = false
+		val, found, err := newSource(cfg, newResolver(), zap.NewNop()).Lookup(t.Context(), "10.0.0.5")
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "db.example.com.", val)
+	})
+
+	t.Run("forward lookup error", func(t *testing.T) {
+		r := newResolver()
+		cfg := createDefaultConfig().(*Config)
+		cfg.ForwardConfirm = true
+		source := newSource(cfg, &failingIPResolver{r}, zap.NewNop())
+
+		_, found, err := source.Lookup(t.Context(), "10.0.0.1")
+		require.ErrorContains(t, err, `forward confirmation of "web-1.example.com"`)
+		assert.False(t, found)
+		assert.True(t, lookupsource.IsTransient(err))
+	})
+}
+
+// failingIPResolver answers PTR queries and fails address queries with a
+// temporary error.
+type failingIPResolver struct {
+	*stubResolver
+}
+
+func (*failingIPResolver) LookupIP(_ context.Context, _, host string) ([]net.IP, error) {
+	return nil, &net.DNSError{Err: "server misbehaving", Name: host, IsTemporary: true}
+}
+
+func TestLookupErrorAttribute(t *testing.T) {
+	tests := []struct {
+		name       string
+		recordType RecordType
+		key        string
+		err        error
+		confirm    bool
+		want       string
+	}{
+		{name: "NXDOMAIN", recordType: RecordTypeA, key: "missing.example.com", want: "NXDOMAIN"},
+		{name: "NODATA", recordType: RecordTypePTR, key: "10.0.0.2", want: "NODATA"},
+		{name: "invalid key", recordType: RecordTypePTR, key: "not-an-ip", want: "invalid_key"},
+		{name: "scoped key", recordType: RecordTypePTR, key: "fe80::1%eth0", want: "invalid_key"},
+		{name: "unverified", recordType: RecordTypePTR, key: "10.0.0.66", confirm: true, want: "unverified"},
+		{
+			name:       "SERVFAIL",
+			recordType: RecordTypeA,
+			key:        "www.example.com",
+			err:        &net.DNSError{Err: "server misbehaving", Name: "www.example.com", IsTemporary: true},
+			want:       "SERVFAIL",
+		},
+		{
+			name:       "temporary not found",
+			recordType: RecordTypeA,
+			key:        "www.example.com",
+			err:        &net.DNSError{Err: "no such host", Name: "www.example.com", IsNotFound: true, IsTemporary: true},
+			want:       "SERVFAIL",
+		},
+		{
+			name:       "timeout",
+			recordType: RecordTypeA,
+			key:        "www.example.com",
+			err:        &net.DNSError{Err: "i/o timeout", Name: "www.example.com", IsTimeout: true},
+			want:       "timeout",
+		},
+		{
+			name:       "deadline exceeded",
+			recordType: RecordTypeA,
+			key:        "www.example.com",
+			err:        context.DeadlineExceeded,
+			want:       "timeout",
+		},
+		{
+			name:       "rejected",
+			recordType: RecordTypeA,
+			key:        "www.example.com",
+			err:        &net.DNSError{Err: "server refused query", Name: "www.example.com"},
+			want:       "rejected",
+		},
+		{
+			name:       "unreachable",
+			recordType: RecordTypeA,
+			key:        "www.example.com",
+			err: &net.DNSError{
+				Err:  "connection refused",
+				Name: "www.example.com",
+				UnwrapErr: &net.OpError{
+					Op:  "dial",
+					Net: "udp",
+					Err: errors.New("connection refused"),
+				},
+			},
+			want: "unreachable",
+		},
+		{
+			name:       "other error",
+			recordType: RecordTypeA,
+			key:        "www.example.com",
+			err:        errors.New("resolver closed"),
+			want:       "error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newStubResolver()
+			// The name doesn't resolve back to the address.
+			r.addrs["10.0.0.66"] = []string{"web.example.com."}
+			r.err = tt.err
+			cfg := createDefaultConfig().(*Config)
+			cfg.RecordType = tt.recordType
+			cfg.ForwardConfirm = tt.confirm
+			cfg.EmitErrorAttribute = true
+			source := newSource(cfg, r, zap.NewNop())
+
+			val, found, err := source.Lookup(t.Context(), tt.key)
+			assert.False(t, found)
+			if tt.err == nil {
+				require.NoError(t, err)
+				assert.Equal(t, lookupsource.NotFoundReason(tt.want), val)
+				return
+			}
+			require.Error(t, err)
+			reason, ok := lookupsource.FailureReason(err)
+			require.True(t, ok)
+			assert.Equal(t, tt.want, reason)
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		r := newStubResolver()
+		cfg := createDefaultConfig().(*Config)
+		cfg.RecordType = RecordTypeA
+		source := newSource(cfg, r, zap.NewNop())
+
+		val, found, err := source.Lookup(t.Context(), "missing.example.com")
+		require.NoError(t, err)
+		assert.False(t, found)
+		assert.Nil(t, val)
+
+		r.err = &net.DNSError{Err: "server misbehaving", Name: "www.example.com", IsTemporary: true}
+		_, _, err = source.Lookup(t.Context(), "www.example.com")
+		require.Error(t, err)
+		_, ok := lookupsource.FailureReason(err)
+		assert.False(t, ok)
+	})
+
+	t.Run("negative aggregate", func(t *testing.T) {
+		cfg := createDefaultConfig().(*Config)
+		cfg.EmitErrorAttribute = true
+		cfg.NegativeAggregates = []NegativeAggregate{{CIDR: "100.64.0.0/10", PrefixLength: 24}}
+		source := newSource(cfg, newStubResolver(), zap.NewNop())
+
+		// The second address is answered by the miss of the first one, and
+		// keeps its reason.
+		for _, key := range []string{"100.64.1.1", "100.64.1.2"} {
+			val, found, err := source.Lookup(t.Context(), key)
+			require.NoError(t, err)
+			assert.False(t, found)
+			assert.Equal(t, lookupsource.NotFoundReason("NXDOMAIN"), val)
+		}
+	})
+}
+
+func TestLookupRefreshAhead(t *testing.T) {
+	const ttl = 300 * time.Millisecond
+	r := &countingResolver{stubResolver: newStubResolver()}
+	r.addrs["10.0.0.3"] = []string{"db.example.com."}
+	cfg := createDefaultConfig().(*Config)
+	cfg.Cache.TTL = ttl
+	cfg.RefreshAhead.Enabled = true
+	cfg.RefreshAhead.MinAccesses = 3
+	cfg.RefreshAhead.Window = ttl / 2
+	cfg.RefreshAhead.Interval = 5 * time.Millisecond
+	require.NoError(t, cfg.Validate())
+	source := newSource(cfg, r, zap.NewNop())
+	require.NoError(t, source.Start(t.Context(), componenttest.NewNopHost()))
+	defer func() { require.NoError(t, source.Shutdown(t.Context())) }()
+
+	lookup := func(key, want string) {
+		t.Helper()
+		val, found, err := source.Lookup(t.Context(), key)
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, want, val)
+	}
+
+	// 10.0.0.1 is read often, 10.0.0.3 once.
+	start := time.Now()
+	lookup("10.0.0.1", "web-1.example.com")
+	lookup("10.0.0.3", "db.example.com")
+	for range 4 {
+		lookup("10.0.0.1", "web-1.example.com")
+	}
+	assert.Equal(t, int64(2), r.lookups.Load())
+
+	// Reading the hot entry within the window refreshes it in the
+	// background.
+	time.Sleep(time.Until(start.Add(ttl * 2 / 3)))
+	lookup("10.0.0.1", "web-1.example.com")
+	require.Eventually(t, func() bool { return r.lookups.Load() == 3 }, ttl/4, time.Millisecond)
+
+	// Once the first entries expire, the hot key is still cached, and the
+	// cold one is looked up again.
+	time.Sleep(time.Until(start.Add(ttl * 11 / 10)))
+	stats, _ := source.(lookupsource.CacheStatsReporter).CacheStats()
+	lookup("10.0.0.1", "web-1.example.com")
+	lookup("10.0.0.3", "db.example.com")
+	after, _ := source.(lookupsource.CacheStatsReporter).CacheStats()
+	assert.Equal(t, stats.Hits+1, after.Hits)
+	assert.Equal(t, stats.Misses+1, after.Misses)
+	assert.Equal(t, int64(4), r.lookups.Load())
+}
+
+// countingResolver counts the PTR queries of a stubResolver, atomically so
+// that the test can read the count while refreshes query in the background.
+type countingResolver struct {
+	*stubResolver
+	lookups atomic.Int64
+}
+
+func (r *countingResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	names, err := r.stubResolver.LookupAddr(ctx, addr)
+	r.lookups.Add(1)
+	return names, err
+}
+
+func TestResolverLocalAddr(t *testing.T) {
+	assert.Nil(t, localAddr("udp", nil))
+	assert.Equal(t, &net.UDPAddr{IP: net.ParseIP("10.0.0.5")}, localAddr("udp4", net.ParseIP("10.0.0.5")))
+	assert.Equal(t, &net.TCPAddr{IP: net.ParseIP("10.0.0.5")}, localAddr("tcp", net.ParseIP("10.0.0.5")))
+
+	assert.Same(t, net.DefaultResolver, newResolver("", nil))
+
+	// Queries are sent from the bind address, to the configured server or
+	// else to the server chosen by the resolver.
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer server.Close()
+	for _, configured := range []string{server.LocalAddr().String(), ""} {
+		r := newResolver(configured, net.ParseIP("127.0.0.1"))
+		conn, err := r.Dial(t.Context(), "udp", server.LocalAddr().String())
+		require.NoError(t, err)
+		assert.True(t, conn.LocalAddr().(*net.UDPAddr).IP.Equal(net.ParseIP("127.0.0.1")))
+		assert.Equal(t, server.LocalAddr().String(), conn.RemoteAddr().String())
+		require.NoError(t, conn.Close())
+	}
+}
+
+func TestConfigLocalIP(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	ip, err := cfg.localIP()
+	require.NoError(t, err)
+	assert.Nil(t, ip)
+
+	cfg.BindAddress = "127.0.0.1"
+	ip, err = cfg.localIP()
+	require.NoError(t, err)
+	assert.Equal(t, net.ParseIP("127.0.0.1"), ip)
+
+	loopback := loopbackInterface(t)
+	cfg = createDefaultConfig().(*Config)
+	cfg.Interface = loopback.Name
+	cfg.Server = "127.0.0.53:53"
+	ip, err = cfg.localIP()
+	require.NoError(t, err)
+	assert.True(t, ip.IsLoopback())
+	assert.NotNil(t, ip.To4())
+
+	_, err = createSource(t.Context(), lookupsource.CreateSettings{}, cfg)
+	require.NoError(t, err)
+}
+
+// loopbackInterface returns the loopback interface of the host, skipping the
+// test if it has no IPv4 loopback address.
+func loopbackInterface(t *testing.T) *net.Interface {
+	t.Helper()
+	ifaces, err := net.Interfaces()
+	require.NoError(t, err)
+	for i := range ifaces {
+		if ifaces[i].Flags&net.FlagLoopback == 0 {
+			continue
+		}
+		addrs, err := ifaces[i].Addrs()
+		require.NoError(t, err)
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+				return &ifaces[i]
+			}
+		}
+	}
+	t.Skip("no IPv4 loopback interface")
+	return nil
+}
+
+func TestLookupCache(t *testing.T) {
+	r := newStubResolver()
+	cfg := createDefaultConfig().(*Config)
+	cfg.RecordType = RecordTypeCNAME
+	source := newSource(cfg, r, zap.NewNop())
+
+	for range 3 {
+		val, found, err := source.Lookup(t.Context(), "www.example.com")
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "cdn.example.net", val)
+	}
+	assert.Equal(t, 1, r.calls)
+
+	stats, ok := source.(lookupsource.CacheStatsReporter).CacheStats()
+	require.True(t, ok)
+	assert.Equal(t, int64(2), stats.Hits)
+}
+
+func TestLookupLatency(t *testing.T) {
+	r := newStubResolver()
+	r.delay = 50 * time.Millisecond
+	cfg := createDefaultConfig().(*Config)
+	cfg.EmitLatencyAttribute = true
+	source := newSource(cfg, r, zap.NewNop())
+
+	val, found, err := source.Lookup(t.Context(), "10.0.0.1")
+	require.NoError(t, err)
+	assert.True(t, found)
+	require.IsType(t, lookupsource.TimedResult{}, val)
+	result := val.(lookupsource.TimedResult)
+	assert.Equal(t, "web-1.example.com", result.Value)
+	assert.GreaterOrEqual(t, result.Duration, r.delay)
+
+	// Cache hits don't query the resolver.
+	val, found, err = source.Lookup(t.Context(), "10.0.0.1")
+	require.NoError(t, err)
+	assert.True(t, found)
+	result = val.(lookupsource.TimedResult)
+	assert.Equal(t, "web-1.example.com", result.Value)
+	assert.Less(t, result.Duration, r.delay)
+	assert.Equal(t, 1, r.calls)
+
+	_, found, err = source.Lookup(t.Context(), "10.0.0.9")
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestLookupCacheCaseInsensitive(t *testing.T) {
+	r := newStubResolver()
+	cfg := createDefaultConfig().(*Config)
+	cfg.RecordType = RecordTypeA
+	source := newSource(cfg, r, zap.NewNop())
+
+	for _, key := range []string{"web.example.com", "WEB.example.com", "Web.Example.Com"} {
+		val, found, err := source.Lookup(t.Context(), key)
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "10.0.0.1", val)
+	}
+	assert.Equal(t, 1, r.calls)
+}
+
+func TestShutdown(t *testing.T) {
+	r := newStubResolver()
+	cfg := createDefaultConfig().(*Config)
+	cfg.RecordType = RecordTypeCNAME
+	source := newSource(cfg, r, zap.NewNop())
+
+	_, found, err := source.Lookup(t.Context(), "www.example.com")
+	require.NoError(t, err)
+	assert.True(t, found)
+
+	require.NoError(t, source.Shutdown(t.Context()))
+	stats, _ := source.(lookupsource.CacheStatsReporter).CacheStats()
+	assert.Zero(t, stats.Size)
+
+	// A second shutdown is a no-op.
+	assert.NoError(t, source.Shutdown(t.Context()))
+}
+
+func TestLookupLogs(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	var settings lookupsource.CreateSettings
+	settings.TelemetrySettings.Logger = zap.New(core)
+	cfg := createDefaultConfig().(*Config)
+	cfg.Server = "10.0.0.53:53"
+	cfg.Cache.Enabled = false
+	source, err := createSource(t.Context(), settings, cfg)
+	require.NoError(t, err)
+	require.Equal(t, 1, logs.FilterMessage("Using the configured DNS server").FilterField(zap.String("server", "10.0.0.53:53")).Len())
+
+	r := newStubResolver()
+	source = newSource(cfg, r, zap.New(core))
+	for _, key := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.9", "web.example.com"} {
+		_, _, err = source.Lookup(t.Context(), key)
+		require.NoError(t, err)
+	}
+	r.err = &net.DNSError{Err: "server misbehaving", Name: "10.0.0.1", IsTemporary: true}
+	_, _, err = source.Lookup(t.Context(), "10.0.0.1")
+	require.Error(t, err)
+
+	type entry struct {
+		message string
+		fields  map[string]any
+	}
+	var got []entry
+	for _, e := range logs.FilterMessageSnippet("DNS lookup").All() {
+		assert.Equal(t, zapcore.DebugLevel, e.Level)
+		fields := e.ContextMap()
+		assert.Equal(t, "PTR", fields["record_type"])
+		assert.Equal(t, "10.0.0.53:53", fields["server"])
+		delete(fields, "record_type")
+		delete(fields, "server")
+		delete(fields, "error")
+		got = append(got, entry{message: e.Message, fields: fields})
+	}
+	assert.Equal(t, []entry{
+		{message: "DNS lookup resolved", fields: map[string]any{"key": "10.0.0.1", "value": "web-1.example.com"}},
+		{message: "DNS lookup not found", fields: map[string]any{"key": "10.0.0.2", "reason": "no PTR records"}},
+		{message: "DNS lookup not found", fields: map[string]any{"key": "10.0.0.9", "reason": "name does not exist"}},
+		{message: "DNS lookup not found", fields: map[string]any{"key": "web.example.com", "reason": "key is not an IP address"}},
+		{message: "DNS lookup failed", fields: map[string]any{"key": "10.0.0.1"}},
+	}, got)
+}
+
+func TestLookupLogsSampled(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	var settings lookupsource.CreateSettings
+	settings.TelemetrySettings.Logger = zap.New(core)
+	cfg := createDefaultConfig().(*Config)
+	cfg.Cache.Enabled = false
+	source := newSource(cfg, newStubResolver(), settings.LookupLogger())
+
+	for range 50 {
+		_, _, err := source.Lookup(t.Context(), "10.0.0.1")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 10, logs.FilterMessage("DNS lookup resolved").Len())
+}
+
+func TestIntegrationLookup(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test querying public DNS in short mode")
+	}
+
+	tests := []struct {
+		recordType RecordType
+		key        string
+		want       string
+	}{
+		{recordType: RecordTypeA, key: "localhost", want: "127.0.0.1"},
+		{recordType: RecordTypeCNAME, key: "www.github.com", want: "github.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.recordType), func(t *testing.T) {
+			cfg := createDefaultConfig().(*Config)
+			cfg.RecordType = tt.recordType
+			source, err := createSource(t.Context(), lookupsource.CreateSettings{}, cfg)
+			require.NoError(t, err)
+
+			val, found, err := source.Lookup(t.Context(), tt.key)
+			require.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, tt.want, val)
+		})
+	}
+}
+
+// newUDPServer starts a DNS server over UDP answering PTR queries with
+// answerPTR and returns its address.
+func newUDPServer(t *testing.T, ptr map[string]string) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var query dnsmessage.Message
+			if err := query.Unpack(buf[:n]); err != nil || len(query.Questions) != 1 {
+				continue
+			}
+			if answer, err := answerPTR(&query, ptr, dnsmessage.RCodeSuccess); err == nil {
+				_, _ = conn.WriteTo(answer, addr)
+			}
+		}
+	}()
+	t.Cleanup(func() {
+		conn.Close()
+		<-done
+	})
+	return conn.LocalAddr().String()
+}
+
+func TestReconfigure(t *testing.T) {
+	server := newUDPServer(t, map[string]string{
+		"2.0.0.10.in-addr.arpa.": "new-2.example.com.",
+	})
+	r := &stubResolver{addrs: map[string][]string{
+		"10.0.0.1": {"old-1.example.com."},
+		"10.0.0.2": {"old-2.example.com."},
+	}}
+	cfg := createDefaultConfig().(*Config)
+	source := newSource(cfg, r, zap.NewNop())
+
+	val, found, err := source.Lookup(t.Context(), "10.0.0.1")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "old-1.example.com", val)
+
+	newCfg := createDefaultConfig().(*Config)
+	newCfg.Server = server
+	require.NoError(t, lookupsource.Reconfigure(t.Context(), source, newCfg))
+
+	// The cache is kept, and the next queries go to the new server.
+	val, found, err = source.Lookup(t.Context(), "10.0.0.1")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "old-1.example.com", val)
+	val, found, err = source.Lookup(t.Context(), "10.0.0.2")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "new-2.example.com", val)
+	assert.Equal(t, 1, r.calls)
+
+	// Reconfiguring with the same settings changes nothing, and other
+	// settings require recreating the source.
+	require.NoError(t, lookupsource.Reconfigure(t.Context(), source, newCfg))
+	otherCfg := createDefaultConfig().(*Config)
+	otherCfg.Server = server
+	otherCfg.RecordType = RecordTypeA
+	require.ErrorIs(t, lookupsource.Reconfigure(t.Context(), source, otherCfg), lookupsource.ErrReconfigureUnsupported)
+	val, _, err = source.Lookup(t.Context(), "10.0.0.2")
+	require.NoError(t, err)
+	assert.Equal(t, "new-2.example.com", val)
+}
+
+func TestDebugLookup(t *testing.T) {
+	r := newStubResolver()
+	factory := lookupsource.NewSourceFactory(sourceType, createDefaultConfig,
+		func(_ context.Context, _ lookupsource.CreateSettings, cfg lookupsource.SourceConfig) (lookupsource.Source, error) {
+			return newSource(cfg.(*Config), r, zap.NewNop()), nil
+		},
+	)
+	cfg := createDefaultConfig().(*Config)
+	cfg.EmitErrorAttribute = true
+
+	result, err := lookupsource.DebugLookup(t.Context(), factory, cfg, "10.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, "dns", result.SourceType)
+	assert.Equal(t, "web-1.example.com", result.Value)
+	assert.True(t, result.Found)
+	assert.Equal(t, lookupsource.CacheMiss, result.Cache)
+	assert.Empty(t, result.Error)
+
+	result, err = lookupsource.DebugLookup(t.Context(), factory, cfg, "10.0.0.9")
+	require.NoError(t, err)
+	assert.False(t, result.Found)
+	assert.Nil(t, result.Value)
+	assert.Equal(t, reasonNXDOMAIN, result.Reason)
+	assert.Empty(t, result.ErrorClass)
+
+	r.err = &net.DNSError{Err: "server misbehaving", Name: "1.0.0.10.in-addr.arpa.", IsTemporary: true}
+	result, err = lookupsource.DebugLookup(t.Context(), factory, cfg, "10.0.0.1")
+	require.NoError(t, err)
+	assert.False(t, result.Found)
+	assert.Equal(t, reasonSERVFAIL, result.Reason)
+	assert.Equal(t, lookupsource.ErrorClassTransient, result.ErrorClass)
+	assert.Contains(t, result.Error, "server misbehaving")
+	require.Error(t, result.Err)
+}
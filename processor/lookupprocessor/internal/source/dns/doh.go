@@ -0,0 +1,291 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package dns // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/dns"
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/confighttp"
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+const (
+	// dohContentType is the media type of DNS-over-HTTPS queries and
+	// answers.
+	dohContentType = "application/dns-message"
+
+	// maxDoHResponseSize is the largest DNS message.
+	maxDoHResponseSize = 65535
+)
+
+var errDoHNotStarted = errors.New("dns source not started")
+
+// DoHConfig configures DNS-over-HTTPS queries.
+type DoHConfig struct {
+	// ClientConfig configures the HTTP client: endpoint, TLS, headers,
+	// authentication extensions, etc. Endpoint is the URL queries are
+	// posted to, such as https://dns.example.com/dns-query. Queries are
+	// bounded by the timeout of the source.
+	confighttp.ClientConfig `mapstructure:",squash"`
+
+	// MaxConcurrentQueries bounds the queries of a batch lookup in flight
+	// at once, so that they share the streams of one HTTP/2 connection
+	// rather than opening more.
+	// Default: 50
+	MaxConcurrentQueries int `mapstructure:"max_concurrent_queries"`
+}
+
+func (c *DoHConfig) enabled() bool {
+	return c.Endpoint != ""
+}
+
+// validate adds the errors of the DoH settings of cfg to errs.
+func (c *DoHConfig) validate(cfg *Config, errs *lookupsource.FieldErrors) {
+	if !c.enabled() {
+		return
+	}
+	if !strings.HasPrefix(c.Endpoint, "https://") {
+		errs.Addf("doh.endpoint", "must be an https:// URL, got %q", c.Endpoint)
+	}
+	if cfg.RecordType != RecordTypePTR {
+		errs.Addf("doh", "is only supported with the PTR record type, not %s", cfg.RecordType)
+	}
+	if cfg.Server != "" || cfg.BindAddress != "" || cfg.Interface != "" {
+		errs.Addf("doh", "cannot be used with server, bind_address or interface")
+	}
+	if c.MaxConcurrentQueries <= 0 {
+		errs.Addf("doh.max_concurrent_queries", "must be positive, got %d", c.MaxConcurrentQueries)
+	}
+}
+
+// dohResolver resolves PTR records, and the addresses confirming them, with
+// DNS-over-HTTPS queries (RFC 8484). Queries running concurrently share the
+// connections of its client, multiplexed over HTTP/2.
+type dohResolver struct {
+	endpoint string
+	// newClient creates the client on start, if set, since authentication
+	// extensions are only available from the host.
+	newClient func(ctx context.Context, host component.Host) (*http.Client, error)
+
+	mu     sync.RWMutex
+	client *http.Client
+}
+
+func (r *dohResolver) start(ctx context.Context, host component.Host) error {
+	if r.newClient == nil {
+		return nil
+	}
+	client, err := r.newClient(ctx, host)
+	if err != nil {
+		return fmt.Errorf("failed to create DNS-over-HTTPS client: %w", err)
+	}
+	r.mu.Lock()
+	r.client = client
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *dohResolver) shutdown(context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.client != nil {
+		r.client.CloseIdleConnections()
+	}
+	return nil
+}
+
+func (r *dohResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return nil, &net.DNSError{Err: "unrecognized address", Name: addr}
+	}
+	answers, err := r.query(ctx, reverseName(ip), dnsmessage.TypePTR)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, answer := range answers {
+		if ptr, ok := answer.Body.(*dnsmessage.PTRResource); ok {
+			names = append(names, ptr.PTR.String())
+		}
+	}
+	return names, nil
+}
+
+func (r *dohResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	qtype := dnsmessage.TypeA
+	if network == "ip6" {
+		qtype = dnsmessage.TypeAAAA
+	}
+	answers, err := r.query(ctx, host, qtype)
+	if err != nil {
+		return nil, err
+	}
+	var ips []net.IP
+	for _, answer := range answers {
+		switch body := answer.Body.(type) {
+		case *dnsmessage.AResource:
+			ips = append(ips, net.IP(body.A[:]))
+		case *dnsmessage.AAAAResource:
+			ips = append(ips, net.IP(body.AAAA[:]))
+		}
+	}
+	return ips, nil
+}
+
+// LookupCNAME is not supported: Validate only allows PTR lookups over
+// DNS-over-HTTPS.
+func (*dohResolver) LookupCNAME(context.Context, string) (string, error) {
+	return "", errors.New("CNAME lookups are not supported over DNS-over-HTTPS")
+}
+
+// LookupSRV is not supported, like LookupCNAME.
+func (*dohResolver) LookupSRV(context.Context, string, string, string) (string, []*net.SRV, error) {
+	return "", nil, errors.New("SRV lookups are not supported over DNS-over-HTTPS")
+}
+
+// query posts a query for the records of type qtype of name and returns the
+// answer records. Errors are returned as a *net.DNSError where the answer
+// has a meaning for the lookup, so that they are classified like those of
+// the system resolver: NXDOMAIN is not found, and SERVFAIL and server errors
+// are temporary.
+func (r *dohResolver) query(ctx context.Context, name string, qtype dnsmessage.Type) ([]dnsmessage.Resource, error) {
+	r.mu.RLock()
+	client := r.client
+	r.mu.RUnlock()
+	if client == nil {
+		return nil, errDoHNotStarted
+	}
+
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	qname, err := dnsmessage.NewName(name)
+	if err != nil {
+		return nil, &net.DNSError{Err: "invalid name", Name: name}
+	}
+	// The ID is zero so that identical queries can be cached by HTTP
+	// caches, as recommended by RFC 8484.
+	msg := dnsmessage.Message{
+		Header:    dnsmessage.Header{RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: qname, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+	body, err := msg.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode DNS query: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create DNS-over-HTTPS request: %w", err)
+	}
+	req.Header.Set("Content-Type", dohContentType)
+	req.Header.Set("Accept", dohContentType)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &net.DNSError{
+			Err:         "DNS-over-HTTPS query failed: " + resp.Status,
+			Name:        name,
+			Server:      r.endpoint,
+			IsTemporary: resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests,
+		}
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxDoHResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DNS-over-HTTPS answer: %w", err)
+	}
+
+	var answer dnsmessage.Message
+	if err := answer.Unpack(data); err != nil {
+		return nil, &net.DNSError{Err: "cannot unmarshal DNS message", Name: name, Server: r.endpoint}
+	}
+	switch answer.Header.RCode {
+	case dnsmessage.RCodeSuccess:
+		return answer.Answers, nil
+	case dnsmessage.RCodeNameError:
+		return nil, &net.DNSError{Err: "no such host", Name: name, Server: r.endpoint, IsNotFound: true}
+	case dnsmessage.RCodeServerFailure:
+		return nil, &net.DNSError{Err: "server misbehaving", Name: name, Server: r.endpoint, IsTemporary: true}
+	default:
+		return nil, &net.DNSError{Err: "server misbehaving: " + answer.Header.RCode.String(), Name: name, Server: r.endpoint}
+	}
+}
+
+// reverseName returns the name of the PTR record of addr, under in-addr.arpa
+// for IPv4 addresses and ip6.arpa for IPv6 ones.
+func reverseName(addr netip.Addr) string {
+	addr = addr.Unmap()
+	var b strings.Builder
+	if addr.Is4() {
+		ip := addr.As4()
+		for i := len(ip) - 1; i >= 0; i-- {
+			b.WriteString(strconv.Itoa(int(ip[i])))
+			b.WriteByte('.')
+		}
+		b.WriteString("in-addr.arpa.")
+		return b.String()
+	}
+	const hexDigits = "0123456789abcdef"
+	ip := addr.As16()
+	for i := len(ip) - 1; i >= 0; i-- {
+		b.WriteByte(hexDigits[ip[i]&0xf])
+		b.WriteByte('.')
+		b.WriteByte(hexDigits[ip[i]>>4])
+		b.WriteByte('.')
+	}
+	b.WriteString("ip6.arpa.")
+	return b.String()
+}
+
+// lookupBatch returns a batch lookup function running fn for the keys
+// concurrently, at most MaxConcurrentQueries at a time, so that the queries
+// of the keys share the connections of the client. The first key is looked
+// up alone: queries started together before a connection is open would
+// each dial their own. The batch never fails as a whole: each key gets the
+// result of its own lookup.
+func (s *dnsSource) lookupBatch(fn lookupsource.LookupFunc) lookupsource.BatchLookupFunc {
+	return func(ctx context.Context, keys []string) ([]lookupsource.Result, error) {
+		results := make([]lookupsource.Result, len(keys))
+		if len(keys) == 0 {
+			return results, nil
+		}
+		val, found, err := fn(ctx, keys[0])
+		results[0] = lookupsource.Result{Value: val, Found: found, Err: err}
+
+		sem := make(chan struct{}, s.cfg.DoH.MaxConcurrentQueries)
+		var wg sync.WaitGroup
+		for i, key := range keys[1:] {
+			i++
+			sem <- struct{}{}
+			wg.Add(1)
+			go func() {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				val, found, err := fn(ctx, key)
+				results[i] = lookupsource.Result{Value: val, Found: found, Err: err}
+			}()
+		}
+		wg.Wait()
+		return results, nil
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package dns
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.uber.org/zap"
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+// dohServer is a DNS-over-HTTPS server over HTTP/2 answering PTR queries
+// from ptr, keyed by reverse name, with NXDOMAIN for other names. It counts
+// the connections and queries it receives.
+type dohServer struct {
+	*httptest.Server
+	ptr map[string]string
+	// rcode, if set, answers every query.
+	rcode dnsmessage.RCode
+
+	conns   atomic.Int64
+	queries atomic.Int64
+}
+
+func newDoHServer(t *testing.T, ptr map[string]string) *dohServer {
+	s := &dohServer{ptr: ptr}
+	s.Server = httptest.NewUnstartedServer(http.HandlerFunc(s.serveDNS))
+	s.EnableHTTP2 = true
+	s.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			s.conns.Add(1)
+		}
+	}
+	s.StartTLS()
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *dohServer) serveDNS(w http.ResponseWriter, r *http.Request) {
+	s.queries.Add(1)
+	if r.Method != http.MethodPost || r.Header.Get("Content-Type") != dohContentType {
+		http.Error(w, "unsupported request", http.StatusUnsupportedMediaType)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var query dnsmessage.Message
+	if err := query.Unpack(body); err != nil || len(query.Questions) != 1 {
+		http.Error(w, "invalid query", http.StatusBadRequest)
+		return
+	}
+	packed, err := answerPTR(&query, s.ptr, s.rcode)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", dohContentType)
+	_, _ = w.Write(packed)
+}
+
+// answerPTR returns the packed answer to query from ptr, keyed by reverse
+// name, with NXDOMAIN for other names, or rcode if set.
+func answerPTR(query *dnsmessage.Message, ptr map[string]string, rcode dnsmessage.RCode) ([]byte, error) {
+	question := query.Questions[0]
+	answer := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: query.ID, Response: true, RCode: rcode},
+		Questions: query.Questions,
+	}
+	if name, ok := ptr[question.Name.String()]; ok && rcode == dnsmessage.RCodeSuccess && question.Type == dnsmessage.TypePTR {
+		answer.Answers = []dnsmessage.Resource{{
+			Header: dnsmessage.ResourceHeader{Name: question.Name, Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET, TTL: 300},
+			Body:   &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName(name)},
+		}}
+	} else if rcode == dnsmessage.RCodeSuccess {
+		answer.Header.RCode = dnsmessage.RCodeNameError
+	}
+	return answer.Pack()
+}
+
+func newDoHSource(t *testing.T, server *dohServer, modify func(*Config)) lookupsource.Source {
+	cfg := createDefaultConfig().(*Config)
+	cfg.DoH.Endpoint = server.URL
+	if modify != nil {
+		modify(cfg)
+	}
+	require.NoError(t, cfg.Validate())
+	source := newSource(cfg, &dohResolver{endpoint: server.URL, client: server.Client()}, zap.NewNop())
+	require.NoError(t, source.Start(t.Context(), componenttest.NewNopHost()))
+	t.Cleanup(func() { require.NoError(t, source.Shutdown(t.Context())) })
+	return source
+}
+
+func TestDoHLookup(t *testing.T) {
+	server := newDoHServer(t, map[string]string{
+		"1.0.0.10.in-addr.arpa.": "Web-1.Example.com.",
+		"1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.": "db-1.example.com.",
+	})
+	source := newDoHSource(t, server, func(c *Config) { c.LowercaseResult = true })
+
+	val, found, err := source.Lookup(t.Context(), "10.0.0.1")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "web-1.example.com", val)
+
+	val, found, err = source.Lookup(t.Context(), "2001:db8::1")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "db-1.example.com", val)
+
+	_, found, err = source.Lookup(t.Context(), "10.0.0.2")
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestDoHLookupBatch(t *testing.T) {
+	ptr := make(map[string]string)
+	keys := make([]string, 10)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("10.0.0.%d", i+1)
+		// The last key has no PTR record.
+		if i < len(keys)-1 {
+			ptr[fmt.Sprintf("%d.0.0.10.in-addr.arpa.", i+1)] = fmt.Sprintf("host-%d.example.com.", i+1)
+		}
+	}
+	server := newDoHServer(t, ptr)
+	source := newDoHSource(t, server, func(c *Config) {
+		c.CIDROverrides = []CIDROverride{{CIDR: "192.168.0.0/16", Value: "lab"}}
+	})
+	batch, ok := source.(lookupsource.BatchSource)
+	require.True(t, ok)
+
+	results, err := batch.LookupBatch(t.Context(), keys)
+	require.NoError(t, err)
+	require.Len(t, results, len(keys))
+	for i, res := range results[:len(keys)-1] {
+		require.NoError(t, res.Err, keys[i])
+		assert.True(t, res.Found, keys[i])
+		assert.Equal(t, fmt.Sprintf("host-%d.example.com", i+1), res.Value, keys[i])
+	}
+	assert.False(t, results[len(keys)-1].Found)
+	assert.NoError(t, results[len(keys)-1].Err)
+
+	// The queries of the batch share a connection rather than opening one
+	// each.
+	assert.Equal(t, int64(len(keys)), server.queries.Load())
+	assert.Less(t, server.conns.Load(), int64(len(keys)))
+
+	// Only the keys missing from the cache are queried, and overridden keys
+	// aren't queried at all.
+	results, err = batch.LookupBatch(t.Context(), []string{"10.0.0.1", "::ffff:10.0.0.2", "10.0.0.11", "192.168.1.1"})
+	require.NoError(t, err)
+	assert.Equal(t, "host-1.example.com", results[0].Value)
+	assert.Equal(t, "host-2.example.com", results[1].Value)
+	assert.False(t, results[2].Found)
+	assert.Equal(t, lookupsource.Result{Value: "lab", Found: true}, results[3])
+	assert.Equal(t, int64(len(keys)+1), server.queries.Load())
+}
+
+func TestDoHLookupErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		rcode         dnsmessage.RCode
+		wantTransient bool
+		wantPermanent bool
+	}{
+		{
+			name:          "SERVFAIL",
+			rcode:         dnsmessage.RCodeServerFailure,
+			wantTransient: true,
+		},
+		{
+			name:          "REFUSED",
+			rcode:         dnsmessage.RCodeRefused,
+			wantPermanent: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newDoHServer(t, nil)
+			server.rcode = tt.rcode
+			source := newDoHSource(t, server, nil)
+
+			_, found, err := source.Lookup(t.Context(), "10.0.0.1")
+			assert.False(t, found)
+			require.Error(t, err)
+			assert.Equal(t, tt.wantTransient, lookupsource.IsTransient(err))
+			assert.Equal(t, tt.wantPermanent, lookupsource.IsPermanent(err))
+		})
+	}
+
+	// Server errors are temporary.
+	failing := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "overloaded", http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	r := &dohResolver{endpoint: failing.URL, client: failing.Client()}
+	_, err := r.LookupAddr(t.Context(), "10.0.0.1")
+	var dnsErr *net.DNSError
+	require.ErrorAs(t, err, &dnsErr)
+	assert.True(t, dnsErr.IsTemporary)
+	assert.Contains(t, dnsErr.Err, "503")
+
+	// Queries before start fail.
+	_, err = (&dohResolver{endpoint: failing.URL}).LookupAddr(t.Context(), "10.0.0.1")
+	assert.ErrorIs(t, err, errDoHNotStarted)
+}
+
+func TestReverseName(t *testing.T) {
+	assert.Equal(t, "4.3.2.1.in-addr.arpa.", reverseName(netip.MustParseAddr("1.2.3.4")))
+	assert.Equal(t, "4.3.2.1.in-addr.arpa.", reverseName(netip.MustParseAddr("::ffff:1.2.3.4")))
+	assert.Equal(t,
+		"1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.",
+		reverseName(netip.MustParseAddr("2001:db8::1")))
+}
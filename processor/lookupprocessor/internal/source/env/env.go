@@ -0,0 +1,95 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package env provides a lookup source reading values from environment
+// variables of the collector process.
+//
+// The variable read for a key is the configured prefix followed by the key,
+// optionally normalized so that keys such as "client.ip" map to valid
+// variable names such as "CLIENT_IP".
+package env // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/env"
+
+import (
+	"context"
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+const sourceType = "env"
+
+// invalidNameRegexp matches the characters not allowed in portable
+// environment variable names.
+var invalidNameRegexp = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+type Config struct {
+	// Prefix is prepended to the normalized key to form the name of the
+	// variable read, such as "LOOKUP_HOSTS_".
+	Prefix string `mapstructure:"prefix"`
+
+	// Uppercase converts keys to upper case.
+	// Default: false
+	Uppercase bool `mapstructure:"uppercase"`
+
+	// Replace replaces every character of a key other than letters, digits
+	// and underscores. If empty, keys are used as they are.
+	Replace string `mapstructure:"replace"`
+}
+
+func (c *Config) Validate() error {
+	if invalidNameRegexp.MatchString(c.Replace) {
+		return errors.New("replace must only contain letters, digits and underscores")
+	}
+	return nil
+}
+
+func NewFactory() lookupsource.SourceFactory {
+	return lookupsource.NewSourceFactory(
+		sourceType,
+		createDefaultConfig,
+		createSource,
+	)
+}
+
+func createDefaultConfig() lookupsource.SourceConfig {
+	return &Config{}
+}
+
+func createSource(
+	_ context.Context,
+	_ lookupsource.CreateSettings,
+	cfg lookupsource.SourceConfig,
+) (lookupsource.Source, error) {
+	envCfg := *cfg.(*Config)
+
+	// Reading the environment is already cheap, so no cache is needed, and
+	// variables changed at runtime are picked up.
+	lookup := func(_ context.Context, key string) (any, bool, error) {
+		val := os.Getenv(envCfg.Prefix + envCfg.normalize(key))
+		if val == "" {
+			return nil, false, nil
+		}
+		return val, true, nil
+	}
+
+	return lookupsource.NewSource(
+		lookup,
+		func() string { return sourceType },
+		nil, // no start needed
+		nil, // no shutdown needed
+	), nil
+}
+
+// normalize returns the variable name suffix for key.
+func (c *Config) normalize(key string) string {
+	if c.Replace != "" {
+		key = invalidNameRegexp.ReplaceAllLiteralString(key, c.Replace)
+	}
+	if c.Uppercase {
+		key = strings.ToUpper(key)
+	}
+	return key
+}
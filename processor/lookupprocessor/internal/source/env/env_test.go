@@ -0,0 +1,97 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package env
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{
+			name: "default",
+		},
+		{
+			name: "prefix and replace",
+			cfg:  Config{Prefix: "LOOKUP_", Uppercase: true, Replace: "_"},
+		},
+		{
+			name:    "invalid replace",
+			cfg:     Config{Replace: "-"},
+			wantErr: "replace must only contain letters, digits and underscores",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	t.Setenv("LOOKUP_10_0_0_1", "web-1")
+	t.Setenv("LOOKUP_10_0_0_2", "")
+	t.Setenv("LOOKUP_WEB_1", "storefront")
+	t.Setenv("LOOKUP_web_1", "lowercase")
+	t.Setenv("LOOKUP_web-1", "raw")
+
+	tests := []struct {
+		name      string
+		cfg       Config
+		key       string
+		wantVal   any
+		wantFound bool
+	}{
+		{name: "hit", cfg: Config{Prefix: "LOOKUP_", Replace: "_"}, key: "10.0.0.1", wantVal: "web-1", wantFound: true},
+		{name: "empty", cfg: Config{Prefix: "LOOKUP_", Replace: "_"}, key: "10.0.0.2"},
+		{name: "unset", cfg: Config{Prefix: "LOOKUP_", Replace: "_"}, key: "10.0.0.3"},
+		{name: "not normalized", cfg: Config{Prefix: "LOOKUP_"}, key: "web-1", wantVal: "raw", wantFound: true},
+		{name: "replace", cfg: Config{Prefix: "LOOKUP_", Replace: "_"}, key: "web-1", wantVal: "lowercase", wantFound: true},
+		{name: "uppercase", cfg: Config{Prefix: "LOOKUP_", Uppercase: true, Replace: "_"}, key: "web-1", wantVal: "storefront", wantFound: true},
+		{name: "without prefix", cfg: Config{Uppercase: true, Replace: "_"}, key: "lookup.web.1", wantVal: "storefront", wantFound: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := NewFactory().CreateSource(t.Context(), lookupsource.CreateSettings{}, &tt.cfg)
+			require.NoError(t, err)
+			assert.Equal(t, "env", source.Type())
+
+			val, found, err := source.Lookup(t.Context(), tt.key)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantFound, found)
+			assert.Equal(t, tt.wantVal, val)
+		})
+	}
+}
+
+func TestLookupReadsCurrentEnvironment(t *testing.T) {
+	source, err := NewFactory().CreateSource(t.Context(), lookupsource.CreateSettings{}, &Config{Prefix: "LOOKUP_"})
+	require.NoError(t, err)
+
+	_, found, err := source.Lookup(t.Context(), "late")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	t.Setenv("LOOKUP_late", "set")
+	val, found, err := source.Lookup(t.Context(), "late")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "set", val)
+}
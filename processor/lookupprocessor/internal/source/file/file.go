@@ -0,0 +1,547 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package file provides a lookup source backed by a local file too large to
+// be held in memory.
+//
+// On load, the source copies the file to a private snapshot and indexes the
+// offset of the line of each key; values are read from the snapshot when they
+// are looked up, and the most used ones are cached. Memory therefore grows
+// with the number and size of keys, not with the size of values, and changes
+// to the file, even a broken rewrite in place, don't affect lookups until it
+// is indexed again and validated.
+package file // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/file"
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/compression"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/invert"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/jsonnumber"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/metadata"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+const sourceType = "file"
+
+// Results of reloads recorded in the result attribute of the reload counter.
+var (
+	successfulReload = metric.WithAttributeSet(attribute.NewSet(
+		attribute.String("source_type", sourceType), attribute.String("result", "success")))
+	failedReload = metric.WithAttributeSet(attribute.NewSet(
+		attribute.String("source_type", sourceType), attribute.String("result", "failure")))
+)
+
+// Format is the encoding of the file.
+type Format string
+
+// FormatNDJSON is a file of JSON objects, one per line (JSON Lines).
+const FormatNDJSON Format = "ndjson"
+
+type Config struct {
+	// Path is the path of the file. Files compressed with gzip or zstd are
+	// decompressed.
+	Path string `mapstructure:"path"`
+
+	// Format is the encoding of the file. Only ndjson is supported.
+	// Default: ndjson
+	Format Format `mapstructure:"format"`
+
+	// KeyField is the field of each object holding its lookup key, which
+	// must be a string. Later lines override earlier ones with the same key.
+	// Default: key
+	KeyField string `mapstructure:"key_field"`
+
+	// ValueField is the field of each object holding its value. If empty,
+	// the value of a key is a map of the other fields of its object.
+	ValueField string `mapstructure:"value_field"`
+
+	// Invert looks entries up by value rather than by key: an inverted
+	// entry maps the value of an entry to its key. It requires ValueField,
+	// whose values must be strings.
+	Invert bool `mapstructure:"invert"`
+
+	// InvertCollision selects what an inverted entry returns when several
+	// keys have its value: first, list or error.
+	// Default: first
+	InvertCollision invert.Collision `mapstructure:"invert_collision"`
+
+	// RequiredFields are fields every object must hold besides KeyField,
+	// such as ValueField. A file with an object missing one is rejected.
+	RequiredFields []string `mapstructure:"required_fields"`
+
+	// JSONNumbers is how numbers are decoded: float64, which rounds
+	// integers above 2^53, int64, which keeps integers that fit as int64,
+	// or string, which keeps the exact text of every number.
+	// Default: float64
+	JSONNumbers jsonnumber.Mode `mapstructure:"json_numbers"`
+
+	// MinRows is the fewest objects the file must hold. Smaller files, such
+	// as a truncated or empty one, are rejected: the collector fails to
+	// start, and a reload keeps the previous entries. Zero accepts any file.
+	// Default: 1
+	MinRows int `mapstructure:"min_rows"`
+
+	// RefreshInterval is how often the modification time of the file is
+	// checked; the file is indexed again when it changes. Zero disables
+	// reloading.
+	// Default: 1m
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"`
+
+	Cache lookupsource.CacheConfig `mapstructure:"cache"`
+}
+
+func (c *Config) Validate() error {
+	if c.Path == "" {
+		return errors.New("path must be specified")
+	}
+	if c.Format != FormatNDJSON {
+		return fmt.Errorf("unsupported format %q, must be %q", c.Format, FormatNDJSON)
+	}
+	if c.KeyField == "" {
+		return errors.New("key_field must be specified")
+	}
+	for i, field := range c.RequiredFields {
+		if field == "" {
+			return fmt.Errorf("required_fields[%d] must not be empty", i)
+		}
+	}
+	if err := c.JSONNumbers.Validate(); err != nil {
+		return err
+	}
+	if c.Invert && c.ValueField == "" {
+		return errors.New("invert requires value_field")
+	}
+	if err := c.InvertCollision.Validate(); err != nil {
+		return err
+	}
+	if c.MinRows < 0 {
+		return errors.New("min_rows must not be negative")
+	}
+	if c.RefreshInterval < 0 {
+		return errors.New("refresh_interval must not be negative")
+	}
+	return nil
+}
+
+func NewFactory() lookupsource.SourceFactory {
+	return lookupsource.NewSourceFactory(
+		sourceType,
+		createDefaultConfig,
+		createSource,
+	)
+}
+
+func createDefaultConfig() lookupsource.SourceConfig {
+	return &Config{
+		Format:          FormatNDJSON,
+		KeyField:        "key",
+		JSONNumbers:     jsonnumber.Float64,
+		InvertCollision: invert.First,
+		MinRows:         1,
+		RefreshInterval: time.Minute,
+		Cache: lookupsource.CacheConfig{
+			Enabled: true,
+			Size:    10000,
+		},
+	}
+}
+
+func createSource(
+	_ context.Context,
+	settings lookupsource.CreateSettings,
+	cfg lookupsource.SourceConfig,
+) (lookupsource.Source, error) {
+	meter := settings.TelemetrySettings.MeterProvider.Meter(metadata.ScopeName)
+	return newSource(cfg.(*Config), settings.TelemetrySettings.Logger, meter), nil
+}
+
+func newSource(cfg *Config, logger *zap.Logger, meter metric.Meter) lookupsource.Source {
+	reloads, err := meter.Int64Counter(
+		"otelcol_lookup_source_reloads",
+		metric.WithDescription("Number of reloads of the source data, by result."),
+		metric.WithUnit("{reloads}"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+	s := &fileSource{
+		cfg:     cfg,
+		logger:  logger,
+		cache:   lookupsource.NewCache(cfg.Cache),
+		reloads: reloads,
+	}
+	s.cachedRead = lookupsource.WrapWithCache(s.cache, s.read)
+
+	return lookupsource.NewSource(
+		s.lookup,
+		func() string { return sourceType },
+		s.start,
+		s.shutdown,
+		lookupsource.WithCache(s.cache),
+		lookupsource.WithReconfigure(s.reconfigure),
+	)
+}
+
+type fileSource struct {
+	cfg    *Config
+	logger *zap.Logger
+	cache  *lookupsource.Cache
+	// reloads counts the reloads of the file by result.
+	reloads metric.Int64Counter
+	// cachedRead is read fronted by the cache.
+	cachedRead lookupsource.LookupFunc
+
+	// mu guards the index, the file it points into and the cache, so that a
+	// reload neither closes the file under a lookup reading it nor lets a
+	// lookup cache a value of the previous file.
+	mu    sync.RWMutex
+	index *index
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// index locates the line of each key in a snapshot of the file.
+type index struct {
+	// snapshot is the private copy of the file values are read from.
+	snapshot *os.File
+	modTime  time.Time
+	lines    map[string]line
+	// inverted are the inverted entries, read instead of lines with
+	// Config.Invert.
+	inverted map[string]any
+}
+
+// close closes and removes the snapshot of the index.
+func (idx *index) close() error {
+	return errors.Join(idx.snapshot.Close(), os.Remove(idx.snapshot.Name()))
+}
+
+// line is the position of a line of the file, without its line break.
+type line struct {
+	offset int64
+	length int
+}
+
+// start indexes the file and starts watching it. The collector fails to start
+// if the file can't be indexed, rather than running without any entries.
+func (s *fileSource) start(_ context.Context, _ component.Host) error {
+	idx, err := s.buildIndex()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.index = idx
+	s.mu.Unlock()
+
+	s.startRefresh(idx.modTime)
+	return nil
+}
+
+// startRefresh starts watching the file, last modified at modTime, if
+// reloading is enabled.
+func (s *fileSource) startRefresh(modTime time.Time) {
+	if s.cfg.RefreshInterval > 0 {
+		var ctx context.Context
+		ctx, s.cancel = context.WithCancel(context.Background())
+		s.wg.Add(1)
+		go s.refresh(ctx, modTime)
+	}
+}
+
+// stopRefresh stops watching the file and waits for a reload in progress.
+func (s *fileSource) stopRefresh() {
+	if s.cancel != nil {
+		s.cancel()
+		s.wg.Wait()
+		s.cancel = nil
+	}
+}
+
+func (s *fileSource) shutdown(ctx context.Context) error {
+	s.stopRefresh()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var errs []error
+	if s.index != nil {
+		errs = append(errs, s.index.close())
+		s.index = nil
+	}
+	errs = append(errs, s.cache.Shutdown(ctx))
+	return errors.Join(errs...)
+}
+
+// reconfigure applies cfg in place: its file is indexed and replaces the
+// current one, and watched at its refresh_interval. The cache is kept if
+// only refresh_interval changes. A file that can't be indexed leaves the
+// source unchanged, and cache settings require recreating the source.
+func (s *fileSource) reconfigure(_ context.Context, sourceCfg lookupsource.SourceConfig) error {
+	cfg, ok := sourceCfg.(*Config)
+	if !ok {
+		return fmt.Errorf("unexpected config type %T", sourceCfg)
+	}
+	if !reflect.DeepEqual(cfg.Cache, s.cfg.Cache) {
+		return fmt.Errorf("%w: cache settings can't change", lookupsource.ErrReconfigureUnsupported)
+	}
+	s.mu.RLock()
+	started := s.index != nil
+	s.mu.RUnlock()
+	if !started {
+		// The file of cfg is indexed on start.
+		s.mu.Lock()
+		s.cfg = cfg
+		s.mu.Unlock()
+		return nil
+	}
+
+	sameData := *cfg
+	sameData.RefreshInterval = s.cfg.RefreshInterval
+	var idx *index
+	if !reflect.DeepEqual(&sameData, s.cfg) {
+		var err error
+		if idx, err = (&fileSource{cfg: cfg, logger: s.logger}).buildIndex(); err != nil {
+			return err
+		}
+	}
+
+	s.stopRefresh()
+	s.mu.Lock()
+	s.cfg = cfg
+	var previous *index
+	if idx != nil {
+		previous = s.index
+		s.index = idx
+		s.cache.Clear()
+	}
+	modTime := s.index.modTime
+	s.mu.Unlock()
+	if previous != nil {
+		_ = previous.close()
+	}
+	s.startRefresh(modTime)
+	s.logger.Info("File source reconfigured", zap.String("path", cfg.Path))
+	return nil
+}
+
+// refresh indexes the file again whenever it changes, until ctx is done. The
+// new index only replaces the previous one once the file is fully read and
+// valid; a failed reload keeps the previous index.
+func (s *fileSource) refresh(ctx context.Context, modTime time.Time) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.cfg.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(s.cfg.Path)
+			if err == nil && info.ModTime().Equal(modTime) {
+				continue
+			}
+			var idx *index
+			if err == nil {
+				idx, err = s.buildIndex()
+			}
+			if err != nil {
+				s.logger.Warn("Failed to reload file, keeping the previous entries",
+					zap.String("path", s.cfg.Path), zap.Error(err))
+				s.reloads.Add(ctx, 1, failedReload)
+				continue
+			}
+			modTime = idx.modTime
+			s.swap(idx)
+			s.reloads.Add(ctx, 1, successfulReload)
+		}
+	}
+}
+
+// swap replaces the index with idx and drops the cached values of the
+// previous file.
+func (s *fileSource) swap(idx *index) {
+	s.mu.Lock()
+	previous := s.index
+	s.index = idx
+	s.cache.Clear()
+	s.mu.Unlock()
+
+	if previous != nil {
+		_ = previous.close()
+	}
+}
+
+// buildIndex copies the file, decompressed, to a snapshot while recording the
+// line of each key, then validates it. The snapshot is kept open to read values from, and
+// removed if the file is rejected.
+func (s *fileSource) buildIndex() (*index, error) {
+	f, err := os.Open(s.cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", s.cfg.Path, err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", s.cfg.Path, err)
+	}
+	content, err := compression.NewReader(f, s.cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", s.cfg.Path, err)
+	}
+	defer content.Close()
+	snapshot, err := os.CreateTemp("", "lookup-file-*.ndjson")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a snapshot of %s: %w", s.cfg.Path, err)
+	}
+	idx := &index{snapshot: snapshot, modTime: info.ModTime(), lines: map[string]line{}}
+	if err := s.indexLines(idx, io.TeeReader(content, snapshot)); err != nil {
+		_ = idx.close()
+		return nil, err
+	}
+	return idx, nil
+}
+
+// indexLines records the line of each key read from r into idx, and
+// validates the objects read. With Config.Invert, it records the inverted
+// entries instead.
+func (s *fileSource) indexLines(idx *index, r io.Reader) error {
+	reader := bufio.NewReader(r)
+	var offset int64
+	rows := 0
+	values := make(map[string]any)
+	for lineNum := 1; ; lineNum++ {
+		data, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("failed to read %s: %w", s.cfg.Path, err)
+		}
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 {
+			key, fields, keyErr := s.parseKey(trimmed)
+			if keyErr != nil {
+				return fmt.Errorf("failed to parse %s, line %d: %w", s.cfg.Path, lineNum, keyErr)
+			}
+			if s.cfg.Invert {
+				if keyErr = s.addValue(values, key, fields); keyErr != nil {
+					return fmt.Errorf("failed to parse %s, line %d: %w", s.cfg.Path, lineNum, keyErr)
+				}
+			} else {
+				idx.lines[key] = line{offset: offset, length: len(bytes.TrimRight(data, "\r\n"))}
+			}
+			rows++
+		}
+		offset += int64(len(data))
+		if err != nil {
+			break
+		}
+	}
+	if rows < s.cfg.MinRows {
+		return fmt.Errorf("%s holds %d objects, fewer than min_rows %d", s.cfg.Path, rows, s.cfg.MinRows)
+	}
+	if !s.cfg.Invert {
+		return nil
+	}
+
+	var collisions int
+	var err error
+	if idx.inverted, collisions, err = invert.Entries(values, s.cfg.InvertCollision); err != nil {
+		return fmt.Errorf("failed to invert %s: %w", s.cfg.Path, err)
+	}
+	if collisions > 0 && s.cfg.InvertCollision != invert.List {
+		s.logger.Warn("Values of the file are shared by several keys, inverted entries return the first key",
+			zap.String("path", s.cfg.Path), zap.Int("values", collisions))
+	}
+	return nil
+}
+
+// parseKey returns the key and the fields of the object of a line, which
+// must hold the required fields.
+func (s *fileSource) parseKey(data []byte) (string, map[string]json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return "", nil, err
+	}
+	raw, ok := fields[s.cfg.KeyField]
+	if !ok {
+		return "", nil, fmt.Errorf("missing key field %q", s.cfg.KeyField)
+	}
+	var key string
+	if err := json.Unmarshal(raw, &key); err != nil {
+		return "", nil, fmt.Errorf("key field %q must be a string", s.cfg.KeyField)
+	}
+	for _, field := range s.cfg.RequiredFields {
+		if _, ok := fields[field]; !ok {
+			return "", nil, fmt.Errorf("missing required field %q", field)
+		}
+	}
+	return key, fields, nil
+}
+
+// addValue sets the value of key in values to the value field of fields.
+// Keys whose object lacks it have no value, like when they are looked up.
+func (s *fileSource) addValue(values map[string]any, key string, fields map[string]json.RawMessage) error {
+	raw, ok := fields[s.cfg.ValueField]
+	if !ok {
+		delete(values, key)
+		return nil
+	}
+	var value any
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return err
+	}
+	values[key] = value
+	return nil
+}
+
+func (s *fileSource) lookup(ctx context.Context, key string) (any, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cachedRead(ctx, key)
+}
+
+// read reads the value of key from the file. s.mu must be held.
+func (s *fileSource) read(_ context.Context, key string) (any, bool, error) {
+	if s.index == nil {
+		return nil, false, nil
+	}
+	if s.index.inverted != nil {
+		val, found := s.index.inverted[key]
+		return val, found, nil
+	}
+	l, ok := s.index.lines[key]
+	if !ok {
+		return nil, false, nil
+	}
+	data := make([]byte, l.length)
+	if _, err := s.index.snapshot.ReadAt(data, l.offset); err != nil {
+		return nil, false, fmt.Errorf("failed to read %s: %w", s.cfg.Path, err)
+	}
+
+	var fields map[string]any
+	if err := jsonnumber.Unmarshal(data, &fields, s.cfg.JSONNumbers); err != nil {
+		return nil, false, fmt.Errorf("failed to parse %s at offset %d: %w", s.cfg.Path, l.offset, err)
+	}
+	if s.cfg.ValueField != "" {
+		val, found := fields[s.cfg.ValueField]
+		return val, found, nil
+	}
+	delete(fields, s.cfg.KeyField)
+	return fields, true, nil
+}
@@ -0,0 +1,560 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package file
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/otel/metric/noop"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/invert"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/jsonnumber"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+// writeFile writes lines to an NDJSON file in a temporary directory and
+// returns its path.
+func writeFile(t *testing.T, path string, lines ...string) string {
+	t.Helper()
+	if path == "" {
+		path = filepath.Join(t.TempDir(), "users.ndjson")
+	}
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o600))
+	return path
+}
+
+func newTestConfig(path string) *Config {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Path = path
+	return cfg
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr string
+	}{
+		{
+			name: "valid",
+		},
+		{
+			name:    "missing path",
+			modify:  func(c *Config) { c.Path = "" },
+			wantErr: "path must be specified",
+		},
+		{
+			name:    "unsupported format",
+			modify:  func(c *Config) { c.Format = "csv" },
+			wantErr: `unsupported format "csv"`,
+		},
+		{
+			name:    "missing key field",
+			modify:  func(c *Config) { c.KeyField = "" },
+			wantErr: "key_field must be specified",
+		},
+		{
+			name:    "empty required field",
+			modify:  func(c *Config) { c.RequiredFields = []string{"name", ""} },
+			wantErr: "required_fields[1] must not be empty",
+		},
+		{
+			name:    "invalid json numbers",
+			modify:  func(c *Config) { c.JSONNumbers = "decimal" },
+			wantErr: `invalid json_numbers "decimal"`,
+		},
+		{
+			name: "invert",
+			modify: func(c *Config) {
+				c.ValueField = "name"
+				c.Invert = true
+			},
+		},
+		{
+			name:    "invert without value field",
+			modify:  func(c *Config) { c.Invert = true },
+			wantErr: "invert requires value_field",
+		},
+		{
+			name:    "unsupported invert collision",
+			modify:  func(c *Config) { c.InvertCollision = "last" },
+			wantErr: `unsupported invert_collision "last"`,
+		},
+		{
+			name:    "negative min rows",
+			modify:  func(c *Config) { c.MinRows = -1 },
+			wantErr: "min_rows must not be negative",
+		},
+		{
+			name:    "negative refresh interval",
+			modify:  func(c *Config) { c.RefreshInterval = -1 },
+			wantErr: "refresh_interval must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig("users.ndjson")
+			if tt.modify != nil {
+				tt.modify(cfg)
+			}
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	path := writeFile(t, "",
+		`{"key": "user001", "name": "Alice", "age": 30}`,
+		``,
+		`{"key": "user002", "name": "Bob", "age": 41}`,
+		`{"key": "user001", "name": "Alice Smith", "age": 31}`,
+		`{"key": "user004", "name": "", "age": null}`,
+	)
+
+	tests := []struct {
+		name       string
+		valueField string
+		key        string
+		wantFound  bool
+		wantValue  any
+	}{
+		{
+			name:      "object",
+			key:       "user002",
+			wantFound: true,
+			wantValue: map[string]any{"name": "Bob", "age": float64(41)},
+		},
+		{
+			name:       "value field",
+			valueField: "name",
+			key:        "user002",
+			wantFound:  true,
+			wantValue:  "Bob",
+		},
+		{
+			name:       "later line wins",
+			valueField: "name",
+			key:        "user001",
+			wantFound:  true,
+			wantValue:  "Alice Smith",
+		},
+		{
+			name:       "empty value",
+			valueField: "name",
+			key:        "user004",
+			wantFound:  true,
+			wantValue:  "",
+		},
+		{
+			name:       "null value",
+			valueField: "age",
+			key:        "user004",
+			wantFound:  true,
+		},
+		{
+			name:       "missing value field",
+			valueField: "email",
+			key:        "user001",
+		},
+		{
+			name: "miss",
+			key:  "user003",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(path)
+			cfg.ValueField = tt.valueField
+			source, err := NewFactory().CreateSource(t.Context(), lookupsource.CreateSettings{TelemetrySettings: componenttest.NewNopTelemetrySettings()}, cfg)
+			require.NoError(t, err)
+			require.NoError(t, source.Start(t.Context(), componenttest.NewNopHost()))
+			defer func() { require.NoError(t, source.Shutdown(t.Context())) }()
+
+			val, found, err := source.Lookup(t.Context(), tt.key)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantFound, found)
+			assert.Equal(t, tt.wantValue, val)
+		})
+	}
+}
+
+func TestLookupInverted(t *testing.T) {
+	path := writeFile(t, "",
+		`{"key": "10.0.0.3", "team": "checkout"}`,
+		`{"key": "10.0.0.1", "team": "storefront"}`,
+		`{"key": "10.0.0.2", "team": "checkout"}`,
+		`{"key": "10.0.0.4"}`,
+	)
+
+	tests := []struct {
+		name         string
+		collision    invert.Collision
+		key          string
+		want         any
+		wantWarnings int
+	}{
+		{
+			name: "unique",
+			key:  "storefront",
+			want: "10.0.0.1",
+		},
+		{
+			name:         "collision first",
+			key:          "checkout",
+			want:         "10.0.0.2",
+			wantWarnings: 1,
+		},
+		{
+			name:      "collision list",
+			collision: invert.List,
+			key:       "checkout",
+			want:      []any{"10.0.0.2", "10.0.0.3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zap.WarnLevel)
+			cfg := newTestConfig(path)
+			cfg.ValueField = "team"
+			cfg.Invert = true
+			if tt.collision != "" {
+				cfg.InvertCollision = tt.collision
+			}
+			source := newSource(cfg, zap.New(core), noop.Meter{})
+			require.NoError(t, source.Start(t.Context(), componenttest.NewNopHost()))
+			defer func() { require.NoError(t, source.Shutdown(t.Context())) }()
+
+			val, found, err := source.Lookup(t.Context(), tt.key)
+			require.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, tt.want, val)
+			assert.Equal(t, tt.wantWarnings, logs.Len())
+
+			// Keys are no longer looked up.
+			_, found, err = source.Lookup(t.Context(), "10.0.0.1")
+			require.NoError(t, err)
+			assert.False(t, found)
+		})
+	}
+
+	t.Run("collision error", func(t *testing.T) {
+		cfg := newTestConfig(path)
+		cfg.ValueField = "team"
+		cfg.Invert = true
+		cfg.InvertCollision = invert.Error
+		source := newSource(cfg, zap.NewNop(), noop.Meter{})
+		assert.ErrorContains(t, source.Start(t.Context(), componenttest.NewNopHost()),
+			`failed to invert `+path+`: value "checkout" is shared by keys [10.0.0.2 10.0.0.3]`)
+	})
+}
+
+func TestLookupJSONNumbers(t *testing.T) {
+	path := writeFile(t, "", `{"key": "acct", "account_id": 9007199254740993, "ratio": 0.5}`)
+
+	tests := []struct {
+		mode jsonnumber.Mode
+		want map[string]any
+	}{
+		// float64 can't hold the account ID, which is rounded.
+		{mode: jsonnumber.Float64, want: map[string]any{"account_id": float64(9007199254740992), "ratio": 0.5}},
+		{mode: jsonnumber.Int64, want: map[string]any{"account_id": int64(9007199254740993), "ratio": 0.5}},
+		{mode: jsonnumber.String, want: map[string]any{"account_id": "9007199254740993", "ratio": "0.5"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			cfg := newTestConfig(path)
+			cfg.JSONNumbers = tt.mode
+			source, err := NewFactory().CreateSource(t.Context(), lookupsource.CreateSettings{TelemetrySettings: componenttest.NewNopTelemetrySettings()}, cfg)
+			require.NoError(t, err)
+			require.NoError(t, source.Start(t.Context(), componenttest.NewNopHost()))
+			defer func() { require.NoError(t, source.Shutdown(t.Context())) }()
+
+			val, found, err := source.Lookup(t.Context(), "acct")
+			require.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, tt.want, val)
+		})
+	}
+}
+
+func TestLookupCompressed(t *testing.T) {
+	content := `{"key": "user001", "name": "Alice"}` + "\n" + `{"key": "user002", "name": "Bob"}` + "\n"
+
+	var gzipped bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	_, err := gw.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+
+	var zstded bytes.Buffer
+	zw, err := zstd.NewWriter(&zstded)
+	require.NoError(t, err)
+	_, err = zw.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	tests := []struct {
+		name string
+		file string
+		data []byte
+	}{
+		{name: "gzip", file: "users.ndjson.gz", data: gzipped.Bytes()},
+		{name: "zstd", file: "users.ndjson.zst", data: zstded.Bytes()},
+		// Compressed files are detected without the extension too.
+		{name: "gzip without extension", file: "users.ndjson", data: gzipped.Bytes()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			require.NoError(t, os.WriteFile(path, tt.data, 0o600))
+			cfg := newTestConfig(path)
+			cfg.ValueField = "name"
+			source := newSource(cfg, zap.NewNop(), noop.Meter{})
+			require.NoError(t, source.Start(t.Context(), componenttest.NewNopHost()))
+			defer func() { require.NoError(t, source.Shutdown(t.Context())) }()
+
+			// Values are read from the decompressed snapshot.
+			for key, want := range map[string]string{"user001": "Alice", "user002": "Bob"} {
+				val, found, err := source.Lookup(t.Context(), key)
+				require.NoError(t, err)
+				assert.True(t, found)
+				assert.Equal(t, want, val)
+			}
+		})
+	}
+}
+
+func TestLookupLineEndings(t *testing.T) {
+	// Values are read at the offsets of their lines, which must account for
+	// CRLF line breaks and trailing lines without any.
+	path := writeFile(t, "",
+		`{"key": "a", "value": 1}`+"\r",
+		`{"key": "b", "value": 2}`+"\r",
+		`{"key": "c", "value": 3}`,
+	)
+	cfg := newTestConfig(path)
+	cfg.ValueField = "value"
+	source := newSource(cfg, zap.NewNop(), noop.Meter{})
+	require.NoError(t, source.Start(t.Context(), componenttest.NewNopHost()))
+	defer func() { require.NoError(t, source.Shutdown(t.Context())) }()
+
+	for key, want := range map[string]float64{"a": 1, "b": 2, "c": 3} {
+		val, found, err := source.Lookup(t.Context(), key)
+		require.NoError(t, err)
+		assert.True(t, found, key)
+		assert.Equal(t, want, val, key)
+	}
+}
+
+func TestStartError(t *testing.T) {
+	tests := []struct {
+		name    string
+		lines   []string
+		wantErr string
+	}{
+		{
+			name:    "invalid json",
+			lines:   []string{`{"key": "a", "name": "Alice"}`, `{"key": `},
+			wantErr: "line 2",
+		},
+		{
+			name:    "missing key",
+			lines:   []string{`{"name": "a"}`},
+			wantErr: `missing key field "key"`,
+		},
+		{
+			name:    "key not a string",
+			lines:   []string{`{"key": 1}`},
+			wantErr: `key field "key" must be a string`,
+		},
+		{
+			name:    "missing required field",
+			lines:   []string{`{"key": "a", "name": "Alice"}`, `{"key": "b"}`},
+			wantErr: `line 2: missing required field "name"`,
+		},
+		{
+			name:    "empty",
+			lines:   []string{""},
+			wantErr: "holds 0 objects, fewer than min_rows 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(writeFile(t, "", tt.lines...))
+			cfg.RequiredFields = []string{"name"}
+			source := newSource(cfg, zap.NewNop(), noop.Meter{})
+			assert.ErrorContains(t, source.Start(t.Context(), componenttest.NewNopHost()), tt.wantErr)
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		source := newSource(newTestConfig(filepath.Join(t.TempDir(), "missing.ndjson")), zap.NewNop(), noop.Meter{})
+		assert.ErrorContains(t, source.Start(t.Context(), componenttest.NewNopHost()), "failed to open")
+	})
+}
+
+func TestReload(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	path := writeFile(t, "", `{"key": "user001", "name": "Alice"}`)
+	cfg := newTestConfig(path)
+	cfg.ValueField = "name"
+	cfg.RefreshInterval = 10 * time.Millisecond
+	source := newSource(cfg, zap.New(core), noop.Meter{})
+	require.NoError(t, source.Start(t.Context(), componenttest.NewNopHost()))
+	defer func() { require.NoError(t, source.Shutdown(t.Context())) }()
+
+	lookup := func(key string) any {
+		val, _, err := source.Lookup(t.Context(), key)
+		require.NoError(t, err)
+		return val
+	}
+	// Cache the value of the first file.
+	assert.Equal(t, "Alice", lookup("user001"))
+
+	// The new index replaces the previous one when the file changes, and the
+	// cached values of the previous file are dropped.
+	writeFile(t, path,
+		`{"key": "user002", "name": "Bob"}`,
+		`{"key": "user001", "name": "Alice Smith"}`,
+	)
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Hour)))
+	require.Eventually(t, func() bool { return lookup("user002") == "Bob" }, 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, "Alice Smith", lookup("user001"))
+
+	// Failed reloads keep the previous index.
+	require.NoError(t, os.Remove(path))
+	require.Eventually(t, func() bool { return logs.Len() > 0 }, 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, "Bob", lookup("user002"))
+	assert.Equal(t, "Failed to reload file, keeping the previous entries", logs.All()[0].Message)
+}
+
+func TestReloadValidation(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	meter := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test")
+	reloads := func(result string) int64 {
+		var rm metricdata.ResourceMetrics
+		require.NoError(t, reader.Collect(t.Context(), &rm))
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				if m.Name != "otelcol_lookup_source_reloads" {
+					continue
+				}
+				for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+					if v, _ := dp.Attributes.Value("result"); v.AsString() == result {
+						return dp.Value
+					}
+				}
+			}
+		}
+		return 0
+	}
+
+	path := writeFile(t, "",
+		`{"key": "user001", "name": "Alice"}`,
+		`{"key": "user002", "name": "Bob"}`,
+		`{"key": "user003", "name": "Carol"}`,
+	)
+	cfg := newTestConfig(path)
+	cfg.ValueField = "name"
+	cfg.RequiredFields = []string{"name"}
+	cfg.MinRows = 2
+	cfg.RefreshInterval = 10 * time.Millisecond
+	source := newSource(cfg, zap.NewNop(), meter)
+	require.NoError(t, source.Start(t.Context(), componenttest.NewNopHost()))
+	defer func() { require.NoError(t, source.Shutdown(t.Context())) }()
+
+	lookup := func(key string) any {
+		val, _, err := source.Lookup(t.Context(), key)
+		require.NoError(t, err)
+		return val
+	}
+	modTime := time.Now()
+	rewrite := func(lines ...string) {
+		writeFile(t, path, lines...)
+		modTime = modTime.Add(time.Hour)
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+
+	// A valid file replaces the previous entries.
+	rewrite(
+		`{"key": "user001", "name": "Alice Smith"}`,
+		`{"key": "user002", "name": "Bob Jones"}`,
+	)
+	require.Eventually(t, func() bool { return reloads("success") == 1 }, 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, "Alice Smith", lookup("user001"))
+	assert.Nil(t, lookup("user003"))
+
+	// A truncated file, and one with an invalid object, are rejected, and
+	// the previous entries are still served, including ones never cached:
+	// they are read from the snapshot, not from the rewritten file.
+	rewrite(`{"key": "user001", "name": "Alice"}`)
+	require.Eventually(t, func() bool { return reloads("failure") > 0 }, 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, "Bob Jones", lookup("user002"))
+
+	rewrite(
+		`{"key": "user001", "name": "Alice"}`,
+		`{"key": "user002"}`,
+		`{"key": "user003", "name": "Carol"}`,
+	)
+	failures := reloads("failure")
+	require.Eventually(t, func() bool { return reloads("failure") > failures }, 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, "Alice Smith", lookup("user001"))
+	assert.Equal(t, int64(1), reloads("success"))
+}
+
+func TestReconfigure(t *testing.T) {
+	cfg := newTestConfig(writeFile(t, "", `{"key": "user001", "name": "Alice"}`))
+	cfg.ValueField = "name"
+	source := newSource(cfg, zap.NewNop(), noop.Meter{})
+	require.NoError(t, source.Start(t.Context(), componenttest.NewNopHost()))
+	defer func() { require.NoError(t, source.Shutdown(t.Context())) }()
+
+	lookup := func(key string) any {
+		val, _, err := source.Lookup(t.Context(), key)
+		require.NoError(t, err)
+		return val
+	}
+	assert.Equal(t, "Alice", lookup("user001"))
+
+	// A new path is indexed and replaces the previous file.
+	newCfg := newTestConfig(writeFile(t, "", `{"key": "user001", "name": "Alice Smith"}`))
+	newCfg.ValueField = "name"
+	require.NoError(t, lookupsource.Reconfigure(t.Context(), source, newCfg))
+	assert.Equal(t, "Alice Smith", lookup("user001"))
+
+	// A file that can't be indexed leaves the source unchanged.
+	brokenCfg := newTestConfig(filepath.Join(t.TempDir(), "missing.ndjson"))
+	brokenCfg.ValueField = "name"
+	require.Error(t, lookupsource.Reconfigure(t.Context(), source, brokenCfg))
+	assert.Equal(t, "Alice Smith", lookup("user001"))
+
+	// Cache settings require recreating the source.
+	cacheCfg := *newCfg
+	cacheCfg.Cache.Size = 10
+	require.ErrorIs(t, lookupsource.Reconfigure(t.Context(), source, &cacheCfg), lookupsource.ErrReconfigureUnsupported)
+}
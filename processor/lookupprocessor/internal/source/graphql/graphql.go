@@ -0,0 +1,243 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package graphql provides a lookup source running GraphQL queries against
+// an HTTP API, such as a CMDB exposing the owner of the host named by the
+// key.
+package graphql // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/graphql"
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/confighttp"
+	"go.opentelemetry.io/collector/config/configopaque"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/httpsource"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/jsonnumber"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+const (
+	sourceType = "graphql"
+
+	// keyVariable is the variable of Config.Query set to the lookup key.
+	keyVariable = "$key"
+
+	// maxResponseSize bounds the response read for a query.
+	maxResponseSize = 1 << 20
+)
+
+type Config struct {
+	// ClientConfig configures the HTTP client of the API: endpoint, timeout
+	// of a single query, TLS, headers, authentication extensions, etc.
+	// Endpoint is the URL queries are posted to, such as
+	// https://cmdb.example.com/graphql.
+	confighttp.ClientConfig `mapstructure:",squash"`
+
+	// Query is the GraphQL query run for each key, declaring a $key
+	// variable set to the key, such as
+	// query($key: String!) { host(name: $key) { owner { team } } }.
+	Query string `mapstructure:"query"`
+
+	// ResultPath is the dot-separated path of the value in the data object
+	// of the response, such as host.owner.team. Numeric segments index
+	// lists.
+	ResultPath string `mapstructure:"result_path"`
+
+	// Username and Password authenticate queries with HTTP Basic
+	// authentication.
+	Username string              `mapstructure:"username"`
+	Password configopaque.String `mapstructure:"password"`
+
+	// BearerToken authenticates queries with an Authorization: Bearer
+	// header. It can't be used with Username.
+	BearerToken configopaque.String `mapstructure:"bearer_token"`
+
+	Cache lookupsource.CacheConfig `mapstructure:"cache"`
+}
+
+func (c *Config) Validate() error {
+	var errs lookupsource.FieldErrors
+	if c.Endpoint == "" {
+		errs.Addf("endpoint", "must be specified")
+	}
+	if c.Query == "" {
+		errs.Addf("query", "must be specified")
+	} else if !strings.Contains(c.Query, keyVariable) {
+		errs.Addf("query", "must use the %s variable", keyVariable)
+	}
+	if _, err := parsePath(c.ResultPath); err != nil {
+		errs.Add("result_path", err)
+	}
+	if c.Password != "" && c.Username == "" {
+		errs.Addf("password", "requires username")
+	}
+	if c.BearerToken != "" && c.Username != "" {
+		errs.Addf("bearer_token", "cannot be used with username, set only one of them")
+	}
+	if c.Timeout < 0 {
+		errs.Addf("timeout", "must not be negative, got %s", c.Timeout)
+	}
+	return errs.Err()
+}
+
+func NewFactory() lookupsource.SourceFactory {
+	return lookupsource.NewSourceFactory(
+		sourceType,
+		createDefaultConfig,
+		createSource,
+	)
+}
+
+func createDefaultConfig() lookupsource.SourceConfig {
+	clientConfig := confighttp.NewDefaultClientConfig()
+	clientConfig.Timeout = 5 * time.Second
+	return &Config{
+		ClientConfig: clientConfig,
+		Cache: lookupsource.CacheConfig{
+			Enabled: true,
+			Size:    10000,
+			TTL:     5 * time.Minute,
+		},
+	}
+}
+
+func createSource(
+	_ context.Context,
+	settings lookupsource.CreateSettings,
+	cfg lookupsource.SourceConfig,
+) (lookupsource.Source, error) {
+	return newSource(cfg.(*Config), settings.TelemetrySettings), nil
+}
+
+func newSource(cfg *Config, telemetry component.TelemetrySettings) lookupsource.Source {
+	// Validate rejected invalid paths.
+	path, _ := parsePath(cfg.ResultPath)
+	s := &graphqlSource{
+		cfg:    cfg,
+		path:   path,
+		client: httpsource.NewClient(sourceType, &cfg.ClientConfig, telemetry),
+	}
+	return httpsource.NewSource(s.client, cfg.Cache, s.lookup)
+}
+
+type graphqlSource struct {
+	cfg    *Config
+	path   []string
+	client *httpsource.Client
+}
+
+// request is the body of a GraphQL query over HTTP.
+type request struct {
+	Query     string         `json:"query"`
+	Variables map[string]any `json:"variables"`
+}
+
+// response is the body of the answer to a GraphQL query.
+type response struct {
+	Data   map[string]any `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// lookup runs the query with key as its $key variable and returns the value
+// at the result path of the data it answers. A null or missing value is not
+// found.
+func (s *graphqlSource) lookup(ctx context.Context, key string) (any, bool, error) {
+	body, err := json.Marshal(request{
+		Query:     s.cfg.Query,
+		Variables: map[string]any{strings.TrimPrefix(keyVariable, "$"): key},
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to encode graphql request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create graphql request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/graphql-response+json, application/json")
+	switch {
+	case s.cfg.Username != "":
+		req.SetBasicAuth(s.cfg.Username, string(s.cfg.Password))
+	case s.cfg.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+string(s.cfg.BearerToken))
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("graphql query failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result response
+	decoder := httpsource.NewDecoder(resp, maxResponseSize)
+	decoder.UseNumber()
+	if err := decoder.Decode(&result); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, false, fmt.Errorf("graphql query failed: %s", resp.Status)
+		}
+		return nil, false, fmt.Errorf("failed to decode graphql response: %w", err)
+	}
+	if len(result.Errors) > 0 {
+		messages := make([]string, len(result.Errors))
+		for i, e := range result.Errors {
+			messages[i] = e.Message
+		}
+		return nil, false, fmt.Errorf("graphql query failed: %s", strings.Join(messages, "; "))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("graphql query failed: %s", resp.Status)
+	}
+
+	val := resolvePath(result.Data, s.path)
+	if val == nil {
+		return nil, false, nil
+	}
+	// Integers, such as ports, keep their integer type.
+	return jsonnumber.Convert(val, jsonnumber.Int64), true, nil
+}
+
+// parsePath splits a result path into its segments.
+func parsePath(path string) ([]string, error) {
+	if path == "" {
+		return nil, errors.New("must be specified")
+	}
+	segments := strings.Split(path, ".")
+	for _, segment := range segments {
+		if segment == "" {
+			return nil, fmt.Errorf("invalid path %q, segments must not be empty", path)
+		}
+	}
+	return segments, nil
+}
+
+// resolvePath returns the value at path in data, or nil if a segment is
+// missing or null.
+func resolvePath(data map[string]any, path []string) any {
+	var val any = data
+	for _, segment := range path {
+		switch v := val.(type) {
+		case map[string]any:
+			val = v[segment]
+		case []any:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil
+			}
+			val = v[i]
+		default:
+			return nil
+		}
+	}
+	return val
+}
@@ -0,0 +1,239 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package graphql
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/httpsource"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/sourcetest"
+)
+
+const testQuery = `query($key: String!) { host(name: $key) { owner { team } tags ports } }`
+
+// newTestServer answers queries with the response of their key variable, or
+// with data holding a null host for unknown keys. Requests without the
+// Authorization header auth, if set, are rejected.
+func newTestServer(t *testing.T, auth string, responses map[string]string) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	var requests atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if auth != "" && r.Header.Get("Authorization") != auth {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var req request
+		if !assert.NoError(t, json.NewDecoder(r.Body).Decode(&req)) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		assert.Equal(t, testQuery, req.Query)
+		w.Header().Set("Content-Type", "application/graphql-response+json")
+		if resp, ok := responses[req.Variables["key"].(string)]; ok {
+			_, _ = w.Write([]byte(resp))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data": {"host": null}}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func newTestConfig(endpoint, path string) *Config {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Endpoint = endpoint
+	cfg.Query = testQuery
+	cfg.ResultPath = path
+	return cfg
+}
+
+func TestConfigValidate(t *testing.T) {
+	sourcetest.RunValidateTests(t, func() *Config { return newTestConfig("http://cmdb.example.com/graphql", "host.owner.team") }, []sourcetest.ValidateTest[*Config]{
+		{
+			Name: "valid",
+		},
+		{
+			Name: "basic auth",
+			Modify: func(c *Config) {
+				c.Username = "otel"
+				c.Password = "secret"
+			},
+		},
+		{
+			Name:   "bearer token",
+			Modify: func(c *Config) { c.BearerToken = "token" },
+		},
+		{
+			Name:    "missing endpoint",
+			Modify:  func(c *Config) { c.Endpoint = "" },
+			WantErr: "endpoint: must be specified",
+		},
+		{
+			Name:    "missing query",
+			Modify:  func(c *Config) { c.Query = "" },
+			WantErr: "query: must be specified",
+		},
+		{
+			Name:    "query without key variable",
+			Modify:  func(c *Config) { c.Query = `{ host(name: "web") { owner } }` },
+			WantErr: "query: must use the $key variable",
+		},
+		{
+			Name:    "missing result path",
+			Modify:  func(c *Config) { c.ResultPath = "" },
+			WantErr: "result_path: must be specified",
+		},
+		{
+			Name:    "empty path segment",
+			Modify:  func(c *Config) { c.ResultPath = "host..team" },
+			WantErr: `result_path: invalid path "host..team", segments must not be empty`,
+		},
+		{
+			Name:    "password without username",
+			Modify:  func(c *Config) { c.Password = "secret" },
+			WantErr: "password: requires username",
+		},
+		{
+			Name: "bearer token with username",
+			Modify: func(c *Config) {
+				c.Username = "otel"
+				c.BearerToken = "token"
+			},
+			WantErr: "bearer_token: cannot be used with username",
+		},
+		{
+			Name:    "negative timeout",
+			Modify:  func(c *Config) { c.Timeout = -1 },
+			WantErr: "timeout: must not be negative",
+		},
+	})
+}
+
+func TestLookup(t *testing.T) {
+	srv, _ := newTestServer(t, "", map[string]string{
+		"web-1": `{"data": {"host": {"owner": {"team": "storefront"}, "tags": ["prod", "eu"], "ports": [80, 443]}}}`,
+		"db-1":  `{"data": {"host": {"owner": null, "tags": [], "ports": [5432]}}}`,
+		"lab-1": `{"data": {"host": {"owner": {"team": ""}, "tags": ["lab"], "ports": [8080.5]}}}`,
+		"bad-1": `{"data": {"host": null}, "errors": [{"message": "permission denied", "path": ["host"]}, {"message": "quota exceeded"}]}`,
+		"bad-2": `not json`,
+	})
+
+	tests := []struct {
+		name      string
+		path      string
+		key       string
+		want      any
+		wantFound bool
+		wantErr   string
+	}{
+		{name: "found", path: "host.owner.team", key: "web-1", want: "storefront", wantFound: true},
+		{name: "empty value", path: "host.owner.team", key: "lab-1", want: "", wantFound: true},
+		{name: "list element", path: "host.tags.1", key: "web-1", want: "eu", wantFound: true},
+		{name: "integer", path: "host.ports.0", key: "web-1", want: int64(80), wantFound: true},
+		{name: "float", path: "host.ports.0", key: "lab-1", want: 8080.5, wantFound: true},
+		{name: "list", path: "host.ports", key: "web-1", want: []any{int64(80), int64(443)}, wantFound: true},
+		{name: "object", path: "host.owner", key: "web-1", want: map[string]any{"team": "storefront"}, wantFound: true},
+		{name: "null object", path: "host.owner.team", key: "db-1"},
+		{name: "null result", path: "host.owner.team", key: "unknown"},
+		{name: "index out of range", path: "host.tags.0", key: "db-1"},
+		{name: "missing field", path: "host.location", key: "web-1"},
+		{name: "errors", path: "host.owner.team", key: "bad-1", wantErr: "graphql query failed: permission denied; quota exceeded"},
+		{name: "invalid response", path: "host.owner.team", key: "bad-2", wantErr: "failed to decode graphql response"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := sourcetest.CreateAndStart(t, NewFactory(), newTestConfig(srv.URL, tt.path))
+			val, found, err := source.Lookup(t.Context(), tt.key)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				assert.False(t, found)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantFound, found)
+			assert.Equal(t, tt.want, val)
+		})
+	}
+}
+
+func TestLookupAuth(t *testing.T) {
+	tests := []struct {
+		name    string
+		auth    string
+		modify  func(*Config)
+		wantErr string
+	}{
+		{
+			name: "basic",
+			auth: "Basic b3RlbDpzZWNyZXQ=",
+			modify: func(c *Config) {
+				c.Username = "otel"
+				c.Password = "secret"
+			},
+		},
+		{
+			name:   "bearer",
+			auth:   "Bearer token",
+			modify: func(c *Config) { c.BearerToken = "token" },
+		},
+		{
+			name:    "missing",
+			auth:    "Bearer token",
+			modify:  func(*Config) {},
+			wantErr: "graphql query failed: 401 Unauthorized",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := newTestServer(t, tt.auth, map[string]string{
+				"web-1": `{"data": {"host": {"owner": {"team": "storefront"}}}}`,
+			})
+			cfg := newTestConfig(srv.URL, "host.owner.team")
+			tt.modify(cfg)
+			source := sourcetest.CreateAndStart(t, NewFactory(), cfg)
+
+			val, found, err := source.Lookup(t.Context(), "web-1")
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, "storefront", val)
+		})
+	}
+}
+
+func TestLookupCache(t *testing.T) {
+	srv, requests := newTestServer(t, "", map[string]string{
+		"web-1": `{"data": {"host": {"owner": {"team": "storefront"}}}}`,
+	})
+	source := sourcetest.CreateAndStart(t, NewFactory(), newTestConfig(srv.URL, "host.owner.team"))
+
+	for range 3 {
+		val, found, err := source.Lookup(t.Context(), "web-1")
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "storefront", val)
+	}
+	assert.Equal(t, int64(1), requests.Load())
+}
+
+func TestLookupNotStarted(t *testing.T) {
+	source := sourcetest.Create(t, NewFactory(), newTestConfig("http://localhost", "host"))
+	_, _, err := source.Lookup(t.Context(), "web-1")
+	assert.ErrorIs(t, err, httpsource.ErrNotStarted)
+}
@@ -0,0 +1,167 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package grpc provides a lookup source calling a remote gRPC lookup service.
+//
+// The service is defined in lookup.proto. Its messages are the well-known
+// google.protobuf.StringValue wrapper, so servers can be written without
+// generating code from this package: the key is sent as the request value,
+// and unknown keys are answered with the NOT_FOUND status code.
+package grpc // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/grpc"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configgrpc"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+const (
+	sourceType = "grpc"
+
+	// lookupMethod is the full method name of LookupService.Lookup.
+	lookupMethod = "/opentelemetry.collector.lookup.v1.LookupService/Lookup"
+)
+
+var (
+	errMissingEndpoint = errors.New("endpoint must be specified")
+	errNotStarted      = errors.New("grpc source not started")
+)
+
+type Config struct {
+	// ClientConfig configures the connection to the lookup service:
+	// endpoint, TLS, headers sent with every call, authentication, etc.
+	configgrpc.ClientConfig `mapstructure:",squash"`
+
+	// Timeout bounds the duration of a single Lookup call.
+	// Default: 5s
+	Timeout time.Duration `mapstructure:"timeout"`
+
+	Cache lookupsource.CacheConfig `mapstructure:"cache"`
+}
+
+func (c *Config) Validate() error {
+	if c.Endpoint == "" {
+		return errMissingEndpoint
+	}
+	if c.Timeout < 0 {
+		return errors.New("timeout must not be negative")
+	}
+	return nil
+}
+
+func NewFactory() lookupsource.SourceFactory {
+	return lookupsource.NewSourceFactory(
+		sourceType,
+		createDefaultConfig,
+		createSource,
+	)
+}
+
+func createDefaultConfig() lookupsource.SourceConfig {
+	return &Config{
+		ClientConfig: configgrpc.NewDefaultClientConfig(),
+		Timeout:      5 * time.Second,
+		Cache: lookupsource.CacheConfig{
+			Enabled: true,
+			Size:    10000,
+			TTL:     5 * time.Minute,
+		},
+	}
+}
+
+func createSource(
+	_ context.Context,
+	settings lookupsource.CreateSettings,
+	cfg lookupsource.SourceConfig,
+) (lookupsource.Source, error) {
+	return newSource(cfg.(*Config), settings.TelemetrySettings), nil
+}
+
+// newSource returns a source dialing with the extra dial options, which
+// tests use to connect to an in-process server.
+func newSource(cfg *Config, telemetry component.TelemetrySettings, dialOpts ...configgrpc.ToClientConnOption) lookupsource.Source {
+	cache := lookupsource.NewCache(cfg.Cache)
+	s := &grpcSource{cfg: cfg, telemetry: telemetry, dialOpts: dialOpts, cache: cache}
+
+	lookup := lookupsource.WrapWithCache(cache, s.lookup)
+
+	return lookupsource.NewSource(
+		lookup,
+		func() string { return sourceType },
+		s.start,
+		s.shutdown,
+		lookupsource.WithCache(cache),
+	)
+}
+
+type grpcSource struct {
+	cfg       *Config
+	telemetry component.TelemetrySettings
+	dialOpts  []configgrpc.ToClientConnOption
+
+	// conn is shared by all lookups; gRPC multiplexes concurrent calls on
+	// it. md holds the configured headers.
+	conn *grpclib.ClientConn
+	md   metadata.MD
+
+	cache *lookupsource.Cache
+}
+
+// start creates the client connection. It is deferred until start because
+// authentication extensions are only available from the host.
+func (s *grpcSource) start(ctx context.Context, host component.Host) error {
+	conn, err := s.cfg.ToClientConn(ctx, host.GetExtensions(), s.telemetry, s.dialOpts...)
+	if err != nil {
+		return fmt.Errorf("failed to create gRPC client: %w", err)
+	}
+	headers := map[string]string{}
+	for k, v := range s.cfg.Headers.Iter {
+		headers[k] = string(v)
+	}
+	s.conn = conn
+	s.md = metadata.New(headers)
+	return nil
+}
+
+func (s *grpcSource) shutdown(ctx context.Context) error {
+	var err error
+	if s.conn != nil {
+		err = s.conn.Close()
+		s.conn = nil
+	}
+	return errors.Join(err, s.cache.Shutdown(ctx))
+}
+
+func (s *grpcSource) lookup(ctx context.Context, key string) (any, bool, error) {
+	if s.conn == nil {
+		return nil, false, errNotStarted
+	}
+
+	if s.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.cfg.Timeout)
+		defer cancel()
+	}
+	ctx = metadata.NewOutgoingContext(ctx, s.md)
+
+	resp := &wrapperspb.StringValue{}
+	err := s.conn.Invoke(ctx, lookupMethod, wrapperspb.String(key), resp, grpclib.WaitForReady(s.cfg.WaitForReady))
+	if status.Code(err) == codes.NotFound {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("grpc lookup failed: %w", err)
+	}
+	return resp.GetValue(), true, nil
+}
@@ -0,0 +1,182 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config/configgrpc"
+	"go.opentelemetry.io/collector/config/configopaque"
+	"go.opentelemetry.io/collector/config/configtls"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+// lookupServer implements LookupService from a map, or fails every call
+// with err if set. It records the metadata of the last call.
+type lookupServer struct {
+	entries map[string]string
+	err     error
+	md      metadata.MD
+}
+
+func (s *lookupServer) lookup(ctx context.Context, key string) (string, error) {
+	s.md, _ = metadata.FromIncomingContext(ctx)
+	if s.err != nil {
+		return "", s.err
+	}
+	value, ok := s.entries[key]
+	if !ok {
+		return "", status.Errorf(codes.NotFound, "unknown key %q", key)
+	}
+	return value, nil
+}
+
+// lookupServiceDesc registers a lookupServer the way generated code for
+// lookup.proto would.
+var lookupServiceDesc = grpclib.ServiceDesc{
+	ServiceName: "opentelemetry.collector.lookup.v1.LookupService",
+	HandlerType: (*any)(nil),
+	Methods: []grpclib.MethodDesc{{
+		MethodName: "Lookup",
+		Handler: func(srv any, ctx context.Context, dec func(any) error, _ grpclib.UnaryServerInterceptor) (any, error) {
+			req := &wrapperspb.StringValue{}
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			value, err := srv.(*lookupServer).lookup(ctx, req.GetValue())
+			if err != nil {
+				return nil, err
+			}
+			return wrapperspb.String(value), nil
+		},
+	}},
+}
+
+// startServer serves srv on an in-memory listener and returns a started
+// source connected to it.
+func startServer(t *testing.T, srv *lookupServer, modify func(*Config)) (lookupsource.Source, *grpclib.Server) {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	server := grpclib.NewServer()
+	server.RegisterService(&lookupServiceDesc, srv)
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.Endpoint = "passthrough:///bufnet"
+	cfg.TLS = configtls.ClientConfig{Insecure: true}
+	cfg.Cache.Enabled = false
+	if modify != nil {
+		modify(cfg)
+	}
+	require.NoError(t, cfg.Validate())
+
+	dialer := configgrpc.WithGrpcDialOption(grpclib.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return lis.DialContext(ctx)
+	}))
+	source := newSource(cfg, componenttest.NewNopTelemetrySettings(), dialer)
+	require.NoError(t, source.Start(t.Context(), componenttest.NewNopHost()))
+	t.Cleanup(func() { assert.NoError(t, source.Shutdown(context.Background())) })
+	return source, server
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr string
+	}{
+		{
+			name:   "valid",
+			modify: func(c *Config) { c.Endpoint = "enrichment:4317" },
+		},
+		{
+			name:    "missing endpoint",
+			wantErr: "endpoint must be specified",
+		},
+		{
+			name: "negative timeout",
+			modify: func(c *Config) {
+				c.Endpoint = "enrichment:4317"
+				c.Timeout = -1
+			},
+			wantErr: "timeout must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := createDefaultConfig().(*Config)
+			if tt.modify != nil {
+				tt.modify(cfg)
+			}
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	srv := &lookupServer{entries: map[string]string{"10.0.0.1": "web-1"}}
+	source, _ := startServer(t, srv, func(cfg *Config) {
+		cfg.Headers.Set("x-api-key", configopaque.String("secret"))
+	})
+
+	val, found, err := source.Lookup(t.Context(), "10.0.0.1")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "web-1", val)
+	assert.Equal(t, []string{"secret"}, srv.md.Get("x-api-key"))
+
+	val, found, err = source.Lookup(t.Context(), "10.0.0.2")
+	require.NoError(t, err)
+	assert.False(t, found)
+	assert.Nil(t, val)
+}
+
+func TestLookupStatusError(t *testing.T) {
+	srv := &lookupServer{err: status.Error(codes.PermissionDenied, "denied")}
+	source, _ := startServer(t, srv, nil)
+
+	_, found, err := source.Lookup(t.Context(), "10.0.0.1")
+	assert.False(t, found)
+	assert.ErrorContains(t, err, "grpc lookup failed")
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestLookupTransportError(t *testing.T) {
+	source, server := startServer(t, &lookupServer{}, func(cfg *Config) { cfg.Timeout = time.Second })
+	server.Stop()
+
+	_, found, err := source.Lookup(t.Context(), "10.0.0.1")
+	assert.False(t, found)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+func TestLookupBeforeStart(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Endpoint = "enrichment:4317"
+	source := newSource(cfg, componenttest.NewNopTelemetrySettings())
+
+	_, _, err := source.Lookup(t.Context(), "10.0.0.1")
+	assert.ErrorIs(t, err, errNotStarted)
+}
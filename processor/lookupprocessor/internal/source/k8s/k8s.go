@@ -0,0 +1,316 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package k8s provides a lookup source that resolves pod IPs to Kubernetes
+// pod metadata.
+//
+// A Pod informer keeps an in-memory IP index up to date, so lookups never
+// call the Kubernetes API. Pods using the host network share their node's IP
+// and cannot be attributed reliably, so they are not indexed.
+package k8s // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/k8s"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	k8s "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/internal/k8sconfig"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+const (
+	sourceType = "k8s"
+
+	defaultResyncPeriod = 5 * time.Minute
+)
+
+// Keys of the metadata map returned by lookups.
+const (
+	podNameKey      = "k8s.pod.name"
+	podUIDKey       = "k8s.pod.uid"
+	namespaceKey    = "k8s.namespace.name"
+	nodeNameKey     = "k8s.node.name"
+	podLabelsKey    = "k8s.pod.labels"
+	podOwnerKindKey = "k8s.pod.owner.kind"
+	podOwnerNameKey = "k8s.pod.owner.name"
+)
+
+type Config struct {
+	k8sconfig.APIConfig `mapstructure:",squash"`
+
+	// Namespace restricts the watched pods to a single namespace.
+	// Default: "" (all namespaces)
+	Namespace string `mapstructure:"namespace"`
+
+	// ResyncPeriod is how often the informer replays its full state.
+	// Default: 5m
+	ResyncPeriod time.Duration `mapstructure:"resync_period"`
+
+	// For mocking
+	makeClient func(apiConf k8sconfig.APIConfig) (k8s.Interface, error)
+}
+
+func (c *Config) Validate() error {
+	if c.ResyncPeriod < 0 {
+		return fmt.Errorf("resync_period must not be negative, got %s", c.ResyncPeriod)
+	}
+	return c.APIConfig.Validate()
+}
+
+func (c *Config) getK8sClient() (k8s.Interface, error) {
+	if c.makeClient == nil {
+		c.makeClient = k8sconfig.MakeClient
+	}
+	return c.makeClient(c.APIConfig)
+}
+
+func NewFactory() lookupsource.SourceFactory {
+	return lookupsource.NewSourceFactory(
+		sourceType,
+		createDefaultConfig,
+		createSource,
+	)
+}
+
+func createDefaultConfig() lookupsource.SourceConfig {
+	return &Config{
+		APIConfig: k8sconfig.APIConfig{
+			AuthType: k8sconfig.AuthTypeServiceAccount,
+		},
+		ResyncPeriod: defaultResyncPeriod,
+	}
+}
+
+func createSource(
+	_ context.Context,
+	_ lookupsource.CreateSettings,
+	cfg lookupsource.SourceConfig,
+) (lookupsource.Source, error) {
+	s := newPodIndex(cfg.(*Config))
+
+	return lookupsource.NewSource(
+		s.lookup,
+		func() string { return sourceType },
+		s.start,
+		s.shutdown,
+		lookupsource.WithReadiness(s.ready),
+		lookupsource.WithChanges(&s.changes),
+	), nil
+}
+
+// podIndex maintains an IP to pod metadata index fed by a Pod informer.
+type podIndex struct {
+	cfg *Config
+
+	mu    sync.RWMutex
+	byIP  map[string]indexedPod
+	ipsOf map[types.UID][]string
+	// changes reports the updates of byIP.
+	changes lookupsource.Changes
+
+	factory informers.SharedInformerFactory
+	stopCh  chan struct{}
+	// synced reports whether the event handler received the initial list
+	// of pods.
+	synced cache.InformerSynced
+}
+
+type indexedPod struct {
+	uid      types.UID
+	metadata map[string]any
+}
+
+func newPodIndex(cfg *Config) *podIndex {
+	return &podIndex{
+		cfg:   cfg,
+		byIP:  make(map[string]indexedPod),
+		ipsOf: make(map[types.UID][]string),
+	}
+}
+
+func (p *podIndex) start(_ context.Context, _ component.Host) error {
+	client, err := p.cfg.getK8sClient()
+	if err != nil {
+		return fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+
+	var opts []informers.SharedInformerOption
+	if p.cfg.Namespace != "" {
+		opts = append(opts, informers.WithNamespace(p.cfg.Namespace))
+	}
+	p.factory = informers.NewSharedInformerFactoryWithOptions(client, p.cfg.ResyncPeriod, opts...)
+
+	informer := p.factory.Core().V1().Pods().Informer()
+	registration, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    p.onAdd,
+		UpdateFunc: p.onUpdate,
+		DeleteFunc: p.onDelete,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to register pod event handler: %w", err)
+	}
+	p.synced = registration.HasSynced
+
+	p.stopCh = make(chan struct{})
+	p.factory.Start(p.stopCh)
+	return nil
+}
+
+func (p *podIndex) shutdown(_ context.Context) error {
+	if p.stopCh == nil {
+		return nil
+	}
+	close(p.stopCh)
+	p.factory.Shutdown()
+	p.stopCh = nil
+	return nil
+}
+
+// ready waits until the index holds the pods listed when the informer
+// started, so that lookups don't miss pods that were already running.
+func (p *podIndex) ready(ctx context.Context) error {
+	if p.synced == nil {
+		return errors.New("k8s source not started")
+	}
+	if !cache.WaitForCacheSync(ctx.Done(), p.synced) {
+		return fmt.Errorf("pod informer not synced: %w", ctx.Err())
+	}
+	return nil
+}
+
+func (p *podIndex) lookup(_ context.Context, ip string) (any, bool, error) {
+	p.mu.RLock()
+	entry, found := p.byIP[ip]
+	p.mu.RUnlock()
+	if !found {
+		return nil, false, nil
+	}
+	return entry.metadata, true, nil
+}
+
+func (p *podIndex) onAdd(obj any) {
+	if pod, ok := obj.(*corev1.Pod); ok {
+		p.upsert(pod)
+	}
+}
+
+func (p *podIndex) onUpdate(_, newObj any) {
+	if pod, ok := newObj.(*corev1.Pod); ok {
+		p.upsert(pod)
+	}
+}
+
+func (p *podIndex) onDelete(obj any) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	if pod, ok := obj.(*corev1.Pod); ok {
+		p.mu.Lock()
+		p.removeLocked(pod.UID)
+		p.mu.Unlock()
+		p.changes.Notify()
+	}
+}
+
+func (p *podIndex) upsert(pod *corev1.Pod) {
+	p.mu.Lock()
+	defer p.changes.Notify()
+	defer p.mu.Unlock()
+
+	p.removeLocked(pod.UID)
+
+	// Host network pods report the node IP, which is shared by every other
+	// host network pod on that node.
+	if pod.Spec.HostNetwork {
+		return
+	}
+
+	ips := podIPs(pod)
+	if len(ips) == 0 {
+		return
+	}
+
+	entry := indexedPod{uid: pod.UID, metadata: podMetadata(pod)}
+	for _, ip := range ips {
+		// IPs are recycled; the most recently observed pod owns the IP.
+		if prev, ok := p.byIP[ip]; ok && prev.uid != pod.UID {
+			p.ipsOf[prev.uid] = removeIP(p.ipsOf[prev.uid], ip)
+		}
+		p.byIP[ip] = entry
+	}
+	p.ipsOf[pod.UID] = ips
+}
+
+func (p *podIndex) removeLocked(uid types.UID) {
+	for _, ip := range p.ipsOf[uid] {
+		if entry, ok := p.byIP[ip]; ok && entry.uid == uid {
+			delete(p.byIP, ip)
+		}
+	}
+	delete(p.ipsOf, uid)
+}
+
+func podIPs(pod *corev1.Pod) []string {
+	var ips []string
+	seen := make(map[string]struct{})
+	add := func(ip string) {
+		if ip == "" {
+			return
+		}
+		if _, ok := seen[ip]; ok {
+			return
+		}
+		seen[ip] = struct{}{}
+		ips = append(ips, ip)
+	}
+
+	add(pod.Status.PodIP)
+	for _, podIP := range pod.Status.PodIPs {
+		add(podIP.IP)
+	}
+	return ips
+}
+
+func removeIP(ips []string, ip string) []string {
+	for i, v := range ips {
+		if v == ip {
+			return append(ips[:i], ips[i+1:]...)
+		}
+	}
+	return ips
+}
+
+func podMetadata(pod *corev1.Pod) map[string]any {
+	md := map[string]any{
+		podNameKey:   pod.Name,
+		podUIDKey:    string(pod.UID),
+		namespaceKey: pod.Namespace,
+	}
+	if pod.Spec.NodeName != "" {
+		md[nodeNameKey] = pod.Spec.NodeName
+	}
+	if len(pod.Labels) > 0 {
+		labels := make(map[string]any, len(pod.Labels))
+		for k, v := range pod.Labels {
+			labels[k] = v
+		}
+		md[podLabelsKey] = labels
+	}
+	for _, ref := range pod.OwnerReferences {
+		if ref.Controller != nil && *ref.Controller {
+			md[podOwnerKindKey] = ref.Kind
+			md[podOwnerNameKey] = ref.Name
+			break
+		}
+	}
+	return md
+}
@@ -0,0 +1,165 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	k8s "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/internal/k8sconfig"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+func newPod(name, uid, ip string) *corev1.Pod {
+	controller := true
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			UID:       types.UID(uid),
+			Labels:    map[string]string{"app": name},
+			OwnerReferences: []metav1.OwnerReference{
+				{Kind: "ReplicaSet", Name: name + "-rs", Controller: &controller},
+			},
+		},
+		Spec: corev1.PodSpec{NodeName: "node-1"},
+		Status: corev1.PodStatus{
+			PodIP:  ip,
+			PodIPs: []corev1.PodIP{{IP: ip}},
+		},
+	}
+}
+
+func startSource(t *testing.T, client k8s.Interface) lookupsource.Source {
+	t.Helper()
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.makeClient = func(k8sconfig.APIConfig) (k8s.Interface, error) {
+		return client, nil
+	}
+
+	source, err := NewFactory().CreateSource(t.Context(), lookupsource.CreateSettings{}, cfg)
+	require.NoError(t, err)
+	require.NoError(t, source.Start(t.Context(), componenttest.NewNopHost()))
+	t.Cleanup(func() {
+		assert.NoError(t, source.Shutdown(context.Background()))
+	})
+	return source
+}
+
+func found(t *testing.T, source lookupsource.Source, ip string) bool {
+	_, ok, err := source.Lookup(t.Context(), ip)
+	return ok && err == nil
+}
+
+func TestPodLifecycle(t *testing.T) {
+	client := fake.NewClientset()
+	source := startSource(t, client)
+	pods := client.CoreV1().Pods("default")
+
+	_, err := pods.Create(t.Context(), newPod("web", "uid-1", "10.1.0.5"), metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		return found(t, source, "10.1.0.5")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	val, _, err := source.Lookup(t.Context(), "10.1.0.5")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		podNameKey:      "web",
+		podUIDKey:       "uid-1",
+		namespaceKey:    "default",
+		nodeNameKey:     "node-1",
+		podLabelsKey:    map[string]any{"app": "web"},
+		podOwnerKindKey: "ReplicaSet",
+		podOwnerNameKey: "web-rs",
+	}, val)
+
+	// An update that changes the pod IP moves the index entry.
+	updated := newPod("web", "uid-1", "10.1.0.6")
+	_, err = pods.Update(t.Context(), updated, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		return !found(t, source, "10.1.0.5") && found(t, source, "10.1.0.6")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, pods.Delete(t.Context(), "web", metav1.DeleteOptions{}))
+
+	assert.Eventually(t, func() bool {
+		return !found(t, source, "10.1.0.6")
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestHostNetworkPodsNotIndexed(t *testing.T) {
+	hostPod := newPod("node-exporter", "uid-host", "192.168.1.10")
+	hostPod.Spec.HostNetwork = true
+	regularPod := newPod("web", "uid-web", "10.1.0.7")
+
+	client := fake.NewClientset(hostPod, regularPod)
+	source := startSource(t, client)
+
+	assert.Eventually(t, func() bool {
+		return found(t, source, "10.1.0.7")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.False(t, found(t, source, "192.168.1.10"))
+}
+
+func TestReady(t *testing.T) {
+	client := fake.NewClientset(newPod("web", "uid-web", "10.1.0.9"))
+	source := startSource(t, client)
+
+	// Pods running when the source started are found once it is ready.
+	require.NoError(t, lookupsource.WaitReady(t.Context(), source))
+	assert.True(t, found(t, source, "10.1.0.9"))
+
+	notStarted := newPodIndex(createDefaultConfig().(*Config))
+	assert.EqualError(t, notStarted.ready(t.Context()), "k8s source not started")
+}
+
+func TestRecycledIP(t *testing.T) {
+	idx := newPodIndex(createDefaultConfig().(*Config))
+
+	oldPod := newPod("old", "uid-old", "10.1.0.8")
+	newPodSameIP := newPod("new", "uid-new", "10.1.0.8")
+
+	idx.onAdd(oldPod)
+	idx.onAdd(newPodSameIP)
+
+	val, ok, err := idx.lookup(t.Context(), "10.1.0.8")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "new", val.(map[string]any)[podNameKey])
+
+	// Deleting the old pod must not remove the entry now owned by the new pod.
+	idx.onDelete(oldPod)
+	val, ok, err = idx.lookup(t.Context(), "10.1.0.8")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "new", val.(map[string]any)[podNameKey])
+}
+
+func TestConfigValidate(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	assert.NoError(t, cfg.Validate())
+
+	cfg.ResyncPeriod = -time.Second
+	assert.ErrorContains(t, cfg.Validate(), "resync_period")
+
+	cfg = createDefaultConfig().(*Config)
+	cfg.AuthType = "invalid"
+	assert.Error(t, cfg.Validate())
+}
@@ -0,0 +1,186 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package memcached provides a lookup source reading values from memcached.
+//
+// Each lookup key is turned into a memcached key with the configured key
+// template and fetched with a GET. Missing keys are not found; the stored
+// bytes are used as the lookup value otherwise.
+package memcached // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/memcached"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"go.opentelemetry.io/collector/component"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+const (
+	sourceType = "memcached"
+
+	// keyPlaceholder is replaced by the lookup key in Config.KeyTemplate.
+	keyPlaceholder = "{key}"
+)
+
+var (
+	errMissingServers = errors.New("servers must be specified")
+	errNotStarted     = errors.New("memcached source not started")
+)
+
+type Config struct {
+	// Servers are the addresses ("host:port") of the memcached servers.
+	// Keys are distributed across them like other memcached clients do.
+	Servers []string `mapstructure:"servers"`
+
+	// KeyTemplate builds the memcached key from the lookup key, which
+	// replaces every "{key}" in it, such as "hosts:{key}".
+	// Default: {key}
+	KeyTemplate string `mapstructure:"key_template"`
+
+	// Timeout bounds the duration of a single request to a server.
+	// Default: 1s
+	Timeout time.Duration `mapstructure:"timeout"`
+
+	// MaxIdleConns is the number of idle connections kept per server.
+	// Default: 2
+	MaxIdleConns int `mapstructure:"max_idle_conns"`
+
+	Cache lookupsource.CacheConfig `mapstructure:"cache"`
+}
+
+func (c *Config) Validate() error {
+	if len(c.Servers) == 0 {
+		return errMissingServers
+	}
+	for _, server := range c.Servers {
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			return fmt.Errorf("invalid server %q: %w", server, err)
+		}
+	}
+	if !strings.Contains(c.KeyTemplate, keyPlaceholder) {
+		return fmt.Errorf("key_template must contain %q", keyPlaceholder)
+	}
+	if c.Timeout < 0 {
+		return errors.New("timeout must not be negative")
+	}
+	if c.MaxIdleConns < 0 {
+		return errors.New("max_idle_conns must not be negative")
+	}
+	return nil
+}
+
+func NewFactory() lookupsource.SourceFactory {
+	return lookupsource.NewSourceFactory(
+		sourceType,
+		createDefaultConfig,
+		createSource,
+	)
+}
+
+func createDefaultConfig() lookupsource.SourceConfig {
+	return &Config{
+		KeyTemplate:  keyPlaceholder,
+		Timeout:      time.Second,
+		MaxIdleConns: 2,
+		Cache: lookupsource.CacheConfig{
+			Enabled: true,
+			Size:    10000,
+			TTL:     time.Minute,
+		},
+	}
+}
+
+func createSource(
+	_ context.Context,
+	_ lookupsource.CreateSettings,
+	cfg lookupsource.SourceConfig,
+) (lookupsource.Source, error) {
+	return newSource(cfg.(*Config), newClient), nil
+}
+
+func newSource(cfg *Config, newClient func(*Config) client) lookupsource.Source {
+	cache := lookupsource.NewCache(cfg.Cache)
+	s := &memcachedSource{cfg: cfg, newClient: newClient, cache: cache}
+
+	lookup := lookupsource.WrapWithCache(cache, s.lookup)
+
+	return lookupsource.NewSource(
+		lookup,
+		func() string { return sourceType },
+		s.start,
+		s.shutdown,
+		lookupsource.WithHealthCheck(s.checkHealth),
+		lookupsource.WithCache(cache),
+	)
+}
+
+// client is the subset of *memcache.Client used by the source.
+type client interface {
+	Get(key string) (*memcache.Item, error)
+	Ping() error
+	Close() error
+}
+
+func newClient(cfg *Config) client {
+	c := memcache.New(cfg.Servers...)
+	c.Timeout = cfg.Timeout
+	c.MaxIdleConns = cfg.MaxIdleConns
+	return c
+}
+
+type memcachedSource struct {
+	cfg       *Config
+	newClient func(*Config) client
+	client    client
+	cache     *lookupsource.Cache
+}
+
+func (s *memcachedSource) start(_ context.Context, _ component.Host) error {
+	c := s.newClient(s.cfg)
+	if err := c.Ping(); err != nil {
+		_ = c.Close()
+		return fmt.Errorf("failed to connect to memcached: %w", err)
+	}
+	s.client = c
+	return nil
+}
+
+func (s *memcachedSource) shutdown(ctx context.Context) error {
+	var err error
+	if s.client != nil {
+		err = s.client.Close()
+		s.client = nil
+	}
+	return errors.Join(err, s.cache.Shutdown(ctx))
+}
+
+func (s *memcachedSource) checkHealth(_ context.Context) error {
+	if s.client == nil {
+		return errNotStarted
+	}
+	return s.client.Ping()
+}
+
+// lookup fetches the memcached key of key. The client has no context
+// support, so requests are bounded by Config.Timeout instead of ctx.
+func (s *memcachedSource) lookup(_ context.Context, key string) (any, bool, error) {
+	if s.client == nil {
+		return nil, false, errNotStarted
+	}
+
+	item, err := s.client.Get(strings.ReplaceAll(s.cfg.KeyTemplate, keyPlaceholder, key))
+	if errors.Is(err, memcache.ErrCacheMiss) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("memcached get failed: %w", err)
+	}
+	return string(item.Value), true, nil
+}
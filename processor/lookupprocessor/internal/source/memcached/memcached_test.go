@@ -0,0 +1,167 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package memcached
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/sourcetest"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+// stubClient serves items from a map. Unknown keys are cache misses.
+type stubClient struct {
+	items   map[string]string
+	err     error
+	pingErr error
+	gets    []string
+	closed  bool
+}
+
+func (c *stubClient) Get(key string) (*memcache.Item, error) {
+	c.gets = append(c.gets, key)
+	if c.err != nil {
+		return nil, c.err
+	}
+	value, ok := c.items[key]
+	if !ok {
+		return nil, memcache.ErrCacheMiss
+	}
+	return &memcache.Item{Key: key, Value: []byte(value)}, nil
+}
+
+func (c *stubClient) Ping() error {
+	return c.pingErr
+}
+
+func (c *stubClient) Close() error {
+	c.closed = true
+	return nil
+}
+
+func newTestConfig() *Config {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Servers = []string{"localhost:11211"}
+	return cfg
+}
+
+// newTestSource returns a started source backed by c.
+func newTestSource(t *testing.T, cfg *Config, c *stubClient) lookupsource.Source {
+	t.Helper()
+	return sourcetest.Start(t, newSource(cfg, func(*Config) client { return c }))
+}
+
+func TestConfigValidate(t *testing.T) {
+	sourcetest.RunValidateTests(t, newTestConfig, []sourcetest.ValidateTest[*Config]{
+		{
+			Name: "valid",
+		},
+		{
+			Name:    "missing servers",
+			Modify:  func(c *Config) { c.Servers = nil },
+			WantErr: "servers must be specified",
+		},
+		{
+			Name:    "server without port",
+			Modify:  func(c *Config) { c.Servers = []string{"localhost"} },
+			WantErr: `invalid server "localhost"`,
+		},
+		{
+			Name:    "template without key",
+			Modify:  func(c *Config) { c.KeyTemplate = "hosts:" },
+			WantErr: `key_template must contain "{key}"`,
+		},
+		{
+			Name:    "negative timeout",
+			Modify:  func(c *Config) { c.Timeout = -1 },
+			WantErr: "timeout must not be negative",
+		},
+	})
+}
+
+func TestLookup(t *testing.T) {
+	c := &stubClient{items: map[string]string{"hosts:10.0.0.1": "web-1"}}
+	cfg := newTestConfig()
+	cfg.KeyTemplate = "hosts:{key}"
+	source := newTestSource(t, cfg, c)
+
+	val, found, err := source.Lookup(t.Context(), "10.0.0.1")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "web-1", val)
+
+	val, found, err = source.Lookup(t.Context(), "10.0.0.2")
+	require.NoError(t, err)
+	assert.False(t, found)
+	assert.Nil(t, val)
+
+	assert.Equal(t, []string{"hosts:10.0.0.1", "hosts:10.0.0.2"}, c.gets)
+}
+
+func TestLookupError(t *testing.T) {
+	getErr := errors.New("connection reset")
+	source := newTestSource(t, newTestConfig(), &stubClient{err: getErr})
+
+	_, found, err := source.Lookup(t.Context(), "10.0.0.1")
+	assert.False(t, found)
+	assert.ErrorIs(t, err, getErr)
+}
+
+func TestLookupCache(t *testing.T) {
+	c := &stubClient{items: map[string]string{"10.0.0.1": "web-1"}}
+	source := newTestSource(t, newTestConfig(), c)
+
+	for range 3 {
+		val, found, err := source.Lookup(t.Context(), "10.0.0.1")
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "web-1", val)
+	}
+	assert.Len(t, c.gets, 1)
+}
+
+func TestLookupBeforeStart(t *testing.T) {
+	source := newSource(newTestConfig(), func(*Config) client { return &stubClient{} })
+
+	_, _, err := source.Lookup(t.Context(), "10.0.0.1")
+	assert.ErrorIs(t, err, errNotStarted)
+}
+
+func TestStartUnreachable(t *testing.T) {
+	pingErr := errors.New("connection refused")
+	c := &stubClient{pingErr: pingErr}
+	source := newSource(newTestConfig(), func(*Config) client { return c })
+
+	err := source.Start(t.Context(), componenttest.NewNopHost())
+	assert.ErrorIs(t, err, pingErr)
+	assert.True(t, c.closed)
+}
+
+func TestCheckHealth(t *testing.T) {
+	c := &stubClient{}
+	source := newTestSource(t, newTestConfig(), c)
+	checker := source.(lookupsource.HealthChecker)
+
+	assert.NoError(t, checker.CheckHealth(t.Context()))
+
+	c.pingErr = errors.New("connection refused")
+	assert.ErrorIs(t, checker.CheckHealth(t.Context()), c.pingErr)
+}
+
+func TestShutdown(t *testing.T) {
+	c := &stubClient{}
+	source := newTestSource(t, newTestConfig(), c)
+
+	require.NoError(t, source.Shutdown(t.Context()))
+	assert.True(t, c.closed)
+
+	// A second shutdown is a no-op.
+	assert.NoError(t, source.Shutdown(t.Context()))
+}
@@ -0,0 +1,213 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package promql provides a lookup source running PromQL instant queries
+// against a Prometheus-compatible HTTP API, such as the current error rate of
+// the service named by the key.
+package promql // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/promql"
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/confighttp"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/httpsource"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+const (
+	sourceType = "promql"
+
+	// keyPlaceholder is replaced by the lookup key in Config.Query.
+	keyPlaceholder = "{key}"
+
+	// queryPath is the path of the instant query endpoint of the API.
+	queryPath = "/api/v1/query"
+
+	// maxResponseSize bounds the response read for a query. Queries are
+	// expected to return a single sample.
+	maxResponseSize = 1 << 20
+)
+
+type Config struct {
+	// ClientConfig configures the HTTP client of the API: endpoint, timeout
+	// of a single query, TLS, headers, authentication, etc. Endpoint is the
+	// base URL of the API, such as http://prometheus:9090.
+	confighttp.ClientConfig `mapstructure:",squash"`
+
+	// Query is the PromQL instant query run for each key. {key} is
+	// replaced by the key, escaped to be used within a double-quoted string,
+	// such as sum(rate(http_errors_total{service="{key}"}[5m])).
+	Query string `mapstructure:"query"`
+
+	Cache lookupsource.CacheConfig `mapstructure:"cache"`
+}
+
+func (c *Config) Validate() error {
+	if c.Endpoint == "" {
+		return errors.New("endpoint must be specified")
+	}
+	if c.Query == "" {
+		return errors.New("query must be specified")
+	}
+	if !strings.Contains(c.Query, keyPlaceholder) {
+		return fmt.Errorf("query must contain the %s placeholder", keyPlaceholder)
+	}
+	if c.Timeout < 0 {
+		return errors.New("timeout must not be negative")
+	}
+	return nil
+}
+
+func NewFactory() lookupsource.SourceFactory {
+	return lookupsource.NewSourceFactory(
+		sourceType,
+		createDefaultConfig,
+		createSource,
+	)
+}
+
+func createDefaultConfig() lookupsource.SourceConfig {
+	clientConfig := confighttp.NewDefaultClientConfig()
+	clientConfig.Timeout = 5 * time.Second
+	return &Config{
+		ClientConfig: clientConfig,
+		// Query results change quickly, so they are only cached briefly.
+		Cache: lookupsource.CacheConfig{
+			Enabled: true,
+			Size:    10000,
+			TTL:     30 * time.Second,
+		},
+	}
+}
+
+func createSource(
+	_ context.Context,
+	settings lookupsource.CreateSettings,
+	cfg lookupsource.SourceConfig,
+) (lookupsource.Source, error) {
+	return newSource(cfg.(*Config), settings.TelemetrySettings), nil
+}
+
+func newSource(cfg *Config, telemetry component.TelemetrySettings) lookupsource.Source {
+	s := &promqlSource{
+		cfg:      cfg,
+		queryURL: strings.TrimSuffix(cfg.Endpoint, "/") + queryPath,
+		client:   httpsource.NewClient(sourceType, &cfg.ClientConfig, telemetry),
+	}
+	return httpsource.NewSource(s.client, cfg.Cache, s.lookup)
+}
+
+type promqlSource struct {
+	cfg      *Config
+	queryURL string
+	client   *httpsource.Client
+}
+
+// lookup runs the query of key and returns the value of its first sample,
+// or of the scalar or string it evaluates to. Queries returning no sample
+// are not found.
+func (s *promqlSource) lookup(ctx context.Context, key string) (any, bool, error) {
+	query := strings.ReplaceAll(s.cfg.Query, keyPlaceholder, escapeKey(key))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.queryURL+"?"+url.Values{"query": {query}}.Encode(), http.NoBody)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create promql request: %w", err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("promql query failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result queryResponse
+	if err := httpsource.NewDecoder(resp, maxResponseSize).Decode(&result); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, false, fmt.Errorf("promql query failed: %s", resp.Status)
+		}
+		return nil, false, fmt.Errorf("failed to decode promql response: %w", err)
+	}
+	if result.Status != "success" {
+		return nil, false, fmt.Errorf("promql query failed: %s: %s: %s", resp.Status, result.ErrorType, result.Error)
+	}
+	return result.Data.value()
+}
+
+// escapeKey escapes key to be used within a double-quoted PromQL string, so
+// that keys can't change the query. PromQL strings use the escapes of Go.
+func escapeKey(key string) string {
+	quoted := strconv.Quote(key)
+	return quoted[1 : len(quoted)-1]
+}
+
+// queryResponse is the response of the instant query endpoint.
+type queryResponse struct {
+	Status    string    `json:"status"`
+	Data      queryData `json:"data"`
+	ErrorType string    `json:"errorType"`
+	Error     string    `json:"error"`
+}
+
+type queryData struct {
+	ResultType string          `json:"resultType"`
+	Result     json.RawMessage `json:"result"`
+}
+
+// sample is a [<unix time>, "<value>"] pair.
+type sample [2]json.RawMessage
+
+func (d queryData) value() (any, bool, error) {
+	switch d.ResultType {
+	case "vector":
+		var series []struct {
+			Value sample `json:"value"`
+		}
+		if err := json.Unmarshal(d.Result, &series); err != nil {
+			return nil, false, fmt.Errorf("failed to decode promql vector: %w", err)
+		}
+		if len(series) == 0 {
+			return nil, false, nil
+		}
+		return series[0].Value.float()
+	case "scalar":
+		var s sample
+		if err := json.Unmarshal(d.Result, &s); err != nil {
+			return nil, false, fmt.Errorf("failed to decode promql scalar: %w", err)
+		}
+		return s.float()
+	case "string":
+		var s sample
+		if err := json.Unmarshal(d.Result, &s); err != nil {
+			return nil, false, fmt.Errorf("failed to decode promql string: %w", err)
+		}
+		var str string
+		if err := json.Unmarshal(s[1], &str); err != nil {
+			return nil, false, fmt.Errorf("failed to decode promql string: %w", err)
+		}
+		return str, true, nil
+	default:
+		return nil, false, fmt.Errorf("unsupported promql result type %q, the query must return an instant vector or a scalar", d.ResultType)
+	}
+}
+
+// float returns the value of s. Prometheus encodes sample values as strings,
+// so that NaN and infinities can be represented.
+func (s sample) float() (any, bool, error) {
+	var str string
+	if err := json.Unmarshal(s[1], &str); err != nil {
+		return nil, false, fmt.Errorf("failed to decode promql sample: %w", err)
+	}
+	val, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to decode promql sample: %w", err)
+	}
+	return val, true, nil
+}
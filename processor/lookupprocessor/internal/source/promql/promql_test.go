@@ -0,0 +1,266 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package promql
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config/configopaque"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/httpsource"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/sourcetest"
+)
+
+const testQuery = `sum(rate(http_errors_total{service="{key}"}[5m]))`
+
+// newTestServer returns a server answering queries with the response of
+// responses for the query, or an empty vector.
+func newTestServer(t *testing.T, responses map[string]string) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	var requests atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path != queryPath {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if resp, ok := responses[r.URL.Query().Get("query")]; ok {
+			_, _ = w.Write([]byte(resp))
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func newTestConfig(endpoint string) *Config {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Endpoint = endpoint
+	cfg.Query = testQuery
+	return cfg
+}
+
+func TestConfigValidate(t *testing.T) {
+	sourcetest.RunValidateTests(t, func() *Config { return newTestConfig("http://localhost:9090") }, []sourcetest.ValidateTest[*Config]{
+		{
+			Name: "valid",
+		},
+		{
+			Name:    "missing endpoint",
+			Modify:  func(c *Config) { c.Endpoint = "" },
+			WantErr: "endpoint must be specified",
+		},
+		{
+			Name:    "missing query",
+			Modify:  func(c *Config) { c.Query = "" },
+			WantErr: "query must be specified",
+		},
+		{
+			Name:    "query without placeholder",
+			Modify:  func(c *Config) { c.Query = "up" },
+			WantErr: "query must contain the {key} placeholder",
+		},
+		{
+			Name:    "negative timeout",
+			Modify:  func(c *Config) { c.Timeout = -1 },
+			WantErr: "timeout must not be negative",
+		},
+	})
+}
+
+func TestLookup(t *testing.T) {
+	srv, _ := newTestServer(t, map[string]string{
+		`sum(rate(http_errors_total{service="checkout"}[5m]))`: `{"status":"success","data":{"resultType":"vector","result":[
+			{"metric":{},"value":[1700000000.123,"0.25"]},
+			{"metric":{},"value":[1700000000.123,"0.5"]}
+		]}}`,
+		`sum(rate(http_errors_total{service="cart"}[5m]))`: `{"status":"success","data":{"resultType":"vector","result":[
+			{"metric":{},"value":[1700000000.123,"NaN"]}
+		]}}`,
+		`sum(rate(http_errors_total{service="ad\"}) or vector(1) or up{a=\"\\"}[5m]))`: `{"status":"success","data":{"resultType":"vector","result":[
+			{"metric":{},"value":[1700000000.123,"1"]}
+		]}}`,
+	})
+	source := sourcetest.CreateAndStart(t, NewFactory(), newTestConfig(srv.URL))
+
+	tests := []struct {
+		name      string
+		key       string
+		wantFound bool
+		wantValue any
+	}{
+		{
+			name:      "first sample",
+			key:       "checkout",
+			wantFound: true,
+			wantValue: 0.25,
+		},
+		{
+			name:      "escaped key",
+			key:       `ad"}) or vector(1) or up{a="\`,
+			wantFound: true,
+			wantValue: float64(1),
+		},
+		{
+			name: "empty vector",
+			key:  "payments",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			val, found, err := source.Lookup(t.Context(), tt.key)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantFound, found)
+			assert.Equal(t, tt.wantValue, val)
+		})
+	}
+
+	t.Run("NaN", func(t *testing.T) {
+		val, found, err := source.Lookup(t.Context(), "cart")
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.True(t, math.IsNaN(val.(float64)))
+	})
+}
+
+func TestLookupResultTypes(t *testing.T) {
+	srv, _ := newTestServer(t, map[string]string{
+		`scalar(up{job="scalar"})`: `{"status":"success","data":{"resultType":"scalar","result":[1700000000,"42"]}}`,
+		`"string"`:                 `{"status":"success","data":{"resultType":"string","result":[1700000000,"string"]}}`,
+		`up{job="matrix"}[5m]`:     `{"status":"success","data":{"resultType":"matrix","result":[]}}`,
+	})
+
+	tests := []struct {
+		query     string
+		key       string
+		wantValue any
+		wantErr   string
+	}{
+		{
+			query:     `scalar(up{job="{key}"})`,
+			key:       "scalar",
+			wantValue: float64(42),
+		},
+		{
+			query:     `"{key}"`,
+			key:       "string",
+			wantValue: "string",
+		},
+		{
+			query:   `up{job="{key}"}[5m]`,
+			key:     "matrix",
+			wantErr: `unsupported promql result type "matrix"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			cfg := newTestConfig(srv.URL)
+			cfg.Query = tt.query
+			source := sourcetest.CreateAndStart(t, NewFactory(), cfg)
+
+			val, found, err := source.Lookup(t.Context(), tt.key)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, tt.wantValue, val)
+		})
+	}
+}
+
+func TestLookupErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{
+			name:    "query error",
+			status:  http.StatusBadRequest,
+			body:    `{"status":"error","errorType":"bad_data","error":"invalid parameter \"query\": parse error"}`,
+			wantErr: "promql query failed: 400 Bad Request: bad_data: invalid parameter",
+		},
+		{
+			name:    "server error",
+			status:  http.StatusBadGateway,
+			body:    "upstream unavailable",
+			wantErr: "promql query failed: 502 Bad Gateway",
+		},
+		{
+			name:    "invalid response",
+			status:  http.StatusOK,
+			body:    "not json",
+			wantErr: "failed to decode promql response",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+			cfg := newTestConfig(srv.URL)
+			cfg.Cache.Enabled = false
+			source := sourcetest.CreateAndStart(t, NewFactory(), cfg)
+
+			_, found, err := source.Lookup(t.Context(), "checkout")
+			assert.ErrorContains(t, err, tt.wantErr)
+			assert.False(t, found)
+		})
+	}
+}
+
+func TestLookupCached(t *testing.T) {
+	srv, requests := newTestServer(t, map[string]string{
+		`sum(rate(http_errors_total{service="checkout"}[5m]))`: `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1700000000,"0.25"]}]}}`,
+	})
+	source := sourcetest.CreateAndStart(t, NewFactory(), newTestConfig(srv.URL))
+
+	for range 3 {
+		val, found, err := source.Lookup(t.Context(), "checkout")
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, 0.25, val)
+	}
+	assert.Equal(t, int64(1), requests.Load())
+}
+
+func TestLookupHeaders(t *testing.T) {
+	var auth atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth.Store(r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+	}))
+	defer srv.Close()
+	cfg := newTestConfig(srv.URL + "/")
+	cfg.Headers.Set("Authorization", configopaque.String("Bearer secret"))
+	source := sourcetest.CreateAndStart(t, NewFactory(), cfg)
+
+	_, found, err := source.Lookup(t.Context(), "checkout")
+	require.NoError(t, err)
+	assert.False(t, found)
+	assert.Equal(t, "Bearer secret", auth.Load())
+}
+
+func TestLookupNotStarted(t *testing.T) {
+	source := newSource(newTestConfig("http://localhost:9090"), componenttest.NewNopTelemetrySettings())
+	_, _, err := source.Lookup(t.Context(), "checkout")
+	assert.ErrorIs(t, err, httpsource.ErrNotStarted)
+}
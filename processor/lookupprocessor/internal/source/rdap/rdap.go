@@ -0,0 +1,273 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package rdap provides a lookup source resolving IP addresses to the
+// network registered for them, such as its organization, autonomous system
+// and country, with the Registration Data Access Protocol (RFC 9083), the
+// successor of WHOIS.
+package rdap // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/rdap"
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/confighttp"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/httpsource"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+const (
+	sourceType = "rdap"
+
+	// ipPath is the path of the IP network queries of the service.
+	ipPath = "/ip/"
+
+	// maxResponseSize bounds the response read for an address. Network
+	// objects with many entities and remarks can take a few hundred KiB.
+	maxResponseSize = 4 << 20
+)
+
+type Config struct {
+	// ClientConfig configures the HTTP client of the service: endpoint,
+	// timeout of a single query, TLS, headers, etc. Endpoint is the base URL
+	// of the service. The default, https://rdap.org, redirects each query
+	// to the registry responsible for the address.
+	confighttp.ClientConfig `mapstructure:",squash"`
+
+	// RateLimit is the number of queries per second sent to the service,
+	// which usually throttles or blocks clients querying too fast. Zero
+	// disables the limit.
+	// Default: 1
+	RateLimit float64 `mapstructure:"rate_limit"`
+
+	// RateLimitBurst is the number of queries that can be sent at once
+	// above RateLimit.
+	// Default: 5
+	RateLimitBurst int `mapstructure:"rate_limit_burst"`
+
+	// MaxRedirects is the number of redirects followed for a query, such as
+	// from a bootstrap service to the registry of the address and on to a
+	// national registry.
+	// Default: 5
+	MaxRedirects int `mapstructure:"max_redirects"`
+
+	Cache lookupsource.CacheConfig `mapstructure:"cache"`
+}
+
+func (c *Config) Validate() error {
+	if c.Endpoint == "" {
+		return errors.New("endpoint must be specified")
+	}
+	if c.Timeout < 0 {
+		return errors.New("timeout must not be negative")
+	}
+	if c.RateLimit < 0 {
+		return errors.New("rate_limit must not be negative")
+	}
+	if c.RateLimitBurst < 0 {
+		return errors.New("rate_limit_burst must not be negative")
+	}
+	if c.MaxRedirects < 0 {
+		return errors.New("max_redirects must not be negative")
+	}
+	return nil
+}
+
+func NewFactory() lookupsource.SourceFactory {
+	return lookupsource.NewSourceFactory(
+		sourceType,
+		createDefaultConfig,
+		createSource,
+	)
+}
+
+func createDefaultConfig() lookupsource.SourceConfig {
+	clientConfig := confighttp.NewDefaultClientConfig()
+	clientConfig.Endpoint = "https://rdap.org"
+	clientConfig.Timeout = 10 * time.Second
+	return &Config{
+		ClientConfig:   clientConfig,
+		RateLimit:      1,
+		RateLimitBurst: 5,
+		MaxRedirects:   5,
+		// Registrations rarely change, so they are cached for a day.
+		Cache: lookupsource.CacheConfig{
+			Enabled: true,
+			Size:    10000,
+			TTL:     24 * time.Hour,
+		},
+	}
+}
+
+func createSource(
+	_ context.Context,
+	settings lookupsource.CreateSettings,
+	cfg lookupsource.SourceConfig,
+) (lookupsource.Source, error) {
+	return newSource(cfg.(*Config), settings.TelemetrySettings), nil
+}
+
+func newSource(cfg *Config, telemetry component.TelemetrySettings) lookupsource.Source {
+	s := &rdapSource{
+		baseURL: strings.TrimSuffix(cfg.Endpoint, "/") + ipPath,
+		client:  httpsource.NewClient(sourceType, &cfg.ClientConfig, telemetry),
+	}
+	maxRedirects := cfg.MaxRedirects
+	s.client.CheckRedirect = func(_ *http.Request, via []*http.Request) error {
+		if len(via) > maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		return nil
+	}
+
+	// Cache hits don't count against the rate limit.
+	return httpsource.NewSource(s.client, cfg.Cache, lookupsource.WithRateLimit(s.lookup, cfg.RateLimit, cfg.RateLimitBurst))
+}
+
+type rdapSource struct {
+	baseURL string
+	client  *httpsource.Client
+}
+
+// lookup queries the network registered for the IP address key, following
+// redirects, and returns its fields. Addresses no registry knows about are
+// not found.
+func (s *rdapSource) lookup(ctx context.Context, key string) (any, bool, error) {
+	addr, err := netip.ParseAddr(key)
+	if err != nil {
+		return nil, false, lookupsource.Permanent(fmt.Errorf("invalid IP address %q: %w", key, err))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.baseURL+addr.WithZone("").String(), http.NoBody)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create rdap request: %w", err)
+	}
+	req.Header.Set("Accept", "application/rdap+json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("rdap query failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, false, nil
+	case http.StatusTooManyRequests:
+		return nil, false, lookupsource.Transient(fmt.Errorf("rdap query rate limited by %s: %s", resp.Request.URL.Host, resp.Status))
+	default:
+		return nil, false, fmt.Errorf("rdap query failed: %s", resp.Status)
+	}
+
+	var network ipNetwork
+	if err := httpsource.NewDecoder(resp, maxResponseSize).Decode(&network); err != nil {
+		return nil, false, fmt.Errorf("failed to decode rdap response: %w", err)
+	}
+	return network.fields(), true, nil
+}
+
+// ipNetwork is the subset of an RDAP IP network object used by the source,
+// including the origin AS extension of ARIN and the CIDR extension.
+type ipNetwork struct {
+	Handle       string   `json:"handle"`
+	Name         string   `json:"name"`
+	Country      string   `json:"country"`
+	StartAddress string   `json:"startAddress"`
+	EndAddress   string   `json:"endAddress"`
+	Entities     []entity `json:"entities"`
+	OriginASNs   []int64  `json:"arin_originas0_originautnums"`
+	CIDRs        []struct {
+		V4Prefix string `json:"v4prefix"`
+		V6Prefix string `json:"v6prefix"`
+		Length   int    `json:"length"`
+	} `json:"cidr0_cidrs"`
+}
+
+type entity struct {
+	Roles []string `json:"roles"`
+	// VCard is a jCard (RFC 7095): ["vcard", [[name, params, type,
+	// value], ...]].
+	VCard    []json.RawMessage `json:"vcardArray"`
+	Entities []entity          `json:"entities"`
+}
+
+// fields returns the fields of n that are set: handle, name, country,
+// start_address, end_address, cidr, org and asn.
+func (n *ipNetwork) fields() map[string]any {
+	fields := map[string]any{}
+	for name, val := range map[string]string{
+		"handle":        n.Handle,
+		"name":          n.Name,
+		"country":       n.Country,
+		"start_address": n.StartAddress,
+		"end_address":   n.EndAddress,
+		"org":           registrant(n.Entities),
+	} {
+		if val != "" {
+			fields[name] = val
+		}
+	}
+	if len(n.CIDRs) > 0 {
+		prefix := n.CIDRs[0].V4Prefix
+		if prefix == "" {
+			prefix = n.CIDRs[0].V6Prefix
+		}
+		fields["cidr"] = fmt.Sprintf("%s/%d", prefix, n.CIDRs[0].Length)
+	}
+	if len(n.OriginASNs) > 0 {
+		fields["asn"] = n.OriginASNs[0]
+	}
+	return fields
+}
+
+// registrant returns the formatted name of the first registrant among
+// entities and the entities they contain, or "" if there is none.
+func registrant(entities []entity) string {
+	for _, e := range entities {
+		if slices.Contains(e.Roles, "registrant") {
+			if name := e.formattedName(); name != "" {
+				return name
+			}
+		}
+	}
+	for _, e := range entities {
+		if name := registrant(e.Entities); name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// formattedName returns the fn property of the vCard of e, or "" if it
+// has none.
+func (e *entity) formattedName() string {
+	if len(e.VCard) != 2 {
+		return ""
+	}
+	var properties [][]json.RawMessage
+	if err := json.Unmarshal(e.VCard[1], &properties); err != nil {
+		return ""
+	}
+	for _, property := range properties {
+		if len(property) < 4 {
+			continue
+		}
+		var name, value string
+		if json.Unmarshal(property[0], &name) != nil || name != "fn" {
+			continue
+		}
+		if json.Unmarshal(property[3], &value) == nil {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,303 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package rdap
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/httpsource"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/sourcetest"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+// testNetwork is an abridged response of ARIN for 8.8.8.8.
+const testNetwork = `{
+  "rdapConformance": ["nro_rdap_profile_0", "rdap_level_0", "cidr0", "arin_originas0"],
+  "objectClassName": "ip network",
+  "handle": "NET-8-8-8-0-2",
+  "startAddress": "8.8.8.0",
+  "endAddress": "8.8.8.255",
+  "ipVersion": "v4",
+  "name": "GOGL",
+  "type": "DIRECT ALLOCATION",
+  "country": "US",
+  "cidr0_cidrs": [{"v4prefix": "8.8.8.0", "length": 24}],
+  "arin_originas0_originautnums": [15169],
+  "entities": [{
+    "objectClassName": "entity",
+    "handle": "GOGL",
+    "roles": ["registrant"],
+    "vcardArray": ["vcard", [
+      ["version", {}, "text", "4.0"],
+      ["fn", {}, "text", "Google LLC"],
+      ["kind", {}, "text", "org"]
+    ]],
+    "entities": [{
+      "objectClassName": "entity",
+      "handle": "ABUSE5250-ARIN",
+      "roles": ["abuse"],
+      "vcardArray": ["vcard", [["fn", {}, "text", "Abuse"]]]
+    }]
+  }]
+}`
+
+// newTestServer serves the networks by address under /ip/, and 404 for
+// other addresses.
+func newTestServer(t *testing.T, networks map[string]string) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	var requests atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		assert.Equal(t, "application/rdap+json", r.Header.Get("Accept"))
+		network, ok := networks[strings.TrimPrefix(r.URL.Path, ipPath)]
+		if !strings.HasPrefix(r.URL.Path, ipPath) || !ok {
+			w.Header().Set("Content-Type", "application/rdap+json")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errorCode": 404, "title": "Not Found"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/rdap+json")
+		_, _ = w.Write([]byte(network))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+// newRedirectServer redirects every query to target, like a bootstrap
+// service.
+func newRedirectServer(t *testing.T, target string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target+r.URL.Path, http.StatusFound)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestConfig(endpoint string) *Config {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Endpoint = endpoint
+	// Tests query faster than public services allow.
+	cfg.RateLimit = 0
+	return cfg
+}
+
+func TestConfigValidate(t *testing.T) {
+	sourcetest.RunValidateTests(t, func() *Config { return createDefaultConfig().(*Config) }, []sourcetest.ValidateTest[*Config]{
+		{
+			Name: "valid",
+		},
+		{
+			Name:    "missing endpoint",
+			Modify:  func(c *Config) { c.Endpoint = "" },
+			WantErr: "endpoint must be specified",
+		},
+		{
+			Name:    "negative timeout",
+			Modify:  func(c *Config) { c.Timeout = -1 },
+			WantErr: "timeout must not be negative",
+		},
+		{
+			Name:    "negative rate limit",
+			Modify:  func(c *Config) { c.RateLimit = -1 },
+			WantErr: "rate_limit must not be negative",
+		},
+		{
+			Name:    "negative rate limit burst",
+			Modify:  func(c *Config) { c.RateLimitBurst = -1 },
+			WantErr: "rate_limit_burst must not be negative",
+		},
+		{
+			Name:    "negative max redirects",
+			Modify:  func(c *Config) { c.MaxRedirects = -1 },
+			WantErr: "max_redirects must not be negative",
+		},
+	})
+}
+
+func TestLookup(t *testing.T) {
+	srv, _ := newTestServer(t, map[string]string{
+		"8.8.8.8":      testNetwork,
+		"2001:db8::1":  `{"handle": "2001:DB8::/32", "name": "DOC-RESERVED", "cidr0_cidrs": [{"v6prefix": "2001:db8::", "length": 32}]}`,
+		"192.0.2.1":    `{"handle": "NET-192-0-2-0-1"}`,
+		"198.51.100.1": `{"handle": "NET-198-51-100-0-1", "entities": [{"roles": ["technical"], "vcardArray": ["vcard", [["fn", {}, "text", "NOC"]]]}]}`,
+		"203.0.113.1":  `not json`,
+		"203.0.113.2":  `{}`,
+	})
+	source := sourcetest.CreateAndStart(t, NewFactory(), newTestConfig(srv.URL))
+
+	tests := []struct {
+		name      string
+		key       string
+		want      any
+		wantFound bool
+		wantErr   string
+		permanent bool
+	}{
+		{
+			name: "found",
+			key:  "8.8.8.8",
+			want: map[string]any{
+				"handle":        "NET-8-8-8-0-2",
+				"name":          "GOGL",
+				"country":       "US",
+				"start_address": "8.8.8.0",
+				"end_address":   "8.8.8.255",
+				"cidr":          "8.8.8.0/24",
+				"org":           "Google LLC",
+				"asn":           int64(15169),
+			},
+			wantFound: true,
+		},
+		{
+			name: "ipv6",
+			key:  "2001:db8::1",
+			want: map[string]any{
+				"handle": "2001:DB8::/32",
+				"name":   "DOC-RESERVED",
+				"cidr":   "2001:db8::/32",
+			},
+			wantFound: true,
+		},
+		{
+			name:      "only handle",
+			key:       "192.0.2.1",
+			want:      map[string]any{"handle": "NET-192-0-2-0-1"},
+			wantFound: true,
+		},
+		{
+			name:      "no registrant",
+			key:       "198.51.100.1",
+			want:      map[string]any{"handle": "NET-198-51-100-0-1"},
+			wantFound: true,
+		},
+		{
+			name:      "empty network",
+			key:       "203.0.113.2",
+			want:      map[string]any{},
+			wantFound: true,
+		},
+		{
+			name: "not found",
+			key:  "100.64.0.1",
+		},
+		{
+			name:      "invalid address",
+			key:       "not-an-ip",
+			wantErr:   `invalid IP address "not-an-ip"`,
+			permanent: true,
+		},
+		{
+			name:    "invalid response",
+			key:     "203.0.113.1",
+			wantErr: "failed to decode rdap response",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			val, found, err := source.Lookup(t.Context(), tt.key)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				assert.Equal(t, tt.permanent, lookupsource.IsPermanent(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantFound, found)
+			assert.Equal(t, tt.want, val)
+		})
+	}
+}
+
+func TestLookupStatus(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		wantErr       string
+		wantTransient bool
+	}{
+		{
+			name:          "rate limited",
+			status:        http.StatusTooManyRequests,
+			wantErr:       "rdap query rate limited",
+			wantTransient: true,
+		},
+		{
+			name:    "server error",
+			status:  http.StatusInternalServerError,
+			wantErr: "rdap query failed: 500 Internal Server Error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+			source := sourcetest.CreateAndStart(t, NewFactory(), newTestConfig(srv.URL))
+
+			_, found, err := source.Lookup(t.Context(), "8.8.8.8")
+			require.ErrorContains(t, err, tt.wantErr)
+			assert.False(t, found)
+			assert.Equal(t, tt.wantTransient, lookupsource.IsTransient(err))
+		})
+	}
+}
+
+func TestLookupRedirect(t *testing.T) {
+	registry, requests := newTestServer(t, map[string]string{"8.8.8.8": testNetwork})
+	// The bootstrap service redirects to the regional registry through an
+	// intermediate service, as some national registries do.
+	bootstrap := newRedirectServer(t, newRedirectServer(t, registry.URL).URL)
+
+	t.Run("followed", func(t *testing.T) {
+		source := sourcetest.CreateAndStart(t, NewFactory(), newTestConfig(bootstrap.URL))
+		val, found, err := source.Lookup(t.Context(), "8.8.8.8")
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, "Google LLC", val.(map[string]any)["org"])
+
+		// A 404 from the registry the query ends up at is a miss too.
+		_, found, err = source.Lookup(t.Context(), "100.64.0.1")
+		require.NoError(t, err)
+		assert.False(t, found)
+	})
+
+	t.Run("too many redirects", func(t *testing.T) {
+		requests.Store(0)
+		cfg := newTestConfig(bootstrap.URL)
+		cfg.MaxRedirects = 1
+		source := sourcetest.CreateAndStart(t, NewFactory(), cfg)
+		_, found, err := source.Lookup(t.Context(), "8.8.8.8")
+		require.ErrorContains(t, err, "stopped after 1 redirects")
+		assert.False(t, found)
+		assert.Zero(t, requests.Load())
+	})
+}
+
+func TestLookupCache(t *testing.T) {
+	srv, requests := newTestServer(t, map[string]string{"8.8.8.8": testNetwork})
+	source := sourcetest.CreateAndStart(t, NewFactory(), newTestConfig(srv.URL))
+
+	for range 3 {
+		_, found, err := source.Lookup(t.Context(), "8.8.8.8")
+		require.NoError(t, err)
+		assert.True(t, found)
+	}
+	assert.Equal(t, int64(1), requests.Load())
+}
+
+func TestLookupNotStarted(t *testing.T) {
+	source := sourcetest.Create(t, NewFactory(), newTestConfig("http://localhost"))
+	_, _, err := source.Lookup(t.Context(), "8.8.8.8")
+	assert.ErrorIs(t, err, httpsource.ErrNotStarted)
+}
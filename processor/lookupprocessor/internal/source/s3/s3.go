@@ -0,0 +1,364 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package s3 provides a lookup source backed by a CSV or JSON object stored
+// in Amazon S3 or an S3-compatible object store.
+//
+// The object is downloaded on start and then on every refresh interval, and
+// lookups are served from the entries parsed from the last successful
+// download.
+package s3 // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/s3"
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"go.opentelemetry.io/collector/component"
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/compression"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/invert"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/jsonnumber"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+const sourceType = "s3"
+
+// Format is the encoding of the object.
+type Format string
+
+const (
+	// FormatCSV is a CSV object whose first row names the columns.
+	FormatCSV Format = "csv"
+	// FormatJSON is a JSON object mapping lookup keys to their values.
+	FormatJSON Format = "json"
+)
+
+var (
+	errMissingBucket = errors.New("bucket must be specified")
+	errMissingKey    = errors.New("key must be specified")
+)
+
+type Config struct {
+	// Bucket is the name of the bucket holding the object.
+	Bucket string `mapstructure:"bucket"`
+
+	// Key is the key of the object in Bucket. Objects compressed with gzip
+	// or zstd are decompressed.
+	Key string `mapstructure:"key"`
+
+	// Region is the region of the bucket. If empty, the region is taken
+	// from the environment or shared configuration, like credentials,
+	// which always come from the default AWS credential chain.
+	Region string `mapstructure:"region"`
+
+	// Endpoint is the URL of an S3-compatible object store to use instead
+	// of Amazon S3. Objects are then addressed with path-style URLs.
+	Endpoint string `mapstructure:"endpoint"`
+
+	// Format is the encoding of the object: csv or json.
+	// Default: csv
+	Format Format `mapstructure:"format"`
+
+	// JSONNumbers is how the numbers of the json format are decoded:
+	// float64, which rounds integers above 2^53, int64, which keeps
+	// integers that fit as int64, or string, which keeps the exact text of
+	// every number.
+	// Default: float64
+	JSONNumbers jsonnumber.Mode `mapstructure:"json_numbers"`
+
+	// KeyColumn is the CSV column holding the lookup keys.
+	// Default: the first column
+	KeyColumn string `mapstructure:"key_column"`
+
+	// ValueColumn is the CSV column holding the values. If empty, the value
+	// of a key is a map of the other columns of its row.
+	ValueColumn string `mapstructure:"value_column"`
+
+	// Invert looks entries up by value rather than by key: an inverted
+	// entry maps the value of an entry to its key. Values must be strings.
+	Invert bool `mapstructure:"invert"`
+
+	// InvertCollision selects what an inverted entry returns when several
+	// keys have its value: first, list or error.
+	// Default: first
+	InvertCollision invert.Collision `mapstructure:"invert_collision"`
+
+	// RefreshInterval is how often the object is downloaded again. Zero
+	// disables refreshing.
+	// Default: 5m
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"`
+}
+
+func (c *Config) Validate() error {
+	if c.Bucket == "" {
+		return errMissingBucket
+	}
+	if c.Key == "" {
+		return errMissingKey
+	}
+	switch c.Format {
+	case FormatCSV:
+	case FormatJSON:
+		if c.KeyColumn != "" || c.ValueColumn != "" {
+			return errors.New("key_column and value_column can only be used with the csv format")
+		}
+	default:
+		return fmt.Errorf("unsupported format %q, must be %q or %q", c.Format, FormatCSV, FormatJSON)
+	}
+	if err := c.JSONNumbers.Validate(); err != nil {
+		return err
+	}
+	if c.Invert && c.Format == FormatCSV && c.ValueColumn == "" {
+		return errors.New("invert requires value_column with the csv format")
+	}
+	if err := c.InvertCollision.Validate(); err != nil {
+		return err
+	}
+	if c.RefreshInterval < 0 {
+		return errors.New("refresh_interval must not be negative")
+	}
+	return nil
+}
+
+func NewFactory() lookupsource.SourceFactory {
+	return lookupsource.NewSourceFactory(
+		sourceType,
+		createDefaultConfig,
+		createSource,
+	)
+}
+
+func createDefaultConfig() lookupsource.SourceConfig {
+	return &Config{
+		Format:          FormatCSV,
+		JSONNumbers:     jsonnumber.Float64,
+		InvertCollision: invert.First,
+		RefreshInterval: 5 * time.Minute,
+	}
+}
+
+func createSource(
+	_ context.Context,
+	settings lookupsource.CreateSettings,
+	cfg lookupsource.SourceConfig,
+) (lookupsource.Source, error) {
+	return newSource(cfg.(*Config), settings.TelemetrySettings.Logger, newClient), nil
+}
+
+func newSource(cfg *Config, logger *zap.Logger, newClient func(context.Context, *Config) (client, error)) lookupsource.Source {
+	s := &s3Source{cfg: cfg, logger: logger, newClient: newClient}
+
+	// Entries are served from memory, so no cache is needed.
+	return lookupsource.NewSource(
+		s.lookup,
+		func() string { return sourceType },
+		s.start,
+		s.shutdown,
+		lookupsource.WithChanges(&s.changes),
+	)
+}
+
+// client is the subset of *s3.Client used by the source.
+type client interface {
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+func newClient(ctx context.Context, cfg *Config) (client, error) {
+	var opts []func(*config.LoadOptions) error
+	if cfg.Region != "" {
+		opts = append(opts, config.WithRegion(cfg.Region))
+	}
+	awsCfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+			o.UsePathStyle = true
+		}
+	}), nil
+}
+
+type s3Source struct {
+	cfg       *Config
+	logger    *zap.Logger
+	newClient func(context.Context, *Config) (client, error)
+	client    client
+
+	mu      sync.RWMutex
+	entries map[string]any
+	// changes reports the replacements of entries.
+	changes lookupsource.Changes
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// start downloads the object and starts refreshing it. The collector fails
+// to start if the first download fails, rather than running without any
+// entries.
+func (s *s3Source) start(ctx context.Context, _ component.Host) error {
+	c, err := s.newClient(ctx, s.cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create S3 client: %w", err)
+	}
+	s.client = c
+	if err := s.load(ctx); err != nil {
+		return err
+	}
+
+	if s.cfg.RefreshInterval > 0 {
+		var refreshCtx context.Context
+		refreshCtx, s.cancel = context.WithCancel(context.Background())
+		s.wg.Add(1)
+		go s.refresh(refreshCtx)
+	}
+	return nil
+}
+
+func (s *s3Source) shutdown(_ context.Context) error {
+	if s.cancel == nil {
+		return nil
+	}
+	s.cancel()
+	s.wg.Wait()
+	s.cancel = nil
+	return nil
+}
+
+// refresh downloads the object every refresh interval until ctx is done. A
+// failed download keeps the entries of the last successful one.
+func (s *s3Source) refresh(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.cfg.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.load(ctx); err != nil && ctx.Err() == nil {
+				s.logger.Warn("Failed to refresh S3 object, keeping the previous entries",
+					zap.String("bucket", s.cfg.Bucket), zap.String("key", s.cfg.Key), zap.Error(err))
+			}
+		}
+	}
+}
+
+// load downloads, decompresses and parses the object, and replaces the entries with the
+// result if both succeed.
+func (s *s3Source) load(ctx context.Context) error {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.cfg.Bucket),
+		Key:    aws.String(s.cfg.Key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to download s3://%s/%s: %w", s.cfg.Bucket, s.cfg.Key, err)
+	}
+	defer out.Body.Close()
+	body, err := compression.NewReader(out.Body, s.cfg.Key)
+	if err != nil {
+		return fmt.Errorf("failed to read s3://%s/%s: %w", s.cfg.Bucket, s.cfg.Key, err)
+	}
+	defer body.Close()
+
+	var entries map[string]any
+	if s.cfg.Format == FormatJSON {
+		entries, err = parseJSON(body, s.cfg.JSONNumbers)
+	} else {
+		entries, err = parseCSV(body, s.cfg.KeyColumn, s.cfg.ValueColumn)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse s3://%s/%s: %w", s.cfg.Bucket, s.cfg.Key, err)
+	}
+	if s.cfg.Invert {
+		var collisions int
+		entries, collisions, err = invert.Entries(entries, s.cfg.InvertCollision)
+		if err != nil {
+			return fmt.Errorf("failed to invert s3://%s/%s: %w", s.cfg.Bucket, s.cfg.Key, err)
+		}
+		if collisions > 0 && s.cfg.InvertCollision != invert.List {
+			s.logger.Warn("Values of the S3 object are shared by several keys, inverted entries return the first key",
+				zap.String("bucket", s.cfg.Bucket), zap.String("key", s.cfg.Key), zap.Int("values", collisions))
+		}
+	}
+
+	s.mu.Lock()
+	s.entries = entries
+	s.mu.Unlock()
+	s.changes.Notify()
+	return nil
+}
+
+func (s *s3Source) lookup(_ context.Context, key string) (any, bool, error) {
+	s.mu.RLock()
+	val, found := s.entries[key]
+	s.mu.RUnlock()
+	return val, found, nil
+}
+
+func parseJSON(r io.Reader, numbers jsonnumber.Mode) (map[string]any, error) {
+	var entries map[string]any
+	if err := jsonnumber.Decode(r, &entries, numbers); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// parseCSV reads the rows of a CSV document whose first row names the
+// columns. Later rows override earlier ones with the same key.
+func parseCSV(r io.Reader, keyColumn, valueColumn string) (map[string]any, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	keyIdx := 0
+	if keyColumn != "" {
+		if keyIdx = slices.Index(header, keyColumn); keyIdx < 0 {
+			return nil, fmt.Errorf("key column %q not found in header %v", keyColumn, header)
+		}
+	}
+	valueIdx := -1
+	if valueColumn != "" {
+		if valueIdx = slices.Index(header, valueColumn); valueIdx < 0 {
+			return nil, fmt.Errorf("value column %q not found in header %v", valueColumn, header)
+		}
+	}
+
+	entries := map[string]any{}
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if valueIdx >= 0 {
+			entries[row[keyIdx]] = row[valueIdx]
+			continue
+		}
+		value := make(map[string]any, len(row)-1)
+		for i, column := range header {
+			if i != keyIdx {
+				value[column] = row[i]
+			}
+		}
+		entries[row[keyIdx]] = value
+	}
+}
@@ -0,0 +1,450 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package s3
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/invert"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/jsonnumber"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/sourcetest"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+const hostsCSV = `ip,host,team
+10.0.0.1,web-1,storefront
+10.0.0.2,web-2,checkout
+`
+
+// stubClient serves a single object, or fails with err if set. The object
+// can be changed while a source refreshes it.
+type stubClient struct {
+	mu       sync.Mutex
+	object   string
+	err      error
+	requests []s3.GetObjectInput
+}
+
+func (c *stubClient) GetObject(_ context.Context, params *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests = append(c.requests, *params)
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader([]byte(c.object)))}, nil
+}
+
+func (c *stubClient) set(object string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.object = object
+	c.err = err
+}
+
+func newTestConfig() *Config {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Bucket = "enrichment"
+	cfg.Key = "hosts.csv"
+	return cfg
+}
+
+func newTestSource(cfg *Config, logger *zap.Logger, c *stubClient) lookupsource.Source {
+	return newSource(cfg, logger, func(context.Context, *Config) (client, error) { return c, nil })
+}
+
+func TestConfigValidate(t *testing.T) {
+	sourcetest.RunValidateTests(t, newTestConfig, []sourcetest.ValidateTest[*Config]{
+		{
+			Name: "valid",
+		},
+		{
+			Name:    "missing bucket",
+			Modify:  func(c *Config) { c.Bucket = "" },
+			WantErr: "bucket must be specified",
+		},
+		{
+			Name:    "missing key",
+			Modify:  func(c *Config) { c.Key = "" },
+			WantErr: "key must be specified",
+		},
+		{
+			Name:    "unsupported format",
+			Modify:  func(c *Config) { c.Format = "xml" },
+			WantErr: `unsupported format "xml"`,
+		},
+		{
+			Name: "columns with json",
+			Modify: func(c *Config) {
+				c.Format = FormatJSON
+				c.KeyColumn = "ip"
+			},
+			WantErr: "key_column and value_column can only be used with the csv format",
+		},
+		{
+			Name: "invert csv",
+			Modify: func(c *Config) {
+				c.ValueColumn = "host"
+				c.Invert = true
+			},
+		},
+		{
+			Name:    "invert csv without value column",
+			Modify:  func(c *Config) { c.Invert = true },
+			WantErr: "invert requires value_column with the csv format",
+		},
+		{
+			Name:    "invalid json numbers",
+			Modify:  func(c *Config) { c.JSONNumbers = "decimal" },
+			WantErr: `invalid json_numbers "decimal"`,
+		},
+		{
+			Name:    "unsupported invert collision",
+			Modify:  func(c *Config) { c.InvertCollision = "last" },
+			WantErr: `unsupported invert_collision "last"`,
+		},
+		{
+			Name:    "negative refresh interval",
+			Modify:  func(c *Config) { c.RefreshInterval = -1 },
+			WantErr: "refresh_interval must not be negative",
+		},
+	})
+}
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name   string
+		object string
+		modify func(*Config)
+		key    string
+		want   any
+	}{
+		{
+			name:   "csv row map",
+			object: hostsCSV,
+			key:    "10.0.0.1",
+			want:   map[string]any{"host": "web-1", "team": "storefront"},
+		},
+		{
+			name:   "csv value column",
+			object: hostsCSV,
+			modify: func(c *Config) { c.ValueColumn = "host" },
+			key:    "10.0.0.2",
+			want:   "web-2",
+		},
+		{
+			name:   "csv key column",
+			object: hostsCSV,
+			modify: func(c *Config) {
+				c.KeyColumn = "host"
+				c.ValueColumn = "team"
+			},
+			key:  "web-1",
+			want: "storefront",
+		},
+		{
+			name:   "json",
+			object: `{"10.0.0.1": "web-1", "10.0.0.2": {"host": "web-2", "port": 8080}}`,
+			modify: func(c *Config) { c.Format = FormatJSON },
+			key:    "10.0.0.2",
+			want:   map[string]any{"host": "web-2", "port": float64(8080)},
+		},
+		{
+			name:   "json int64 numbers",
+			object: `{"10.0.0.1": {"account_id": 9007199254740993, "load": 0.5}}`,
+			modify: func(c *Config) {
+				c.Format = FormatJSON
+				c.JSONNumbers = jsonnumber.Int64
+			},
+			key:  "10.0.0.1",
+			want: map[string]any{"account_id": int64(9007199254740993), "load": 0.5},
+		},
+		{
+			name:   "json string numbers",
+			object: `{"10.0.0.1": {"account_id": 123456789012345678901234567890}}`,
+			modify: func(c *Config) {
+				c.Format = FormatJSON
+				c.JSONNumbers = jsonnumber.String
+			},
+			key:  "10.0.0.1",
+			want: map[string]any{"account_id": "123456789012345678901234567890"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			if tt.modify != nil {
+				tt.modify(cfg)
+			}
+			c := &stubClient{object: tt.object}
+			source := sourcetest.Start(t, newTestSource(cfg, zap.NewNop(), c))
+
+			val, found, err := source.Lookup(t.Context(), tt.key)
+			require.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, tt.want, val)
+
+			_, found, err = source.Lookup(t.Context(), "10.0.0.9")
+			require.NoError(t, err)
+			assert.False(t, found)
+
+			require.Len(t, c.requests, 1)
+			assert.Equal(t, "enrichment", aws.ToString(c.requests[0].Bucket))
+			assert.Equal(t, "hosts.csv", aws.ToString(c.requests[0].Key))
+		})
+	}
+}
+
+func TestLookupCompressed(t *testing.T) {
+	var gzipped bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	_, err := gw.Write([]byte(hostsCSV))
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+
+	var zstded bytes.Buffer
+	zw, err := zstd.NewWriter(&zstded)
+	require.NoError(t, err)
+	_, err = zw.Write([]byte(hostsCSV))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	tests := []struct {
+		name   string
+		key    string
+		object []byte
+	}{
+		{name: "gzip", key: "hosts.csv.gz", object: gzipped.Bytes()},
+		{name: "zstd", key: "hosts.csv.zst", object: zstded.Bytes()},
+		// Compressed objects are detected without the extension too.
+		{name: "gzip without extension", key: "hosts.csv", object: gzipped.Bytes()},
+		{name: "zstd without extension", key: "hosts.csv", object: zstded.Bytes()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.Key = tt.key
+			cfg.ValueColumn = "host"
+			source := sourcetest.Start(t, newTestSource(cfg, zap.NewNop(), &stubClient{object: string(tt.object)}))
+
+			for key, want := range map[string]string{"10.0.0.1": "web-1", "10.0.0.2": "web-2"} {
+				val, found, err := source.Lookup(t.Context(), key)
+				require.NoError(t, err)
+				assert.True(t, found)
+				assert.Equal(t, want, val)
+			}
+		})
+	}
+
+	t.Run("corrupt", func(t *testing.T) {
+		cfg := newTestConfig()
+		cfg.Key = "hosts.csv.gz"
+		source := newTestSource(cfg, zap.NewNop(), &stubClient{object: hostsCSV})
+		assert.ErrorContains(t, source.Start(t.Context(), componenttest.NewNopHost()), "failed to read s3://enrichment/hosts.csv.gz")
+	})
+}
+
+func TestLookupInverted(t *testing.T) {
+	const teamsCSV = `ip,team
+10.0.0.3,checkout
+10.0.0.1,storefront
+10.0.0.2,checkout
+`
+
+	tests := []struct {
+		name         string
+		object       string
+		modify       func(*Config)
+		key          string
+		want         any
+		wantWarnings int
+	}{
+		{
+			name:   "unique",
+			object: hostsCSV,
+			modify: func(c *Config) { c.ValueColumn = "host" },
+			key:    "web-2",
+			want:   "10.0.0.2",
+		},
+		{
+			name:   "json",
+			object: `{"10.0.0.1": "web-1", "10.0.0.2": "web-2"}`,
+			modify: func(c *Config) { c.Format = FormatJSON },
+			key:    "web-1",
+			want:   "10.0.0.1",
+		},
+		{
+			name:         "collision first",
+			object:       teamsCSV,
+			modify:       func(c *Config) { c.ValueColumn = "team" },
+			key:          "checkout",
+			want:         "10.0.0.2",
+			wantWarnings: 1,
+		},
+		{
+			name:   "collision list",
+			object: teamsCSV,
+			modify: func(c *Config) {
+				c.ValueColumn = "team"
+				c.InvertCollision = invert.List
+			},
+			key:  "checkout",
+			want: []any{"10.0.0.2", "10.0.0.3"},
+		},
+		{
+			name:   "unique list",
+			object: teamsCSV,
+			modify: func(c *Config) {
+				c.ValueColumn = "team"
+				c.InvertCollision = invert.List
+			},
+			key:  "storefront",
+			want: []any{"10.0.0.1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zap.WarnLevel)
+			cfg := newTestConfig()
+			cfg.Invert = true
+			tt.modify(cfg)
+			source := sourcetest.Start(t, newTestSource(cfg, zap.New(core), &stubClient{object: tt.object}))
+
+			val, found, err := source.Lookup(t.Context(), tt.key)
+			require.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, tt.want, val)
+			assert.Equal(t, tt.wantWarnings, logs.Len())
+
+			// Keys are no longer looked up.
+			_, found, err = source.Lookup(t.Context(), "10.0.0.1")
+			require.NoError(t, err)
+			assert.False(t, found)
+		})
+	}
+}
+
+func TestStartError(t *testing.T) {
+	tests := []struct {
+		name    string
+		object  string
+		err     error
+		modify  func(*Config)
+		wantErr string
+	}{
+		{
+			name:    "download",
+			err:     errors.New("access denied"),
+			wantErr: "failed to download s3://enrichment/hosts.csv: access denied",
+		},
+		{
+			name:    "missing column",
+			object:  "address,host\n10.0.0.1,web-1\n",
+			wantErr: `failed to parse s3://enrichment/hosts.csv: value column "team" not found`,
+		},
+		{
+			name:    "inconsistent row",
+			object:  "ip,team\n10.0.0.1\n",
+			wantErr: "failed to parse s3://enrichment/hosts.csv",
+		},
+		{
+			name:    "invert collision",
+			object:  "ip,team\n10.0.0.1,checkout\n10.0.0.2,checkout\n",
+			modify:  func(c *Config) { c.Invert, c.InvertCollision = true, invert.Error },
+			wantErr: `failed to invert s3://enrichment/hosts.csv: value "checkout" is shared by keys [10.0.0.1 10.0.0.2]`,
+		},
+		{
+			name:   "invert non-string value",
+			object: `{"10.0.0.1": {"team": "checkout"}}`,
+			modify: func(c *Config) {
+				c.Format, c.ValueColumn = FormatJSON, ""
+				c.Invert = true
+			},
+			wantErr: `failed to invert s3://enrichment/hosts.csv: value of key "10.0.0.1" is not a string`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.ValueColumn = "team"
+			if tt.modify != nil {
+				tt.modify(cfg)
+			}
+			source := newTestSource(cfg, zap.NewNop(), &stubClient{object: tt.object, err: tt.err})
+			assert.ErrorContains(t, source.Start(t.Context(), componenttest.NewNopHost()), tt.wantErr)
+		})
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	cfg := newTestConfig()
+	cfg.ValueColumn = "host"
+	cfg.RefreshInterval = 10 * time.Millisecond
+	c := &stubClient{object: hostsCSV}
+	source := sourcetest.Start(t, newTestSource(cfg, zap.New(core), c))
+
+	lookup := func(key string) any {
+		val, _, err := source.Lookup(t.Context(), key)
+		require.NoError(t, err)
+		return val
+	}
+	assert.Equal(t, "web-1", lookup("10.0.0.1"))
+
+	// The new snapshot replaces the previous one as a whole.
+	c.set("ip,host\n10.0.0.3,web-3\n", nil)
+	require.Eventually(t, func() bool { return lookup("10.0.0.3") == "web-3" }, 5*time.Second, 5*time.Millisecond)
+	assert.Nil(t, lookup("10.0.0.1"))
+
+	// Failed refreshes keep serving the last good snapshot.
+	c.set("", errors.New("throttled"))
+	require.Eventually(t, func() bool { return logs.Len() > 0 }, 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, "web-3", lookup("10.0.0.3"))
+	assert.Equal(t, "Failed to refresh S3 object, keeping the previous entries", logs.All()[0].Message)
+}
+
+func TestRefreshClearsChainCache(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.ValueColumn = "host"
+	cfg.RefreshInterval = 10 * time.Millisecond
+	c := &stubClient{object: hostsCSV}
+	chain := lookupsource.NewChainWithConfig(
+		lookupsource.ChainConfig{Cache: lookupsource.CacheConfig{Enabled: true}},
+		newTestSource(cfg, zap.NewNop(), c),
+	)
+	require.NoError(t, chain.Start(t.Context(), componenttest.NewNopHost()))
+	defer func() { require.NoError(t, chain.Shutdown(t.Context())) }()
+
+	lookup := func(key string) any {
+		val, _, err := chain.Lookup(t.Context(), key)
+		require.NoError(t, err)
+		return val
+	}
+	assert.Equal(t, "web-1", lookup("10.0.0.1"))
+
+	// The chain caches results without expiration, so only the refresh
+	// clearing its cache lets the new value through.
+	c.set("ip,host\n10.0.0.1,web-1-new\n", nil)
+	require.Eventually(t, func() bool { return lookup("10.0.0.1") == "web-1-new" }, 5*time.Second, 5*time.Millisecond)
+}
@@ -0,0 +1,276 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package sql provides a lookup source backed by a SQL database.
+//
+// The configured query is prepared once on start and executed with the lookup
+// key as its only parameter, or with the parts of compound keys named by
+// params. The first column of the first returned row is
+// used as the lookup value. The database driver must be registered with
+// database/sql by the collector distribution.
+package sql // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/sql"
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+const sourceType = "sql"
+
+var (
+	errMissingDriver     = errors.New("driver must be specified")
+	errMissingDataSource = errors.New("datasource must be specified")
+	errMissingQuery      = errors.New("query must be specified")
+	errNotStarted        = errors.New("sql source not started")
+	errSingleKey         = errors.New("sql source with params only serves compound key lookups")
+
+	// placeholderRegexp matches the positional placeholders supported by the
+	// common drivers: "?" (MySQL, SQLite) and "$N" (PostgreSQL).
+	placeholderRegexp = regexp.MustCompile(`\?|\$[0-9]+`)
+)
+
+type Config struct {
+	// Driver is the name of the database/sql driver (e.g., "postgres", "mysql").
+	Driver string `mapstructure:"driver"`
+
+	// DataSource is the driver-specific connection string.
+	DataSource string `mapstructure:"datasource"`
+
+	// Query is executed with the lookup key as its only parameter.
+	// It must contain exactly one placeholder ("$1" or "?"), or one per
+	// entry of Params, and return a single column.
+	Query string `mapstructure:"query"`
+
+	// Params are the names of the parts of compound keys bound to the
+	// placeholders of Query, in order, such as [ip, port]. With params,
+	// the source only serves lookups with several source attributes.
+	Params []string `mapstructure:"params"`
+
+	// MaxOpenConns limits the number of open connections to the database.
+	// Default: 0 (unlimited)
+	MaxOpenConns int `mapstructure:"max_open_conns"`
+
+	// MaxIdleConns limits the number of idle connections kept in the pool.
+	// Default: 2
+	MaxIdleConns int `mapstructure:"max_idle_conns"`
+
+	// ConnMaxLifetime is the maximum time a connection may be reused.
+	// Default: 0 (no limit)
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`
+
+	// Timeout bounds the duration of a single query.
+	// Default: 5s
+	Timeout time.Duration `mapstructure:"timeout"`
+
+	Cache lookupsource.CacheConfig `mapstructure:"cache"`
+}
+
+func (c *Config) Validate() error {
+	if c.Driver == "" {
+		return errMissingDriver
+	}
+	if c.DataSource == "" {
+		return errMissingDataSource
+	}
+	if c.Query == "" {
+		return errMissingQuery
+	}
+	n := len(placeholderRegexp.FindAllString(c.Query, -1))
+	if len(c.Params) == 0 && n != 1 {
+		return fmt.Errorf("query must contain exactly one placeholder for the key, found %d", n)
+	}
+	if len(c.Params) > 0 && n != len(c.Params) {
+		return fmt.Errorf("query must contain one placeholder per param, found %d for %d params", n, len(c.Params))
+	}
+	seen := make(map[string]bool, len(c.Params))
+	for _, param := range c.Params {
+		if param == "" {
+			return errors.New("params must not be empty")
+		}
+		if seen[param] {
+			return fmt.Errorf("duplicate param %q", param)
+		}
+		seen[param] = true
+	}
+	if c.MaxOpenConns < 0 {
+		return errors.New("max_open_conns must not be negative")
+	}
+	if c.MaxIdleConns < 0 {
+		return errors.New("max_idle_conns must not be negative")
+	}
+	if c.ConnMaxLifetime < 0 {
+		return errors.New("conn_max_lifetime must not be negative")
+	}
+	if c.Timeout < 0 {
+		return errors.New("timeout must not be negative")
+	}
+	return nil
+}
+
+func NewFactory() lookupsource.SourceFactory {
+	return lookupsource.NewSourceFactory(
+		sourceType,
+		createDefaultConfig,
+		createSource,
+	)
+}
+
+func createDefaultConfig() lookupsource.SourceConfig {
+	return &Config{
+		MaxIdleConns: 2,
+		Timeout:      5 * time.Second,
+		Cache: lookupsource.CacheConfig{
+			Enabled: false,
+			Size:    1000,
+		},
+	}
+}
+
+func createSource(
+	_ context.Context,
+	_ lookupsource.CreateSettings,
+	cfg lookupsource.SourceConfig,
+) (lookupsource.Source, error) {
+	sqlCfg := cfg.(*Config)
+
+	cache := lookupsource.NewCache(sqlCfg.Cache)
+	s := &sqlSource{cfg: sqlCfg, cache: cache}
+
+	opts := []lookupsource.SourceOption{
+		lookupsource.WithHealthCheck(s.checkHealth),
+		lookupsource.WithCache(cache),
+	}
+	var lookup lookupsource.LookupFunc
+	if len(sqlCfg.Params) == 0 {
+		lookup = lookupsource.WrapWithCache(cache, s.lookup)
+	} else {
+		// Compound keys are cached under their encoded form.
+		multiKeyLookup := lookupsource.WrapWithCache(cache, lookupsource.CompoundLookup(s.lookupParams))
+		lookup = func(context.Context, string) (any, bool, error) {
+			return nil, false, lookupsource.Permanent(errSingleKey)
+		}
+		opts = append(opts, lookupsource.WithMultiKeyLookup(func(ctx context.Context, keys map[string]string) (any, bool, error) {
+			return multiKeyLookup(ctx, lookupsource.CompoundKey(keys))
+		}))
+	}
+
+	return lookupsource.NewSource(
+		lookup,
+		func() string { return sourceType },
+		s.start,
+		s.shutdown,
+		opts...,
+	), nil
+}
+
+type sqlSource struct {
+	cfg   *Config
+	db    *sql.DB
+	stmt  *sql.Stmt
+	cache *lookupsource.Cache
+}
+
+func (s *sqlSource) start(ctx context.Context, _ component.Host) error {
+	db, err := sql.Open(s.cfg.Driver, s.cfg.DataSource)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	db.SetMaxOpenConns(s.cfg.MaxOpenConns)
+	db.SetMaxIdleConns(s.cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(s.cfg.ConnMaxLifetime)
+
+	if err = db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	stmt, err := db.PrepareContext(ctx, s.cfg.Query)
+	if err != nil {
+		_ = db.Close()
+		return fmt.Errorf("failed to prepare query: %w", err)
+	}
+
+	s.db = db
+	s.stmt = stmt
+	return nil
+}
+
+func (s *sqlSource) shutdown(ctx context.Context) error {
+	errs := s.cache.Shutdown(ctx)
+	if s.db == nil {
+		return errs
+	}
+	if s.stmt != nil {
+		errs = errors.Join(errs, s.stmt.Close())
+	}
+	errs = errors.Join(errs, s.db.Close())
+	s.stmt = nil
+	s.db = nil
+	return errs
+}
+
+func (s *sqlSource) checkHealth(ctx context.Context) error {
+	if s.db == nil {
+		return errNotStarted
+	}
+	return s.db.PingContext(ctx)
+}
+
+func (s *sqlSource) lookup(ctx context.Context, key string) (any, bool, error) {
+	return s.query(ctx, key)
+}
+
+// lookupParams looks up a compound key, binding its parts to the
+// placeholders of the query in the order of params.
+func (s *sqlSource) lookupParams(ctx context.Context, keys map[string]string) (any, bool, error) {
+	args := make([]any, len(s.cfg.Params))
+	for i, param := range s.cfg.Params {
+		val, ok := keys[param]
+		if !ok {
+			return nil, false, lookupsource.Permanent(fmt.Errorf("compound key has no %q part", param))
+		}
+		args[i] = val
+	}
+	return s.query(ctx, args...)
+}
+
+// query runs the query with args and returns the first column of the first
+// row.
+func (s *sqlSource) query(ctx context.Context, args ...any) (any, bool, error) {
+	if s.stmt == nil {
+		return nil, false, errNotStarted
+	}
+
+	if s.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.cfg.Timeout)
+		defer cancel()
+	}
+
+	var value any
+	err := s.stmt.QueryRowContext(ctx, args...).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("sql lookup failed: %w", err)
+	}
+
+	switch v := value.(type) {
+	case nil:
+		// A NULL column is indistinguishable from a missing row for enrichment.
+		return nil, false, nil
+	case []byte:
+		return string(v), true, nil
+	default:
+		return v, true, nil
+	}
+}
@@ -0,0 +1,251 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package sql
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+const (
+	testQuery       = "SELECT name FROM hosts WHERE ip = $1"
+	testParamsQuery = "SELECT owner FROM connections WHERE ip = $1 AND port = $2"
+)
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{
+			name: "valid postgres placeholder",
+			cfg:  Config{Driver: "postgres", DataSource: "dsn", Query: testQuery},
+		},
+		{
+			name: "valid mysql placeholder",
+			cfg:  Config{Driver: "mysql", DataSource: "dsn", Query: "SELECT name FROM hosts WHERE ip = ?"},
+		},
+		{
+			name:    "missing driver",
+			cfg:     Config{DataSource: "dsn", Query: testQuery},
+			wantErr: "driver must be specified",
+		},
+		{
+			name:    "missing datasource",
+			cfg:     Config{Driver: "postgres", Query: testQuery},
+			wantErr: "datasource must be specified",
+		},
+		{
+			name:    "missing query",
+			cfg:     Config{Driver: "postgres", DataSource: "dsn"},
+			wantErr: "query must be specified",
+		},
+		{
+			name:    "no placeholder",
+			cfg:     Config{Driver: "postgres", DataSource: "dsn", Query: "SELECT name FROM hosts"},
+			wantErr: "exactly one placeholder",
+		},
+		{
+			name:    "too many placeholders",
+			cfg:     Config{Driver: "postgres", DataSource: "dsn", Query: "SELECT name FROM hosts WHERE ip = $1 OR ip = $2"},
+			wantErr: "exactly one placeholder",
+		},
+		{
+			name: "valid params",
+			cfg:  Config{Driver: "postgres", DataSource: "dsn", Query: testParamsQuery, Params: []string{"ip", "port"}},
+		},
+		{
+			name:    "params placeholder mismatch",
+			cfg:     Config{Driver: "postgres", DataSource: "dsn", Query: testQuery, Params: []string{"ip", "port"}},
+			wantErr: "one placeholder per param, found 1 for 2 params",
+		},
+		{
+			name:    "empty param",
+			cfg:     Config{Driver: "postgres", DataSource: "dsn", Query: testParamsQuery, Params: []string{"ip", ""}},
+			wantErr: "params must not be empty",
+		},
+		{
+			name:    "duplicate param",
+			cfg:     Config{Driver: "postgres", DataSource: "dsn", Query: testParamsQuery, Params: []string{"ip", "ip"}},
+			wantErr: `duplicate param "ip"`,
+		},
+		{
+			name:    "negative pool size",
+			cfg:     Config{Driver: "postgres", DataSource: "dsn", Query: testQuery, MaxOpenConns: -1},
+			wantErr: "max_open_conns must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func newTestSource(t *testing.T) (lookupsource.Source, sqlmock.Sqlmock) {
+	t.Helper()
+	return newTestSourceWithConfig(t, func(*Config) {})
+}
+
+// newTestSourceWithConfig returns a started source whose config, querying
+// testQuery, is modified by modify.
+func newTestSourceWithConfig(t *testing.T, modify func(*Config)) (lookupsource.Source, sqlmock.Sqlmock) {
+	t.Helper()
+
+	dsn := t.Name()
+	db, mock, err := sqlmock.NewWithDSN(dsn, sqlmock.MonitorPingsOption(true))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.Driver = "sqlmock"
+	cfg.DataSource = dsn
+	cfg.Query = testQuery
+	modify(cfg)
+	require.NoError(t, cfg.Validate())
+
+	mock.ExpectPing()
+	mock.ExpectPrepare(regexp.QuoteMeta(cfg.Query))
+
+	source, err := NewFactory().CreateSource(t.Context(), lookupsource.CreateSettings{}, cfg)
+	require.NoError(t, err)
+	require.NoError(t, source.Start(t.Context(), componenttest.NewNopHost()))
+
+	return source, mock
+}
+
+func TestLookup(t *testing.T) {
+	t.Run("matching row", func(t *testing.T) {
+		source, mock := newTestSource(t)
+		mock.ExpectQuery(regexp.QuoteMeta(testQuery)).
+			WithArgs("10.0.0.1").
+			WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow([]byte("web-1")))
+
+		val, found, err := source.Lookup(t.Context(), "10.0.0.1")
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "web-1", val)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("no rows", func(t *testing.T) {
+		source, mock := newTestSource(t)
+		mock.ExpectQuery(regexp.QuoteMeta(testQuery)).
+			WithArgs("10.0.0.2").
+			WillReturnRows(sqlmock.NewRows([]string{"name"}))
+
+		val, found, err := source.Lookup(t.Context(), "10.0.0.2")
+		require.NoError(t, err)
+		assert.False(t, found)
+		assert.Nil(t, val)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		source, mock := newTestSource(t)
+		queryErr := errors.New("connection reset")
+		mock.ExpectQuery(regexp.QuoteMeta(testQuery)).
+			WithArgs("10.0.0.3").
+			WillReturnError(queryErr)
+
+		_, found, err := source.Lookup(t.Context(), "10.0.0.3")
+		require.ErrorIs(t, err, queryErr)
+		assert.False(t, found)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestMultiKeyLookup(t *testing.T) {
+	source, mock := newTestSourceWithConfig(t, func(cfg *Config) {
+		cfg.Query = testParamsQuery
+		cfg.Params = []string{"ip", "port"}
+		cfg.Cache.Enabled = true
+	})
+	ms, ok := source.(lookupsource.MultiKeySource)
+	require.True(t, ok)
+
+	mock.ExpectQuery(regexp.QuoteMeta(testParamsQuery)).
+		WithArgs("10.0.0.1", "5432").
+		WillReturnRows(sqlmock.NewRows([]string{"owner"}).AddRow([]byte("postgres")))
+	mock.ExpectQuery(regexp.QuoteMeta(testParamsQuery)).
+		WithArgs("10.0.0.1", "443").
+		WillReturnRows(sqlmock.NewRows([]string{"owner"}))
+
+	// The second lookup of the same compound key is served by the cache.
+	for range 2 {
+		val, found, err := ms.MultiKeyLookup(t.Context(), map[string]string{"ip": "10.0.0.1", "port": "5432"})
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "postgres", val)
+	}
+	val, found, err := ms.MultiKeyLookup(t.Context(), map[string]string{"port": "443", "ip": "10.0.0.1"})
+	require.NoError(t, err)
+	assert.False(t, found)
+	assert.Nil(t, val)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	_, _, err = ms.MultiKeyLookup(t.Context(), map[string]string{"ip": "10.0.0.1"})
+	require.ErrorContains(t, err, `compound key has no "port" part`)
+	assert.True(t, lookupsource.IsPermanent(err))
+
+	_, _, err = source.Lookup(t.Context(), "10.0.0.1")
+	require.ErrorIs(t, err, errSingleKey)
+
+	// Sources without params only serve single key lookups.
+	single, _ := newTestSource(t)
+	_, ok = single.(lookupsource.MultiKeySource)
+	assert.False(t, ok)
+}
+
+func TestLookupBeforeStart(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Driver = "sqlmock"
+	cfg.DataSource = t.Name()
+	cfg.Query = testQuery
+
+	source, err := NewFactory().CreateSource(t.Context(), lookupsource.CreateSettings{}, cfg)
+	require.NoError(t, err)
+
+	_, _, err = source.Lookup(t.Context(), "10.0.0.1")
+	assert.ErrorIs(t, err, errNotStarted)
+}
+
+func TestCheckHealth(t *testing.T) {
+	source, mock := newTestSource(t)
+	checker := source.(lookupsource.HealthChecker)
+
+	mock.ExpectPing()
+	assert.NoError(t, checker.CheckHealth(t.Context()))
+
+	pingErr := errors.New("connection refused")
+	mock.ExpectPing().WillReturnError(pingErr)
+	assert.ErrorIs(t, checker.CheckHealth(t.Context()), pingErr)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShutdown(t *testing.T) {
+	source, mock := newTestSource(t)
+	mock.ExpectClose()
+
+	require.NoError(t, source.Shutdown(t.Context()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// A second shutdown is a no-op.
+	assert.NoError(t, source.Shutdown(t.Context()))
+}
@@ -0,0 +1,355 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package sqlite provides a lookup source backed by a local SQLite database
+// file, such as one shipped alongside the collector.
+//
+// The file is opened read-only with the pure Go driver from modernc.org/sqlite,
+// so the collector needs no cgo. The configured query is prepared on open and
+// executed with the lookup key as its only parameter; the first column of the
+// first returned row is used as the lookup value. When the file is replaced,
+// the new one is opened and prepared before it replaces the previous one.
+package sqlite // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/sqlite"
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+	_ "modernc.org/sqlite" // registers the "sqlite" driver
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/metadata"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+const (
+	sourceType = "sqlite"
+	driverName = "sqlite"
+)
+
+var (
+	errNotStarted = errors.New("sqlite source not started")
+
+	// placeholderRegexp matches the placeholders of a SQLite query bound by
+	// position: "?" and "?N".
+	placeholderRegexp = regexp.MustCompile(`\?[0-9]*`)
+
+	// Results of reloads recorded in the result attribute of the reload
+	// counter.
+	successfulReload = metric.WithAttributeSet(attribute.NewSet(
+		attribute.String("source_type", sourceType), attribute.String("result", "success")))
+	failedReload = metric.WithAttributeSet(attribute.NewSet(
+		attribute.String("source_type", sourceType), attribute.String("result", "failure")))
+)
+
+type Config struct {
+	// Path is the path of the SQLite database file, which is opened
+	// read-only.
+	Path string `mapstructure:"path"`
+
+	// Query is executed with the lookup key as its only parameter. It must
+	// contain exactly one placeholder ("?" or "?1") and return a single
+	// column.
+	Query string `mapstructure:"query"`
+
+	// RefreshInterval is how often the file is checked for changes; it is
+	// opened again when it is replaced or modified. Zero disables reopening.
+	// Default: 1m
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"`
+
+	// Timeout bounds the duration of a single query.
+	// Default: 5s
+	Timeout time.Duration `mapstructure:"timeout"`
+
+	Cache lookupsource.CacheConfig `mapstructure:"cache"`
+}
+
+func (c *Config) Validate() error {
+	var errs lookupsource.FieldErrors
+	if c.Path == "" {
+		errs.Addf("path", "must be specified")
+	}
+	if c.Query == "" {
+		errs.Addf("query", "must be specified")
+	} else if n := len(placeholderRegexp.FindAllString(c.Query, -1)); n != 1 {
+		errs.Addf("query", "must contain exactly one placeholder for the key, found %d", n)
+	}
+	if c.RefreshInterval < 0 {
+		errs.Addf("refresh_interval", "must not be negative, got %s", c.RefreshInterval)
+	}
+	if c.Timeout < 0 {
+		errs.Addf("timeout", "must not be negative, got %s", c.Timeout)
+	}
+	return errs.Err()
+}
+
+func NewFactory() lookupsource.SourceFactory {
+	return lookupsource.NewSourceFactory(
+		sourceType,
+		createDefaultConfig,
+		createSource,
+	)
+}
+
+func createDefaultConfig() lookupsource.SourceConfig {
+	return &Config{
+		RefreshInterval: time.Minute,
+		Timeout:         5 * time.Second,
+		Cache: lookupsource.CacheConfig{
+			Enabled: true,
+			Size:    10000,
+		},
+	}
+}
+
+func createSource(
+	_ context.Context,
+	settings lookupsource.CreateSettings,
+	cfg lookupsource.SourceConfig,
+) (lookupsource.Source, error) {
+	meter := settings.TelemetrySettings.MeterProvider.Meter(metadata.ScopeName)
+	return newSource(cfg.(*Config), settings.TelemetrySettings.Logger, meter), nil
+}
+
+func newSource(cfg *Config, logger *zap.Logger, meter metric.Meter) lookupsource.Source {
+	reloads, err := meter.Int64Counter(
+		"otelcol_lookup_source_reloads",
+		metric.WithDescription("Number of reloads of the source data, by result."),
+		metric.WithUnit("{reloads}"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+	s := &sqliteSource{
+		cfg:     cfg,
+		logger:  logger,
+		cache:   lookupsource.NewCache(cfg.Cache),
+		reloads: reloads,
+	}
+	s.cachedQuery = lookupsource.WrapWithCache(s.cache, s.query)
+
+	return lookupsource.NewSource(
+		s.lookup,
+		func() string { return sourceType },
+		s.start,
+		s.shutdown,
+		lookupsource.WithHealthCheck(s.checkHealth),
+		lookupsource.WithCache(s.cache),
+	)
+}
+
+type sqliteSource struct {
+	cfg    *Config
+	logger *zap.Logger
+	cache  *lookupsource.Cache
+	// reloads counts the reopenings of the file by result.
+	reloads metric.Int64Counter
+	// cachedQuery is query fronted by the cache.
+	cachedQuery lookupsource.LookupFunc
+
+	// mu guards the database and the cache, so that reopening the file
+	// neither closes the database under a running query nor lets a query
+	// cache a value of the previous file.
+	mu sync.RWMutex
+	db *database
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// database is an open database file with the query prepared.
+type database struct {
+	db   *sql.DB
+	stmt *sql.Stmt
+	// info describes the file when it was opened.
+	info os.FileInfo
+}
+
+func (d *database) close() error {
+	return errors.Join(d.stmt.Close(), d.db.Close())
+}
+
+// start opens the file and starts watching it. The collector fails to start
+// if the file can't be opened or the query prepared.
+func (s *sqliteSource) start(ctx context.Context, _ component.Host) error {
+	d, err := s.open(ctx)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.db = d
+	s.mu.Unlock()
+
+	if s.cfg.RefreshInterval > 0 {
+		var refreshCtx context.Context
+		refreshCtx, s.cancel = context.WithCancel(context.Background())
+		s.wg.Add(1)
+		go s.refresh(refreshCtx, d.info)
+	}
+	return nil
+}
+
+func (s *sqliteSource) shutdown(ctx context.Context) error {
+	if s.cancel != nil {
+		s.cancel()
+		s.wg.Wait()
+		s.cancel = nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var errs []error
+	if s.db != nil {
+		errs = append(errs, s.db.close())
+		s.db = nil
+	}
+	errs = append(errs, s.cache.Shutdown(ctx))
+	return errors.Join(errs...)
+}
+
+// open opens the file read-only and prepares the query.
+func (s *sqliteSource) open(ctx context.Context) (*database, error) {
+	// The file is stated before it is opened, so that a file replaced in
+	// between is seen as changed on the next check.
+	info, err := os.Stat(s.cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", s.cfg.Path, err)
+	}
+	db, err := sql.Open(driverName, dataSourceName(s.cfg.Path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", s.cfg.Path, err)
+	}
+	if err = db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to open %s: %w", s.cfg.Path, err)
+	}
+	stmt, err := db.PrepareContext(ctx, s.cfg.Query)
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to prepare query on %s: %w", s.cfg.Path, err)
+	}
+	return &database{db: db, stmt: stmt, info: info}, nil
+}
+
+// dataSourceName returns the URI opening path read-only. query_only also
+// rejects writes from the query itself.
+func dataSourceName(path string) string {
+	u := url.URL{Scheme: "file", Path: path, RawQuery: "mode=ro&_pragma=query_only(1)"}
+	return u.String()
+}
+
+// refresh opens the file again whenever it is replaced or modified, until
+// ctx is done. A file that can't be opened, or on which the query can't be
+// prepared, keeps the previous one in use.
+func (s *sqliteSource) refresh(ctx context.Context, opened os.FileInfo) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.cfg.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(s.cfg.Path)
+			if err == nil && !changed(opened, info) {
+				continue
+			}
+			var d *database
+			if err == nil {
+				d, err = s.open(ctx)
+			}
+			if err != nil {
+				s.logger.Warn("Failed to reopen database, keeping the previous one",
+					zap.String("path", s.cfg.Path), zap.Error(err))
+				s.reloads.Add(ctx, 1, failedReload)
+				continue
+			}
+			opened = d.info
+			s.swap(d)
+			s.reloads.Add(ctx, 1, successfulReload)
+		}
+	}
+}
+
+// changed reports whether the file described by info is not the one opened,
+// either because it was replaced or because it was modified in place.
+func changed(opened, info os.FileInfo) bool {
+	return !os.SameFile(opened, info) ||
+		!info.ModTime().Equal(opened.ModTime()) ||
+		info.Size() != opened.Size()
+}
+
+// swap replaces the database with d and drops the cached values of the
+// previous file.
+func (s *sqliteSource) swap(d *database) {
+	s.mu.Lock()
+	previous := s.db
+	s.db = d
+	s.cache.Clear()
+	s.mu.Unlock()
+
+	if previous != nil {
+		_ = previous.close()
+	}
+}
+
+func (s *sqliteSource) checkHealth(ctx context.Context) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.db == nil {
+		return errNotStarted
+	}
+	return s.db.db.PingContext(ctx)
+}
+
+func (s *sqliteSource) lookup(ctx context.Context, key string) (any, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cachedQuery(ctx, key)
+}
+
+// query runs the query with key and returns the first column of the first
+// row. s.mu must be held.
+func (s *sqliteSource) query(ctx context.Context, key string) (any, bool, error) {
+	if s.db == nil {
+		return nil, false, errNotStarted
+	}
+
+	if s.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.cfg.Timeout)
+		defer cancel()
+	}
+
+	var value any
+	err := s.db.stmt.QueryRowContext(ctx, key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("sqlite lookup failed: %w", err)
+	}
+
+	switch v := value.(type) {
+	case nil:
+		// A NULL column is indistinguishable from a missing row for enrichment.
+		return nil, false, nil
+	case []byte:
+		return string(v), true, nil
+	default:
+		return v, true, nil
+	}
+}
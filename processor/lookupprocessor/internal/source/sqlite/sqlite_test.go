@@ -0,0 +1,206 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package sqlite
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/otel/metric/noop"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+const testQuery = "SELECT name FROM users WHERE id = ?"
+
+// writeDB creates a SQLite database at path holding a users table with
+// users, by id.
+func writeDB(t *testing.T, path string, users map[string]any) {
+	t.Helper()
+	db, err := sql.Open(driverName, path)
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE users (id TEXT PRIMARY KEY, name TEXT)")
+	require.NoError(t, err)
+	for id, name := range users {
+		_, err = db.Exec("INSERT INTO users (id, name) VALUES (?, ?)", id, name)
+		require.NoError(t, err)
+	}
+}
+
+func newTestConfig(path string) *Config {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Path = path
+	cfg.Query = testQuery
+	return cfg
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr string
+	}{
+		{
+			name: "valid",
+		},
+		{
+			name:   "numbered placeholder",
+			modify: func(c *Config) { c.Query = "SELECT name FROM users WHERE id = ?1" },
+		},
+		{
+			name:    "missing path",
+			modify:  func(c *Config) { c.Path = "" },
+			wantErr: "path: must be specified",
+		},
+		{
+			name:    "missing query",
+			modify:  func(c *Config) { c.Query = "" },
+			wantErr: "query: must be specified",
+		},
+		{
+			name:    "no placeholder",
+			modify:  func(c *Config) { c.Query = "SELECT name FROM users" },
+			wantErr: "query: must contain exactly one placeholder for the key, found 0",
+		},
+		{
+			name:    "two placeholders",
+			modify:  func(c *Config) { c.Query = "SELECT name FROM users WHERE id = ? OR name = ?" },
+			wantErr: "query: must contain exactly one placeholder for the key, found 2",
+		},
+		{
+			name:    "negative refresh interval",
+			modify:  func(c *Config) { c.RefreshInterval = -1 },
+			wantErr: "refresh_interval: must not be negative",
+		},
+		{
+			name:    "negative timeout",
+			modify:  func(c *Config) { c.Timeout = -1 },
+			wantErr: "timeout: must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig("users.db")
+			if tt.modify != nil {
+				tt.modify(cfg)
+			}
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.db")
+	writeDB(t, path, map[string]any{"user001": "Alice", "user002": nil})
+
+	source, err := NewFactory().CreateSource(t.Context(), lookupsource.CreateSettings{TelemetrySettings: componenttest.NewNopTelemetrySettings()}, newTestConfig(path))
+	require.NoError(t, err)
+	require.NoError(t, source.Start(t.Context(), componenttest.NewNopHost()))
+	defer func() { require.NoError(t, source.Shutdown(t.Context())) }()
+
+	val, found, err := source.Lookup(t.Context(), "user001")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "Alice", val)
+
+	// Missing rows and NULL values are both misses.
+	for _, key := range []string{"user002", "user003"} {
+		val, found, err = source.Lookup(t.Context(), key)
+		require.NoError(t, err)
+		assert.False(t, found, key)
+		assert.Nil(t, val, key)
+	}
+}
+
+func TestLookupNotStarted(t *testing.T) {
+	source := newSource(newTestConfig("users.db"), zap.NewNop(), noop.Meter{})
+	_, _, err := source.Lookup(t.Context(), "user001")
+	assert.ErrorIs(t, err, errNotStarted)
+}
+
+func TestStartError(t *testing.T) {
+	t.Run("missing file", func(t *testing.T) {
+		source := newSource(newTestConfig(filepath.Join(t.TempDir(), "missing.db")), zap.NewNop(), noop.Meter{})
+		assert.ErrorContains(t, source.Start(t.Context(), componenttest.NewNopHost()), "failed to open")
+	})
+
+	t.Run("invalid query", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "users.db")
+		writeDB(t, path, map[string]any{"user001": "Alice"})
+		cfg := newTestConfig(path)
+		cfg.Query = "SELECT email FROM users WHERE id = ?"
+		source := newSource(cfg, zap.NewNop(), noop.Meter{})
+		assert.ErrorContains(t, source.Start(t.Context(), componenttest.NewNopHost()), "failed to prepare query")
+	})
+}
+
+func TestReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.db")
+	writeDB(t, path, map[string]any{"user001": "Alice"})
+	cfg := newTestConfig(path)
+	cfg.Query = "DELETE FROM users WHERE id = ? RETURNING name"
+	cfg.Cache.Enabled = false
+	source := newSource(cfg, zap.NewNop(), noop.Meter{})
+	require.NoError(t, source.Start(t.Context(), componenttest.NewNopHost()))
+	defer func() { require.NoError(t, source.Shutdown(t.Context())) }()
+	_, _, err := source.Lookup(t.Context(), "user001")
+	assert.ErrorContains(t, err, "readonly")
+
+	db, err := sql.Open(driverName, path)
+	require.NoError(t, err)
+	defer db.Close()
+	var name string
+	require.NoError(t, db.QueryRow(testQuery, "user001").Scan(&name))
+	assert.Equal(t, "Alice", name)
+}
+
+func TestReopen(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "users.db")
+	writeDB(t, path, map[string]any{"user001": "Alice"})
+	cfg := newTestConfig(path)
+	cfg.RefreshInterval = 10 * time.Millisecond
+	source := newSource(cfg, zap.New(core), noop.Meter{})
+	require.NoError(t, source.Start(t.Context(), componenttest.NewNopHost()))
+	defer func() { require.NoError(t, source.Shutdown(t.Context())) }()
+
+	lookup := func(key string) any {
+		val, _, err := source.Lookup(t.Context(), key)
+		require.NoError(t, err)
+		return val
+	}
+	// Cache the value of the first file.
+	assert.Equal(t, "Alice", lookup("user001"))
+
+	// A file swapped in by a rename replaces the previous one, and the
+	// cached values of the previous file are dropped.
+	next := filepath.Join(dir, "users.db.new")
+	writeDB(t, next, map[string]any{"user001": "Alice Smith", "user002": "Bob"})
+	require.NoError(t, os.Rename(next, path))
+	require.Eventually(t, func() bool { return lookup("user002") == "Bob" }, 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, "Alice Smith", lookup("user001"))
+
+	// A file that can't be opened keeps the previous one in use.
+	require.NoError(t, os.WriteFile(next, []byte("not a database"), 0o600))
+	require.NoError(t, os.Rename(next, path))
+	require.Eventually(t, func() bool { return logs.Len() > 0 }, 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, "Bob", lookup("user002"))
+	assert.Equal(t, "Failed to reopen database, keeping the previous one", logs.All()[0].Message)
+}
@@ -0,0 +1,68 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package static provides a lookup source backed by an inline map of entries
+// embedded in the processor configuration.
+package static // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/static"
+
+import (
+	"context"
+	"errors"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+const sourceType = "static"
+
+var errNoEntries = errors.New("entries must not be empty")
+
+type Config struct {
+	// Entries maps lookup keys to their values.
+	Entries map[string]any `mapstructure:"entries"`
+}
+
+func (c *Config) Validate() error {
+	if len(c.Entries) == 0 {
+		return errNoEntries
+	}
+	return nil
+}
+
+func NewFactory() lookupsource.SourceFactory {
+	return lookupsource.NewSourceFactory(
+		sourceType,
+		createDefaultConfig,
+		createSource,
+	)
+}
+
+func createDefaultConfig() lookupsource.SourceConfig {
+	return &Config{}
+}
+
+func createSource(
+	_ context.Context,
+	_ lookupsource.CreateSettings,
+	cfg lookupsource.SourceConfig,
+) (lookupsource.Source, error) {
+	staticCfg := cfg.(*Config)
+
+	// Copy the entries so later changes to the config don't affect lookups.
+	entries := make(map[string]any, len(staticCfg.Entries))
+	for k, v := range staticCfg.Entries {
+		entries[k] = v
+	}
+
+	// Map reads are already cheap, so no cache is needed.
+	lookup := func(_ context.Context, key string) (any, bool, error) {
+		val, found := entries[key]
+		return val, found, nil
+	}
+
+	return lookupsource.NewSource(
+		lookup,
+		func() string { return sourceType },
+		nil, // no start needed
+		nil, // no shutdown needed
+	), nil
+}
@@ -0,0 +1,117 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package static
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr error
+	}{
+		{
+			name: "valid",
+			cfg:  Config{Entries: map[string]any{"a": "b"}},
+		},
+		{
+			name:    "nil entries",
+			cfg:     Config{},
+			wantErr: errNoEntries,
+		},
+		{
+			name:    "empty entries",
+			cfg:     Config{Entries: map[string]any{}},
+			wantErr: errNoEntries,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	cfg := &Config{
+		Entries: map[string]any{
+			"10.0.0.1": "web-1",
+			"10.0.0.2": 42,
+		},
+	}
+
+	source, err := NewFactory().CreateSource(t.Context(), lookupsource.CreateSettings{}, cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "static", source.Type())
+
+	tests := []struct {
+		name      string
+		key       string
+		wantVal   any
+		wantFound bool
+	}{
+		{name: "hit string", key: "10.0.0.1", wantVal: "web-1", wantFound: true},
+		{name: "hit int", key: "10.0.0.2", wantVal: 42, wantFound: true},
+		{name: "miss", key: "10.0.0.3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			val, found, err := source.Lookup(t.Context(), tt.key)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantFound, found)
+			assert.Equal(t, tt.wantVal, val)
+		})
+	}
+}
+
+func TestEntriesCopiedAtCreate(t *testing.T) {
+	cfg := &Config{Entries: map[string]any{"key": "before"}}
+
+	source, err := NewFactory().CreateSource(t.Context(), lookupsource.CreateSettings{}, cfg)
+	require.NoError(t, err)
+
+	cfg.Entries["key"] = "after"
+
+	val, found, err := source.Lookup(t.Context(), "key")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "before", val)
+}
+
+func TestDebugLookup(t *testing.T) {
+	cfg := &Config{Entries: map[string]any{"10.0.0.1": "web-1"}}
+
+	result, err := lookupsource.DebugLookup(t.Context(), NewFactory(), cfg, "10.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, "static", result.SourceType)
+	assert.Equal(t, "10.0.0.1", result.Key)
+	assert.Equal(t, "web-1", result.Value)
+	assert.True(t, result.Found)
+	// The source has no cache.
+	assert.Empty(t, result.Cache)
+	assert.Empty(t, result.ErrorClass)
+
+	result, err = lookupsource.DebugLookup(t.Context(), NewFactory(), cfg, "10.0.0.2")
+	require.NoError(t, err)
+	assert.False(t, result.Found)
+	assert.Nil(t, result.Value)
+
+	// The config is validated.
+	_, err = lookupsource.DebugLookup(t.Context(), NewFactory(), nil, "10.0.0.1")
+	assert.ErrorIs(t, err, errNoEntries)
+}
@@ -0,0 +1,108 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package sourceconfig decodes and creates lookup sources. It is shared by
+// the lookup processor and the lookup extension, which both own sources.
+package sourceconfig // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/sourceconfig"
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/confmap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/bloom"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/cloud"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/dns"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/env"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/file"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/graphql"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/grpc"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/k8s"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/memcached"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/noop"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/promql"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/rdap"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/s3"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/sql"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/sqlite"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/static"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+// DefaultType is the source type used when none is configured.
+const DefaultType = "noop"
+
+// DefaultRegistry returns a registry holding the built-in source factories.
+func DefaultRegistry() *lookupsource.Registry {
+	registry := lookupsource.NewRegistry()
+	for _, factory := range []lookupsource.SourceFactory{
+		bloom.NewFactory(),
+		cloud.NewFactory(),
+		dns.NewFactory(),
+		env.NewFactory(),
+		file.NewFactory(),
+		graphql.NewFactory(),
+		grpc.NewFactory(),
+		k8s.NewFactory(),
+		memcached.NewFactory(),
+		noop.NewFactory(),
+		promql.NewFactory(),
+		rdap.NewFactory(),
+		s3.NewFactory(),
+		sql.NewFactory(),
+		sqlite.NewFactory(),
+		static.NewFactory(),
+	} {
+		if err := registry.Register(factory); err != nil {
+			panic(err)
+		}
+	}
+	return registry
+}
+
+// Decode decodes the settings of conf, except "type", into the default
+// config of the factory registered for typ.
+func Decode(registry *lookupsource.Registry, typ string, conf *confmap.Conf) (lookupsource.SourceConfig, error) {
+	factory, ok := registry.Get(typ)
+	if !ok {
+		return nil, fmt.Errorf("unknown source type %q, available types: %v", typ, registry.Types())
+	}
+
+	settings := conf.ToStringMap()
+	delete(settings, "type")
+
+	cfg := factory.CreateDefaultConfig()
+	if err := confmap.NewFromStringMap(settings).Unmarshal(cfg); err != nil {
+		return nil, fmt.Errorf("error reading settings for source type %q: %w", typ, err)
+	}
+	return cfg, nil
+}
+
+// Create creates a source of type typ. A nil cfg is replaced by the default
+// config of the factory.
+func Create(
+	ctx context.Context,
+	registry *lookupsource.Registry,
+	typ string,
+	cfg lookupsource.SourceConfig,
+	set component.TelemetrySettings,
+	buildInfo component.BuildInfo,
+) (lookupsource.Source, error) {
+	factory, ok := registry.Get(typ)
+	if !ok {
+		return nil, fmt.Errorf("unknown source type %q, available types: %v", typ, registry.Types())
+	}
+
+	if cfg == nil {
+		cfg = factory.CreateDefaultConfig()
+	}
+
+	createSettings := lookupsource.CreateSettings{
+		TelemetrySettings: set,
+		BuildInfo:         buildInfo,
+	}
+
+	return factory.CreateSource(ctx, createSettings, cfg)
+}
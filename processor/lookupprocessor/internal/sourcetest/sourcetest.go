@@ -0,0 +1,73 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package sourcetest provides helpers shared by the tests of the built-in
+// lookup sources.
+package sourcetest // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/sourcetest"
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+// NewNopCreateSettings returns settings for creating a source in tests.
+func NewNopCreateSettings() lookupsource.CreateSettings {
+	return lookupsource.CreateSettings{TelemetrySettings: componenttest.NewNopTelemetrySettings()}
+}
+
+// Create creates a source with factory and cfg, without starting it.
+func Create(t *testing.T, factory lookupsource.SourceFactory, cfg lookupsource.SourceConfig) lookupsource.Source {
+	t.Helper()
+	source, err := factory.CreateSource(t.Context(), NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+	return source
+}
+
+// Start starts source and shuts it down once the test is done.
+func Start(t *testing.T, source lookupsource.Source) lookupsource.Source {
+	t.Helper()
+	require.NoError(t, source.Start(t.Context(), componenttest.NewNopHost()))
+	t.Cleanup(func() { require.NoError(t, source.Shutdown(t.Context())) })
+	return source
+}
+
+// CreateAndStart creates a source with factory and cfg, and starts it like
+// [Start].
+func CreateAndStart(t *testing.T, factory lookupsource.SourceFactory, cfg lookupsource.SourceConfig) lookupsource.Source {
+	t.Helper()
+	return Start(t, Create(t, factory, cfg))
+}
+
+// ValidateTest is a case of [RunValidateTests]: Modify changes a valid
+// config, whose validation then fails with an error containing WantErr, or
+// succeeds if WantErr is empty.
+type ValidateTest[C any] struct {
+	Name    string
+	Modify  func(C)
+	WantErr string
+}
+
+// RunValidateTests runs each of tests as a subtest, against a config
+// returned by newConfig.
+func RunValidateTests[C interface{ Validate() error }](t *testing.T, newConfig func() C, tests []ValidateTest[C]) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			cfg := newConfig()
+			if tt.Modify != nil {
+				tt.Modify(cfg)
+			}
+			err := cfg.Validate()
+			if tt.WantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.WantErr)
+		})
+	}
+}
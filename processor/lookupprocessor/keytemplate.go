@@ -0,0 +1,112 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupprocessor // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor"
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// Prefixes of the attribute references of a source_key template.
+const (
+	templateResourcePrefix   = "resource."
+	templateAttributesPrefix = "attributes."
+)
+
+// keyTemplate is a parsed source_key: literal text and references to
+// attributes, rendered in order.
+type keyTemplate struct {
+	parts []templatePart
+}
+
+// templatePart is either literal text, or a reference to the attribute name
+// of the resource or of the record.
+type templatePart struct {
+	literal  string
+	name     string
+	resource bool
+}
+
+// parseKeyTemplate parses a template such as
+// "{resource.service.name}/{attributes.region}". References name an
+// attribute after the "resource." or "attributes." prefix, and "{{" and "}}"
+// stand for literal braces.
+func parseKeyTemplate(s string) (*keyTemplate, error) {
+	var t keyTemplate
+	var literal strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '{' && strings.HasPrefix(s[i:], "{{"), c == '}' && strings.HasPrefix(s[i:], "}}"):
+			literal.WriteByte(c)
+			i++
+		case c == '}':
+			return nil, fmt.Errorf("unexpected '}' at offset %d, use '}}' for a literal brace", i)
+		case c == '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated reference at offset %d", i)
+			}
+			part, err := parseReference(s[i+1 : i+end])
+			if err != nil {
+				return nil, err
+			}
+			if literal.Len() > 0 {
+				t.parts = append(t.parts, templatePart{literal: literal.String()})
+				literal.Reset()
+			}
+			t.parts = append(t.parts, part)
+			i += end
+		default:
+			literal.WriteByte(c)
+		}
+	}
+	if literal.Len() > 0 {
+		t.parts = append(t.parts, templatePart{literal: literal.String()})
+	}
+	for _, part := range t.parts {
+		if part.name != "" {
+			return &t, nil
+		}
+	}
+	return nil, errors.New("must reference at least one attribute")
+}
+
+func parseReference(ref string) (templatePart, error) {
+	if name, ok := strings.CutPrefix(ref, templateResourcePrefix); ok && name != "" {
+		return templatePart{name: name, resource: true}, nil
+	}
+	if name, ok := strings.CutPrefix(ref, templateAttributesPrefix); ok && name != "" {
+		return templatePart{name: name}, nil
+	}
+	return templatePart{}, fmt.Errorf("invalid reference {%s}, must be {%s<name>} or {%s<name>}", ref, templateResourcePrefix, templateAttributesPrefix)
+}
+
+// render returns the key of a record with attributes attrs and resource
+// attributes resource. Missing attributes are replaced by "" if
+// substituteMissing is set; otherwise render returns "" so that the record
+// isn't looked up.
+func (t *keyTemplate) render(resource, attrs pcommon.Map, substituteMissing bool) string {
+	var key strings.Builder
+	for _, part := range t.parts {
+		if part.name == "" {
+			key.WriteString(part.literal)
+			continue
+		}
+		source := attrs
+		if part.resource {
+			source = resource
+		}
+		val, ok := source.Get(part.name)
+		if !ok && !substituteMissing {
+			return ""
+		}
+		if ok {
+			key.WriteString(val.AsString())
+		}
+	}
+	return key.String()
+}
@@ -0,0 +1,63 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupprocessor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+func TestKeyTemplate(t *testing.T) {
+	resource := pcommon.NewMap()
+	resource.PutStr("service.name", "checkout")
+	attrs := pcommon.NewMap()
+	attrs.PutStr("region", "eu-west-1")
+	attrs.PutInt("shard", 3)
+
+	tests := []struct {
+		template     string
+		want         string
+		wantWithMiss string
+	}{
+		{template: "{resource.service.name}/{attributes.region}", want: "checkout/eu-west-1", wantWithMiss: "checkout/eu-west-1"},
+		{template: "{attributes.region}-{attributes.shard}", want: "eu-west-1-3", wantWithMiss: "eu-west-1-3"},
+		{template: "{{literal}}:{attributes.region}", want: "{literal}:eu-west-1", wantWithMiss: "{literal}:eu-west-1"},
+		{template: "{{{attributes.region}}}", want: "{eu-west-1}", wantWithMiss: "{eu-west-1}"},
+		{template: "{attributes.region}/{attributes.zone}", want: "", wantWithMiss: "eu-west-1/"},
+		{template: "{resource.region}", want: "", wantWithMiss: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			tmpl, err := parseKeyTemplate(tt.template)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, tmpl.render(resource, attrs, false))
+			assert.Equal(t, tt.wantWithMiss, tmpl.render(resource, attrs, true))
+		})
+	}
+}
+
+func TestParseKeyTemplateErrors(t *testing.T) {
+	tests := []struct {
+		template string
+		wantErr  string
+	}{
+		{template: "static", wantErr: "must reference at least one attribute"},
+		{template: "{{attributes.region}}", wantErr: "must reference at least one attribute"},
+		{template: "{attributes.region", wantErr: "unterminated reference at offset 0"},
+		{template: "{attributes.region}}", wantErr: "unexpected '}' at offset 19"},
+		{template: "{region}", wantErr: "invalid reference {region}"},
+		{template: "{resource.}", wantErr: "invalid reference {resource.}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			_, err := parseKeyTemplate(tt.template)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
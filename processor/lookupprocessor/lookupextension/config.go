@@ -0,0 +1,119 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupextension // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupextension"
+
+import (
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/confighttp"
+	"go.opentelemetry.io/collector/confmap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/sourceconfig"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+type Config struct {
+	// Sources are the sources owned by the extension, keyed by the name
+	// processors refer to them by.
+	Sources map[string]SourceConfig `mapstructure:"sources"`
+
+	// RecentLookups serves the last lookups of each source over HTTP.
+	RecentLookups RecentLookupsConfig `mapstructure:"recent_lookups"`
+
+	// sources resolves SourceConfig.Type to a factory while unmarshaling. It
+	// is set by the extension factory so that custom sources added with
+	// WithSources can be configured.
+	sources *lookupsource.Registry
+}
+
+// SourceConfig configures a source the same way as in the lookup processor.
+type SourceConfig struct {
+	// Type is the source type identifier (e.g., "noop", "static", "sql").
+	Type string `mapstructure:"type"`
+
+	// Config holds the source-specific configuration.
+	// This is populated during config unmarshaling based on the Type.
+	Config lookupsource.SourceConfig `mapstructure:"-"`
+}
+
+// RecentLookupsConfig configures the HTTP endpoint listing the last lookups
+// of each source, with their key, outcome and latency, to debug what a
+// running source is asked and answers.
+type RecentLookupsConfig struct {
+	// Enabled serves the lookups at /debug/lookups.
+	Enabled bool `mapstructure:"enabled"`
+
+	// Size is the number of lookups kept for each source.
+	Size int `mapstructure:"size"`
+
+	confighttp.ServerConfig `mapstructure:",squash"`
+}
+
+var (
+	_ component.Config    = (*Config)(nil)
+	_ confmap.Unmarshaler = (*Config)(nil)
+)
+
+func (cfg *Config) Validate() error {
+	if len(cfg.Sources) == 0 {
+		return errors.New("at least one source must be specified")
+	}
+	if cfg.RecentLookups.Enabled && cfg.RecentLookups.Size <= 0 {
+		return fmt.Errorf("recent_lookups::size must be positive, got %d", cfg.RecentLookups.Size)
+	}
+	return nil
+}
+
+// Unmarshal decodes the extension configuration. The Type of each source
+// picks the source factory whose default config receives the remaining
+// settings of that source.
+func (cfg *Config) Unmarshal(componentParser *confmap.Conf) error {
+	if componentParser == nil {
+		return nil
+	}
+
+	// Sources are decoded below, into the config of their type, so that
+	// unknown settings are rejected everywhere else.
+	settings := componentParser.ToStringMap()
+	delete(settings, "sources")
+	if err := confmap.NewFromStringMap(settings).Unmarshal(cfg); err != nil {
+		return err
+	}
+
+	registry := cfg.sources
+	if registry == nil {
+		registry = sourceconfig.DefaultRegistry()
+	}
+
+	sourcesSection, err := componentParser.Sub("sources")
+	if err != nil {
+		return err
+	}
+	if cfg.Sources == nil {
+		cfg.Sources = make(map[string]SourceConfig)
+	}
+	for name := range sourcesSection.ToStringMap() {
+		sourceSection, err := sourcesSection.Sub(name)
+		if err != nil {
+			return err
+		}
+		// The settings of the source type are decoded strictly by Decode.
+		var sourceCfg SourceConfig
+		if err := sourceSection.Unmarshal(&sourceCfg, confmap.WithIgnoreUnused()); err != nil {
+			return fmt.Errorf("source %q: %w", name, err)
+		}
+		if sourceCfg.Type == "" {
+			sourceCfg.Type = sourceconfig.DefaultType
+		}
+		sourceCfg.Config, err = sourceconfig.Decode(registry, sourceCfg.Type, sourceSection)
+		if err != nil {
+			return fmt.Errorf("source %q: %w", name, err)
+		}
+		cfg.Sources[name] = sourceCfg
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupextension
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/confmap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/noop"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/static"
+)
+
+func TestConfigUnmarshal(t *testing.T) {
+	cfg := NewFactory().CreateDefaultConfig().(*Config)
+	require.NoError(t, confmap.NewFromStringMap(map[string]any{
+		"sources": map[string]any{
+			"default": map[string]any{},
+			"hosts": map[string]any{
+				"type":    "static",
+				"entries": map[string]any{"10.0.0.1": "web-1"},
+			},
+		},
+	}).Unmarshal(cfg))
+	require.NoError(t, cfg.Validate())
+
+	assert.Equal(t, "noop", cfg.Sources["default"].Type)
+	assert.IsType(t, &noop.Config{}, cfg.Sources["default"].Config)
+
+	staticCfg, ok := cfg.Sources["hosts"].Config.(*static.Config)
+	require.True(t, ok)
+	assert.Equal(t, map[string]any{"10.0.0.1": "web-1"}, staticCfg.Entries)
+}
+
+func TestConfigUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  map[string]any
+		wantErr string
+	}{
+		{
+			name:    "unknown source type",
+			source:  map[string]any{"type": "missing"},
+			wantErr: `source "src": unknown source type "missing"`,
+		},
+		{
+			name:    "unknown source setting",
+			source:  map[string]any{"type": "static", "entryes": map[string]any{"a": "b"}},
+			wantErr: `source "src": error reading settings for source type "static"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewFactory().CreateDefaultConfig().(*Config)
+			err := confmap.NewFromStringMap(map[string]any{
+				"sources": map[string]any{"src": tt.source},
+			}).Unmarshal(cfg)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestConfigUnmarshalUnknownSetting(t *testing.T) {
+	cfg := NewFactory().CreateDefaultConfig().(*Config)
+	err := confmap.NewFromStringMap(map[string]any{
+		"sources":       map[string]any{"src": map[string]any{"type": "static"}},
+		"recent_lookup": map[string]any{"enabled": true},
+	}).Unmarshal(cfg)
+	assert.ErrorContains(t, err, "recent_lookup")
+}
+
+func TestConfigValidate(t *testing.T) {
+	cfg := NewFactory().CreateDefaultConfig().(*Config)
+	assert.EqualError(t, cfg.Validate(), "at least one source must be specified")
+}
+
+func TestConfigValidateRecentLookups(t *testing.T) {
+	cfg := NewFactory().CreateDefaultConfig().(*Config)
+	cfg.Sources = map[string]SourceConfig{"default": {Type: "noop"}}
+	require.NoError(t, cfg.Validate())
+
+	cfg.RecentLookups.Enabled = true
+	cfg.RecentLookups.Size = 0
+	assert.EqualError(t, cfg.Validate(), "recent_lookups::size must be positive, got 0")
+}
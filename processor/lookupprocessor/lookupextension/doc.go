@@ -0,0 +1,9 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:generate mdatagen metadata.yaml
+
+// Package lookupextension contains an extension owning lookup sources, so
+// that several lookup processors can share them, and their caches, by
+// referring to the extension instead of each creating their own.
+package lookupextension // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupextension"
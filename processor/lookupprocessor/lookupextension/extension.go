@@ -0,0 +1,113 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupextension // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupextension"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+// lookupExtension owns the sources of Config.Sources. The collector starts
+// extensions before processors and shuts them down after, so the sources are
+// running whenever a processor uses them.
+type lookupExtension struct {
+	sources map[string]lookupsource.Source
+
+	config    *Config
+	telemetry component.TelemetrySettings
+	// recent holds the last lookups of each source when
+	// Config.RecentLookups is enabled, served by server.
+	recent map[string]*lookupsource.RecentLookups
+	server *http.Server
+
+	meter        metric.Meter
+	metrics      *sourceMetrics
+	registration metric.Registration
+}
+
+var _ lookupsource.LookupExtension = (*lookupExtension)(nil)
+
+func (e *lookupExtension) Source(name string) (lookupsource.Source, bool) {
+	source, ok := e.sources[name]
+	return source, ok
+}
+
+func (e *lookupExtension) Start(ctx context.Context, host component.Host) error {
+	for _, name := range sortedNames(e.sources) {
+		if err := e.sources[name].Start(ctx, host); err != nil {
+			return fmt.Errorf("failed to start source %q: %w", name, err)
+		}
+	}
+
+	registration, err := e.metrics.register(e.meter, e.sources)
+	if err != nil {
+		return fmt.Errorf("failed to register source metrics: %w", err)
+	}
+	e.registration = registration
+
+	if e.recent != nil {
+		if err := e.startServer(ctx, host); err != nil {
+			return fmt.Errorf("failed to start recent lookups server: %w", err)
+		}
+	}
+	return nil
+}
+
+// startServer serves the recent lookups of the sources at /debug/lookups.
+func (e *lookupExtension) startServer(ctx context.Context, host component.Host) error {
+	listener, err := e.config.RecentLookups.ToListener(ctx)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/debug/lookups", recentLookupsHandler(e.recent))
+	server, err := e.config.RecentLookups.ToServer(ctx, host.GetExtensions(), e.telemetry, mux)
+	if err != nil {
+		return errors.Join(err, listener.Close())
+	}
+	e.server = server
+
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			e.telemetry.Logger.Error("Recent lookups server failed", zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+func (e *lookupExtension) Shutdown(ctx context.Context) error {
+	var errs error
+	if e.server != nil {
+		errs = e.server.Close()
+		e.server = nil
+	}
+	if e.registration != nil {
+		errs = errors.Join(errs, e.registration.Unregister())
+		e.registration = nil
+	}
+	for _, name := range sortedNames(e.sources) {
+		if err := e.sources[name].Shutdown(ctx); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("failed to shut down source %q: %w", name, err))
+		}
+	}
+	return errs
+}
+
+func sortedNames(m map[string]lookupsource.Source) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,133 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupextension
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/extension/extensiontest"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/static"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupextension/internal/metadata"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+func TestSource(t *testing.T) {
+	cfg := NewFactory().CreateDefaultConfig().(*Config)
+	cfg.Sources = map[string]SourceConfig{
+		"hosts": {Type: "static", Config: &static.Config{Entries: map[string]any{"10.0.0.1": "web-1"}}},
+	}
+
+	ext, err := NewFactory().Create(t.Context(), extensiontest.NewNopSettings(metadata.Type), cfg)
+	require.NoError(t, err)
+	require.NoError(t, ext.Start(t.Context(), componenttest.NewNopHost()))
+	defer func() { require.NoError(t, ext.Shutdown(context.Background())) }()
+
+	lookupExt, ok := ext.(lookupsource.LookupExtension)
+	require.True(t, ok)
+
+	source, ok := lookupExt.Source("hosts")
+	require.True(t, ok)
+	val, found, err := source.Lookup(t.Context(), "10.0.0.1")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "web-1", val)
+
+	_, ok = lookupExt.Source("users")
+	assert.False(t, ok)
+}
+
+func TestLifecycle(t *testing.T) {
+	var started, stopped []string
+	factory := func(typ string) lookupsource.SourceFactory {
+		return lookupsource.NewSourceFactory(
+			typ,
+			func() lookupsource.SourceConfig { return &static.Config{} },
+			func(context.Context, lookupsource.CreateSettings, lookupsource.SourceConfig) (lookupsource.Source, error) {
+				return lookupsource.NewSource(
+					func(context.Context, string) (any, bool, error) { return nil, false, nil },
+					func() string { return typ },
+					func(context.Context, component.Host) error {
+						started = append(started, typ)
+						return nil
+					},
+					func(context.Context) error {
+						stopped = append(stopped, typ)
+						return errors.New("boom")
+					},
+				), nil
+			},
+		)
+	}
+
+	f := NewFactoryWithOptions(WithSources(factory("a"), factory("b")))
+	cfg := f.CreateDefaultConfig().(*Config)
+	cfg.Sources = map[string]SourceConfig{"first": {Type: "a"}, "second": {Type: "b"}}
+
+	ext, err := f.Create(t.Context(), extensiontest.NewNopSettings(metadata.Type), cfg)
+	require.NoError(t, err)
+	require.NoError(t, ext.Start(t.Context(), componenttest.NewNopHost()))
+	assert.Equal(t, []string{"a", "b"}, started)
+
+	err = ext.Shutdown(t.Context())
+	assert.ErrorContains(t, err, `failed to shut down source "first": boom`)
+	assert.ErrorContains(t, err, `failed to shut down source "second": boom`)
+	assert.Equal(t, []string{"a", "b"}, stopped)
+}
+
+func TestWithSourcesDuplicateType(t *testing.T) {
+	f := NewFactoryWithOptions(WithSources(static.NewFactory(), static.NewFactory()))
+	_, err := f.Create(t.Context(), extensiontest.NewNopSettings(metadata.Type), f.CreateDefaultConfig())
+	assert.ErrorContains(t, err, `source type "static" is already registered`)
+}
+
+func TestRecentLookups(t *testing.T) {
+	cfg := NewFactory().CreateDefaultConfig().(*Config)
+	cfg.Sources = map[string]SourceConfig{
+		"hosts": {Type: "static", Config: &static.Config{Entries: map[string]any{"10.0.0.1": "web-1"}}},
+	}
+	cfg.RecentLookups.Enabled = true
+	cfg.RecentLookups.Size = 2
+	cfg.RecentLookups.Endpoint = "localhost:0"
+
+	ext, err := NewFactory().Create(t.Context(), extensiontest.NewNopSettings(metadata.Type), cfg)
+	require.NoError(t, err)
+	require.NoError(t, ext.Start(t.Context(), componenttest.NewNopHost()))
+	defer func() { require.NoError(t, ext.Shutdown(context.Background())) }()
+
+	source, ok := ext.(lookupsource.LookupExtension).Source("hosts")
+	require.True(t, ok)
+	for _, key := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"} {
+		_, _, err = source.Lookup(t.Context(), key)
+		require.NoError(t, err)
+	}
+
+	handler := recentLookupsHandler(ext.(*lookupExtension).recent)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/lookups?format=json", http.NoBody))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var sources []sourceLookups
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &sources))
+	require.Len(t, sources, 1)
+	assert.Equal(t, "hosts", sources[0].Name)
+	// The newest lookups are listed first, and the oldest was evicted.
+	require.Len(t, sources[0].Lookups, 2)
+	assert.Equal(t, "10.0.0.3", sources[0].Lookups[0].Key)
+	assert.Equal(t, "10.0.0.2", sources[0].Lookups[1].Key)
+	assert.False(t, sources[0].Lookups[0].Found)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/lookups", http.NoBody))
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, rec.Body.String(), "<td>10.0.0.3</td>")
+}
@@ -0,0 +1,120 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupextension // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupextension"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/confighttp"
+	"go.opentelemetry.io/collector/extension"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/sourceconfig"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupextension/internal/metadata"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+type FactoryOption func(*lookupExtensionFactory)
+
+// WithSources adds custom source factories to the extension, like the lookup
+// processor option of the same name: the first call replaces the default
+// sources, later calls add to them.
+func WithSources(factories ...lookupsource.SourceFactory) FactoryOption {
+	return func(f *lookupExtensionFactory) {
+		if !f.defaultSourcesOverridden {
+			f.sources = lookupsource.NewRegistry()
+			f.defaultSourcesOverridden = true
+		}
+		for _, factory := range factories {
+			f.registrationErr = errors.Join(f.registrationErr, f.sources.Register(factory))
+		}
+	}
+}
+
+type lookupExtensionFactory struct {
+	sources                  *lookupsource.Registry
+	defaultSourcesOverridden bool
+	registrationErr          error
+}
+
+func NewFactory() extension.Factory {
+	return NewFactoryWithOptions()
+}
+
+// NewFactoryWithOptions creates a lookup extension factory with custom
+// sources. Register the same sources as with the lookup processor to share
+// them through the extension.
+func NewFactoryWithOptions(options ...FactoryOption) extension.Factory {
+	f := &lookupExtensionFactory{
+		sources: sourceconfig.DefaultRegistry(),
+	}
+	for _, opt := range options {
+		opt(f)
+	}
+
+	return extension.NewFactory(
+		metadata.Type,
+		f.createDefaultConfig,
+		f.createExtension,
+		metadata.ExtensionStability,
+	)
+}
+
+func (f *lookupExtensionFactory) createDefaultConfig() component.Config {
+	return &Config{
+		RecentLookups: RecentLookupsConfig{
+			Size: 100,
+			ServerConfig: confighttp.ServerConfig{
+				Endpoint: "localhost:55690",
+			},
+		},
+		sources: f.sources,
+	}
+}
+
+func (f *lookupExtensionFactory) createExtension(
+	ctx context.Context,
+	set extension.Settings,
+	cfg component.Config,
+) (extension.Extension, error) {
+	if f.registrationErr != nil {
+		return nil, f.registrationErr
+	}
+
+	extCfg := cfg.(*Config)
+
+	meter := set.MeterProvider.Meter(metadata.ScopeName)
+	metrics, err := newSourceMetrics(meter)
+	if err != nil {
+		return nil, err
+	}
+
+	sources := make(map[string]lookupsource.Source, len(extCfg.Sources))
+	for name, sourceCfg := range extCfg.Sources {
+		source, err := sourceconfig.Create(ctx, f.sources, sourceCfg.Type, sourceCfg.Config, set.TelemetrySettings, set.BuildInfo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create source %q: %w", name, err)
+		}
+		sources[name] = source
+	}
+
+	ext := &lookupExtension{
+		sources:   sources,
+		meter:     meter,
+		metrics:   metrics,
+		config:    extCfg,
+		telemetry: set.TelemetrySettings,
+	}
+	if extCfg.RecentLookups.Enabled {
+		ext.recent = make(map[string]*lookupsource.RecentLookups, len(sources))
+		for name, source := range sources {
+			recent := lookupsource.NewRecentLookups(extCfg.RecentLookups.Size)
+			ext.recent[name] = recent
+			sources[name] = lookupsource.WithRecentLookups(source, recent)
+		}
+	}
+	return ext, nil
+}
@@ -0,0 +1,63 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupextension // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupextension"
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"slices"
+	"sort"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+var recentLookupsPage = template.Must(template.New("lookups").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Recent lookups</title></head>
+<body>
+<h1>Recent lookups</h1>
+{{range .}}
+<h2>{{.Name}}</h2>
+<table border="1" cellpadding="4">
+<tr><th>Time</th><th>Key</th><th>Found</th><th>Latency</th><th>Error</th></tr>
+{{range .Lookups}}<tr><td>{{.Time.Format "2006-01-02T15:04:05.000Z07:00"}}</td><td>{{.Key}}</td><td>{{.Found}}</td><td>{{.Latency}}</td><td>{{.Error}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
+
+// sourceLookups are the recent lookups of a source, newest first.
+type sourceLookups struct {
+	Name    string                      `json:"name"`
+	Lookups []lookupsource.LookupRecord `json:"lookups"`
+}
+
+// recentLookupsHandler serves the recent lookups of each source, sorted by
+// source name, as an HTML page, or as JSON with the format=json query
+// parameter.
+func recentLookupsHandler(recent map[string]*lookupsource.RecentLookups) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		names := make([]string, 0, len(recent))
+		for name := range recent {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		sources := make([]sourceLookups, len(names))
+		for i, name := range names {
+			records := recent[name].Records()
+			slices.Reverse(records)
+			sources[i] = sourceLookups{Name: name, Lookups: records}
+		}
+
+		if r.URL.Query().Get("format") == "json" {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(sources)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = recentLookupsPage.Execute(w, sources)
+	})
+}
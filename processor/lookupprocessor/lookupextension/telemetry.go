@@ -0,0 +1,73 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupextension // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupextension"
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+// sourceMetrics are gauges observed for every source of the extension,
+// with the source_type and source_name attributes.
+type sourceMetrics struct {
+	cacheSize     metric.Int64ObservableGauge
+	cacheHitRatio metric.Float64ObservableGauge
+	health        metric.Int64ObservableGauge
+}
+
+func newSourceMetrics(meter metric.Meter) (*sourceMetrics, error) {
+	var m sourceMetrics
+	var err, errs error
+	m.cacheSize, err = meter.Int64ObservableGauge(
+		"otelcol_lookup_source_cache_size",
+		metric.WithDescription("Number of entries in the cache of the source. Only reported for sources with an enabled cache."),
+		metric.WithUnit("{entries}"),
+	)
+	errs = errors.Join(errs, err)
+	m.cacheHitRatio, err = meter.Float64ObservableGauge(
+		"otelcol_lookup_source_cache_hit_ratio",
+		metric.WithDescription("Fraction of the cache lookups of the source that found an entry. Only reported for sources with an enabled cache."),
+		metric.WithUnit("1"),
+	)
+	errs = errors.Join(errs, err)
+	m.health, err = meter.Int64ObservableGauge(
+		"otelcol_lookup_source_health",
+		metric.WithDescription("Whether the health check of the source passes (1) or fails (0)."),
+		metric.WithUnit("1"),
+	)
+	errs = errors.Join(errs, err)
+	return &m, errs
+}
+
+// register registers the callback observing the gauges for sources.
+func (m *sourceMetrics) register(meter metric.Meter, sources map[string]lookupsource.Source) (metric.Registration, error) {
+	return meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		for _, name := range sortedNames(sources) {
+			source := sources[name]
+			attrs := metric.WithAttributes(
+				attribute.String("source_type", source.Type()),
+				attribute.String("source_name", name),
+			)
+
+			if reporter, ok := source.(lookupsource.CacheStatsReporter); ok {
+				if stats, ok := reporter.CacheStats(); ok {
+					o.ObserveInt64(m.cacheSize, int64(stats.Size), attrs)
+					o.ObserveFloat64(m.cacheHitRatio, stats.HitRatio(), attrs)
+				}
+			}
+
+			var healthy int64
+			if lookupsource.CheckHealth(ctx, source) == nil {
+				healthy = 1
+			}
+			o.ObserveInt64(m.health, healthy, attrs)
+		}
+		return nil
+	}, m.cacheSize, m.cacheHitRatio, m.health)
+}
@@ -0,0 +1,111 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupextension
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/extension/extensiontest"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/source/static"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupextension/internal/metadata"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+// cachedFactory creates sources resolving every key through an enabled
+// cache, whose health check fails.
+func cachedFactory() lookupsource.SourceFactory {
+	return lookupsource.NewSourceFactory(
+		"cached",
+		func() lookupsource.SourceConfig { return &static.Config{} },
+		func(context.Context, lookupsource.CreateSettings, lookupsource.SourceConfig) (lookupsource.Source, error) {
+			cache := lookupsource.NewCache(lookupsource.CacheConfig{Enabled: true})
+			lookup := func(_ context.Context, key string) (any, bool, error) { return key, true, nil }
+			return lookupsource.NewSource(
+				lookupsource.WrapWithCache(cache, lookup),
+				func() string { return "cached" },
+				nil,
+				nil,
+				lookupsource.WithCache(cache),
+				lookupsource.WithHealthCheck(func(context.Context) error { return errors.New("unreachable") }),
+			), nil
+		},
+	)
+}
+
+func TestSourceMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	set := extensiontest.NewNopSettings(metadata.Type)
+	set.MeterProvider = sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	f := NewFactoryWithOptions(WithSources(cachedFactory(), static.NewFactory()))
+	cfg := f.CreateDefaultConfig().(*Config)
+	cfg.Sources = map[string]SourceConfig{
+		"hosts": {Type: "cached", Config: &static.Config{}},
+		"users": {Type: "static", Config: &static.Config{Entries: map[string]any{"a": "b"}}},
+	}
+
+	ext, err := f.Create(t.Context(), set, cfg)
+	require.NoError(t, err)
+	require.NoError(t, ext.Start(t.Context(), componenttest.NewNopHost()))
+
+	// One miss then three hits.
+	hosts, _ := ext.(lookupsource.LookupExtension).Source("hosts")
+	for range 4 {
+		_, _, err = hosts.Lookup(t.Context(), "10.0.0.1")
+		require.NoError(t, err)
+	}
+
+	hostsAttrs := attribute.NewSet(attribute.String("source_type", "cached"), attribute.String("source_name", "hosts"))
+	usersAttrs := attribute.NewSet(attribute.String("source_type", "static"), attribute.String("source_name", "users"))
+
+	got := collect(t, reader)
+	assert.Equal(t, map[attribute.Set]float64{hostsAttrs: 1}, got["otelcol_lookup_source_cache_size"])
+	assert.Equal(t, map[attribute.Set]float64{hostsAttrs: 0.75}, got["otelcol_lookup_source_cache_hit_ratio"])
+	assert.Equal(t, map[attribute.Set]float64{hostsAttrs: 0, usersAttrs: 1}, got["otelcol_lookup_source_health"])
+
+	// The gauges are no longer observed once the extension is shut down.
+	require.NoError(t, ext.Shutdown(t.Context()))
+	assert.Empty(t, collect(t, reader))
+}
+
+// collect returns the values of the gauges read by reader, by name and
+// attributes.
+func collect(t *testing.T, reader *sdkmetric.ManualReader) map[string]map[attribute.Set]float64 {
+	t.Helper()
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(t.Context(), &rm))
+
+	got := make(map[string]map[attribute.Set]float64)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			values := make(map[attribute.Set]float64)
+			switch data := m.Data.(type) {
+			case metricdata.Gauge[int64]:
+				for _, dp := range data.DataPoints {
+					values[dp.Attributes] = float64(dp.Value)
+				}
+			case metricdata.Gauge[float64]:
+				for _, dp := range data.DataPoints {
+					values[dp.Attributes] = dp.Value
+				}
+			default:
+				t.Fatalf("unexpected data type %T for %s", m.Data, m.Name)
+			}
+			if len(values) > 0 {
+				got[m.Name] = values
+			}
+		}
+	}
+	return got
+}
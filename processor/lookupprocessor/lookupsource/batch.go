@@ -0,0 +1,88 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+
+import "context"
+
+// Result is the outcome of looking up a single key in a batch.
+type Result struct {
+	Value any
+	Found bool
+	// Err is set if the lookup of this key failed. Other keys in the
+	// batch are unaffected.
+	Err error
+}
+
+// BatchLookupFunc looks up several keys at once. It must return exactly one
+// Result per key, in the same order as keys. A non-nil error means the whole
+// batch failed.
+type BatchLookupFunc func(ctx context.Context, keys []string) ([]Result, error)
+
+// BatchSource is implemented by sources that can resolve many keys in a single
+// round trip (e.g., Redis MGET or a SQL IN clause).
+//
+// Use [WithBatchLookup] to create implementations and [AsBatchSource] to batch
+// over any [Source].
+type BatchSource interface {
+	Source
+	LookupBatch(ctx context.Context, keys []string) ([]Result, error)
+}
+
+// WithBatchLookup makes [NewSource] return a [BatchSource] that uses fn for
+// batch lookups.
+func WithBatchLookup(fn BatchLookupFunc) SourceOption {
+	return batchLookupOption{fn: fn}
+}
+
+type batchLookupOption struct {
+	fn BatchLookupFunc
+}
+
+func (o batchLookupOption) apply(s *sourceImpl) {
+	s.batchFn = o.fn
+}
+
+// AsBatchSource returns source as a BatchSource. Sources that don't implement
+// batching are adapted by calling Lookup once per key.
+func AsBatchSource(source Source) BatchSource {
+	if bs, ok := source.(BatchSource); ok {
+		return bs
+	}
+	return loopBatchSource{Source: source}
+}
+
+type loopBatchSource struct {
+	Source
+}
+
+func (s loopBatchSource) LookupBatch(ctx context.Context, keys []string) ([]Result, error) {
+	return LoopBatch(s.Lookup)(ctx, keys)
+}
+
+// LoopBatch adapts a LookupFunc into a BatchLookupFunc that looks up each key
+// in turn. It stops early if ctx is canceled.
+func LoopBatch(fn LookupFunc) BatchLookupFunc {
+	return func(ctx context.Context, keys []string) ([]Result, error) {
+		results := make([]Result, len(keys))
+		for i, key := range keys {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			val, found, err := fn(ctx, key)
+			results[i] = Result{Value: val, Found: found, Err: err}
+		}
+		return results, nil
+	}
+}
+
+type batchSourceImpl struct {
+	*sourceImpl
+}
+
+func (s *batchSourceImpl) LookupBatch(ctx context.Context, keys []string) ([]Result, error) {
+	if s.keyFn != nil {
+		keys = canonicalKeys(keys, s.keyFn)
+	}
+	return s.batchFn(ctx, keys)
+}
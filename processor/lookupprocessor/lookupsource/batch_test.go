@@ -0,0 +1,128 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrapBatchWithCache(t *testing.T) {
+	keyErr := errors.New("key failed")
+	var forwarded [][]string
+	fn := func(_ context.Context, keys []string) ([]Result, error) {
+		forwarded = append(forwarded, keys)
+		results := make([]Result, len(keys))
+		for i, key := range keys {
+			switch key {
+			case "missing":
+			case "broken":
+				results[i] = Result{Err: keyErr}
+			default:
+				results[i] = Result{Value: "value-" + key, Found: true}
+			}
+		}
+		return results, nil
+	}
+
+	cache := NewCache(CacheConfig{Enabled: true})
+	cache.Set("a", "cached-a")
+	cache.Set("c", "cached-c")
+	lookup := WrapBatchWithCache(cache, fn)
+
+	results, err := lookup(t.Context(), []string{"a", "b", "c", "missing", "broken"})
+	require.NoError(t, err)
+	assert.Equal(t, []Result{
+		{Value: "cached-a", Found: true},
+		{Value: "value-b", Found: true},
+		{Value: "cached-c", Found: true},
+		{},
+		{Err: keyErr},
+	}, results)
+	require.Len(t, forwarded, 1)
+	assert.Equal(t, []string{"b", "missing", "broken"}, forwarded[0])
+
+	// b is now cached; misses and failures are retried.
+	_, err = lookup(t.Context(), []string{"a", "b", "missing", "broken"})
+	require.NoError(t, err)
+	require.Len(t, forwarded, 2)
+	assert.Equal(t, []string{"missing", "broken"}, forwarded[1])
+
+	// All hits don't call the source.
+	_, err = lookup(t.Context(), []string{"a", "b"})
+	require.NoError(t, err)
+	assert.Len(t, forwarded, 2)
+
+	// Bypassing the cache forwards every key, refreshing the cached ones.
+	results, err = lookup(WithCacheBypass(t.Context()), []string{"a", "b"})
+	require.NoError(t, err)
+	require.Len(t, forwarded, 3)
+	assert.Equal(t, []string{"a", "b"}, forwarded[2])
+	assert.Equal(t, []Result{{Value: "value-a", Found: true}, {Value: "value-b", Found: true}}, results)
+	val, _ := cache.Get("a")
+	assert.Equal(t, "value-a", val)
+}
+
+func TestWrapBatchWithCacheErrors(t *testing.T) {
+	cache := NewCache(CacheConfig{Enabled: true})
+
+	batchErr := errors.New("unavailable")
+	_, err := WrapBatchWithCache(cache, func(context.Context, []string) ([]Result, error) {
+		return nil, batchErr
+	})(t.Context(), []string{"a"})
+	assert.ErrorIs(t, err, batchErr)
+
+	_, err = WrapBatchWithCache(cache, func(context.Context, []string) ([]Result, error) {
+		return []Result{}, nil
+	})(t.Context(), []string{"a"})
+	assert.ErrorContains(t, err, "returned 0 results for 1 keys")
+}
+
+func TestAsBatchSource(t *testing.T) {
+	lookup := func(_ context.Context, key string) (any, bool, error) {
+		if key == "missing" {
+			return nil, false, nil
+		}
+		return "value-" + key, true, nil
+	}
+
+	t.Run("adapts single lookups", func(t *testing.T) {
+		source := NewSource(lookup, func() string { return "test" }, nil, nil)
+		_, ok := source.(BatchSource)
+		require.False(t, ok)
+
+		results, err := AsBatchSource(source).LookupBatch(t.Context(), []string{"a", "missing"})
+		require.NoError(t, err)
+		assert.Equal(t, []Result{{Value: "value-a", Found: true}, {}}, results)
+	})
+
+	t.Run("uses native batching", func(t *testing.T) {
+		called := false
+		source := NewSource(lookup, func() string { return "test" }, nil, nil,
+			WithBatchLookup(func(_ context.Context, keys []string) ([]Result, error) {
+				called = true
+				return make([]Result, len(keys)), nil
+			}),
+		)
+		bs, ok := source.(BatchSource)
+		require.True(t, ok)
+		assert.Same(t, bs, AsBatchSource(source))
+		assert.Equal(t, "test", bs.Type())
+
+		_, err := bs.LookupBatch(t.Context(), []string{"a"})
+		require.NoError(t, err)
+		assert.True(t, called)
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		_, err := LoopBatch(lookup)(ctx, []string{"a"})
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
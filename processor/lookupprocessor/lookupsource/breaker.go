@@ -0,0 +1,222 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by lookups rejected by an open circuit breaker.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerState is the state of a [CircuitBreaker].
+type BreakerState int
+
+const (
+	// BreakerClosed lets all lookups through.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects lookups without calling the source.
+	BreakerOpen
+	// BreakerHalfOpen lets a single probe through to test recovery.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half_open"
+	default:
+		return "unknown"
+	}
+}
+
+// BreakerConfig controls when a [CircuitBreaker] opens and recovers.
+type BreakerConfig struct {
+	// FailureThreshold is the number of consecutive failed lookups that
+	// opens the breaker.
+	// Default: 5
+	FailureThreshold int `mapstructure:"failure_threshold"`
+
+	// Cooldown is how long the breaker stays open before letting a probe
+	// lookup through.
+	// Default: 30s
+	Cooldown time.Duration `mapstructure:"cooldown"`
+
+	// NotFoundWhenOpen makes rejected lookups report "not found" instead
+	// of returning ErrCircuitOpen.
+	NotFoundWhenOpen bool `mapstructure:"not_found_when_open"`
+
+	// OnStateChange, if set, is called after every state transition.
+	// It must not block.
+	OnStateChange func(from, to BreakerState) `mapstructure:"-"`
+}
+
+const defaultFailureThreshold = 5
+
+func NewDefaultBreakerConfig() BreakerConfig {
+	return BreakerConfig{
+		FailureThreshold: defaultFailureThreshold,
+		Cooldown:         30 * time.Second,
+	}
+}
+
+// CircuitBreaker stops calling a failing source for a cooldown period so
+// lookups fail fast instead of each waiting for a timeout.
+type CircuitBreaker struct {
+	cfg BreakerConfig
+	now func() time.Time
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func NewCircuitBreaker(cfg BreakerConfig) *CircuitBreaker {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = defaultFailureThreshold
+	}
+	return &CircuitBreaker{cfg: cfg, now: time.Now}
+}
+
+// State returns the current breaker state.
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerOpen && b.cooledDown() {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// Wrap returns a lookup function guarded by the breaker.
+// Lookups that find nothing count as successes, and so do errors marked
+// with [Permanent]: the source answered, the request was wrong. Errors
+// caused by the caller's context being done count as neither: a half-open
+// breaker lets another probe through instead. While half-open, only the
+// probe decides whether the breaker closes or opens again; lookups let
+// through before it opened count as neither.
+func (b *CircuitBreaker) Wrap(fn LookupFunc) LookupFunc {
+	return func(ctx context.Context, key string) (any, bool, error) {
+		probe, ok := b.allow()
+		if !ok {
+			if b.cfg.NotFoundWhenOpen {
+				return nil, false, nil
+			}
+			return nil, false, ErrCircuitOpen
+		}
+
+		val, found, err := fn(ctx, key)
+		if err != nil && ctx.Err() != nil {
+			b.release(probe)
+		} else {
+			b.record(probe, err != nil && !IsPermanent(err))
+		}
+		return val, found, err
+	}
+}
+
+// WithCircuitBreaker wraps a lookup function with a new [CircuitBreaker].
+// Use [NewCircuitBreaker] directly to observe the breaker state.
+//
+// Example:
+//
+//	lookup := lookupsource.WithCircuitBreaker(myLookupFunc, lookupsource.NewDefaultBreakerConfig())
+func WithCircuitBreaker(fn LookupFunc, cfg BreakerConfig) LookupFunc {
+	return NewCircuitBreaker(cfg).Wrap(fn)
+}
+
+// allow reports whether a lookup may call the source, and whether it is the
+// probe of a half-open breaker.
+func (b *CircuitBreaker) allow() (probe, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerClosed:
+		return false, true
+	case BreakerOpen:
+		if !b.cooledDown() {
+			return false, false
+		}
+		b.setState(BreakerHalfOpen)
+	}
+
+	// Half-open: only one probe at a time.
+	if b.probing {
+		return false, false
+	}
+	b.probing = true
+	return true, true
+}
+
+func (b *CircuitBreaker) record(probe, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if probe {
+		b.probing = false
+		if failed {
+			b.open()
+		} else {
+			b.failures = 0
+			b.setState(BreakerClosed)
+		}
+		return
+	}
+	if b.state != BreakerClosed {
+		// The breaker opened while the lookup ran: the probe decides when
+		// it closes again.
+		return
+	}
+
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.cfg.FailureThreshold {
+		b.open()
+	}
+}
+
+// release ends a lookup that says nothing of the source, freeing the probe
+// slot of a half-open breaker without changing its state.
+func (b *CircuitBreaker) release(probe bool) {
+	if !probe {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *CircuitBreaker) open() {
+	b.openedAt = b.now()
+	b.setState(BreakerOpen)
+}
+
+func (b *CircuitBreaker) cooledDown() bool {
+	return b.now().Sub(b.openedAt) >= b.cfg.Cooldown
+}
+
+// setState records a transition. b.mu must be held.
+func (b *CircuitBreaker) setState(to BreakerState) {
+	from := b.state
+	if from == to {
+		return
+	}
+	b.state = to
+	if b.cfg.OnStateChange != nil {
+		b.cfg.OnStateChange(from, to)
+	}
+}
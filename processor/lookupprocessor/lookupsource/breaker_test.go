@@ -0,0 +1,237 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	errUpstream := errors.New("upstream down")
+	failing := true
+	calls := 0
+	fn := func(context.Context, string) (any, bool, error) {
+		calls++
+		if failing {
+			return nil, false, errUpstream
+		}
+		return "value", true, nil
+	}
+
+	var transitions []string
+	now := time.Now()
+	breaker := NewCircuitBreaker(BreakerConfig{
+		FailureThreshold: 2,
+		Cooldown:         time.Minute,
+		OnStateChange: func(from, to BreakerState) {
+			transitions = append(transitions, from.String()+"->"+to.String())
+		},
+	})
+	breaker.now = func() time.Time { return now }
+	lookup := breaker.Wrap(fn)
+
+	// Closed: failures below the threshold pass through.
+	_, _, err := lookup(t.Context(), "key")
+	require.ErrorIs(t, err, errUpstream)
+	assert.Equal(t, BreakerClosed, breaker.State())
+
+	// Reaching the threshold opens the breaker.
+	_, _, err = lookup(t.Context(), "key")
+	require.ErrorIs(t, err, errUpstream)
+	assert.Equal(t, BreakerOpen, breaker.State())
+
+	// Open: lookups fail fast without calling the source.
+	_, _, err = lookup(t.Context(), "key")
+	require.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, 2, calls)
+
+	// After the cooldown a failed probe reopens the breaker.
+	now = now.Add(time.Minute)
+	assert.Equal(t, BreakerHalfOpen, breaker.State())
+	_, _, err = lookup(t.Context(), "key")
+	require.ErrorIs(t, err, errUpstream)
+	assert.Equal(t, BreakerOpen, breaker.State())
+	assert.Equal(t, 3, calls)
+
+	// A successful probe closes it.
+	now = now.Add(time.Minute)
+	failing = false
+	val, found, err := lookup(t.Context(), "key")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "value", val)
+	assert.Equal(t, BreakerClosed, breaker.State())
+
+	assert.Equal(t, []string{
+		"closed->open",
+		"open->half_open",
+		"half_open->open",
+		"open->half_open",
+		"half_open->closed",
+	}, transitions)
+}
+
+func TestCircuitBreakerSuccessResetsFailures(t *testing.T) {
+	errUpstream := errors.New("upstream down")
+	results := []error{errUpstream, nil, errUpstream, nil}
+	lookup := WithCircuitBreaker(func(context.Context, string) (any, bool, error) {
+		err := results[0]
+		results = results[1:]
+		// Not found is a successful lookup.
+		return nil, false, err
+	}, BreakerConfig{FailureThreshold: 2, Cooldown: time.Minute})
+
+	for range 3 {
+		_, _, _ = lookup(t.Context(), "key")
+	}
+	_, _, err := lookup(t.Context(), "key")
+	assert.NoError(t, err)
+}
+
+func TestCircuitBreakerNotFoundWhenOpen(t *testing.T) {
+	lookup := WithCircuitBreaker(func(context.Context, string) (any, bool, error) {
+		return nil, false, errors.New("upstream down")
+	}, BreakerConfig{FailureThreshold: 1, Cooldown: time.Minute, NotFoundWhenOpen: true})
+
+	_, _, err := lookup(t.Context(), "key")
+	require.Error(t, err)
+
+	_, found, err := lookup(t.Context(), "key")
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestCircuitBreakerIgnoresCallerCancellation(t *testing.T) {
+	breaker := NewCircuitBreaker(BreakerConfig{FailureThreshold: 1, Cooldown: time.Minute})
+	lookup := breaker.Wrap(func(ctx context.Context, _ string) (any, bool, error) {
+		return nil, false, ctx.Err()
+	})
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	_, _, err := lookup(ctx, "key")
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, BreakerClosed, breaker.State())
+}
+
+func TestCircuitBreakerCancelledProbe(t *testing.T) {
+	var transitions []string
+	now := time.Now()
+	breaker := NewCircuitBreaker(BreakerConfig{
+		FailureThreshold: 1,
+		Cooldown:         time.Minute,
+		OnStateChange: func(from, to BreakerState) {
+			transitions = append(transitions, from.String()+"->"+to.String())
+		},
+	})
+	breaker.now = func() time.Time { return now }
+	errUpstream := errors.New("upstream down")
+	lookup := breaker.Wrap(func(ctx context.Context, _ string) (any, bool, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, false, err
+		}
+		return nil, false, errUpstream
+	})
+
+	_, _, err := lookup(t.Context(), "key")
+	require.ErrorIs(t, err, errUpstream)
+	now = now.Add(time.Minute)
+
+	// A probe cancelled by its caller leaves the breaker half-open, and
+	// the next lookup probes again.
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	_, _, err = lookup(ctx, "key")
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, BreakerHalfOpen, breaker.State())
+
+	_, _, err = lookup(t.Context(), "key")
+	require.ErrorIs(t, err, errUpstream)
+	assert.Equal(t, BreakerOpen, breaker.State())
+	assert.Equal(t, []string{"closed->open", "open->half_open", "half_open->open"}, transitions)
+}
+
+func TestCircuitBreakerIgnoresPermanentErrors(t *testing.T) {
+	breaker := NewCircuitBreaker(BreakerConfig{FailureThreshold: 1, Cooldown: time.Minute})
+	errMalformed := Permanent(errors.New("malformed key"))
+	lookup := breaker.Wrap(func(context.Context, string) (any, bool, error) {
+		return nil, false, errMalformed
+	})
+
+	for range 3 {
+		_, _, err := lookup(t.Context(), "key")
+		require.ErrorIs(t, err, errMalformed)
+	}
+	assert.Equal(t, BreakerClosed, breaker.State())
+}
+
+func TestCircuitBreakerOnlyProbeDecidesHalfOpen(t *testing.T) {
+	now := time.Now()
+	breaker := NewCircuitBreaker(BreakerConfig{FailureThreshold: 1, Cooldown: time.Minute})
+	breaker.now = func() time.Time { return now }
+	errUpstream := errors.New("upstream down")
+	// Lookups of "slow" and "probe" tell started once they run, then wait
+	// for release.
+	started := make(chan struct{})
+	release := map[string]chan struct{}{"slow": make(chan struct{}), "probe": make(chan struct{})}
+	lookup := breaker.Wrap(func(_ context.Context, key string) (any, bool, error) {
+		if wait, ok := release[key]; ok {
+			started <- struct{}{}
+			<-wait
+		}
+		if key == "slow" {
+			return "value", true, nil
+		}
+		return nil, false, errUpstream
+	})
+	lookupAsync := func(key string) <-chan error {
+		done := make(chan error, 1)
+		go func() {
+			_, _, err := lookup(t.Context(), key)
+			done <- err
+		}()
+		<-started
+		return done
+	}
+
+	// A lookup let through while closed is still running when the breaker
+	// opens, and then goes half-open.
+	slow := lookupAsync("slow")
+	_, _, err := lookup(t.Context(), "key")
+	require.ErrorIs(t, err, errUpstream)
+	now = now.Add(time.Minute)
+	probe := lookupAsync("probe")
+	_, _, err = lookup(t.Context(), "key")
+	require.ErrorIs(t, err, ErrCircuitOpen, "the probe holds the probe slot")
+
+	// The earlier lookup succeeding doesn't close the breaker.
+	close(release["slow"])
+	require.NoError(t, <-slow)
+	assert.Equal(t, BreakerHalfOpen, breaker.State())
+
+	// The probe failing opens it again.
+	close(release["probe"])
+	require.ErrorIs(t, <-probe, errUpstream)
+	assert.Equal(t, BreakerOpen, breaker.State())
+}
+
+func TestCircuitBreakerDefaultFailureThreshold(t *testing.T) {
+	breaker := NewCircuitBreaker(BreakerConfig{Cooldown: time.Minute})
+	lookup := breaker.Wrap(func(context.Context, string) (any, bool, error) {
+		return nil, false, errors.New("upstream down")
+	})
+
+	for range defaultFailureThreshold - 1 {
+		_, _, _ = lookup(t.Context(), "key")
+	}
+	assert.Equal(t, BreakerClosed, breaker.State())
+	_, _, _ = lookup(t.Context(), "key")
+	assert.Equal(t, BreakerOpen, breaker.State())
+}
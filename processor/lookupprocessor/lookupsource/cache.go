@@ -4,7 +4,12 @@
 package lookupsource // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
 
 import (
+	"container/list"
 	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -16,52 +21,806 @@ type CacheConfig struct {
 
 	// Default: 0 (no expiration)
 	TTL time.Duration `mapstructure:"ttl"`
+
+	// MinTTL is the shortest time-to-live of an entry.
+	// Default: 0 (no floor)
+	MinTTL time.Duration `mapstructure:"min_ttl"`
+
+	// MaxTTL is the longest time-to-live of an entry, including entries
+	// that wouldn't expire. It takes precedence over MinTTL.
+	// Default: 0 (no ceiling)
+	MaxTTL time.Duration `mapstructure:"max_ttl"`
+
+	// CaseInsensitive lowercases keys. It is ignored if KeyNormalizer is set.
+	// Default: false
+	CaseInsensitive bool `mapstructure:"case_insensitive"`
+
+	// MaxValueBytes skips caching values whose estimated size is larger.
+	// Default: 0 (no limit)
+	MaxValueBytes int `mapstructure:"max_value_bytes"`
+
+	// ErrorTTL is how long WrapWithCache and WrapBatchWithCache cache the
+	// errors of lookups. MinTTL doesn't apply to it.
+	// Default: 0 (errors are not cached)
+	ErrorTTL time.Duration `mapstructure:"error_ttl"`
+
+	// EvictInSetOrder evicts the least recently set entry rather than the
+	// least recently used one, so that Get doesn't reorder entries.
+	// Default: false
+	EvictInSetOrder bool `mapstructure:"evict_in_set_order"`
+
+	// KeyNormalizer canonicalizes keys before they are read or written.
+	KeyNormalizer func(string) string `mapstructure:"-"`
+
+	// OnEvict, if set, is called with the stored key and the value of every
+	// entry the cache drops, but not of entries replaced by Set. It is
+	// called once the cache is unlocked, and may run concurrently.
+	OnEvict func(key string, value any) `mapstructure:"-"`
+}
+
+const defaultCacheSize = 1000
+
+// CacheBackend stores the entries of [WrapWithCache] and [WrapBatchWithCache].
+// [Cache], returned by [NewCache], keeps them in memory; other
+// implementations, such as one backed by Redis or memcached, can share them
+// between collectors. Implementations must be safe for concurrent use, and
+// expire or evict entries as they see fit.
+type CacheBackend interface {
+	// Get returns the value of key, and whether it was found.
+	Get(key string) (any, bool)
+	// Set stores value for key, replacing any previous value.
+	Set(key string, value any)
+	// Delete removes the entry of key, if any.
+	Delete(key string)
+	// Clear removes every entry.
+	Clear()
+	// Size returns the number of entries.
+	Size() int
 }
 
+var _ CacheBackend = (*Cache)(nil)
+
+// Cache is a size-bounded cache with optional TTL expiration. When full, the
+// least recently used entry is evicted, or the least recently set one
+// with [CacheConfig.EvictInSetOrder]. It is safe for concurrent use.
+//
+// Sources owning a cache call [Cache.Shutdown] when they shut down.
 type Cache struct {
-	config CacheConfig
+	config    CacheConfig
+	now       func() time.Time
+	normalize func(string) string
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	// order holds the keys from least to most recently used, or set with
+	// EvictInSetOrder. Each entry holds its element, so that moving or
+	// removing a key doesn't search the list.
+	order list.List
+	// hits, misses and evictions are atomic so that Stats and ResetStats
+	// don't wait for c.mu.
+	hits      atomic.Int64
+	misses    atomic.Int64
+	evictions atomic.Int64
+	// bytes is the sum of the sizes of the entries, see MemoryUsage.
+	bytes int64
+	// closed is set by Shutdown.
+	closed bool
+	// removed are the entries to pass to OnEvict once c.mu is released.
+	removed []removedEntry
+	// onChange are the functions registered with OnChange, called once c.mu
+	// is released if changed is set.
+	onChange []func()
+	changed  bool
+}
+
+type removedEntry struct {
+	key   string
+	value any
+}
+
+type cacheEntry struct {
+	value     any
+	expiresAt time.Time
+	// accesses counts the Get calls that found the entry since it was set.
+	accesses int64
+	// size is the estimated size of the entry, counted in Cache.bytes.
+	size int64
+	// elem is the element of the key in Cache.order.
+	elem *list.Element
 }
 
 func NewCache(cfg CacheConfig) *Cache {
-	return &Cache{config: cfg}
+	if cfg.Size <= 0 {
+		cfg.Size = defaultCacheSize
+	}
+	normalize := cfg.KeyNormalizer
+	if normalize == nil && cfg.CaseInsensitive {
+		normalize = strings.ToLower
+	}
+	return &Cache{
+		config:    cfg,
+		now:       time.Now,
+		normalize: normalize,
+		entries:   make(map[string]cacheEntry),
+	}
+}
+
+func (c *Cache) Get(key string) (any, bool) {
+	key = c.key(key)
+
+	c.mu.Lock()
+	defer c.unlock()
+
+	if c.closed {
+		return nil, false
+	}
+	entry, ok := c.entries[key]
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+	if !entry.expiresAt.IsZero() && !c.now().Before(entry.expiresAt) {
+		c.remove(key)
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.hits.Add(1)
+	entry.accesses++
+	c.entries[key] = entry
+	if !c.config.EvictInSetOrder {
+		c.order.MoveToBack(entry.elem)
+	}
+	return entry.value, true
+}
+
+// CacheEntryInfo describes an entry of a [Cache].
+type CacheEntryInfo struct {
+	// Accesses counts the Get calls that found the entry since it was set.
+	// Setting a key again, such as to refresh it, resets its count.
+	Accesses int64
+	// ExpiresAt is when the entry expires, or zero if it doesn't.
+	ExpiresAt time.Time
+}
+
+// Entry returns the description of the entry of key, or false if key has no
+// entry or an expired one. Unlike Get, it neither counts as an access of the
+// entry nor as a hit or miss, so that sources can decide from the accesses of
+// an entry they just read whether to refresh it ahead of its expiry.
+func (c *Cache) Entry(key string) (CacheEntryInfo, bool) {
+	key = c.key(key)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || (!entry.expiresAt.IsZero() && !c.now().Before(entry.expiresAt)) {
+		return CacheEntryInfo{}, false
+	}
+	return CacheEntryInfo{Accesses: entry.accesses, ExpiresAt: entry.expiresAt}, true
 }
 
-func (*Cache) Get(_ string) (any, bool) {
-	return nil, false
+func (c *Cache) Set(key string, value any) {
+	c.set(key, value, c.config.TTL)
 }
 
-func (*Cache) Set(_ string, _ any) {
-	// Stub
+// SetWithTTL sets key to value like Set, with the time-to-live ttl instead of
+// the configured TTL, such as the TTL of a DNS answer. A ttl of zero or less
+// means no expiration.
+func (c *Cache) SetWithTTL(key string, value any, ttl time.Duration) {
+	c.set(key, value, ttl)
 }
 
-func (*Cache) Clear() {
-	// Stub
+func (c *Cache) set(key string, value any, ttl time.Duration) {
+	c.store(key, value, c.clampTTL(ttl))
 }
 
-// WrapWithCache wraps a lookup function with caching.
+// setError caches err for key for ErrorTTL, lowered to MaxTTL but never
+// raised to MinTTL.
+func (c *Cache) setError(key string, err error) {
+	ttl := c.config.ErrorTTL
+	if c.config.MaxTTL > 0 && ttl > c.config.MaxTTL {
+		ttl = c.config.MaxTTL
+	}
+	c.store(key, errorEntry{err: err}, ttl)
+}
+
+// store sets key to value, expiring after ttl if positive.
+func (c *Cache) store(key string, value any, ttl time.Duration) {
+	key = c.key(key)
+
+	c.mu.Lock()
+	defer c.unlock()
+
+	if c.closed {
+		return
+	}
+	if c.config.MaxValueBytes > 0 && estimateSize(value) > c.config.MaxValueBytes {
+		// The previous value of key mustn't be returned in place of value.
+		if _, ok := c.entries[key]; ok {
+			c.remove(key)
+		}
+		return
+	}
+	entry := cacheEntry{value: value, size: entrySize(key, value)}
+	if ttl > 0 {
+		entry.expiresAt = c.now().Add(ttl)
+	}
+
+	if old, ok := c.entries[key]; ok {
+		c.bytes -= old.size
+		c.order.MoveToBack(old.elem)
+		entry.elem = old.elem
+	} else {
+		if len(c.entries) >= c.config.Size {
+			c.remove(c.order.Front().Value.(string))
+			c.evictions.Add(1)
+		}
+		entry.elem = c.order.PushBack(key)
+	}
+	c.entries[key] = entry
+	c.bytes += entry.size
+}
+
+func (c *Cache) Clear() {
+	c.mu.Lock()
+	defer c.unlock()
+
+	c.removeAll()
+	c.changed = true
+}
+
+// OnChange registers fn to be called after every Clear or InvalidateFunc,
+// which sources call when the data they look keys up in changes, such as a
+// reloaded file, so that results derived from their values, like the ones
+// cached by a chain, can be dropped as well. fn is called after the cache is
+// unlocked, so it may use the cache.
+func (c *Cache) OnChange(fn func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onChange = append(c.onChange, fn)
+}
+
+// InvalidateFunc removes the entries for which pred returns true and returns
+// the number removed, such as the entries of keys a reloaded dataset no
+// longer holds. pred receives the stored form of each key, normalized if the
+// cache normalizes keys, and is called with the cache locked, so it must not
+// use the cache. Expired entries not yet removed are passed to pred as well.
+// Removed entries are not counted as evictions.
+func (c *Cache) InvalidateFunc(pred func(key string, value any) bool) int {
+	c.mu.Lock()
+	defer c.unlock()
+
+	removed := 0
+	for elem := c.order.Front(); elem != nil; {
+		next := elem.Next()
+		if key := elem.Value.(string); pred(key, c.entries[key].value) {
+			c.remove(key)
+			removed++
+		}
+		elem = next
+	}
+	c.changed = true
+	return removed
+}
+
+// Shutdown releases the entries of the cache. Afterwards, Get finds nothing
+// and Set does nothing, so that lookups still in flight when their source
+// shuts down neither read nor repopulate the cache, and Stats keeps the hit,
+// miss and eviction counts reached by then. Shutdown is idempotent and may be
+// called concurrently with Get and Set.
+func (c *Cache) Shutdown(_ context.Context) error {
+	c.mu.Lock()
+	defer c.unlock()
+
+	c.closed = true
+	c.removeAll()
+	return nil
+}
+
+// RemoveExpired removes the expired entries and returns the number removed.
+// Expired entries are otherwise only removed when Get finds them, so sources
+// expecting entries never read again, or releasing resources with OnEvict,
+// can call it periodically. Removed entries are not counted as evictions.
+func (c *Cache) RemoveExpired() int {
+	c.mu.Lock()
+	defer c.unlock()
+
+	now := c.now()
+	removed := 0
+	for elem := c.order.Front(); elem != nil; {
+		next := elem.Next()
+		key := elem.Value.(string)
+		if at := c.entries[key].expiresAt; !at.IsZero() && !now.Before(at) {
+			c.remove(key)
+			removed++
+		}
+		elem = next
+	}
+	return removed
+}
+
+// Len returns the number of entries, including expired ones not yet removed.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Size returns the number of entries, like [Cache.Len].
+func (c *Cache) Size() int {
+	return c.Len()
+}
+
+// MemoryUsage returns an estimate of the bytes held by the entries of the
+// cache, including expired ones not yet removed: the length of each key,
+// the size of its value estimated like for [CacheConfig.MaxValueBytes], and
+// a fixed overhead per entry for the bookkeeping of the cache. It is kept up
+// to date as entries are set and removed, so it is cheap to call, and the
+// same entries always give the same estimate. The actual memory used
+// depends on the Go runtime and is usually higher.
+func (c *Cache) MemoryUsage() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.bytes
+}
+
+// CacheStats are the statistics of a [Cache].
+type CacheStats struct {
+	// Hits and Misses count the Get calls that found, or didn't find, an
+	// entry.
+	Hits   int64
+	Misses int64
+	// Evictions counts the entries removed to make room for new ones.
+	// Expired entries are not evictions.
+	Evictions int64
+	// Size is the number of entries, including expired ones not yet removed.
+	Size int
+}
+
+// HitRatio returns the fraction of Get calls that found an entry, or 0 if
+// Get wasn't called.
+func (s CacheStats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// Stats returns the statistics of the cache. The counters are read one at a
+// time, so lookups running concurrently may be counted in some and not yet
+// in others.
+func (c *Cache) Stats() CacheStats {
+	return CacheStats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+		Size:      c.Len(),
+	}
+}
+
+// ResetStats zeroes the hit, miss and eviction counts, leaving the entries
+// as they are, so that statistics sampled periodically cover the interval
+// since the previous sample, such as the hit ratio of the last minute.
+// Lookups running concurrently are counted in either interval.
+func (c *Cache) ResetStats() {
+	c.hits.Store(0)
+	c.misses.Store(0)
+	c.evictions.Store(0)
+}
+
+// CacheStatsReporter is implemented by sources that can report the
+// statistics of their cache.
+//
+// Sources created with [NewSource] implement it, reporting the cache set with
+// [WithCache] if it is enabled.
+type CacheStatsReporter interface {
+	CacheStats() (CacheStats, bool)
+}
+
+// WithCache sets the cache whose statistics the source's CacheStats method
+// reports, and whose clears and invalidations its OnChange method reports.
+// It doesn't enable caching: wrap the lookup function with [WrapWithCache]
+// as well.
+func WithCache(cache *Cache) SourceOption {
+	return cacheOption{cache: cache}
+}
+
+type cacheOption struct {
+	cache *Cache
+}
+
+func (o cacheOption) apply(s *sourceImpl) {
+	s.cache = o.cache
+}
+
+// clampTTL applies MinTTL and MaxTTL to ttl, where zero or less means no
+// expiration.
+func (c *Cache) clampTTL(ttl time.Duration) time.Duration {
+	if ttl > 0 && ttl < c.config.MinTTL {
+		ttl = c.config.MinTTL
+	}
+	if c.config.MaxTTL > 0 && (ttl <= 0 || ttl > c.config.MaxTTL) {
+		ttl = c.config.MaxTTL
+	}
+	return ttl
+}
+
+// key returns the canonical form of key under which its entry is stored.
+func (c *Cache) key(key string) string {
+	if c.normalize == nil {
+		return key
+	}
+	return c.normalize(key)
+}
+
+// Delete removes the entry of key, if any.
+func (c *Cache) Delete(key string) {
+	key = c.key(key)
+
+	c.mu.Lock()
+	defer c.unlock()
+
+	if _, ok := c.entries[key]; ok {
+		c.remove(key)
+	}
+}
+
+// estimateSize returns an estimate of the bytes held by a lookup value: the
+// length of strings and byte slices, 8 bytes for other scalars, and the sum
+// of the elements of slices and maps with their keys.
+func estimateSize(value any) int {
+	switch v := value.(type) {
+	case nil:
+		return 0
+	case string:
+		return len(v)
+	case []byte:
+		return len(v)
+	case bool:
+		return 1
+	case []string:
+		size := 0
+		for _, s := range v {
+			size += len(s)
+		}
+		return size
+	case []any:
+		size := 0
+		for _, e := range v {
+			size += estimateSize(e)
+		}
+		return size
+	case map[string]any:
+		size := 0
+		for k, e := range v {
+			size += len(k) + estimateSize(e)
+		}
+		return size
+	case TimedResult:
+		return estimateSize(v.Value) + 8
+	default:
+		return 8
+	}
+}
+
+// entryOverhead is the estimated size of the bookkeeping of a cache entry:
+// its map slot, expiration and counters, and its element in the order of keys.
+const entryOverhead = 64
+
+// entrySize returns the estimated size of the entry of key holding value.
+func entrySize(key string, value any) int64 {
+	return int64(len(key) + estimateSize(value) + entryOverhead)
+}
+
+// remove deletes key from the cache. c.mu must be held.
+func (c *Cache) remove(key string) {
+	entry := c.entries[key]
+	c.removedEntry(key, entry.value)
+	c.bytes -= entry.size
+	delete(c.entries, key)
+	c.order.Remove(entry.elem)
+}
+
+// removeAll deletes every entry from the cache. c.mu must be held.
+func (c *Cache) removeAll() {
+	for elem := c.order.Front(); elem != nil; elem = elem.Next() {
+		key := elem.Value.(string)
+		c.removedEntry(key, c.entries[key].value)
+	}
+	c.entries = make(map[string]cacheEntry)
+	c.order.Init()
+	c.bytes = 0
+}
+
+// removedEntry queues the removed entry key for OnEvict. c.mu must be held.
+func (c *Cache) removedEntry(key string, value any) {
+	if c.config.OnEvict != nil {
+		c.removed = append(c.removed, removedEntry{key: key, value: value})
+	}
+}
+
+// unlock releases c.mu, then calls OnEvict with the entries removed while it
+// was held, and the OnChange functions if the cache was cleared or
+// invalidated.
+func (c *Cache) unlock() {
+	removed := c.removed
+	c.removed = nil
+	var onChange []func()
+	if c.changed {
+		onChange = c.onChange
+		c.changed = false
+	}
+	c.mu.Unlock()
+	for _, e := range removed {
+		c.config.OnEvict(e.key, e.value)
+	}
+	for _, fn := range onChange {
+		fn()
+	}
+}
+
+// CacheWrapOption configures [WrapWithCache] and [WrapBatchWithCache].
+type CacheWrapOption interface {
+	applyCacheWrap(*cacheWrapConfig)
+}
+
+type cacheWrapConfig struct {
+	namespace   string
+	keyFn       func(string) string
+	negativeTTL time.Duration
+}
+
+// canonicalKey returns key mapped by the function set with WithKeyFunc.
+func (c cacheWrapConfig) canonicalKey(key string) string {
+	if c.keyFn == nil {
+		return key
+	}
+	return c.keyFn(key)
+}
+
+// key returns the cache key of the canonical key key.
+func (c cacheWrapConfig) key(key string) string {
+	if c.namespace == "" {
+		return key
+	}
+	return c.namespace + ":" + key
+}
+
+func newCacheWrapConfig(opts []CacheWrapOption) cacheWrapConfig {
+	var cfg cacheWrapConfig
+	for _, opt := range opts {
+		opt.applyCacheWrap(&cfg)
+	}
+	return cfg
+}
+
+// WithKeyNamespace stores the entries of the wrapped function under
+// "namespace:key", so that functions sharing a cache, such as those of
+// different source types, don't read each other's entries for the same key.
+// The wrapped function still receives the key without the namespace.
+func WithKeyNamespace(namespace string) CacheWrapOption {
+	return keyNamespaceOption{namespace: namespace}
+}
+
+type keyNamespaceOption struct {
+	namespace string
+}
+
+func (o keyNamespaceOption) applyCacheWrap(cfg *cacheWrapConfig) {
+	cfg.namespace = o.namespace
+}
+
+// WithNegativeCacheTTL caches keys the wrapped function doesn't find, so
+// that unknown keys don't reach the source on every lookup. A [Cache] keeps
+// them for ttl, whatever its TTL; other backends store them as
+// [NotFoundMarker] with Set and expire them like any other entry. Keys found
+// with an empty value are cached as found, not as misses. A non-positive ttl,
+// the default, doesn't cache misses.
+func WithNegativeCacheTTL(ttl time.Duration) CacheWrapOption {
+	return negativeCacheTTLOption(ttl)
+}
+
+type negativeCacheTTLOption time.Duration
+
+func (o negativeCacheTTLOption) applyCacheWrap(cfg *cacheWrapConfig) {
+	cfg.negativeTTL = time.Duration(o)
+}
+
+// isNegativeEntry reports whether val is a miss cached with
+// WithNegativeCacheTTL.
+func isNegativeEntry(val any) bool {
+	if _, ok := val.(notFoundEntry); ok {
+		return true
+	}
+	return val == NotFoundMarker
+}
+
+// errorEntry is the value cached for a key whose lookup failed, with
+// CacheConfig.ErrorTTL.
+type errorEntry struct {
+	err error
+}
+
+// cachesErrors returns cache as a [Cache], and true if the errors of lookups
+// with ctx are cached in it. Errors are only cached by a [Cache] with an
+// ErrorTTL, and not for lookups that bypassed the cache, which would replace
+// a good entry, nor for lookups whose own context is done, as the error then
+// says nothing of the source.
+func cachesErrors(ctx context.Context, cache CacheBackend, bypass bool) (*Cache, bool) {
+	local, ok := cache.(*Cache)
+	if !ok || local.config.ErrorTTL <= 0 || bypass || ctx.Err() != nil {
+		return nil, false
+	}
+	return local, true
+}
+
+// writesMisses reports whether setNegative writes to the cache. Checking it
+// first keeps misses, which dominate workloads such as scans of unknown keys,
+// from building the key of an entry that isn't written, and from reaching
+// the backend at all.
+func (c cacheWrapConfig) writesMisses(bypass bool) bool {
+	return c.negativeTTL > 0 || bypass
+}
+
+// setNegative caches key as not found, if enabled, or removes its entry if
+// the lookup bypassed the cache, since a refreshed key that no longer exists
+// mustn't keep its entry.
+func (c cacheWrapConfig) setNegative(cache CacheBackend, key string, bypass bool) {
+	switch {
+	case c.negativeTTL > 0:
+		if local, ok := cache.(*Cache); ok {
+			local.SetWithTTL(key, notFoundEntry{}, c.negativeTTL)
+		} else {
+			cache.Set(key, NotFoundMarker)
+		}
+	case bypass:
+		cache.Delete(key)
+	}
+}
+
+type cacheBypassKey struct{}
+
+// WithCacheBypass returns a copy of ctx with which the lookups of
+// [WrapWithCache] and [WrapBatchWithCache] don't read the cache: every key
+// is looked up again and its entry replaced with the fresh result, or removed
+// if the key is no longer found, such as to refresh stale keys without
+// clearing the whole cache.
+func WithCacheBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheBypassKey{}, true)
+}
+
+// IsCacheBypassed reports whether ctx was returned by [WithCacheBypass].
+func IsCacheBypassed(ctx context.Context) bool {
+	bypass, _ := ctx.Value(cacheBypassKey{}).(bool)
+	return bypass
+}
+
+// cacheEnabled reports whether cache stores entries: it is set and, if a
+// [Cache], enabled.
+func cacheEnabled(cache CacheBackend) bool {
+	if c, ok := cache.(*Cache); ok {
+		return c != nil && c.config.Enabled
+	}
+	return cache != nil
+}
+
+// WrapWithCache wraps a lookup function with caching. cache is usually a
+// [Cache], but can be any [CacheBackend]. A nil or disabled [Cache] disables
+// caching.
 //
 // Example:
 //
 //	cache := lookupsource.NewCache(cfg.Cache)
 //	cachedLookup := lookupsource.WrapWithCache(cache, myLookupFunc)
-func WrapWithCache(cache *Cache, fn LookupFunc) LookupFunc {
-	if cache == nil || !cache.config.Enabled {
-		return fn
+func WrapWithCache(cache CacheBackend, fn LookupFunc, opts ...CacheWrapOption) LookupFunc {
+	cfg := newCacheWrapConfig(opts)
+	if !cacheEnabled(cache) {
+		if cfg.keyFn == nil {
+			return fn
+		}
+		return func(ctx context.Context, key string) (any, bool, error) {
+			return fn(ctx, cfg.keyFn(key))
+		}
 	}
 	return func(ctx context.Context, key string) (any, bool, error) {
-		if val, found := cache.Get(key); found {
-			return val, true, nil
+		key = cfg.canonicalKey(key)
+		bypass := IsCacheBypassed(ctx)
+		if !bypass {
+			if val, found := cache.Get(cfg.key(key)); found {
+				if e, ok := val.(errorEntry); ok {
+					return nil, false, e.err
+				}
+				if isNegativeEntry(val) {
+					return nil, false, nil
+				}
+				return val, true, nil
+			}
 		}
 
 		val, found, err := fn(ctx, key)
 		if err != nil {
+			if local, ok := cachesErrors(ctx, cache, bypass); ok {
+				local.setError(cfg.key(key), err)
+			}
 			return nil, false, err
 		}
 
 		if found {
-			cache.Set(key, val)
+			cache.Set(cfg.key(key), val)
+		} else if cfg.writesMisses(bypass) {
+			cfg.setNegative(cache, cfg.key(key), bypass)
 		}
 
 		return val, found, nil
 	}
 }
+
+// WrapBatchWithCache wraps a batch lookup function with caching. Keys found in
+// the cache are answered directly and only the misses are forwarded to fn, in
+// a single call. Results are returned in the order of keys.
+func WrapBatchWithCache(cache CacheBackend, fn BatchLookupFunc, opts ...CacheWrapOption) BatchLookupFunc {
+	cfg := newCacheWrapConfig(opts)
+	if !cacheEnabled(cache) {
+		if cfg.keyFn == nil {
+			return fn
+		}
+		return func(ctx context.Context, keys []string) ([]Result, error) {
+			return fn(ctx, canonicalKeys(keys, cfg.keyFn))
+		}
+	}
+	return func(ctx context.Context, keys []string) ([]Result, error) {
+		results := make([]Result, len(keys))
+		var missKeys []string
+		var missIdx []int
+		bypass := IsCacheBypassed(ctx)
+		for i, key := range keys {
+			key = cfg.canonicalKey(key)
+			if !bypass {
+				if val, found := cache.Get(cfg.key(key)); found {
+					if e, ok := val.(errorEntry); ok {
+						results[i] = Result{Err: e.err}
+					} else if !isNegativeEntry(val) {
+						results[i] = Result{Value: val, Found: true}
+					}
+					continue
+				}
+			}
+			missKeys = append(missKeys, key)
+			missIdx = append(missIdx, i)
+		}
+		if len(missKeys) == 0 {
+			return results, nil
+		}
+
+		missResults, err := fn(ctx, missKeys)
+		if err != nil {
+			// The failure of the whole batch is cached for each key.
+			if local, ok := cachesErrors(ctx, cache, bypass); ok {
+				for _, key := range missKeys {
+					local.setError(cfg.key(key), err)
+				}
+			}
+			return nil, err
+		}
+		if len(missResults) != len(missKeys) {
+			return nil, fmt.Errorf("batch lookup returned %d results for %d keys", len(missResults), len(missKeys))
+		}
+
+		local, cacheErrors := cachesErrors(ctx, cache, bypass)
+		for j, res := range missResults {
+			switch {
+			case res.Err != nil:
+				if cacheErrors {
+					local.setError(cfg.key(missKeys[j]), res.Err)
+				}
+			case res.Found:
+				cache.Set(cfg.key(missKeys[j]), res.Value)
+			case cfg.writesMisses(bypass):
+				cfg.setNegative(cache, cfg.key(missKeys[j]), bypass)
+			}
+			results[missIdx[j]] = res
+		}
+		return results, nil
+	}
+}
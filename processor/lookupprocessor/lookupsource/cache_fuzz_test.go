@@ -0,0 +1,347 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fuzzCacheSize = 4
+
+// fuzzKeys are the keys operations pick from, differing only in case in
+// pairs, so that the case-insensitive cache shares their entries.
+var fuzzKeys = []string{"a", "A", "b", "B", "c", "d", "e", "f", "web-1", "WEB-1"}
+
+// cacheOp is an operation on a cache, decoded from 3 bytes of fuzz input:
+// the operation, the key and an argument, the TTL of sets in milliseconds or
+// the time a clock advance skips.
+type cacheOp struct {
+	kind byte
+	key  string
+	arg  int
+}
+
+const (
+	opGet byte = iota
+	opSet
+	opSetWithTTL
+	opDelete
+	opClear
+	opRemoveExpired
+	opInvalidate
+	opAdvance
+	opCount
+)
+
+func decodeCacheOps(data []byte) []cacheOp {
+	ops := make([]cacheOp, 0, len(data)/3)
+	for i := 0; i+2 < len(data); i += 3 {
+		ops = append(ops, cacheOp{
+			kind: data[i] % opCount,
+			key:  fuzzKeys[int(data[i+1])%len(fuzzKeys)],
+			arg:  int(data[i+2]),
+		})
+	}
+	return ops
+}
+
+// cacheOrder returns the keys of c from least to most recently used. c.mu
+// must be held.
+func cacheOrder(c *Cache) []string {
+	keys := make([]string, 0, c.order.Len())
+	for elem := c.order.Front(); elem != nil; elem = elem.Next() {
+		keys = append(keys, elem.Value.(string))
+	}
+	return keys
+}
+
+// cacheInvariantsError returns why the entries, the order of c and its
+// bound disagree, or nil.
+func cacheInvariantsError(c *Cache) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) > c.config.Size {
+		return fmt.Errorf("%d entries in a cache of %d", len(c.entries), c.config.Size)
+	}
+	order := cacheOrder(c)
+	if len(order) != len(c.entries) {
+		return fmt.Errorf("%d keys in order for %d entries", len(order), len(c.entries))
+	}
+	var bytes int64
+	for key, entry := range c.entries {
+		bytes += entrySize(key, entry.value)
+	}
+	if bytes != c.bytes {
+		return fmt.Errorf("memory usage %d for entries of %d bytes", c.bytes, bytes)
+	}
+	seen := make(map[string]struct{}, len(order))
+	for _, key := range order {
+		if _, dup := seen[key]; dup {
+			return fmt.Errorf("key %q in order twice", key)
+		}
+		seen[key] = struct{}{}
+		entry, ok := c.entries[key]
+		if !ok {
+			return fmt.Errorf("key %q in order has no entry", key)
+		}
+		if entry.elem == nil || entry.elem.Value != key {
+			return fmt.Errorf("entry of %q doesn't hold its element in order", key)
+		}
+		if key != strings.ToLower(key) {
+			return fmt.Errorf("key %q stored without normalization", key)
+		}
+	}
+	if len(c.removed) > 0 {
+		return fmt.Errorf("%d removed entries not passed to OnEvict", len(c.removed))
+	}
+	return nil
+}
+
+// modelCache is the reference behavior of a Cache touching entries on Get:
+// entries are dropped when found expired, or least recently used first when
+// the cache is full.
+type modelCache struct {
+	entries map[string]cacheEntry
+	order   []string
+	now     time.Time
+}
+
+func (m *modelCache) get(key string) (any, bool) {
+	if m.expire(key) {
+		return nil, false
+	}
+	entry, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+	m.remove(key)
+	m.entries[key] = entry
+	m.order = append(m.order, key)
+	return entry.value, true
+}
+
+// expire removes the entry of key if it expired, and returns whether it did.
+func (m *modelCache) expire(key string) bool {
+	entry, ok := m.entries[key]
+	if !ok || entry.expiresAt.IsZero() || m.now.Before(entry.expiresAt) {
+		return false
+	}
+	m.remove(key)
+	return true
+}
+
+func (m *modelCache) set(key string, value any, ttl time.Duration) {
+	entry := cacheEntry{value: value}
+	if ttl > 0 {
+		entry.expiresAt = m.now.Add(ttl)
+	}
+	if _, ok := m.entries[key]; ok {
+		m.remove(key)
+	} else if len(m.entries) >= fuzzCacheSize {
+		m.remove(m.order[0])
+	}
+	m.entries[key] = entry
+	m.order = append(m.order, key)
+}
+
+func (m *modelCache) remove(key string) {
+	delete(m.entries, key)
+	for i, k := range m.order {
+		if k == key {
+			m.order = append(m.order[:i:i], m.order[i+1:]...)
+			return
+		}
+	}
+}
+
+// evictedValue is whether InvalidateFunc removes an entry of the fuzz tests.
+func evictedValue(value any) bool {
+	return value.(int)%3 == 0
+}
+
+func newFuzzCache(now *atomic.Int64, onEvict func(string, any)) *Cache {
+	c := NewCache(CacheConfig{
+		Enabled:         true,
+		Size:            fuzzCacheSize,
+		TTL:             time.Hour,
+		CaseInsensitive: true,
+		OnEvict:         onEvict,
+	})
+	c.now = func() time.Time { return time.UnixMilli(now.Load()) }
+	return c
+}
+
+// applyCacheOp runs op on c, setting value if it sets an entry.
+func applyCacheOp(c *Cache, now *atomic.Int64, op cacheOp, value int) (any, bool) {
+	switch op.kind {
+	case opGet:
+		return c.Get(op.key)
+	case opSet:
+		c.Set(op.key, value)
+	case opSetWithTTL:
+		c.SetWithTTL(op.key, value, time.Duration(op.arg)*time.Millisecond)
+	case opDelete:
+		c.Delete(op.key)
+	case opClear:
+		c.Clear()
+	case opRemoveExpired:
+		c.RemoveExpired()
+	case opInvalidate:
+		c.InvalidateFunc(func(_ string, value any) bool { return evictedValue(value) })
+	case opAdvance:
+		now.Add(int64(op.arg))
+	}
+	return nil, false
+}
+
+func FuzzCache(f *testing.F) {
+	// Fill the cache past its size, then read the keys back.
+	f.Add([]byte{1, 0, 0, 1, 2, 0, 1, 4, 0, 1, 5, 0, 1, 6, 0, 0, 0, 0, 0, 2, 0, 0, 6, 0})
+	// Short TTLs, expired by a clock advance, then read and removed.
+	f.Add([]byte{2, 0, 10, 2, 2, 200, 7, 0, 50, 0, 1, 0, 5, 0, 0, 0, 3, 0})
+	// Keys differing in case share an entry, and are deleted and cleared.
+	f.Add([]byte{1, 8, 0, 0, 9, 0, 3, 9, 0, 0, 8, 0, 1, 0, 0, 4, 0, 0, 0, 0, 0})
+	// Entries replaced, evicted and invalidated in turn.
+	f.Add([]byte{1, 0, 0, 1, 0, 0, 1, 2, 0, 1, 4, 0, 6, 0, 0, 1, 5, 0, 1, 6, 0, 1, 7, 0})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ops := decodeCacheOps(data)
+
+		// Run sequentially, against the model. Every entry set either
+		// remains, is replaced or is passed to OnEvict exactly once.
+		var now atomic.Int64
+		evicted := map[int]int{}
+		c := newFuzzCache(&now, func(_ string, value any) { evicted[value.(int)]++ })
+		model := &modelCache{entries: map[string]cacheEntry{}}
+		replaced := map[int]bool{}
+		for i, op := range ops {
+			key := strings.ToLower(op.key)
+			model.now = time.UnixMilli(now.Load())
+			switch op.kind {
+			case opGet:
+				wantVal, wantOK := model.get(key)
+				val, ok := applyCacheOp(c, &now, op, i)
+				require.Equal(t, wantOK, ok, "op %d: get %q", i, op.key)
+				require.Equal(t, wantVal, val, "op %d: get %q", i, op.key)
+			case opSet, opSetWithTTL:
+				if entry, ok := model.entries[key]; ok {
+					replaced[entry.value.(int)] = true
+				}
+				ttl := time.Hour
+				if op.kind == opSetWithTTL {
+					ttl = time.Duration(op.arg) * time.Millisecond
+				}
+				model.set(key, i, ttl)
+				applyCacheOp(c, &now, op, i)
+			case opDelete:
+				model.remove(key)
+				applyCacheOp(c, &now, op, i)
+			case opClear:
+				model.entries = map[string]cacheEntry{}
+				model.order = nil
+				applyCacheOp(c, &now, op, i)
+			case opRemoveExpired:
+				for _, k := range append([]string(nil), model.order...) {
+					model.expire(k)
+				}
+				applyCacheOp(c, &now, op, i)
+			case opInvalidate:
+				for _, k := range append([]string(nil), model.order...) {
+					if evictedValue(model.entries[k].value) {
+						model.remove(k)
+					}
+				}
+				applyCacheOp(c, &now, op, i)
+			default:
+				applyCacheOp(c, &now, op, i)
+			}
+			require.NoError(t, cacheInvariantsError(c), "op %d", i)
+			c.mu.Lock()
+			order := cacheOrder(c)
+			c.mu.Unlock()
+			require.True(t, slices.Equal(model.order, order), "op %d: order %q, want %q", i, order, model.order)
+		}
+		for value, n := range evicted {
+			require.Equal(t, 1, n, "value %d passed to OnEvict %d times", value, n)
+			require.False(t, replaced[value], "replaced value %d passed to OnEvict", value)
+		}
+		for _, key := range model.order {
+			_, gone := evicted[model.entries[key].value.(int)]
+			require.False(t, gone, "value of %q passed to OnEvict but still cached", key)
+		}
+
+		// Run the same operations split across goroutines. The outcome
+		// depends on the schedule, but the invariants must hold, and no
+		// entry is passed to OnEvict twice.
+		var mu sync.Mutex
+		evictedConcurrently := map[int]int{}
+		c = newFuzzCache(&now, func(_ string, value any) {
+			mu.Lock()
+			evictedConcurrently[value.(int)]++
+			mu.Unlock()
+		})
+		const workers = 4
+		var wg sync.WaitGroup
+		for w := range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := w; i < len(ops); i += workers {
+					applyCacheOp(c, &now, ops[i], i)
+				}
+			}()
+		}
+		wg.Wait()
+		require.NoError(t, cacheInvariantsError(c))
+		for value, n := range evictedConcurrently {
+			require.Equal(t, 1, n, "value %d passed to OnEvict %d times", value, n)
+		}
+		for _, key := range cacheOrder(c) {
+			_, gone := evictedConcurrently[c.entries[key].value.(int)]
+			require.False(t, gone, "value of %q passed to OnEvict but still cached", key)
+		}
+	})
+}
+
+func TestCacheConcurrentOps(t *testing.T) {
+	var now atomic.Int64
+	var evictions atomic.Int64
+	c := newFuzzCache(&now, func(string, any) { evictions.Add(1) })
+
+	var wg sync.WaitGroup
+	for w := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := rand.New(rand.NewPCG(uint64(w), 0))
+			for i := range 2000 {
+				op := cacheOp{
+					kind: byte(r.IntN(int(opCount))),
+					key:  fuzzKeys[r.IntN(len(fuzzKeys))],
+					arg:  r.IntN(256),
+				}
+				applyCacheOp(c, &now, op, w*2000+i)
+				if i%100 == 0 {
+					assert.NoError(t, cacheInvariantsError(c))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.NoError(t, cacheInvariantsError(c))
+	assert.Positive(t, evictions.Load())
+	assert.Equal(t, c.Len(), c.Stats().Size)
+}
@@ -0,0 +1,1207 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+)
+
+func TestCacheGetSet(t *testing.T) {
+	cache := NewCache(CacheConfig{Enabled: true, Size: 2})
+
+	_, found := cache.Get("a")
+	assert.False(t, found)
+
+	cache.Set("a", 1)
+	val, found := cache.Get("a")
+	assert.True(t, found)
+	assert.Equal(t, 1, val)
+
+	cache.Set("a", 2)
+	val, _ = cache.Get("a")
+	assert.Equal(t, 2, val)
+	assert.Equal(t, 1, cache.Len())
+
+	cache.Clear()
+	_, found = cache.Get("a")
+	assert.False(t, found)
+	assert.Equal(t, 0, cache.Len())
+}
+
+func TestCacheKeyNormalization(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  CacheConfig
+		key  string
+	}{
+		{
+			name: "case insensitive",
+			cfg:  CacheConfig{CaseInsensitive: true},
+			key:  "WEB.Example.com",
+		},
+		{
+			name: "key normalizer",
+			cfg: CacheConfig{
+				// The normalizer takes precedence over CaseInsensitive.
+				CaseInsensitive: true,
+				KeyNormalizer:   func(key string) string { return strings.TrimSuffix(strings.ToLower(key), ".") },
+			},
+			key: "WEB.Example.com.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Enabled = true
+			cache := NewCache(tt.cfg)
+			cache.Set("web.example.com", "10.0.0.1")
+
+			val, found := cache.Get(tt.key)
+			assert.True(t, found)
+			assert.Equal(t, "10.0.0.1", val)
+
+			cache.Set(tt.key, "10.0.0.2")
+			assert.Equal(t, 1, cache.Len())
+			val, _ = cache.Get("web.example.com")
+			assert.Equal(t, "10.0.0.2", val)
+		})
+	}
+
+	cache := NewCache(CacheConfig{Enabled: true})
+	cache.Set("web.example.com", "10.0.0.1")
+	_, found := cache.Get("WEB.example.com")
+	assert.False(t, found, "keys are case sensitive by default")
+}
+
+func TestCacheEviction(t *testing.T) {
+	cache := NewCache(CacheConfig{Enabled: true, Size: 2})
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	// Re-setting a makes b the oldest entry.
+	cache.Set("a", 1)
+	cache.Set("c", 3)
+
+	_, found := cache.Get("b")
+	assert.False(t, found)
+	for _, key := range []string{"a", "c"} {
+		_, found = cache.Get(key)
+		assert.True(t, found, key)
+	}
+	assert.Equal(t, 2, cache.Len())
+	assert.Equal(t, int64(1), cache.Stats().Evictions)
+}
+
+func TestCacheMemoryUsage(t *testing.T) {
+	now := time.Now()
+	cache := NewCache(CacheConfig{Enabled: true, Size: 2, TTL: time.Minute})
+	cache.now = func() time.Time { return now }
+	assert.Zero(t, cache.MemoryUsage())
+
+	// Each entry counts its key, its value and the entry overhead.
+	cache.Set("web-1", "storefront")
+	assert.Equal(t, int64(5+10+entryOverhead), cache.MemoryUsage())
+	cache.Set("web-2", map[string]any{"team": "checkout", "port": 443})
+	assert.Equal(t, int64(5+10+entryOverhead)+int64(5+4+8+4+8+entryOverhead), cache.MemoryUsage())
+
+	// Replacing a value counts only the new one.
+	cache.Set("web-1", "db")
+	usage := cache.MemoryUsage()
+	assert.Equal(t, int64(5+2+entryOverhead)+int64(5+4+8+4+8+entryOverhead), usage)
+
+	// Evicting web-2, set least recently, for a larger entry grows the
+	// estimate by the difference.
+	cache.Set("db-1", strings.Repeat("x", 100))
+	assert.Equal(t, usage-int64(5+4+8+4+8+entryOverhead)+int64(4+100+entryOverhead), cache.MemoryUsage())
+
+	cache.Delete("db-1")
+	assert.Equal(t, int64(5+2+entryOverhead), cache.MemoryUsage())
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, 1, cache.RemoveExpired())
+	assert.Zero(t, cache.MemoryUsage())
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	assert.Equal(t, 1, cache.InvalidateFunc(func(key string, _ any) bool { return key == "a" }))
+	assert.Equal(t, int64(1+8+entryOverhead), cache.MemoryUsage())
+	cache.Clear()
+	assert.Zero(t, cache.MemoryUsage())
+}
+
+func TestCacheEvictInSetOrder(t *testing.T) {
+	tests := []struct {
+		name            string
+		evictInSetOrder bool
+		wantEvicted     []string
+	}{
+		{
+			name: "least recently used",
+			// The key read before each set is never the least recently used.
+			wantEvicted: []string{"b", "c", "d"},
+		},
+		{
+			name:            "set order",
+			evictInSetOrder: true,
+			wantEvicted:     []string{"hot", "b", "c"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var evicted []string
+			cache := NewCache(CacheConfig{
+				Enabled:         true,
+				Size:            3,
+				EvictInSetOrder: tt.evictInSetOrder,
+				OnEvict:         func(key string, _ any) { evicted = append(evicted, key) },
+			})
+			cache.Set("hot", 0)
+			cache.Set("b", 1)
+			cache.Set("c", 2)
+			for i, key := range []string{"d", "e", "f"} {
+				cache.Get("hot")
+				cache.Set(key, 3+i)
+			}
+
+			assert.Equal(t, tt.wantEvicted, evicted)
+			_, found := cache.Get("hot")
+			assert.Equal(t, !tt.evictInSetOrder, found)
+		})
+	}
+}
+
+func TestChainAndMergeCacheEvictLeastRecentlyUsed(t *testing.T) {
+	cached := CacheConfig{Enabled: true, Size: 2}
+	tests := []struct {
+		name    string
+		newFunc func(source Source) Source
+	}{
+		{
+			name:    "chain",
+			newFunc: func(source Source) Source { return NewChainWithConfig(ChainConfig{Cache: cached}, source) },
+		},
+		{
+			name:    "merge",
+			newFunc: func(source Source) Source { return NewMergeWithConfig(MergeConfig{Cache: cached}, source) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &testSource{typ: "file", entries: map[string]any{
+				"hot": map[string]any{"v": 0},
+				"b":   map[string]any{"v": 1},
+				"c":   map[string]any{"v": 2},
+			}}
+			combined := tt.newFunc(source.source())
+			for _, key := range []string{"hot", "b", "hot", "c", "hot"} {
+				_, found, err := combined.Lookup(t.Context(), key)
+				require.NoError(t, err)
+				require.True(t, found)
+			}
+			// Reading "hot" again keeps it cached when "c" evicts "b".
+			assert.Equal(t, 3, source.calls)
+		})
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	now := time.Now()
+	cache := NewCache(CacheConfig{Enabled: true, TTL: time.Minute})
+	cache.now = func() time.Time { return now }
+
+	cache.Set("a", 1)
+	_, found := cache.Get("a")
+	assert.True(t, found)
+
+	now = now.Add(time.Minute)
+	_, found = cache.Get("a")
+	assert.False(t, found)
+	assert.Equal(t, 0, cache.Len())
+}
+
+func TestCacheTTLClamp(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     CacheConfig
+		ttl     time.Duration
+		wantTTL time.Duration
+	}{
+		{name: "within bounds", cfg: CacheConfig{MinTTL: time.Second, MaxTTL: time.Hour}, ttl: time.Minute, wantTTL: time.Minute},
+		{name: "above max", cfg: CacheConfig{MaxTTL: time.Hour}, ttl: 24 * time.Hour, wantTTL: time.Hour},
+		{name: "below min", cfg: CacheConfig{MinTTL: 30 * time.Second}, ttl: time.Millisecond, wantTTL: 30 * time.Second},
+		{name: "no expiration with max", cfg: CacheConfig{MaxTTL: time.Hour}, ttl: 0, wantTTL: time.Hour},
+		{name: "no expiration with min", cfg: CacheConfig{MinTTL: time.Second}, ttl: 0, wantTTL: 0},
+		{name: "max over min", cfg: CacheConfig{MinTTL: time.Hour, MaxTTL: time.Minute}, ttl: time.Second, wantTTL: time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			tt.cfg.Enabled = true
+			cache := NewCache(tt.cfg)
+			cache.now = func() time.Time { return now }
+			cache.SetWithTTL("a", 1, tt.ttl)
+
+			if tt.wantTTL == 0 {
+				now = now.Add(100 * 365 * 24 * time.Hour)
+				_, found := cache.Get("a")
+				assert.True(t, found)
+				return
+			}
+			now = now.Add(tt.wantTTL - time.Nanosecond)
+			_, found := cache.Get("a")
+			assert.True(t, found)
+			now = now.Add(time.Nanosecond)
+			_, found = cache.Get("a")
+			assert.False(t, found)
+		})
+	}
+}
+
+func TestCacheTTLClampFixed(t *testing.T) {
+	now := time.Now()
+	cache := NewCache(CacheConfig{Enabled: true, TTL: 24 * time.Hour, MaxTTL: time.Hour})
+	cache.now = func() time.Time { return now }
+	cache.Set("a", 1)
+
+	now = now.Add(time.Hour)
+	_, found := cache.Get("a")
+	assert.False(t, found)
+}
+
+func TestCacheRemoveExpired(t *testing.T) {
+	now := time.Now()
+	cache := NewCache(CacheConfig{Enabled: true, TTL: time.Minute})
+	cache.now = func() time.Time { return now }
+
+	cache.Set("a", 1)
+	cache.SetWithTTL("b", 2, time.Hour)
+	cache.SetWithTTL("c", 3, 0)
+	assert.Zero(t, cache.RemoveExpired())
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, 1, cache.RemoveExpired())
+	assert.Equal(t, 2, cache.Len())
+	assert.Zero(t, cache.Stats().Evictions)
+}
+
+func TestCacheEntry(t *testing.T) {
+	now := time.Now()
+	cache := NewCache(CacheConfig{Enabled: true, TTL: time.Minute, CaseInsensitive: true})
+	cache.now = func() time.Time { return now }
+
+	_, ok := cache.Entry("a")
+	assert.False(t, ok)
+
+	cache.Set("a", 1)
+	cache.SetWithTTL("b", 2, 0)
+	for range 3 {
+		cache.Get("A")
+	}
+	info, ok := cache.Entry("a")
+	require.True(t, ok)
+	assert.Equal(t, CacheEntryInfo{Accesses: 3, ExpiresAt: now.Add(time.Minute)}, info)
+	// Reading the entry isn't an access, nor a hit.
+	info, _ = cache.Entry("a")
+	assert.Equal(t, int64(3), info.Accesses)
+	assert.Equal(t, int64(3), cache.Stats().Hits)
+
+	info, ok = cache.Entry("b")
+	require.True(t, ok)
+	assert.Equal(t, CacheEntryInfo{}, info)
+
+	// Setting the key again resets its accesses.
+	cache.Set("a", 1)
+	info, _ = cache.Entry("a")
+	assert.Zero(t, info.Accesses)
+
+	now = now.Add(time.Minute)
+	_, ok = cache.Entry("a")
+	assert.False(t, ok)
+}
+
+// evictRecorder records the entries passed to OnEvict.
+type evictRecorder struct {
+	mu      sync.Mutex
+	evicted []string
+}
+
+func (r *evictRecorder) onEvict(key string, value any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.evicted = append(r.evicted, fmt.Sprintf("%s=%v", key, value))
+}
+
+func (r *evictRecorder) take() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	evicted := r.evicted
+	r.evicted = nil
+	return evicted
+}
+
+func TestCacheOnEvict(t *testing.T) {
+	t.Run("eviction", func(t *testing.T) {
+		var r evictRecorder
+		cache := NewCache(CacheConfig{Enabled: true, Size: 2, OnEvict: r.onEvict})
+		cache.Set("a", 1)
+		cache.Set("b", 2)
+		cache.Set("a", 3)
+		assert.Empty(t, r.take(), "replaced entries are not evicted")
+
+		cache.Set("c", 4)
+		assert.Equal(t, []string{"b=2"}, r.take())
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		var r evictRecorder
+		now := time.Now()
+		cache := NewCache(CacheConfig{Enabled: true, TTL: time.Minute, OnEvict: r.onEvict})
+		cache.now = func() time.Time { return now }
+		cache.Set("a", 1)
+		cache.Set("b", 2)
+		cache.SetWithTTL("c", 3, time.Hour)
+
+		now = now.Add(time.Minute)
+		_, found := cache.Get("b")
+		assert.False(t, found)
+		assert.Equal(t, []string{"b=2"}, r.take())
+		assert.Equal(t, 1, cache.RemoveExpired())
+		assert.Equal(t, []string{"a=1"}, r.take())
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		var r evictRecorder
+		cache := NewCache(CacheConfig{Enabled: true, OnEvict: r.onEvict})
+		cache.Set("a", 1)
+		cache.Set("b", 2)
+		cache.Set("c", 3)
+		cache.Clear()
+		assert.Equal(t, []string{"a=1", "b=2", "c=3"}, r.take())
+	})
+
+	t.Run("invalidate and shutdown", func(t *testing.T) {
+		var r evictRecorder
+		cache := NewCache(CacheConfig{Enabled: true, CaseInsensitive: true, OnEvict: r.onEvict})
+		cache.Set("A", 1)
+		cache.Set("b", 2)
+		cache.InvalidateFunc(func(key string, _ any) bool { return key == "a" })
+		assert.Equal(t, []string{"a=1"}, r.take())
+		require.NoError(t, cache.Shutdown(t.Context()))
+		assert.Equal(t, []string{"b=2"}, r.take())
+	})
+
+	t.Run("callback uses the cache", func(t *testing.T) {
+		var cache *Cache
+		var lens []int
+		cache = NewCache(CacheConfig{Enabled: true, Size: 1, OnEvict: func(string, any) {
+			lens = append(lens, cache.Len())
+		}})
+		cache.Set("a", 1)
+		cache.Set("b", 2)
+		assert.Equal(t, []int{1}, lens)
+	})
+}
+
+func TestCacheInvalidateFunc(t *testing.T) {
+	cache := NewCache(CacheConfig{Enabled: true, Size: 4})
+	cache.Set("web-1", "storefront")
+	cache.Set("web-2", "checkout")
+	cache.Set("db-1", "checkout")
+	cache.Set("db-2", "storefront")
+
+	removed := cache.InvalidateFunc(func(key string, value any) bool {
+		return value == "checkout" || key == "db-2"
+	})
+	assert.Equal(t, 3, removed)
+	assert.Equal(t, 1, cache.Len())
+	val, found := cache.Get("web-1")
+	assert.True(t, found)
+	assert.Equal(t, "storefront", val)
+	_, found = cache.Get("web-2")
+	assert.False(t, found)
+
+	// The remaining entries keep their order, and invalidated ones don't
+	// count as evictions.
+	for _, key := range []string{"a", "b", "c", "d"} {
+		cache.Set(key, key)
+	}
+	_, found = cache.Get("web-1")
+	assert.False(t, found)
+	assert.Equal(t, int64(1), cache.Stats().Evictions)
+
+	assert.Zero(t, cache.InvalidateFunc(func(string, any) bool { return false }))
+	assert.Equal(t, 4, cache.Len())
+}
+
+func TestCacheOnChange(t *testing.T) {
+	cache := NewCache(CacheConfig{Enabled: true, Size: 2})
+	changes := 0
+	cache.OnChange(func() {
+		changes++
+		// The cache is unlocked.
+		cache.Len()
+	})
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Set("c", 3)
+	cache.Get("c")
+	cache.Delete("c")
+	assert.Zero(t, changes, "evictions and deletions are no changes of the data")
+
+	cache.InvalidateFunc(func(string, any) bool { return false })
+	assert.Equal(t, 1, changes)
+	cache.Clear()
+	assert.Equal(t, 2, changes)
+	require.NoError(t, cache.Shutdown(t.Context()))
+	assert.Equal(t, 2, changes)
+}
+
+func TestCacheShutdown(t *testing.T) {
+	cache := NewCache(CacheConfig{Enabled: true})
+	cache.Set("a", 1)
+	cache.Get("a")
+
+	// Lookups in flight while the cache shuts down.
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 100 {
+				key := fmt.Sprintf("%d-%d", i, j)
+				cache.Set(key, j)
+				cache.Get(key)
+			}
+		}()
+	}
+	require.NoError(t, cache.Shutdown(t.Context()))
+	wg.Wait()
+	goleak.VerifyNone(t)
+
+	assert.Equal(t, 0, cache.Len())
+	final := cache.Stats()
+	assert.GreaterOrEqual(t, final.Hits, int64(1))
+
+	// Neither reads nor writes reach the cache once shut down, so the
+	// statistics keep their final values.
+	cache.Set("a", 1)
+	_, found := cache.Get("a")
+	assert.False(t, found)
+	assert.Equal(t, final, cache.Stats())
+
+	// A second shutdown is a no-op.
+	require.NoError(t, cache.Shutdown(t.Context()))
+	assert.Equal(t, final, cache.Stats())
+}
+
+func TestCacheStats(t *testing.T) {
+	cache := NewCache(CacheConfig{Enabled: true})
+	assert.Equal(t, CacheStats{}, cache.Stats())
+	assert.Zero(t, cache.Stats().HitRatio())
+
+	cache.Get("a")
+	cache.Set("a", 1)
+	cache.Get("a")
+	cache.Get("a")
+	cache.Set("b", 2)
+
+	stats := cache.Stats()
+	assert.Equal(t, CacheStats{Hits: 2, Misses: 1, Size: 2}, stats)
+	assert.InDelta(t, 2.0/3, stats.HitRatio(), 1e-9)
+}
+
+func TestCacheStatsConcurrent(t *testing.T) {
+	cache := NewCache(CacheConfig{Enabled: true, Size: 1000})
+	cache.Set("a", 1)
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 100 {
+				cache.Get("a")
+				cache.Get("missing")
+				cache.Set(strconv.Itoa(i*100+j), j)
+				cache.Stats()
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := cache.Stats()
+	assert.Equal(t, int64(800), stats.Hits)
+	assert.Equal(t, int64(800), stats.Misses)
+	assert.Equal(t, 801, stats.Size)
+}
+
+func TestCacheResetStats(t *testing.T) {
+	cache := NewCache(CacheConfig{Enabled: true, Size: 2})
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Set("c", 3)
+	cache.Get("a")
+	cache.Get("b")
+	require.Equal(t, CacheStats{Hits: 1, Misses: 1, Evictions: 1, Size: 2}, cache.Stats())
+
+	cache.ResetStats()
+	assert.Equal(t, CacheStats{Size: 2}, cache.Stats())
+	assert.Zero(t, cache.Stats().HitRatio())
+
+	// The entries are kept, and counting starts over.
+	val, ok := cache.Get("b")
+	require.True(t, ok)
+	assert.Equal(t, 2, val)
+	assert.Equal(t, CacheStats{Hits: 1, Size: 2}, cache.Stats())
+}
+
+func TestSourceCacheStats(t *testing.T) {
+	lookup := func(context.Context, string) (any, bool, error) { return "v", true, nil }
+
+	_, ok := NewSource(lookup, nil, nil, nil).(CacheStatsReporter).CacheStats()
+	assert.False(t, ok, "no cache")
+
+	disabled := NewCache(CacheConfig{})
+	_, ok = NewSource(WrapWithCache(disabled, lookup), nil, nil, nil, WithCache(disabled)).(CacheStatsReporter).CacheStats()
+	assert.False(t, ok, "disabled cache")
+
+	cache := NewCache(CacheConfig{Enabled: true})
+	source := NewSource(WrapWithCache(cache, lookup), nil, nil, nil, WithCache(cache))
+	for range 3 {
+		_, _, err := source.Lookup(t.Context(), "a")
+		require.NoError(t, err)
+	}
+	stats, ok := source.(CacheStatsReporter).CacheStats()
+	require.True(t, ok)
+	assert.Equal(t, CacheStats{Hits: 2, Misses: 1, Size: 1}, stats)
+}
+
+func TestWrapWithCache(t *testing.T) {
+	calls := 0
+	fn := func(_ context.Context, key string) (any, bool, error) {
+		calls++
+		if key == "missing" {
+			return nil, false, nil
+		}
+		return "value-" + key, true, nil
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		calls = 0
+		lookup := WrapWithCache(NewCache(CacheConfig{}), fn)
+		for range 2 {
+			_, _, err := lookup(t.Context(), "a")
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		calls = 0
+		lookup := WrapWithCache(NewCache(CacheConfig{Enabled: true}), fn)
+		for range 2 {
+			val, found, err := lookup(t.Context(), "a")
+			require.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, "value-a", val)
+		}
+		assert.Equal(t, 1, calls)
+
+		// Misses are not cached.
+		for range 2 {
+			_, found, err := lookup(t.Context(), "missing")
+			require.NoError(t, err)
+			assert.False(t, found)
+		}
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		lookupErr := errors.New("boom")
+		lookup := WrapWithCache(NewCache(CacheConfig{Enabled: true}), func(context.Context, string) (any, bool, error) {
+			return nil, false, lookupErr
+		})
+		_, _, err := lookup(t.Context(), "a")
+		assert.ErrorIs(t, err, lookupErr)
+	})
+}
+
+func TestWrapWithCacheNegativeTTL(t *testing.T) {
+	// The source distinguishes keys found with a value, keys found with an
+	// empty value, and keys not found.
+	entries := map[string]any{"web": "web-1", "empty": ""}
+	tests := []struct {
+		name    string
+		backend func() (CacheBackend, func(time.Duration))
+		miss    any
+	}{
+		{
+			name: "cache",
+			backend: func() (CacheBackend, func(time.Duration)) {
+				cache := NewCache(CacheConfig{Enabled: true, TTL: time.Hour})
+				now := time.Now()
+				cache.now = func() time.Time { return now }
+				return cache, func(d time.Duration) { now = now.Add(d) }
+			},
+			miss: notFoundEntry{},
+		},
+		{
+			name: "backend",
+			backend: func() (CacheBackend, func(time.Duration)) {
+				return newMapBackend(), nil
+			},
+			miss: NotFoundMarker,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend, advance := tt.backend()
+			calls := map[string]int{}
+			lookup := WrapWithCache(backend, func(_ context.Context, key string) (any, bool, error) {
+				calls[key]++
+				val, found := entries[key]
+				return val, found, nil
+			}, WithNegativeCacheTTL(time.Minute))
+
+			for range 2 {
+				for key, want := range map[string]any{"web": "web-1", "empty": "", "missing": nil} {
+					val, found, err := lookup(t.Context(), key)
+					require.NoError(t, err)
+					assert.Equal(t, want != nil, found, key)
+					assert.Equal(t, want, val, key)
+				}
+			}
+			assert.Equal(t, map[string]int{"web": 1, "empty": 1, "missing": 1}, calls)
+
+			val, _ := backend.Get("empty")
+			assert.Equal(t, "", val)
+			val, _ = backend.Get("missing")
+			assert.Equal(t, tt.miss, val)
+
+			if advance != nil {
+				// Misses expire after the negative TTL, found keys
+				// after the TTL of the cache.
+				advance(time.Minute)
+				for _, key := range []string{"web", "empty", "missing"} {
+					_, _, err := lookup(t.Context(), key)
+					require.NoError(t, err)
+				}
+				assert.Equal(t, map[string]int{"web": 1, "empty": 1, "missing": 2}, calls)
+			}
+		})
+	}
+
+	t.Run("batch", func(t *testing.T) {
+		cache := NewCache(CacheConfig{Enabled: true})
+		var looked [][]string
+		lookup := WrapBatchWithCache(cache, func(_ context.Context, keys []string) ([]Result, error) {
+			looked = append(looked, keys)
+			results := make([]Result, len(keys))
+			for i, key := range keys {
+				results[i].Value, results[i].Found = entries[key]
+			}
+			return results, nil
+		}, WithNegativeCacheTTL(time.Minute))
+
+		want := []Result{{Value: "web-1", Found: true}, {Value: "", Found: true}, {}}
+		for range 2 {
+			results, err := lookup(t.Context(), []string{"web", "empty", "missing"})
+			require.NoError(t, err)
+			assert.Equal(t, want, results)
+		}
+		assert.Equal(t, [][]string{{"web", "empty", "missing"}}, looked)
+	})
+
+	t.Run("bypass", func(t *testing.T) {
+		cache := NewCache(CacheConfig{Enabled: true})
+		lookup := WrapWithCache(cache, func(context.Context, string) (any, bool, error) {
+			return nil, false, nil
+		}, WithNegativeCacheTTL(time.Minute))
+		cache.Set("gone", "web-9")
+
+		// A refreshed key that no longer exists is cached as a miss.
+		_, found, err := lookup(WithCacheBypass(t.Context()), "gone")
+		require.NoError(t, err)
+		assert.False(t, found)
+		val, _ := cache.Get("gone")
+		assert.Equal(t, notFoundEntry{}, val)
+	})
+}
+
+func TestWrapWithCacheErrorTTL(t *testing.T) {
+	lookupErr := errors.New("connection refused")
+	newCache := func(cfg CacheConfig) (*Cache, func(time.Duration)) {
+		cfg.Enabled = true
+		cache := NewCache(cfg)
+		now := time.Now()
+		cache.now = func() time.Time { return now }
+		return cache, func(d time.Duration) { now = now.Add(d) }
+	}
+
+	t.Run("single", func(t *testing.T) {
+		cache, advance := newCache(CacheConfig{ErrorTTL: time.Second, MinTTL: time.Minute})
+		calls := 0
+		down := true
+		lookup := WrapWithCache(cache, func(context.Context, string) (any, bool, error) {
+			calls++
+			if down {
+				return nil, false, lookupErr
+			}
+			return "web-1", true, nil
+		})
+
+		// The error is served from the cache within ErrorTTL.
+		for range 3 {
+			_, found, err := lookup(t.Context(), "10.0.0.1")
+			assert.ErrorIs(t, err, lookupErr)
+			assert.False(t, found)
+		}
+		assert.Equal(t, 1, calls)
+
+		// Once it expires, despite MinTTL, the recovered source is called.
+		down = false
+		advance(time.Second)
+		val, found, err := lookup(t.Context(), "10.0.0.1")
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "web-1", val)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("max ttl", func(t *testing.T) {
+		cache, _ := newCache(CacheConfig{ErrorTTL: time.Minute, MaxTTL: time.Second})
+		cache.setError("a", lookupErr)
+		entry, ok := cache.Entry("a")
+		require.True(t, ok)
+		assert.Equal(t, cache.now().Add(time.Second), entry.ExpiresAt)
+	})
+
+	t.Run("not cached", func(t *testing.T) {
+		calls := 0
+		fn := func(context.Context, string) (any, bool, error) {
+			calls++
+			return nil, false, lookupErr
+		}
+
+		// Without ErrorTTL, other backends, bypassed lookups and lookups
+		// whose context is done don't cache errors.
+		cache, _ := newCache(CacheConfig{})
+		_, _, _ = WrapWithCache(cache, fn)(t.Context(), "a")
+		_, _, _ = WrapWithCache(newMapBackend(), fn)(t.Context(), "a")
+
+		cache, _ = newCache(CacheConfig{ErrorTTL: time.Second})
+		cache.Set("b", "web-2")
+		lookup := WrapWithCache(cache, fn)
+		_, _, _ = lookup(WithCacheBypass(t.Context()), "b")
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		_, _, _ = lookup(ctx, "c")
+		_, _, _ = lookup(t.Context(), "c")
+		assert.Equal(t, 5, calls)
+
+		val, _ := cache.Get("b")
+		assert.Equal(t, "web-2", val)
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		cache, advance := newCache(CacheConfig{ErrorTTL: time.Second})
+		var looked [][]string
+		batchErr := errors.New("unavailable")
+		lookup := WrapBatchWithCache(cache, func(_ context.Context, keys []string) ([]Result, error) {
+			looked = append(looked, keys)
+			if keys[0] == "down" {
+				return nil, batchErr
+			}
+			results := make([]Result, len(keys))
+			for i, key := range keys {
+				if key == "broken" {
+					results[i].Err = lookupErr
+				} else {
+					results[i] = Result{Value: "v-" + key, Found: true}
+				}
+			}
+			return results, nil
+		})
+
+		want := []Result{{Value: "v-web", Found: true}, {Err: lookupErr}}
+		for range 2 {
+			results, err := lookup(t.Context(), []string{"web", "broken"})
+			require.NoError(t, err)
+			assert.Equal(t, want, results)
+		}
+		assert.Equal(t, [][]string{{"web", "broken"}}, looked)
+
+		// The failure of a whole batch is cached for each of its keys.
+		_, err := lookup(t.Context(), []string{"down"})
+		assert.ErrorIs(t, err, batchErr)
+		results, err := lookup(t.Context(), []string{"down"})
+		require.NoError(t, err)
+		assert.Equal(t, []Result{{Err: batchErr}}, results)
+		assert.Len(t, looked, 2)
+
+		advance(time.Second)
+		_, err = lookup(t.Context(), []string{"broken"})
+		require.NoError(t, err)
+		assert.Len(t, looked, 3)
+	})
+}
+
+// mapBackend is a CacheBackend keeping entries in a map, without size or
+// time limits.
+type mapBackend struct {
+	mu      sync.Mutex
+	entries map[string]any
+}
+
+func newMapBackend() *mapBackend {
+	return &mapBackend{entries: map[string]any{}}
+}
+
+func (b *mapBackend) Get(key string) (any, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	val, ok := b.entries[key]
+	return val, ok
+}
+
+func (b *mapBackend) Set(key string, value any) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.entries[key] = value
+}
+
+func (b *mapBackend) Delete(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.entries, key)
+}
+
+func (b *mapBackend) Clear() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	clear(b.entries)
+}
+
+func (b *mapBackend) Size() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.entries)
+}
+
+// writeCountingBackend is a mapBackend counting the calls writing to it.
+type writeCountingBackend struct {
+	*mapBackend
+	writes atomic.Int64
+}
+
+func (b *writeCountingBackend) Set(key string, value any) {
+	b.writes.Add(1)
+	b.mapBackend.Set(key, value)
+}
+
+func (b *writeCountingBackend) Delete(key string) {
+	b.writes.Add(1)
+	b.mapBackend.Delete(key)
+}
+
+func TestWrapWithCacheMissesWithoutNegativeTTL(t *testing.T) {
+	backend := &writeCountingBackend{mapBackend: newMapBackend()}
+	var calls int
+	fn := func(_ context.Context, key string) (any, bool, error) {
+		calls++
+		if key == "web" {
+			return "web-1", true, nil
+		}
+		return nil, false, nil
+	}
+	lookup := WrapWithCache(backend, fn, WithKeyNamespace("dns"))
+	batchLookup := WrapBatchWithCache(backend, func(ctx context.Context, keys []string) ([]Result, error) {
+		results := make([]Result, len(keys))
+		for i, key := range keys {
+			results[i].Value, results[i].Found, results[i].Err = fn(ctx, key)
+		}
+		return results, nil
+	}, WithKeyNamespace("dns"))
+
+	// Misses aren't written, and keep reaching the source.
+	for i := range 100 {
+		val, found, err := lookup(t.Context(), strconv.Itoa(i))
+		require.NoError(t, err)
+		assert.False(t, found)
+		assert.Nil(t, val)
+	}
+	results, err := batchLookup(t.Context(), []string{"a", "b"})
+	require.NoError(t, err)
+	assert.Equal(t, []Result{{}, {}}, results)
+	_, found, err := lookup(t.Context(), "0")
+	require.NoError(t, err)
+	assert.False(t, found)
+	assert.Equal(t, 103, calls)
+	assert.Zero(t, backend.writes.Load())
+	assert.Zero(t, backend.Size())
+
+	// Hits are written as before.
+	_, found, err = lookup(t.Context(), "web")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, int64(1), backend.writes.Load())
+
+	// A bypassed miss still removes the entry of its key.
+	backend.Set("dns:gone", "stale")
+	_, found, err = lookup(WithCacheBypass(t.Context()), "gone")
+	require.NoError(t, err)
+	assert.False(t, found)
+	_, ok := backend.Get("dns:gone")
+	assert.False(t, ok)
+}
+
+func TestWrapWithCacheBackend(t *testing.T) {
+	backend := newMapBackend()
+	var calls int
+	lookup := WrapWithCache(backend, func(_ context.Context, key string) (any, bool, error) {
+		calls++
+		if key == "missing" {
+			return nil, false, nil
+		}
+		return "host-" + key, true, nil
+	}, WithKeyNamespace("hosts"))
+
+	for range 2 {
+		val, found, err := lookup(t.Context(), "10.0.0.1")
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "host-10.0.0.1", val)
+	}
+	_, found, err := lookup(t.Context(), "missing")
+	require.NoError(t, err)
+	assert.False(t, found)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, map[string]any{"hosts:10.0.0.1": "host-10.0.0.1"}, backend.entries)
+
+	// Entries set in the backend by others are read as well.
+	backend.Set("hosts:10.0.0.2", "shared")
+	val, _, err := lookup(t.Context(), "10.0.0.2")
+	require.NoError(t, err)
+	assert.Equal(t, "shared", val)
+	assert.Equal(t, 2, calls)
+
+	batch := WrapBatchWithCache(backend, LoopBatch(func(_ context.Context, key string) (any, bool, error) {
+		calls++
+		return "batch-" + key, true, nil
+	}), WithKeyNamespace("hosts"))
+	results, err := batch(t.Context(), []string{"10.0.0.1", "10.0.0.3"})
+	require.NoError(t, err)
+	assert.Equal(t, []Result{{Value: "host-10.0.0.1", Found: true}, {Value: "batch-10.0.0.3", Found: true}}, results)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, 3, backend.Size())
+
+	backend.Clear()
+	_, _, err = lookup(t.Context(), "10.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, 4, calls)
+}
+
+func TestWrapWithCacheDisabled(t *testing.T) {
+	var calls int
+	fn := func(context.Context, string) (any, bool, error) {
+		calls++
+		return "web-1", true, nil
+	}
+	for name, cache := range map[string]CacheBackend{
+		"nil backend": nil,
+		"nil cache":   (*Cache)(nil),
+		"disabled":    NewCache(CacheConfig{}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			calls = 0
+			lookup := WrapWithCache(cache, fn)
+			for range 2 {
+				_, _, err := lookup(t.Context(), "10.0.0.1")
+				require.NoError(t, err)
+			}
+			assert.Equal(t, 2, calls)
+		})
+	}
+}
+
+func TestWrapWithCacheMaxValueBytes(t *testing.T) {
+	values := map[string]any{
+		"small": "web-1",
+		"large": strings.Repeat("x", 1024),
+	}
+	calls := 0
+	cache := NewCache(CacheConfig{Enabled: true, MaxValueBytes: 100})
+	lookup := WrapWithCache(cache, func(_ context.Context, key string) (any, bool, error) {
+		calls++
+		return values[key], true, nil
+	})
+
+	for range 2 {
+		val, found, err := lookup(t.Context(), "large")
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, values["large"], val)
+	}
+	assert.Equal(t, 2, calls)
+	_, found := cache.Get("large")
+	assert.False(t, found)
+
+	val, found, err := lookup(t.Context(), "small")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "web-1", val)
+	val, found = cache.Get("small")
+	assert.True(t, found)
+	assert.Equal(t, "web-1", val)
+
+	// A value growing past the limit replaces the cached one.
+	cache.Set("small", values["large"])
+	_, found = cache.Get("small")
+	assert.False(t, found)
+}
+
+func TestEstimateSize(t *testing.T) {
+	tests := []struct {
+		value any
+		want  int
+	}{
+		{value: nil, want: 0},
+		{value: "web-1", want: 5},
+		{value: []byte("web-1"), want: 5},
+		{value: int64(42), want: 8},
+		{value: true, want: 1},
+		{value: []string{"a", "bc"}, want: 3},
+		{value: []any{"a", int64(1)}, want: 9},
+		{value: map[string]any{"host": "web-1", "tags": []any{"a"}}, want: 14},
+		{value: TimedResult{Value: "web-1"}, want: 13},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%T", tt.value), func(t *testing.T) {
+			assert.Equal(t, tt.want, estimateSize(tt.value))
+		})
+	}
+}
+
+func TestWrapWithCacheBypass(t *testing.T) {
+	cache := NewCache(CacheConfig{Enabled: true})
+	calls := 0
+	value := "web-1"
+	lookup := WrapWithCache(cache, func(context.Context, string) (any, bool, error) {
+		calls++
+		return value, value != "", nil
+	})
+
+	_, _, err := lookup(t.Context(), "10.0.0.1")
+	require.NoError(t, err)
+	require.Equal(t, 1, calls)
+
+	// A bypassed lookup is made even though the key is cached, and
+	// replaces its entry.
+	value = "web-2"
+	bypassCtx := WithCacheBypass(t.Context())
+	assert.True(t, IsCacheBypassed(bypassCtx))
+	assert.False(t, IsCacheBypassed(t.Context()))
+	val, found, err := lookup(bypassCtx, "10.0.0.1")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "web-2", val)
+	assert.Equal(t, 2, calls)
+
+	val, _, err = lookup(t.Context(), "10.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, "web-2", val)
+	assert.Equal(t, 2, calls)
+
+	// A bypassed lookup that doesn't find the key removes its entry.
+	value = ""
+	_, found, err = lookup(bypassCtx, "10.0.0.1")
+	require.NoError(t, err)
+	assert.False(t, found)
+	assert.Zero(t, cache.Len())
+}
+
+func TestWrapWithCacheKeyNamespace(t *testing.T) {
+	cache := NewCache(CacheConfig{Enabled: true})
+	var keys []string
+	source := func(value string) LookupFunc {
+		return func(_ context.Context, key string) (any, bool, error) {
+			keys = append(keys, key)
+			return value, true, nil
+		}
+	}
+	dns := WrapWithCache(cache, source("web-1.example.com"), WithKeyNamespace("dns"))
+	redis := WrapWithCache(cache, source("storefront"), WithKeyNamespace("redis"))
+
+	for range 2 {
+		val, _, err := dns(t.Context(), "10.0.0.1")
+		require.NoError(t, err)
+		assert.Equal(t, "web-1.example.com", val)
+
+		val, _, err = redis(t.Context(), "10.0.0.1")
+		require.NoError(t, err)
+		assert.Equal(t, "storefront", val)
+	}
+
+	// Each function looked the key up once, without the namespace.
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.1"}, keys)
+	assert.Equal(t, 2, cache.Len())
+	_, found := cache.Get("10.0.0.1")
+	assert.False(t, found)
+	val, _ := cache.Get("redis:10.0.0.1")
+	assert.Equal(t, "storefront", val)
+
+	// The batch wrapper shares the entries of its namespace.
+	batch := WrapBatchWithCache(cache, func(context.Context, []string) ([]Result, error) {
+		return nil, errors.New("unexpected batch lookup")
+	}, WithKeyNamespace("dns"))
+	results, err := batch(t.Context(), []string{"10.0.0.1"})
+	require.NoError(t, err)
+	assert.Equal(t, []Result{{Value: "web-1.example.com", Found: true}}, results)
+}
+
+func BenchmarkWrapWithCacheMiss(b *testing.B) {
+	miss := func(context.Context, string) (any, bool, error) { return nil, false, nil }
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = "10.0." + strconv.Itoa(i/256) + "." + strconv.Itoa(i%256)
+	}
+
+	for _, bm := range []struct {
+		name string
+		opts []CacheWrapOption
+	}{
+		{name: "negative caching disabled"},
+		{name: "negative caching disabled with namespace", opts: []CacheWrapOption{WithKeyNamespace("dns")}},
+		// Every key is written, for comparison.
+		{name: "negative caching enabled", opts: []CacheWrapOption{WithNegativeCacheTTL(time.Nanosecond)}},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			lookup := WrapWithCache(NewCache(CacheConfig{Enabled: true, Size: 10000}), miss, bm.opts...)
+			ctx := b.Context()
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					_, _, _ = lookup(ctx, keys[i%len(keys)])
+					i++
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/collector/component"
+)
+
+// ChainErrorPolicy controls what a chain does when one of its sources fails.
+type ChainErrorPolicy string
+
+const (
+	// ChainContinue moves on to the next source when a source fails. If no
+	// source finds the key, the collected errors are returned.
+	ChainContinue ChainErrorPolicy = "continue"
+	// ChainFailFast returns the first source error without trying the
+	// remaining sources.
+	ChainFailFast ChainErrorPolicy = "fail_fast"
+)
+
+// ChainConfig configures a source created with [NewChainWithConfig].
+type ChainConfig struct {
+	// OnError is the error policy.
+	// Default: continue
+	OnError ChainErrorPolicy `mapstructure:"on_error"`
+
+	// Cache caches the result of the chain. It is cleared when the data of
+	// a source changes, see [ChangeNotifier].
+	Cache CacheConfig `mapstructure:"cache"`
+}
+
+func (c *ChainConfig) Validate() error {
+	switch c.OnError {
+	case "", ChainContinue, ChainFailFast:
+		return nil
+	default:
+		return fmt.Errorf("invalid on_error policy %q, must be %q or %q", c.OnError, ChainContinue, ChainFailFast)
+	}
+}
+
+// NewChain creates a Source that tries each of sources in order and returns
+// the first value found. Failing sources are skipped.
+//
+// Example:
+//
+//	source := lookupsource.NewChain(fileSource, dnsSource, httpSource)
+func NewChain(sources ...Source) Source {
+	return NewChainWithConfig(ChainConfig{}, sources...)
+}
+
+// NewChainWithConfig is like [NewChain] with a configurable error policy and
+// result cache.
+func NewChainWithConfig(cfg ChainConfig, sources ...Source) Source {
+	c := &chain{sources: sources, failFast: cfg.OnError == ChainFailFast, cache: NewCache(cfg.Cache)}
+	clearOnChange(c.cache, sources)
+
+	types := make([]string, len(sources))
+	for i, s := range sources {
+		types[i] = s.Type()
+	}
+	typ := "chain[" + strings.Join(types, ",") + "]"
+
+	return NewSource(
+		WrapWithCache(c.cache, c.lookup),
+		func() string { return typ },
+		c.start,
+		c.shutdown,
+		WithHealthCheck(c.checkHealth),
+		WithReadiness(c.ready),
+		WithCache(c.cache),
+	)
+}
+
+type chain struct {
+	sources  []Source
+	failFast bool
+	cache    *Cache
+}
+
+func (c *chain) lookup(ctx context.Context, key string) (any, bool, error) {
+	var errs []error
+	for _, s := range c.sources {
+		val, found, err := s.Lookup(ctx, key)
+		if err != nil {
+			err = fmt.Errorf("%s: %w", s.Type(), err)
+			if c.failFast {
+				return nil, false, err
+			}
+			errs = append(errs, err)
+			continue
+		}
+		if found {
+			return val, true, nil
+		}
+	}
+	return nil, false, errors.Join(errs...)
+}
+
+func (c *chain) start(ctx context.Context, host component.Host) error {
+	return startSources(ctx, host, c.sources)
+}
+
+// startSources starts sources in order. If one fails to start, the ones
+// started so far are shut down, in reverse order, so that no source is left
+// running behind a source that failed to start.
+func startSources(ctx context.Context, host component.Host, sources []Source) error {
+	for i, s := range sources {
+		if err := s.Start(ctx, host); err != nil {
+			errs := []error{fmt.Errorf("failed to start %s source: %w", s.Type(), err)}
+			for j := i - 1; j >= 0; j-- {
+				errs = append(errs, sources[j].Shutdown(ctx))
+			}
+			return errors.Join(errs...)
+		}
+	}
+	return nil
+}
+
+func (c *chain) shutdown(ctx context.Context) error {
+	var errs []error
+	for _, s := range c.sources {
+		errs = append(errs, s.Shutdown(ctx))
+	}
+	errs = append(errs, c.cache.Shutdown(ctx))
+	return errors.Join(errs...)
+}
+
+func (c *chain) checkHealth(ctx context.Context) error {
+	return CheckHealth(ctx, c.sources...)
+}
+
+func (c *chain) ready(ctx context.Context) error {
+	return WaitReady(ctx, c.sources...)
+}
@@ -0,0 +1,236 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+)
+
+type testSource struct {
+	typ     string
+	entries map[string]any
+	err     error
+	calls   int
+	// cache, if set, is the cache of the source, whose clears report a
+	// change of its data.
+	cache *Cache
+}
+
+func (s *testSource) source() Source {
+	var opts []SourceOption
+	if s.cache != nil {
+		opts = append(opts, WithCache(s.cache))
+	}
+	return NewSource(func(_ context.Context, key string) (any, bool, error) {
+		s.calls++
+		if s.err != nil {
+			return nil, false, s.err
+		}
+		val, found := s.entries[key]
+		return val, found, nil
+	}, func() string { return s.typ }, nil, nil, opts...)
+}
+
+func TestChainLookup(t *testing.T) {
+	errDown := errors.New("down")
+
+	tests := []struct {
+		name      string
+		cfg       ChainConfig
+		sources   []*testSource
+		key       string
+		wantVal   any
+		wantFound bool
+		wantErr   string
+		wantCalls []int
+	}{
+		{
+			name: "first hit",
+			sources: []*testSource{
+				{typ: "file", entries: map[string]any{"a": "from-file"}},
+				{typ: "dns", entries: map[string]any{"a": "from-dns"}},
+			},
+			key:       "a",
+			wantVal:   "from-file",
+			wantFound: true,
+			wantCalls: []int{1, 0},
+		},
+		{
+			name: "fallthrough to second",
+			sources: []*testSource{
+				{typ: "file"},
+				{typ: "dns", entries: map[string]any{"a": "from-dns"}},
+			},
+			key:       "a",
+			wantVal:   "from-dns",
+			wantFound: true,
+			wantCalls: []int{1, 1},
+		},
+		{
+			name:      "all miss",
+			sources:   []*testSource{{typ: "file"}, {typ: "dns"}},
+			key:       "a",
+			wantCalls: []int{1, 1},
+		},
+		{
+			name: "continue past error",
+			sources: []*testSource{
+				{typ: "file", err: errDown},
+				{typ: "dns", entries: map[string]any{"a": "from-dns"}},
+			},
+			key:       "a",
+			wantVal:   "from-dns",
+			wantFound: true,
+			wantCalls: []int{1, 1},
+		},
+		{
+			name: "continue reports errors when nothing found",
+			sources: []*testSource{
+				{typ: "file", err: errDown},
+				{typ: "dns"},
+			},
+			key:       "a",
+			wantErr:   "file: down",
+			wantCalls: []int{1, 1},
+		},
+		{
+			name: "fail fast",
+			cfg:  ChainConfig{OnError: ChainFailFast},
+			sources: []*testSource{
+				{typ: "file", err: errDown},
+				{typ: "dns", entries: map[string]any{"a": "from-dns"}},
+			},
+			key:       "a",
+			wantErr:   "file: down",
+			wantCalls: []int{1, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sources := make([]Source, len(tt.sources))
+			for i, s := range tt.sources {
+				sources[i] = s.source()
+			}
+			chain := NewChainWithConfig(tt.cfg, sources...)
+
+			val, found, err := chain.Lookup(t.Context(), tt.key)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantFound, found)
+			assert.Equal(t, tt.wantVal, val)
+			for i, s := range tt.sources {
+				assert.Equal(t, tt.wantCalls[i], s.calls, s.typ)
+			}
+		})
+	}
+}
+
+func TestChainType(t *testing.T) {
+	chain := NewChain((&testSource{typ: "file"}).source(), (&testSource{typ: "dns"}).source())
+	assert.Equal(t, "chain[file,dns]", chain.Type())
+}
+
+func TestChainCache(t *testing.T) {
+	first := &testSource{typ: "file", cache: NewCache(CacheConfig{})}
+	second := &testSource{typ: "dns", entries: map[string]any{"a": "from-dns"}}
+	chain := NewChainWithConfig(
+		ChainConfig{Cache: CacheConfig{Enabled: true}},
+		first.source(),
+		second.source(),
+	)
+	lookup := func() {
+		val, found, err := chain.Lookup(t.Context(), "a")
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "from-dns", val)
+	}
+
+	// A cached key doesn't go through the sources again.
+	for range 3 {
+		lookup()
+	}
+	assert.Equal(t, 1, first.calls)
+	assert.Equal(t, 1, second.calls)
+
+	// A change of the data of a source drops the cached results.
+	first.entries = map[string]any{"b": "from-file"}
+	first.cache.Clear()
+	lookup()
+	lookup()
+	assert.Equal(t, 2, first.calls)
+	assert.Equal(t, 2, second.calls)
+}
+
+func TestChainCacheNested(t *testing.T) {
+	geoip := &testSource{typ: "geoip", entries: map[string]any{"a": map[string]any{"country": "DE"}}, cache: NewCache(CacheConfig{})}
+	k8s := &testSource{typ: "k8s", entries: map[string]any{"a": map[string]any{"pod": "web-1"}}}
+	cached := CacheConfig{Enabled: true}
+	chain := NewChainWithConfig(ChainConfig{Cache: cached},
+		NewMergeWithConfig(MergeConfig{Cache: cached}, geoip.source(), k8s.source()),
+	)
+	lookup := func() any {
+		val, found, err := chain.Lookup(t.Context(), "a")
+		require.NoError(t, err)
+		require.True(t, found)
+		return val
+	}
+
+	assert.Equal(t, map[string]any{"country": "DE", "pod": "web-1"}, lookup())
+	lookup()
+	assert.Equal(t, 1, k8s.calls)
+
+	// Invalidating the cache of a merged source cascades to the merge and
+	// then to the chain.
+	geoip.entries["a"] = map[string]any{"country": "FR"}
+	geoip.cache.InvalidateFunc(func(string, any) bool { return true })
+	assert.Equal(t, map[string]any{"country": "FR", "pod": "web-1"}, lookup())
+	assert.Equal(t, 2, k8s.calls)
+}
+
+func TestChainLifecycle(t *testing.T) {
+	var started, stopped []string
+	newSource := func(typ string, startErr error) Source {
+		return NewSource(nil, func() string { return typ },
+			func(context.Context, component.Host) error {
+				started = append(started, typ)
+				return startErr
+			},
+			func(context.Context) error {
+				stopped = append(stopped, typ)
+				return nil
+			},
+		)
+	}
+
+	chain := NewChain(newSource("file", nil), newSource("dns", nil))
+	require.NoError(t, chain.Start(t.Context(), componenttest.NewNopHost()))
+	require.NoError(t, chain.Shutdown(t.Context()))
+	assert.Equal(t, []string{"file", "dns"}, started)
+	assert.Equal(t, []string{"file", "dns"}, stopped)
+
+	// The sources started before one that fails are shut down.
+	started, stopped = nil, nil
+	errStart := errors.New("no connection")
+	chain = NewChain(newSource("file", nil), newSource("env", nil), newSource("dns", errStart), newSource("static", nil))
+	assert.ErrorIs(t, chain.Start(t.Context(), componenttest.NewNopHost()), errStart)
+	assert.Equal(t, []string{"file", "env", "dns"}, started)
+	assert.Equal(t, []string{"env", "file"}, stopped)
+}
+
+func TestChainConfigValidate(t *testing.T) {
+	assert.NoError(t, (&ChainConfig{}).Validate())
+	assert.NoError(t, (&ChainConfig{OnError: ChainFailFast}).Validate())
+	assert.ErrorContains(t, (&ChainConfig{OnError: "ignore"}).Validate(), `invalid on_error policy "ignore"`)
+}
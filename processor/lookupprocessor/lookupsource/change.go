@@ -0,0 +1,67 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+
+import "sync"
+
+// ChangeNotifier is implemented by sources that report when the data they
+// look keys up in changes, so that sources combining their results, such as
+// chains and merges, drop the results they cached.
+//
+// Sources created with [NewSource] implement it, reporting the clears and
+// invalidations of the cache set with [WithCache], if any, and the changes
+// notified to the [Changes] set with [WithChanges].
+type ChangeNotifier interface {
+	// OnChange registers fn to be called whenever the data of the source
+	// changes.
+	OnChange(fn func())
+}
+
+// clearOnChange clears cache whenever the data of one of sources changes.
+// Sources that don't implement [ChangeNotifier] never report a change.
+func clearOnChange(cache *Cache, sources []Source) {
+	for _, s := range sources {
+		if n, ok := s.(ChangeNotifier); ok {
+			n.OnChange(cache.Clear)
+		}
+	}
+}
+
+// Changes reports the changes of a source without a cache, such as one
+// replacing its entries on refresh, which calls Notify after every change.
+// The zero value is ready to use.
+type Changes struct {
+	mu  sync.Mutex
+	fns []func()
+}
+
+// OnChange registers fn to be called by Notify.
+func (c *Changes) OnChange(fn func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fns = append(c.fns, fn)
+}
+
+// Notify calls the functions registered with OnChange.
+func (c *Changes) Notify() {
+	c.mu.Lock()
+	fns := c.fns
+	c.mu.Unlock()
+	for _, fn := range fns {
+		fn()
+	}
+}
+
+// WithChanges makes the source report the changes notified to c.
+func WithChanges(c *Changes) SourceOption {
+	return changesOption{changes: c}
+}
+
+type changesOption struct {
+	changes *Changes
+}
+
+func (o changesOption) apply(s *sourceImpl) {
+	s.changes = o.changes
+}
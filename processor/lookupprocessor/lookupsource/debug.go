@@ -0,0 +1,137 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+)
+
+// CacheOutcome is whether a lookup was answered by the cache of its source.
+type CacheOutcome string
+
+const (
+	// CacheHit is a lookup answered by the cache.
+	CacheHit CacheOutcome = "hit"
+	// CacheMiss is a lookup not found in the cache, answered by the
+	// backend of the source.
+	CacheMiss CacheOutcome = "miss"
+)
+
+// DebugResult is the outcome of a lookup made by [DebugLookup].
+type DebugResult struct {
+	// SourceType is the type reported by the source.
+	SourceType string `json:"source_type"`
+	Key        string `json:"key"`
+	// Value is the value of a found key, or the value returned with a miss,
+	// if any. A [TimedResult] is replaced by its value.
+	Value   any           `json:"value,omitempty"`
+	Found   bool          `json:"found"`
+	Latency time.Duration `json:"latency"`
+	// Cache is whether the lookup was answered by the cache of the source,
+	// or empty if the source reports no cache statistics.
+	Cache CacheOutcome `json:"cache,omitempty"`
+	// Reason is why the lookup failed or found nothing, for sources
+	// reporting it with [NotFoundReason] or [WithFailureReason].
+	Reason string `json:"reason,omitempty"`
+	// Err is the error the lookup failed with, if any.
+	Err        error      `json:"-"`
+	Error      string     `json:"error,omitempty"`
+	ErrorClass ErrorClass `json:"error_class,omitempty"`
+}
+
+// DebugLookup creates a source of factory with cfg, or its default config if
+// nil, starts it, looks up key once and shuts the source down. It reproduces
+// a lookup outside of a pipeline, such as from a debug command, with the
+// logs and telemetry of the source discarded. Sources that need a host
+// extension, such as an authenticator, can't be started this way.
+//
+// The error of the lookup is reported in the result; DebugLookup only
+// returns an error if the source can't be created, started or shut down.
+func DebugLookup(ctx context.Context, factory SourceFactory, cfg SourceConfig, key string) (DebugResult, error) {
+	if cfg == nil {
+		cfg = factory.CreateDefaultConfig()
+	}
+	if err := cfg.Validate(); err != nil {
+		return DebugResult{}, fmt.Errorf("invalid %s source config: %w", factory.Type(), err)
+	}
+	settings := CreateSettings{
+		TelemetrySettings: component.TelemetrySettings{
+			Logger:         zap.NewNop(),
+			TracerProvider: tracenoop.NewTracerProvider(),
+			MeterProvider:  metricnoop.NewMeterProvider(),
+		},
+	}
+	source, err := factory.CreateSource(ctx, settings, cfg)
+	if err != nil {
+		return DebugResult{}, fmt.Errorf("failed to create %s source: %w", factory.Type(), err)
+	}
+	if err := source.Start(ctx, debugHost{}); err != nil {
+		return DebugResult{}, errors.Join(fmt.Errorf("failed to start %s source: %w", factory.Type(), err), source.Shutdown(ctx))
+	}
+
+	result := debugLookup(ctx, source, key)
+	if err := source.Shutdown(ctx); err != nil {
+		return result, fmt.Errorf("failed to shut down %s source: %w", factory.Type(), err)
+	}
+	return result, nil
+}
+
+// debugLookup looks up key in source and describes the outcome.
+func debugLookup(ctx context.Context, source Source, key string) DebugResult {
+	reporter, _ := source.(CacheStatsReporter)
+	var before CacheStats
+	var hasCache bool
+	if reporter != nil {
+		before, hasCache = reporter.CacheStats()
+	}
+
+	start := time.Now()
+	val, found, err := source.Lookup(ctx, key)
+	result := DebugResult{
+		SourceType: source.Type(),
+		Key:        key,
+		Value:      val,
+		Found:      found,
+		Latency:    time.Since(start),
+		Err:        err,
+		ErrorClass: ClassifyError(err),
+	}
+	if timed, ok := val.(TimedResult); ok {
+		result.Value = timed.Value
+	}
+	if reason, ok := val.(NotFoundReason); ok && !found {
+		result.Value = nil
+		result.Reason = string(reason)
+	}
+	if err != nil {
+		result.Error = err.Error()
+		result.Reason, _ = FailureReason(err)
+	}
+	if hasCache {
+		after, _ := reporter.CacheStats()
+		switch {
+		case after.Hits > before.Hits:
+			result.Cache = CacheHit
+		case after.Misses > before.Misses:
+			result.Cache = CacheMiss
+		}
+	}
+	return result
+}
+
+// debugHost is the host of the sources started by DebugLookup, which has no
+// extensions.
+type debugHost struct{}
+
+func (debugHost) GetExtensions() map[component.ID]component.Component {
+	return nil
+}
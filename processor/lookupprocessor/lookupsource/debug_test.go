@@ -0,0 +1,100 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type debugConfig struct {
+	err error
+}
+
+func (c *debugConfig) Validate() error { return c.err }
+
+// newDebugFactory returns a factory of sources with a cache holding "cached",
+// answering other keys with lookup.
+func newDebugFactory(lookup LookupFunc) SourceFactory {
+	return NewSourceFactory(
+		"debug",
+		func() SourceConfig { return &debugConfig{} },
+		func(context.Context, CreateSettings, SourceConfig) (Source, error) {
+			cache := NewCache(CacheConfig{Enabled: true, Size: 10})
+			cache.Set("cached", "from-cache")
+			return NewSource(WrapWithCache(cache, lookup), func() string { return "debug" }, nil, cache.Shutdown, WithCache(cache)), nil
+		},
+	)
+}
+
+func TestDebugLookup(t *testing.T) {
+	errTimeout := WithFailureReason(Transient(errors.New("i/o timeout")), "timeout")
+	factory := newDebugFactory(func(_ context.Context, key string) (any, bool, error) {
+		switch key {
+		case "found":
+			return TimedResult{Value: "value"}, true, nil
+		case "slow":
+			return nil, false, errTimeout
+		case "bad":
+			return nil, false, Permanent(errors.New("malformed key"))
+		case "broken":
+			return nil, false, errors.New("unexpected")
+		}
+		return NotFoundReason("NXDOMAIN"), false, nil
+	})
+
+	tests := []struct {
+		key  string
+		want DebugResult
+	}{
+		{
+			key:  "cached",
+			want: DebugResult{SourceType: "debug", Key: "cached", Value: "from-cache", Found: true, Cache: CacheHit},
+		},
+		{
+			key:  "found",
+			want: DebugResult{SourceType: "debug", Key: "found", Value: "value", Found: true, Cache: CacheMiss},
+		},
+		{
+			key:  "missing",
+			want: DebugResult{SourceType: "debug", Key: "missing", Cache: CacheMiss, Reason: "NXDOMAIN"},
+		},
+		{
+			key: "slow",
+			want: DebugResult{
+				SourceType: "debug", Key: "slow", Cache: CacheMiss, Reason: "timeout",
+				Err: errTimeout, Error: "i/o timeout", ErrorClass: ErrorClassTransient,
+			},
+		},
+		{
+			key:  "bad",
+			want: DebugResult{SourceType: "debug", Key: "bad", Cache: CacheMiss, Error: "malformed key", ErrorClass: ErrorClassPermanent},
+		},
+		{
+			key:  "broken",
+			want: DebugResult{SourceType: "debug", Key: "broken", Cache: CacheMiss, Error: "unexpected", ErrorClass: ErrorClassUnclassified},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, err := DebugLookup(t.Context(), factory, nil, tt.key)
+			require.NoError(t, err)
+			got.Latency = 0
+			if tt.want.Err == nil {
+				got.Err = nil
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDebugLookupInvalidConfig(t *testing.T) {
+	factory := newDebugFactory(nil)
+	_, err := DebugLookup(t.Context(), factory, &debugConfig{err: errors.New("path must be specified")}, "key")
+	assert.EqualError(t, err, "invalid debug source config: path must be specified")
+}
@@ -0,0 +1,129 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+
+import "errors"
+
+var (
+	// ErrTransient classifies lookup errors that may not happen again, such
+	// as timeouts or an overloaded server. Mark errors with [Transient].
+	ErrTransient = errors.New("transient lookup error")
+
+	// ErrPermanent classifies lookup errors that happen again for the same
+	// key, such as a malformed request. Mark errors with [Permanent].
+	ErrPermanent = errors.New("permanent lookup error")
+)
+
+// Transient marks err as transient: errors.Is(err, ErrTransient) reports
+// true for the result, which otherwise behaves like err. It returns nil if
+// err is nil.
+func Transient(err error) error {
+	return classify(err, ErrTransient)
+}
+
+// Permanent marks err as permanent: errors.Is(err, ErrPermanent) reports
+// true for the result, which otherwise behaves like err. It returns nil if
+// err is nil.
+func Permanent(err error) error {
+	return classify(err, ErrPermanent)
+}
+
+// IsTransient reports whether err was marked with [Transient].
+func IsTransient(err error) bool {
+	return errors.Is(err, ErrTransient)
+}
+
+// IsPermanent reports whether err was marked with [Permanent].
+func IsPermanent(err error) bool {
+	return errors.Is(err, ErrPermanent)
+}
+
+// ErrorClass is the class of a lookup error, see [Transient] and
+// [Permanent].
+type ErrorClass string
+
+const (
+	ErrorClassTransient ErrorClass = "transient"
+	ErrorClassPermanent ErrorClass = "permanent"
+	// ErrorClassUnclassified is the class of errors marked neither
+	// transient nor permanent.
+	ErrorClassUnclassified ErrorClass = "unclassified"
+)
+
+// ClassifyError returns the class of err, or an empty class if err is nil.
+func ClassifyError(err error) ErrorClass {
+	switch {
+	case err == nil:
+		return ""
+	case IsTransient(err):
+		return ErrorClassTransient
+	case IsPermanent(err):
+		return ErrorClassPermanent
+	default:
+		return ErrorClassUnclassified
+	}
+}
+
+func classify(err, class error) error {
+	if err == nil {
+		return nil
+	}
+	return &classifiedError{err: err, class: class}
+}
+
+// classifiedError keeps the message of err while matching both err and its
+// class with errors.Is and errors.As.
+type classifiedError struct {
+	err   error
+	class error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() []error {
+	return []error{e.err, e.class}
+}
+
+// NotFoundReason is the value of a lookup that didn't find its key, returned
+// with found set to false by sources configured to report why, such as
+// "NXDOMAIN". The lookup processor writes it to the <target>.lookup_error
+// attribute. Other callers can ignore it like any value of a miss.
+type NotFoundReason string
+
+// WithFailureReason marks err with reason, a short description of why the
+// lookup failed, such as "SERVFAIL" or "timeout", which the lookup processor
+// writes to the <target>.lookup_error attribute of sources configured to
+// report it. The result otherwise behaves like err. It returns nil if err is
+// nil.
+func WithFailureReason(err error, reason string) error {
+	if err == nil {
+		return nil
+	}
+	return &reasonError{err: err, reason: reason}
+}
+
+// FailureReason returns the reason err was marked with by
+// [WithFailureReason], or false if it wasn't.
+func FailureReason(err error) (string, bool) {
+	var reasonErr *reasonError
+	if errors.As(err, &reasonErr) {
+		return reasonErr.reason, true
+	}
+	return "", false
+}
+
+type reasonError struct {
+	err    error
+	reason string
+}
+
+func (e *reasonError) Error() string {
+	return e.err.Error()
+}
+
+func (e *reasonError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,51 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorClassification(t *testing.T) {
+	errUpstream := errors.New("server misbehaving")
+
+	transient := fmt.Errorf("lookup failed: %w", Transient(errUpstream))
+	assert.True(t, IsTransient(transient))
+	assert.False(t, IsPermanent(transient))
+	assert.ErrorIs(t, transient, errUpstream)
+	assert.EqualError(t, transient, "lookup failed: server misbehaving")
+
+	permanent := Permanent(&net.DNSError{Err: "invalid name", Name: "bad..name"})
+	assert.True(t, IsPermanent(permanent))
+	assert.False(t, IsTransient(permanent))
+	var dnsErr *net.DNSError
+	assert.ErrorAs(t, permanent, &dnsErr)
+
+	assert.False(t, IsTransient(errUpstream))
+	assert.False(t, IsPermanent(errUpstream))
+	assert.NoError(t, Transient(nil))
+	assert.NoError(t, Permanent(nil))
+}
+
+func TestFailureReason(t *testing.T) {
+	assert.NoError(t, WithFailureReason(nil, "timeout"))
+
+	_, ok := FailureReason(errors.New("boom"))
+	assert.False(t, ok)
+
+	cause := errors.New("i/o timeout")
+	err := fmt.Errorf("dns PTR lookup failed: %w", Transient(WithFailureReason(cause, "timeout")))
+	reason, ok := FailureReason(err)
+	require.True(t, ok)
+	assert.Equal(t, "timeout", reason)
+	assert.Equal(t, "dns PTR lookup failed: i/o timeout", err.Error())
+	assert.ErrorIs(t, err, cause)
+	assert.True(t, IsTransient(err))
+}
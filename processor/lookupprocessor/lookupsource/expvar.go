@@ -0,0 +1,90 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+
+import (
+	"expvar"
+	"sync"
+)
+
+// ExpvarName is the name of the expvar variable holding the statistics of
+// the caches published with [PublishExpvar].
+const ExpvarName = "lookup_cache"
+
+// expvarStats is the JSON form of the statistics of a source type.
+type expvarStats struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Size      int   `json:"size"`
+	Evictions int64 `json:"evictions"`
+}
+
+var (
+	expvarOnce sync.Once
+	expvarMu   sync.Mutex
+	expvarID   int
+	// expvarReporters are the published reporters, by source type and
+	// publication.
+	expvarReporters = make(map[string]map[int]CacheStatsReporter)
+)
+
+// PublishExpvar adds the cache statistics of r to the [ExpvarName] expvar
+// variable, served on /debug/vars by the expvar package. Statistics are
+// keyed by source type: those of reporters published with the same type are
+// summed. Reporters without an enabled cache are skipped when the variable
+// is read.
+//
+// It returns a function removing r from the variable.
+func PublishExpvar(sourceType string, r CacheStatsReporter) (unpublish func()) {
+	expvarOnce.Do(func() {
+		expvar.Publish(ExpvarName, expvar.Func(expvarSnapshot))
+	})
+
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+	expvarID++
+	id := expvarID
+	if expvarReporters[sourceType] == nil {
+		expvarReporters[sourceType] = make(map[int]CacheStatsReporter)
+	}
+	expvarReporters[sourceType][id] = r
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			expvarMu.Lock()
+			defer expvarMu.Unlock()
+			delete(expvarReporters[sourceType], id)
+			if len(expvarReporters[sourceType]) == 0 {
+				delete(expvarReporters, sourceType)
+			}
+		})
+	}
+}
+
+func expvarSnapshot() any {
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+
+	snapshot := make(map[string]expvarStats, len(expvarReporters))
+	for sourceType, reporters := range expvarReporters {
+		var sum expvarStats
+		var ok bool
+		for _, r := range reporters {
+			stats, enabled := r.CacheStats()
+			if !enabled {
+				continue
+			}
+			ok = true
+			sum.Hits += stats.Hits
+			sum.Misses += stats.Misses
+			sum.Size += stats.Size
+			sum.Evictions += stats.Evictions
+		}
+		if ok {
+			snapshot[sourceType] = sum
+		}
+	}
+	return snapshot
+}
@@ -0,0 +1,58 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readExpvar decodes the published cache statistics.
+func readExpvar(t *testing.T) map[string]expvarStats {
+	t.Helper()
+	v := expvar.Get(ExpvarName)
+	require.NotNil(t, v)
+	var got map[string]expvarStats
+	require.NoError(t, json.Unmarshal([]byte(v.String()), &got))
+	return got
+}
+
+func TestPublishExpvar(t *testing.T) {
+	newCachedSource := func(size int) (Source, *Cache) {
+		cache := NewCache(CacheConfig{Enabled: true, Size: size})
+		lookup := func(context.Context, string) (any, bool, error) { return "v", true, nil }
+		return NewSource(WrapWithCache(cache, lookup), nil, nil, nil, WithCache(cache)), cache
+	}
+
+	first, firstCache := newCachedSource(1)
+	second, secondCache := newCachedSource(10)
+	uncached := NewSource(nil, nil, nil, nil)
+
+	unpublishFirst := PublishExpvar("test", first.(CacheStatsReporter))
+	unpublishSecond := PublishExpvar("test", second.(CacheStatsReporter))
+	unpublishUncached := PublishExpvar("uncached", uncached.(CacheStatsReporter))
+	defer unpublishSecond()
+	defer unpublishUncached()
+
+	firstCache.Get("a")
+	firstCache.Set("a", 1)
+	firstCache.Get("a")
+	firstCache.Set("b", 2) // evicts a
+	secondCache.Get("a")
+	secondCache.Set("a", 1)
+
+	got := readExpvar(t)
+	assert.Equal(t, expvarStats{Hits: 1, Misses: 2, Size: 2, Evictions: 1}, got["test"])
+	assert.NotContains(t, got, "uncached")
+
+	unpublishFirst()
+	unpublishFirst()
+	got = readExpvar(t)
+	assert.Equal(t, expvarStats{Misses: 1, Size: 1}, got["test"])
+}
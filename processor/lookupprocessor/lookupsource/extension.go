@@ -0,0 +1,19 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+
+import "go.opentelemetry.io/collector/component"
+
+// LookupExtension is implemented by extensions owning lookup sources, so that
+// several lookup processors can share a source, and its cache, by referring
+// to the extension ID instead of configuring the source themselves.
+//
+// The extension starts and shuts down its sources; users of a source must
+// not.
+type LookupExtension interface {
+	component.Component
+
+	// Source returns the source configured under name, if any.
+	Source(name string) (Source, bool)
+}
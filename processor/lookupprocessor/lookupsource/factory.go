@@ -5,8 +5,11 @@ package lookupsource // import "github.com/open-telemetry/opentelemetry-collecto
 
 import (
 	"context"
+	"time"
 
 	"go.opentelemetry.io/collector/component"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 type SourceConfig interface {
@@ -18,6 +21,19 @@ type CreateSettings struct {
 	BuildInfo         component.BuildInfo
 }
 
+// LookupLogger returns the logger of the settings for entries written on
+// every lookup. Entries are sampled: after the first 10 entries with the same
+// level and message in a second, only every 100th is written. It returns a
+// no-op logger if the settings have none.
+func (s CreateSettings) LookupLogger() *zap.Logger {
+	if s.TelemetrySettings.Logger == nil {
+		return zap.NewNop()
+	}
+	return s.TelemetrySettings.Logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return zapcore.NewSamplerWithOptions(core, time.Second, 10, 100)
+	}))
+}
+
 type CreateSourceFunc func(ctx context.Context, settings CreateSettings, cfg SourceConfig) (Source, error)
 
 type CreateDefaultConfigFunc func() SourceConfig
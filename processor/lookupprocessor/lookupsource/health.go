@@ -0,0 +1,52 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// HealthChecker is implemented by sources that can verify their backend is
+// reachable (e.g., pinging Redis or running "SELECT 1").
+//
+// Sources created with [NewSource] implement it, reporting healthy unless a
+// check is configured with [WithHealthCheck].
+type HealthChecker interface {
+	CheckHealth(ctx context.Context) error
+}
+
+// HealthCheckFunc returns an error if the source backend is unhealthy.
+type HealthCheckFunc func(ctx context.Context) error
+
+// WithHealthCheck sets the function used by the source's CheckHealth method.
+func WithHealthCheck(fn HealthCheckFunc) SourceOption {
+	return healthCheckOption{fn: fn}
+}
+
+type healthCheckOption struct {
+	fn HealthCheckFunc
+}
+
+func (o healthCheckOption) apply(s *sourceImpl) {
+	s.healthFn = o.fn
+}
+
+// CheckHealth checks the health of all sources and returns the combined
+// errors of the unhealthy ones, each prefixed with the source type. Sources
+// that don't implement [HealthChecker] are assumed healthy.
+func CheckHealth(ctx context.Context, sources ...Source) error {
+	var errs []error
+	for _, s := range sources {
+		hc, ok := s.(HealthChecker)
+		if !ok {
+			continue
+		}
+		if err := hc.CheckHealth(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.Type(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
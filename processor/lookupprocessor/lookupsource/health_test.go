@@ -0,0 +1,70 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/component"
+)
+
+// plainSource implements Source but not HealthChecker.
+type plainSource struct{}
+
+func (plainSource) Lookup(context.Context, string) (any, bool, error) { return nil, false, nil }
+func (plainSource) Type() string                                      { return "plain" }
+func (plainSource) Start(context.Context, component.Host) error       { return nil }
+func (plainSource) Shutdown(context.Context) error                    { return nil }
+
+func newHealthSource(typ string, err error) Source {
+	return NewSource(nil, func() string { return typ }, nil, nil,
+		WithHealthCheck(func(context.Context) error { return err }),
+	)
+}
+
+func TestSourceCheckHealth(t *testing.T) {
+	errDown := errors.New("connection refused")
+
+	healthy := newHealthSource("redis", nil)
+	assert.NoError(t, healthy.(HealthChecker).CheckHealth(t.Context()))
+
+	unhealthy := newHealthSource("redis", errDown)
+	assert.ErrorIs(t, unhealthy.(HealthChecker).CheckHealth(t.Context()), errDown)
+
+	// Sources without a configured check are healthy.
+	unchecked := NewSource(nil, func() string { return "noop" }, nil, nil)
+	assert.NoError(t, unchecked.(HealthChecker).CheckHealth(t.Context()))
+
+	// Batch sources keep the health check.
+	batch := NewSource(nil, func() string { return "batch" }, nil, nil,
+		WithBatchLookup(func(context.Context, []string) ([]Result, error) { return nil, nil }),
+		WithHealthCheck(func(context.Context) error { return errDown }),
+	)
+	assert.ErrorIs(t, batch.(HealthChecker).CheckHealth(t.Context()), errDown)
+}
+
+func TestCheckHealthAggregation(t *testing.T) {
+	errRedis := errors.New("connection refused")
+	errSQL := errors.New("too many connections")
+
+	assert.NoError(t, CheckHealth(t.Context()))
+	assert.NoError(t, CheckHealth(t.Context(), plainSource{}, newHealthSource("redis", nil)))
+
+	err := CheckHealth(t.Context(),
+		newHealthSource("redis", errRedis),
+		plainSource{},
+		newHealthSource("static", nil),
+		newHealthSource("sql", errSQL),
+	)
+	assert.ErrorIs(t, err, errRedis)
+	assert.ErrorIs(t, err, errSQL)
+	assert.EqualError(t, err, "redis: connection refused\nsql: too many connections")
+
+	chain := NewChain(newHealthSource("static", nil), newHealthSource("sql", errSQL))
+	err = CheckHealth(t.Context(), chain)
+	assert.EqualError(t, err, "chain[static,sql]: sql: too many connections")
+}
@@ -0,0 +1,73 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+
+// KeyFuncProvider is implemented by sources that map the keys they are given
+// to canonical keys before looking them up, such as by stripping a port from
+// "host:port".
+//
+// Sources created with [NewSource] implement it, returning the function set
+// with [WithKeyFunc] or the identity.
+type KeyFuncProvider interface {
+	KeyFunc() func(string) string
+}
+
+// KeyFunc returns the function mapping keys of source to canonical keys, or
+// the identity if source doesn't implement [KeyFuncProvider].
+func KeyFunc(source Source) func(string) string {
+	if p, ok := source.(KeyFuncProvider); ok {
+		if fn := p.KeyFunc(); fn != nil {
+			return fn
+		}
+	}
+	return identityKey
+}
+
+func identityKey(key string) string {
+	return key
+}
+
+// canonicalKeys returns keys mapped by fn, in a new slice.
+func canonicalKeys(keys []string, fn func(string) string) []string {
+	canonical := make([]string, len(keys))
+	for i, key := range keys {
+		canonical[i] = fn(key)
+	}
+	return canonical
+}
+
+// KeyFuncOption is both a [SourceOption] and a [CacheWrapOption], so that a
+// source and its cache share one canonical key.
+type KeyFuncOption interface {
+	SourceOption
+	CacheWrapOption
+}
+
+// WithKeyFunc maps keys to canonical keys with fn. Passed to [NewSource], the
+// source looks up and reports fn with its KeyFunc method; passed to
+// [WrapWithCache] or [WrapBatchWithCache], entries are stored under the
+// canonical key and the wrapped function receives it. Keys mapping to the
+// same canonical key then share one cache entry:
+//
+//	keyFunc := lookupsource.WithKeyFunc(stripPort)
+//	lookup := lookupsource.WrapWithCache(cache, myLookupFunc, keyFunc)
+//	source := lookupsource.NewSource(lookup, typeFunc, nil, nil, keyFunc)
+//
+// Since the key may be mapped by both, fn must return canonical keys
+// unchanged.
+func WithKeyFunc(fn func(string) string) KeyFuncOption {
+	return keyFuncOption{fn: fn}
+}
+
+type keyFuncOption struct {
+	fn func(string) string
+}
+
+func (o keyFuncOption) apply(s *sourceImpl) {
+	s.keyFn = o.fn
+}
+
+func (o keyFuncOption) applyCacheWrap(cfg *cacheWrapConfig) {
+	cfg.keyFn = o.fn
+}
@@ -0,0 +1,88 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stripPort returns the host of a "host:port" key, and other keys unchanged.
+func stripPort(key string) string {
+	if host, _, err := net.SplitHostPort(key); err == nil {
+		return host
+	}
+	return key
+}
+
+func TestWithKeyFunc(t *testing.T) {
+	var keys []string
+	cache := NewCache(CacheConfig{Enabled: true})
+	keyFunc := WithKeyFunc(stripPort)
+	lookup := WrapWithCache(cache, func(_ context.Context, key string) (any, bool, error) {
+		keys = append(keys, key)
+		return "web-1", true, nil
+	}, keyFunc)
+	source := NewSource(lookup, func() string { return "test" }, nil, nil, keyFunc, WithCache(cache))
+
+	for _, key := range []string{"10.0.0.1:1234", "10.0.0.1:5678", "10.0.0.1"} {
+		val, found, err := source.Lookup(t.Context(), key)
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "web-1", val)
+	}
+	assert.Equal(t, []string{"10.0.0.1"}, keys)
+	assert.Equal(t, 1, cache.Len())
+	assert.Equal(t, "10.0.0.1", KeyFunc(source)("10.0.0.1:1234"))
+}
+
+func TestWithKeyFuncBatch(t *testing.T) {
+	var keys [][]string
+	cache := NewCache(CacheConfig{Enabled: true})
+	keyFunc := WithKeyFunc(stripPort)
+	lookup := WrapBatchWithCache(cache, func(_ context.Context, batch []string) ([]Result, error) {
+		keys = append(keys, batch)
+		results := make([]Result, len(batch))
+		for i, key := range batch {
+			results[i] = Result{Value: "host " + key, Found: true}
+		}
+		return results, nil
+	}, keyFunc)
+	source := AsBatchSource(NewSource(nil, nil, nil, nil, keyFunc, WithBatchLookup(lookup)))
+
+	results, err := source.LookupBatch(t.Context(), []string{"10.0.0.1:1234", "10.0.0.2:80"})
+	require.NoError(t, err)
+	assert.Equal(t, []Result{{Value: "host 10.0.0.1", Found: true}, {Value: "host 10.0.0.2", Found: true}}, results)
+
+	results, err = source.LookupBatch(t.Context(), []string{"10.0.0.1:5678", "10.0.0.3:443"})
+	require.NoError(t, err)
+	assert.Equal(t, []Result{{Value: "host 10.0.0.1", Found: true}, {Value: "host 10.0.0.3", Found: true}}, results)
+
+	assert.Equal(t, [][]string{{"10.0.0.1", "10.0.0.2"}, {"10.0.0.3"}}, keys)
+	assert.Equal(t, 3, cache.Len())
+}
+
+func TestWithKeyFuncCacheDisabled(t *testing.T) {
+	var keys []string
+	lookup := WrapWithCache(NewCache(CacheConfig{}), func(_ context.Context, key string) (any, bool, error) {
+		keys = append(keys, key)
+		return nil, false, nil
+	}, WithKeyFunc(stripPort))
+
+	_, _, err := lookup(t.Context(), "10.0.0.1:1234")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1"}, keys)
+}
+
+func TestKeyFuncIdentity(t *testing.T) {
+	source := NewSource(nil, nil, nil, nil)
+	assert.Equal(t, "10.0.0.1:1234", KeyFunc(source)("10.0.0.1:1234"))
+
+	// Sources not created with NewSource don't implement KeyFuncProvider.
+	assert.Equal(t, "10.0.0.1:1234", KeyFunc(loopBatchSource{Source: source})("10.0.0.1:1234"))
+}
@@ -0,0 +1,35 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+
+import (
+	"context"
+	"maps"
+)
+
+// WithKeyMapping wraps a lookup function so that keys are translated through
+// mapping before fn is called, for telemetry whose keys differ from those of
+// the source by a known table, such as short codes of canonical IDs. Keys
+// missing from mapping are passed to fn unchanged if passthrough is set, and
+// are not found without calling fn otherwise. mapping is copied, so later
+// changes to it have no effect.
+//
+// Apply it outside [WrapWithCache] so that the cache is keyed on the
+// translated key, and keys mapped to the same one share a cache entry:
+//
+//	cachedLookup := lookupsource.WrapWithCache(cache, myLookupFunc)
+//	lookup := lookupsource.WithKeyMapping(cachedLookup, mapping, true)
+func WithKeyMapping(fn LookupFunc, mapping map[string]string, passthrough bool) LookupFunc {
+	mapping = maps.Clone(mapping)
+	return func(ctx context.Context, key string) (any, bool, error) {
+		mapped, ok := mapping[key]
+		if !ok {
+			if !passthrough {
+				return nil, false, nil
+			}
+			mapped = key
+		}
+		return fn(ctx, mapped)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithKeyMapping(t *testing.T) {
+	values := map[string]string{
+		"host-0001": "web-1",
+		"fra":       "Frankfurt",
+	}
+	var called []string
+	fn := func(_ context.Context, key string) (any, bool, error) {
+		called = append(called, key)
+		val, ok := values[key]
+		if !ok {
+			return nil, false, nil
+		}
+		return val, true, nil
+	}
+	mapping := map[string]string{"h1": "host-0001", "h9": "host-0009"}
+
+	tests := []struct {
+		name        string
+		passthrough bool
+		key         string
+		wantValue   any
+		wantFound   bool
+		wantCalled  []string
+	}{
+		{
+			name:       "mapped",
+			key:        "h1",
+			wantValue:  "web-1",
+			wantFound:  true,
+			wantCalled: []string{"host-0001"},
+		},
+		{
+			name:       "mapped not found",
+			key:        "h9",
+			wantCalled: []string{"host-0009"},
+		},
+		{
+			name:        "unmapped passthrough",
+			passthrough: true,
+			key:         "fra",
+			wantValue:   "Frankfurt",
+			wantFound:   true,
+			wantCalled:  []string{"fra"},
+		},
+		{
+			name: "unmapped drop",
+			key:  "fra",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called = nil
+			val, found, err := WithKeyMapping(fn, mapping, tt.passthrough)(t.Context(), tt.key)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantFound, found)
+			assert.Equal(t, tt.wantValue, val)
+			assert.Equal(t, tt.wantCalled, called)
+		})
+	}
+}
+
+func TestWithKeyMappingCached(t *testing.T) {
+	cache := NewCache(CacheConfig{Enabled: true})
+	calls := 0
+	mapping := map[string]string{"h1": "host-0001", "web1": "host-0001"}
+	lookup := WithKeyMapping(WrapWithCache(cache, func(context.Context, string) (any, bool, error) {
+		calls++
+		return "web-1", true, nil
+	}), mapping, false)
+
+	// Changes to the mapping after wrapping are ignored.
+	mapping["h2"] = "host-0002"
+
+	for _, key := range []string{"h1", "web1", "h1"} {
+		val, found, err := lookup(t.Context(), key)
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "web-1", val)
+	}
+	// Keys mapped to the same key share its cache entry.
+	assert.Equal(t, 1, calls)
+	_, ok := cache.Get("host-0001")
+	assert.True(t, ok)
+	_, ok = cache.Get("h1")
+	assert.False(t, ok)
+
+	_, found, err := lookup(t.Context(), "h2")
+	require.NoError(t, err)
+	assert.False(t, found)
+}
@@ -0,0 +1,201 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/collector/component"
+)
+
+// MergeConflictPolicy controls how a merge combines entries of the same name
+// found by several sources.
+type MergeConflictPolicy string
+
+const (
+	// MergeLastWins keeps the entry of the source listed last when several
+	// sources return it.
+	MergeLastWins MergeConflictPolicy = "last_wins"
+	// MergePrefix names every entry after the type of its source, such as
+	// geoip.country, so that entries of different sources never conflict.
+	MergePrefix MergeConflictPolicy = "prefix"
+)
+
+// MergeConfig configures a source created with [NewMergeWithConfig].
+type MergeConfig struct {
+	// OnConflict is the conflict policy.
+	// Default: last_wins
+	OnConflict MergeConflictPolicy `mapstructure:"on_conflict"`
+
+	// MaxConcurrency is the number of sources looked up at once. Zero looks
+	// all of them up at once.
+	// Default: 0
+	MaxConcurrency int `mapstructure:"max_concurrency"`
+
+	// Cache caches the merged result. It is cleared when the data of a
+	// source changes, see [ChangeNotifier].
+	Cache CacheConfig `mapstructure:"cache"`
+}
+
+func (c *MergeConfig) Validate() error {
+	switch c.OnConflict {
+	case "", MergeLastWins, MergePrefix:
+	default:
+		return fmt.Errorf("invalid on_conflict policy %q, must be %q or %q", c.OnConflict, MergeLastWins, MergePrefix)
+	}
+	if c.MaxConcurrency < 0 {
+		return errors.New("max_concurrency must not be negative")
+	}
+	return nil
+}
+
+// NewMerge creates a Source that looks keys up in all of sources and merges
+// the values found into one map, such as the location of an IP address from
+// one source and its pod from another. Map values are merged entry by
+// entry, and other values are added under the type of their source.
+// Entries returned by several sources take the value of the source listed
+// last. The key is found if any source finds it.
+//
+// Example:
+//
+//	source := lookupsource.NewMerge(geoipSource, k8sSource)
+func NewMerge(sources ...Source) Source {
+	return NewMergeWithConfig(MergeConfig{}, sources...)
+}
+
+// NewMergeWithConfig is like [NewMerge] with a configurable conflict policy,
+// concurrency and result cache.
+//
+// Sources failing to look a key up are left out of the merge: their errors
+// are only returned, joined, when no source finds the key.
+func NewMergeWithConfig(cfg MergeConfig, sources ...Source) Source {
+	m := &merge{
+		sources: sources,
+		prefix:  cfg.OnConflict == MergePrefix,
+		workers: len(sources),
+		cache:   NewCache(cfg.Cache),
+	}
+	if cfg.MaxConcurrency > 0 {
+		m.workers = min(cfg.MaxConcurrency, len(sources))
+	}
+	clearOnChange(m.cache, sources)
+
+	types := make([]string, len(sources))
+	for i, s := range sources {
+		types[i] = s.Type()
+	}
+	typ := "merge[" + strings.Join(types, ",") + "]"
+
+	return NewSource(
+		WrapWithCache(m.cache, m.lookup),
+		func() string { return typ },
+		m.start,
+		m.shutdown,
+		WithHealthCheck(m.checkHealth),
+		WithReadiness(m.ready),
+		WithCache(m.cache),
+	)
+}
+
+type merge struct {
+	sources []Source
+	prefix  bool
+	workers int
+	cache   *Cache
+}
+
+type mergeResult struct {
+	val   any
+	found bool
+	err   error
+}
+
+func (m *merge) lookup(ctx context.Context, key string) (any, bool, error) {
+	results := make([]mergeResult, len(m.sources))
+	lookup := func(i int) {
+		r := &results[i]
+		r.val, r.found, r.err = m.sources[i].Lookup(ctx, key)
+	}
+	if m.workers <= 1 {
+		for i := range m.sources {
+			lookup(i)
+		}
+	} else {
+		indexes := make(chan int)
+		var wg sync.WaitGroup
+		for range m.workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range indexes {
+					lookup(i)
+				}
+			}()
+		}
+		for i := range m.sources {
+			indexes <- i
+		}
+		close(indexes)
+		wg.Wait()
+	}
+
+	// Results are merged in the order of the sources, whatever order the
+	// lookups completed in, so that conflicts are resolved the same way.
+	var merged map[string]any
+	var errs []error
+	for i, r := range results {
+		typ := m.sources[i].Type()
+		if r.err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", typ, r.err))
+			continue
+		}
+		if !r.found {
+			continue
+		}
+		if merged == nil {
+			merged = map[string]any{}
+		}
+		entries, ok := r.val.(map[string]any)
+		if !ok {
+			merged[typ] = r.val
+			continue
+		}
+		// The entries are copied, as the map may be shared with a cache.
+		for k, v := range entries {
+			if m.prefix {
+				k = typ + "." + k
+			}
+			merged[k] = v
+		}
+	}
+	if merged == nil {
+		return nil, false, errors.Join(errs...)
+	}
+	return merged, true, nil
+}
+
+func (m *merge) start(ctx context.Context, host component.Host) error {
+	return startSources(ctx, host, m.sources)
+}
+
+func (m *merge) shutdown(ctx context.Context) error {
+	var errs []error
+	for _, s := range m.sources {
+		errs = append(errs, s.Shutdown(ctx))
+	}
+	errs = append(errs, m.cache.Shutdown(ctx))
+	return errors.Join(errs...)
+}
+
+func (m *merge) checkHealth(ctx context.Context) error {
+	return CheckHealth(ctx, m.sources...)
+}
+
+func (m *merge) ready(ctx context.Context) error {
+	return WaitReady(ctx, m.sources...)
+}
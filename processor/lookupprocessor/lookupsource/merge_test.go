@@ -0,0 +1,219 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+)
+
+func TestMergeLookup(t *testing.T) {
+	errDown := errors.New("down")
+	geoip := func() *testSource {
+		return &testSource{typ: "geoip", entries: map[string]any{
+			"10.0.0.1": map[string]any{"country": "DE", "city": "Berlin"},
+		}}
+	}
+	k8s := func() *testSource {
+		return &testSource{typ: "k8s", entries: map[string]any{
+			"10.0.0.1": map[string]any{"pod": "web-1", "city": "cluster-a"},
+			"10.0.0.2": map[string]any{"pod": "web-2"},
+		}}
+	}
+
+	tests := []struct {
+		name      string
+		cfg       MergeConfig
+		sources   []*testSource
+		key       string
+		wantVal   any
+		wantFound bool
+		wantErr   string
+	}{
+		{
+			name:      "last wins",
+			sources:   []*testSource{geoip(), k8s()},
+			key:       "10.0.0.1",
+			wantVal:   map[string]any{"country": "DE", "city": "cluster-a", "pod": "web-1"},
+			wantFound: true,
+		},
+		{
+			name:      "last wins in source order",
+			sources:   []*testSource{k8s(), geoip()},
+			key:       "10.0.0.1",
+			wantVal:   map[string]any{"country": "DE", "city": "Berlin", "pod": "web-1"},
+			wantFound: true,
+		},
+		{
+			name:    "prefix",
+			cfg:     MergeConfig{OnConflict: MergePrefix},
+			sources: []*testSource{geoip(), k8s()},
+			key:     "10.0.0.1",
+			wantVal: map[string]any{
+				"geoip.country": "DE", "geoip.city": "Berlin",
+				"k8s.pod": "web-1", "k8s.city": "cluster-a",
+			},
+			wantFound: true,
+		},
+		{
+			name:      "found by one source",
+			sources:   []*testSource{geoip(), k8s()},
+			key:       "10.0.0.2",
+			wantVal:   map[string]any{"pod": "web-2"},
+			wantFound: true,
+		},
+		{
+			name: "non-map value keyed by source type",
+			sources: []*testSource{
+				geoip(),
+				{typ: "dns", entries: map[string]any{"10.0.0.1": "web-1.example.com"}},
+			},
+			key:       "10.0.0.1",
+			wantVal:   map[string]any{"country": "DE", "city": "Berlin", "dns": "web-1.example.com"},
+			wantFound: true,
+		},
+		{
+			name:    "not found",
+			sources: []*testSource{geoip(), k8s()},
+			key:     "10.0.0.9",
+		},
+		{
+			name:      "failing source left out",
+			sources:   []*testSource{geoip(), {typ: "k8s", err: errDown}},
+			key:       "10.0.0.1",
+			wantVal:   map[string]any{"country": "DE", "city": "Berlin"},
+			wantFound: true,
+		},
+		{
+			name:    "errors returned when not found",
+			sources: []*testSource{geoip(), {typ: "k8s", err: errDown}},
+			key:     "10.0.0.9",
+			wantErr: "k8s: down",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sources := make([]Source, len(tt.sources))
+			for i, s := range tt.sources {
+				sources[i] = s.source()
+			}
+			merge := NewMergeWithConfig(tt.cfg, sources...)
+
+			val, found, err := merge.Lookup(t.Context(), tt.key)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantFound, found)
+			assert.Equal(t, tt.wantVal, val)
+			for _, s := range tt.sources {
+				assert.Equal(t, 1, s.calls, s.typ)
+			}
+		})
+	}
+}
+
+func TestMergeDoesNotModifySourceValues(t *testing.T) {
+	geoip := &testSource{typ: "geoip", entries: map[string]any{"a": map[string]any{"country": "DE"}}}
+	k8s := &testSource{typ: "k8s", entries: map[string]any{"a": map[string]any{"pod": "web-1"}}}
+
+	_, _, err := NewMerge(geoip.source(), k8s.source()).Lookup(t.Context(), "a")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"country": "DE"}, geoip.entries["a"])
+}
+
+func TestMergeMaxConcurrency(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int64
+	newSource := func(typ string) Source {
+		return NewSource(func(context.Context, string) (any, bool, error) {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				m := maxInFlight.Load()
+				if n <= m || maxInFlight.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			return map[string]any{typ: true}, true, nil
+		}, func() string { return typ }, nil, nil)
+	}
+	sources := []Source{newSource("a"), newSource("b"), newSource("c"), newSource("d")}
+
+	val, found, err := NewMergeWithConfig(MergeConfig{MaxConcurrency: 2}, sources...).Lookup(t.Context(), "key")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, map[string]any{"a": true, "b": true, "c": true, "d": true}, val)
+	assert.Equal(t, int64(2), maxInFlight.Load())
+}
+
+func TestMergeCache(t *testing.T) {
+	geoip := &testSource{typ: "geoip", entries: map[string]any{"a": map[string]any{"country": "DE"}}}
+	k8s := &testSource{typ: "k8s", entries: map[string]any{"a": map[string]any{"pod": "web-1"}}, cache: NewCache(CacheConfig{})}
+	merge := NewMergeWithConfig(MergeConfig{Cache: CacheConfig{Enabled: true}}, geoip.source(), k8s.source())
+	lookup := func() any {
+		val, found, err := merge.Lookup(t.Context(), "a")
+		require.NoError(t, err)
+		require.True(t, found)
+		return val
+	}
+
+	// A cached key doesn't go through the sources again.
+	for range 3 {
+		assert.Equal(t, map[string]any{"country": "DE", "pod": "web-1"}, lookup())
+	}
+	assert.Equal(t, 1, geoip.calls)
+	assert.Equal(t, 1, k8s.calls)
+
+	// A change of the data of a source drops the cached results.
+	k8s.entries["a"] = map[string]any{"pod": "web-2"}
+	k8s.cache.Clear()
+	assert.Equal(t, map[string]any{"country": "DE", "pod": "web-2"}, lookup())
+	assert.Equal(t, 2, geoip.calls)
+}
+
+func TestMergeStartFailure(t *testing.T) {
+	var started, stopped []string
+	newSource := func(typ string, startErr error) Source {
+		return NewSource(nil, func() string { return typ },
+			func(context.Context, component.Host) error {
+				started = append(started, typ)
+				return startErr
+			},
+			func(context.Context) error {
+				stopped = append(stopped, typ)
+				return nil
+			},
+		)
+	}
+
+	// The sources started before one that fails are shut down.
+	errStart := errors.New("no connection")
+	merge := NewMerge(newSource("file", nil), newSource("env", nil), newSource("dns", errStart), newSource("static", nil))
+	assert.ErrorIs(t, merge.Start(t.Context(), componenttest.NewNopHost()), errStart)
+	assert.Equal(t, []string{"file", "env", "dns"}, started)
+	assert.Equal(t, []string{"env", "file"}, stopped)
+}
+
+func TestMergeType(t *testing.T) {
+	merge := NewMerge((&testSource{typ: "geoip"}).source(), (&testSource{typ: "k8s"}).source())
+	assert.Equal(t, "merge[geoip,k8s]", merge.Type())
+}
+
+func TestMergeConfigValidate(t *testing.T) {
+	assert.NoError(t, (&MergeConfig{}).Validate())
+	assert.NoError(t, (&MergeConfig{OnConflict: MergePrefix, MaxConcurrency: 2}).Validate())
+	assert.ErrorContains(t, (&MergeConfig{OnConflict: "first_wins"}).Validate(), `invalid on_conflict policy "first_wins"`)
+	assert.ErrorContains(t, (&MergeConfig{MaxConcurrency: -1}).Validate(), "max_concurrency must not be negative")
+}
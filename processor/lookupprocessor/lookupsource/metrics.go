@@ -0,0 +1,99 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Lookup results recorded in the result attribute of the request counter.
+const (
+	resultFound    = "found"
+	resultNotFound = "not_found"
+	resultError    = "error"
+)
+
+// durationBuckets are the bounds, in seconds, of the lookup duration
+// histogram: from sub-millisecond cache hits to lookups hitting a timeout.
+var durationBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// WithMetrics wraps a lookup function so that each lookup is recorded with
+// the source_type attribute set to sourceType, and attrs:
+//
+//   - otelcol_lookup_source_requests counts lookups, with the result
+//     attribute set to found, not_found or error.
+//   - otelcol_lookup_source_duration records their duration in seconds.
+//   - otelcol_lookup_source_errors counts failed lookups.
+//
+// Apply it inside [WrapWithCache] to measure the calls reaching the backend
+// of a source, or outside to measure all lookups, including cache hits:
+//
+//	lookup := lookupsource.WithMetrics(myLookupFunc, meter, "mysource")
+//	cachedLookup := lookupsource.WrapWithCache(cache, lookup)
+//
+// Set attrs to tell apart the series of several sources of the same type,
+// such as the processor attribute and source_name attribute the lookup
+// processor sets.
+func WithMetrics(fn LookupFunc, meter metric.Meter, sourceType string, attrs ...attribute.KeyValue) LookupFunc {
+	// Instruments are usable even if creating them fails, for example
+	// because the meter provider rejects a name, so errors are only reported.
+	requests, err := meter.Int64Counter(
+		"otelcol_lookup_source_requests",
+		metric.WithDescription("Number of lookups made to the source, by result."),
+		metric.WithUnit("{requests}"),
+	)
+	handleInstrumentError(err)
+	duration, err := meter.Float64Histogram(
+		"otelcol_lookup_source_duration",
+		metric.WithDescription("Duration of the lookups made to the source."),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(durationBuckets...),
+	)
+	handleInstrumentError(err)
+	failures, err := meter.Int64Counter(
+		"otelcol_lookup_source_errors",
+		metric.WithDescription("Number of lookups made to the source that failed."),
+		metric.WithUnit("{errors}"),
+	)
+	handleInstrumentError(err)
+
+	sourceKVs := append([]attribute.KeyValue{attribute.String("source_type", sourceType)}, attrs...)
+	sourceAttrs := metric.WithAttributeSet(attribute.NewSet(sourceKVs...))
+	resultAttrs := func(result string) metric.MeasurementOption {
+		kvs := append(slices.Clone(sourceKVs), attribute.String("result", result))
+		return metric.WithAttributeSet(attribute.NewSet(kvs...))
+	}
+	foundAttrs := resultAttrs(resultFound)
+	notFoundAttrs := resultAttrs(resultNotFound)
+	errorAttrs := resultAttrs(resultError)
+
+	return func(ctx context.Context, key string) (any, bool, error) {
+		start := time.Now()
+		val, found, err := fn(ctx, key)
+		duration.Record(ctx, time.Since(start).Seconds(), sourceAttrs)
+
+		switch {
+		case err != nil:
+			requests.Add(ctx, 1, errorAttrs)
+			failures.Add(ctx, 1, sourceAttrs)
+		case found:
+			requests.Add(ctx, 1, foundAttrs)
+		default:
+			requests.Add(ctx, 1, notFoundAttrs)
+		}
+		return val, found, err
+	}
+}
+
+func handleInstrumentError(err error) {
+	if err != nil {
+		otel.Handle(err)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestWithMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	meter := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test")
+
+	errUnavailable := errors.New("unavailable")
+	lookup := WithMetrics(func(_ context.Context, key string) (any, bool, error) {
+		switch key {
+		case "found":
+			return "value", true, nil
+		case "broken":
+			return nil, false, errUnavailable
+		default:
+			return nil, false, nil
+		}
+	}, meter, "dns")
+
+	val, found, err := lookup(t.Context(), "found")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "value", val)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(t.Context(), &rm))
+	foundAttrs := attribute.NewSet(attribute.String("source_type", "dns"), attribute.String("result", "found"))
+	assert.Equal(t, map[attribute.Set]int64{foundAttrs: 1}, sums(t, rm, "otelcol_lookup_source_requests"))
+	assert.Empty(t, sums(t, rm, "otelcol_lookup_source_errors"))
+	sourceAttrs := attribute.NewSet(attribute.String("source_type", "dns"))
+	assert.Equal(t, map[attribute.Set]uint64{sourceAttrs: 1}, histogramCounts(t, rm, "otelcol_lookup_source_duration"))
+
+	_, _, err = lookup(t.Context(), "missing")
+	require.NoError(t, err)
+	_, _, err = lookup(t.Context(), "broken")
+	require.ErrorIs(t, err, errUnavailable)
+
+	rm = metricdata.ResourceMetrics{}
+	require.NoError(t, reader.Collect(t.Context(), &rm))
+	assert.Equal(t, map[attribute.Set]int64{
+		foundAttrs: 1,
+		attribute.NewSet(attribute.String("source_type", "dns"), attribute.String("result", "not_found")): 1,
+		attribute.NewSet(attribute.String("source_type", "dns"), attribute.String("result", "error")):     1,
+	}, sums(t, rm, "otelcol_lookup_source_requests"))
+	assert.Equal(t, map[attribute.Set]int64{sourceAttrs: 1}, sums(t, rm, "otelcol_lookup_source_errors"))
+	assert.Equal(t, map[attribute.Set]uint64{sourceAttrs: 3}, histogramCounts(t, rm, "otelcol_lookup_source_duration"))
+}
+
+func TestWithMetricsAttributes(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	meter := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test")
+
+	found := func(context.Context, string) (any, bool, error) { return "value", true, nil }
+	first := WithMetrics(found, meter, "dns", attribute.String("source_name", "first"))
+	second := WithMetrics(found, meter, "dns", attribute.String("source_name", "second"))
+	for _, lookup := range []LookupFunc{first, second, second} {
+		_, _, err := lookup(t.Context(), "key")
+		require.NoError(t, err)
+	}
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(t.Context(), &rm))
+	resultAttrs := func(name string) attribute.Set {
+		return attribute.NewSet(attribute.String("source_type", "dns"), attribute.String("source_name", name), attribute.String("result", "found"))
+	}
+	assert.Equal(t, map[attribute.Set]int64{resultAttrs("first"): 1, resultAttrs("second"): 2}, sums(t, rm, "otelcol_lookup_source_requests"))
+	sourceAttrs := func(name string) attribute.Set {
+		return attribute.NewSet(attribute.String("source_type", "dns"), attribute.String("source_name", name))
+	}
+	assert.Equal(t, map[attribute.Set]uint64{sourceAttrs("first"): 1, sourceAttrs("second"): 2}, histogramCounts(t, rm, "otelcol_lookup_source_duration"))
+}
+
+// findMetric returns the metric named name in rm, or nil if there is none.
+func findMetric(rm metricdata.ResourceMetrics, name string) *metricdata.Metrics {
+	for _, sm := range rm.ScopeMetrics {
+		for i := range sm.Metrics {
+			if sm.Metrics[i].Name == name {
+				return &sm.Metrics[i]
+			}
+		}
+	}
+	return nil
+}
+
+// sums returns the values of the counter named name, by attributes.
+func sums(t *testing.T, rm metricdata.ResourceMetrics, name string) map[attribute.Set]int64 {
+	t.Helper()
+	m := findMetric(rm, name)
+	if m == nil {
+		return nil
+	}
+	data, ok := m.Data.(metricdata.Sum[int64])
+	require.True(t, ok, "unexpected data type %T for %s", m.Data, name)
+	values := make(map[attribute.Set]int64)
+	for _, dp := range data.DataPoints {
+		values[dp.Attributes] = dp.Value
+	}
+	return values
+}
+
+// histogramCounts returns the counts of the histogram named name, by
+// attributes.
+func histogramCounts(t *testing.T, rm metricdata.ResourceMetrics, name string) map[attribute.Set]uint64 {
+	t.Helper()
+	m := findMetric(rm, name)
+	if m == nil {
+		return nil
+	}
+	data, ok := m.Data.(metricdata.Histogram[float64])
+	require.True(t, ok, "unexpected data type %T for %s", m.Data, name)
+	counts := make(map[attribute.Set]uint64)
+	for _, dp := range data.DataPoints {
+		counts[dp.Attributes] = dp.Count
+	}
+	return counts
+}
@@ -0,0 +1,92 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// MultiKeyLookupFunc looks up a compound key made of several named parts,
+// such as an IP address and a port, whose result depends on all of them.
+type MultiKeyLookupFunc func(ctx context.Context, keys map[string]string) (any, bool, error)
+
+// MultiKeySource is implemented by sources that can look up compound keys,
+// such as a SQL query with several parameters.
+//
+// Use [WithMultiKeyLookup] to create implementations.
+type MultiKeySource interface {
+	Source
+	MultiKeyLookup(ctx context.Context, keys map[string]string) (any, bool, error)
+}
+
+// WithMultiKeyLookup makes [NewSource] return a [MultiKeySource] that uses fn
+// for compound key lookups. It can be combined with [WithBatchLookup].
+func WithMultiKeyLookup(fn MultiKeyLookupFunc) SourceOption {
+	return multiKeyLookupOption{fn: fn}
+}
+
+type multiKeyLookupOption struct {
+	fn MultiKeyLookupFunc
+}
+
+func (o multiKeyLookupOption) apply(s *sourceImpl) {
+	s.multiKeyFn = o.fn
+}
+
+// CompoundKey encodes keys into a single key, the same for equal maps, so
+// that compound keys can be cached and deduplicated like any other key.
+// [ParseCompoundKey] decodes it.
+func CompoundKey(keys map[string]string) string {
+	// Maps are encoded with sorted keys, and encoding a map of strings
+	// cannot fail.
+	b, _ := json.Marshal(keys)
+	return string(b)
+}
+
+// ParseCompoundKey decodes a key encoded with [CompoundKey].
+func ParseCompoundKey(key string) (map[string]string, error) {
+	var keys map[string]string
+	if err := json.Unmarshal([]byte(key), &keys); err != nil {
+		return nil, Permanent(fmt.Errorf("invalid compound key %q: %w", key, err))
+	}
+	return keys, nil
+}
+
+// CompoundLookup adapts fn into a LookupFunc looking up keys encoded with
+// [CompoundKey], so that the LookupFunc wrappers, such as [WrapWithCache],
+// apply to compound key lookups:
+//
+//	lookup := lookupsource.WrapWithCache(cache, lookupsource.CompoundLookup(myMultiKeyLookupFunc))
+//	source := lookupsource.NewSource(nil, typeFunc, nil, nil,
+//	    lookupsource.WithMultiKeyLookup(func(ctx context.Context, keys map[string]string) (any, bool, error) {
+//	        return lookup(ctx, lookupsource.CompoundKey(keys))
+//	    }),
+//	)
+func CompoundLookup(fn MultiKeyLookupFunc) LookupFunc {
+	return func(ctx context.Context, key string) (any, bool, error) {
+		keys, err := ParseCompoundKey(key)
+		if err != nil {
+			return nil, false, err
+		}
+		return fn(ctx, keys)
+	}
+}
+
+type multiKeySourceImpl struct {
+	*sourceImpl
+}
+
+func (s *multiKeySourceImpl) MultiKeyLookup(ctx context.Context, keys map[string]string) (any, bool, error) {
+	return s.multiKeyFn(ctx, keys)
+}
+
+type batchMultiKeySourceImpl struct {
+	*batchSourceImpl
+}
+
+func (s *batchMultiKeySourceImpl) MultiKeyLookup(ctx context.Context, keys map[string]string) (any, bool, error) {
+	return s.multiKeyFn(ctx, keys)
+}
@@ -0,0 +1,92 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompoundKey(t *testing.T) {
+	keys := map[string]string{"port": "443", "ip": "10.0.0.1"}
+	key := CompoundKey(keys)
+	assert.Equal(t, `{"ip":"10.0.0.1","port":"443"}`, key)
+	assert.Equal(t, key, CompoundKey(map[string]string{"ip": "10.0.0.1", "port": "443"}))
+
+	got, err := ParseCompoundKey(key)
+	require.NoError(t, err)
+	assert.Equal(t, keys, got)
+
+	_, err = ParseCompoundKey("10.0.0.1")
+	require.ErrorContains(t, err, `invalid compound key "10.0.0.1"`)
+	assert.True(t, IsPermanent(err))
+}
+
+func TestWithMultiKeyLookup(t *testing.T) {
+	owners := map[string]string{
+		CompoundKey(map[string]string{"ip": "10.0.0.1", "port": "443"}):  "nginx",
+		CompoundKey(map[string]string{"ip": "10.0.0.1", "port": "5432"}): "postgres",
+	}
+	var calls int
+	newLookup := func() LookupFunc {
+		return WrapWithCache(NewCache(CacheConfig{Enabled: true, Size: 10}), CompoundLookup(func(_ context.Context, keys map[string]string) (any, bool, error) {
+			calls++
+			owner, ok := owners[CompoundKey(keys)]
+			return owner, ok, nil
+		}))
+	}
+	multiKey := func(lookup LookupFunc) MultiKeyLookupFunc {
+		return func(ctx context.Context, keys map[string]string) (any, bool, error) {
+			return lookup(ctx, CompoundKey(keys))
+		}
+	}
+
+	tests := []struct {
+		name      string
+		newSource func(lookup LookupFunc) Source
+	}{
+		{
+			name: "multi-key",
+			newSource: func(lookup LookupFunc) Source {
+				return NewSource(nil, func() string { return "owners" }, nil, nil, WithMultiKeyLookup(multiKey(lookup)))
+			},
+		},
+		{
+			name: "batch and multi-key",
+			newSource: func(lookup LookupFunc) Source {
+				return NewSource(nil, func() string { return "owners" }, nil, nil,
+					WithMultiKeyLookup(multiKey(lookup)),
+					WithBatchLookup(LoopBatch(lookup)),
+				)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = 0
+			ms, ok := tt.newSource(newLookup()).(MultiKeySource)
+			require.True(t, ok)
+
+			for range 2 {
+				val, found, err := ms.MultiKeyLookup(t.Context(), map[string]string{"ip": "10.0.0.1", "port": "5432"})
+				require.NoError(t, err)
+				assert.True(t, found)
+				assert.Equal(t, "postgres", val)
+			}
+			_, found, err := ms.MultiKeyLookup(t.Context(), map[string]string{"ip": "10.0.0.2", "port": "443"})
+			require.NoError(t, err)
+			assert.False(t, found)
+			// The second lookup of a compound key is a cache hit.
+			assert.Equal(t, 2, calls)
+		})
+	}
+
+	_, ok := NewSource(nil, func() string { return "owners" }, nil, nil).(MultiKeySource)
+	assert.False(t, ok)
+	_, ok = tests[1].newSource(newLookup()).(BatchSource)
+	assert.True(t, ok)
+}
@@ -0,0 +1,97 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// MultiValueMode selects how a lookup result holding several values, such as
+// the host names of a PTR lookup, is returned.
+type MultiValueMode string
+
+const (
+	// MultiValueArray returns the values as a []any, which is written as an
+	// array attribute.
+	MultiValueArray MultiValueMode = "array"
+	// MultiValueJoin returns the string forms of the values joined by a
+	// separator.
+	MultiValueJoin MultiValueMode = "join"
+	// MultiValueFirst returns the first value.
+	MultiValueFirst MultiValueMode = "first"
+)
+
+// Validate returns an error if m is not a known mode.
+func (m MultiValueMode) Validate() error {
+	switch m {
+	case MultiValueArray, MultiValueJoin, MultiValueFirst:
+		return nil
+	default:
+		return fmt.Errorf("invalid multi_value %q, must be %q, %q or %q", m, MultiValueArray, MultiValueJoin, MultiValueFirst)
+	}
+}
+
+// ApplyMultiValue returns val according to mode if it is a []any or a
+// []string, and val itself otherwise. The value of a [TimedResult] is
+// returned according to mode as well, keeping its duration. It reports false
+// for an empty slice with the first mode, which has no value to return. val
+// is never modified, so it may be a value shared by a cache.
+func ApplyMultiValue(val any, mode MultiValueMode, separator string) (any, bool) {
+	var values []any
+	switch v := val.(type) {
+	case TimedResult:
+		inner, ok := ApplyMultiValue(v.Value, mode, separator)
+		if !ok {
+			return nil, false
+		}
+		return TimedResult{Value: inner, Duration: v.Duration}, true
+	case []any:
+		values = v
+	case []string:
+		values = make([]any, len(v))
+		for i, s := range v {
+			values[i] = s
+		}
+	default:
+		return val, true
+	}
+
+	switch mode {
+	case MultiValueJoin:
+		parts := make([]string, len(values))
+		for i, v := range values {
+			parts[i] = fmt.Sprint(v)
+		}
+		return strings.Join(parts, separator), true
+	case MultiValueFirst:
+		if len(values) == 0 {
+			return nil, false
+		}
+		return values[0], true
+	default:
+		// Copy the values so that callers writing to the result don't
+		// change a slice shared with a cache.
+		return append([]any(nil), values...), true
+	}
+}
+
+// WithMultiValue wraps a lookup function so that its results are returned
+// according to mode, as with [ApplyMultiValue]. Apply it outside
+// [WrapWithCache] so that the cache keeps the values returned by the source
+// and every mode is applied the same way to cache hits and misses:
+//
+//	cachedLookup := lookupsource.WrapWithCache(cache, myLookupFunc)
+//	lookup := lookupsource.WithMultiValue(cachedLookup, lookupsource.MultiValueJoin, ",")
+func WithMultiValue(fn LookupFunc, mode MultiValueMode, separator string) LookupFunc {
+	return func(ctx context.Context, key string) (any, bool, error) {
+		val, found, err := fn(ctx, key)
+		if err != nil || !found {
+			return val, found, err
+		}
+		val, found = ApplyMultiValue(val, mode, separator)
+		return val, found, nil
+	}
+}
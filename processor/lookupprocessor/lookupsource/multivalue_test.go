@@ -0,0 +1,89 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyMultiValue(t *testing.T) {
+	tests := []struct {
+		name      string
+		val       any
+		mode      MultiValueMode
+		want      any
+		wantFound bool
+	}{
+		{name: "array", val: []any{"a", int64(1)}, mode: MultiValueArray, want: []any{"a", int64(1)}, wantFound: true},
+		{name: "array of strings", val: []string{"a", "b"}, mode: MultiValueArray, want: []any{"a", "b"}, wantFound: true},
+		{name: "join", val: []any{"a", int64(1)}, mode: MultiValueJoin, want: "a,1", wantFound: true},
+		{name: "join strings", val: []string{"a", "b"}, mode: MultiValueJoin, want: "a,b", wantFound: true},
+		{name: "join empty", val: []string{}, mode: MultiValueJoin, want: "", wantFound: true},
+		{name: "first", val: []string{"a", "b"}, mode: MultiValueFirst, want: "a", wantFound: true},
+		{name: "first empty", val: []any{}, mode: MultiValueFirst, want: nil, wantFound: false},
+		{name: "scalar", val: "a", mode: MultiValueFirst, want: "a", wantFound: true},
+		{name: "map", val: map[string]any{"a": 1}, mode: MultiValueJoin, want: map[string]any{"a": 1}, wantFound: true},
+		{name: "timed", val: TimedResult{Value: []string{"a", "b"}, Duration: time.Second}, mode: MultiValueJoin, want: TimedResult{Value: "a,b", Duration: time.Second}, wantFound: true},
+		{name: "timed first empty", val: TimedResult{Value: []any{}}, mode: MultiValueFirst, want: nil, wantFound: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := ApplyMultiValue(tt.val, tt.mode, ",")
+			assert.Equal(t, tt.wantFound, found)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMultiValueModeValidate(t *testing.T) {
+	for _, mode := range []MultiValueMode{MultiValueArray, MultiValueJoin, MultiValueFirst} {
+		assert.NoError(t, mode.Validate())
+	}
+	assert.EqualError(t, MultiValueMode("last").Validate(), `invalid multi_value "last", must be "array", "join" or "first"`)
+}
+
+func TestWithMultiValueCached(t *testing.T) {
+	tests := []struct {
+		mode MultiValueMode
+		want any
+	}{
+		{mode: MultiValueArray, want: []any{"web-1.example.com", "web.example.com"}},
+		{mode: MultiValueJoin, want: "web-1.example.com;web.example.com"},
+		{mode: MultiValueFirst, want: "web-1.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			calls := 0
+			cache := NewCache(CacheConfig{Enabled: true})
+			lookup := WithMultiValue(WrapWithCache(cache, func(context.Context, string) (any, bool, error) {
+				calls++
+				return []string{"web-1.example.com", "web.example.com"}, true, nil
+			}), tt.mode, ";")
+
+			for range 2 {
+				val, found, err := lookup(t.Context(), "10.0.0.1")
+				require.NoError(t, err)
+				assert.True(t, found)
+				assert.Equal(t, tt.want, val)
+
+				// Changing a result doesn't change the cached entry.
+				if arr, ok := val.([]any); ok {
+					arr[0] = "changed"
+				}
+			}
+			assert.Equal(t, 1, calls)
+
+			cached, ok := cache.Get("10.0.0.1")
+			require.True(t, ok)
+			assert.Equal(t, []string{"web-1.example.com", "web.example.com"}, cached)
+		})
+	}
+}
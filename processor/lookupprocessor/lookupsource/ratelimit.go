@@ -0,0 +1,39 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"golang.org/x/time/rate"
+)
+
+// ErrRateLimited is returned by lookups that could not be admitted by a
+// [WithRateLimit] limiter before the context was done.
+var ErrRateLimited = errors.New("lookup rate limited")
+
+// WithRateLimit wraps a lookup function so the underlying source is called at
+// most rps times per second, with bursts of up to burst calls. A lookup waits
+// for its turn, but fails with ErrRateLimited right away if it could not be
+// admitted before the context deadline. A non-positive rps disables limiting.
+//
+// Apply it before [WrapWithCache] so that only cache misses count against the
+// limit:
+//
+//	limited := lookupsource.WithRateLimit(myLookupFunc, 10, 5)
+//	cachedLookup := lookupsource.WrapWithCache(cache, limited)
+func WithRateLimit(fn LookupFunc, rps float64, burst int) LookupFunc {
+	if rps <= 0 {
+		return fn
+	}
+	limiter := rate.NewLimiter(rate.Limit(rps), max(burst, 1))
+	return func(ctx context.Context, key string) (any, bool, error) {
+		if err := limiter.Wait(ctx); err != nil {
+			return nil, false, fmt.Errorf("%w: %w", ErrRateLimited, err)
+		}
+		return fn(ctx, key)
+	}
+}
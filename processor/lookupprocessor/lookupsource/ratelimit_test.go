@@ -0,0 +1,78 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithRateLimit(t *testing.T) {
+	var calls atomic.Int64
+	lookup := WithRateLimit(func(context.Context, string) (any, bool, error) {
+		calls.Add(1)
+		return "value", true, nil
+	}, 100, 1)
+
+	start := time.Now()
+	for range 21 {
+		_, _, err := lookup(t.Context(), "key")
+		require.NoError(t, err)
+	}
+	elapsed := time.Since(start)
+
+	// 20 calls beyond the burst at 100/s take at least 200ms.
+	assert.GreaterOrEqual(t, elapsed, 180*time.Millisecond)
+	assert.Equal(t, int64(21), calls.Load())
+}
+
+func TestWithRateLimitDeadline(t *testing.T) {
+	calls := 0
+	lookup := WithRateLimit(func(context.Context, string) (any, bool, error) {
+		calls++
+		return "value", true, nil
+	}, 0.1, 1)
+
+	_, _, err := lookup(t.Context(), "key")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	_, _, err = lookup(ctx, "key")
+	assert.ErrorIs(t, err, ErrRateLimited)
+	// The limiter fails fast rather than waiting for the deadline.
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, 1, calls)
+}
+
+func TestWithRateLimitCachedHitsAreFree(t *testing.T) {
+	calls := 0
+	limited := WithRateLimit(func(context.Context, string) (any, bool, error) {
+		calls++
+		return "value", true, nil
+	}, 0.1, 1)
+	lookup := WrapWithCache(NewCache(CacheConfig{Enabled: true}), limited)
+
+	for range 5 {
+		_, found, err := lookup(t.Context(), "key")
+		require.NoError(t, err)
+		assert.True(t, found)
+	}
+	assert.Equal(t, 1, calls)
+}
+
+func TestWithRateLimitDisabled(t *testing.T) {
+	fn := func(context.Context, string) (any, bool, error) { return nil, false, nil }
+	lookup := WithRateLimit(fn, 0, 0)
+	for range 100 {
+		_, _, err := lookup(t.Context(), "key")
+		require.NoError(t, err)
+	}
+}
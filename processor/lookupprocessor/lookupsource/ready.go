@@ -0,0 +1,55 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ReadinessChecker is implemented by sources that load their data in the
+// background once started, such as from a Kubernetes informer, and don't
+// find keys they haven't loaded yet until then.
+//
+// Sources created with [NewSource] implement it, ready as soon as they
+// started unless a function is set with [WithReadiness].
+type ReadinessChecker interface {
+	// Ready blocks until the source is ready to serve lookups, or returns
+	// an error if it can't be, or if ctx is done first.
+	Ready(ctx context.Context) error
+}
+
+// ReadyFunc blocks until the source is ready to serve lookups.
+type ReadyFunc func(ctx context.Context) error
+
+// WithReadiness sets the function used by the source's Ready method.
+func WithReadiness(fn ReadyFunc) SourceOption {
+	return readinessOption{fn: fn}
+}
+
+type readinessOption struct {
+	fn ReadyFunc
+}
+
+func (o readinessOption) apply(s *sourceImpl) {
+	s.readyFn = o.fn
+}
+
+// WaitReady waits for all sources to be ready and returns the combined
+// errors of the ones that aren't once ctx is done, each prefixed with the
+// source type. Sources that don't implement [ReadinessChecker] are ready.
+func WaitReady(ctx context.Context, sources ...Source) error {
+	var errs []error
+	for _, s := range sources {
+		rc, ok := s.(ReadinessChecker)
+		if !ok {
+			continue
+		}
+		if err := rc.Ready(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.Type(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
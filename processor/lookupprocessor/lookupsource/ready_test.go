@@ -0,0 +1,80 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newWarmingSource returns a source that becomes ready once ready is closed.
+func newWarmingSource(typ string, ready <-chan struct{}) Source {
+	return NewSource(nil, func() string { return typ }, nil, nil,
+		WithReadiness(func(ctx context.Context) error {
+			// A ready source is ready even once ctx is done.
+			select {
+			case <-ready:
+				return nil
+			default:
+			}
+			select {
+			case <-ready:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}),
+	)
+}
+
+func TestSourceReady(t *testing.T) {
+	// Sources without a readiness function are ready.
+	unchecked := NewSource(nil, func() string { return "noop" }, nil, nil)
+	assert.NoError(t, unchecked.(ReadinessChecker).Ready(t.Context()))
+
+	ready := make(chan struct{})
+	warming := newWarmingSource("k8s", ready)
+	time.AfterFunc(20*time.Millisecond, func() { close(ready) })
+	start := time.Now()
+	require.NoError(t, warming.(ReadinessChecker).Ready(t.Context()))
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+	// Batch sources keep the readiness function.
+	errNotReady := errors.New("informer failed to sync")
+	batch := NewSource(nil, func() string { return "batch" }, nil, nil,
+		WithBatchLookup(func(context.Context, []string) ([]Result, error) { return nil, nil }),
+		WithReadiness(func(context.Context) error { return errNotReady }),
+	)
+	assert.ErrorIs(t, batch.(ReadinessChecker).Ready(t.Context()), errNotReady)
+}
+
+func TestWaitReady(t *testing.T) {
+	assert.NoError(t, WaitReady(t.Context()))
+	assert.NoError(t, WaitReady(t.Context(), plainSource{}, newWarmingSource("k8s", closedChan())))
+
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+	err := WaitReady(ctx,
+		newWarmingSource("static", closedChan()),
+		newWarmingSource("k8s", make(chan struct{})),
+	)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.EqualError(t, err, "k8s: context deadline exceeded")
+
+	// Chains are ready once all their sources are.
+	chain := NewChain(newWarmingSource("static", closedChan()), newWarmingSource("k8s", make(chan struct{})))
+	err = WaitReady(ctx, chain)
+	assert.EqualError(t, err, "chain[static,k8s]: k8s: context deadline exceeded")
+}
+
+func closedChan() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}
@@ -0,0 +1,144 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// LookupRecord is the outcome of a lookup kept by [RecentLookups].
+type LookupRecord struct {
+	Time    time.Time     `json:"time"`
+	Key     string        `json:"key"`
+	Found   bool          `json:"found"`
+	Latency time.Duration `json:"latency"`
+	// Error is the message of the error the lookup failed with, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// RecentLookups keeps the outcomes of the last lookups of a source in a ring
+// buffer of fixed size, dropping the oldest once full. It is safe for
+// concurrent use.
+type RecentLookups struct {
+	mu      sync.Mutex
+	records []LookupRecord
+	// next is the index the next record is written at.
+	next int
+	full bool
+}
+
+// NewRecentLookups returns a RecentLookups keeping the last size lookups. A
+// size below 1 keeps one.
+func NewRecentLookups(size int) *RecentLookups {
+	return &RecentLookups{records: make([]LookupRecord, max(size, 1))}
+}
+
+// Add records record, evicting the oldest record if the buffer is full.
+func (r *RecentLookups) Add(record LookupRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records[r.next] = record
+	r.next++
+	if r.next == len(r.records) {
+		r.next = 0
+		r.full = true
+	}
+}
+
+// Records returns a copy of the records kept, oldest first.
+func (r *RecentLookups) Records() []LookupRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([]LookupRecord(nil), r.records[:r.next]...)
+	}
+	records := make([]LookupRecord, 0, len(r.records))
+	records = append(records, r.records[r.next:]...)
+	return append(records, r.records[:r.next]...)
+}
+
+func (r *RecentLookups) add(start time.Time, key string, found bool, err error) {
+	record := LookupRecord{
+		Time:    start,
+		Key:     key,
+		Found:   found,
+		Latency: time.Since(start),
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	r.Add(record)
+}
+
+// WithRecentLookups wraps source so that the outcome of each of its lookups
+// is added to recent, to inspect what a running source is asked and answers.
+// Compound keys are recorded as encoded by [CompoundKey], and each key of a
+// batch is recorded with the latency of the whole batch.
+//
+// The returned source keeps the capabilities of source, such as batching,
+// health checks, reconfiguration and cache statistics. Its Start and
+// Shutdown start and shut down source.
+func WithRecentLookups(source Source, recent *RecentLookups) Source {
+	opts := []SourceOption{delegateOption{source: source}}
+	if hc, ok := source.(HealthChecker); ok {
+		opts = append(opts, WithHealthCheck(hc.CheckHealth))
+	}
+	if rc, ok := source.(ReadinessChecker); ok {
+		opts = append(opts, WithReadiness(rc.Ready))
+	}
+	if r, ok := source.(Reconfigurer); ok {
+		opts = append(opts, WithReconfigure(r.Reconfigure))
+	}
+	if sp, ok := source.(StoreFuncProvider); ok {
+		opts = append(opts, WithStore(sp.StoreFunc()))
+	}
+	if bs, ok := source.(BatchSource); ok {
+		opts = append(opts, WithBatchLookup(func(ctx context.Context, keys []string) ([]Result, error) {
+			start := time.Now()
+			results, err := bs.LookupBatch(ctx, keys)
+			for i, key := range keys {
+				switch {
+				case err != nil:
+					recent.add(start, key, false, err)
+				case i < len(results):
+					recent.add(start, key, results[i].Found, results[i].Err)
+				}
+			}
+			return results, err
+		}))
+	}
+	if ms, ok := source.(MultiKeySource); ok {
+		opts = append(opts, WithMultiKeyLookup(func(ctx context.Context, keys map[string]string) (any, bool, error) {
+			start := time.Now()
+			val, found, err := ms.MultiKeyLookup(ctx, keys)
+			recent.add(start, CompoundKey(keys), found, err)
+			return val, found, err
+		}))
+	}
+
+	return NewSource(
+		func(ctx context.Context, key string) (any, bool, error) {
+			start := time.Now()
+			val, found, err := source.Lookup(ctx, key)
+			recent.add(start, key, found, err)
+			return val, found, err
+		},
+		source.Type,
+		source.Start,
+		source.Shutdown,
+		opts...,
+	)
+}
+
+// delegateOption makes the source report the cache statistics, changes and
+// key function of source, which it wraps, unless set by other options.
+type delegateOption struct {
+	source Source
+}
+
+func (o delegateOption) apply(s *sourceImpl) {
+	s.delegate = o.source
+}
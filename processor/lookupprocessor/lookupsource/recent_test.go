@@ -0,0 +1,140 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func recordedKeys(records []LookupRecord) []string {
+	keys := make([]string, len(records))
+	for i, r := range records {
+		keys[i] = r.Key
+	}
+	return keys
+}
+
+func TestRecentLookups(t *testing.T) {
+	recent := NewRecentLookups(3)
+	assert.Empty(t, recent.Records())
+
+	recent.Add(LookupRecord{Key: "a"})
+	recent.Add(LookupRecord{Key: "b"})
+	assert.Equal(t, []string{"a", "b"}, recordedKeys(recent.Records()))
+
+	// The oldest records are evicted once the buffer is full.
+	for _, key := range []string{"c", "d", "e"} {
+		recent.Add(LookupRecord{Key: key})
+	}
+	assert.Equal(t, []string{"c", "d", "e"}, recordedKeys(recent.Records()))
+	recent.Add(LookupRecord{Key: "f"})
+	assert.Equal(t, []string{"d", "e", "f"}, recordedKeys(recent.Records()))
+}
+
+func TestRecentLookupsConcurrent(t *testing.T) {
+	recent := NewRecentLookups(50)
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 100 {
+				recent.Add(LookupRecord{Key: strconv.Itoa(i*100 + j)})
+				_ = recent.Records()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, recent.Records(), 50)
+}
+
+func TestWithRecentLookups(t *testing.T) {
+	errLookup := errors.New("connection refused")
+	cache := NewCache(CacheConfig{Enabled: true})
+	inner := NewSource(
+		func(_ context.Context, key string) (any, bool, error) {
+			switch key {
+			case "10.0.0.1":
+				return "web-1", true, nil
+			case "10.0.0.9":
+				return nil, false, errLookup
+			}
+			return nil, false, nil
+		},
+		func() string { return "static" },
+		nil,
+		nil,
+		WithCache(cache),
+		WithBatchLookup(func(_ context.Context, keys []string) ([]Result, error) {
+			return make([]Result, len(keys)), nil
+		}),
+	)
+	recent := NewRecentLookups(10)
+	source := WithRecentLookups(inner, recent)
+	assert.Equal(t, "static", source.Type())
+
+	val, found, err := source.Lookup(t.Context(), "10.0.0.1")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "web-1", val)
+	_, found, err = source.Lookup(t.Context(), "10.0.0.2")
+	require.NoError(t, err)
+	assert.False(t, found)
+	_, _, err = source.Lookup(t.Context(), "10.0.0.9")
+	assert.ErrorIs(t, err, errLookup)
+
+	records := recent.Records()
+	require.Len(t, records, 3)
+	assert.Equal(t, "10.0.0.1", records[0].Key)
+	assert.True(t, records[0].Found)
+	assert.Empty(t, records[0].Error)
+	assert.Equal(t, "10.0.0.2", records[1].Key)
+	assert.False(t, records[1].Found)
+	assert.Equal(t, "connection refused", records[2].Error)
+	for _, r := range records {
+		assert.False(t, r.Time.IsZero())
+		assert.GreaterOrEqual(t, r.Latency, time.Duration(0))
+	}
+
+	// Batch lookups record each key.
+	bs, ok := source.(BatchSource)
+	require.True(t, ok)
+	_, err = bs.LookupBatch(t.Context(), []string{"a", "b"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2", "10.0.0.9", "a", "b"}, recordedKeys(recent.Records()))
+
+	// The cache of the wrapped source is still reported.
+	cache.Set("10.0.0.1", "web-1")
+	_, ok = source.(CacheStatsReporter).CacheStats()
+	assert.True(t, ok)
+	changed := false
+	source.(ChangeNotifier).OnChange(func() { changed = true })
+	cache.Clear()
+	assert.True(t, changed)
+}
+
+func TestWithRecentLookupsMultiKey(t *testing.T) {
+	inner := NewSource(nil, func() string { return "sql" }, nil, nil,
+		WithMultiKeyLookup(func(_ context.Context, keys map[string]string) (any, bool, error) {
+			return keys["ip"] + ":" + keys["port"], true, nil
+		}),
+	)
+	recent := NewRecentLookups(10)
+	source, ok := WithRecentLookups(inner, recent).(MultiKeySource)
+	require.True(t, ok)
+
+	val, found, err := source.MultiKeyLookup(t.Context(), map[string]string{"ip": "10.0.0.1", "port": "443"})
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "10.0.0.1:443", val)
+	assert.Equal(t, []string{`{"ip":"10.0.0.1","port":"443"}`}, recordedKeys(recent.Records()))
+}
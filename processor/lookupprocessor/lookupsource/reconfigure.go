@@ -0,0 +1,55 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrReconfigureUnsupported is returned by [Reconfigure] for sources that
+// can't apply a configuration in place, or not the changes it makes. Such
+// sources must be recreated to apply it.
+var ErrReconfigureUnsupported = errors.New("source can't be reconfigured in place")
+
+// Reconfigurer is implemented by sources that can apply a new configuration
+// while running, such as swapping their DNS server or reloading another
+// file, keeping their cache where its entries remain valid.
+//
+// Sources created with [NewSource] implement it, returning
+// [ErrReconfigureUnsupported] unless a function is set with
+// [WithReconfigure].
+type Reconfigurer interface {
+	// Reconfigure applies cfg, a configuration of the type of the source,
+	// in place. It returns an error wrapping [ErrReconfigureUnsupported],
+	// and leaves the source unchanged, if cfg changes settings that can't
+	// be applied in place.
+	Reconfigure(ctx context.Context, cfg SourceConfig) error
+}
+
+// ReconfigureFunc applies a new configuration to a running source.
+type ReconfigureFunc func(ctx context.Context, cfg SourceConfig) error
+
+// WithReconfigure sets the function used by the source's Reconfigure method.
+func WithReconfigure(fn ReconfigureFunc) SourceOption {
+	return reconfigureOption{fn: fn}
+}
+
+type reconfigureOption struct {
+	fn ReconfigureFunc
+}
+
+func (o reconfigureOption) apply(s *sourceImpl) {
+	s.reconfigureFn = o.fn
+}
+
+// Reconfigure applies cfg to source in place. It returns
+// [ErrReconfigureUnsupported] if source doesn't implement [Reconfigurer].
+func Reconfigure(ctx context.Context, source Source, cfg SourceConfig) error {
+	r, ok := source.(Reconfigurer)
+	if !ok {
+		return ErrReconfigureUnsupported
+	}
+	return r.Reconfigure(ctx, cfg)
+}
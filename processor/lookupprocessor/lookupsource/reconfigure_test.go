@@ -0,0 +1,49 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type prefixConfig struct {
+	prefix string
+}
+
+func (*prefixConfig) Validate() error { return nil }
+
+func TestReconfigure(t *testing.T) {
+	prefix := "a-"
+	source := NewSource(
+		func(_ context.Context, key string) (any, bool, error) { return prefix + key, true, nil },
+		func() string { return "prefix" },
+		nil,
+		nil,
+		WithReconfigure(func(_ context.Context, cfg SourceConfig) error {
+			prefix = cfg.(*prefixConfig).prefix
+			return nil
+		}),
+	)
+
+	require.NoError(t, Reconfigure(t.Context(), source, &prefixConfig{prefix: "b-"}))
+	val, _, err := source.Lookup(t.Context(), "key")
+	require.NoError(t, err)
+	assert.Equal(t, "b-key", val)
+
+	// Wrappers reconfigure the source they wrap.
+	recent := WithRecentLookups(source, NewRecentLookups(10))
+	require.NoError(t, Reconfigure(t.Context(), recent, &prefixConfig{prefix: "c-"}))
+	val, _, err = source.Lookup(t.Context(), "key")
+	require.NoError(t, err)
+	assert.Equal(t, "c-key", val)
+
+	// Sources without a reconfigure function must be recreated.
+	plain := NewSource(nil, func() string { return "noop" }, nil, nil)
+	assert.ErrorIs(t, Reconfigure(t.Context(), plain, &prefixConfig{}), ErrReconfigureUnsupported)
+	assert.ErrorIs(t, Reconfigure(t.Context(), plainSource{}, &prefixConfig{}), ErrReconfigureUnsupported)
+}
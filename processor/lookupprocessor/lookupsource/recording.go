@@ -0,0 +1,171 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// ErrNotRecorded is returned by lookups replayed with [WithRecording] for
+// keys absent from the recording.
+var ErrNotRecorded = errors.New("key not recorded")
+
+// RecordingMode selects whether [WithRecording] records or replays lookups.
+type RecordingMode string
+
+const (
+	// RecordingModeRecord looks keys up with the wrapped function and
+	// appends each result to the recording.
+	RecordingModeRecord RecordingMode = "record"
+	// RecordingModeReplay answers lookups from the recording only, without
+	// calling the wrapped function.
+	RecordingModeReplay RecordingMode = "replay"
+)
+
+// UnseenKeyAction selects what replayed lookups of keys absent from the
+// recording return.
+type UnseenKeyAction string
+
+const (
+	// UnseenKeyError fails the lookup with an error wrapping
+	// ErrNotRecorded.
+	UnseenKeyError UnseenKeyAction = "error"
+	// UnseenKeyNotFound reports the key as not found.
+	UnseenKeyNotFound UnseenKeyAction = "not_found"
+)
+
+// RecordingConfig configures [WithRecording].
+type RecordingConfig struct {
+	// Mode is record or replay.
+	Mode RecordingMode `mapstructure:"mode"`
+
+	// Path is the file the lookups are recorded to or replayed from. It is
+	// truncated when recording.
+	Path string `mapstructure:"path"`
+
+	// OnUnseenKey selects what replayed lookups of keys absent from the
+	// recording return: error or not_found.
+	// Default: error
+	OnUnseenKey UnseenKeyAction `mapstructure:"on_unseen_key"`
+}
+
+func (c *RecordingConfig) Validate() error {
+	switch c.Mode {
+	case RecordingModeRecord, RecordingModeReplay:
+	default:
+		return fmt.Errorf("invalid recording mode %q, must be %q or %q", c.Mode, RecordingModeRecord, RecordingModeReplay)
+	}
+	if c.Path == "" {
+		return errors.New("path must be specified")
+	}
+	switch c.OnUnseenKey {
+	case "", UnseenKeyError, UnseenKeyNotFound:
+	default:
+		return fmt.Errorf("invalid on_unseen_key %q, must be %q or %q", c.OnUnseenKey, UnseenKeyError, UnseenKeyNotFound)
+	}
+	return nil
+}
+
+// recordedLookup is a line of a recording.
+type recordedLookup struct {
+	Key   string `json:"key"`
+	Value any    `json:"value,omitempty"`
+	Found bool   `json:"found"`
+}
+
+// WithRecording wraps a lookup function to capture the lookups of a live
+// source and play them back later, such as in an integration test or
+// benchmark that mustn't depend on the source being reachable.
+//
+// In record mode every lookup that doesn't fail is appended to cfg.Path as a
+// JSON line holding its key, value and whether it was found; failed lookups
+// are returned but not recorded. In replay mode fn is not called, and may be
+// nil: each key gets the result last recorded for it, with values as
+// decoded from JSON, so numbers are float64 and arrays []any.
+//
+// The returned close function flushes and closes the recording, and must be
+// called once done with the lookup function:
+//
+//	lookup, closeRecording, err := lookupsource.WithRecording(myLookupFunc, lookupsource.RecordingConfig{
+//		Mode: lookupsource.RecordingModeRecord,
+//		Path: "testdata/lookups.ndjson",
+//	})
+func WithRecording(fn LookupFunc, cfg RecordingConfig) (LookupFunc, func() error, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	if cfg.Mode == RecordingModeReplay {
+		return replay(cfg)
+	}
+
+	f, err := os.Create(cfg.Path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create recording: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	var mu sync.Mutex
+	var encodeErr error
+
+	lookup := func(ctx context.Context, key string) (any, bool, error) {
+		val, found, err := fn(ctx, key)
+		if err != nil {
+			return val, found, err
+		}
+		mu.Lock()
+		if encodeErr == nil {
+			encodeErr = enc.Encode(recordedLookup{Key: key, Value: val, Found: found})
+		}
+		mu.Unlock()
+		return val, found, nil
+	}
+	closeRecording := func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		err := encodeErr
+		if err != nil {
+			err = fmt.Errorf("failed to record lookup: %w", err)
+		}
+		return errors.Join(err, w.Flush(), f.Close())
+	}
+	return lookup, closeRecording, nil
+}
+
+// replay returns a lookup function answering from the recording at
+// cfg.Path.
+func replay(cfg RecordingConfig) (LookupFunc, func() error, error) {
+	f, err := os.Open(cfg.Path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open recording: %w", err)
+	}
+	defer f.Close()
+
+	recorded := make(map[string]recordedLookup)
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for line := 1; dec.More(); line++ {
+		var r recordedLookup
+		if err := dec.Decode(&r); err != nil {
+			return nil, nil, fmt.Errorf("invalid recording, lookup %d: %w", line, err)
+		}
+		recorded[r.Key] = r
+	}
+
+	lookup := func(_ context.Context, key string) (any, bool, error) {
+		r, ok := recorded[key]
+		if ok {
+			return r.Value, r.Found, nil
+		}
+		if cfg.OnUnseenKey == UnseenKeyNotFound {
+			return nil, false, nil
+		}
+		return nil, false, Permanent(fmt.Errorf("%w: %q", ErrNotRecorded, key))
+	}
+	return lookup, func() error { return nil }, nil
+}
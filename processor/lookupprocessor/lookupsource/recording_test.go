@@ -0,0 +1,133 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithRecording(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lookups.ndjson")
+	var calls atomic.Int64
+	source := func(_ context.Context, key string) (any, bool, error) {
+		calls.Add(1)
+		switch key {
+		case "10.0.0.1":
+			return "web-1", true, nil
+		case "10.0.0.2":
+			return map[string]any{"name": "web-2", "port": 8080}, true, nil
+		case "10.0.0.3":
+			return nil, false, errors.New("unavailable")
+		default:
+			return nil, false, nil
+		}
+	}
+
+	lookup, closeRecording, err := WithRecording(source, RecordingConfig{Mode: RecordingModeRecord, Path: path})
+	require.NoError(t, err)
+	for _, key := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.9", "10.0.0.3", "10.0.0.1"} {
+		_, _, _ = lookup(t.Context(), key)
+	}
+	require.NoError(t, closeRecording())
+	assert.Equal(t, int64(5), calls.Load())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `{"key":"10.0.0.1","value":"web-1","found":true}
+{"key":"10.0.0.2","value":{"name":"web-2","port":8080},"found":true}
+{"key":"10.0.0.9","found":false}
+{"key":"10.0.0.1","value":"web-1","found":true}
+`, string(data))
+
+	tests := []struct {
+		name      string
+		unseenKey UnseenKeyAction
+		key       string
+		wantVal   any
+		wantFound bool
+		wantErr   error
+	}{
+		{name: "found", key: "10.0.0.1", wantVal: "web-1", wantFound: true},
+		{name: "map", key: "10.0.0.2", wantVal: map[string]any{"name": "web-2", "port": float64(8080)}, wantFound: true},
+		{name: "not found", key: "10.0.0.9"},
+		{name: "failed lookup", key: "10.0.0.3", wantErr: ErrNotRecorded},
+		{name: "unseen key", key: "10.0.0.4", wantErr: ErrNotRecorded},
+		{name: "unseen key not found", unseenKey: UnseenKeyNotFound, key: "10.0.0.4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replayed, closeReplay, err := WithRecording(nil, RecordingConfig{Mode: RecordingModeReplay, Path: path, OnUnseenKey: tt.unseenKey})
+			require.NoError(t, err)
+			defer func() { require.NoError(t, closeReplay()) }()
+
+			val, found, err := replayed(t.Context(), tt.key)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				assert.True(t, IsPermanent(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantFound, found)
+			assert.Equal(t, tt.wantVal, val)
+		})
+	}
+	assert.Equal(t, int64(5), calls.Load())
+}
+
+func TestWithRecordingErrors(t *testing.T) {
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "invalid.ndjson")
+	require.NoError(t, os.WriteFile(invalid, []byte("{\"key\":\"a\",\"found\":false}\nnot json\n"), 0o600))
+
+	tests := []struct {
+		name    string
+		cfg     RecordingConfig
+		wantErr string
+	}{
+		{
+			name:    "invalid mode",
+			cfg:     RecordingConfig{Mode: "rewind", Path: invalid},
+			wantErr: `invalid recording mode "rewind"`,
+		},
+		{
+			name:    "no path",
+			cfg:     RecordingConfig{Mode: RecordingModeReplay},
+			wantErr: "path must be specified",
+		},
+		{
+			name:    "invalid unseen key action",
+			cfg:     RecordingConfig{Mode: RecordingModeReplay, Path: invalid, OnUnseenKey: "guess"},
+			wantErr: `invalid on_unseen_key "guess"`,
+		},
+		{
+			name:    "missing recording",
+			cfg:     RecordingConfig{Mode: RecordingModeReplay, Path: filepath.Join(dir, "missing.ndjson")},
+			wantErr: "failed to open recording",
+		},
+		{
+			name:    "invalid recording",
+			cfg:     RecordingConfig{Mode: RecordingModeReplay, Path: invalid},
+			wantErr: "invalid recording, lookup 2",
+		},
+		{
+			name:    "unwritable recording",
+			cfg:     RecordingConfig{Mode: RecordingModeRecord, Path: filepath.Join(dir, "missing", "lookups.ndjson")},
+			wantErr: "failed to create recording",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := WithRecording(nil, tt.cfg)
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// RefreshSchedulerConfig configures a [RefreshScheduler].
+type RefreshSchedulerConfig struct {
+	// Interval is how often scheduled refreshes are started.
+	// Default: 1s
+	Interval time.Duration `mapstructure:"interval"`
+
+	// Budget is the number of refreshes started per interval. Further
+	// scheduled keys wait for the next intervals.
+	// Default: 100
+	Budget int `mapstructure:"budget"`
+
+	// MaxConcurrency is the number of refreshes running at once, including
+	// those started in previous intervals that haven't completed yet.
+	// Default: Budget
+	MaxConcurrency int `mapstructure:"max_concurrency"`
+
+	// Jitter is the longest random delay of each refresh.
+	// Default: 0
+	Jitter time.Duration `mapstructure:"jitter"`
+}
+
+func (c *RefreshSchedulerConfig) Validate() error {
+	if c.Interval < 0 {
+		return errors.New("interval must not be negative")
+	}
+	if c.Budget < 0 {
+		return errors.New("budget must not be negative")
+	}
+	if c.MaxConcurrency < 0 {
+		return errors.New("max_concurrency must not be negative")
+	}
+	if c.Jitter < 0 {
+		return errors.New("jitter must not be negative")
+	}
+	return nil
+}
+
+const (
+	defaultRefreshInterval = time.Second
+	defaultRefreshBudget   = 100
+)
+
+// RefreshScheduler refreshes cache entries in the background at a bounded
+// rate, so that many entries due at about the same time, such as the
+// thousands of entries of a cache filled at startup, don't all hit the
+// source at once.
+//
+// Keys are looked up again with the function given to [NewRefreshScheduler]
+// and a context returned by [WithCacheBypass], so a function wrapped with
+// [WrapWithCache] replaces the entry of each refreshed key. A key is
+// refreshed once however many times it is scheduled before its refresh
+// completes.
+type RefreshScheduler struct {
+	cfg RefreshSchedulerConfig
+	fn  LookupFunc
+
+	mu sync.Mutex
+	// queue holds the scheduled keys not started yet, oldest first.
+	queue []string
+	// scheduled holds the keys of queue and of the running refreshes.
+	scheduled map[string]struct{}
+	running   int
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewRefreshScheduler returns a scheduler refreshing keys with fn, and starts
+// it. Zero fields of cfg take their defaults. [RefreshScheduler.Shutdown]
+// stops it.
+//
+//	lookup := lookupsource.WrapWithCache(cache, myLookupFunc)
+//	scheduler := lookupsource.NewRefreshScheduler(lookupsource.RefreshSchedulerConfig{Budget: 50}, lookup)
+//	scheduler.Schedule(key)
+func NewRefreshScheduler(cfg RefreshSchedulerConfig, fn LookupFunc) *RefreshScheduler {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultRefreshInterval
+	}
+	if cfg.Budget <= 0 {
+		cfg.Budget = defaultRefreshBudget
+	}
+	if cfg.MaxConcurrency <= 0 {
+		cfg.MaxConcurrency = cfg.Budget
+	}
+	s := &RefreshScheduler{
+		cfg:       cfg,
+		fn:        fn,
+		scheduled: make(map[string]struct{}),
+	}
+
+	var ctx context.Context
+	ctx, s.cancel = context.WithCancel(context.Background())
+	s.wg.Add(1)
+	go s.run(ctx)
+	return s
+}
+
+// Schedule schedules a refresh of key, and reports whether it did: keys
+// already waiting for or undergoing a refresh aren't scheduled again.
+func (s *RefreshScheduler) Schedule(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.scheduled[key]; ok {
+		return false
+	}
+	s.scheduled[key] = struct{}{}
+	s.queue = append(s.queue, key)
+	return true
+}
+
+// Pending returns the number of scheduled keys whose refresh hasn't started.
+func (s *RefreshScheduler) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.queue)
+}
+
+// Shutdown stops the scheduler, cancelling the running refreshes and
+// dropping the keys still waiting, and waits for the refreshes to return.
+func (s *RefreshScheduler) Shutdown(_ context.Context) error {
+	if s.cancel == nil {
+		return nil
+	}
+	s.cancel()
+	s.wg.Wait()
+	s.cancel = nil
+	return nil
+}
+
+func (s *RefreshScheduler) run(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, key := range s.next() {
+				s.wg.Add(1)
+				go s.refresh(ctx, key)
+			}
+		}
+	}
+}
+
+// next dequeues the keys to refresh in this interval, within both the budget
+// and the free concurrency.
+func (s *RefreshScheduler) next() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := min(len(s.queue), s.cfg.Budget, s.cfg.MaxConcurrency-s.running)
+	if n <= 0 {
+		return nil
+	}
+	keys := s.queue[:n:n]
+	s.queue = s.queue[n:]
+	s.running += n
+	return keys
+}
+
+func (s *RefreshScheduler) refresh(ctx context.Context, key string) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.scheduled, key)
+		s.running--
+		s.mu.Unlock()
+	}()
+
+	if s.cfg.Jitter > 0 {
+		timer := time.NewTimer(rand.N(s.cfg.Jitter))
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+	}
+	// A failed refresh leaves the entry as it is, and the key can be
+	// scheduled again.
+	_, _, _ = s.fn(WithCacheBypass(ctx), key)
+}
@@ -0,0 +1,182 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefreshSchedulerConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     RefreshSchedulerConfig
+		wantErr string
+	}{
+		{
+			name: "defaults",
+		},
+		{
+			name:    "negative interval",
+			cfg:     RefreshSchedulerConfig{Interval: -1},
+			wantErr: "interval must not be negative",
+		},
+		{
+			name:    "negative budget",
+			cfg:     RefreshSchedulerConfig{Budget: -1},
+			wantErr: "budget must not be negative",
+		},
+		{
+			name:    "negative max concurrency",
+			cfg:     RefreshSchedulerConfig{MaxConcurrency: -1},
+			wantErr: "max_concurrency must not be negative",
+		},
+		{
+			name:    "negative jitter",
+			cfg:     RefreshSchedulerConfig{Jitter: -1},
+			wantErr: "jitter must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestRefreshSchedulerBudget(t *testing.T) {
+	const budget = 5
+	var running, maxRunning, refreshed atomic.Int64
+	fn := func(ctx context.Context, _ string) (any, bool, error) {
+		assert.True(t, IsCacheBypassed(ctx))
+		n := running.Add(1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		// Outlive a few intervals, so that later intervals find
+		// refreshes still running.
+		time.Sleep(5 * time.Millisecond)
+		running.Add(-1)
+		refreshed.Add(1)
+		return "v", true, nil
+	}
+
+	scheduler := NewRefreshScheduler(RefreshSchedulerConfig{
+		Interval: time.Millisecond,
+		Budget:   budget,
+		Jitter:   time.Millisecond,
+	}, fn)
+	defer func() { require.NoError(t, scheduler.Shutdown(t.Context())) }()
+
+	for i := range 200 {
+		require.True(t, scheduler.Schedule(strconv.Itoa(i)))
+	}
+	require.Eventually(t, func() bool { return refreshed.Load() == 200 }, 10*time.Second, time.Millisecond)
+	assert.LessOrEqual(t, maxRunning.Load(), int64(budget))
+	assert.Zero(t, scheduler.Pending())
+}
+
+func TestRefreshSchedulerNext(t *testing.T) {
+	// A long interval keeps the scheduler from starting refreshes, so that
+	// next can be called directly.
+	scheduler := NewRefreshScheduler(RefreshSchedulerConfig{
+		Interval:       time.Hour,
+		Budget:         3,
+		MaxConcurrency: 4,
+	}, func(context.Context, string) (any, bool, error) { return nil, false, nil })
+	defer func() { require.NoError(t, scheduler.Shutdown(t.Context())) }()
+
+	for i := range 10 {
+		scheduler.Schedule(strconv.Itoa(i))
+	}
+	assert.Equal(t, []string{"0", "1", "2"}, scheduler.next())
+	// One refresh is still running, so only one more can start.
+	assert.Equal(t, []string{"3"}, scheduler.next())
+	assert.Empty(t, scheduler.next())
+	assert.Equal(t, 6, scheduler.Pending())
+}
+
+func TestRefreshSchedulerDeduplicates(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	calls := map[string]int{}
+	fn := func(_ context.Context, key string) (any, bool, error) {
+		mu.Lock()
+		calls[key]++
+		mu.Unlock()
+		<-release
+		return "v", true, nil
+	}
+	scheduler := NewRefreshScheduler(RefreshSchedulerConfig{Interval: time.Millisecond}, fn)
+	defer func() { require.NoError(t, scheduler.Shutdown(t.Context())) }()
+
+	assert.True(t, scheduler.Schedule("a"))
+	assert.False(t, scheduler.Schedule("a"), "waiting key scheduled again")
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls["a"] == 1
+	}, 5*time.Second, time.Millisecond)
+	assert.False(t, scheduler.Schedule("a"), "running key scheduled again")
+
+	close(release)
+	require.Eventually(t, func() bool { return scheduler.Schedule("a") }, 5*time.Second, time.Millisecond)
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls["a"] == 2
+	}, 5*time.Second, time.Millisecond)
+}
+
+func TestRefreshSchedulerRefreshesCache(t *testing.T) {
+	cache := NewCache(CacheConfig{Enabled: true, Size: 10})
+	var version atomic.Int64
+	lookup := WrapWithCache(cache, func(_ context.Context, key string) (any, bool, error) {
+		return key + "-" + strconv.FormatInt(version.Load(), 10), true, nil
+	})
+	val, _, err := lookup(t.Context(), "a")
+	require.NoError(t, err)
+	assert.Equal(t, "a-0", val)
+
+	scheduler := NewRefreshScheduler(RefreshSchedulerConfig{Interval: time.Millisecond}, lookup)
+	defer func() { require.NoError(t, scheduler.Shutdown(t.Context())) }()
+
+	version.Store(1)
+	scheduler.Schedule("a")
+	require.Eventually(t, func() bool {
+		val, _ := cache.Get("a")
+		return val == "a-1"
+	}, 5*time.Second, time.Millisecond)
+}
+
+func TestRefreshSchedulerShutdown(t *testing.T) {
+	started := make(chan struct{})
+	scheduler := NewRefreshScheduler(RefreshSchedulerConfig{Interval: time.Millisecond}, func(ctx context.Context, _ string) (any, bool, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, false, ctx.Err()
+	})
+	scheduler.Schedule("a")
+	<-started
+
+	// Shutdown cancels the running refresh and waits for it.
+	require.NoError(t, scheduler.Shutdown(t.Context()))
+	require.NoError(t, scheduler.Shutdown(t.Context()))
+}
@@ -0,0 +1,64 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Registry maps source type identifiers to their factories.
+//
+// Use [NewRegistry] to create instances.
+type Registry struct {
+	mu        sync.RWMutex
+	factories map[string]SourceFactory
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{factories: make(map[string]SourceFactory)}
+}
+
+// Register adds a factory to the registry.
+// It returns an error if a factory for the same type is already registered.
+func (r *Registry) Register(factory SourceFactory) error {
+	if factory == nil {
+		return errors.New("source factory must not be nil")
+	}
+	typ := factory.Type()
+	if typ == "" {
+		return errors.New("source factory type must not be empty")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.factories[typ]; exists {
+		return fmt.Errorf("source type %q is already registered", typ)
+	}
+	r.factories[typ] = factory
+	return nil
+}
+
+// Get returns the factory registered for the given type.
+func (r *Registry) Get(typ string) (SourceFactory, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	factory, ok := r.factories[typ]
+	return factory, ok
+}
+
+// Types returns the registered source types in sorted order.
+func (r *Registry) Types() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	types := make([]string, 0, len(r.factories))
+	for typ := range r.factories {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
@@ -0,0 +1,55 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestFactory(typ string) SourceFactory {
+	return NewSourceFactory(typ, nil, nil)
+}
+
+func TestRegistryRegisterAndGet(t *testing.T) {
+	r := NewRegistry()
+	for _, typ := range []string{"b", "a", "c"} {
+		require.NoError(t, r.Register(newTestFactory(typ)))
+	}
+
+	for _, typ := range []string{"a", "b", "c"} {
+		factory, ok := r.Get(typ)
+		require.True(t, ok, typ)
+		assert.Equal(t, typ, factory.Type())
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, r.Types())
+}
+
+func TestRegistryUnknownType(t *testing.T) {
+	r := NewRegistry()
+	require.NoError(t, r.Register(newTestFactory("a")))
+
+	factory, ok := r.Get("missing")
+	assert.False(t, ok)
+	assert.Nil(t, factory)
+}
+
+func TestRegistryDuplicate(t *testing.T) {
+	r := NewRegistry()
+	require.NoError(t, r.Register(newTestFactory("a")))
+
+	err := r.Register(newTestFactory("a"))
+	assert.EqualError(t, err, `source type "a" is already registered`)
+	assert.Equal(t, []string{"a"}, r.Types())
+}
+
+func TestRegistryInvalidFactory(t *testing.T) {
+	r := NewRegistry()
+
+	assert.Error(t, r.Register(nil))
+	assert.Error(t, r.Register(newTestFactory("")))
+	assert.Empty(t, r.Types())
+}
@@ -0,0 +1,113 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryConfig controls how [WithRetry] retries failed lookups.
+type RetryConfig struct {
+	// MaxAttempts is the total number of calls, including the first one.
+	// Values below 2 disable retries.
+	// Default: 3
+	MaxAttempts int `mapstructure:"max_attempts"`
+
+	// InitialBackoff is the wait before the first retry.
+	// Default: 100ms
+	InitialBackoff time.Duration `mapstructure:"initial_backoff"`
+
+	// MaxBackoff caps the wait between retries.
+	// Default: 2s
+	MaxBackoff time.Duration `mapstructure:"max_backoff"`
+
+	// Multiplier grows the backoff after each retry.
+	// Default: 2
+	Multiplier float64 `mapstructure:"multiplier"`
+
+	// Retryable reports whether an error should be retried.
+	// If nil, all errors are retried except those marked with [Permanent].
+	Retryable func(error) bool `mapstructure:"-"`
+}
+
+func NewDefaultRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxAttempts:    3,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     2 * time.Second,
+		Multiplier:     2,
+	}
+}
+
+// WithRetry wraps a lookup function so failed lookups are retried with
+// exponential backoff and jitter. A lookup that returns found=false without
+// an error is a valid answer and is never retried.
+//
+// Retrying stops early when ctx is done, or when the next backoff would end
+// after the ctx deadline; the last lookup error is returned in that case.
+//
+// Example:
+//
+//	lookup := lookupsource.WithRetry(myLookupFunc, lookupsource.NewDefaultRetryConfig())
+//	cachedLookup := lookupsource.WrapWithCache(cache, lookup)
+func WithRetry(fn LookupFunc, cfg RetryConfig) LookupFunc {
+	if cfg.MaxAttempts < 2 {
+		return fn
+	}
+	return func(ctx context.Context, key string) (any, bool, error) {
+		backoff := cfg.InitialBackoff
+		for attempt := 1; ; attempt++ {
+			val, found, err := fn(ctx, key)
+			if err == nil {
+				return val, found, nil
+			}
+			if attempt >= cfg.MaxAttempts || !cfg.retryable(err) {
+				return nil, false, err
+			}
+
+			wait := jitter(backoff)
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+				return nil, false, err
+			}
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, false, err
+			case <-timer.C:
+			}
+
+			backoff = nextBackoff(backoff, cfg)
+		}
+	}
+}
+
+func (cfg RetryConfig) retryable(err error) bool {
+	if cfg.Retryable != nil {
+		return cfg.Retryable(err)
+	}
+	return !IsPermanent(err)
+}
+
+func nextBackoff(backoff time.Duration, cfg RetryConfig) time.Duration {
+	if cfg.Multiplier > 1 {
+		backoff = time.Duration(float64(backoff) * cfg.Multiplier)
+	}
+	if cfg.MaxBackoff > 0 && backoff > cfg.MaxBackoff {
+		backoff = cfg.MaxBackoff
+	}
+	return backoff
+}
+
+// jitter returns a random duration in [d/2, d), so concurrent callers
+// retrying the same upstream don't do so in lockstep.
+func jitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+	half := d / 2
+	return half + rand.N(d-half)
+}
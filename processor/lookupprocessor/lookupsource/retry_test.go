@@ -0,0 +1,149 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     2 * time.Millisecond,
+		Multiplier:     2,
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	errTemporary := errors.New("temporary")
+
+	t.Run("success after retries", func(t *testing.T) {
+		calls := 0
+		lookup := WithRetry(func(context.Context, string) (any, bool, error) {
+			calls++
+			if calls < 3 {
+				return nil, false, errTemporary
+			}
+			return "value", true, nil
+		}, testRetryConfig())
+
+		val, found, err := lookup(t.Context(), "key")
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "value", val)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("not found is not retried", func(t *testing.T) {
+		calls := 0
+		lookup := WithRetry(func(context.Context, string) (any, bool, error) {
+			calls++
+			return nil, false, nil
+		}, testRetryConfig())
+
+		_, found, err := lookup(t.Context(), "key")
+		require.NoError(t, err)
+		assert.False(t, found)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("exhaustion returns last error", func(t *testing.T) {
+		calls := 0
+		lookup := WithRetry(func(context.Context, string) (any, bool, error) {
+			calls++
+			return nil, false, fmt.Errorf("attempt %d: %w", calls, errTemporary)
+		}, testRetryConfig())
+
+		_, _, err := lookup(t.Context(), "key")
+		assert.EqualError(t, err, "attempt 3: temporary")
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("non-retryable error", func(t *testing.T) {
+		errPermanent := errors.New("permanent")
+		cfg := testRetryConfig()
+		cfg.Retryable = func(err error) bool { return !errors.Is(err, errPermanent) }
+
+		calls := 0
+		lookup := WithRetry(func(context.Context, string) (any, bool, error) {
+			calls++
+			return nil, false, errPermanent
+		}, cfg)
+
+		_, _, err := lookup(t.Context(), "key")
+		assert.ErrorIs(t, err, errPermanent)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("permanent error", func(t *testing.T) {
+		calls := 0
+		lookup := WithRetry(func(context.Context, string) (any, bool, error) {
+			calls++
+			return nil, false, Permanent(errTemporary)
+		}, testRetryConfig())
+
+		_, _, err := lookup(t.Context(), "key")
+		assert.ErrorIs(t, err, errTemporary)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("context cancellation aborts", func(t *testing.T) {
+		cfg := testRetryConfig()
+		cfg.MaxAttempts = 10
+		cfg.InitialBackoff = time.Hour
+		cfg.MaxBackoff = time.Hour
+
+		ctx, cancel := context.WithCancel(t.Context())
+		calls := 0
+		lookup := WithRetry(func(context.Context, string) (any, bool, error) {
+			calls++
+			cancel()
+			return nil, false, errTemporary
+		}, cfg)
+
+		_, _, err := lookup(ctx, "key")
+		assert.ErrorIs(t, err, errTemporary)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("deadline before next backoff", func(t *testing.T) {
+		cfg := testRetryConfig()
+		cfg.InitialBackoff = time.Hour
+		cfg.MaxBackoff = time.Hour
+
+		ctx, cancel := context.WithTimeout(t.Context(), time.Minute)
+		defer cancel()
+		calls := 0
+		lookup := WithRetry(func(context.Context, string) (any, bool, error) {
+			calls++
+			return nil, false, errTemporary
+		}, cfg)
+
+		_, _, err := lookup(ctx, "key")
+		assert.ErrorIs(t, err, errTemporary)
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func TestNextBackoff(t *testing.T) {
+	cfg := RetryConfig{Multiplier: 2, MaxBackoff: 300 * time.Millisecond}
+	assert.Equal(t, 200*time.Millisecond, nextBackoff(100*time.Millisecond, cfg))
+	assert.Equal(t, 300*time.Millisecond, nextBackoff(200*time.Millisecond, cfg))
+}
+
+func TestJitter(t *testing.T) {
+	for range 100 {
+		d := jitter(100 * time.Millisecond)
+		assert.GreaterOrEqual(t, d, 50*time.Millisecond)
+		assert.Less(t, d, 100*time.Millisecond)
+	}
+}
@@ -14,6 +14,12 @@ import (
 //   - If found=true, value contains the lookup result
 //   - If found=false, the key was not found (not an error)
 //   - If error!=nil, the lookup failed
+//
+// A key that is present with an empty value, such as an empty string or a
+// JSON null, is found: the processor writes the empty value to the target
+// attribute, and caches store it like any other value. Only found=false
+// applies default_value or skips the record, and is cached as a miss with
+// [WithNegativeCacheTTL] or [WithTieredNegativeTTL].
 type LookupFunc func(ctx context.Context, key string) (any, bool, error)
 
 type TypeFunc func() string
@@ -71,6 +77,14 @@ func NewSource(
 	for _, opt := range opts {
 		opt.apply(s)
 	}
+	switch {
+	case s.batchFn != nil && s.multiKeyFn != nil:
+		return &batchMultiKeySourceImpl{batchSourceImpl: &batchSourceImpl{sourceImpl: s}}
+	case s.batchFn != nil:
+		return &batchSourceImpl{sourceImpl: s}
+	case s.multiKeyFn != nil:
+		return &multiKeySourceImpl{sourceImpl: s}
+	}
 	return s
 }
 
@@ -79,13 +93,26 @@ type sourceImpl struct {
 	typeFn     TypeFunc
 	startFn    StartFunc
 	shutdownFn ShutdownFunc
+	batchFn    BatchLookupFunc
+	multiKeyFn MultiKeyLookupFunc
+	healthFn   HealthCheckFunc
+	readyFn    ReadyFunc
+	// reconfigureFn is the function of WithReconfigure, if any.
+	reconfigureFn ReconfigureFunc
+	cache         *Cache
+	keyFn         func(string) string
+	storeFn       StoreFunc
+	// changes are the changes of WithChanges, if any.
+	changes *Changes
+	// delegate is the source wrapped by the source, if any.
+	delegate Source
 }
 
 func (s *sourceImpl) Lookup(ctx context.Context, key string) (any, bool, error) {
 	if s.lookupFn == nil {
 		return nil, false, nil
 	}
-	return s.lookupFn(ctx, key)
+	return s.lookupFn(ctx, s.canonicalKey(key))
 }
 
 func (s *sourceImpl) Type() string {
@@ -108,3 +135,70 @@ func (s *sourceImpl) Shutdown(ctx context.Context) error {
 	}
 	return s.shutdownFn(ctx)
 }
+
+func (s *sourceImpl) CacheStats() (CacheStats, bool) {
+	if s.cache == nil {
+		if r, ok := s.delegate.(CacheStatsReporter); ok {
+			return r.CacheStats()
+		}
+		return CacheStats{}, false
+	}
+	if !s.cache.config.Enabled {
+		return CacheStats{}, false
+	}
+	return s.cache.Stats(), true
+}
+
+func (s *sourceImpl) KeyFunc() func(string) string {
+	if s.keyFn == nil {
+		if s.delegate != nil {
+			return KeyFunc(s.delegate)
+		}
+		return identityKey
+	}
+	return s.keyFn
+}
+
+// canonicalKey returns key mapped by the function set with WithKeyFunc.
+func (s *sourceImpl) canonicalKey(key string) string {
+	if s.keyFn == nil {
+		return key
+	}
+	return s.keyFn(key)
+}
+
+func (s *sourceImpl) StoreFunc() StoreFunc {
+	return s.storeFn
+}
+
+func (s *sourceImpl) CheckHealth(ctx context.Context) error {
+	if s.healthFn == nil {
+		return nil
+	}
+	return s.healthFn(ctx)
+}
+
+func (s *sourceImpl) OnChange(fn func()) {
+	if s.changes != nil {
+		s.changes.OnChange(fn)
+	}
+	if s.cache != nil {
+		s.cache.OnChange(fn)
+	} else if n, ok := s.delegate.(ChangeNotifier); ok {
+		n.OnChange(fn)
+	}
+}
+
+func (s *sourceImpl) Ready(ctx context.Context) error {
+	if s.readyFn == nil {
+		return nil
+	}
+	return s.readyFn(ctx)
+}
+
+func (s *sourceImpl) Reconfigure(ctx context.Context, cfg SourceConfig) error {
+	if s.reconfigureFn == nil {
+		return ErrReconfigureUnsupported
+	}
+	return s.reconfigureFn(ctx, cfg)
+}
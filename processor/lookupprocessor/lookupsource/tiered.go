@@ -0,0 +1,174 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+
+import (
+	"context"
+	"time"
+)
+
+// NotFoundMarker is the value stored in the second tier of
+// [NewTieredCache] for keys the origin doesn't find. It is a string so that
+// tiers storing strings, such as Redis or memcached, can hold it.
+const NotFoundMarker = "\x00lookup:not_found"
+
+// StoreFunc stores value for key in a source, such as a shared cache, with
+// the time-to-live ttl. A ttl of zero means the default of the source.
+type StoreFunc func(ctx context.Context, key string, value any, ttl time.Duration) error
+
+// StoreFuncProvider is implemented by sources that can store values, so that
+// they can be populated as the second tier of [NewTieredCache].
+//
+// Sources created with [NewSource] implement it, returning the function set
+// with [WithStore] or nil.
+type StoreFuncProvider interface {
+	StoreFunc() StoreFunc
+}
+
+// WithStore sets the function used by the source's StoreFunc method.
+func WithStore(fn StoreFunc) SourceOption {
+	return storeOption{fn: fn}
+}
+
+type storeOption struct {
+	fn StoreFunc
+}
+
+func (o storeOption) apply(s *sourceImpl) {
+	s.storeFn = o.fn
+}
+
+// TieredCacheOption configures [NewTieredCache].
+type TieredCacheOption interface {
+	applyTiered(*tieredConfig)
+}
+
+type tieredConfig struct {
+	l2TTL         time.Duration
+	l1NegativeTTL time.Duration
+	l2NegativeTTL time.Duration
+}
+
+// WithL2TTL sets the time-to-live of the values stored in the second tier.
+// Zero, the default, uses the default of the tier.
+func WithL2TTL(ttl time.Duration) TieredCacheOption {
+	return l2TTLOption(ttl)
+}
+
+type l2TTLOption time.Duration
+
+func (o l2TTLOption) applyTiered(cfg *tieredConfig) {
+	cfg.l2TTL = time.Duration(o)
+}
+
+// WithTieredNegativeTTL caches keys the origin doesn't find for l1 in the
+// first tier and for l2 in the second, so that unknown keys don't reach the
+// origin on every lookup. Zero, the default, doesn't cache them in that tier.
+// Keys the origin finds with an empty value, such as an empty string, are not
+// misses: both tiers store the empty value. It is the tiered counterpart of
+// [WithNegativeCacheTTL].
+func WithTieredNegativeTTL(l1, l2 time.Duration) TieredCacheOption {
+	return negativeTTLOption{l1: l1, l2: l2}
+}
+
+type negativeTTLOption struct {
+	l1, l2 time.Duration
+}
+
+func (o negativeTTLOption) applyTiered(cfg *tieredConfig) {
+	cfg.l1NegativeTTL = o.l1
+	cfg.l2NegativeTTL = o.l2
+}
+
+// notFoundEntry is the value cached in the first tier for keys the origin
+// doesn't find.
+type notFoundEntry struct{}
+
+// NewTieredCache wraps origin with two cache tiers: l1, a local in-memory
+// cache, and l2, a source shared by several collectors, such as Redis or
+// memcached. Keys are looked up in l1, then l2, then origin, and the tiers
+// that missed are populated with the result: l1 with its configured TTL,
+// and l2, if it implements [StoreFuncProvider], with the TTL set with
+// [WithL2TTL]. Keys that are not found are cached as well with
+// [WithTieredNegativeTTL]; l2 holds them as [NotFoundMarker]. A nil l1 or l2
+// skips that tier.
+//
+// The second tier is an optimization, so its failures don't fail lookups:
+// errors reading it are treated as misses and errors storing to it are
+// ignored. Errors of origin are returned and nothing is cached. Contexts
+// returned by [WithCacheBypass] skip reading both tiers.
+//
+// Example:
+//
+//	l1 := lookupsource.NewCache(lookupsource.CacheConfig{Enabled: true, TTL: time.Minute})
+//	lookup := lookupsource.NewTieredCache(l1, redisSource, myLookupFunc,
+//	    lookupsource.WithL2TTL(time.Hour),
+//	    lookupsource.WithTieredNegativeTTL(10*time.Second, time.Minute))
+func NewTieredCache(l1 *Cache, l2 Source, origin LookupFunc, opts ...TieredCacheOption) LookupFunc {
+	var cfg tieredConfig
+	for _, opt := range opts {
+		opt.applyTiered(&cfg)
+	}
+	if l1 != nil && !l1.config.Enabled {
+		l1 = nil
+	}
+	var store StoreFunc
+	if p, ok := l2.(StoreFuncProvider); ok {
+		store = p.StoreFunc()
+	}
+
+	return func(ctx context.Context, key string) (any, bool, error) {
+		bypass := IsCacheBypassed(ctx)
+		if l1 != nil && !bypass {
+			if val, found := l1.Get(key); found {
+				if _, notFound := val.(notFoundEntry); notFound {
+					return nil, false, nil
+				}
+				return val, true, nil
+			}
+		}
+
+		if l2 != nil && !bypass {
+			if val, found, err := l2.Lookup(ctx, key); err == nil && found {
+				if val == NotFoundMarker {
+					if l1 != nil && cfg.l1NegativeTTL > 0 {
+						l1.SetWithTTL(key, notFoundEntry{}, cfg.l1NegativeTTL)
+					}
+					return nil, false, nil
+				}
+				if l1 != nil {
+					l1.Set(key, val)
+				}
+				return val, true, nil
+			}
+		}
+
+		val, found, err := origin(ctx, key)
+		if err != nil {
+			return nil, false, err
+		}
+		if found {
+			if l1 != nil {
+				l1.Set(key, val)
+			}
+			if store != nil {
+				_ = store(ctx, key, val, cfg.l2TTL)
+			}
+			return val, true, nil
+		}
+
+		if l1 != nil {
+			if cfg.l1NegativeTTL > 0 {
+				l1.SetWithTTL(key, notFoundEntry{}, cfg.l1NegativeTTL)
+			} else if bypass {
+				// A refreshed key that no longer exists mustn't keep its entry.
+				l1.Delete(key)
+			}
+		}
+		if store != nil && cfg.l2NegativeTTL > 0 {
+			_ = store(ctx, key, NotFoundMarker, cfg.l2NegativeTTL)
+		}
+		return nil, false, nil
+	}
+}
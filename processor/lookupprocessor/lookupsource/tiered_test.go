@@ -0,0 +1,263 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTier is a shared cache used as the second tier, recording its lookups
+// and the TTLs of the values stored.
+type fakeTier struct {
+	mu      sync.Mutex
+	entries map[string]any
+	ttls    map[string]time.Duration
+	lookups int
+	err     error
+}
+
+func newFakeTier() *fakeTier {
+	return &fakeTier{entries: map[string]any{}, ttls: map[string]time.Duration{}}
+}
+
+func (f *fakeTier) source() Source {
+	return NewSource(
+		func(_ context.Context, key string) (any, bool, error) {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			f.lookups++
+			if f.err != nil {
+				return nil, false, f.err
+			}
+			val, ok := f.entries[key]
+			return val, ok, nil
+		},
+		func() string { return "fake" },
+		nil,
+		nil,
+		WithStore(func(_ context.Context, key string, value any, ttl time.Duration) error {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			f.entries[key] = value
+			f.ttls[key] = ttl
+			return nil
+		}),
+	)
+}
+
+// countingOrigin returns a lookup function finding the keys of entries,
+// with the number of calls made to it.
+func countingOrigin(entries map[string]any) (LookupFunc, *int) {
+	var calls int
+	return func(_ context.Context, key string) (any, bool, error) {
+		calls++
+		val, ok := entries[key]
+		return val, ok, nil
+	}, &calls
+}
+
+func TestTieredCache(t *testing.T) {
+	t.Run("L1 hit avoids L2", func(t *testing.T) {
+		l1 := NewCache(CacheConfig{Enabled: true})
+		l1.Set("10.0.0.1", "web-1")
+		l2 := newFakeTier()
+		origin, calls := countingOrigin(nil)
+		lookup := NewTieredCache(l1, l2.source(), origin)
+
+		val, found, err := lookup(t.Context(), "10.0.0.1")
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "web-1", val)
+		assert.Zero(t, l2.lookups)
+		assert.Zero(t, *calls)
+	})
+
+	t.Run("L2 hit avoids origin", func(t *testing.T) {
+		l1 := NewCache(CacheConfig{Enabled: true})
+		l2 := newFakeTier()
+		l2.entries["10.0.0.1"] = "web-1"
+		origin, calls := countingOrigin(nil)
+		lookup := NewTieredCache(l1, l2.source(), origin)
+
+		val, found, err := lookup(t.Context(), "10.0.0.1")
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "web-1", val)
+		assert.Zero(t, *calls)
+
+		// L1 was populated from L2.
+		val, found = l1.Get("10.0.0.1")
+		assert.True(t, found)
+		assert.Equal(t, "web-1", val)
+	})
+
+	t.Run("full miss populates both tiers", func(t *testing.T) {
+		l1 := NewCache(CacheConfig{Enabled: true})
+		l2 := newFakeTier()
+		origin, calls := countingOrigin(map[string]any{"10.0.0.1": "web-1"})
+		lookup := NewTieredCache(l1, l2.source(), origin, WithL2TTL(time.Hour))
+
+		val, found, err := lookup(t.Context(), "10.0.0.1")
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "web-1", val)
+		assert.Equal(t, 1, *calls)
+
+		val, found = l1.Get("10.0.0.1")
+		assert.True(t, found)
+		assert.Equal(t, "web-1", val)
+		assert.Equal(t, "web-1", l2.entries["10.0.0.1"])
+		assert.Equal(t, time.Hour, l2.ttls["10.0.0.1"])
+
+		// Later lookups are answered by L1.
+		_, _, err = lookup(t.Context(), "10.0.0.1")
+		require.NoError(t, err)
+		assert.Equal(t, 1, *calls)
+		assert.Equal(t, 1, l2.lookups)
+	})
+}
+
+func TestTieredCacheNegative(t *testing.T) {
+	l1 := NewCache(CacheConfig{Enabled: true})
+	now := time.Now()
+	l1.now = func() time.Time { return now }
+	l2 := newFakeTier()
+	origin, calls := countingOrigin(nil)
+	lookup := NewTieredCache(l1, l2.source(), origin, WithTieredNegativeTTL(10*time.Second, time.Minute))
+
+	_, found, err := lookup(t.Context(), "10.0.0.9")
+	require.NoError(t, err)
+	assert.False(t, found)
+	assert.Equal(t, 1, *calls)
+	assert.Equal(t, NotFoundMarker, l2.entries["10.0.0.9"])
+	assert.Equal(t, time.Minute, l2.ttls["10.0.0.9"])
+
+	// The negative result is answered by L1 until its TTL expires.
+	_, found, err = lookup(t.Context(), "10.0.0.9")
+	require.NoError(t, err)
+	assert.False(t, found)
+	assert.Equal(t, 1, l2.lookups)
+
+	// Then by L2, which populates L1 again.
+	now = now.Add(10 * time.Second)
+	_, found, err = lookup(t.Context(), "10.0.0.9")
+	require.NoError(t, err)
+	assert.False(t, found)
+	assert.Equal(t, 2, l2.lookups)
+	assert.Equal(t, 1, *calls)
+	val, found := l1.Get("10.0.0.9")
+	assert.True(t, found)
+	assert.Equal(t, notFoundEntry{}, val)
+}
+
+func TestTieredCacheEmptyValue(t *testing.T) {
+	l1 := NewCache(CacheConfig{Enabled: true})
+	l2 := newFakeTier()
+	origin, calls := countingOrigin(map[string]any{"10.0.0.1": ""})
+	lookup := NewTieredCache(l1, l2.source(), origin, WithTieredNegativeTTL(10*time.Second, time.Minute))
+
+	// An empty value is found, and stored as such rather than as a miss.
+	for range 2 {
+		val, found, err := lookup(t.Context(), "10.0.0.1")
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Empty(t, val)
+	}
+	assert.Equal(t, 1, *calls)
+	assert.Equal(t, "", l2.entries["10.0.0.1"])
+	val, found := l1.Get("10.0.0.1")
+	assert.True(t, found)
+	assert.Equal(t, "", val)
+}
+
+func TestTieredCacheNegativeDisabled(t *testing.T) {
+	l1 := NewCache(CacheConfig{Enabled: true})
+	l2 := newFakeTier()
+	origin, calls := countingOrigin(nil)
+	lookup := NewTieredCache(l1, l2.source(), origin)
+
+	for range 2 {
+		_, found, err := lookup(t.Context(), "10.0.0.9")
+		require.NoError(t, err)
+		assert.False(t, found)
+	}
+	assert.Equal(t, 2, *calls)
+	assert.Zero(t, l1.Len())
+	assert.Empty(t, l2.entries)
+}
+
+func TestTieredCacheL2Errors(t *testing.T) {
+	l1 := NewCache(CacheConfig{Enabled: true})
+	l2 := newFakeTier()
+	l2.err = errors.New("connection refused")
+	origin, calls := countingOrigin(map[string]any{"10.0.0.1": "web-1"})
+	lookup := NewTieredCache(l1, l2.source(), origin)
+
+	val, found, err := lookup(t.Context(), "10.0.0.1")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "web-1", val)
+	assert.Equal(t, 1, *calls)
+}
+
+func TestTieredCacheOriginError(t *testing.T) {
+	l1 := NewCache(CacheConfig{Enabled: true})
+	l2 := newFakeTier()
+	originErr := errors.New("origin unavailable")
+	lookup := NewTieredCache(l1, l2.source(), func(context.Context, string) (any, bool, error) {
+		return nil, false, originErr
+	}, WithTieredNegativeTTL(time.Minute, time.Minute))
+
+	_, found, err := lookup(t.Context(), "10.0.0.1")
+	assert.ErrorIs(t, err, originErr)
+	assert.False(t, found)
+	assert.Zero(t, l1.Len())
+	assert.Empty(t, l2.entries)
+}
+
+func TestTieredCacheReadOnlyL2(t *testing.T) {
+	l1 := NewCache(CacheConfig{Enabled: true})
+	l2 := NewSource(
+		func(context.Context, string) (any, bool, error) { return nil, false, nil },
+		func() string { return "readonly" },
+		nil,
+		nil,
+	)
+	origin, _ := countingOrigin(map[string]any{"10.0.0.1": "web-1"})
+	lookup := NewTieredCache(l1, l2, origin)
+
+	val, found, err := lookup(t.Context(), "10.0.0.1")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "web-1", val)
+	assert.Equal(t, 1, l1.Len())
+}
+
+func TestTieredCacheBypass(t *testing.T) {
+	l1 := NewCache(CacheConfig{Enabled: true})
+	l1.Set("10.0.0.1", "stale")
+	l2 := newFakeTier()
+	l2.entries["10.0.0.1"] = "stale"
+	origin, calls := countingOrigin(map[string]any{"10.0.0.1": "web-1"})
+	lookup := NewTieredCache(l1, l2.source(), origin)
+
+	val, found, err := lookup(WithCacheBypass(t.Context()), "10.0.0.1")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "web-1", val)
+	assert.Equal(t, 1, *calls)
+	assert.Zero(t, l2.lookups)
+
+	// Both tiers were refreshed.
+	val, _ = l1.Get("10.0.0.1")
+	assert.Equal(t, "web-1", val)
+	assert.Equal(t, "web-1", l2.entries["10.0.0.1"])
+}
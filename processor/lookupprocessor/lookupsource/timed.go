@@ -0,0 +1,36 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+
+import (
+	"context"
+	"time"
+)
+
+// TimedResult is a lookup result returned with the duration of the lookup
+// that found it, by sources configured to report their latency. The lookup
+// processor writes Value to the target attribute and Duration to the
+// <target>.lookup_ms attribute.
+type TimedResult struct {
+	Value    any
+	Duration time.Duration
+}
+
+// WithTiming wraps a lookup function so that the results it finds are
+// returned as a [TimedResult]. Apply it outside [WrapWithCache] so that
+// cache hits report the near-zero duration of reading the cache rather than
+// that of the lookup that populated it:
+//
+//	cachedLookup := lookupsource.WrapWithCache(cache, myLookupFunc)
+//	lookup := lookupsource.WithTiming(cachedLookup)
+func WithTiming(fn LookupFunc) LookupFunc {
+	return func(ctx context.Context, key string) (any, bool, error) {
+		start := time.Now()
+		val, found, err := fn(ctx, key)
+		if err != nil || !found {
+			return val, found, err
+		}
+		return TimedResult{Value: val, Duration: time.Since(start)}, true, nil
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithTiming(t *testing.T) {
+	lookup := WithTiming(func(_ context.Context, key string) (any, bool, error) {
+		switch key {
+		case "slow":
+			time.Sleep(20 * time.Millisecond)
+			return "web-1", true, nil
+		case "error":
+			return nil, false, errors.New("unavailable")
+		default:
+			return nil, false, nil
+		}
+	})
+
+	val, found, err := lookup(t.Context(), "slow")
+	require.NoError(t, err)
+	assert.True(t, found)
+	require.IsType(t, TimedResult{}, val)
+	assert.Equal(t, "web-1", val.(TimedResult).Value)
+	assert.GreaterOrEqual(t, val.(TimedResult).Duration, 20*time.Millisecond)
+
+	val, found, err = lookup(t.Context(), "missing")
+	require.NoError(t, err)
+	assert.False(t, found)
+	assert.Nil(t, val)
+
+	_, _, err = lookup(t.Context(), "error")
+	assert.EqualError(t, err, "unavailable")
+}
+
+func TestWithTimingCached(t *testing.T) {
+	cache := NewCache(CacheConfig{Enabled: true})
+	lookup := WithTiming(WrapWithCache(cache, func(context.Context, string) (any, bool, error) {
+		time.Sleep(20 * time.Millisecond)
+		return "web-1", true, nil
+	}))
+
+	val, _, err := lookup(t.Context(), "10.0.0.1")
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, val.(TimedResult).Duration, 20*time.Millisecond)
+
+	// The cache keeps the plain value, and hits report the time to read it.
+	cached, _ := cache.Get("10.0.0.1")
+	assert.Equal(t, "web-1", cached)
+	val, _, err = lookup(t.Context(), "10.0.0.1")
+	require.NoError(t, err)
+	assert.Less(t, val.(TimedResult).Duration, 10*time.Millisecond)
+}
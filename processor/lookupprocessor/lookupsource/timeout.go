@@ -0,0 +1,61 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrTimeout is returned by lookups that did not complete within the
+// duration given to [WithTimeout].
+var ErrTimeout = errors.New("lookup timed out")
+
+// WithTimeout wraps a lookup function so that a lookup returns at most d
+// after it started, with a transient error wrapping ErrTimeout if fn has not
+// returned by then. fn gets a context with that deadline; one that ignores
+// it keeps running in the background until it returns, but its result is
+// discarded. A non-positive d disables the timeout.
+//
+// A timeout is an error, not a "not found" answer, so it is never cached.
+// Applied inside [WithRetry], d bounds each attempt; applied outside, it
+// bounds the lookup including all retries:
+//
+//	lookup := lookupsource.WithTimeout(lookupsource.WithRetry(myLookupFunc, retryCfg), time.Second)
+//	cachedLookup := lookupsource.WrapWithCache(cache, lookup)
+func WithTimeout(fn LookupFunc, d time.Duration) LookupFunc {
+	if d <= 0 {
+		return fn
+	}
+	timeoutErr := Transient(fmt.Errorf("%w after %s", ErrTimeout, d))
+	type result struct {
+		val   any
+		found bool
+		err   error
+	}
+	return func(ctx context.Context, key string) (any, bool, error) {
+		ctx, cancel := context.WithTimeoutCause(ctx, d, timeoutErr)
+		defer cancel()
+
+		// Buffered so that fn can deliver a late result and exit.
+		done := make(chan result, 1)
+		go func() {
+			val, found, err := fn(ctx, key)
+			done <- result{val: val, found: found, err: err}
+		}()
+
+		select {
+		case r := <-done:
+			if r.err != nil && errors.Is(context.Cause(ctx), ErrTimeout) {
+				// fn gave up because of the deadline.
+				return nil, false, timeoutErr
+			}
+			return r.val, r.found, r.err
+		case <-ctx.Done():
+			return nil, false, context.Cause(ctx)
+		}
+	}
+}
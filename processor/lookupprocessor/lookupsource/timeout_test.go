@@ -0,0 +1,117 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithTimeout(t *testing.T) {
+	errUpstream := errors.New("upstream unavailable")
+	// release unblocks the slow lookups once the test is done.
+	release := make(chan struct{})
+	defer close(release)
+
+	tests := []struct {
+		name    string
+		fn      LookupFunc
+		want    any
+		wantErr error
+	}{
+		{
+			name: "in time",
+			fn: func(context.Context, string) (any, bool, error) {
+				return "value", true, nil
+			},
+			want: "value",
+		},
+		{
+			name: "error in time",
+			fn: func(context.Context, string) (any, bool, error) {
+				return nil, false, errUpstream
+			},
+			wantErr: errUpstream,
+		},
+		{
+			name: "ignores context",
+			fn: func(context.Context, string) (any, bool, error) {
+				<-release
+				return "late", true, nil
+			},
+			wantErr: ErrTimeout,
+		},
+		{
+			name: "honors context",
+			fn: func(ctx context.Context, _ string) (any, bool, error) {
+				<-ctx.Done()
+				return nil, false, ctx.Err()
+			},
+			wantErr: ErrTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			val, found, err := WithTimeout(tt.fn, 50*time.Millisecond)(t.Context(), "key")
+			assert.Less(t, time.Since(start), time.Second)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.False(t, found)
+				assert.Nil(t, val)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, tt.want, val)
+		})
+	}
+}
+
+func TestWithTimeoutParentCanceled(t *testing.T) {
+	lookup := WithTimeout(func(ctx context.Context, _ string) (any, bool, error) {
+		<-ctx.Done()
+		return nil, false, ctx.Err()
+	}, time.Minute)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	_, _, err := lookup(ctx, "key")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NotErrorIs(t, err, ErrTimeout)
+}
+
+func TestWithTimeoutNotCached(t *testing.T) {
+	var calls atomic.Int64
+	var slow atomic.Bool
+	slow.Store(true)
+	lookup := WrapWithCache(NewCache(CacheConfig{Enabled: true, Size: 10}), WithTimeout(func(ctx context.Context, _ string) (any, bool, error) {
+		calls.Add(1)
+		if slow.Load() {
+			<-ctx.Done()
+			return nil, false, ctx.Err()
+		}
+		return "value", true, nil
+	}, 20*time.Millisecond))
+
+	_, _, err := lookup(t.Context(), "key")
+	require.ErrorIs(t, err, ErrTimeout)
+	assert.True(t, IsTransient(err))
+
+	// The timeout was not cached as an answer, so the next lookup reaches
+	// the source again.
+	slow.Store(false)
+	val, found, err := lookup(t.Context(), "key")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "value", val)
+	assert.Equal(t, int64(2), calls.Load())
+}
@@ -0,0 +1,63 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+
+import (
+	"errors"
+	"fmt"
+)
+
+// FieldError is a problem with the value of a config field.
+type FieldError struct {
+	// Field is the name of the field as configured, such as "timeout" or
+	// "cidr_overrides[0].cidr".
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// FieldErrors collects the problems of a config, so that its Validate method
+// reports all of them at once rather than only the first one, each prefixed
+// with the field it concerns:
+//
+//	func (c *Config) Validate() error {
+//		var errs lookupsource.FieldErrors
+//		if c.Endpoint == "" {
+//			errs.Addf("endpoint", "must be specified")
+//		}
+//		if c.Timeout < 0 {
+//			errs.Addf("timeout", "must not be negative, got %s", c.Timeout)
+//		}
+//		return errs.Err()
+//	}
+type FieldErrors []*FieldError
+
+// Add records err for field, unless err is nil.
+func (e *FieldErrors) Add(field string, err error) {
+	if err != nil {
+		*e = append(*e, &FieldError{Field: field, Err: err})
+	}
+}
+
+// Addf records an error for field formatted like [fmt.Errorf].
+func (e *FieldErrors) Addf(field, format string, args ...any) {
+	e.Add(field, fmt.Errorf(format, args...))
+}
+
+// Err returns the recorded errors joined with [errors.Join], one per line,
+// or nil if none was recorded.
+func (e FieldErrors) Err() error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errors.Join(errs...)
+}
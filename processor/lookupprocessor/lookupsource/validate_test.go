@@ -0,0 +1,33 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupsource
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFieldErrors(t *testing.T) {
+	var errs FieldErrors
+	require.NoError(t, errs.Err())
+
+	errs.Add("endpoint", nil)
+	require.NoError(t, errs.Err(), "nil errors are not recorded")
+
+	errNegative := errors.New("must not be negative")
+	errs.Add("timeout", errNegative)
+	errs.Addf("lookups[2].source", "unknown source %q", "users")
+
+	err := errs.Err()
+	require.Error(t, err)
+	assert.Equal(t, "timeout: must not be negative\nlookups[2].source: unknown source \"users\"", err.Error())
+	assert.ErrorIs(t, err, errNegative)
+
+	var fieldErr *FieldError
+	require.ErrorAs(t, err, &fieldErr)
+	assert.Equal(t, "timeout", fieldErr.Field)
+}
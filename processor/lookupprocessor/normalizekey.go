@@ -0,0 +1,61 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupprocessor // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor"
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// KeyNormalization canonicalizes the values read as lookup keys, so that
+// spellings of the same key, such as "Host:443 " and "host", are looked up
+// once and share one cache entry. Unlike the key functions of sources, it
+// applies before skip_keys and is the same for every source.
+type KeyNormalization struct {
+	// TrimSpace removes leading and trailing white space.
+	// Default: false
+	TrimSpace bool `mapstructure:"trim_space"`
+
+	// Lowercase lowercases keys.
+	// Default: false
+	Lowercase bool `mapstructure:"lowercase"`
+
+	// StripPort removes the port of host:port and [host]:port keys, such
+	// as "web-1:8080" or "[2001:db8::1]:443". Bare IPv6 addresses are kept.
+	// Default: false
+	StripPort bool `mapstructure:"strip_port"`
+}
+
+// compile returns a function normalizing a key, or nil if no option is set.
+func (n KeyNormalization) compile() func(string) string {
+	if !n.TrimSpace && !n.Lowercase && !n.StripPort {
+		return nil
+	}
+	return func(key string) string {
+		if n.TrimSpace {
+			key = strings.TrimSpace(key)
+		}
+		if n.StripPort {
+			key = stripPort(key)
+		}
+		if n.Lowercase {
+			key = strings.ToLower(key)
+		}
+		return key
+	}
+}
+
+// stripPort returns the host of key if it is a host and a numeric port, and
+// key otherwise.
+func stripPort(key string) string {
+	host, port, err := net.SplitHostPort(key)
+	if err != nil {
+		return key
+	}
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return key
+	}
+	return host
+}
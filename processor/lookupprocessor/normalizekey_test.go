@@ -0,0 +1,38 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupprocessor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyNormalization(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  KeyNormalization
+		key  string
+		want string
+	}{
+		{name: "trim space", cfg: KeyNormalization{TrimSpace: true}, key: " Host:443\t", want: "Host:443"},
+		{name: "lowercase", cfg: KeyNormalization{Lowercase: true}, key: "Web-1.Example.COM", want: "web-1.example.com"},
+		{name: "strip port", cfg: KeyNormalization{StripPort: true}, key: "web-1:8080", want: "web-1"},
+		{name: "strip port of IPv6 address", cfg: KeyNormalization{StripPort: true}, key: "[2001:db8::1]:443", want: "2001:db8::1"},
+		{name: "keep bare IPv6 address", cfg: KeyNormalization{StripPort: true}, key: "2001:db8::1", want: "2001:db8::1"},
+		{name: "keep non-numeric port", cfg: KeyNormalization{StripPort: true}, key: "urn:isbn", want: "urn:isbn"},
+		{name: "port is stripped after trimming", cfg: KeyNormalization{TrimSpace: true, StripPort: true, Lowercase: true}, key: "Host:443 ", want: "host"},
+		{name: "untrimmed port is kept", cfg: KeyNormalization{StripPort: true}, key: "Host:443 ", want: "Host:443 "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.cfg.compile()(tt.key))
+		})
+	}
+}
+
+func TestKeyNormalizationDisabled(t *testing.T) {
+	assert.Nil(t, KeyNormalization{}.compile())
+}
@@ -0,0 +1,301 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupprocessor // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor"
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottldatapoint"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottllog"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottlresource"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottlspan"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/ottlfuncs"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+// isExpression reports whether an attribute setting is an OTTL expression
+// rather than a plain attribute name.
+func isExpression(s string) bool {
+	return strings.ContainsAny(s, `[("`)
+}
+
+// lookupResultKey is the context key holding the value LookupResult returns.
+type lookupResultKey struct{}
+
+// newLookupResultFactory returns the LookupResult converter, which yields
+// the value being written by the target statement.
+func newLookupResultFactory[K any]() ottl.Factory[K] {
+	return ottl.NewFactory("LookupResult", nil, func(ottl.FunctionContext, ottl.Arguments) (ottl.ExprFunc[K], error) {
+		return func(ctx context.Context, _ K) (any, error) {
+			return ctx.Value(lookupResultKey{}), nil
+		}, nil
+	})
+}
+
+func ottlFunctions[K any]() map[string]ottl.Factory[K] {
+	functions := ottlfuncs.StandardFuncs[K]()
+	lookupResult := newLookupResultFactory[K]()
+	functions[lookupResult.Name()] = lookupResult
+	return functions
+}
+
+// ottlRule holds the OTTL parts of a lookup rule compiled for one context.
+// Nil fields fall back to the plain attribute settings.
+type ottlRule[K any] struct {
+	conditions *ottl.ConditionSequence[K]
+	// key computes the lookup key from source_attribute.
+	key *ottl.ValueExpression[K]
+	// target reads the current value of target_attribute and setTarget
+	// writes the lookup result to it.
+	target    *ottl.ValueExpression[K]
+	setTarget *ottl.Statement[K]
+}
+
+// newOTTLRule compiles the OTTL parts of cfg with parser. It returns nil
+// when cfg only uses plain attribute names and has no conditions, so that
+// records can be enriched without building a transform context.
+func newOTTLRule[K any](parser ottl.Parser[K], cfg *LookupRule, errorMode ottl.ErrorMode, set component.TelemetrySettings) (*ottlRule[K], error) {
+	var rules ottlRule[K]
+	used := false
+
+	if len(cfg.Conditions) > 0 {
+		conditions, err := parser.ParseConditions(cfg.Conditions)
+		if err != nil {
+			return nil, err
+		}
+		seq := ottl.NewConditionSequence(conditions, set, ottl.WithConditionSequenceErrorMode[K](errorMode))
+		rules.conditions = &seq
+		used = true
+	}
+
+	if isExpression(cfg.SourceAttribute) {
+		key, err := parser.ParseValueExpression(cfg.SourceAttribute)
+		if err != nil {
+			return nil, fmt.Errorf("invalid source_attribute: %w", err)
+		}
+		rules.key = key
+		used = true
+	}
+
+	if isExpression(cfg.TargetAttribute) {
+		target, err := parser.ParseValueExpression(cfg.TargetAttribute)
+		if err != nil {
+			return nil, fmt.Errorf("invalid target_attribute: %w", err)
+		}
+		setTarget, err := parser.ParseStatement(fmt.Sprintf("set(%s, LookupResult())", cfg.TargetAttribute))
+		if err != nil {
+			return nil, fmt.Errorf("invalid target_attribute: %w", err)
+		}
+		rules.target = target
+		rules.setTarget = setTarget
+		used = true
+	}
+
+	if !used {
+		return nil, nil
+	}
+	return &rules, nil
+}
+
+// The parse functions compile the OTTL parts of every rule for the signal
+// the processor handles, in the context each rule reads.
+
+func (r *lookupRule) parseResource(set component.TelemetrySettings) error {
+	parser, err := ottlresource.NewParser(ottlFunctions[*ottlresource.TransformContext](), set)
+	if err != nil {
+		return err
+	}
+	r.resource, err = newOTTLRule(parser, r.cfg, r.errorMode, set)
+	return err
+}
+
+func (p *lookupProcessor) parseLogRules(set component.TelemetrySettings) error {
+	for _, r := range p.rules {
+		if err := r.parseLog(set); err != nil {
+			return fmt.Errorf("lookups[%d]: %w", r.index, err)
+		}
+	}
+	return nil
+}
+
+func (r *lookupRule) parseLog(set component.TelemetrySettings) error {
+	if r.cfg.Context == ContextResource {
+		return r.parseResource(set)
+	}
+	parser, err := ottllog.NewParser(ottlFunctions[*ottllog.TransformContext](), set)
+	if err != nil {
+		return err
+	}
+	r.log, err = newOTTLRule(parser, r.cfg, r.errorMode, set)
+	return err
+}
+
+func (p *lookupProcessor) parseSpanRules(set component.TelemetrySettings) error {
+	for _, r := range p.rules {
+		if err := r.parseSpan(set); err != nil {
+			return fmt.Errorf("lookups[%d]: %w", r.index, err)
+		}
+	}
+	return nil
+}
+
+func (r *lookupRule) parseSpan(set component.TelemetrySettings) error {
+	if r.cfg.Context == ContextResource {
+		return r.parseResource(set)
+	}
+	parser, err := ottlspan.NewParser(ottlFunctions[*ottlspan.TransformContext](), set)
+	if err != nil {
+		return err
+	}
+	r.span, err = newOTTLRule(parser, r.cfg, r.errorMode, set)
+	return err
+}
+
+func (p *lookupProcessor) parseDataPointRules(set component.TelemetrySettings) error {
+	for _, r := range p.rules {
+		if err := r.parseDataPoint(set); err != nil {
+			return fmt.Errorf("lookups[%d]: %w", r.index, err)
+		}
+	}
+	return nil
+}
+
+func (r *lookupRule) parseDataPoint(set component.TelemetrySettings) error {
+	if r.cfg.Context == ContextResource {
+		return r.parseResource(set)
+	}
+	parser, err := ottldatapoint.NewParser(ottlFunctions[*ottldatapoint.TransformContext](), set)
+	if err != nil {
+		return err
+	}
+	r.dataPoint, err = newOTTLRule(parser, r.cfg, r.errorMode, set)
+	return err
+}
+
+// addRecord adds the record whose attributes are attrs, and whose resource
+// has the attributes resource, to the batch, unless the route of the rule
+// selects another source for it. newCtx only builds the transform context
+// when compiled, the OTTL parts of the rule, has something to evaluate. The
+// context is kept until the result of the lookup is written.
+func addRecord[K interface{ Close() }](ctx context.Context, b *lookupBatch, compiled *ottlRule[K], resource, attrs pcommon.Map, newCtx func() K) error {
+	if b.rule.route != nil && !b.rule.route.match(attrs) {
+		return nil
+	}
+	if compiled == nil {
+		b.rule.add(b, resource, attrs)
+		return nil
+	}
+	tCtx := newCtx()
+	added, err := compiled.add(ctx, b, tCtx, resource, attrs, tCtx.Close)
+	if !added {
+		tCtx.Close()
+	}
+	return err
+}
+
+// add is the OTTL counterpart of lookupRule.add. It reports whether the
+// record was added to the batch, in which case done is called once the
+// record no longer needs tCtx.
+func (r *ottlRule[K]) add(ctx context.Context, b *lookupBatch, tCtx K, resource, attrs pcommon.Map, done func()) (bool, error) {
+	rule := b.rule
+	if r.conditions != nil {
+		match, err := r.conditions.Eval(ctx, tCtx)
+		if err != nil || !match {
+			return false, err
+		}
+	}
+
+	var key string
+	if r.key != nil {
+		val, err := r.key.Eval(ctx, tCtx)
+		if err != nil {
+			return false, rule.handleExpressionError("Failed to evaluate source_attribute", err)
+		}
+		key = keyString(val)
+	} else {
+		key = b.attributeKey(resource, attrs)
+	}
+	key = rule.normalize(key)
+	if key == "" {
+		return false, nil
+	}
+
+	if !rule.cfg.Overwrite && !rule.cfg.Flatten && len(rule.cfg.TargetParts) == 0 {
+		if r.target != nil {
+			existing, err := r.target.Eval(ctx, tCtx)
+			if err != nil {
+				return false, rule.handleExpressionError("Failed to evaluate target_attribute", err)
+			}
+			if existing != nil {
+				return false, nil
+			}
+		} else if _, exists := attrs.Get(rule.cfg.TargetAttribute); exists {
+			return false, nil
+		}
+	}
+
+	b.add(key, func(ctx context.Context, val any) error {
+		if r.setTarget == nil {
+			rule.write(attrs, val)
+			return nil
+		}
+		// An expression target has no attribute name to derive the latency
+		// and error attributes from, so only the value is set.
+		if failure, ok := val.(failedResult); ok {
+			if !failure.hasValue {
+				return nil
+			}
+			val = failure.value
+		}
+		if timed, ok := val.(lookupsource.TimedResult); ok {
+			val = timed.Value
+		}
+		if !rule.cfg.Overwrite {
+			// The target may be shared with a record written before, such
+			// as a resource attribute set from several log records.
+			if existing, err := r.target.Eval(ctx, tCtx); err != nil || existing != nil {
+				return nil
+			}
+		}
+		if _, _, err := r.setTarget.Execute(context.WithValue(ctx, lookupResultKey{}, val), tCtx); err != nil {
+			return rule.handleExpressionError("Failed to set target_attribute", err)
+		}
+		return nil
+	}, done)
+	return true, nil
+}
+
+// handleExpressionError applies the configured error mode to an error
+// evaluating source_attribute or target_attribute.
+func (r *lookupRule) handleExpressionError(msg string, err error) error {
+	switch r.errorMode {
+	case ottl.PropagateError:
+		return err
+	case ottl.IgnoreError:
+		r.logger.Warn(msg, zap.Error(err))
+	}
+	return nil
+}
+
+// keyString converts the value of a key expression to a lookup key. Nil
+// values yield an empty key, so the record is skipped.
+func keyString(val any) string {
+	switch v := val.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case pcommon.Value:
+		return v.AsString()
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -5,32 +5,881 @@ package lookupprocessor // import "github.com/open-telemetry/opentelemetry-colle
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"go.opentelemetry.io/collector/client"
 	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 	"go.uber.org/zap"
 
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottldatapoint"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottllog"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottlresource"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottlspan"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
 )
 
 type lookupProcessor struct {
+	// sources are the sources created for Config.Sources, by name.
+	sources map[string]lookupsource.Source
+	// extensionSources are the sources of Config.Sources owned by a lookup
+	// extension, by name. Their rules get a source when the processor starts.
+	extensionSources map[string]SourceConfig
+	rules            []*lookupRule
+	// id is the component ID of the processor, set as the processor
+	// attribute of its metrics.
+	id component.ID
+	// meter records the lookups made to the sources of the rules.
+	meter          metric.Meter
+	cacheMetrics   *cacheMetrics
+	registration   metric.Registration
+	maxConcurrency int
+	exposeExpvar   bool
+	readyTimeout   time.Duration
+	// cacheBypassKey is the client metadata key of CacheBypassMetadataKey.
+	cacheBypassKey string
+	// unpublish removes the sources published with exposeExpvar.
+	unpublish []func()
+	// started are the names of the started sources, in start order.
+	started []string
+	// sourceConfigs are the configs sources were created with, by name.
+	sourceConfigs map[string]SourceConfig
+	logger        *zap.Logger
+}
+
+// contextPrefix marks a source_attribute read from the client metadata of
+// the incoming context instead of the record attributes.
+const contextPrefix = "context."
+
+// latencyAttributeSuffix is appended to the target attribute to name the
+// attribute holding the duration of lookups of sources reporting it.
+const latencyAttributeSuffix = ".lookup_ms"
+
+// errorAttributeSuffix is appended to the target attribute to name the
+// attribute holding why lookups of sources reporting it failed.
+const errorAttributeSuffix = ".lookup_error"
+
+// failedResult is the outcome of a lookup that failed or didn't find its key
+// when the source reported why: reason is written to the <target>.lookup_error
+// attribute, and value, if set, to the target attribute.
+type failedResult struct {
+	reason   string
+	value    any
+	hasValue bool
+}
+
+// lookupRule is a LookupRule bound to its source.
+type lookupRule struct {
+	cfg *LookupRule
+	// index is the index of the rule in Config.Lookups. Rules with route_by
+	// make several lookupRules with the same index.
+	index  int
 	source lookupsource.Source
-	logger *zap.Logger
+	// route selects the records the rule applies to, if it comes from a
+	// rule with route_by.
+	route *routeFilter
+	// sourceLookup looks keys up in source, recording metrics and applying
+	// the multi_value mode.
+	sourceLookup lookupsource.LookupFunc
+	// metricAttrs are set on the metrics of the lookups of the rule, next to
+	// source_type.
+	metricAttrs         []attribute.KeyValue
+	multiValue          lookupsource.MultiValueMode
+	multiValueSeparator string
+	errorMode           ottl.ErrorMode
+	onError             LookupErrorAction
+	logger              *zap.Logger
+	// metadataKey is the client metadata key holding the lookup key, if
+	// source_attribute has the context prefix.
+	metadataKey string
+	// keyTemplate builds the lookup key if source_key is set.
+	keyTemplate *keyTemplate
+	// transform applies the transform steps to string results, if any.
+	transform func(string) string
+	// normalizeKey applies normalize_key to the keys read from records, if
+	// set.
+	normalizeKey func(string) string
+	// skipKey reports whether a key matches skip_keys, if set.
+	skipKey func(string) bool
+	// sample reports whether the record with a trace ID is looked up, if
+	// sample_rate is set.
+	sample func(pcommon.TraceID) bool
+	// cardinality limits the distinct values written to data points, if
+	// metrics_cardinality_limit is set.
+	cardinality *cardinalityLimiter
+
+	// OTTL parts of the rule for the signal the processor handles. Only the
+	// ones matching the signal and LookupRule.Context are set, and only if
+	// the rule uses OTTL.
+	resource  *ottlRule[*ottlresource.TransformContext]
+	log       *ottlRule[*ottllog.TransformContext]
+	span      *ottlRule[*ottlspan.TransformContext]
+	dataPoint *ottlRule[*ottldatapoint.TransformContext]
+}
+
+func newLookupProcessor(id component.ID, cfg *Config, sources map[string]lookupsource.Source, logger *zap.Logger, meter metric.Meter) (*lookupProcessor, error) {
+	metrics, err := newCacheMetrics(meter)
+	if err != nil {
+		return nil, err
+	}
+	p := &lookupProcessor{
+		sources:          sources,
+		extensionSources: make(map[string]SourceConfig),
+		id:               id,
+		meter:            meter,
+		cacheMetrics:     metrics,
+		maxConcurrency:   cfg.MaxConcurrency,
+		exposeExpvar:     cfg.ExposeExpvar,
+		cacheBypassKey:   cfg.CacheBypassMetadataKey,
+		readyTimeout:     cfg.ReadyTimeout,
+		sourceConfigs:    make(map[string]SourceConfig),
+		logger:           logger,
+	}
+	limiters := newCardinalityLimiters(cfg.MetricsCardinalityLimit, cfg.Lookups, id, logger, meter)
+	for name, sourceCfg := range cfg.Sources {
+		if sourceCfg.Extension != nil {
+			p.extensionSources[name] = sourceCfg
+		} else {
+			p.sourceConfigs[name] = sourceCfg
+		}
+	}
+	for i := range cfg.Lookups {
+		for _, routed := range expandRoutes(&cfg.Lookups[i]) {
+			r, err := newLookupRule(id, cfg, i, routed, sources, logger, meter)
+			if err != nil {
+				return nil, fmt.Errorf("lookups[%d]: %w", i, err)
+			}
+			r.cardinality = limiters[r.cfg.TargetAttribute]
+			p.rules = append(p.rules, r)
+		}
+	}
+	return p, nil
 }
 
-func newLookupProcessor(source lookupsource.Source, logger *zap.Logger) *lookupProcessor {
-	return &lookupProcessor{source: source, logger: logger}
+// newLookupRule returns the rule of routed, the index-th rule of cfg or one
+// of its routes, bound to its source in sources, if any. id is the component
+// ID of the processor the rule belongs to.
+func newLookupRule(id component.ID, cfg *Config, index int, routed routedRule, sources map[string]lookupsource.Source, logger *zap.Logger, meter metric.Meter) (*lookupRule, error) {
+	rule := routed.cfg
+	var metadataKey string
+	if key, ok := strings.CutPrefix(rule.SourceAttribute, contextPrefix); ok {
+		metadataKey = key
+	}
+	r := &lookupRule{
+		cfg:                 rule,
+		index:               index,
+		route:               routed.route,
+		multiValue:          cfg.MultiValue,
+		multiValueSeparator: cfg.MultiValueSeparator,
+		errorMode:           cfg.ErrorMode,
+		onError:             cfg.OnError,
+		logger:              logger.With(zap.Int("lookup", index), zap.String("source", rule.Source)),
+		metadataKey:         metadataKey,
+		metricAttrs:         []attribute.KeyValue{processorAttribute(id), attribute.String("source_name", rule.Source)},
+	}
+	if rule.SourceKey != "" {
+		tmpl, err := parseKeyTemplate(rule.SourceKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid source_key: %w", err)
+		}
+		r.keyTemplate = tmpl
+	}
+	transform, err := compileTransform(rule.Transform)
+	if err != nil {
+		return nil, err
+	}
+	r.transform = transform
+	r.normalizeKey = rule.NormalizeKey.compile()
+	skipKey, err := rule.SkipKeys.compile()
+	if err != nil {
+		return nil, err
+	}
+	r.skipKey = skipKey
+	sample, err := newSampler(rule.SampleRate, rule.SampleBy)
+	if err != nil {
+		return nil, err
+	}
+	r.sample = sample
+	if source, ok := sources[rule.Source]; ok {
+		if err := r.setSource(source, meter); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// setSource binds the rule to source, whose lookups are recorded with meter.
+// Rules with source_attributes look compound keys up, which source must
+// support.
+func (r *lookupRule) setSource(source lookupsource.Source, meter metric.Meter) error {
+	lookup := source.Lookup
+	if len(r.cfg.SourceAttributes) > 0 {
+		ms, ok := source.(lookupsource.MultiKeySource)
+		if !ok {
+			return fmt.Errorf("source %q of type %q doesn't support source_attributes", r.cfg.Source, source.Type())
+		}
+		lookup = lookupsource.CompoundLookup(ms.MultiKeyLookup)
+	}
+	r.source = source
+	lookup = lookupsource.WithMetrics(lookup, meter, source.Type(), r.metricAttrs...)
+	r.sourceLookup = lookupsource.WithMultiValue(lookup, r.multiValue, r.multiValueSeparator)
+	return nil
 }
 
 func (p *lookupProcessor) Start(ctx context.Context, host component.Host) error {
-	return p.source.Start(ctx, host)
+	if err := p.start(ctx, host); err != nil {
+		// Don't leave the sources started so far running, nor the metrics
+		// and expvars registered, behind a processor that failed to start.
+		return errors.Join(err, p.Shutdown(ctx))
+	}
+	return nil
+}
+
+func (p *lookupProcessor) start(ctx context.Context, host component.Host) error {
+	for _, name := range sortedNames(p.sources) {
+		if slices.Contains(p.started, name) {
+			continue
+		}
+		if err := p.sources[name].Start(ctx, host); err != nil {
+			return fmt.Errorf("failed to start source %q: %w", name, err)
+		}
+		p.started = append(p.started, name)
+	}
+	registration, err := p.cacheMetrics.register(p.meter, p.id, p.sources)
+	if err != nil {
+		return fmt.Errorf("failed to register cache metrics: %w", err)
+	}
+	p.registration = registration
+	if p.exposeExpvar {
+		for _, name := range sortedNames(p.sources) {
+			if reporter, ok := p.sources[name].(lookupsource.CacheStatsReporter); ok {
+				p.unpublish = append(p.unpublish, lookupsource.PublishExpvar(p.sources[name].Type(), reporter))
+			}
+		}
+	}
+	ready := maps.Clone(p.sources)
+	for _, name := range sortedNames(p.extensionSources) {
+		source, err := p.extensionSources[name].resolve(host, name)
+		if err != nil {
+			return fmt.Errorf("failed to resolve source %q: %w", name, err)
+		}
+		ready[name] = source
+		for _, rule := range p.rules {
+			if rule.cfg.Source == name {
+				if err := rule.setSource(source, p.meter); err != nil {
+					return fmt.Errorf("lookups[%d]: %w", rule.index, err)
+				}
+			}
+		}
+	}
+	if p.readyTimeout > 0 {
+		if err := p.waitReady(ctx, ready); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitReady waits up to readyTimeout for sources, by name, to be ready, and
+// returns an error naming the ones that aren't by then.
+func (p *lookupProcessor) waitReady(ctx context.Context, sources map[string]lookupsource.Source) error {
+	ctx, cancel := context.WithTimeout(ctx, p.readyTimeout)
+	defer cancel()
+
+	// The sources share the deadline, so waiting for them one after the
+	// other takes as long as waiting for the slowest one.
+	var errs []error
+	for _, name := range sortedNames(sources) {
+		rc, ok := sources[name].(lookupsource.ReadinessChecker)
+		if !ok {
+			continue
+		}
+		if err := rc.Ready(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("source %q is not ready after %s: %w", name, p.readyTimeout, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// resolve returns the source named name, or sourceCfg.Name if set, of the
+// lookup extension sourceCfg refers to. The extension owns the source and so
+// starts and shuts it down.
+func (sourceCfg SourceConfig) resolve(host component.Host, name string) (lookupsource.Source, error) {
+	ext, ok := host.GetExtensions()[*sourceCfg.Extension]
+	if !ok {
+		return nil, fmt.Errorf("extension %q not found", sourceCfg.Extension)
+	}
+	lookupExt, ok := ext.(lookupsource.LookupExtension)
+	if !ok {
+		return nil, fmt.Errorf("extension %q is not a lookup extension", sourceCfg.Extension)
+	}
+	if sourceCfg.Name != "" {
+		name = sourceCfg.Name
+	}
+	source, ok := lookupExt.Source(name)
+	if !ok {
+		return nil, fmt.Errorf("extension %q has no source %q", sourceCfg.Extension, name)
+	}
+	return source, nil
 }
 
 func (p *lookupProcessor) Shutdown(ctx context.Context) error {
-	return p.source.Shutdown(ctx)
+	for _, unpublish := range p.unpublish {
+		unpublish()
+	}
+	p.unpublish = nil
+	var errs error
+	if p.registration != nil {
+		errs = p.registration.Unregister()
+		p.registration = nil
+	}
+	return errors.Join(errs, p.shutdownSources(ctx))
 }
 
-func (*lookupProcessor) processLogs(_ context.Context, ld plog.Logs) (plog.Logs, error) {
-	// Skeleton: passthrough for now
+// retire moves the started sources of p, to be handed over to the processor
+// created next with the same ID and signal, out of p, so that Shutdown leaves
+// them running. It returns nil if no source is started.
+func (p *lookupProcessor) retire() *retiredSources {
+	if len(p.started) == 0 {
+		return nil
+	}
+	r := &retiredSources{
+		sources: make(map[string]lookupsource.Source, len(p.started)),
+		started: p.started,
+		configs: make(map[string]SourceConfig, len(p.started)),
+		logger:  p.logger,
+	}
+	for _, name := range p.started {
+		r.sources[name] = p.sources[name]
+		r.configs[name] = p.sourceConfigs[name]
+	}
+	p.started = nil
+	return r
+}
+
+// replaceSource binds the rules of the source named name to source instead.
+func (p *lookupProcessor) replaceSource(name string, source lookupsource.Source) error {
+	p.sources[name] = source
+	for _, rule := range p.rules {
+		if rule.cfg.Source == name {
+			if err := rule.setSource(source, p.meter); err != nil {
+				return fmt.Errorf("lookups[%d]: %w", rule.index, err)
+			}
+		}
+	}
+	return nil
+}
+
+// shutdownSources shuts down the started sources in reverse start order.
+// Sources owned by an extension are left to it.
+func (p *lookupProcessor) shutdownSources(ctx context.Context) error {
+	var errs error
+	for i := len(p.started) - 1; i >= 0; i-- {
+		name := p.started[i]
+		if err := p.sources[name].Shutdown(ctx); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("failed to shut down source %q: %w", name, err))
+		}
+	}
+	p.started = nil
+	return errs
+}
+
+func sortedNames[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// The process functions apply the rules one after the other to the whole
+// batch, so that a rule sees the attributes written by the previous ones
+// whatever their contexts. Within a rule, records are collected first and
+// looked up together, see lookupBatch.
+
+func (p *lookupProcessor) processLogs(ctx context.Context, ld plog.Logs) (plog.Logs, error) {
+	ctx = p.withCacheBypass(ctx)
+	rls := ld.ResourceLogs()
+	for _, rule := range p.rules {
+		batch := rule.newBatch(ctx)
+		for i := 0; i < rls.Len(); i++ {
+			rl := rls.At(i)
+			if rule.cfg.Context == ContextResource {
+				if err := batch.addResource(ctx, rl.Resource(), rl); err != nil {
+					batch.close()
+					return ld, err
+				}
+				continue
+			}
+			sls := rl.ScopeLogs()
+			for j := 0; j < sls.Len(); j++ {
+				sl := sls.At(j)
+				lrs := sl.LogRecords()
+				for k := 0; k < lrs.Len(); k++ {
+					lr := lrs.At(k)
+					if !rule.sampled(lr.TraceID()) {
+						continue
+					}
+					err := addRecord(ctx, batch, rule.log, rl.Resource().Attributes(), lr.Attributes(), func() *ottllog.TransformContext {
+						return ottllog.NewTransformContextPtr(rl, sl, lr)
+					})
+					if err != nil {
+						batch.close()
+						return ld, err
+					}
+				}
+			}
+		}
+		if err := batch.run(ctx, p.maxConcurrency); err != nil {
+			return ld, err
+		}
+	}
 	return ld, nil
 }
+
+func (p *lookupProcessor) processTraces(ctx context.Context, td ptrace.Traces) (ptrace.Traces, error) {
+	ctx = p.withCacheBypass(ctx)
+	rss := td.ResourceSpans()
+	for _, rule := range p.rules {
+		batch := rule.newBatch(ctx)
+		for i := 0; i < rss.Len(); i++ {
+			rs := rss.At(i)
+			if rule.cfg.Context == ContextResource {
+				if err := batch.addResource(ctx, rs.Resource(), rs); err != nil {
+					batch.close()
+					return td, err
+				}
+				continue
+			}
+			sss := rs.ScopeSpans()
+			for j := 0; j < sss.Len(); j++ {
+				ss := sss.At(j)
+				spans := ss.Spans()
+				for k := 0; k < spans.Len(); k++ {
+					span := spans.At(k)
+					if !rule.sampled(span.TraceID()) {
+						continue
+					}
+					err := addRecord(ctx, batch, rule.span, rs.Resource().Attributes(), span.Attributes(), func() *ottlspan.TransformContext {
+						return ottlspan.NewTransformContextPtr(rs, ss, span)
+					})
+					if err != nil {
+						batch.close()
+						return td, err
+					}
+				}
+			}
+		}
+		if err := batch.run(ctx, p.maxConcurrency); err != nil {
+			return td, err
+		}
+	}
+	return td, nil
+}
+
+func (p *lookupProcessor) processMetrics(ctx context.Context, md pmetric.Metrics) (pmetric.Metrics, error) {
+	ctx = p.withCacheBypass(ctx)
+	rms := md.ResourceMetrics()
+	for _, rule := range p.rules {
+		batch := rule.newBatch(ctx)
+		batch.cardinality = rule.cardinality
+		for i := 0; i < rms.Len(); i++ {
+			rm := rms.At(i)
+			if rule.cfg.Context == ContextResource {
+				if err := batch.addResource(ctx, rm.Resource(), rm); err != nil {
+					batch.close()
+					return md, err
+				}
+				continue
+			}
+			sms := rm.ScopeMetrics()
+			for j := 0; j < sms.Len(); j++ {
+				sm := sms.At(j)
+				metrics := sm.Metrics()
+				for k := 0; k < metrics.Len(); k++ {
+					metric := metrics.At(k)
+					err := forEachDataPoint(metric, func(dp any, attrs pcommon.Map) error {
+						if !rule.sampled(pcommon.NewTraceIDEmpty()) {
+							return nil
+						}
+						return addRecord(ctx, batch, rule.dataPoint, rm.Resource().Attributes(), attrs, func() *ottldatapoint.TransformContext {
+							return ottldatapoint.NewTransformContextPtr(rm, sm, metric, dp)
+						})
+					})
+					if err != nil {
+						batch.close()
+						return md, err
+					}
+				}
+			}
+		}
+		if err := batch.run(ctx, p.maxConcurrency); err != nil {
+			return md, err
+		}
+	}
+	return md, nil
+}
+
+// schemaURLItem is implemented by the ResourceLogs, ResourceSpans and
+// ResourceMetrics that own a resource.
+type schemaURLItem interface {
+	SchemaUrl() string
+	SetSchemaUrl(v string)
+}
+
+// addResource adds resource to the batch of its rule.
+func (b *lookupBatch) addResource(ctx context.Context, resource pcommon.Resource, schemaURLItem schemaURLItem) error {
+	if !b.rule.sampled(pcommon.NewTraceIDEmpty()) {
+		return nil
+	}
+	return addRecord(ctx, b, b.rule.resource, resource.Attributes(), resource.Attributes(), func() *ottlresource.TransformContext {
+		return ottlresource.NewTransformContextPtr(resource, schemaURLItem)
+	})
+}
+
+// sampled reports whether the record with trace ID traceID is looked up
+// under the sample_rate of the rule. Resources and data points have none.
+func (r *lookupRule) sampled(traceID pcommon.TraceID) bool {
+	return r.sample == nil || r.sample(traceID)
+}
+
+// forEachDataPoint calls fn with every data point of m, whatever its type,
+// and its attributes. It stops at the first error returned by fn.
+func forEachDataPoint(m pmetric.Metric, fn func(dp any, attrs pcommon.Map) error) error {
+	switch m.Type() {
+	case pmetric.MetricTypeGauge:
+		dps := m.Gauge().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			if err := fn(dps.At(i), dps.At(i).Attributes()); err != nil {
+				return err
+			}
+		}
+	case pmetric.MetricTypeSum:
+		dps := m.Sum().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			if err := fn(dps.At(i), dps.At(i).Attributes()); err != nil {
+				return err
+			}
+		}
+	case pmetric.MetricTypeHistogram:
+		dps := m.Histogram().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			if err := fn(dps.At(i), dps.At(i).Attributes()); err != nil {
+				return err
+			}
+		}
+	case pmetric.MetricTypeExponentialHistogram:
+		dps := m.ExponentialHistogram().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			if err := fn(dps.At(i), dps.At(i).Attributes()); err != nil {
+				return err
+			}
+		}
+	case pmetric.MetricTypeSummary:
+		dps := m.Summary().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			if err := fn(dps.At(i), dps.At(i).Attributes()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// add adds the record with attributes attrs, and resource attributes
+// resource, to the batch if it has a key and its target attribute may be
+// written.
+func (r *lookupRule) add(b *lookupBatch, resource, attrs pcommon.Map) {
+	key := r.normalize(b.attributeKey(resource, attrs))
+	if key == "" {
+		return
+	}
+	if !r.cfg.Overwrite && !r.cfg.Flatten && len(r.cfg.TargetParts) == 0 {
+		// Results are appended to an existing array.
+		if target, exists := attrs.Get(r.cfg.TargetAttribute); exists &&
+			(!r.cfg.AppendToArray || target.Type() != pcommon.ValueTypeSlice) {
+			return
+		}
+	}
+
+	b.add(key, func(_ context.Context, val any) error {
+		r.write(attrs, val)
+		return nil
+	}, nil)
+}
+
+// normalize returns key normalized by normalize_key, if set.
+func (r *lookupRule) normalize(key string) string {
+	if r.normalizeKey == nil {
+		return key
+	}
+	return r.normalizeKey(key)
+}
+
+// attributeKey returns the lookup key of a record with attributes attrs and
+// resource attributes resource when source_attribute is not an OTTL
+// expression: the client metadata value read when the batch was created,
+// the rendered source_key, the compound key of source_attributes, or the
+// source attribute.
+func (b *lookupBatch) attributeKey(resource, attrs pcommon.Map) string {
+	if b.rule.metadataKey != "" {
+		return b.metadataValue
+	}
+	if len(b.rule.cfg.SourceAttributes) > 0 {
+		return compoundAttributeKey(b.rule.cfg.SourceAttributes, attrs)
+	}
+	if b.rule.keyTemplate != nil {
+		return b.rule.keyTemplate.render(resource, attrs, b.rule.cfg.SourceKeyMissing == MissingAttributeEmpty)
+	}
+	if keyVal, ok := attrs.Get(b.rule.cfg.SourceAttribute); ok {
+		return keyVal.AsString()
+	}
+	return ""
+}
+
+// compoundAttributeKey returns the compound key made of the attributes of
+// attrs named by sourceAttributes, or "" if any of them is missing.
+func compoundAttributeKey(sourceAttributes map[string]string, attrs pcommon.Map) string {
+	keys := make(map[string]string, len(sourceAttributes))
+	for part, name := range sourceAttributes {
+		val, ok := attrs.Get(name)
+		if !ok {
+			return ""
+		}
+		keys[part] = val.AsString()
+	}
+	return lookupsource.CompoundKey(keys)
+}
+
+// metadataValue returns the first value of key in the client metadata of
+// ctx, or "" if there is none.
+func metadataValue(ctx context.Context, key string) string {
+	if values := client.FromContext(ctx).Metadata.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// withCacheBypass returns ctx marked to bypass the caches of the sources if
+// its client metadata sets the cache bypass key to true.
+func (p *lookupProcessor) withCacheBypass(ctx context.Context) context.Context {
+	if p.cacheBypassKey == "" {
+		return ctx
+	}
+	if bypass, _ := strconv.ParseBool(metadataValue(ctx, p.cacheBypassKey)); bypass {
+		return lookupsource.WithCacheBypass(ctx)
+	}
+	return ctx
+}
+
+// lookup returns the value to write for key: the transformed source result,
+// the default value if key is not found, or the skip_keys default value,
+// without looking key up, if it is skipped. It returns false if nothing
+// should be written. Lookup failures follow on_error: they are logged and
+// leave the records unchanged or get the default value, so that a failing
+// source never drops telemetry, unless they are returned to reject the
+// batch.
+func (r *lookupRule) lookup(ctx context.Context, key string) (any, bool, error) {
+	if r.skipKey != nil && r.skipKey(key) {
+		if r.cfg.SkipKeys.DefaultValue == nil {
+			return nil, false, nil
+		}
+		return r.cfg.SkipKeys.DefaultValue, true, nil
+	}
+	val, found, err := r.sourceLookup(ctx, key)
+	if err != nil {
+		reason, hasReason := lookupsource.FailureReason(err)
+		switch r.onError {
+		case LookupErrorPropagate:
+			return nil, false, fmt.Errorf("lookups[%d]: lookup in source %q failed: %w", r.index, r.cfg.Source, err)
+		case LookupErrorDefault:
+			r.logger.Debug("Lookup failed, writing the default value", zap.String("key", key), zap.Error(err))
+			return failed(reason, hasReason, r.cfg.DefaultValue, r.cfg.DefaultValue != nil)
+		}
+		r.logger.Debug("Lookup failed", zap.String("key", key), zap.Error(err))
+		return failed(reason, hasReason, nil, false)
+	}
+	if !found {
+		reason, hasReason := val.(lookupsource.NotFoundReason)
+		return failed(string(reason), hasReason && reason != "", r.cfg.DefaultValue, !r.cfg.SkipOnNotFound && r.cfg.DefaultValue != nil)
+	}
+	if r.transform != nil {
+		if timed, ok := val.(lookupsource.TimedResult); ok {
+			timed.Value, found = r.transformValue(key, timed.Value)
+			return timed, found, nil
+		}
+		val, found = r.transformValue(key, val)
+		return val, found, nil
+	}
+	return val, true, nil
+}
+
+// failed returns the result of a lookup that failed or didn't find its key:
+// value, if hasValue, and the reason reported by the source, if hasReason.
+func failed(reason string, hasReason bool, value any, hasValue bool) (any, bool, error) {
+	if hasReason {
+		return failedResult{reason: reason, value: value, hasValue: hasValue}, true, nil
+	}
+	if !hasValue {
+		return nil, false, nil
+	}
+	return value, true, nil
+}
+
+// transformValue applies the transform of the rule to val, the result found
+// for key.
+func (r *lookupRule) transformValue(key string, val any) (any, bool) {
+	if s, ok := val.(string); ok {
+		return r.transform(s), true
+	}
+	if r.cfg.TransformNonString == NonStringError {
+		r.logger.Debug("Lookup result is not a string and cannot be transformed",
+			zap.String("key", key), zap.String("type", fmt.Sprintf("%T", val)))
+		return nil, false
+	}
+	return val, true
+}
+
+// write writes the lookup result val to the target attribute of attrs. With
+// flatten, the entries of a map result are written to one attribute each and
+// the existence of every written attribute is checked here rather than
+// before the lookup, since the attribute names depend on the result. The
+// same goes for the parts of string results with target_parts.
+//
+// Results of sources reporting their latency also write the duration of the
+// lookup, in milliseconds, to the <target>.lookup_ms attribute, and failures
+// of sources reporting their reason write it to the <target>.lookup_error
+// attribute.
+func (r *lookupRule) write(attrs pcommon.Map, val any) {
+	if failure, ok := val.(failedResult); ok {
+		r.writeFailureReason(attrs, failure.reason)
+		if !failure.hasValue {
+			return
+		}
+		val = failure.value
+	}
+	if timed, ok := val.(lookupsource.TimedResult); ok {
+		val = timed.Value
+		r.writeLatency(attrs, timed.Duration)
+	}
+	switch {
+	case r.cfg.AppendToArray:
+		r.appendValue(attrs, val)
+	case r.cfg.Flatten:
+		r.putFlattened(attrs, r.cfg.TargetAttribute, val, 0)
+	case len(r.cfg.TargetParts) > 0:
+		if s, ok := val.(string); ok {
+			r.putParts(attrs, s)
+			return
+		}
+		if _, exists := attrs.Get(r.cfg.TargetAttribute); exists && !r.cfg.Overwrite {
+			return
+		}
+		putValue(attrs, r.cfg.TargetAttribute, val)
+	default:
+		putValue(attrs, r.cfg.TargetAttribute, val)
+	}
+}
+
+// appendValue appends val, or each element of a list val, to the array of
+// the target attribute, creating it if it doesn't exist. A target attribute
+// that isn't an array is replaced with Overwrite and kept otherwise.
+func (r *lookupRule) appendValue(attrs pcommon.Map, val any) {
+	var array pcommon.Slice
+	switch target, exists := attrs.Get(r.cfg.TargetAttribute); {
+	case exists && target.Type() == pcommon.ValueTypeSlice:
+		array = target.Slice()
+	case exists && !r.cfg.Overwrite:
+		return
+	default:
+		array = attrs.PutEmptySlice(r.cfg.TargetAttribute)
+	}
+
+	values, ok := val.([]any)
+	if !ok {
+		values = []any{val}
+	}
+	for _, v := range values {
+		elem := pcommon.NewValueEmpty()
+		if err := elem.FromRaw(v); err != nil {
+			elem.SetStr(fmt.Sprint(v))
+		}
+		if r.cfg.AppendDeduplicate && containsValue(array, elem) {
+			continue
+		}
+		elem.CopyTo(array.AppendEmpty())
+	}
+}
+
+// containsValue reports whether array holds a value equal to v.
+func containsValue(array pcommon.Slice, v pcommon.Value) bool {
+	for _, elem := range array.All() {
+		if elem.Equal(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeLatency writes the duration d of a lookup to the latency attribute of
+// the target attribute.
+func (r *lookupRule) writeLatency(attrs pcommon.Map, d time.Duration) {
+	key := r.cfg.TargetAttribute + latencyAttributeSuffix
+	if !r.cfg.Overwrite {
+		if _, exists := attrs.Get(key); exists {
+			return
+		}
+	}
+	attrs.PutDouble(key, float64(d)/float64(time.Millisecond))
+}
+
+// writeFailureReason writes the reason a lookup failed to the error
+// attribute of the target attribute.
+func (r *lookupRule) writeFailureReason(attrs pcommon.Map, reason string) {
+	key := r.cfg.TargetAttribute + errorAttributeSuffix
+	if !r.cfg.Overwrite {
+		if _, exists := attrs.Get(key); exists {
+			return
+		}
+	}
+	attrs.PutStr(key, reason)
+}
+
+// putFlattened writes val to the attribute key of attrs, recursing into map
+// values nested depth levels deep until FlattenMaxDepth is reached.
+func (r *lookupRule) putFlattened(attrs pcommon.Map, key string, val any, depth int) {
+	if m, ok := val.(map[string]any); ok && (r.cfg.FlattenMaxDepth == 0 || depth < r.cfg.FlattenMaxDepth) {
+		for k, v := range m {
+			r.putFlattened(attrs, key+r.cfg.FlattenSeparator+k, v, depth+1)
+		}
+		return
+	}
+	if !r.cfg.Overwrite {
+		if _, exists := attrs.Get(key); exists {
+			return
+		}
+	}
+	putValue(attrs, key, val)
+}
+
+// putValue writes val to attrs, falling back to its string form for types
+// that have no attribute representation.
+func putValue(attrs pcommon.Map, key string, val any) {
+	if err := attrs.PutEmpty(key).FromRaw(val); err != nil {
+		attrs.PutStr(key, fmt.Sprint(val))
+	}
+}
@@ -0,0 +1,2305 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupprocessor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"maps"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/client"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/confmap"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/processor"
+	"go.opentelemetry.io/collector/processor/processortest"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/metadata"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/sourceconfig"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+// newTestConfig returns a config with a single lookup using a static source
+// with two known hosts. The "sources", "error_mode", "multi_value" and
+// "multi_value_separator" settings replace the processor settings, the others
+// the settings of the lookup.
+func newTestConfig(t *testing.T, settings map[string]any) *Config {
+	t.Helper()
+
+	rule := map[string]any{
+		"source":           "hosts",
+		"source_attribute": "client.ip",
+		"target_attribute": "host.name",
+	}
+	conf := map[string]any{
+		"sources": map[string]any{
+			"hosts": map[string]any{
+				"type": "static",
+				"entries": map[string]any{
+					"10.0.0.1": "web-1",
+					"10.0.0.2": "web-2",
+				},
+			},
+		},
+		"lookups": []any{rule},
+	}
+	for k, v := range settings {
+		switch k {
+		case "sources", "error_mode", "multi_value", "multi_value_separator":
+			conf[k] = v
+		default:
+			rule[k] = v
+		}
+	}
+
+	cfg := NewFactory().CreateDefaultConfig().(*Config)
+	require.NoError(t, confmap.NewFromStringMap(conf).Unmarshal(cfg))
+	require.NoError(t, cfg.Validate())
+	return cfg
+}
+
+func TestProcessLogs(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]any
+		// want maps each record's client.ip to its expected host.name, or
+		// to nil if host.name must be absent.
+		want map[string]any
+		// wantResource is the expected resource host.name, or nil.
+		wantResource any
+	}{
+		{
+			name: "record attributes",
+			want: map[string]any{"10.0.0.1": "web-1", "10.0.0.2": "web-2", "10.0.0.9": nil},
+		},
+		{
+			name:     "default value",
+			settings: map[string]any{"default_value": "unknown"},
+			want:     map[string]any{"10.0.0.1": "web-1", "10.0.0.2": "web-2", "10.0.0.9": "unknown"},
+		},
+		{
+			name:         "resource attributes",
+			settings:     map[string]any{"context": "resource"},
+			want:         map[string]any{"10.0.0.1": nil, "10.0.0.2": nil, "10.0.0.9": nil},
+			wantResource: "web-2",
+		},
+		{
+			name:     "conditions",
+			settings: map[string]any{"conditions": []any{`attributes["client.ip"] != "10.0.0.2"`}},
+			want:     map[string]any{"10.0.0.1": "web-1", "10.0.0.2": nil, "10.0.0.9": nil},
+		},
+		{
+			name: "resource conditions not matched",
+			settings: map[string]any{
+				"context":    "resource",
+				"conditions": []any{`attributes["client.ip"] == "10.0.0.1"`},
+			},
+			want: map[string]any{"10.0.0.1": nil, "10.0.0.2": nil, "10.0.0.9": nil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := new(consumertest.LogsSink)
+			factory := NewFactory()
+			proc, err := factory.CreateLogs(t.Context(), processortest.NewNopSettings(metadata.Type), newTestConfig(t, tt.settings), sink)
+			require.NoError(t, err)
+			require.NoError(t, proc.Start(t.Context(), componenttest.NewNopHost()))
+			defer func() { require.NoError(t, proc.Shutdown(t.Context())) }()
+
+			require.NoError(t, proc.ConsumeLogs(t.Context(), generateTestLogs()))
+
+			require.Len(t, sink.AllLogs(), 1)
+			rl := sink.AllLogs()[0].ResourceLogs().At(0)
+			resourceHost, ok := rl.Resource().Attributes().Get("host.name")
+			if tt.wantResource == nil {
+				assert.False(t, ok)
+			} else {
+				assert.Equal(t, tt.wantResource, resourceHost.AsRaw())
+			}
+
+			lrs := rl.ScopeLogs().At(0).LogRecords()
+			require.Equal(t, len(tt.want), lrs.Len())
+			for i := 0; i < lrs.Len(); i++ {
+				attrs := lrs.At(i).Attributes()
+				ip, _ := attrs.Get("client.ip")
+				host, ok := attrs.Get("host.name")
+				want := tt.want[ip.Str()]
+				if want == nil {
+					assert.False(t, ok, ip.Str())
+					continue
+				}
+				require.True(t, ok, ip.Str())
+				assert.Equal(t, want, host.AsRaw(), ip.Str())
+				// Unrelated attributes are preserved.
+				assert.Equal(t, 3, attrs.Len())
+			}
+		})
+	}
+}
+
+func TestProcessLogsOverwrite(t *testing.T) {
+	for _, overwrite := range []bool{false, true} {
+		sink := new(consumertest.LogsSink)
+		cfg := newTestConfig(t, map[string]any{"overwrite": overwrite})
+		proc, err := NewFactory().CreateLogs(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, sink)
+		require.NoError(t, err)
+
+		ld := plog.NewLogs()
+		attrs := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().Attributes()
+		attrs.PutStr("client.ip", "10.0.0.1")
+		attrs.PutStr("host.name", "existing")
+		require.NoError(t, proc.ConsumeLogs(t.Context(), ld))
+
+		host, _ := sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Attributes().Get("host.name")
+		if overwrite {
+			assert.Equal(t, "web-1", host.Str())
+		} else {
+			assert.Equal(t, "existing", host.Str())
+		}
+	}
+}
+
+func TestProcessFlatten(t *testing.T) {
+	geoSources := map[string]any{
+		"hosts": map[string]any{
+			"type": "static",
+			"entries": map[string]any{
+				"10.0.0.1": map[string]any{
+					"country": "PT",
+					"city":    "Lisbon",
+					"location": map[string]any{
+						"lat": 38.7,
+						"lon": -9.1,
+					},
+				},
+				"10.0.0.2": "web-2",
+			},
+		},
+	}
+	tests := []struct {
+		name     string
+		settings map[string]any
+		// existing attributes are set on every record before processing.
+		existing map[string]any
+		// want maps each record's client.ip to its expected attributes,
+		// client.ip included.
+		want map[string]map[string]any
+	}{
+		{
+			name: "nested maps",
+			want: map[string]map[string]any{
+				"10.0.0.1": {
+					"client.ip":        "10.0.0.1",
+					"geo.country":      "PT",
+					"geo.city":         "Lisbon",
+					"geo.location.lat": 38.7,
+					"geo.location.lon": -9.1,
+				},
+				"10.0.0.2": {"client.ip": "10.0.0.2", "geo": "web-2"},
+			},
+		},
+		{
+			name:     "separator and max depth",
+			settings: map[string]any{"flatten_separator": "_", "flatten_max_depth": 1},
+			want: map[string]map[string]any{
+				"10.0.0.1": {
+					"client.ip":    "10.0.0.1",
+					"geo_country":  "PT",
+					"geo_city":     "Lisbon",
+					"geo_location": map[string]any{"lat": 38.7, "lon": -9.1},
+				},
+				"10.0.0.2": {"client.ip": "10.0.0.2", "geo": "web-2"},
+			},
+		},
+		{
+			name:     "existing attributes kept",
+			existing: map[string]any{"geo.city": "Porto", "geo": "existing"},
+			want: map[string]map[string]any{
+				"10.0.0.1": {
+					"client.ip":        "10.0.0.1",
+					"geo":              "existing",
+					"geo.country":      "PT",
+					"geo.city":         "Porto",
+					"geo.location.lat": 38.7,
+					"geo.location.lon": -9.1,
+				},
+				"10.0.0.2": {"client.ip": "10.0.0.2", "geo": "existing", "geo.city": "Porto"},
+			},
+		},
+		{
+			name:     "existing attributes overwritten",
+			settings: map[string]any{"overwrite": true},
+			existing: map[string]any{"geo.city": "Porto"},
+			want: map[string]map[string]any{
+				"10.0.0.1": {
+					"client.ip":        "10.0.0.1",
+					"geo.country":      "PT",
+					"geo.city":         "Lisbon",
+					"geo.location.lat": 38.7,
+					"geo.location.lon": -9.1,
+				},
+				"10.0.0.2": {"client.ip": "10.0.0.2", "geo": "web-2", "geo.city": "Porto"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := map[string]any{
+				"sources":          geoSources,
+				"target_attribute": "geo",
+				"flatten":          true,
+			}
+			for k, v := range tt.settings {
+				settings[k] = v
+			}
+			sink := new(consumertest.LogsSink)
+			proc, err := NewFactory().CreateLogs(t.Context(), processortest.NewNopSettings(metadata.Type), newTestConfig(t, settings), sink)
+			require.NoError(t, err)
+
+			ld := plog.NewLogs()
+			lrs := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+			for ip := range tt.want {
+				attrs := lrs.AppendEmpty().Attributes()
+				require.NoError(t, attrs.FromRaw(tt.existing))
+				attrs.PutStr("client.ip", ip)
+			}
+			require.NoError(t, proc.ConsumeLogs(t.Context(), ld))
+
+			lrs = sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords()
+			for i := 0; i < lrs.Len(); i++ {
+				attrs := lrs.At(i).Attributes()
+				ip, _ := attrs.Get("client.ip")
+				assert.Equal(t, tt.want[ip.Str()], attrs.AsRaw(), ip.Str())
+			}
+		})
+	}
+}
+
+func TestProcessTargetParts(t *testing.T) {
+	sources := map[string]any{
+		"hosts": map[string]any{
+			"type": "static",
+			"entries": map[string]any{
+				"10.0.0.1": "dc1.rack2.web-1",
+				"10.0.0.2": "dc1.rack2",
+				"10.0.0.3": "dc1.rack2.web-3.example.com",
+				"10.0.0.4": 42,
+			},
+		},
+	}
+	tests := []struct {
+		name     string
+		settings map[string]any
+		// existing attributes are set on every record before processing.
+		existing map[string]any
+		// want maps each record's client.ip to its expected attributes,
+		// client.ip included.
+		want map[string]map[string]any
+	}{
+		{
+			name: "defaults",
+			want: map[string]map[string]any{
+				"10.0.0.1": {"client.ip": "10.0.0.1", "host.dc": "dc1", "host.rack": "rack2", "host.name": "web-1"},
+				"10.0.0.2": {"client.ip": "10.0.0.2"},
+				"10.0.0.3": {"client.ip": "10.0.0.3", "host.dc": "dc1", "host.rack": "rack2", "host.name": "web-3.example.com"},
+				"10.0.0.4": {"client.ip": "10.0.0.4", "host": int64(42)},
+			},
+		},
+		{
+			name:     "partial",
+			settings: map[string]any{"on_too_few_parts": "partial", "on_too_many_parts": "partial"},
+			want: map[string]map[string]any{
+				"10.0.0.1": {"client.ip": "10.0.0.1", "host.dc": "dc1", "host.rack": "rack2", "host.name": "web-1"},
+				"10.0.0.2": {"client.ip": "10.0.0.2", "host.dc": "dc1", "host.rack": "rack2"},
+				"10.0.0.3": {"client.ip": "10.0.0.3", "host.dc": "dc1", "host.rack": "rack2", "host.name": "web-3"},
+				"10.0.0.4": {"client.ip": "10.0.0.4", "host": int64(42)},
+			},
+		},
+		{
+			name:     "skip too many",
+			settings: map[string]any{"on_too_many_parts": "skip"},
+			want: map[string]map[string]any{
+				"10.0.0.1": {"client.ip": "10.0.0.1", "host.dc": "dc1", "host.rack": "rack2", "host.name": "web-1"},
+				"10.0.0.2": {"client.ip": "10.0.0.2"},
+				"10.0.0.3": {"client.ip": "10.0.0.3"},
+				"10.0.0.4": {"client.ip": "10.0.0.4", "host": int64(42)},
+			},
+		},
+		{
+			name:     "discarded part and separators",
+			settings: map[string]any{"target_parts": []any{"", "rack"}, "split_separator": ".", "flatten_separator": "_", "on_too_many_parts": "partial"},
+			want: map[string]map[string]any{
+				"10.0.0.1": {"client.ip": "10.0.0.1", "host_rack": "rack2"},
+				"10.0.0.2": {"client.ip": "10.0.0.2", "host_rack": "rack2"},
+				"10.0.0.3": {"client.ip": "10.0.0.3", "host_rack": "rack2"},
+				"10.0.0.4": {"client.ip": "10.0.0.4", "host": int64(42)},
+			},
+		},
+		{
+			name:     "existing attributes kept",
+			existing: map[string]any{"host.rack": "rack9", "host": "existing"},
+			want: map[string]map[string]any{
+				"10.0.0.1": {"client.ip": "10.0.0.1", "host": "existing", "host.dc": "dc1", "host.rack": "rack9", "host.name": "web-1"},
+				"10.0.0.2": {"client.ip": "10.0.0.2", "host": "existing", "host.rack": "rack9"},
+				"10.0.0.3": {"client.ip": "10.0.0.3", "host": "existing", "host.dc": "dc1", "host.rack": "rack9", "host.name": "web-3.example.com"},
+				"10.0.0.4": {"client.ip": "10.0.0.4", "host": "existing", "host.rack": "rack9"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := map[string]any{
+				"sources":          sources,
+				"target_attribute": "host",
+				"target_parts":     []any{"dc", "rack", "name"},
+			}
+			maps.Copy(settings, tt.settings)
+			sink := new(consumertest.LogsSink)
+			proc, err := NewFactory().CreateLogs(t.Context(), processortest.NewNopSettings(metadata.Type), newTestConfig(t, settings), sink)
+			require.NoError(t, err)
+
+			ld := plog.NewLogs()
+			lrs := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+			for ip := range tt.want {
+				attrs := lrs.AppendEmpty().Attributes()
+				require.NoError(t, attrs.FromRaw(tt.existing))
+				attrs.PutStr("client.ip", ip)
+			}
+			require.NoError(t, proc.ConsumeLogs(t.Context(), ld))
+
+			lrs = sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords()
+			for i := 0; i < lrs.Len(); i++ {
+				attrs := lrs.At(i).Attributes()
+				ip, _ := attrs.Get("client.ip")
+				assert.Equal(t, tt.want[ip.Str()], attrs.AsRaw(), ip.Str())
+			}
+		})
+	}
+}
+
+func TestProcessAppendToArray(t *testing.T) {
+	sources := map[string]any{
+		"hosts": map[string]any{
+			"type": "static",
+			"entries": map[string]any{
+				"10.0.0.1": "web-1",
+				"10.0.0.2": []any{"web-2", "web-1"},
+			},
+		},
+	}
+	tests := []struct {
+		name     string
+		settings map[string]any
+		ip       string
+		// existing is the target attribute before processing, if set.
+		existing any
+		want     any
+	}{
+		{
+			name: "created",
+			ip:   "10.0.0.1",
+			want: []any{"web-1"},
+		},
+		{
+			name:     "appended",
+			ip:       "10.0.0.1",
+			existing: []any{"db-1", "web-1"},
+			want:     []any{"db-1", "web-1", "web-1"},
+		},
+		{
+			name:     "deduplicated",
+			settings: map[string]any{"append_deduplicate": true},
+			ip:       "10.0.0.1",
+			existing: []any{"db-1", "web-1"},
+			want:     []any{"db-1", "web-1"},
+		},
+		{
+			name:     "list result",
+			ip:       "10.0.0.2",
+			existing: []any{"db-1"},
+			want:     []any{"db-1", "web-2", "web-1"},
+		},
+		{
+			name:     "list result deduplicated",
+			settings: map[string]any{"append_deduplicate": true},
+			ip:       "10.0.0.2",
+			existing: []any{"web-1"},
+			want:     []any{"web-1", "web-2"},
+		},
+		{
+			name:     "scalar kept",
+			ip:       "10.0.0.1",
+			existing: "existing",
+			want:     "existing",
+		},
+		{
+			name:     "scalar overwritten",
+			settings: map[string]any{"overwrite": true},
+			ip:       "10.0.0.1",
+			existing: "existing",
+			want:     []any{"web-1"},
+		},
+		{
+			name:     "not found",
+			ip:       "10.0.0.9",
+			existing: []any{"db-1"},
+			want:     []any{"db-1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := map[string]any{
+				"sources":         sources,
+				"append_to_array": true,
+			}
+			for k, v := range tt.settings {
+				settings[k] = v
+			}
+			sink := new(consumertest.LogsSink)
+			proc, err := NewFactory().CreateLogs(t.Context(), processortest.NewNopSettings(metadata.Type), newTestConfig(t, settings), sink)
+			require.NoError(t, err)
+
+			ld := plog.NewLogs()
+			attrs := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().Attributes()
+			attrs.PutStr("client.ip", tt.ip)
+			if tt.existing != nil {
+				require.NoError(t, attrs.PutEmpty("host.name").FromRaw(tt.existing))
+			}
+			require.NoError(t, proc.ConsumeLogs(t.Context(), ld))
+
+			attrs = sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Attributes()
+			assert.Equal(t, tt.want, attrs.AsRaw()["host.name"])
+		})
+	}
+}
+
+func TestProcessTraces(t *testing.T) {
+	t.Run("span attributes", func(t *testing.T) {
+		sink := new(consumertest.TracesSink)
+		cfg := newTestConfig(t, map[string]any{"default_value": "unknown"})
+		proc, err := NewFactory().CreateTraces(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, sink)
+		require.NoError(t, err)
+		require.NoError(t, proc.ConsumeTraces(t.Context(), generateTestTraces()))
+
+		require.Len(t, sink.AllTraces(), 1)
+		want := map[string]string{"10.0.0.1": "web-1", "10.0.0.2": "web-2", "10.0.0.9": "unknown"}
+		spanCount := 0
+		rss := sink.AllTraces()[0].ResourceSpans()
+		for i := 0; i < rss.Len(); i++ {
+			_, ok := rss.At(i).Resource().Attributes().Get("host.name")
+			assert.False(t, ok)
+			sss := rss.At(i).ScopeSpans()
+			for j := 0; j < sss.Len(); j++ {
+				spans := sss.At(j).Spans()
+				for k := 0; k < spans.Len(); k++ {
+					spanCount++
+					attrs := spans.At(k).Attributes()
+					ip, _ := attrs.Get("client.ip")
+					host, ok := attrs.Get("host.name")
+					require.True(t, ok, spans.At(k).Name())
+					assert.Equal(t, want[ip.Str()], host.Str(), spans.At(k).Name())
+					method, ok := attrs.Get("http.method")
+					require.True(t, ok)
+					assert.Equal(t, "GET", method.Str())
+				}
+			}
+		}
+		assert.Equal(t, 12, spanCount)
+	})
+
+	t.Run("resource attributes", func(t *testing.T) {
+		sink := new(consumertest.TracesSink)
+		cfg := newTestConfig(t, map[string]any{"context": "resource"})
+		proc, err := NewFactory().CreateTraces(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, sink)
+		require.NoError(t, err)
+		require.NoError(t, proc.ConsumeTraces(t.Context(), generateTestTraces()))
+
+		rss := sink.AllTraces()[0].ResourceSpans()
+		for i, want := range []string{"web-1", "web-2"} {
+			host, ok := rss.At(i).Resource().Attributes().Get("host.name")
+			require.True(t, ok)
+			assert.Equal(t, want, host.Str())
+			_, ok = rss.At(i).ScopeSpans().At(0).Spans().At(0).Attributes().Get("host.name")
+			assert.False(t, ok)
+		}
+	})
+}
+
+func TestProcessMetrics(t *testing.T) {
+	t.Run("data point attributes", func(t *testing.T) {
+		sink := new(consumertest.MetricsSink)
+		cfg := newTestConfig(t, nil)
+		proc, err := NewFactory().CreateMetrics(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, sink)
+		require.NoError(t, err)
+		require.NoError(t, proc.ConsumeMetrics(t.Context(), generateTestMetrics()))
+
+		require.Len(t, sink.AllMetrics(), 1)
+		rm := sink.AllMetrics()[0].ResourceMetrics().At(0)
+		_, ok := rm.Resource().Attributes().Get("host.name")
+		assert.False(t, ok)
+
+		metrics := rm.ScopeMetrics().At(0).Metrics()
+		require.Equal(t, 5, metrics.Len())
+		for i := 0; i < metrics.Len(); i++ {
+			m := metrics.At(i)
+			var hosts []any
+			require.NoError(t, forEachDataPoint(m, func(_ any, attrs pcommon.Map) error {
+				host, ok := attrs.Get("host.name")
+				if !ok {
+					hosts = append(hosts, nil)
+					return nil
+				}
+				hosts = append(hosts, host.AsRaw())
+				return nil
+			}))
+			assert.Equal(t, []any{"web-1", "web-2", nil}, hosts, m.Type().String())
+		}
+	})
+
+	t.Run("resource attributes", func(t *testing.T) {
+		sink := new(consumertest.MetricsSink)
+		cfg := newTestConfig(t, map[string]any{"context": "resource"})
+		proc, err := NewFactory().CreateMetrics(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, sink)
+		require.NoError(t, err)
+		require.NoError(t, proc.ConsumeMetrics(t.Context(), generateTestMetrics()))
+
+		rm := sink.AllMetrics()[0].ResourceMetrics().At(0)
+		host, ok := rm.Resource().Attributes().Get("host.name")
+		require.True(t, ok)
+		assert.Equal(t, "web-2", host.Str())
+
+		metrics := rm.ScopeMetrics().At(0).Metrics()
+		for i := 0; i < metrics.Len(); i++ {
+			require.NoError(t, forEachDataPoint(metrics.At(i), func(_ any, attrs pcommon.Map) error {
+				_, ok := attrs.Get("host.name")
+				assert.False(t, ok)
+				return nil
+			}))
+		}
+	})
+}
+
+func TestProcessMetricsCardinalityLimit(t *testing.T) {
+	entries := map[string]any{}
+	for i := range 20 {
+		entries[fmt.Sprintf("10.0.1.%d", i)] = fmt.Sprintf("host-%d", i)
+	}
+	cfg := NewFactory().CreateDefaultConfig().(*Config)
+	require.NoError(t, confmap.NewFromStringMap(map[string]any{
+		"sources": map[string]any{
+			"hosts": map[string]any{"type": "static", "entries": entries},
+		},
+		"lookups": []any{
+			map[string]any{"source": "hosts", "source_attribute": "client.ip", "target_attribute": "host.name"},
+		},
+		"metrics_cardinality_limit": map[string]any{"max_values": 5, "overflow_value": "other"},
+	}).Unmarshal(cfg))
+	require.NoError(t, cfg.Validate())
+
+	// newMetrics returns a gauge with one data point per IP address,
+	// starting with 10.0.1.<from>.
+	newMetrics := func(from, count int) pmetric.Metrics {
+		md := pmetric.NewMetrics()
+		dps := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty().SetEmptyGauge().DataPoints()
+		for i := from; i < from+count; i++ {
+			dps.AppendEmpty().Attributes().PutStr("client.ip", fmt.Sprintf("10.0.1.%d", i))
+		}
+		return md
+	}
+	hosts := func(md pmetric.Metrics) map[string]int {
+		got := map[string]int{}
+		dps := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Gauge().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			host, _ := dps.At(i).Attributes().Get("host.name")
+			got[host.Str()]++
+		}
+		return got
+	}
+
+	reader := sdkmetric.NewManualReader()
+	set := processortest.NewNopSettings(metadata.Type)
+	set.MeterProvider = sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	sink := new(consumertest.MetricsSink)
+	proc, err := NewFactory().CreateMetrics(t.Context(), set, cfg, sink)
+	require.NoError(t, err)
+	require.NoError(t, proc.Start(t.Context(), componenttest.NewNopHost()))
+	defer func() { require.NoError(t, proc.Shutdown(t.Context())) }()
+
+	require.NoError(t, proc.ConsumeMetrics(t.Context(), newMetrics(0, 20)))
+	got := hosts(sink.AllMetrics()[0])
+	assert.Len(t, got, 6)
+	assert.Equal(t, 15, got["other"])
+
+	// Values within the budget are still written in later batches, and
+	// further values keep overflowing.
+	require.NoError(t, proc.ConsumeMetrics(t.Context(), newMetrics(0, 20)))
+	assert.Equal(t, got, hosts(sink.AllMetrics()[1]))
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(t.Context(), &rm))
+	var overflows int64
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "otelcol_lookup_cardinality_overflows" {
+				continue
+			}
+			for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				assert.Equal(t, attribute.NewSet(attribute.String("processor", "lookup"), attribute.String("target_attribute", "host.name")), dp.Attributes)
+				overflows += dp.Value
+			}
+		}
+	}
+	assert.Equal(t, int64(30), overflows)
+
+	// The limit doesn't apply to logs.
+	logsSink := new(consumertest.LogsSink)
+	logsProc, err := NewFactory().CreateLogs(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, logsSink)
+	require.NoError(t, err)
+	ld := plog.NewLogs()
+	lrs := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+	for i := range 20 {
+		lrs.AppendEmpty().Attributes().PutStr("client.ip", fmt.Sprintf("10.0.1.%d", i))
+	}
+	require.NoError(t, logsProc.ConsumeLogs(t.Context(), ld))
+	lrs = logsSink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords()
+	for i := 0; i < lrs.Len(); i++ {
+		host, _ := lrs.At(i).Attributes().Get("host.name")
+		assert.Equal(t, fmt.Sprintf("host-%d", i), host.Str())
+	}
+}
+
+func TestProcessConditions(t *testing.T) {
+	t.Run("resource path in record context", func(t *testing.T) {
+		sink := new(consumertest.TracesSink)
+		cfg := newTestConfig(t, map[string]any{
+			"conditions": []any{`resource.attributes["client.ip"] == "10.0.0.1"`},
+		})
+		proc, err := NewFactory().CreateTraces(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, sink)
+		require.NoError(t, err)
+		require.NoError(t, proc.ConsumeTraces(t.Context(), generateTestTraces()))
+
+		want := map[string]any{"10.0.0.1": "web-1", "10.0.0.2": "web-2", "10.0.0.9": nil}
+		rss := sink.AllTraces()[0].ResourceSpans()
+		for i := 0; i < rss.Len(); i++ {
+			sss := rss.At(i).ScopeSpans()
+			for j := 0; j < sss.Len(); j++ {
+				spans := sss.At(j).Spans()
+				for k := 0; k < spans.Len(); k++ {
+					attrs := spans.At(k).Attributes()
+					ip, _ := attrs.Get("client.ip")
+					host, ok := attrs.Get("host.name")
+					// Only spans of the first resource match.
+					if i != 0 || want[ip.Str()] == nil {
+						assert.False(t, ok, spans.At(k).Name())
+						continue
+					}
+					require.True(t, ok, spans.At(k).Name())
+					assert.Equal(t, want[ip.Str()], host.Str(), spans.At(k).Name())
+				}
+			}
+		}
+	})
+
+	t.Run("resource context", func(t *testing.T) {
+		sink := new(consumertest.TracesSink)
+		cfg := newTestConfig(t, map[string]any{
+			"context":    "resource",
+			"conditions": []any{`attributes["client.ip"] == "10.0.0.2"`},
+		})
+		proc, err := NewFactory().CreateTraces(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, sink)
+		require.NoError(t, err)
+		require.NoError(t, proc.ConsumeTraces(t.Context(), generateTestTraces()))
+
+		rss := sink.AllTraces()[0].ResourceSpans()
+		_, ok := rss.At(0).Resource().Attributes().Get("host.name")
+		assert.False(t, ok)
+		host, ok := rss.At(1).Resource().Attributes().Get("host.name")
+		require.True(t, ok)
+		assert.Equal(t, "web-2", host.Str())
+	})
+
+	t.Run("data points", func(t *testing.T) {
+		sink := new(consumertest.MetricsSink)
+		cfg := newTestConfig(t, map[string]any{
+			"conditions": []any{`attributes["client.ip"] == "10.0.0.1"`},
+		})
+		proc, err := NewFactory().CreateMetrics(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, sink)
+		require.NoError(t, err)
+		require.NoError(t, proc.ConsumeMetrics(t.Context(), generateTestMetrics()))
+
+		metrics := sink.AllMetrics()[0].ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+		for i := 0; i < metrics.Len(); i++ {
+			var hosts []any
+			require.NoError(t, forEachDataPoint(metrics.At(i), func(_ any, attrs pcommon.Map) error {
+				host, ok := attrs.Get("host.name")
+				if !ok {
+					hosts = append(hosts, nil)
+					return nil
+				}
+				hosts = append(hosts, host.AsRaw())
+				return nil
+			}))
+			assert.Equal(t, []any{"web-1", nil, nil}, hosts, metrics.At(i).Name())
+		}
+	})
+
+	t.Run("invalid condition", func(t *testing.T) {
+		cfg := newTestConfig(t, map[string]any{"conditions": []any{`attributes["client.ip"] ==`}})
+		_, err := NewFactory().CreateLogs(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, consumertest.NewNop())
+		assert.Error(t, err)
+	})
+}
+
+func TestProcessOTTLExpressions(t *testing.T) {
+	t.Run("composed key", func(t *testing.T) {
+		sink := new(consumertest.LogsSink)
+		cfg := newTestConfig(t, map[string]any{
+			"sources": map[string]any{
+				"hosts": map[string]any{
+					"type": "static",
+					"entries": map[string]any{
+						"10.0.0.1:GET": "web-1",
+						"10.0.0.2:get": "web-2",
+					},
+				},
+			},
+			"source_attribute": `Concat([attributes["client.ip"], attributes["http.method"]], ":")`,
+		})
+		proc, err := NewFactory().CreateLogs(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, sink)
+		require.NoError(t, err)
+		require.NoError(t, proc.ConsumeLogs(t.Context(), generateTestLogs()))
+
+		lrs := sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords()
+		host, ok := lrs.At(0).Attributes().Get("host.name")
+		require.True(t, ok)
+		assert.Equal(t, "web-1", host.Str())
+		// Keys are case sensitive.
+		_, ok = lrs.At(1).Attributes().Get("host.name")
+		assert.False(t, ok)
+	})
+
+	t.Run("transformed key and target path", func(t *testing.T) {
+		sink := new(consumertest.TracesSink)
+		cfg := newTestConfig(t, map[string]any{
+			"sources": map[string]any{
+				"hosts": map[string]any{
+					"type":    "static",
+					"entries": map[string]any{"get": "read"},
+				},
+			},
+			"source_attribute": `ToLowerCase(attributes["http.method"])`,
+			"target_attribute": `resource.attributes["http.kind"]`,
+		})
+		proc, err := NewFactory().CreateTraces(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, sink)
+		require.NoError(t, err)
+		require.NoError(t, proc.ConsumeTraces(t.Context(), generateTestTraces()))
+
+		rss := sink.AllTraces()[0].ResourceSpans()
+		for i := 0; i < rss.Len(); i++ {
+			kind, ok := rss.At(i).Resource().Attributes().Get("http.kind")
+			require.True(t, ok)
+			assert.Equal(t, "read", kind.Str())
+			_, ok = rss.At(i).ScopeSpans().At(0).Spans().At(0).Attributes().Get("http.kind")
+			assert.False(t, ok)
+		}
+	})
+
+	t.Run("missing key", func(t *testing.T) {
+		sink := new(consumertest.LogsSink)
+		cfg := newTestConfig(t, map[string]any{
+			"source_attribute": `attributes["missing"]`,
+			"target_attribute": `attributes["host.name"]`,
+			"default_value":    "unknown",
+		})
+		proc, err := NewFactory().CreateLogs(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, sink)
+		require.NoError(t, err)
+		require.NoError(t, proc.ConsumeLogs(t.Context(), generateTestLogs()))
+
+		lrs := sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords()
+		for i := 0; i < lrs.Len(); i++ {
+			_, ok := lrs.At(i).Attributes().Get("host.name")
+			assert.False(t, ok)
+		}
+	})
+
+	t.Run("invalid expression", func(t *testing.T) {
+		cfg := newTestConfig(t, map[string]any{"source_attribute": `Unknown(attributes["client.ip"])`})
+		_, err := NewFactory().CreateLogs(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, consumertest.NewNop())
+		assert.ErrorContains(t, err, "invalid source_attribute")
+	})
+}
+
+func TestProcessClientMetadata(t *testing.T) {
+	withMetadata := func(md map[string][]string) context.Context {
+		return client.NewContext(t.Context(), client.Info{Metadata: client.NewMetadata(md)})
+	}
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		settings map[string]any
+		// want is the host.name expected on every record, or nil if it
+		// must be absent.
+		want any
+	}{
+		{
+			name: "metadata key",
+			ctx:  withMetadata(map[string][]string{"x-forwarded-for": {"10.0.0.1", "10.0.0.2"}}),
+			want: "web-1",
+		},
+		{
+			name: "missing metadata key",
+			ctx:  withMetadata(map[string][]string{"x-real-ip": {"10.0.0.1"}}),
+		},
+		{
+			name: "no client info",
+			ctx:  t.Context(),
+		},
+		{
+			name:     "default value",
+			ctx:      withMetadata(map[string][]string{"x-forwarded-for": {"10.0.0.9"}}),
+			settings: map[string]any{"default_value": "unknown"},
+			want:     "unknown",
+		},
+		{
+			name:     "resource context",
+			ctx:      withMetadata(map[string][]string{"x-forwarded-for": {"10.0.0.2"}}),
+			settings: map[string]any{"context": "resource"},
+			want:     "web-2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := map[string]any{"source_attribute": "context.x-forwarded-for"}
+			for k, v := range tt.settings {
+				settings[k] = v
+			}
+			sink := new(consumertest.LogsSink)
+			proc, err := NewFactory().CreateLogs(t.Context(), processortest.NewNopSettings(metadata.Type), newTestConfig(t, settings), sink)
+			require.NoError(t, err)
+			require.NoError(t, proc.ConsumeLogs(tt.ctx, generateTestLogs()))
+
+			rl := sink.AllLogs()[0].ResourceLogs().At(0)
+			attrs := []pcommon.Map{rl.Resource().Attributes()}
+			if tt.settings["context"] == nil {
+				attrs = attrs[:0]
+				lrs := rl.ScopeLogs().At(0).LogRecords()
+				for i := 0; i < lrs.Len(); i++ {
+					attrs = append(attrs, lrs.At(i).Attributes())
+				}
+			}
+			for _, m := range attrs {
+				host, ok := m.Get("host.name")
+				if tt.want == nil {
+					assert.False(t, ok)
+					continue
+				}
+				require.True(t, ok)
+				assert.Equal(t, tt.want, host.AsRaw())
+			}
+		})
+	}
+}
+
+func TestProcessSourceAttributes(t *testing.T) {
+	// connections resolves the owner of a connection from its address and
+	// port, like a SQL source querying both.
+	connections := lookupsource.NewSourceFactory(
+		"connections",
+		func() lookupsource.SourceConfig { return &slowSourceConfig{} },
+		func(context.Context, lookupsource.CreateSettings, lookupsource.SourceConfig) (lookupsource.Source, error) {
+			return lookupsource.NewSource(nil, func() string { return "connections" }, nil, nil,
+				lookupsource.WithMultiKeyLookup(func(_ context.Context, keys map[string]string) (any, bool, error) {
+					switch keys["ip"] + ":" + keys["port"] {
+					case "10.0.0.1:443":
+						return "nginx", true, nil
+					case "10.0.0.1:5432":
+						return "postgres", true, nil
+					}
+					return nil, false, nil
+				}),
+			), nil
+		},
+	)
+	factory := NewFactoryWithOptions(WithSources(connections))
+	newConfig := func(factory processor.Factory, sourceType string) *Config {
+		cfg := factory.CreateDefaultConfig().(*Config)
+		require.NoError(t, confmap.NewFromStringMap(map[string]any{
+			"sources": map[string]any{"owners": map[string]any{"type": sourceType}},
+			"lookups": []any{map[string]any{
+				"source":            "owners",
+				"source_attributes": map[string]any{"ip": "server.address", "port": "server.port"},
+				"target_attribute":  "server.owner",
+			}},
+		}).Unmarshal(cfg))
+		require.NoError(t, cfg.Validate())
+		return cfg
+	}
+
+	sink := new(consumertest.LogsSink)
+	proc, err := factory.CreateLogs(t.Context(), processortest.NewNopSettings(metadata.Type), newConfig(factory, "connections"), sink)
+	require.NoError(t, err)
+	require.NoError(t, proc.Start(t.Context(), componenttest.NewNopHost()))
+	defer func() { require.NoError(t, proc.Shutdown(t.Context())) }()
+
+	ld := plog.NewLogs()
+	lrs := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+	for _, conn := range []struct {
+		ip   string
+		port int64
+	}{{"10.0.0.1", 443}, {"10.0.0.1", 5432}, {"10.0.0.2", 443}, {"10.0.0.1", 0}} {
+		attrs := lrs.AppendEmpty().Attributes()
+		attrs.PutStr("server.address", conn.ip)
+		if conn.port != 0 {
+			attrs.PutInt("server.port", conn.port)
+		}
+	}
+	require.NoError(t, proc.ConsumeLogs(t.Context(), ld))
+
+	var got []any
+	lrs = sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords()
+	for i := 0; i < lrs.Len(); i++ {
+		owner, ok := lrs.At(i).Attributes().Get("server.owner")
+		if !ok {
+			got = append(got, nil)
+			continue
+		}
+		got = append(got, owner.AsRaw())
+	}
+	// Records missing an attribute of the compound key are not looked up.
+	assert.Equal(t, []any{"nginx", "postgres", nil, nil}, got)
+
+	_, err = NewFactory().CreateLogs(t.Context(), processortest.NewNopSettings(metadata.Type), newConfig(NewFactory(), "static"), consumertest.NewNop())
+	require.ErrorContains(t, err, `lookups[0]: source "owners" of type "static" doesn't support source_attributes`)
+}
+
+func TestProcessRouting(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		// want maps the cloud.provider of each record, or "" for the
+		// record without it, to its expected cloud.instance.
+		want map[string]any
+	}{
+		{
+			name:   "default source",
+			source: "other",
+			want:   map[string]any{"aws": "i-aws", "gcp": "gce-1", "azure": "vm-other", "": "vm-other"},
+		},
+		{
+			name: "no default source",
+			want: map[string]any{"aws": "i-aws", "gcp": "gce-1", "azure": nil, "": nil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries := func(val string) map[string]any {
+				return map[string]any{"type": "static", "entries": map[string]any{"10.0.0.1": val}}
+			}
+			cfg := newTestConfig(t, map[string]any{
+				"sources": map[string]any{
+					"aws":   entries("i-aws"),
+					"gcp":   entries("gce-1"),
+					"other": entries("vm-other"),
+				},
+				"source":           tt.source,
+				"target_attribute": "cloud.instance",
+				"route_by":         "cloud.provider",
+				"routes":           map[string]any{"aws": "aws", "gcp": "gcp"},
+			})
+
+			sink := new(consumertest.LogsSink)
+			proc, err := NewFactory().CreateLogs(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, sink)
+			require.NoError(t, err)
+			require.NoError(t, proc.Start(t.Context(), componenttest.NewNopHost()))
+			defer func() { require.NoError(t, proc.Shutdown(t.Context())) }()
+
+			ld := plog.NewLogs()
+			lrs := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+			for _, provider := range []string{"aws", "gcp", "azure", ""} {
+				attrs := lrs.AppendEmpty().Attributes()
+				attrs.PutStr("client.ip", "10.0.0.1")
+				if provider != "" {
+					attrs.PutStr("cloud.provider", provider)
+				}
+			}
+			require.NoError(t, proc.ConsumeLogs(t.Context(), ld))
+
+			got := map[string]any{}
+			lrs = sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords()
+			for i := 0; i < lrs.Len(); i++ {
+				attrs := lrs.At(i).Attributes()
+				var provider string
+				if val, ok := attrs.Get("cloud.provider"); ok {
+					provider = val.Str()
+				}
+				got[provider] = nil
+				if instance, ok := attrs.Get("cloud.instance"); ok {
+					got[provider] = instance.AsRaw()
+				}
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestProcessOnError(t *testing.T) {
+	// failing answers 10.0.0.1 and fails every other lookup, like a source
+	// whose backend went down after caching one key.
+	failing := lookupsource.NewSourceFactory(
+		"failing",
+		func() lookupsource.SourceConfig { return &slowSourceConfig{} },
+		func(context.Context, lookupsource.CreateSettings, lookupsource.SourceConfig) (lookupsource.Source, error) {
+			return lookupsource.NewSource(func(_ context.Context, key string) (any, bool, error) {
+				if key == "10.0.0.1" {
+					return "web-1", true, nil
+				}
+				return nil, false, errors.New("connection refused")
+			}, func() string { return "failing" }, nil, nil), nil
+		},
+	)
+	factory := NewFactoryWithOptions(WithSources(failing))
+
+	tests := []struct {
+		name     string
+		settings map[string]any
+		want     map[string]any
+		wantErr  string
+	}{
+		{
+			name:     "skip",
+			settings: map[string]any{"default_value": "unknown"},
+			want:     map[string]any{"10.0.0.1": "web-1", "10.0.0.2": nil, "10.0.0.9": nil},
+		},
+		{
+			name:     "default",
+			settings: map[string]any{"on_error": "default", "default_value": "unknown", "skip_on_not_found": true},
+			want:     map[string]any{"10.0.0.1": "web-1", "10.0.0.2": "unknown", "10.0.0.9": "unknown"},
+		},
+		{
+			name:     "default without default value",
+			settings: map[string]any{"on_error": "default"},
+			want:     map[string]any{"10.0.0.1": "web-1", "10.0.0.2": nil, "10.0.0.9": nil},
+		},
+		{
+			name:     "propagate",
+			settings: map[string]any{"on_error": "propagate", "default_value": "unknown"},
+			wantErr:  `lookups[0]: lookup in source "hosts" failed: connection refused`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := map[string]any{
+				"sources": map[string]any{"hosts": map[string]any{"type": "failing"}},
+				"lookups": []any{map[string]any{
+					"source":           "hosts",
+					"source_attribute": "client.ip",
+					"target_attribute": "host.name",
+				}},
+			}
+			for k, v := range tt.settings {
+				if k == "on_error" {
+					conf[k] = v
+				} else {
+					conf["lookups"].([]any)[0].(map[string]any)[k] = v
+				}
+			}
+			cfg := factory.CreateDefaultConfig().(*Config)
+			require.NoError(t, confmap.NewFromStringMap(conf).Unmarshal(cfg))
+			require.NoError(t, cfg.Validate())
+
+			sink := new(consumertest.LogsSink)
+			proc, err := factory.CreateLogs(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, sink)
+			require.NoError(t, err)
+			require.NoError(t, proc.Start(t.Context(), componenttest.NewNopHost()))
+			defer func() { require.NoError(t, proc.Shutdown(t.Context())) }()
+
+			err = proc.ConsumeLogs(t.Context(), generateTestLogs())
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				assert.Empty(t, sink.AllLogs())
+				return
+			}
+			require.NoError(t, err)
+			hosts := map[string]any{}
+			lrs := sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords()
+			for i := 0; i < lrs.Len(); i++ {
+				collectHost(hosts, lrs.At(i).Attributes())
+			}
+			assert.Equal(t, tt.want, hosts)
+		})
+	}
+}
+
+func TestProcessCacheBypass(t *testing.T) {
+	var calls atomic.Int64
+	factory := NewFactoryWithOptions(WithSources(lookupsource.NewSourceFactory(
+		"cached",
+		func() lookupsource.SourceConfig { return &slowSourceConfig{} },
+		func(context.Context, lookupsource.CreateSettings, lookupsource.SourceConfig) (lookupsource.Source, error) {
+			cache := lookupsource.NewCache(lookupsource.CacheConfig{Enabled: true})
+			lookup := lookupsource.WrapWithCache(cache, func(_ context.Context, key string) (any, bool, error) {
+				return fmt.Sprintf("host-%s-%d", key, calls.Add(1)), true, nil
+			})
+			return lookupsource.NewSource(lookup, func() string { return "cached" }, nil, cache.Shutdown), nil
+		},
+	)))
+	cfg := factory.CreateDefaultConfig().(*Config)
+	require.NoError(t, confmap.NewFromStringMap(map[string]any{
+		"sources":                   map[string]any{"hosts": map[string]any{"type": "cached"}},
+		"lookups":                   []any{map[string]any{"source": "hosts", "source_attribute": "client.ip", "target_attribute": "host.name"}},
+		"cache_bypass_metadata_key": "x-lookup-refresh",
+	}).Unmarshal(cfg))
+	require.NoError(t, cfg.Validate())
+
+	sink := new(consumertest.LogsSink)
+	proc, err := factory.CreateLogs(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, sink)
+	require.NoError(t, err)
+	require.NoError(t, proc.Start(t.Context(), componenttest.NewNopHost()))
+	defer func() { require.NoError(t, proc.Shutdown(t.Context())) }()
+
+	newLogs := func() plog.Logs {
+		ld := plog.NewLogs()
+		ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().Attributes().PutStr("client.ip", "10.0.0.1")
+		return ld
+	}
+	withRefresh := func(value string) context.Context {
+		return client.NewContext(t.Context(), client.Info{Metadata: client.NewMetadata(map[string][]string{"x-lookup-refresh": {value}})})
+	}
+	host := func(ctx context.Context) string {
+		sink.Reset()
+		require.NoError(t, proc.ConsumeLogs(ctx, newLogs()))
+		val, _ := sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Attributes().Get("host.name")
+		return val.Str()
+	}
+
+	assert.Equal(t, "host-10.0.0.1-1", host(t.Context()))
+	assert.Equal(t, "host-10.0.0.1-1", host(withRefresh("false")))
+	// The refreshed result replaces the cached one.
+	assert.Equal(t, "host-10.0.0.1-2", host(withRefresh("true")))
+	assert.Equal(t, "host-10.0.0.1-2", host(t.Context()))
+	assert.Equal(t, int64(2), calls.Load())
+}
+
+func TestProcessSourceKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]any
+		// want maps each record's client.ip to its expected host.name, or
+		// to nil if host.name must be absent.
+		want map[string]any
+	}{
+		{
+			name:     "resource and record attributes",
+			settings: map[string]any{"source_key": "{resource.client.ip}/{attributes.client.ip}"},
+			want:     map[string]any{"10.0.0.1": "pair-1", "10.0.0.2": nil, "10.0.0.9": "pair-9"},
+		},
+		{
+			name:     "missing attribute skips",
+			settings: map[string]any{"source_key": "{attributes.client.ip}:{attributes.client.port}"},
+			want:     map[string]any{"10.0.0.1": nil, "10.0.0.2": nil, "10.0.0.9": nil},
+		},
+		{
+			name: "missing attribute substituted",
+			settings: map[string]any{
+				"source_key":         "{attributes.client.ip}:{attributes.client.port}",
+				"source_key_missing": "empty",
+			},
+			want: map[string]any{"10.0.0.1": "no-port", "10.0.0.2": nil, "10.0.0.9": nil},
+		},
+		{
+			name:     "literal braces",
+			settings: map[string]any{"source_key": "{{{attributes.http.method}}}"},
+			want:     map[string]any{"10.0.0.1": "braced", "10.0.0.2": "braced", "10.0.0.9": "braced"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := map[string]any{
+				"source_attribute": "",
+				"sources": map[string]any{
+					"hosts": map[string]any{
+						"type": "static",
+						"entries": map[string]any{
+							"10.0.0.2/10.0.0.1": "pair-1",
+							"10.0.0.2/10.0.0.9": "pair-9",
+							"10.0.0.1:":         "no-port",
+							"{GET}":             "braced",
+						},
+					},
+				},
+			}
+			for k, v := range tt.settings {
+				settings[k] = v
+			}
+			sink := new(consumertest.LogsSink)
+			proc, err := NewFactory().CreateLogs(t.Context(), processortest.NewNopSettings(metadata.Type), newTestConfig(t, settings), sink)
+			require.NoError(t, err)
+			require.NoError(t, proc.ConsumeLogs(t.Context(), generateTestLogs()))
+
+			lrs := sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords()
+			for i := 0; i < lrs.Len(); i++ {
+				attrs := lrs.At(i).Attributes()
+				ip, _ := attrs.Get("client.ip")
+				host, ok := attrs.Get("host.name")
+				if want := tt.want[ip.Str()]; want != nil {
+					require.True(t, ok, ip.Str())
+					assert.Equal(t, want, host.AsRaw(), ip.Str())
+				} else {
+					assert.False(t, ok, ip.Str())
+				}
+			}
+		})
+	}
+}
+
+func TestProcessNotFound(t *testing.T) {
+	// signals run the test data of one signal through a processor created
+	// with cfg and return the host.name of each record by client.ip.
+	signals := map[string]func(t *testing.T, cfg *Config) map[string]any{
+		"logs": func(t *testing.T, cfg *Config) map[string]any {
+			sink := new(consumertest.LogsSink)
+			proc, err := NewFactory().CreateLogs(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, sink)
+			require.NoError(t, err)
+			require.NoError(t, proc.ConsumeLogs(t.Context(), generateTestLogs()))
+			hosts := map[string]any{}
+			lrs := sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords()
+			for i := 0; i < lrs.Len(); i++ {
+				collectHost(hosts, lrs.At(i).Attributes())
+			}
+			return hosts
+		},
+		"traces": func(t *testing.T, cfg *Config) map[string]any {
+			sink := new(consumertest.TracesSink)
+			proc, err := NewFactory().CreateTraces(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, sink)
+			require.NoError(t, err)
+			require.NoError(t, proc.ConsumeTraces(t.Context(), generateTestTraces()))
+			hosts := map[string]any{}
+			spans := sink.AllTraces()[0].ResourceSpans().At(0).ScopeSpans().At(0).Spans()
+			for i := 0; i < spans.Len(); i++ {
+				collectHost(hosts, spans.At(i).Attributes())
+			}
+			return hosts
+		},
+		"metrics": func(t *testing.T, cfg *Config) map[string]any {
+			sink := new(consumertest.MetricsSink)
+			proc, err := NewFactory().CreateMetrics(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, sink)
+			require.NoError(t, err)
+			require.NoError(t, proc.ConsumeMetrics(t.Context(), generateTestMetrics()))
+			hosts := map[string]any{}
+			metric := sink.AllMetrics()[0].ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0)
+			require.NoError(t, forEachDataPoint(metric, func(_ any, attrs pcommon.Map) error {
+				collectHost(hosts, attrs)
+				return nil
+			}))
+			return hosts
+		},
+	}
+
+	tests := []struct {
+		name     string
+		settings map[string]any
+		want     map[string]any
+	}{
+		{
+			name: "no default",
+			want: map[string]any{"10.0.0.1": "web-1", "10.0.0.2": "web-2", "10.0.0.9": nil},
+		},
+		{
+			name:     "default value",
+			settings: map[string]any{"default_value": "unknown"},
+			want:     map[string]any{"10.0.0.1": "web-1", "10.0.0.2": "web-2", "10.0.0.9": "unknown"},
+		},
+		{
+			name:     "skip on not found",
+			settings: map[string]any{"default_value": "unknown", "skip_on_not_found": true},
+			want:     map[string]any{"10.0.0.1": "web-1", "10.0.0.2": "web-2", "10.0.0.9": nil},
+		},
+		{
+			// Keys found with an empty value are written as such, rather
+			// than treated as not found.
+			name: "found empty",
+			settings: map[string]any{
+				"sources": map[string]any{
+					"hosts": map[string]any{
+						"type":    "static",
+						"entries": map[string]any{"10.0.0.1": "web-1", "10.0.0.2": ""},
+					},
+				},
+				"default_value": "unknown",
+			},
+			want: map[string]any{"10.0.0.1": "web-1", "10.0.0.2": "", "10.0.0.9": "unknown"},
+		},
+	}
+
+	for _, tt := range tests {
+		for signal, hostsByIP := range signals {
+			t.Run(tt.name+"/"+signal, func(t *testing.T) {
+				assert.Equal(t, tt.want, hostsByIP(t, newTestConfig(t, tt.settings)))
+			})
+		}
+	}
+}
+
+// collectHost records the host.name of attrs, or nil if absent, under its
+// client.ip.
+func collectHost(hosts map[string]any, attrs pcommon.Map) {
+	ip, _ := attrs.Get("client.ip")
+	host, ok := attrs.Get("host.name")
+	if !ok {
+		hosts[ip.Str()] = nil
+		return
+	}
+	hosts[ip.Str()] = host.AsRaw()
+}
+
+func TestProcessMultiValue(t *testing.T) {
+	sources := map[string]any{
+		"hosts": map[string]any{
+			"type": "static",
+			"entries": map[string]any{
+				"10.0.0.1": []any{"web-1.example.com", "web.example.com"},
+				"10.0.0.2": []any{},
+			},
+		},
+	}
+	tests := []struct {
+		name     string
+		settings map[string]any
+		want     map[string]any
+	}{
+		{
+			name: "array",
+			want: map[string]any{"10.0.0.1": []any{"web-1.example.com", "web.example.com"}, "10.0.0.2": []any{}, "10.0.0.9": nil},
+		},
+		{
+			name:     "join",
+			settings: map[string]any{"multi_value": "join", "multi_value_separator": " "},
+			want:     map[string]any{"10.0.0.1": "web-1.example.com web.example.com", "10.0.0.2": "", "10.0.0.9": nil},
+		},
+		{
+			name:     "first",
+			settings: map[string]any{"multi_value": "first", "default_value": "unknown"},
+			want:     map[string]any{"10.0.0.1": "web-1.example.com", "10.0.0.2": "unknown", "10.0.0.9": "unknown"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := map[string]any{"sources": sources}
+			for k, v := range tt.settings {
+				settings[k] = v
+			}
+			sink := new(consumertest.LogsSink)
+			proc, err := NewFactory().CreateLogs(t.Context(), processortest.NewNopSettings(metadata.Type), newTestConfig(t, settings), sink)
+			require.NoError(t, err)
+			require.NoError(t, proc.Start(t.Context(), componenttest.NewNopHost()))
+			defer func() { require.NoError(t, proc.Shutdown(t.Context())) }()
+
+			// The second batch is answered from the same results, so every
+			// mode applies equally to repeated lookups.
+			for range 2 {
+				require.NoError(t, proc.ConsumeLogs(t.Context(), generateTestLogs()))
+			}
+			for _, ld := range sink.AllLogs() {
+				hosts := map[string]any{}
+				lrs := ld.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords()
+				for i := 0; i < lrs.Len(); i++ {
+					collectHost(hosts, lrs.At(i).Attributes())
+				}
+				assert.Equal(t, tt.want, hosts)
+			}
+		})
+	}
+}
+
+func TestProcessSkipKeys(t *testing.T) {
+	sources := map[string]any{
+		"hosts": map[string]any{
+			"type": "static",
+			"entries": map[string]any{
+				"10.0.0.1":    "web-1.internal",
+				"192.168.1.5": "printer.internal",
+				"8.8.8.8":     "dns.google",
+				"1.1.1.1":     "one.one.one.one",
+				"-":           "placeholder",
+			},
+		},
+	}
+	skipKeys := map[string]any{
+		"values": []any{"-"},
+		"cidrs":  []any{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"},
+	}
+	tests := []struct {
+		name     string
+		skipKeys map[string]any
+		want     map[string]any
+	}{
+		{
+			name:     "skipped",
+			skipKeys: skipKeys,
+			want:     map[string]any{"10.0.0.1": nil, "192.168.1.5": nil, "8.8.8.8": "dns.google", "1.1.1.1": "one.one.one.one", "-": nil},
+		},
+		{
+			name:     "default value",
+			skipKeys: map[string]any{"cidrs": skipKeys["cidrs"], "default_value": "private"},
+			want:     map[string]any{"10.0.0.1": "private", "192.168.1.5": "private", "8.8.8.8": "dns.google", "1.1.1.1": "one.one.one.one", "-": "placeholder"},
+		},
+		{
+			name:     "pattern",
+			skipKeys: map[string]any{"patterns": []any{`1\..*`}},
+			want:     map[string]any{"10.0.0.1": "web-1.internal", "192.168.1.5": "printer.internal", "8.8.8.8": "dns.google", "1.1.1.1": nil, "-": "placeholder"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := new(consumertest.LogsSink)
+			cfg := newTestConfig(t, map[string]any{"sources": sources, "skip_keys": tt.skipKeys})
+			proc, err := NewFactory().CreateLogs(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, sink)
+			require.NoError(t, err)
+			require.NoError(t, proc.Start(t.Context(), componenttest.NewNopHost()))
+			defer func() { require.NoError(t, proc.Shutdown(t.Context())) }()
+
+			ld := plog.NewLogs()
+			lrs := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+			for ip := range tt.want {
+				lrs.AppendEmpty().Attributes().PutStr("client.ip", ip)
+			}
+			require.NoError(t, proc.ConsumeLogs(t.Context(), ld))
+
+			hosts := map[string]any{}
+			lrs = sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords()
+			for i := 0; i < lrs.Len(); i++ {
+				collectHost(hosts, lrs.At(i).Attributes())
+			}
+			assert.Equal(t, tt.want, hosts)
+		})
+	}
+}
+
+func TestProcessNormalizeKey(t *testing.T) {
+	keys := []string{"Host:443 ", "host", "HOST:8080", "web-1"}
+	tests := []struct {
+		name         string
+		normalizeKey map[string]any
+		want         []string
+		wantLookups  int64
+	}{
+		{
+			name:         "normalized",
+			normalizeKey: map[string]any{"trim_space": true, "lowercase": true, "strip_port": true},
+			want:         []string{"host-host", "host-host", "host-host", "host-web-1"},
+			wantLookups:  2,
+		},
+		{
+			name:        "raw keys",
+			want:        []string{"host-Host:443 ", "host-host", "host-HOST:8080", "host-web-1"},
+			wantLookups: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &cachedSource{}
+			factory := NewFactoryWithOptions(WithSources(source.factory()))
+			cfg := factory.CreateDefaultConfig().(*Config)
+			rule := map[string]any{"source": "hosts", "source_attribute": "client.ip", "target_attribute": "host.name"}
+			if tt.normalizeKey != nil {
+				rule["normalize_key"] = tt.normalizeKey
+			}
+			require.NoError(t, confmap.NewFromStringMap(map[string]any{
+				"sources": map[string]any{
+					"hosts": map[string]any{"type": "cached", "cache": map[string]any{"enabled": true}},
+				},
+				"lookups": []any{rule},
+			}).Unmarshal(cfg))
+			require.NoError(t, cfg.Validate())
+
+			sink := new(consumertest.LogsSink)
+			proc, err := factory.CreateLogs(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, sink)
+			require.NoError(t, err)
+			require.NoError(t, proc.Start(t.Context(), componenttest.NewNopHost()))
+			defer func() { require.NoError(t, proc.Shutdown(t.Context())) }()
+
+			// The second batch is answered by the cache, whose entries the
+			// spellings of a normalized key share.
+			for range 2 {
+				ld := plog.NewLogs()
+				lrs := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+				for _, key := range keys {
+					lrs.AppendEmpty().Attributes().PutStr("client.ip", key)
+				}
+				require.NoError(t, proc.ConsumeLogs(t.Context(), ld))
+			}
+			assert.Equal(t, tt.wantLookups, source.misses.Load())
+
+			for _, ld := range sink.AllLogs() {
+				lrs := ld.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords()
+				var got []string
+				for i := 0; i < lrs.Len(); i++ {
+					host, _ := lrs.At(i).Attributes().Get("host.name")
+					got = append(got, host.Str())
+				}
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestProcessTransform(t *testing.T) {
+	sources := map[string]any{
+		"hosts": map[string]any{
+			"type": "static",
+			"entries": map[string]any{
+				"10.0.0.1": "WEB-1.example.com.",
+				"10.0.0.2": 42,
+			},
+		},
+	}
+	transform := []any{
+		map[string]any{"type": "trim_suffix", "suffix": ".example.com."},
+		map[string]any{"type": "lowercase"},
+	}
+	tests := []struct {
+		name     string
+		settings map[string]any
+		want     map[string]any
+	}{
+		{
+			name:     "skip non-string",
+			settings: map[string]any{"transform": transform, "default_value": "UNKNOWN"},
+			want:     map[string]any{"10.0.0.1": "web-1", "10.0.0.2": int64(42), "10.0.0.9": "UNKNOWN"},
+		},
+		{
+			name:     "error on non-string",
+			settings: map[string]any{"transform": transform, "transform_non_string": "error"},
+			want:     map[string]any{"10.0.0.1": "web-1", "10.0.0.2": nil, "10.0.0.9": nil},
+		},
+		{
+			name: "after multi value",
+			settings: map[string]any{
+				"sources": map[string]any{
+					"hosts": map[string]any{
+						"type":    "static",
+						"entries": map[string]any{"10.0.0.1": []any{"WEB-1.example.com.", "web.example.com."}},
+					},
+				},
+				"multi_value": "first",
+				"transform":   transform,
+			},
+			want: map[string]any{"10.0.0.1": "web-1", "10.0.0.2": nil, "10.0.0.9": nil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := map[string]any{"sources": sources}
+			for k, v := range tt.settings {
+				settings[k] = v
+			}
+			sink := new(consumertest.LogsSink)
+			proc, err := NewFactory().CreateLogs(t.Context(), processortest.NewNopSettings(metadata.Type), newTestConfig(t, settings), sink)
+			require.NoError(t, err)
+			require.NoError(t, proc.Start(t.Context(), componenttest.NewNopHost()))
+			defer func() { require.NoError(t, proc.Shutdown(t.Context())) }()
+
+			require.NoError(t, proc.ConsumeLogs(t.Context(), generateTestLogs()))
+			hosts := map[string]any{}
+			lrs := sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords()
+			for i := 0; i < lrs.Len(); i++ {
+				collectHost(hosts, lrs.At(i).Attributes())
+			}
+			assert.Equal(t, tt.want, hosts)
+		})
+	}
+}
+
+func TestProcessLatencyAttribute(t *testing.T) {
+	const delay = 20 * time.Millisecond
+	factory := NewFactoryWithOptions(WithSources(lookupsource.NewSourceFactory(
+		"timed",
+		func() lookupsource.SourceConfig { return &slowSourceConfig{} },
+		func(context.Context, lookupsource.CreateSettings, lookupsource.SourceConfig) (lookupsource.Source, error) {
+			lookup := lookupsource.WithTiming(func(_ context.Context, key string) (any, bool, error) {
+				if key != "10.0.0.1" {
+					return nil, false, nil
+				}
+				time.Sleep(delay)
+				return "web-1.example.com", true, nil
+			})
+			return lookupsource.NewSource(lookup, func() string { return "timed" }, nil, nil), nil
+		},
+	)))
+	cfg := factory.CreateDefaultConfig().(*Config)
+	require.NoError(t, confmap.NewFromStringMap(map[string]any{
+		"sources": map[string]any{"hosts": map[string]any{"type": "timed"}},
+		"lookups": []any{map[string]any{
+			"source":           "hosts",
+			"source_attribute": "client.ip",
+			"target_attribute": "host.name",
+			"default_value":    "unknown",
+			"transform":        []any{map[string]any{"type": "uppercase"}},
+		}},
+	}).Unmarshal(cfg))
+	require.NoError(t, cfg.Validate())
+
+	sink := new(consumertest.LogsSink)
+	proc, err := factory.CreateLogs(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, sink)
+	require.NoError(t, err)
+	require.NoError(t, proc.Start(t.Context(), componenttest.NewNopHost()))
+	defer func() { require.NoError(t, proc.Shutdown(t.Context())) }()
+
+	require.NoError(t, proc.ConsumeLogs(t.Context(), generateTestLogs()))
+	lrs := sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords()
+	for i := 0; i < lrs.Len(); i++ {
+		attrs := lrs.At(i).Attributes()
+		ip, _ := attrs.Get("client.ip")
+		host, _ := attrs.Get("host.name")
+		latency, hasLatency := attrs.Get("host.name.lookup_ms")
+		if ip.Str() == "10.0.0.1" {
+			assert.Equal(t, "WEB-1.EXAMPLE.COM", host.Str())
+			require.True(t, hasLatency)
+			assert.Equal(t, pcommon.ValueTypeDouble, latency.Type())
+			assert.GreaterOrEqual(t, latency.Double(), float64(delay/time.Millisecond))
+			continue
+		}
+		// Default values are not looked up, so they have no latency.
+		assert.Equal(t, "unknown", host.Str())
+		assert.False(t, hasLatency)
+	}
+}
+
+func TestProcessErrorAttribute(t *testing.T) {
+	factory := NewFactoryWithOptions(WithSources(lookupsource.NewSourceFactory(
+		"reasons",
+		func() lookupsource.SourceConfig { return &slowSourceConfig{} },
+		func(context.Context, lookupsource.CreateSettings, lookupsource.SourceConfig) (lookupsource.Source, error) {
+			lookup := func(_ context.Context, key string) (any, bool, error) {
+				switch key {
+				case "10.0.0.1":
+					return "web-1", true, nil
+				case "10.0.0.2":
+					return nil, false, lookupsource.WithFailureReason(errors.New("server misbehaving"), "SERVFAIL")
+				}
+				return lookupsource.NotFoundReason("NXDOMAIN"), false, nil
+			}
+			return lookupsource.NewSource(lookup, func() string { return "reasons" }, nil, nil), nil
+		},
+	)))
+
+	tests := []struct {
+		name      string
+		onError   string
+		settings  map[string]any
+		wantHosts map[string]any
+	}{
+		{
+			name:      "skip",
+			onError:   "skip",
+			settings:  map[string]any{"default_value": "unknown"},
+			wantHosts: map[string]any{"10.0.0.1": "web-1", "10.0.0.2": nil, "10.0.0.9": "unknown"},
+		},
+		{
+			name:      "default",
+			onError:   "default",
+			settings:  map[string]any{"default_value": "unknown"},
+			wantHosts: map[string]any{"10.0.0.1": "web-1", "10.0.0.2": "unknown", "10.0.0.9": "unknown"},
+		},
+		{
+			name:      "skip on not found",
+			onError:   "skip",
+			settings:  map[string]any{"default_value": "unknown", "skip_on_not_found": true},
+			wantHosts: map[string]any{"10.0.0.1": "web-1", "10.0.0.2": nil, "10.0.0.9": nil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookup := map[string]any{
+				"source":           "hosts",
+				"source_attribute": "client.ip",
+				"target_attribute": "host.name",
+			}
+			maps.Copy(lookup, tt.settings)
+			cfg := factory.CreateDefaultConfig().(*Config)
+			require.NoError(t, confmap.NewFromStringMap(map[string]any{
+				"on_error": tt.onError,
+				"sources":  map[string]any{"hosts": map[string]any{"type": "reasons"}},
+				"lookups":  []any{lookup},
+			}).Unmarshal(cfg))
+			require.NoError(t, cfg.Validate())
+
+			sink := new(consumertest.LogsSink)
+			proc, err := factory.CreateLogs(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, sink)
+			require.NoError(t, err)
+			require.NoError(t, proc.Start(t.Context(), componenttest.NewNopHost()))
+			defer func() { require.NoError(t, proc.Shutdown(t.Context())) }()
+
+			require.NoError(t, proc.ConsumeLogs(t.Context(), generateTestLogs()))
+			hosts := map[string]any{}
+			reasons := map[string]any{}
+			lrs := sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords()
+			for i := 0; i < lrs.Len(); i++ {
+				attrs := lrs.At(i).Attributes()
+				ip, _ := attrs.Get("client.ip")
+				hosts[ip.Str()] = nil
+				if host, ok := attrs.Get("host.name"); ok {
+					hosts[ip.Str()] = host.Str()
+				}
+				if reason, ok := attrs.Get("host.name.lookup_error"); ok {
+					reasons[ip.Str()] = reason.Str()
+				}
+			}
+			assert.Equal(t, tt.wantHosts, hosts)
+			// Found keys have no reason, and misses keep theirs whether
+			// or not the default value is written.
+			assert.Equal(t, map[string]any{"10.0.0.2": "SERVFAIL", "10.0.0.9": "NXDOMAIN"}, reasons)
+		})
+	}
+}
+
+func TestProcessMultipleLookups(t *testing.T) {
+	sources := map[string]any{
+		"hosts": map[string]any{
+			"type":    "static",
+			"entries": map[string]any{"10.0.0.1": "web-1"},
+		},
+		"departments": map[string]any{
+			"type":    "static",
+			"entries": map[string]any{"u-1": "sales"},
+		},
+		"teams": map[string]any{
+			"type":    "static",
+			"entries": map[string]any{"web-1": "storefront"},
+		},
+	}
+	hostRule := map[string]any{"source": "hosts", "source_attribute": "client.ip", "target_attribute": "host.name"}
+	departmentRule := map[string]any{"source": "departments", "source_attribute": "user.id", "target_attribute": "user.department"}
+	// teamRule depends on the result of hostRule.
+	teamRule := map[string]any{"source": "teams", "source_attribute": "host.name", "target_attribute": "team"}
+
+	tests := []struct {
+		name    string
+		lookups []any
+		want    map[string]any
+	}{
+		{
+			name:    "independent lookups",
+			lookups: []any{hostRule, departmentRule},
+			want:    map[string]any{"host.name": "web-1", "user.department": "sales"},
+		},
+		{
+			name:    "dependent lookups in order",
+			lookups: []any{hostRule, teamRule},
+			want:    map[string]any{"host.name": "web-1", "team": "storefront"},
+		},
+		{
+			name:    "dependent lookups out of order",
+			lookups: []any{teamRule, hostRule},
+			want:    map[string]any{"host.name": "web-1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewFactory().CreateDefaultConfig().(*Config)
+			require.NoError(t, confmap.NewFromStringMap(map[string]any{
+				"sources": sources,
+				"lookups": tt.lookups,
+			}).Unmarshal(cfg))
+			require.NoError(t, cfg.Validate())
+
+			sink := new(consumertest.LogsSink)
+			proc, err := NewFactory().CreateLogs(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, sink)
+			require.NoError(t, err)
+			require.NoError(t, proc.Start(t.Context(), componenttest.NewNopHost()))
+			defer func() { require.NoError(t, proc.Shutdown(t.Context())) }()
+
+			ld := plog.NewLogs()
+			attrs := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().Attributes()
+			attrs.PutStr("client.ip", "10.0.0.1")
+			attrs.PutStr("user.id", "u-1")
+			require.NoError(t, proc.ConsumeLogs(t.Context(), ld))
+
+			got := sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Attributes().AsRaw()
+			delete(got, "client.ip")
+			delete(got, "user.id")
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSharedSource(t *testing.T) {
+	cfg := NewFactory().CreateDefaultConfig().(*Config)
+	require.NoError(t, confmap.NewFromStringMap(map[string]any{
+		"sources": map[string]any{
+			"hosts": map[string]any{"type": "static"},
+		},
+		"lookups": []any{
+			map[string]any{"source": "hosts", "source_attribute": "client.ip", "target_attribute": "client.host.name"},
+			map[string]any{"source": "hosts", "source_attribute": "server.ip", "target_attribute": "server.host.name"},
+		},
+	}).Unmarshal(cfg))
+
+	f := &lookupProcessorFactory{sources: sourceconfig.DefaultRegistry()}
+	proc, err := f.newProcessor(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, processorKey{}, (*lookupProcessor).parseLogRules)
+	require.NoError(t, err)
+	require.Len(t, proc.sources, 1)
+	require.Len(t, proc.rules, 2)
+	assert.Equal(t, proc.sources["hosts"], proc.rules[0].source)
+	assert.Equal(t, proc.sources["hosts"], proc.rules[1].source)
+}
+
+// generateTestMetrics returns one metric of each type, each with one data
+// point per known test IP.
+func generateTestMetrics() pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr("client.ip", "10.0.0.2")
+	metrics := rm.ScopeMetrics().AppendEmpty().Metrics()
+	ips := []string{"10.0.0.1", "10.0.0.2", "10.0.0.9"}
+
+	gauge := metrics.AppendEmpty()
+	gauge.SetName("gauge")
+	sum := metrics.AppendEmpty()
+	sum.SetName("sum")
+	histogram := metrics.AppendEmpty()
+	histogram.SetName("histogram")
+	expHistogram := metrics.AppendEmpty()
+	expHistogram.SetName("exponential_histogram")
+	summary := metrics.AppendEmpty()
+	summary.SetName("summary")
+
+	gaugeDps := gauge.SetEmptyGauge().DataPoints()
+	sumDps := sum.SetEmptySum().DataPoints()
+	histogramDps := histogram.SetEmptyHistogram().DataPoints()
+	expHistogramDps := expHistogram.SetEmptyExponentialHistogram().DataPoints()
+	summaryDps := summary.SetEmptySummary().DataPoints()
+	for _, ip := range ips {
+		gaugeDps.AppendEmpty().Attributes().PutStr("client.ip", ip)
+		sumDps.AppendEmpty().Attributes().PutStr("client.ip", ip)
+		histogramDps.AppendEmpty().Attributes().PutStr("client.ip", ip)
+		expHistogramDps.AppendEmpty().Attributes().PutStr("client.ip", ip)
+		summaryDps.AppendEmpty().Attributes().PutStr("client.ip", ip)
+	}
+	return md
+}
+
+// generateTestTraces returns two resources, with client.ip 10.0.0.1 and
+// 10.0.0.2, each holding two scopes with one span per known test IP.
+func generateTestTraces() ptrace.Traces {
+	td := ptrace.NewTraces()
+	ips := []string{"10.0.0.1", "10.0.0.2", "10.0.0.9"}
+	for i := range 2 {
+		rs := td.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("client.ip", ips[i])
+		for j := range 2 {
+			spans := rs.ScopeSpans().AppendEmpty().Spans()
+			for _, ip := range ips {
+				span := spans.AppendEmpty()
+				span.SetName(fmt.Sprintf("resource-%d/scope-%d/%s", i, j, ip))
+				span.Attributes().PutStr("client.ip", ip)
+				span.Attributes().PutStr("http.method", "GET")
+			}
+		}
+	}
+	return td
+}
+
+func generateTestLogs() plog.Logs {
+	ld := plog.NewLogs()
+	rl := ld.ResourceLogs().AppendEmpty()
+	rl.Resource().Attributes().PutStr("client.ip", "10.0.0.2")
+	lrs := rl.ScopeLogs().AppendEmpty().LogRecords()
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.9"} {
+		lr := lrs.AppendEmpty()
+		lr.Body().SetStr("request from " + ip)
+		lr.Attributes().PutStr("client.ip", ip)
+		lr.Attributes().PutStr("http.method", "GET")
+	}
+	return ld
+}
+
+func TestExposeExpvar(t *testing.T) {
+	source := &cachedSource{}
+	factory := NewFactoryWithOptions(WithSources(source.factory()))
+	cfg := factory.CreateDefaultConfig().(*Config)
+	require.NoError(t, confmap.NewFromStringMap(map[string]any{
+		"sources": map[string]any{
+			"hosts": map[string]any{"type": "cached", "cache": map[string]any{"enabled": true}},
+		},
+		"lookups":       []any{map[string]any{"source": "hosts", "source_attribute": "client.ip", "target_attribute": "host.name"}},
+		"expose_expvar": true,
+	}).Unmarshal(cfg))
+
+	proc, err := factory.CreateLogs(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, consumertest.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, proc.Start(t.Context(), componenttest.NewNopHost()))
+
+	// Three distinct keys looked up twice, in two batches.
+	require.NoError(t, proc.ConsumeLogs(t.Context(), generateTestLogs()))
+	require.NoError(t, proc.ConsumeLogs(t.Context(), generateTestLogs()))
+
+	readStats := func() map[string]map[string]int64 {
+		var got map[string]map[string]int64
+		require.NoError(t, json.Unmarshal([]byte(expvar.Get(lookupsource.ExpvarName).String()), &got))
+		return got
+	}
+	assert.Equal(t, map[string]int64{"hits": 3, "misses": 3, "size": 3, "evictions": 0}, readStats()["cached"])
+
+	require.NoError(t, proc.Shutdown(t.Context()))
+	assert.NotContains(t, readStats(), "cached")
+}
+
+func TestSourceMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	set := processortest.NewNopSettings(metadata.Type)
+	set.MeterProvider = sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	proc, err := NewFactory().CreateLogs(t.Context(), set, newTestConfig(t, nil), consumertest.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, proc.Start(t.Context(), componenttest.NewNopHost()))
+	defer func() { require.NoError(t, proc.Shutdown(t.Context())) }()
+
+	require.NoError(t, proc.ConsumeLogs(t.Context(), generateTestLogs()))
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(t.Context(), &rm))
+	got := make(map[attribute.Set]int64)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "otelcol_lookup_source_requests" {
+				continue
+			}
+			for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				got[dp.Attributes] = dp.Value
+			}
+		}
+	}
+	// Each distinct key of the batch is looked up once.
+	resultAttrs := func(result string) attribute.Set {
+		return attribute.NewSet(
+			attribute.String("processor", "lookup"),
+			attribute.String("source_type", "static"),
+			attribute.String("source_name", "hosts"),
+			attribute.String("result", result),
+		)
+	}
+	assert.Equal(t, map[attribute.Set]int64{resultAttrs("found"): 2, resultAttrs("not_found"): 1}, got)
+}
+
+func TestSourceMetricsProcessorInstances(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	source := &cachedSource{}
+	factory := NewFactoryWithOptions(WithSources(source.factory()))
+	cfg := factory.CreateDefaultConfig().(*Config)
+	require.NoError(t, confmap.NewFromStringMap(map[string]any{
+		"sources": map[string]any{
+			"hosts": map[string]any{"type": "cached", "cache": map[string]any{"enabled": true}},
+		},
+		"lookups": []any{map[string]any{"source": "hosts", "source_attribute": "client.ip", "target_attribute": "host.name"}},
+	}).Unmarshal(cfg))
+
+	// Two processors of the same config, in one collector, look up the same
+	// keys: the first one once, the second one twice.
+	for name, batches := range map[string]int{"a": 1, "b": 2} {
+		set := processortest.NewNopSettings(metadata.Type)
+		set.ID = component.MustNewIDWithName(metadata.Type.String(), name)
+		set.MeterProvider = meterProvider
+		proc, err := factory.CreateLogs(t.Context(), set, cfg, consumertest.NewNop())
+		require.NoError(t, err)
+		require.NoError(t, proc.Start(t.Context(), componenttest.NewNopHost()))
+		defer func() { require.NoError(t, proc.Shutdown(t.Context())) }()
+		for range batches {
+			require.NoError(t, proc.ConsumeLogs(t.Context(), generateTestLogs()))
+		}
+	}
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(t.Context(), &rm))
+	sums := func(name string) map[attribute.Set]int64 {
+		got := make(map[attribute.Set]int64)
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				if m.Name != name {
+					continue
+				}
+				for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+					got[dp.Attributes] = dp.Value
+				}
+			}
+		}
+		return got
+	}
+	sourceAttrs := func(id string, kvs ...attribute.KeyValue) attribute.Set {
+		return attribute.NewSet(append([]attribute.KeyValue{
+			attribute.String("processor", id),
+			attribute.String("source_type", "cached"),
+			attribute.String("source_name", "hosts"),
+		}, kvs...)...)
+	}
+	found := attribute.String("result", "found")
+	assert.Equal(t, map[attribute.Set]int64{
+		sourceAttrs("lookup/a", found): 3,
+		sourceAttrs("lookup/b", found): 6,
+	}, sums("otelcol_lookup_source_requests"))
+	assert.Equal(t, map[attribute.Set]int64{
+		sourceAttrs("lookup/a"): 0,
+		sourceAttrs("lookup/b"): 3,
+	}, sums("otelcol_lookup_source_cache_hits"))
+	assert.Equal(t, map[attribute.Set]int64{
+		sourceAttrs("lookup/a"): 3,
+		sourceAttrs("lookup/b"): 3,
+	}, sums("otelcol_lookup_source_cache_misses"))
+}
+
+type lifecycleSourceConfig struct {
+	ID        string `mapstructure:"id"`
+	FailStart bool   `mapstructure:"fail_start"`
+}
+
+func (*lifecycleSourceConfig) Validate() error { return nil }
+
+// lifecycleSource records the start and shutdown calls of the sources it
+// creates as "start <id>" and "shutdown <id>" events.
+type lifecycleSource struct {
+	events []string
+}
+
+func (s *lifecycleSource) factory() lookupsource.SourceFactory {
+	return lookupsource.NewSourceFactory(
+		"lifecycle",
+		func() lookupsource.SourceConfig { return &lifecycleSourceConfig{} },
+		func(_ context.Context, _ lookupsource.CreateSettings, cfg lookupsource.SourceConfig) (lookupsource.Source, error) {
+			sourceCfg := cfg.(*lifecycleSourceConfig)
+			return lookupsource.NewSource(
+				func(context.Context, string) (any, bool, error) { return nil, false, nil },
+				func() string { return "lifecycle" },
+				func(context.Context, component.Host) error {
+					s.events = append(s.events, "start "+sourceCfg.ID)
+					if sourceCfg.FailStart {
+						return errors.New("unreachable")
+					}
+					return nil
+				},
+				func(context.Context) error {
+					s.events = append(s.events, "shutdown "+sourceCfg.ID)
+					return nil
+				},
+			), nil
+		},
+	)
+}
+
+func TestSourceLifecycle(t *testing.T) {
+	newProcessor := func(t *testing.T, source *lifecycleSource, failStart bool) processor.Logs {
+		factory := NewFactoryWithOptions(WithSources(source.factory()))
+		cfg := factory.CreateDefaultConfig().(*Config)
+		require.NoError(t, confmap.NewFromStringMap(map[string]any{
+			"sources": map[string]any{
+				"hosts":  map[string]any{"type": "lifecycle", "id": "hosts"},
+				"owners": map[string]any{"type": "lifecycle", "id": "owners", "fail_start": failStart},
+			},
+			"lookups": []any{
+				map[string]any{"source": "hosts", "source_attribute": "client.ip", "target_attribute": "host.name"},
+				map[string]any{"source": "hosts", "source_attribute": "server.ip", "target_attribute": "server.host.name"},
+				map[string]any{"source": "owners", "source_attribute": "host.name", "target_attribute": "owner"},
+			},
+		}).Unmarshal(cfg))
+		proc, err := factory.CreateLogs(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, consumertest.NewNop())
+		require.NoError(t, err)
+		return proc
+	}
+
+	t.Run("start and shutdown", func(t *testing.T) {
+		source := &lifecycleSource{}
+		proc := newProcessor(t, source, false)
+		assert.Empty(t, source.events)
+
+		require.NoError(t, proc.Start(t.Context(), componenttest.NewNopHost()))
+		// Sources shared by several lookups are started once.
+		assert.Equal(t, []string{"start hosts", "start owners"}, source.events)
+
+		require.NoError(t, proc.Shutdown(t.Context()))
+		require.NoError(t, proc.Shutdown(t.Context()))
+		assert.Equal(t, []string{"start hosts", "start owners", "shutdown owners", "shutdown hosts"}, source.events)
+	})
+
+	t.Run("start error", func(t *testing.T) {
+		source := &lifecycleSource{}
+		proc := newProcessor(t, source, true)
+
+		err := proc.Start(t.Context(), componenttest.NewNopHost())
+		assert.ErrorContains(t, err, `failed to start source "owners": unreachable`)
+		// The sources started before the failure are shut down, and the
+		// collector shutting down the processor afterwards is a no-op.
+		require.NoError(t, proc.Shutdown(t.Context()))
+		assert.Equal(t, []string{"start hosts", "start owners", "shutdown hosts"}, source.events)
+	})
+
+	t.Run("resolve error", func(t *testing.T) {
+		source := &lifecycleSource{}
+		f := &lookupProcessorFactory{sources: lookupsource.NewRegistry()}
+		require.NoError(t, f.sources.Register(source.factory()))
+		cfg := f.createDefaultConfig().(*Config)
+		require.NoError(t, confmap.NewFromStringMap(map[string]any{
+			"sources": map[string]any{
+				"hosts": map[string]any{"type": "lifecycle", "id": "hosts"},
+				"users": map[string]any{"extension": "lookup"},
+			},
+			"lookups": []any{
+				map[string]any{"source": "hosts", "source_attribute": "client.ip", "target_attribute": "host.name"},
+				map[string]any{"source": "users", "source_attribute": "user.id", "target_attribute": "user.name"},
+			},
+			"expose_expvar": true,
+		}).Unmarshal(cfg))
+		proc, err := f.newProcessor(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, processorKey{}, (*lookupProcessor).parseLogRules)
+		require.NoError(t, err)
+
+		err = proc.Start(t.Context(), componenttest.NewNopHost())
+		assert.ErrorContains(t, err, `failed to resolve source "users"`)
+		// The started sources are shut down, and the cache metrics and
+		// expvars are unregistered, so that Start can be retried.
+		assert.Equal(t, []string{"start hosts", "shutdown hosts"}, source.events)
+		assert.Nil(t, proc.registration)
+		assert.Empty(t, proc.unpublish)
+	})
+}
+
+type warmingSourceConfig struct {
+	// Delay is how long the source takes to load its entries once started.
+	// Zero never loads them.
+	Delay time.Duration `mapstructure:"delay"`
+}
+
+func (*warmingSourceConfig) Validate() error { return nil }
+
+// warmingSource loads its entries in the background once started, finds no
+// key until then, and reports itself ready once they are loaded.
+type warmingSource struct {
+	ready    chan struct{}
+	shutdown atomic.Bool
+}
+
+func (s *warmingSource) factory() lookupsource.SourceFactory {
+	return lookupsource.NewSourceFactory(
+		"warming",
+		func() lookupsource.SourceConfig { return &warmingSourceConfig{} },
+		func(_ context.Context, _ lookupsource.CreateSettings, cfg lookupsource.SourceConfig) (lookupsource.Source, error) {
+			sourceCfg := cfg.(*warmingSourceConfig)
+			s.ready = make(chan struct{})
+			var loaded atomic.Bool
+			return lookupsource.NewSource(
+				func(context.Context, string) (any, bool, error) {
+					if !loaded.Load() {
+						return nil, false, nil
+					}
+					return "web-1", true, nil
+				},
+				func() string { return "warming" },
+				func(context.Context, component.Host) error {
+					if sourceCfg.Delay > 0 {
+						time.AfterFunc(sourceCfg.Delay, func() {
+							loaded.Store(true)
+							close(s.ready)
+						})
+					}
+					return nil
+				},
+				func(context.Context) error {
+					s.shutdown.Store(true)
+					return nil
+				},
+				lookupsource.WithReadiness(func(ctx context.Context) error {
+					select {
+					case <-s.ready:
+						return nil
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				}),
+			), nil
+		},
+	)
+}
+
+func TestProcessReadyTimeout(t *testing.T) {
+	newProcessor := func(t *testing.T, source *warmingSource, delay, readyTimeout time.Duration, next *consumertest.LogsSink) processor.Logs {
+		factory := NewFactoryWithOptions(WithSources(source.factory()))
+		cfg := factory.CreateDefaultConfig().(*Config)
+		require.NoError(t, confmap.NewFromStringMap(map[string]any{
+			"sources": map[string]any{
+				"hosts": map[string]any{"type": "warming", "delay": delay.String()},
+			},
+			"lookups": []any{
+				map[string]any{"source": "hosts", "source_attribute": "client.ip", "target_attribute": "host.name"},
+			},
+			"ready_timeout": readyTimeout.String(),
+		}).Unmarshal(cfg))
+		require.NoError(t, cfg.Validate())
+		proc, err := factory.CreateLogs(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, next)
+		require.NoError(t, err)
+		return proc
+	}
+
+	t.Run("waits for warmup", func(t *testing.T) {
+		const delay = 50 * time.Millisecond
+		sink := new(consumertest.LogsSink)
+		proc := newProcessor(t, &warmingSource{}, delay, 5*time.Second, sink)
+
+		start := time.Now()
+		require.NoError(t, proc.Start(t.Context(), componenttest.NewNopHost()))
+		defer func() { require.NoError(t, proc.Shutdown(t.Context())) }()
+		assert.GreaterOrEqual(t, time.Since(start), delay)
+
+		// The first records after Start are enriched.
+		ld := plog.NewLogs()
+		ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().
+			Attributes().PutStr("client.ip", "192.168.1.1")
+		require.NoError(t, proc.ConsumeLogs(t.Context(), ld))
+		got, ok := sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Attributes().Get("host.name")
+		require.True(t, ok)
+		assert.Equal(t, "web-1", got.Str())
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		source := &warmingSource{}
+		proc := newProcessor(t, source, 0, 0, new(consumertest.LogsSink))
+		require.NoError(t, proc.Start(t.Context(), componenttest.NewNopHost()))
+		require.NoError(t, proc.Shutdown(t.Context()))
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		source := &warmingSource{}
+		proc := newProcessor(t, source, 0, 20*time.Millisecond, new(consumertest.LogsSink))
+
+		err := proc.Start(t.Context(), componenttest.NewNopHost())
+		assert.ErrorContains(t, err, `source "hosts" is not ready after 20ms`)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		// The sources are shut down when the processor fails to start.
+		assert.True(t, source.shutdown.Load())
+		require.NoError(t, proc.Shutdown(t.Context()))
+	})
+}
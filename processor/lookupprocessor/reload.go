@@ -0,0 +1,166 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupprocessor // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+// ReloadHandover hands the started sources of a processor that shuts down
+// over to the processor created next with the same ID and signal, such as
+// when the collector reloads its configuration, so that they keep their
+// warm caches. The collector creates its factories again on reload, so a
+// distribution creates one handover, passes it to every factory with
+// [WithReloadHandover] and shuts it down once the collector has stopped.
+type ReloadHandover struct {
+	gracePeriod time.Duration
+
+	mu      sync.Mutex
+	retired map[processorKey]*retiredSources
+	// closed is set by Shutdown.
+	closed bool
+	// expiring tracks the sources shut down as their grace period ends.
+	expiring sync.WaitGroup
+}
+
+// NewReloadHandover returns a handover keeping the sources of a processor
+// that shuts down for up to gracePeriod, or until Shutdown if zero.
+func NewReloadHandover(gracePeriod time.Duration) *ReloadHandover {
+	return &ReloadHandover{
+		gracePeriod: gracePeriod,
+		retired:     make(map[processorKey]*retiredSources),
+	}
+}
+
+// Shutdown shuts down the sources no processor took over. Processors shutting
+// down afterwards shut their sources down themselves.
+func (h *ReloadHandover) Shutdown(ctx context.Context) error {
+	h.mu.Lock()
+	retired := h.retired
+	h.retired = nil
+	h.closed = true
+	h.mu.Unlock()
+
+	var errs error
+	for _, r := range retired {
+		if r.timer != nil {
+			r.timer.Stop()
+		}
+		errs = errors.Join(errs, r.shutdown(ctx))
+	}
+	h.expiring.Wait()
+	return errs
+}
+
+// retiredSources are the started sources of a shut down processor, kept
+// running by a [ReloadHandover].
+type retiredSources struct {
+	// sources are the sources by name, and started their names in start
+	// order.
+	sources map[string]lookupsource.Source
+	started []string
+	// configs are the configs the sources run with, by name.
+	configs map[string]SourceConfig
+	logger  *zap.Logger
+	// timer shuts the sources down once the grace period ends, if any.
+	timer *time.Timer
+}
+
+// retire keeps r for the processor of key created next. Sources retired
+// earlier for key and not taken over, and r once h is shut down, are shut
+// down.
+func (h *ReloadHandover) retire(ctx context.Context, key processorKey, r *retiredSources) error {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return r.shutdown(ctx)
+	}
+	previous := h.retired[key]
+	h.retired[key] = r
+	if h.gracePeriod > 0 {
+		r.timer = time.AfterFunc(h.gracePeriod, func() { h.expire(key, r) })
+	}
+	h.mu.Unlock()
+
+	if previous == nil {
+		return nil
+	}
+	if previous.timer != nil {
+		previous.timer.Stop()
+	}
+	return previous.shutdown(ctx)
+}
+
+// expire shuts r down if it is still retired for key.
+func (h *ReloadHandover) expire(key processorKey, r *retiredSources) {
+	h.mu.Lock()
+	expired := h.retired[key] == r
+	if expired {
+		delete(h.retired, key)
+		h.expiring.Add(1)
+	}
+	h.mu.Unlock()
+	if !expired {
+		return
+	}
+	defer h.expiring.Done()
+	if err := r.shutdown(context.Background()); err != nil {
+		r.logger.Warn("Failed to shut down sources not taken over", zap.Error(err))
+	}
+}
+
+// take removes the sources retired for key, if any, and returns them. They
+// are no longer shut down when the grace period ends. A nil h retires
+// nothing.
+func (h *ReloadHandover) take(key processorKey) *retiredSources {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r := h.retired[key]
+	if r != nil {
+		delete(h.retired, key)
+		if r.timer != nil {
+			r.timer.Stop()
+		}
+	}
+	return r
+}
+
+// handOver returns the sources of r, by name, that cfg configures again with
+// the same type, so that the processor created for cfg takes them over
+// instead of creating them again. It doesn't apply their new settings.
+func (r *retiredSources) handOver(cfg *Config) map[string]lookupsource.Source {
+	sources := make(map[string]lookupsource.Source)
+	for _, name := range r.started {
+		sourceCfg, ok := cfg.Sources[name]
+		if !ok || sourceCfg.Extension != nil || sourceCfg.typ() != r.configs[name].typ() {
+			continue
+		}
+		sources[name] = r.sources[name]
+	}
+	return sources
+}
+
+// shutdown shuts the sources down in reverse start order.
+func (r *retiredSources) shutdown(ctx context.Context) error {
+	var errs error
+	for i := len(r.started) - 1; i >= 0; i-- {
+		name := r.started[i]
+		if err := r.sources[name].Shutdown(ctx); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("failed to shut down source %q: %w", name, err))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,69 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupprocessor // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor"
+
+import (
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// routeFilter selects the records of a routed lookup rule that are looked up
+// in one of its sources, by the value of the route_by attribute.
+type routeFilter struct {
+	attribute string
+	values    map[string]struct{}
+	// other selects the records whose value isn't in values, or that lack
+	// the attribute, instead of those whose value is.
+	other bool
+}
+
+func (f *routeFilter) match(attrs pcommon.Map) bool {
+	val, ok := attrs.Get(f.attribute)
+	if !ok {
+		return f.other
+	}
+	_, listed := f.values[val.AsString()]
+	return listed != f.other
+}
+
+// routedRule is a lookup rule of a single source, and the records it
+// applies to if it comes from a rule with route_by.
+type routedRule struct {
+	cfg   *LookupRule
+	route *routeFilter
+}
+
+// expandRoutes splits rule, if it has route_by, into one rule per source:
+// one for each source of its routes, in the order of their first route
+// value, and then one for its default source, if any. Each rule is a copy of
+// rule looking its records up in its own source, so that routed rules
+// otherwise behave like any other rule.
+func expandRoutes(rule *LookupRule) []routedRule {
+	if rule.RouteBy == "" {
+		return []routedRule{{cfg: rule}}
+	}
+
+	var rules []routedRule
+	bySource := map[string]*routeFilter{}
+	routed := make(map[string]struct{}, len(rule.Routes))
+	for _, value := range sortedNames(rule.Routes) {
+		routed[value] = struct{}{}
+		source := rule.Routes[value]
+		if filter, ok := bySource[source]; ok {
+			filter.values[value] = struct{}{}
+			continue
+		}
+		cfg := *rule
+		cfg.Source = source
+		filter := &routeFilter{attribute: rule.RouteBy, values: map[string]struct{}{value: {}}}
+		bySource[source] = filter
+		rules = append(rules, routedRule{cfg: &cfg, route: filter})
+	}
+	if rule.Source != "" {
+		rules = append(rules, routedRule{
+			cfg:   rule,
+			route: &routeFilter{attribute: rule.RouteBy, values: routed, other: true},
+		})
+	}
+	return rules
+}
@@ -0,0 +1,65 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupprocessor // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor"
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand/v2"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// SampleMode selects which records a lookup with a sample_rate enriches.
+type SampleMode string
+
+const (
+	// SampleByTraceID samples records by a hash of their trace ID, so that
+	// every span and log record of a trace is enriched, or none is. Records
+	// without a trace ID, such as data points and resources, are sampled at
+	// random.
+	SampleByTraceID SampleMode = "trace_id"
+	// SampleRandom samples each record independently.
+	SampleRandom SampleMode = "random"
+)
+
+// newSampler returns a function reporting whether the record with trace ID
+// traceID is looked up, or nil if every record is.
+func newSampler(rate *float64, mode SampleMode) (func(traceID pcommon.TraceID) bool, error) {
+	if rate == nil || *rate >= 1 {
+		return nil, nil
+	}
+	switch mode {
+	case SampleByTraceID, SampleRandom:
+	default:
+		return nil, fmt.Errorf("invalid sample_by %q, must be %q or %q", mode, SampleByTraceID, SampleRandom)
+	}
+	threshold := *rate
+	return func(traceID pcommon.TraceID) bool {
+		if mode == SampleRandom || traceID.IsEmpty() {
+			return rand.Float64() < threshold
+		}
+		return traceIDFraction(traceID) < threshold
+	}, nil
+}
+
+// traceIDFraction maps traceID to a fraction in [0, 1). Both halves of the
+// ID are mixed in, since not every tracer randomizes the same bytes of it,
+// and sequential IDs must spread over the whole range.
+func traceIDFraction(traceID pcommon.TraceID) float64 {
+	hi := binary.BigEndian.Uint64(traceID[:8])
+	lo := binary.BigEndian.Uint64(traceID[8:])
+	return float64(mix64(hi^mix64(lo))>>11) / (1 << 53)
+}
+
+// mix64 is the finalizer of SplitMix64, whose every output bit depends on
+// every input bit.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,132 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupprocessor
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/processor/processortest"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/internal/metadata"
+)
+
+func TestSampler(t *testing.T) {
+	rate := func(r float64) *float64 { return &r }
+
+	sample, err := newSampler(nil, SampleByTraceID)
+	require.NoError(t, err)
+	assert.Nil(t, sample)
+	sample, err = newSampler(rate(1), SampleRandom)
+	require.NoError(t, err)
+	assert.Nil(t, sample)
+
+	_, err = newSampler(rate(0.5), "span_id")
+	assert.EqualError(t, err, `invalid sample_by "span_id", must be "trace_id" or "random"`)
+
+	sample, err = newSampler(rate(0), SampleRandom)
+	require.NoError(t, err)
+	for i := range 100 {
+		assert.False(t, sample(testTraceID(i)))
+	}
+
+	// The same trace is sampled the same way every time.
+	sample, err = newSampler(rate(0.5), SampleByTraceID)
+	require.NoError(t, err)
+	for i := range 100 {
+		want := sample(testTraceID(i))
+		for range 10 {
+			assert.Equal(t, want, sample(testTraceID(i)))
+		}
+	}
+}
+
+func TestProcessSampleRate(t *testing.T) {
+	const (
+		traces        = 1000
+		spansPerTrace = 4
+	)
+
+	t.Run("trace_id", func(t *testing.T) {
+		cfg := newTestConfig(t, map[string]any{"sample_rate": 0.3})
+		sink := new(consumertest.TracesSink)
+		proc, err := NewFactory().CreateTraces(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, sink)
+		require.NoError(t, err)
+		require.NoError(t, proc.Start(t.Context(), componenttest.NewNopHost()))
+		defer func() { require.NoError(t, proc.Shutdown(t.Context())) }()
+
+		td := ptrace.NewTraces()
+		spans := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+		for i := range traces {
+			for range spansPerTrace {
+				span := spans.AppendEmpty()
+				span.SetTraceID(testTraceID(i))
+				span.Attributes().PutStr("client.ip", "10.0.0.1")
+			}
+		}
+		require.NoError(t, proc.ConsumeTraces(t.Context(), td))
+
+		// Every span of a trace is enriched, or none is.
+		enriched := map[pcommon.TraceID]int{}
+		spans = sink.AllTraces()[0].ResourceSpans().At(0).ScopeSpans().At(0).Spans()
+		for i := 0; i < spans.Len(); i++ {
+			if _, ok := spans.At(i).Attributes().Get("host.name"); ok {
+				enriched[spans.At(i).TraceID()]++
+			}
+		}
+		for traceID, n := range enriched {
+			assert.Equal(t, spansPerTrace, n, "trace %s", traceID)
+		}
+		assert.InDelta(t, 0.3, float64(len(enriched))/traces, 0.05)
+	})
+
+	t.Run("random", func(t *testing.T) {
+		const records = traces * spansPerTrace
+		cfg := newTestConfig(t, map[string]any{"sample_rate": 0.3, "sample_by": "random"})
+		sink := new(consumertest.LogsSink)
+		proc, err := NewFactory().CreateLogs(t.Context(), processortest.NewNopSettings(metadata.Type), cfg, sink)
+		require.NoError(t, err)
+		require.NoError(t, proc.Start(t.Context(), componenttest.NewNopHost()))
+		defer func() { require.NoError(t, proc.Shutdown(t.Context())) }()
+
+		ld := plog.NewLogs()
+		lrs := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+		for i := range records {
+			lr := lrs.AppendEmpty()
+			// Records of the same trace are sampled independently.
+			lr.SetTraceID(testTraceID(i % traces))
+			lr.Attributes().PutStr("client.ip", "10.0.0.1")
+		}
+		require.NoError(t, proc.ConsumeLogs(t.Context(), ld))
+
+		enriched := 0
+		lrs = sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords()
+		for i := 0; i < lrs.Len(); i++ {
+			attrs := lrs.At(i).Attributes()
+			if host, ok := attrs.Get("host.name"); ok {
+				assert.Equal(t, "web-1", host.Str())
+				enriched++
+				continue
+			}
+			// Records not sampled are untouched.
+			assert.Equal(t, map[string]any{"client.ip": "10.0.0.1"}, attrs.AsRaw())
+		}
+		assert.InDelta(t, 0.3, float64(enriched)/records, 0.05)
+	})
+}
+
+// testTraceID returns a trace ID made of i, as sequential IDs are more
+// likely than random ones to expose a poor hash.
+func testTraceID(i int) pcommon.TraceID {
+	var traceID pcommon.TraceID
+	binary.BigEndian.PutUint64(traceID[8:], uint64(i)+1)
+	return traceID
+}
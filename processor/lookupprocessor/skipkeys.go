@@ -0,0 +1,80 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupprocessor // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor"
+
+import (
+	"fmt"
+	"net/netip"
+	"regexp"
+)
+
+// SkipKeys are keys a lookup never looks up, such as private IP addresses
+// for a reverse DNS source that only resolves public ones, or placeholders
+// like "-". A key is skipped if it matches any of them.
+type SkipKeys struct {
+	// Values are keys skipped when equal to the key.
+	Values []string `mapstructure:"values"`
+
+	// CIDRs are prefixes, such as "10.0.0.0/8", skipping the IP address
+	// keys they contain.
+	CIDRs []string `mapstructure:"cidrs"`
+
+	// Patterns are regular expressions, in the syntax of the Go regexp
+	// package, skipping the keys they match entirely.
+	Patterns []string `mapstructure:"patterns"`
+
+	// DefaultValue is written to the target attribute of records with a
+	// skipped key. If unset, they are left untouched.
+	DefaultValue any `mapstructure:"default_value"`
+}
+
+// compile returns a function reporting whether a key is skipped, or nil if
+// no key is.
+func (s SkipKeys) compile() (func(string) bool, error) {
+	if len(s.Values) == 0 && len(s.CIDRs) == 0 && len(s.Patterns) == 0 {
+		return nil, nil
+	}
+	values := make(map[string]struct{}, len(s.Values))
+	for _, v := range s.Values {
+		values[v] = struct{}{}
+	}
+	prefixes := make([]netip.Prefix, len(s.CIDRs))
+	for i, cidr := range s.CIDRs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("skip_keys: cidrs[%d]: invalid cidr %q: %w", i, cidr, err)
+		}
+		prefixes[i] = prefix.Masked()
+	}
+	patterns := make([]*regexp.Regexp, len(s.Patterns))
+	for i, pattern := range s.Patterns {
+		re, err := regexp.Compile(`^(?:` + pattern + `)$`)
+		if err != nil {
+			return nil, fmt.Errorf("skip_keys: patterns[%d]: invalid pattern %q: %w", i, pattern, err)
+		}
+		patterns[i] = re
+	}
+
+	return func(key string) bool {
+		if _, ok := values[key]; ok {
+			return true
+		}
+		if len(prefixes) > 0 {
+			if addr, err := netip.ParseAddr(key); err == nil {
+				addr = addr.WithZone("").Unmap()
+				for _, prefix := range prefixes {
+					if prefix.Contains(addr) {
+						return true
+					}
+				}
+			}
+		}
+		for _, re := range patterns {
+			if re.MatchString(key) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
@@ -0,0 +1,51 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupprocessor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSkipKeys(t *testing.T) {
+	skip, err := SkipKeys{
+		Values:   []string{"-", ""},
+		CIDRs:    []string{"10.0.0.0/8", "192.168.1.1/16", "fc00::/7"},
+		Patterns: []string{`unknown|n/a`, `.*\.internal`},
+	}.compile()
+	require.NoError(t, err)
+
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{key: "-", want: true},
+		{key: "--"},
+		{key: "10.1.2.3", want: true},
+		{key: "192.168.200.1", want: true},
+		{key: "::ffff:10.0.0.1", want: true},
+		{key: "fd12::1%eth0", want: true},
+		{key: "8.8.8.8"},
+		{key: "2001:db8::1"},
+		{key: "unknown", want: true},
+		{key: "n/a", want: true},
+		{key: "unknown-host"},
+		{key: "db.internal", want: true},
+		{key: "db.internal.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			assert.Equal(t, tt.want, skip(tt.key))
+		})
+	}
+}
+
+func TestSkipKeysEmpty(t *testing.T) {
+	skip, err := SkipKeys{DefaultValue: "skipped"}.compile()
+	require.NoError(t, err)
+	assert.Nil(t, skip)
+}
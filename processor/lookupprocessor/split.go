@@ -0,0 +1,119 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupprocessor // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor"
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.uber.org/zap"
+)
+
+// PartCountAction selects what a lookup with target_parts does with results
+// that split into fewer or more parts than there are target parts.
+type PartCountAction string
+
+const (
+	// PartCountSkip leaves the record unchanged.
+	PartCountSkip PartCountAction = "skip"
+	// PartCountPartial writes the parts there are: the first target parts
+	// when there are too few, and the first parts when there are too many,
+	// dropping the others.
+	PartCountPartial PartCountAction = "partial"
+	// PartCountJoinLast writes the last target part with the remaining
+	// parts joined by the separator, like a split into at most as many
+	// parts as there are target parts. Only valid with too many parts.
+	PartCountJoinLast PartCountAction = "join_last"
+)
+
+// validateSplit validates the target_parts settings of rule.
+func (rule *LookupRule) validateSplit() error {
+	if len(rule.TargetParts) == 0 {
+		return nil
+	}
+	if isExpression(rule.TargetAttribute) {
+		return errors.New("target_parts cannot be used with an OTTL target_attribute")
+	}
+	if rule.Flatten || rule.AppendToArray {
+		return errors.New("target_parts cannot be used with flatten or append_to_array")
+	}
+	if rule.SplitSeparator == "" {
+		return errors.New("split_separator must not be empty")
+	}
+	seen := make(map[string]bool, len(rule.TargetParts))
+	for _, part := range rule.TargetParts {
+		// Empty names discard their part.
+		if part == "" {
+			continue
+		}
+		if seen[part] {
+			return fmt.Errorf("target_parts: duplicate part %q", part)
+		}
+		seen[part] = true
+	}
+	if len(seen) == 0 {
+		return errors.New("target_parts must name at least one part")
+	}
+	switch rule.OnTooFewParts {
+	case PartCountSkip, PartCountPartial:
+	default:
+		return fmt.Errorf("invalid on_too_few_parts %q, must be %q or %q", rule.OnTooFewParts, PartCountSkip, PartCountPartial)
+	}
+	switch rule.OnTooManyParts {
+	case PartCountSkip, PartCountPartial, PartCountJoinLast:
+	default:
+		return fmt.Errorf("invalid on_too_many_parts %q, must be %q, %q or %q", rule.OnTooManyParts, PartCountSkip, PartCountPartial, PartCountJoinLast)
+	}
+	return nil
+}
+
+// splitParts splits s by the split separator into at most one part per
+// target part, following on_too_few_parts and on_too_many_parts when the
+// counts differ. It returns false if nothing should be written.
+func (r *lookupRule) splitParts(s string) ([]string, bool) {
+	n := len(r.cfg.TargetParts)
+	parts := strings.Split(s, r.cfg.SplitSeparator)
+	switch {
+	case len(parts) < n:
+		return parts, r.cfg.OnTooFewParts == PartCountPartial
+	case len(parts) > n:
+		switch r.cfg.OnTooManyParts {
+		case PartCountPartial:
+			return parts[:n], true
+		case PartCountJoinLast:
+			parts[n-1] = strings.Join(parts[n-1:], r.cfg.SplitSeparator)
+			return parts[:n], true
+		}
+		return nil, false
+	}
+	return parts, true
+}
+
+// putParts writes the parts of the string result s to one attribute per
+// target part, named after the target attribute and the part joined by
+// FlattenSeparator. With Overwrite false, each attribute that already
+// exists is kept.
+func (r *lookupRule) putParts(attrs pcommon.Map, s string) {
+	parts, ok := r.splitParts(s)
+	if !ok {
+		r.logger.Debug("Lookup result has an unexpected number of parts",
+			zap.Int("parts", strings.Count(s, r.cfg.SplitSeparator)+1), zap.Int("target_parts", len(r.cfg.TargetParts)))
+		return
+	}
+	for i, part := range parts {
+		name := r.cfg.TargetParts[i]
+		if name == "" {
+			continue
+		}
+		key := r.cfg.TargetAttribute + r.cfg.FlattenSeparator + name
+		if !r.cfg.Overwrite {
+			if _, exists := attrs.Get(key); exists {
+				continue
+			}
+		}
+		attrs.PutStr(key, part)
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupprocessor // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor"
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor/lookupsource"
+)
+
+// processorAttribute returns the attribute telling apart the series of the
+// processor with id from the ones of other lookup processors.
+func processorAttribute(id component.ID) attribute.KeyValue {
+	return attribute.String("processor", id.String())
+}
+
+// cacheMetrics are counters observed for the sources created by the
+// processor, with the processor, source_type and source_name attributes.
+// Sources of a lookup extension are reported by the extension.
+type cacheMetrics struct {
+	hits   metric.Int64ObservableCounter
+	misses metric.Int64ObservableCounter
+}
+
+func newCacheMetrics(meter metric.Meter) (*cacheMetrics, error) {
+	var m cacheMetrics
+	var err, errs error
+	m.hits, err = meter.Int64ObservableCounter(
+		"otelcol_lookup_source_cache_hits",
+		metric.WithDescription("Number of lookups answered by the cache of the source. Only reported for sources with an enabled cache."),
+		metric.WithUnit("{hits}"),
+	)
+	errs = errors.Join(errs, err)
+	m.misses, err = meter.Int64ObservableCounter(
+		"otelcol_lookup_source_cache_misses",
+		metric.WithDescription("Number of lookups not found in the cache of the source. Only reported for sources with an enabled cache."),
+		metric.WithUnit("{misses}"),
+	)
+	errs = errors.Join(errs, err)
+	return &m, errs
+}
+
+// register registers the callback observing the counters for sources, by
+// name, of the processor with id.
+func (m *cacheMetrics) register(meter metric.Meter, id component.ID, sources map[string]lookupsource.Source) (metric.Registration, error) {
+	return meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		for _, name := range sortedNames(sources) {
+			source := sources[name]
+			reporter, ok := source.(lookupsource.CacheStatsReporter)
+			if !ok {
+				continue
+			}
+			stats, ok := reporter.CacheStats()
+			if !ok {
+				continue
+			}
+			attrs := metric.WithAttributes(
+				processorAttribute(id),
+				attribute.String("source_type", source.Type()),
+				attribute.String("source_name", name),
+			)
+			o.ObserveInt64(m.hits, stats.Hits, attrs)
+			o.ObserveInt64(m.misses, stats.Misses, attrs)
+		}
+		return nil
+	}, m.hits, m.misses)
+}
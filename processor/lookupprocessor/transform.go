@@ -0,0 +1,139 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupprocessor // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/lookupprocessor"
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// TransformType is the operation of a transform step.
+type TransformType string
+
+const (
+	// TransformLowercase converts the value to lower case.
+	TransformLowercase TransformType = "lowercase"
+	// TransformUppercase converts the value to upper case.
+	TransformUppercase TransformType = "uppercase"
+	// TransformTrimSuffix removes TransformStep.Suffix from the end of the
+	// value.
+	TransformTrimSuffix TransformType = "trim_suffix"
+	// TransformRegexReplace replaces the matches of TransformStep.Pattern
+	// with TransformStep.Replacement.
+	TransformRegexReplace TransformType = "regex_replace"
+	// TransformSubstring keeps TransformStep.Length characters of the value
+	// from TransformStep.Start.
+	TransformSubstring TransformType = "substring"
+)
+
+// NonStringAction selects what the transform of a lookup does with results
+// that aren't strings.
+type NonStringAction string
+
+const (
+	// NonStringSkip writes the result without transforming it.
+	NonStringSkip NonStringAction = "skip"
+	// NonStringError handles the result as a failed lookup: it is logged
+	// and the record is left unchanged.
+	NonStringError NonStringAction = "error"
+)
+
+// TransformStep is one step of the transform of lookup results.
+type TransformStep struct {
+	// Type is the operation of the step: lowercase, uppercase, trim_suffix,
+	// regex_replace or substring.
+	Type TransformType `mapstructure:"type"`
+
+	// Suffix is removed from the end of the value by trim_suffix.
+	Suffix string `mapstructure:"suffix"`
+
+	// Pattern is the regular expression replaced by regex_replace, in the
+	// syntax of the Go regexp package.
+	Pattern string `mapstructure:"pattern"`
+
+	// Replacement replaces the matches of Pattern. $1 or ${name} expand to
+	// the submatches of the pattern.
+	Replacement string `mapstructure:"replacement"`
+
+	// Start is the index of the first character kept by substring. Values
+	// shorter than Start become empty.
+	Start int `mapstructure:"start"`
+
+	// Length is the number of characters kept by substring. Zero keeps the
+	// rest of the value.
+	Length int `mapstructure:"length"`
+}
+
+// compileTransform returns a function applying steps in order, or nil if
+// there are none.
+func compileTransform(steps []TransformStep) (func(string) string, error) {
+	if len(steps) == 0 {
+		return nil, nil
+	}
+	fns := make([]func(string) string, len(steps))
+	for i, step := range steps {
+		fn, err := step.compile()
+		if err != nil {
+			return nil, fmt.Errorf("transform[%d]: %w", i, err)
+		}
+		fns[i] = fn
+	}
+	return func(s string) string {
+		for _, fn := range fns {
+			s = fn(s)
+		}
+		return s
+	}, nil
+}
+
+func (step TransformStep) compile() (func(string) string, error) {
+	switch step.Type {
+	case TransformLowercase:
+		return strings.ToLower, nil
+	case TransformUppercase:
+		return strings.ToUpper, nil
+	case TransformTrimSuffix:
+		if step.Suffix == "" {
+			return nil, errors.New("suffix must be specified")
+		}
+		return func(s string) string { return strings.TrimSuffix(s, step.Suffix) }, nil
+	case TransformRegexReplace:
+		if step.Pattern == "" {
+			return nil, errors.New("pattern must be specified")
+		}
+		re, err := regexp.Compile(step.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern: %w", err)
+		}
+		return func(s string) string { return re.ReplaceAllString(s, step.Replacement) }, nil
+	case TransformSubstring:
+		if step.Start < 0 {
+			return nil, errors.New("start must not be negative")
+		}
+		if step.Length < 0 {
+			return nil, errors.New("length must not be negative")
+		}
+		return func(s string) string { return substring(s, step.Start, step.Length) }, nil
+	default:
+		return nil, fmt.Errorf("invalid type %q, must be %q, %q, %q, %q or %q", step.Type,
+			TransformLowercase, TransformUppercase, TransformTrimSuffix, TransformRegexReplace, TransformSubstring)
+	}
+}
+
+// substring returns length characters of s from start, counted in runes so
+// that multi-byte characters are never split. A zero length keeps the rest
+// of s.
+func substring(s string, start, length int) string {
+	runes := []rune(s)
+	if start >= len(runes) {
+		return ""
+	}
+	runes = runes[start:]
+	if length > 0 && length < len(runes) {
+		runes = runes[:length]
+	}
+	return string(runes)
+}
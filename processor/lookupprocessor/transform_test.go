@@ -0,0 +1,144 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lookupprocessor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileTransform(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []TransformStep
+		in    string
+		want  string
+	}{
+		{
+			name:  "lowercase",
+			steps: []TransformStep{{Type: TransformLowercase}},
+			in:    "Web-1.Example.COM",
+			want:  "web-1.example.com",
+		},
+		{
+			name:  "uppercase",
+			steps: []TransformStep{{Type: TransformUppercase}},
+			in:    "de",
+			want:  "DE",
+		},
+		{
+			name:  "trim suffix",
+			steps: []TransformStep{{Type: TransformTrimSuffix, Suffix: ".example.com."}},
+			in:    "web-1.example.com.",
+			want:  "web-1",
+		},
+		{
+			name:  "trim missing suffix",
+			steps: []TransformStep{{Type: TransformTrimSuffix, Suffix: ".example.com."}},
+			in:    "web-1.example.org.",
+			want:  "web-1.example.org.",
+		},
+		{
+			name:  "regex replace",
+			steps: []TransformStep{{Type: TransformRegexReplace, Pattern: `^arn:aws:iam::(\d+):.*$`, Replacement: "$1"}},
+			in:    "arn:aws:iam::123456789012:role/admin",
+			want:  "123456789012",
+		},
+		{
+			name:  "substring",
+			steps: []TransformStep{{Type: TransformSubstring, Start: 2, Length: 3}},
+			in:    "eu-west-1",
+			want:  "-we",
+		},
+		{
+			name:  "substring to end",
+			steps: []TransformStep{{Type: TransformSubstring, Start: 3}},
+			in:    "eu-west-1",
+			want:  "west-1",
+		},
+		{
+			name:  "substring past end",
+			steps: []TransformStep{{Type: TransformSubstring, Start: 20, Length: 2}},
+			in:    "eu-west-1",
+			want:  "",
+		},
+		{
+			name:  "substring of multi-byte characters",
+			steps: []TransformStep{{Type: TransformSubstring, Start: 1, Length: 2}},
+			in:    "Zürich",
+			want:  "ür",
+		},
+		{
+			name: "chained",
+			steps: []TransformStep{
+				{Type: TransformTrimSuffix, Suffix: ".example.com."},
+				{Type: TransformUppercase},
+			},
+			in:   "web-1.example.com.",
+			want: "WEB-1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transform, err := compileTransform(tt.steps)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, transform(tt.in))
+		})
+	}
+}
+
+func TestCompileTransformErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   []TransformStep
+		wantErr string
+	}{
+		{
+			name:    "unknown type",
+			steps:   []TransformStep{{Type: "reverse"}},
+			wantErr: `transform[0]: invalid type "reverse"`,
+		},
+		{
+			name:    "missing suffix",
+			steps:   []TransformStep{{Type: TransformLowercase}, {Type: TransformTrimSuffix}},
+			wantErr: "transform[1]: suffix must be specified",
+		},
+		{
+			name:    "missing pattern",
+			steps:   []TransformStep{{Type: TransformRegexReplace}},
+			wantErr: "transform[0]: pattern must be specified",
+		},
+		{
+			name:    "invalid pattern",
+			steps:   []TransformStep{{Type: TransformRegexReplace, Pattern: "("}},
+			wantErr: "transform[0]: invalid pattern",
+		},
+		{
+			name:    "negative start",
+			steps:   []TransformStep{{Type: TransformSubstring, Start: -1}},
+			wantErr: "transform[0]: start must not be negative",
+		},
+		{
+			name:    "negative length",
+			steps:   []TransformStep{{Type: TransformSubstring, Length: -1}},
+			wantErr: "transform[0]: length must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := compileTransform(tt.steps)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestCompileTransformEmpty(t *testing.T) {
+	transform, err := compileTransform(nil)
+	require.NoError(t, err)
+	assert.Nil(t, transform)
+}